@@ -1,9 +1,14 @@
 package main
 
 import (
+	"flag"
+
 	"github.com/shini4i/argo-watcher/internal/client"
 )
 
 func main() {
-	client.Run()
+	dryRunFlag := flag.Bool("dry-run", false, "Print the git change the deployment would write back instead of deploying.")
+	flag.Parse()
+
+	client.Run(*dryRunFlag)
 }
@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS idx_tasks_group_id;
+
+ALTER TABLE tasks DROP COLUMN IF EXISTS group_id;
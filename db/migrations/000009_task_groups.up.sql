@@ -0,0 +1,9 @@
+-- A deployment addressed to a label selector or an ApplicationSet is split into
+-- one task per matched application. group_id links those tasks together so their
+-- combined outcome can be reported; it is empty for a task created on its own.
+ALTER TABLE tasks ADD COLUMN IF NOT EXISTS group_id TEXT NOT NULL DEFAULT '';
+
+-- Groups are looked up by id, and most tasks belong to none, so the partial index
+-- stays as small as the grouped history.
+CREATE INDEX IF NOT EXISTS idx_tasks_group_id
+    ON tasks (group_id) WHERE group_id <> '';
@@ -0,0 +1,4 @@
+-- An automatic rollback is a task of its own, started by argo-watcher when a
+-- deployment of an application opted into it fails. rollback_of_id links it to
+-- that failed task; it is empty for every other task.
+ALTER TABLE tasks ADD COLUMN IF NOT EXISTS rollback_of_id TEXT NOT NULL DEFAULT '';
@@ -0,0 +1,3 @@
+-- signing_key names the key a task's write-back commit was signed with, such as
+-- "ssh:SHA256:...". It is empty when the task made no signed commit.
+ALTER TABLE tasks ADD COLUMN IF NOT EXISTS signing_key TEXT NOT NULL DEFAULT '';
@@ -0,0 +1,2 @@
+ALTER TABLE tasks DROP COLUMN IF EXISTS synced_revision;
+ALTER TABLE tasks DROP COLUMN IF EXISTS commit_sha;
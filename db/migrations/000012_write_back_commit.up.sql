@@ -0,0 +1,5 @@
+-- commit_sha is the commit a task's write-back pushed, and synced_revision the
+-- revision ArgoCD's last sync of the application applied when the rollout ended.
+-- Both are empty for a task that made no write-back commit.
+ALTER TABLE tasks ADD COLUMN IF NOT EXISTS commit_sha TEXT NOT NULL DEFAULT '';
+ALTER TABLE tasks ADD COLUMN IF NOT EXISTS synced_revision TEXT NOT NULL DEFAULT '';
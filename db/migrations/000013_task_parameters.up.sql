@@ -0,0 +1,4 @@
+-- parameters holds the Helm parameters a task writes back next to its image tags,
+-- as a JSON array of {name, value, force_string}. It is empty for a task that only
+-- deploys images.
+ALTER TABLE tasks ADD COLUMN IF NOT EXISTS parameters JSONB NOT NULL DEFAULT '[]';
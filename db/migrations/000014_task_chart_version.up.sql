@@ -0,0 +1,3 @@
+-- chart_version is the Helm chart version a chart version task writes into the
+-- application's manifest. It is empty for a task that deploys images.
+ALTER TABLE tasks ADD COLUMN IF NOT EXISTS chart_version TEXT NOT NULL DEFAULT '';
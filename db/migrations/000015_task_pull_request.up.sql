@@ -0,0 +1,4 @@
+-- pull_request is the pull request a write-back was proposed in, stored when the task
+-- starts awaiting its merge so a replica taking the task over can follow it. NULL for
+-- a write-back pushed directly.
+ALTER TABLE tasks ADD COLUMN IF NOT EXISTS pull_request JSONB;
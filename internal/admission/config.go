@@ -0,0 +1,29 @@
+package admission
+
+import (
+	"strings"
+
+	envConfig "github.com/caarlos0/env/v11"
+
+	"github.com/shini4i/argo-watcher/internal/helpers"
+)
+
+// Config holds the settings of the admission policy. It has no required fields, so
+// servers that admit every task start without any of them.
+type Config struct {
+	// PolicyPath names a YAML file of the rules a task must meet to be accepted
+	// (see policySpec).
+	PolicyPath string `env:"ADMISSION_POLICY_PATH"`
+}
+
+// NewConfig loads Config from environment variables.
+func NewConfig() (*Config, error) {
+	config, err := envConfig.ParseAs[Config]()
+	if err != nil {
+		return nil, helpers.PrettifyEnvError(err, "invalid argo-watcher admission configuration:")
+	}
+
+	config.PolicyPath = strings.TrimSpace(config.PolicyPath)
+
+	return &config, nil
+}
@@ -0,0 +1,16 @@
+package admission
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewConfig(t *testing.T) {
+	t.Setenv("ADMISSION_POLICY_PATH", " /etc/argo-watcher/admission.yaml ")
+
+	cfg, err := NewConfig()
+	require.NoError(t, err)
+	assert.Equal(t, "/etc/argo-watcher/admission.yaml", cfg.PolicyPath)
+}
@@ -0,0 +1,168 @@
+package admission
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"os"
+	"strings"
+
+	"github.com/google/cel-go/cel"
+	"github.com/google/cel-go/ext"
+	"gopkg.in/yaml.v3"
+
+	"github.com/shini4i/argo-watcher/internal/models"
+)
+
+// policySpec is the format of ADMISSION_POLICY_PATH:
+//
+//	rules:
+//	  - name: no-latest-tag
+//	    expression: task.images.all(image, image.tag != "latest")
+//	    message: the latest tag cannot be deployed
+//	  - name: payments-only
+//	    expression: task.app != "payments-api" || task.project == "payments"
+//
+// An expression is CEL over task, the task as the API returns it, and app, the ArgoCD
+// Application it is deployed to, or null when ArgoCD does not know it. A task is
+// accepted when every expression evaluates to true.
+type policySpec struct {
+	Rules []struct {
+		Name       string `yaml:"name"`
+		Expression string `yaml:"expression"`
+		Message    string `yaml:"message"`
+	} `yaml:"rules"`
+}
+
+// RejectedError reports a task that breaks rules of the admission policy, with one
+// violation per broken rule.
+type RejectedError struct {
+	Violations []string
+}
+
+func (err *RejectedError) Error() string {
+	return "the task breaks the admission policy: " + strings.Join(err.Violations, "; ")
+}
+
+// rule is a compiled rule of the policy.
+type rule struct {
+	name       string
+	expression string
+	message    string
+	program    cel.Program
+}
+
+// Policy holds the rules a task must meet to be accepted.
+type Policy struct {
+	rules []rule
+}
+
+// LoadPolicy reads the policy file at path. Every expression is compiled up front, so
+// a mistake in the file fails startup instead of the first task it would judge.
+func LoadPolicy(path string) (*Policy, error) {
+	content, err := os.ReadFile(path) // #nosec G304 -- the path is operator configuration
+	if err != nil {
+		return nil, fmt.Errorf("could not read the admission policy: %w", err)
+	}
+
+	var spec policySpec
+	if err := yaml.Unmarshal(content, &spec); err != nil {
+		return nil, fmt.Errorf("could not parse the admission policy %s: %w", path, err)
+	}
+
+	env, err := cel.NewEnv(
+		cel.Variable("task", cel.DynType),
+		cel.Variable("app", cel.DynType),
+		cel.OptionalTypes(),
+		ext.Strings(),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	policy := &Policy{}
+	names := make(map[string]bool, len(spec.Rules))
+	for index, declared := range spec.Rules {
+		if declared.Name == "" {
+			return nil, fmt.Errorf("admission rule %d has no name", index+1)
+		}
+		if names[declared.Name] {
+			return nil, fmt.Errorf("admission rule %q is declared twice", declared.Name)
+		}
+		names[declared.Name] = true
+
+		ast, issues := env.Compile(declared.Expression)
+		if issues.Err() != nil {
+			return nil, fmt.Errorf("admission rule %q: %w", declared.Name, issues.Err())
+		}
+		if output := ast.OutputType(); !output.IsExactType(cel.BoolType) && !output.IsExactType(cel.DynType) {
+			return nil, fmt.Errorf("admission rule %q evaluates to %s, not a bool", declared.Name, output)
+		}
+		program, err := env.Program(ast)
+		if err != nil {
+			return nil, fmt.Errorf("admission rule %q: %w", declared.Name, err)
+		}
+
+		policy.rules = append(policy.rules, rule{
+			name:       declared.Name,
+			expression: declared.Expression,
+			message:    declared.Message,
+			program:    program,
+		})
+	}
+
+	return policy, nil
+}
+
+// Rules returns how many rules the policy holds.
+func (policy *Policy) Rules() int {
+	return len(policy.rules)
+}
+
+// Evaluate returns a violation for every rule task breaks when deployed to app, which
+// is nil when ArgoCD does not know the application. A rule that cannot be evaluated
+// is broken: the policy fails closed.
+func (policy *Policy) Evaluate(task models.Task, app *models.Application) ([]string, error) {
+	taskValue, err := toValue(task)
+	if err != nil {
+		return nil, err
+	}
+	var appValue any
+	if app != nil {
+		if appValue, err = toValue(app); err != nil {
+			return nil, err
+		}
+	}
+
+	var violations []string
+	for _, rule := range policy.rules {
+		result, _, err := rule.program.Eval(map[string]any{"task": taskValue, "app": appValue})
+		switch {
+		case err != nil:
+			slog.Warn("An admission rule could not be evaluated", "rule", rule.name, "error", err)
+			violations = append(violations, fmt.Sprintf("%s: could not be evaluated: %s", rule.name, err))
+		case result.Value() == true:
+		case result.Value() != false:
+			violations = append(violations, fmt.Sprintf("%s: evaluated to %v, not a bool", rule.name, result.Value()))
+		case rule.message != "":
+			violations = append(violations, rule.name+": "+rule.message)
+		default:
+			violations = append(violations, fmt.Sprintf("%s: %s is false", rule.name, rule.expression))
+		}
+	}
+	return violations, nil
+}
+
+// toValue returns value as the JSON the API represents it with, so an expression names
+// its fields the way the API and the ArgoCD manifests do.
+func toValue(value any) (map[string]any, error) {
+	content, err := json.Marshal(value)
+	if err != nil {
+		return nil, err
+	}
+	var converted map[string]any
+	if err := json.Unmarshal(content, &converted); err != nil {
+		return nil, err
+	}
+	return converted, nil
+}
@@ -0,0 +1,125 @@
+package admission
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/shini4i/argo-watcher/internal/models"
+)
+
+func loadPolicy(t *testing.T, content string) (*Policy, error) {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "admission.yaml")
+	require.NoError(t, os.WriteFile(path, []byte(content), 0o600))
+	return LoadPolicy(path)
+}
+
+const rules = `
+rules:
+  - name: no-latest-tag
+    expression: task.images.all(image, image.tag != "latest")
+    message: the latest tag cannot be deployed
+  - name: prod-semver
+    expression: >-
+      app == null || app.metadata.?labels.?env.orValue("") != "prod" ||
+      task.images.all(image, image.tag.matches("^v?[0-9]+\\.[0-9]+\\.[0-9]+$"))
+    message: production applications only accept semantic version tags
+  - name: payments-only
+    expression: task.app != "payments-api" || task.project == "payments"
+  - name: known-author
+    expression: task.author.lowerAscii() in ["alice", "bob"]
+    message: the author is not a known user
+`
+
+func TestPolicyEvaluate(t *testing.T) {
+	policy, err := loadPolicy(t, rules)
+	require.NoError(t, err)
+	assert.Equal(t, 4, policy.Rules())
+
+	prod := &models.Application{Metadata: models.ApplicationMetadata{Name: "payments-api", Labels: map[string]string{"env": "prod"}}}
+
+	t.Run("A task meeting every rule has no violation", func(t *testing.T) {
+		task := models.Task{App: "payments-api", Project: "payments", Author: "Alice", Images: []models.Image{{Image: "app", Tag: "v1.2.3"}}}
+
+		violations, err := policy.Evaluate(task, prod)
+
+		require.NoError(t, err)
+		assert.Empty(t, violations)
+	})
+
+	t.Run("Every broken rule is reported", func(t *testing.T) {
+		task := models.Task{App: "payments-api", Project: "search", Author: "mallory", Images: []models.Image{{Image: "app", Tag: "latest"}}}
+
+		violations, err := policy.Evaluate(task, prod)
+
+		require.NoError(t, err)
+		assert.Equal(t, []string{
+			"no-latest-tag: the latest tag cannot be deployed",
+			"prod-semver: production applications only accept semantic version tags",
+			`payments-only: task.app != "payments-api" || task.project == "payments" is false`,
+			"known-author: the author is not a known user",
+		}, violations)
+	})
+
+	t.Run("An application ArgoCD does not know is null", func(t *testing.T) {
+		task := models.Task{App: "search", Project: "search", Author: "bob", Images: []models.Image{{Image: "app", Tag: "main"}}}
+
+		violations, err := policy.Evaluate(task, nil)
+
+		require.NoError(t, err)
+		assert.Empty(t, violations)
+	})
+
+	t.Run("An application without labels reads as having none", func(t *testing.T) {
+		task := models.Task{App: "search", Project: "search", Author: "bob", Images: []models.Image{{Image: "app", Tag: "main"}}}
+
+		violations, err := policy.Evaluate(task, &models.Application{Metadata: models.ApplicationMetadata{Name: "search"}})
+
+		require.NoError(t, err)
+		assert.Empty(t, violations)
+	})
+}
+
+func TestPolicyEvaluateFailsClosed(t *testing.T) {
+	policy, err := loadPolicy(t, `
+rules:
+  - name: team-label
+    expression: app.metadata.labels.team == "payments"
+  - name: not-a-bool
+    expression: task.app
+`)
+	require.NoError(t, err)
+
+	violations, err := policy.Evaluate(models.Task{App: "api"}, &models.Application{})
+
+	require.NoError(t, err)
+	require.Len(t, violations, 2)
+	assert.Contains(t, violations[0], "team-label: could not be evaluated:")
+	assert.Equal(t, "not-a-bool: evaluated to api, not a bool", violations[1])
+}
+
+func TestLoadPolicyRejectsAMistake(t *testing.T) {
+	for content, message := range map[string]string{
+		"rules: [":                         "could not parse the admission policy",
+		"rules:\n  - expression: 'true'\n": "admission rule 1 has no name",
+		"rules:\n  - name: a\n    expression: 'true'\n  - name: a\n    expression: 'false'\n": `admission rule "a" is declared twice`,
+		"rules:\n  - name: a\n    expression: 'task.app =='\n":                                `admission rule "a": ERROR`,
+		"rules:\n  - name: a\n    expression: '1 + 1'\n":                                      `admission rule "a" evaluates to int, not a bool`,
+	} {
+		_, err := loadPolicy(t, content)
+		assert.ErrorContains(t, err, message, content)
+	}
+
+	_, err := LoadPolicy(filepath.Join(t.TempDir(), "missing.yaml"))
+	assert.ErrorContains(t, err, "could not read the admission policy")
+}
+
+func TestRejectedError(t *testing.T) {
+	err := &RejectedError{Violations: []string{"a: one", "b: two"}}
+
+	assert.Equal(t, "the task breaks the admission policy: a: one; b: two", err.Error())
+}
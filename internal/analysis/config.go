@@ -0,0 +1,109 @@
+package analysis
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	envConfig "github.com/caarlos0/env/v11"
+	"gopkg.in/yaml.v3"
+
+	"github.com/shini4i/argo-watcher/internal/helpers"
+	"github.com/shini4i/argo-watcher/internal/models"
+)
+
+// Config holds the settings of the post-deployment analysis. It has no required
+// fields, so servers that never run an analysis start without any of them.
+type Config struct {
+	// PrometheusUrl is the Prometheus-compatible API the queries are evaluated against,
+	// without the /api/v1 suffix. Without it an application declaring an analysis fails
+	// its deployment rather than passing unchecked.
+	PrometheusUrl string `env:"ANALYSIS_PROMETHEUS_URL"`
+	// PrometheusToken is sent as a bearer token, for an endpoint behind authentication.
+	PrometheusToken string `env:"ANALYSIS_PROMETHEUS_TOKEN"`
+	// ConfigPath names a YAML file declaring the analysis of applications that do not
+	// declare one through their annotations (see fileSpec).
+	ConfigPath string `env:"ANALYSIS_CONFIG_PATH"`
+	// Duration is how long the queries are evaluated for when an analysis does not say.
+	Duration time.Duration `env:"ANALYSIS_DURATION" envDefault:"5m"`
+	// Interval is the time between two evaluations of the queries.
+	Interval time.Duration `env:"ANALYSIS_INTERVAL" envDefault:"30s"`
+	// QueryTimeout bounds a single query.
+	QueryTimeout time.Duration `env:"ANALYSIS_QUERY_TIMEOUT" envDefault:"30s"`
+}
+
+// NewConfig loads Config from environment variables.
+func NewConfig() (*Config, error) {
+	config, err := envConfig.ParseAs[Config]()
+	if err != nil {
+		return nil, helpers.PrettifyEnvError(err, "invalid argo-watcher analysis configuration:")
+	}
+
+	config.PrometheusUrl = strings.TrimSuffix(strings.TrimSpace(config.PrometheusUrl), "/")
+	config.PrometheusToken = strings.TrimSpace(config.PrometheusToken)
+
+	if config.Duration <= 0 {
+		return nil, fmt.Errorf("ANALYSIS_DURATION must be > 0, got %s", config.Duration)
+	}
+	if config.Interval <= 0 {
+		return nil, fmt.Errorf("ANALYSIS_INTERVAL must be > 0, got %s", config.Interval)
+	}
+	if config.QueryTimeout <= 0 {
+		return nil, fmt.Errorf("ANALYSIS_QUERY_TIMEOUT must be > 0, got %s", config.QueryTimeout)
+	}
+
+	return &config, nil
+}
+
+// fileSpec is the format of ANALYSIS_CONFIG_PATH:
+//
+//	applications:
+//	  payments:
+//	    duration: 10m
+//	    queries:
+//	      - name: error-rate
+//	        query: sum(rate(http_requests_total{app="payments",code=~"5.."}[1m]))
+//	        max: 0.5
+type fileSpec struct {
+	Applications map[string]struct {
+		Duration string                 `yaml:"duration"`
+		Queries  []models.AnalysisQuery `yaml:"queries"`
+	} `yaml:"applications"`
+}
+
+// loadSpecs reads the analyses declared in the file at path, keyed by application name.
+// Every analysis is validated up front, so a mistake in the file fails startup instead of
+// the first deployment of the application it concerns.
+func loadSpecs(path string) (map[string]models.AnalysisSpec, error) {
+	content, err := os.ReadFile(path) // #nosec G304 -- the path is operator configuration
+	if err != nil {
+		return nil, fmt.Errorf("could not read the analysis configuration: %w", err)
+	}
+
+	var file fileSpec
+	if err := yaml.Unmarshal(content, &file); err != nil {
+		return nil, fmt.Errorf("could not parse the analysis configuration %s: %w", path, err)
+	}
+
+	specs := make(map[string]models.AnalysisSpec, len(file.Applications))
+	for app, declared := range file.Applications {
+		spec := models.AnalysisSpec{Queries: declared.Queries}
+		if declared.Duration != "" {
+			if spec.Duration, err = time.ParseDuration(declared.Duration); err != nil || spec.Duration <= 0 {
+				return nil, fmt.Errorf("analysis configuration of %q: %q is not a positive duration", app, declared.Duration)
+			}
+		}
+		if len(spec.Queries) == 0 {
+			return nil, fmt.Errorf("analysis configuration of %q declares no query", app)
+		}
+		for index := range spec.Queries {
+			if err := spec.Queries[index].Validate(); err != nil {
+				return nil, fmt.Errorf("analysis configuration of %q: %w", app, err)
+			}
+		}
+		specs[app] = spec
+	}
+
+	return specs, nil
+}
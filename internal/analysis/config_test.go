@@ -0,0 +1,82 @@
+package analysis
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewConfigDefaults(t *testing.T) {
+	t.Setenv("ANALYSIS_PROMETHEUS_URL", " http://prometheus:9090/ ")
+
+	cfg, err := NewConfig()
+	require.NoError(t, err)
+	assert.Equal(t, "http://prometheus:9090", cfg.PrometheusUrl)
+	assert.Equal(t, 5*time.Minute, cfg.Duration)
+	assert.Equal(t, 30*time.Second, cfg.Interval)
+	assert.Equal(t, 30*time.Second, cfg.QueryTimeout)
+}
+
+func TestNewConfigRejectsANonPositiveInterval(t *testing.T) {
+	t.Setenv("ANALYSIS_INTERVAL", "0s")
+
+	_, err := NewConfig()
+	assert.ErrorContains(t, err, "ANALYSIS_INTERVAL must be > 0")
+}
+
+func writeSpecs(t *testing.T, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "analysis.yaml")
+	require.NoError(t, os.WriteFile(path, []byte(content), 0o600))
+	return path
+}
+
+func TestLoadSpecs(t *testing.T) {
+	path := writeSpecs(t, `
+applications:
+  payments:
+    duration: 10m
+    queries:
+      - name: error-rate
+        query: sum(rate(http_requests_total{code=~"5.."}[1m]))
+        max: 0.5
+`)
+
+	specs, err := loadSpecs(path)
+	require.NoError(t, err)
+	require.Contains(t, specs, "payments")
+	assert.Equal(t, 10*time.Minute, specs["payments"].Duration)
+	require.Len(t, specs["payments"].Queries, 1)
+	assert.Equal(t, 0.5, *specs["payments"].Queries[0].Max)
+}
+
+func TestLoadSpecsRejectsAnInvalidAnalysis(t *testing.T) {
+	tests := map[string]struct {
+		content string
+		message string
+	}{
+		"no query": {
+			content: "applications:\n  web:\n    duration: 1m\n",
+			message: `analysis configuration of "web" declares no query`,
+		},
+		"no threshold": {
+			content: "applications:\n  web:\n    queries:\n      - name: up\n        query: up\n",
+			message: `analysis "up" has neither a min nor a max threshold`,
+		},
+		"bad duration": {
+			content: "applications:\n  web:\n    duration: soon\n    queries:\n      - {name: up, query: up, min: 1}\n",
+			message: `"soon" is not a positive duration`,
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			_, err := loadSpecs(writeSpecs(t, tt.content))
+			assert.ErrorContains(t, err, tt.message)
+		})
+	}
+}
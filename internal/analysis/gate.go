@@ -0,0 +1,152 @@
+package analysis
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"math"
+	"strings"
+	"time"
+
+	"github.com/shini4i/argo-watcher/internal/models"
+)
+
+// FailedError reports an analysis that did not pass: the samples that broke a threshold,
+// or the queries that could not be evaluated at all.
+type FailedError struct {
+	Findings []string
+}
+
+func (err *FailedError) Error() string {
+	return "post-deployment analysis failed: " + strings.Join(err.Findings, "; ")
+}
+
+// Gate runs the post-deployment analysis of an application: its queries are evaluated
+// every Interval for the analysis' duration, and the first sample outside its
+// thresholds fails the deployment.
+type Gate struct {
+	client   *prometheusClient
+	specs    map[string]models.AnalysisSpec
+	duration time.Duration
+	interval time.Duration
+}
+
+// NewGate returns the gate configured by cfg, reading ANALYSIS_CONFIG_PATH if it is set.
+func NewGate(cfg *Config) (*Gate, error) {
+	gate := &Gate{duration: cfg.Duration, interval: cfg.Interval}
+
+	if cfg.PrometheusUrl != "" {
+		gate.client = newPrometheusClient(cfg.PrometheusUrl, cfg.PrometheusToken, cfg.QueryTimeout)
+	}
+
+	if cfg.ConfigPath != "" {
+		specs, err := loadSpecs(cfg.ConfigPath)
+		if err != nil {
+			return nil, err
+		}
+		gate.specs = specs
+		slog.Info("Loaded post-deployment analyses", "applications", len(specs), "path", cfg.ConfigPath)
+	}
+
+	return gate, nil
+}
+
+// SpecFor returns the analysis of app, or nil when it has none. An analysis declared
+// through the application's annotations replaces the one the configuration file holds
+// for it, so a team can adjust its own without a change to the server.
+func (gate *Gate) SpecFor(app *models.Application) (*models.AnalysisSpec, error) {
+	spec, err := app.AnalysisSpec()
+	if err != nil {
+		return nil, err
+	}
+
+	if spec == nil {
+		configured, ok := gate.specs[app.Metadata.Name]
+		if !ok {
+			return nil, nil
+		}
+		spec = &configured
+	}
+
+	if spec.Duration == 0 {
+		spec.Duration = gate.duration
+	}
+	return spec, nil
+}
+
+// Run evaluates the queries of spec until its duration is over. The first evaluation
+// happens one interval in, so the new version has served some traffic by then; an
+// analysis shorter than the interval is evaluated once, at its end.
+//
+// It returns nil when every query was measured at least once and never broke its
+// thresholds, and a *FailedError otherwise. A query that fails or returns no sample is
+// retried on the next evaluation; only one that never yields a sample fails the
+// analysis, with the last error it met. stop is consulted before every evaluation and
+// its error ends the analysis, as does the cancellation of ctx.
+func (gate *Gate) Run(ctx context.Context, spec models.AnalysisSpec, stop func() error) error {
+	if gate.client == nil {
+		return &FailedError{Findings: []string{"no Prometheus endpoint is configured to evaluate the analysis (ANALYSIS_PROMETHEUS_URL)"}}
+	}
+
+	evaluations := max(int(spec.Duration/gate.interval), 1)
+	step := spec.Duration / time.Duration(evaluations)
+
+	measured := make(map[string]bool, len(spec.Queries))
+	lastError := make(map[string]string, len(spec.Queries))
+
+	timer := time.NewTimer(step)
+	defer timer.Stop()
+
+	for evaluation := 1; evaluation <= evaluations; evaluation++ {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-timer.C:
+		}
+		timer.Reset(step)
+
+		if err := stop(); err != nil {
+			return err
+		}
+
+		var violations []string
+		for _, query := range spec.Queries {
+			samples, err := gate.client.query(ctx, query.Query)
+			if err != nil {
+				slog.Debug("Analysis query failed", "analysis", query.Name, "error", err)
+				lastError[query.Name] = err.Error()
+				continue
+			}
+
+			for _, sample := range samples {
+				// NaN is what a ratio over no traffic evaluates to: not a measurement.
+				if math.IsNaN(sample.Value) {
+					continue
+				}
+				measured[query.Name] = true
+				if violation := query.Violation(sample.Value, sample.Labels); violation != "" {
+					violations = append(violations, violation)
+				}
+			}
+			if !measured[query.Name] {
+				lastError[query.Name] = "the query returned no data"
+			}
+		}
+
+		if len(violations) > 0 {
+			return &FailedError{Findings: violations}
+		}
+	}
+
+	var unmeasured []string
+	for _, query := range spec.Queries {
+		if !measured[query.Name] {
+			unmeasured = append(unmeasured, fmt.Sprintf("%s could not be evaluated: %s", query.Name, lastError[query.Name]))
+		}
+	}
+	if len(unmeasured) > 0 {
+		return &FailedError{Findings: unmeasured}
+	}
+
+	return nil
+}
@@ -0,0 +1,157 @@
+package analysis
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/shini4i/argo-watcher/internal/models"
+)
+
+// fakePrometheus answers every query with body, and records the queries and the
+// Authorization header it received.
+func fakePrometheus(t *testing.T, body string) (*httptest.Server, *[]string, *string) {
+	t.Helper()
+	var queries []string
+	var authorization string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.Equal(t, "/api/v1/query", r.URL.Path)
+		require.NoError(t, r.ParseForm())
+		queries = append(queries, r.PostForm.Get("query"))
+		authorization = r.Header.Get("Authorization")
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(body))
+	}))
+	t.Cleanup(server.Close)
+	return server, &queries, &authorization
+}
+
+func newTestGate(url string) *Gate {
+	return &Gate{
+		client:   newPrometheusClient(url, "secret", time.Second),
+		duration: 3 * time.Millisecond,
+		interval: time.Millisecond,
+	}
+}
+
+func errorRateSpec() models.AnalysisSpec {
+	limit := 0.5
+	return models.AnalysisSpec{
+		Duration: 3 * time.Millisecond,
+		Queries:  []models.AnalysisQuery{{Name: "error-rate", Query: "sum(rate(errors[1m]))", Max: &limit}},
+	}
+}
+
+func noStop() error { return nil }
+
+func TestRunPassesWhenEverySampleIsWithinThresholds(t *testing.T) {
+	server, queries, authorization := fakePrometheus(t,
+		`{"status":"success","data":{"resultType":"vector","result":[{"metric":{"pod":"web-1"},"value":[1,"0.1"]}]}}`)
+
+	err := newTestGate(server.URL).Run(context.Background(), errorRateSpec(), noStop)
+
+	assert.NoError(t, err)
+	assert.Len(t, *queries, 3)
+	assert.Equal(t, "sum(rate(errors[1m]))", (*queries)[0])
+	assert.Equal(t, "Bearer secret", *authorization)
+}
+
+func TestRunFailsOnTheFirstViolation(t *testing.T) {
+	server, queries, _ := fakePrometheus(t,
+		`{"status":"success","data":{"resultType":"vector","result":[{"metric":{"pod":"web-1"},"value":[1,"0.1"]},{"metric":{"pod":"web-2"},"value":[1,"2.5"]}]}}`)
+
+	err := newTestGate(server.URL).Run(context.Background(), errorRateSpec(), noStop)
+
+	var failed *FailedError
+	require.ErrorAs(t, err, &failed)
+	assert.Equal(t, []string{`error-rate {pod="web-2"} = 2.5, above the maximum 0.5`}, failed.Findings)
+	assert.Len(t, *queries, 1)
+}
+
+func TestRunFailsAQueryThatNeverReturnsData(t *testing.T) {
+	server, _, _ := fakePrometheus(t,
+		`{"status":"success","data":{"resultType":"vector","result":[{"metric":{},"value":[1,"NaN"]}]}}`)
+
+	err := newTestGate(server.URL).Run(context.Background(), errorRateSpec(), noStop)
+
+	var failed *FailedError
+	require.ErrorAs(t, err, &failed)
+	assert.Equal(t, []string{"error-rate could not be evaluated: the query returned no data"}, failed.Findings)
+}
+
+func TestRunReportsARejectedQuery(t *testing.T) {
+	server, _, _ := fakePrometheus(t, `{"status":"error","errorType":"bad_data","error":"parse error"}`)
+
+	err := newTestGate(server.URL).Run(context.Background(), errorRateSpec(), noStop)
+
+	var failed *FailedError
+	require.ErrorAs(t, err, &failed)
+	assert.Equal(t, []string{"error-rate could not be evaluated: Prometheus rejected the query (bad_data): parse error"}, failed.Findings)
+}
+
+func TestRunAcceptsAScalar(t *testing.T) {
+	server, _, _ := fakePrometheus(t, `{"status":"success","data":{"resultType":"scalar","result":[1,"0.2"]}}`)
+
+	assert.NoError(t, newTestGate(server.URL).Run(context.Background(), errorRateSpec(), noStop))
+}
+
+func TestRunStopsWhenAskedTo(t *testing.T) {
+	server, queries, _ := fakePrometheus(t, `{"status":"success","data":{"resultType":"scalar","result":[1,"0.2"]}}`)
+	stopped := errors.New("superseded")
+
+	err := newTestGate(server.URL).Run(context.Background(), errorRateSpec(), func() error { return stopped })
+
+	assert.ErrorIs(t, err, stopped)
+	assert.Empty(t, *queries)
+}
+
+func TestRunWithoutAnEndpointFails(t *testing.T) {
+	gate := &Gate{duration: time.Minute, interval: time.Second}
+
+	err := gate.Run(context.Background(), errorRateSpec(), noStop)
+
+	var failed *FailedError
+	require.ErrorAs(t, err, &failed)
+	assert.Contains(t, failed.Findings[0], "ANALYSIS_PROMETHEUS_URL")
+}
+
+func TestSpecForPrefersTheAnnotations(t *testing.T) {
+	limit := 1.0
+	gate := &Gate{
+		duration: 5 * time.Minute,
+		specs: map[string]models.AnalysisSpec{
+			"web": {Queries: []models.AnalysisQuery{{Name: "from-file", Query: "up", Min: &limit}}},
+		},
+	}
+
+	app := &models.Application{}
+	app.Metadata.Name = "web"
+	spec, err := gate.SpecFor(app)
+	require.NoError(t, err)
+	require.NotNil(t, spec)
+	assert.Equal(t, "from-file", spec.Queries[0].Name)
+	assert.Equal(t, 5*time.Minute, spec.Duration)
+
+	app.Metadata.Annotations = map[string]string{
+		"argo-watcher/analysis.latency.query": "histogram_quantile(0.99, rate(latency_bucket[1m]))",
+		"argo-watcher/analysis.latency.max":   "0.3",
+		"argo-watcher/analysis-duration":      "2m",
+	}
+	spec, err = gate.SpecFor(app)
+	require.NoError(t, err)
+	require.Len(t, spec.Queries, 1)
+	assert.Equal(t, "latency", spec.Queries[0].Name)
+	assert.Equal(t, 2*time.Minute, spec.Duration)
+
+	app.Metadata.Name = "other"
+	app.Metadata.Annotations = nil
+	spec, err = gate.SpecFor(app)
+	require.NoError(t, err)
+	assert.Nil(t, spec)
+}
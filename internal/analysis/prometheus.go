@@ -0,0 +1,148 @@
+package analysis
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// maxQueryResponseBytes bounds how much of a query response is read. A query matching
+// more series than this can describe is a query mistake, not something to buffer.
+const maxQueryResponseBytes = 4 << 20
+
+// sample is one value of a query result, with the labels of its series rendered as
+// {name="value",...} (empty for a scalar).
+type sample struct {
+	Labels string
+	Value  float64
+}
+
+// queryResponse is the response of the Prometheus HTTP API's /api/v1/query.
+type queryResponse struct {
+	Status    string `json:"status"`
+	ErrorType string `json:"errorType"`
+	Error     string `json:"error"`
+	Data      struct {
+		ResultType string          `json:"resultType"`
+		Result     json.RawMessage `json:"result"`
+	} `json:"data"`
+}
+
+// prometheusClient evaluates instant queries against a Prometheus-compatible API.
+type prometheusClient struct {
+	baseUrl string
+	token   string
+	client  *http.Client
+}
+
+func newPrometheusClient(baseUrl, token string, timeout time.Duration) *prometheusClient {
+	return &prometheusClient{baseUrl: baseUrl, token: token, client: &http.Client{Timeout: timeout}}
+}
+
+// query evaluates promql at the current time and returns its samples. Only vector and
+// scalar results are accepted: a threshold applies to one value per series.
+func (client *prometheusClient) query(ctx context.Context, promql string) ([]sample, error) {
+	form := url.Values{"query": {promql}}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, client.baseUrl+"/api/v1/query", strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	if client.token != "" {
+		req.Header.Set("Authorization", "Bearer "+client.token)
+	}
+
+	resp, err := client.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if closeErr := resp.Body.Close(); closeErr != nil {
+			slog.Error("failed to close response body", "error", closeErr)
+		}
+	}()
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxQueryResponseBytes))
+	if err != nil {
+		return nil, err
+	}
+
+	var response queryResponse
+	if err := json.Unmarshal(body, &response); err != nil {
+		return nil, fmt.Errorf("unexpected response from Prometheus (HTTP %d): %.200s", resp.StatusCode, body)
+	}
+	if response.Status != "success" {
+		return nil, fmt.Errorf("Prometheus rejected the query (%s): %s", response.ErrorType, response.Error)
+	}
+
+	return parseResult(response.Data.ResultType, response.Data.Result)
+}
+
+// parseResult decodes the samples of a vector or scalar result. Prometheus renders
+// every value as a [timestamp, "value"] pair.
+func parseResult(resultType string, result json.RawMessage) ([]sample, error) {
+	switch resultType {
+	case "scalar":
+		var value []any
+		if err := json.Unmarshal(result, &value); err != nil {
+			return nil, err
+		}
+		parsed, err := parseValue(value)
+		if err != nil {
+			return nil, err
+		}
+		return []sample{{Value: parsed}}, nil
+	case "vector":
+		var series []struct {
+			Metric map[string]string `json:"metric"`
+			Value  []any             `json:"value"`
+		}
+		if err := json.Unmarshal(result, &series); err != nil {
+			return nil, err
+		}
+		samples := make([]sample, 0, len(series))
+		for _, entry := range series {
+			parsed, err := parseValue(entry.Value)
+			if err != nil {
+				return nil, err
+			}
+			samples = append(samples, sample{Labels: formatLabels(entry.Metric), Value: parsed})
+		}
+		return samples, nil
+	default:
+		return nil, fmt.Errorf("query returned a %s; only an instant vector or a scalar can be compared with a threshold", resultType)
+	}
+}
+
+func parseValue(pair []any) (float64, error) {
+	if len(pair) != 2 {
+		return 0, fmt.Errorf("malformed sample %v", pair)
+	}
+	value, ok := pair[1].(string)
+	if !ok {
+		return 0, fmt.Errorf("malformed sample %v", pair)
+	}
+	return strconv.ParseFloat(value, 64)
+}
+
+// formatLabels renders the labels of a series in PromQL syntax, sorted by name.
+func formatLabels(labels map[string]string) string {
+	if len(labels) == 0 {
+		return ""
+	}
+	pairs := make([]string, 0, len(labels))
+	for name, value := range labels {
+		pairs = append(pairs, fmt.Sprintf("%s=%q", name, value))
+	}
+	slices.Sort(pairs)
+	return "{" + strings.Join(pairs, ",") + "}"
+}
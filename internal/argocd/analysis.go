@@ -0,0 +1,70 @@
+package argocd
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strings"
+
+	"github.com/shini4i/argo-watcher/internal/analysis"
+	"github.com/shini4i/argo-watcher/internal/models"
+)
+
+// Analyze runs the post-deployment analysis of an application whose rollout succeeded,
+// keeping the task in progress until it is over. It returns nil when the application
+// declares no analysis, when the rollout did not succeed — that failure is reported on
+// its own — and when the analysis passed; an *analysis.FailedError when it did not, a
+// malformed declaration included.
+//
+// The analysis stops like the poll loop does, on the same stop conditions: a newer
+// deployment superseding the task, or this replica giving the rollout up.
+func (monitor *DeploymentMonitor) Analyze(task models.Task, application *models.Application, abandoned func() bool) error {
+	if monitor.analysis == nil || application == nil || application.IsFireAndForgetModeActive() {
+		return nil
+	}
+	if monitor.rolloutStatus(task, application) != models.ArgoRolloutAppSuccess {
+		return nil
+	}
+
+	spec, err := monitor.analysis.SpecFor(application)
+	if err != nil {
+		return &analysis.FailedError{Findings: []string{fmt.Sprintf("the analysis is declared incorrectly: %s", err)}}
+	}
+	if spec == nil {
+		return nil
+	}
+
+	slog.Info("Rollout finished; running the post-deployment analysis", "app", task.App, "queries", len(spec.Queries), "duration", spec.Duration, "id", task.Id)
+	names := make([]string, 0, len(spec.Queries))
+	for _, query := range spec.Queries {
+		names = append(names, query.Name)
+	}
+	progress := fmt.Sprintf("Rollout finished. Running the post-deployment analysis for %s: %s.", spec.Duration, strings.Join(names, ", "))
+	if err := monitor.argo.State.SetTaskProgress(task.Id, progress); err != nil {
+		slog.Warn("Failed to record analysis progress", "error", err, "id", task.Id)
+	}
+
+	return monitor.analysis.Run(context.Background(), *spec, func() error {
+		if monitor.taskSuperseded(task.Id) {
+			return errTaskSuperseded
+		}
+		if abandoned() {
+			return errLeaseLost
+		}
+		return nil
+	})
+}
+
+// HandleAnalysisFailure fails a task whose rollout succeeded but whose post-deployment
+// analysis did not pass, with the samples that broke their thresholds.
+func (monitor *DeploymentMonitor) HandleAnalysisFailure(task *models.Task, analysisErr *analysis.FailedError) {
+	slog.Warn("App deployment failed: the post-deployment analysis did not pass.", "app", task.App, "id", task.Id)
+	monitor.argo.metrics.AddFailedDeployment(task.App)
+
+	reason := "Application deployment failed. The rollout succeeded, but the post-deployment analysis did not pass:\n\t" +
+		strings.Join(analysisErr.Findings, "\n\t")
+	if err := monitor.argo.State.SetTaskStatus(task.Id, models.StatusFailedMessage, reason); err != nil {
+		slog.Error("Failed to change task status", "error", err, "id", task.Id)
+	}
+	task.Status = models.StatusFailedMessage
+}
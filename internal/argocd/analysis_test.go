@@ -0,0 +1,133 @@
+package argocd
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
+
+	"github.com/shini4i/argo-watcher/internal/analysis"
+	"github.com/shini4i/argo-watcher/internal/mocks"
+	"github.com/shini4i/argo-watcher/internal/models"
+)
+
+// analyzedApp is an application rolled out to the task's image that declares an
+// error-rate analysis through its annotations.
+func analyzedApp() *models.Application {
+	app := &models.Application{}
+	app.Metadata.Name = "demo"
+	app.Metadata.Annotations = map[string]string{
+		"argo-watcher/analysis.error-rate.query": "error_rate",
+		"argo-watcher/analysis.error-rate.max":   "0.5",
+	}
+	app.Status.Summary.Images = []string{"ghcr.io/shini4i/app:v2"}
+	app.Status.Sync.Status = "Synced"
+	app.Status.Health.Status = "Healthy"
+	return app
+}
+
+// newAnalysisGate returns a gate evaluating its queries against a fake Prometheus that
+// answers every query with value.
+func newAnalysisGate(t *testing.T, value string) *analysis.Gate {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(`{"status":"success","data":{"resultType":"scalar","result":[1,"` + value + `"]}}`))
+	}))
+	t.Cleanup(server.Close)
+
+	gate, err := analysis.NewGate(&analysis.Config{
+		PrometheusUrl: server.URL,
+		Duration:      2 * time.Millisecond,
+		Interval:      time.Millisecond,
+		QueryTimeout:  time.Second,
+	})
+	require.NoError(t, err)
+	return gate
+}
+
+func TestAnalyzePasses(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	state := notSupersededState(ctrl)
+	state.EXPECT().SetTaskProgress(rolloutTask.Id, "Rollout finished. Running the post-deployment analysis for 2ms: error-rate.")
+	monitor := newRolloutMonitor(newArgoApiMock(ctrl), state)
+	monitor.analysis = newAnalysisGate(t, "0.1")
+
+	assert.NoError(t, monitor.Analyze(rolloutTask, analyzedApp(), neverLost))
+}
+
+func TestAnalyzeFailureFailsTheTask(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	state := notSupersededState(ctrl)
+	state.EXPECT().SetTaskProgress(rolloutTask.Id, gomock.Any())
+	metrics := mocks.NewMockMetricsInterface(ctrl)
+	monitor := newRolloutMonitor(newArgoApiMock(ctrl), state)
+	monitor.argo.metrics = metrics
+	monitor.analysis = newAnalysisGate(t, "0.9")
+
+	err := monitor.Analyze(rolloutTask, analyzedApp(), neverLost)
+	var analysisErr *analysis.FailedError
+	require.ErrorAs(t, err, &analysisErr)
+
+	task := rolloutTask
+	metrics.EXPECT().AddFailedDeployment(task.App)
+	state.EXPECT().SetTaskStatus(task.Id, models.StatusFailedMessage,
+		"Application deployment failed. The rollout succeeded, but the post-deployment analysis did not pass:\n\terror-rate = 0.9, above the maximum 0.5")
+	monitor.HandleAnalysisFailure(&task, analysisErr)
+	assert.Equal(t, models.StatusFailedMessage, task.Status)
+}
+
+func TestAnalyzeSkipsWhatIsNotToBeAnalyzed(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	// No SetTaskProgress expectation: none of these starts an analysis.
+	monitor := newRolloutMonitor(newArgoApiMock(ctrl), notSupersededState(ctrl))
+	assert.NoError(t, monitor.Analyze(rolloutTask, analyzedApp(), neverLost), "no gate configured")
+
+	monitor.analysis = newAnalysisGate(t, "0.9")
+
+	unannotated := analyzedApp()
+	unannotated.Metadata.Annotations = nil
+	assert.NoError(t, monitor.Analyze(rolloutTask, unannotated, neverLost), "no analysis declared")
+
+	degraded := analyzedApp()
+	degraded.Status.Health.Status = "Degraded"
+	assert.NoError(t, monitor.Analyze(rolloutTask, degraded, neverLost), "rollout did not succeed")
+}
+
+func TestAnalyzeReportsAMalformedDeclaration(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	monitor := newRolloutMonitor(newArgoApiMock(ctrl), notSupersededState(ctrl))
+	monitor.analysis = newAnalysisGate(t, "0.1")
+
+	app := analyzedApp()
+	app.Metadata.Annotations["argo-watcher/analysis.error-rate.max"] = "low"
+
+	err := monitor.Analyze(rolloutTask, app, neverLost)
+	var analysisErr *analysis.FailedError
+	require.ErrorAs(t, err, &analysisErr)
+	assert.Contains(t, analysisErr.Findings[0], "the analysis is declared incorrectly")
+}
+
+func TestAnalyzeStopsWhenTheLeaseIsLost(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	state := notSupersededState(ctrl)
+	state.EXPECT().SetTaskProgress(rolloutTask.Id, gomock.Any())
+	monitor := newRolloutMonitor(newArgoApiMock(ctrl), state)
+	monitor.analysis = newAnalysisGate(t, "0.1")
+
+	err := monitor.Analyze(rolloutTask, analyzedApp(), func() bool { return true })
+	assert.ErrorIs(t, err, errLeaseLost)
+}
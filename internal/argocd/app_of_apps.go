@@ -0,0 +1,122 @@
+package argocd
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/avast/retry-go/v4"
+
+	"github.com/shini4i/argo-watcher/internal/models"
+)
+
+// ChildRolloutError ends the rollout of an app of apps that did not finish: its own
+// sync never completed, or a child application carrying the task's images did not roll
+// out. It travels the error path because the app of apps' own status, which
+// ProcessDeploymentResult would judge, says nothing about its children.
+type ChildRolloutError struct {
+	// Parent is the app of apps as last fetched.
+	Parent *models.Application
+	// Rollout is the last assessment of the children, nil if they were never assessed.
+	Rollout *models.AppOfAppsRollout
+	// Waited is how long the rollout was polled.
+	Waited time.Duration
+}
+
+func (err *ChildRolloutError) Error() string {
+	return fmt.Sprintf("child applications of %q did not roll out", err.Parent.Metadata.Name)
+}
+
+// appOfAppsTracker keeps the last assessment of the children across polls, so a poll
+// that could not see them all does not erase what the previous one found.
+type appOfAppsTracker struct {
+	rollout *models.AppOfAppsRollout
+}
+
+// checkChildren is the poll step for an app of apps: it follows the children carrying
+// the task's images instead of the application itself. The children are assessed only
+// once the app of apps is Synced, because their specs reach the cluster through its sync.
+//
+// It returns nil once every such child rolled out, an unrecoverable errAppDegraded when
+// one of them degraded, and errForceRetry otherwise. A child already running the task's
+// images cannot recover by receiving them again, so unlike a standalone application a
+// degraded child ends the rollout at once. A lookup that fails keeps the loop polling
+// on the previous assessment: a partial view of the children is not a verdict.
+func (monitor *DeploymentMonitor) checkChildren(ctx context.Context, task models.Task, app *models.Application, refresh bool, tracker *appOfAppsTracker) error {
+	if app.Status.Sync.Status != "Synced" {
+		slog.Debug("App of apps is not synced yet", "status", app.Status.Sync.Status, "id", task.Id)
+		return errForceRetry
+	}
+
+	tree, err := monitor.argo.api.GetResourceTree(ctx, task.App)
+	if err != nil {
+		slog.Debug("Could not fetch resource tree to discover child applications", "error", err, "id", task.Id)
+		return errForceRetry
+	}
+
+	names := tree.ChildApplicationNames()
+	children := make([]*models.Application, 0, len(names))
+	for _, name := range names {
+		child, err := monitor.FetchApplication(ctx, name, refresh)
+		if err != nil {
+			slog.Debug("Could not fetch child application", "child", name, "error", err, "id", task.Id)
+			return errForceRetry
+		}
+		children = append(children, child)
+	}
+
+	tracker.rollout = models.NewAppOfAppsRollout(children, task.ListImages(), monitor.registryProxyUrl, monitor.acceptSuspended)
+
+	switch status := tracker.rollout.Status(); status {
+	case models.ArgoRolloutAppSuccess:
+		slog.Debug("Child applications rolled out", "children", len(tracker.rollout.Children), "id", task.Id)
+		return nil
+	case models.ArgoRolloutAppDegraded:
+		return retry.Unrecoverable(errAppDegraded)
+	default:
+		slog.Debug("Child applications are not final", "status", status, "id", task.Id)
+		return errForceRetry
+	}
+}
+
+// HandleChildRolloutFailure fails the task with a report on every child of the app of
+// apps that did not roll out, or on the app of apps' own sync when that never completed.
+func (monitor *DeploymentMonitor) HandleChildRolloutFailure(task *models.Task, childErr *ChildRolloutError) {
+	slog.Warn("App deployment failed: child applications did not roll out.", "app", task.App, "id", task.Id)
+	monitor.argo.metrics.AddFailedDeployment(task.App)
+
+	if err := monitor.argo.State.SetTaskStatus(task.Id, models.StatusFailedMessage, monitor.childFailureReason(childErr)); err != nil {
+		slog.Error("Failed to change task status", "error", err, "id", task.Id)
+	}
+	task.Status = models.StatusFailedMessage
+}
+
+// childFailureReason renders the status reason of a failed app of apps. The trees of the
+// failed children are fetched best-effort, as for a standalone application.
+func (monitor *DeploymentMonitor) childFailureReason(childErr *ChildRolloutError) string {
+	parent := childErr.Parent
+
+	switch {
+	case parent.Status.Sync.Status != "Synced":
+		status := models.ArgoRolloutAppNotSynced
+		return fmt.Sprintf(
+			"%s\n\n%s",
+			parent.RolloutFailureHeadline(status, childErr.Waited),
+			parent.GetRolloutMessage(status, nil, monitor.fetchResourceTree(parent.Metadata.Name)),
+		)
+	case childErr.Rollout == nil:
+		return fmt.Sprintf(
+			"Application deployment failed. The child applications of %q could not be inspected.",
+			parent.Metadata.Name,
+		)
+	}
+
+	trees := map[string]*models.ApplicationTree{}
+	for _, child := range childErr.Rollout.FailedChildren() {
+		name := child.Application.Metadata.Name
+		trees[name] = monitor.fetchResourceTree(name)
+	}
+
+	return childErr.Rollout.FailureReport(childErr.Waited, trees)
+}
@@ -0,0 +1,101 @@
+package argocd
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
+
+	"github.com/shini4i/argo-watcher/internal/mocks"
+	"github.com/shini4i/argo-watcher/internal/models"
+)
+
+var appOfAppsTree = &models.ApplicationTree{Nodes: []models.ApplicationTreeNode{
+	{Group: "argoproj.io", Kind: "Application", Name: "web", Namespace: "argocd"},
+	{Group: "argoproj.io", Kind: "Application", Name: "db", Namespace: "argocd"},
+}}
+
+// appOfApps is the parent of appOfAppsTree, with no image of its own.
+func appOfApps(sync string) *models.Application {
+	app := &models.Application{}
+	app.Metadata.Name = rolloutTask.App
+	app.Metadata.Annotations = map[string]string{"argo-watcher/app-of-apps": "true"}
+	app.Status.Sync.Status = sync
+	app.Status.Health.Status = "Healthy"
+	return app
+}
+
+func childApp(name, image, health string) *models.Application {
+	app := &models.Application{}
+	app.Metadata.Name = name
+	app.Status.Summary.Images = []string{image}
+	app.Status.Sync.Status = "Synced"
+	app.Status.Health.Status = health
+	return app
+}
+
+// The rollout follows the child running the task's image; a sibling running another
+// image is not part of the deployment, however unhealthy it is.
+func TestWaitRolloutFollowsChildApplications(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	api := mocks.NewMockArgoApiInterface(ctrl)
+	api.EXPECT().GetApplication(gomock.Any(), rolloutTask.App, false).
+		DoAndReturn(appSequence(appOfApps("OutOfSync"), appOfApps("Synced"), appOfApps("Synced"))).Times(3)
+	api.EXPECT().GetResourceTree(gomock.Any(), rolloutTask.App).Return(appOfAppsTree, nil).Times(2)
+	api.EXPECT().GetApplication(gomock.Any(), "web", false).DoAndReturn(appSequence(
+		childApp("web", "ghcr.io/shini4i/app:v1", "Healthy"),
+		childApp("web", "ghcr.io/shini4i/app:v2", "Healthy"),
+	)).Times(2)
+	api.EXPECT().GetApplication(gomock.Any(), "db", false).Return(childApp("db", "postgres:16", "Degraded"), nil).Times(2)
+
+	_, _, err := newRolloutMonitor(api, notSupersededState(ctrl)).WaitRollout(rolloutTask, neverLost)
+	require.NoError(t, err)
+}
+
+func TestWaitRolloutFailsOnDegradedChild(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	api := mocks.NewMockArgoApiInterface(ctrl)
+	api.EXPECT().GetApplication(gomock.Any(), rolloutTask.App, false).Return(appOfApps("Synced"), nil).Times(1)
+	api.EXPECT().GetResourceTree(gomock.Any(), rolloutTask.App).Return(appOfAppsTree, nil)
+	api.EXPECT().GetApplication(gomock.Any(), "web", false).Return(childApp("web", "ghcr.io/shini4i/app:v2", "Degraded"), nil)
+	api.EXPECT().GetApplication(gomock.Any(), "db", false).Return(childApp("db", "postgres:16", "Healthy"), nil)
+
+	monitor := newRolloutMonitor(api, notSupersededState(ctrl))
+	_, _, err := monitor.WaitRollout(rolloutTask, neverLost)
+
+	var childErr *ChildRolloutError
+	require.ErrorAs(t, err, &childErr)
+	require.NotNil(t, childErr.Rollout)
+
+	api.EXPECT().GetResourceTree(gomock.Any(), "web").Return(nil, errors.New("unavailable"))
+	reason := monitor.childFailureReason(childErr)
+	assert.Contains(t, reason, "Child applications not rolled out: web (degraded)")
+	assert.Contains(t, reason, "Child application \"web\" (degraded):\nApp sync status \"Synced\"\nApp health status \"Degraded\"")
+	assert.NotContains(t, reason, "db", "a child outside the deployment is not reported")
+}
+
+func TestWaitRolloutReportsUnsyncedAppOfApps(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	api := mocks.NewMockArgoApiInterface(ctrl)
+	api.EXPECT().GetApplication(gomock.Any(), rolloutTask.App, false).Return(appOfApps("OutOfSync"), nil).Times(2)
+
+	monitor := newRolloutMonitor(api, notSupersededState(ctrl))
+	monitor.defaultAttempts = 2
+
+	_, _, err := monitor.WaitRollout(rolloutTask, neverLost)
+
+	var childErr *ChildRolloutError
+	require.ErrorAs(t, err, &childErr)
+	assert.Nil(t, childErr.Rollout, "the children are not assessed before the app of apps synced")
+
+	api.EXPECT().GetResourceTree(gomock.Any(), rolloutTask.App).Return(appOfAppsTree, nil)
+	assert.Contains(t, monitor.childFailureReason(childErr), "Deployment failed: ArgoCD reports sync status OutOfSync")
+}
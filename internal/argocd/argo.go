@@ -53,6 +53,10 @@ type Argo struct {
 	metrics prometheus.MetricsInterface
 	api     ArgoApiInterface
 	State   state.TaskRepository
+	// PreventDowngrades rejects a task whose semantic versions are older than the ones
+	// last deployed to its application, unless it is marked as a rollback or was sent
+	// by a privileged user (see checkDowngrade).
+	PreventDowngrades bool
 	// reason caches which subsystem, if any, was unreachable at the most recent
 	// Check so it can be read synchronously (IsAvailable / UnavailableReason) off
 	// any request path. An empty reason (ReasonNone) means everything is
@@ -163,8 +167,26 @@ func (argo *Argo) StartLivenessProbe(ctx context.Context, interval time.Duration
 	}
 }
 
+// GetApplication fetches the named application, bounded by ctx. It returns an
+// *ArgoAPIError when ArgoCD answers with an error, as it does for an application
+// that does not exist or that the configured account may not read.
+func (argo *Argo) GetApplication(ctx context.Context, app string) (*models.Application, error) {
+	return argo.api.GetApplication(ctx, app, false)
+}
+
 // AddTask validates a new deployment task and adds it to the task repository.
 func (argo *Argo) AddTask(task models.Task) (*models.Task, error) {
+	// Queued and grouped are for AddTaskGroup to decide: a submitted task that claimed
+	// to be queued would be stored without anyone to monitor it, and one naming a
+	// group would join a group deployment it is not part of.
+	task.Status = ""
+	task.GroupId = ""
+	return argo.addTask(task)
+}
+
+// addTask adds task to the task repository as AddTask does, keeping the status and
+// group AddTaskGroup gave it.
+func (argo *Argo) addTask(task models.Task) (*models.Task, error) {
 	// Gate on the cached reachability instead of a live Check(): a deploy
 	// attempted during an ArgoCD outage then fails fast with a clear error
 	// rather than blocking on the full API retry budget (ARGO_API_RETRIES ×
@@ -175,7 +197,7 @@ func (argo *Argo) AddTask(task models.Task) (*models.Task, error) {
 		return nil, errors.New(models.StatusArgoCDUnavailableMessage)
 	}
 
-	if task.Images == nil || len(task.Images) == 0 {
+	if len(task.Images) == 0 && task.ChartVersion == "" {
 		return nil, fmt.Errorf("trying to create task without images")
 	}
 
@@ -186,8 +208,30 @@ func (argo *Argo) AddTask(task models.Task) (*models.Task, error) {
 	// Always overwrite the rollback fields from server-side history so a
 	// client-supplied value (e.g. echoed back by the "rollback to this version"
 	// action) can never influence the stored result.
-	task.RollbackTargetId = argo.detectRollback(task)
+	deployed, _ := argo.State.GetTasks(0, float64(time.Now().Unix()), task.App, models.StatusDeployedMessage, rollbackHistoryWindow, 0)
+	task.RollbackTargetId = detectRollback(task, deployed)
 	task.IsRollback = task.RollbackTargetId != ""
+	// Only an automatic rollback reverts a failed task, and it is never rolled back in
+	// turn: a submitted task naming one would opt out of automatic rollbacks.
+	task.RollbackOfId = ""
+
+	// The status reason is the server's to write, like the rollback fields: the only
+	// one a new task carries records why a downgrade was let through. So are the pull
+	// request and the write-back, which a task names only once its write-back proposed
+	// or committed them; a commit sent by the client would be waited for in ArgoCD.
+	task.StatusReason = ""
+	task.PullRequest = nil
+	task.WriteBack = nil
+	if argo.PreventDowngrades {
+		reason, err := checkDowngrade(task, deployed)
+		if err != nil {
+			return nil, err
+		}
+		if reason != "" {
+			slog.Info("Letting a downgrade through", "app", task.App, "reason", reason)
+		}
+		task.StatusReason = reason
+	}
 
 	// Superseding stops the watcher polling ArgoCD for a rollout nobody is waiting
 	// on anymore (issue #353). Matching on image name
@@ -216,13 +260,17 @@ func (argo *Argo) AddTask(task models.Task) (*models.Task, error) {
 	// its first renewal that it holds no claim, and stops without writing a status.
 	// A sweep then picks the task up and resumes it, re-running the write-back
 	// idempotently. The cost is a delayed deployment, not a lost or duplicated one.
-	if err := argo.State.ClaimTask(newTask.Id); err != nil {
-		slog.Warn("Failed to claim the new task for this replica", "error", err, "id", newTask.Id)
+	//
+	// A queued task is claimed when it leaves the queue instead (StartQueuedTask).
+	if newTask.Status != models.StatusQueuedMessage {
+		if err := argo.State.ClaimTask(newTask.Id); err != nil {
+			slog.Warn("Failed to claim the new task for this replica", "error", err, "id", newTask.Id)
+		}
 	}
 
 	slog.Info("A new task was triggered", "id", newTask.Id)
 	for index, value := range newTask.Images {
-		slog.Info("Task image expecting tag", "index", index, "tag", value.Tag, "app", task.App, "id", newTask.Id)
+		slog.Info("Task image expecting tag", "index", index, "tag", value.Tag, "digest", value.Digest, "app", task.App, "id", newTask.Id)
 	}
 
 	argo.metrics.AddAcceptedDeployment()
@@ -234,8 +282,8 @@ func (argo *Argo) AddTask(task models.Task) (*models.Task, error) {
 // was successfully deployed at some earlier point for the app AND differs from the
 // current (most recently deployed) version; redeploying the current version is not a
 // rollback. The returned ID is the most recent earlier task carrying that image set.
-func (argo *Argo) detectRollback(task models.Task) string {
-	deployed, _ := argo.State.GetTasks(0, float64(time.Now().Unix()), task.App, models.StatusDeployedMessage, rollbackHistoryWindow, 0)
+// deployed holds the app's most recent successfully deployed tasks, newest first.
+func detectRollback(task models.Task, deployed []models.Task) string {
 	if len(deployed) == 0 {
 		return ""
 	}
@@ -258,9 +306,18 @@ func (argo *Argo) detectRollback(task models.Task) string {
 }
 
 // imageSignature returns a key for a task's image set that is independent of the
-// order the images arrived in.
+// order the images arrived in. A chart version task is keyed by its version. Digests
+// are left out: a task pinned to one matches an earlier task of the same tags that
+// was stored before pinning, or with a signature policy that pinned none.
 func imageSignature(task models.Task) string {
-	return strings.Join(helpers.NormalizeImages(task.ListImages()), ",")
+	if task.ChartVersion != "" {
+		return "chart:" + task.ChartVersion
+	}
+	images := make([]string, len(task.Images))
+	for index, image := range task.Images {
+		images[index] = image.Image + ":" + image.Tag
+	}
+	return strings.Join(helpers.NormalizeImages(images), ",")
 }
 
 // GetTasks retrieves tasks from the state.
@@ -1,15 +1,18 @@
 package argocd
 
 import (
+	"bytes"
 	"context"
 	"crypto/tls"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"log/slog"
 	"net/http"
 	"net/http/cookiejar"
 	"net/url"
+	"strconv"
 	"time"
 
 	retry "github.com/avast/retry-go/v4"
@@ -22,8 +25,13 @@ type ArgoApiInterface interface {
 	Init(serverConfig *config.ServerConfig) error
 	GetUserInfo() (*models.Userinfo, error)
 	GetApplication(ctx context.Context, app string, refresh bool) (*models.Application, error)
+	ListApplications(ctx context.Context, selector string) ([]models.Application, error)
 	GetResourceTree(ctx context.Context, app string) (*models.ApplicationTree, error)
 	GetManagedResources(ctx context.Context, app string) (*models.ManagedResources, error)
+	SyncApplication(ctx context.Context, app string, request models.ApplicationSyncRequest) (*models.Application, error)
+	GetRollout(ctx context.Context, app string, node models.ApplicationTreeNode) (*models.Rollout, error)
+	GetResourceEvents(ctx context.Context, app string, node models.ApplicationTreeNode) ([]models.KubernetesEvent, error)
+	GetPodLogs(ctx context.Context, app string, pod models.ApplicationTreeNode, tailLines uint, previous bool) ([]string, error)
 }
 
 type ArgoApi struct {
@@ -34,6 +42,10 @@ type ArgoApi struct {
 	requestFn func(method, url string, body io.Reader) (*http.Request, error)
 	// cookieJarFn allows injecting a custom cookie jar factory for testing.
 	cookieJarFn func(o *cookiejar.Options) (*cookiejar.Jar, error)
+	// session logs in with account credentials when they replace a static token; nil
+	// otherwise (see withSession).
+	session      *argoSession
+	tokenMetrics tokenExpiryRecorder
 }
 
 // NewArgoApi constructs an ArgoApi with default HTTP helpers.
@@ -52,15 +64,6 @@ func (api *ArgoApi) Init(serverConfig *config.ServerConfig) error {
 	if err != nil {
 		return err
 	}
-	// This is an outbound request cookie sent to the ArgoCD API through the
-	// client's cookie jar, not a Set-Cookie response to a browser, so G124's
-	// Secure/HttpOnly/SameSite attributes do not apply — the Go HTTP client
-	// ignores those browser-storage directives when sending.
-	cookie := &http.Cookie{ // #nosec G124
-		Name:  "argocd.token",
-		Value: serverConfig.ArgoToken,
-	}
-	jar.SetCookies(&api.baseUrl, []*http.Cookie{cookie})
 	transport := &http.Transport{
 		TLSClientConfig: &tls.Config{InsecureSkipVerify: serverConfig.SkipTlsVerify}, // #nosec G402
 	}
@@ -70,6 +73,18 @@ func (api *ArgoApi) Init(serverConfig *config.ServerConfig) error {
 		Timeout:   time.Duration(serverConfig.ArgoApiTimeout) * time.Second,
 	}
 
+	// Account credentials are exchanged for a session token on the first call rather
+	// than here, so an ArgoCD that is down at startup delays nothing but that call.
+	if serverConfig.ArgoUsername != "" {
+		api.session = &argoSession{username: serverConfig.ArgoUsername, password: serverConfig.ArgoPassword}
+		slog.Debug("Authenticating to ArgoCD with account credentials", "username", serverConfig.ArgoUsername)
+	} else {
+		api.setToken(serverConfig.ArgoToken)
+		if expiry := api.recordTokenExpiry(serverConfig.ArgoToken); !expiry.IsZero() {
+			slog.Info("ArgoCD token expires; rotate ARGO_TOKEN before then, or log in with ARGO_USERNAME and ARGO_PASSWORD instead", "expiry", expiry)
+		}
+	}
+
 	slog.Debug("Timeout for ArgoCD API calls set", "timeout", api.client.Timeout)
 
 	api.maxRetries = serverConfig.ArgoApiRetries
@@ -88,6 +103,11 @@ func (api *ArgoApi) Init(serverConfig *config.ServerConfig) error {
 // no further attempts are made, so a slow ArgoCD cannot stretch a single call past the
 // caller's deadline.
 func (api *ArgoApi) doGet(ctx context.Context, reqURL string) ([]byte, int, error) {
+	return api.withSession(ctx, func() ([]byte, int, error) { return api.get(ctx, reqURL) })
+}
+
+// get is doGet without the session handling.
+func (api *ArgoApi) get(ctx context.Context, reqURL string) ([]byte, int, error) {
 	req, err := api.requestFn("GET", reqURL, nil)
 	if err != nil {
 		return nil, 0, err
@@ -131,6 +151,48 @@ func (api *ArgoApi) doGet(ctx context.Context, reqURL string) ([]byte, int, erro
 	return body, resp.StatusCode, nil
 }
 
+// doPost sends payload as a JSON POST to reqURL and returns the response body and status code.
+// Unlike doGet it makes a single attempt: a POST asks ArgoCD to act, and retrying a round-trip
+// that failed after ArgoCD received it would ask twice. Callers that need the action to happen
+// retry it themselves, on their own schedule.
+func (api *ArgoApi) doPost(ctx context.Context, reqURL string, payload any) ([]byte, int, error) {
+	return api.withSession(ctx, func() ([]byte, int, error) { return api.post(ctx, reqURL, payload) })
+}
+
+// post is doPost without the session handling, which the login itself is made with.
+func (api *ArgoApi) post(ctx context.Context, reqURL string, payload any) ([]byte, int, error) {
+	encoded, err := json.Marshal(payload)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	req, err := api.requestFn("POST", reqURL, bytes.NewReader(encoded))
+	if err != nil {
+		return nil, 0, err
+	}
+
+	req = req.WithContext(ctx)
+	req.Header.Set("Accept", "application/json")
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := api.client.Do(req)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer func() {
+		if closeErr := resp.Body.Close(); closeErr != nil {
+			slog.Error("failed to close response body", "error", closeErr)
+		}
+	}()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	return body, resp.StatusCode, nil
+}
+
 // ArgoAPIError is a non-2xx HTTP response from the ArgoCD API. It carries the
 // status code so callers can tell a 5xx outage from a 4xx application error.
 // Error returns ArgoCD's message so IsAppNotFoundError keeps working.
@@ -160,10 +222,30 @@ func parseArgoErrorResponse(statusCode int, body []byte) error {
 	return &ArgoAPIError{StatusCode: statusCode, Message: fmt.Sprintf("failed parsing argocd API response: %s", string(body))}
 }
 
+// GetUserInfo asks ArgoCD about the account the API is used with. With a session, a
+// token ArgoCD reports as logged out is renewed and the question asked once more.
 func (api *ArgoApi) GetUserInfo() (*models.Userinfo, error) {
+	ctx := context.Background()
+	userInfo, err := api.getUserInfo(ctx)
+	if err != nil || userInfo.LoggedIn || api.session == nil {
+		return userInfo, err
+	}
+
+	// ArgoCD does not reject an expired token here, as it does on every other call: it
+	// answers 200 with loggedIn false. Left alone, that would fail the availability check
+	// until a restart, as no 401 ever makes the session log in again.
+	slog.Info("ArgoCD no longer accepts the session token; logging in again", "username", api.session.username)
+	if _, err := api.sessionToken(ctx, api.session.currentToken()); err != nil {
+		return nil, err
+	}
+	return api.getUserInfo(ctx)
+}
+
+// getUserInfo makes a single userinfo call.
+func (api *ArgoApi) getUserInfo(ctx context.Context) (*models.Userinfo, error) {
 	apiUrl := fmt.Sprintf("%s/api/v1/session/userinfo", api.baseUrl.String())
 
-	body, statusCode, err := api.doGet(context.Background(), apiUrl)
+	body, statusCode, err := api.doGet(ctx, apiUrl)
 	if err != nil {
 		return nil, err
 	}
@@ -208,6 +290,33 @@ func (api *ArgoApi) GetApplication(ctx context.Context, app string, refresh bool
 	return &argoApp, nil
 }
 
+// ListApplications returns the applications matching the label selector, or every
+// application the configured account can see when selector is empty. ArgoCD filters
+// by its RBAC, so an application the account may not read is simply not listed.
+func (api *ArgoApi) ListApplications(ctx context.Context, selector string) ([]models.Application, error) {
+	apiUrl := fmt.Sprintf("%s/api/v1/applications", api.baseUrl.String())
+	if selector != "" {
+		apiUrl += "?selector=" + url.QueryEscape(selector)
+	}
+
+	body, statusCode, err := api.doGet(ctx, apiUrl)
+	if err != nil {
+		slog.Error("failed to execute request", "error", err)
+		return nil, err
+	}
+
+	if statusCode != http.StatusOK {
+		return nil, parseArgoErrorResponse(statusCode, body)
+	}
+
+	var list models.ApplicationList
+	if err = json.Unmarshal(body, &list); err != nil {
+		return nil, fmt.Errorf("could not parse applications list response: %w", err)
+	}
+
+	return list.Items, nil
+}
+
 // GetResourceTree fetches the live resource tree of the named ArgoCD application. It is the only
 // source that exposes descendant resources — notably the Pods, whose health carries the actual
 // failure cause (ImagePullBackOff, CrashLoopBackOff) absent from the application's top-level
@@ -260,3 +369,140 @@ func (api *ArgoApi) GetManagedResources(ctx context.Context, app string) (*model
 
 	return &resources, nil
 }
+
+// SyncApplication asks ArgoCD to sync the named application and returns the application as
+// ArgoCD recorded the request. The sync itself runs asynchronously: its outcome is reported
+// later in the application's operation state. The caller logs, because an error here is
+// retried on the next poll rather than failing the deployment outright.
+func (api *ArgoApi) SyncApplication(ctx context.Context, app string, request models.ApplicationSyncRequest) (*models.Application, error) {
+	apiUrl := fmt.Sprintf("%s/api/v1/applications/%s/sync", api.baseUrl.String(), url.PathEscape(app))
+
+	body, statusCode, err := api.doPost(ctx, apiUrl, request)
+	if err != nil {
+		return nil, err
+	}
+
+	if statusCode != http.StatusOK {
+		return nil, parseArgoErrorResponse(statusCode, body)
+	}
+
+	var argoApp models.Application
+	if err = json.Unmarshal(body, &argoApp); err != nil {
+		return nil, fmt.Errorf("could not parse sync response: %w", err)
+	}
+
+	return &argoApp, nil
+}
+
+// GetRollout fetches the live state of an Argo Rollouts Rollout the named application owns,
+// identified by its resource-tree node. The Rollout's step and traffic weight exist only in
+// its own status, which neither the application nor the tree carries. Like GetResourceTree
+// it does not log: progress reporting is best-effort and the caller logs at Debug.
+func (api *ArgoApi) GetRollout(ctx context.Context, app string, node models.ApplicationTreeNode) (*models.Rollout, error) {
+	query := url.Values{}
+	query.Set("resourceName", node.Name)
+	query.Set("namespace", node.Namespace)
+	query.Set("group", node.Group)
+	query.Set("version", node.Version)
+	query.Set("kind", node.Kind)
+
+	apiUrl := fmt.Sprintf("%s/api/v1/applications/%s/resource?%s", api.baseUrl.String(), url.PathEscape(app), query.Encode())
+
+	body, statusCode, err := api.doGet(ctx, apiUrl)
+	if err != nil {
+		return nil, err
+	}
+
+	if statusCode != http.StatusOK {
+		return nil, parseArgoErrorResponse(statusCode, body)
+	}
+
+	var resource models.LiveResource
+	if err = json.Unmarshal(body, &resource); err != nil {
+		return nil, fmt.Errorf("could not parse resource response: %w", err)
+	}
+
+	var rollout models.Rollout
+	if err = json.Unmarshal([]byte(resource.Manifest), &rollout); err != nil {
+		return nil, fmt.Errorf("could not parse rollout manifest: %w", err)
+	}
+
+	return &rollout, nil
+}
+
+// GetResourceEvents fetches the Kubernetes events of one resource the named application
+// owns, identified by its resource-tree node. Like GetResourceTree it does not log: it is
+// called only on the failure path to enrich the reported reason, and the caller logs at Debug.
+func (api *ArgoApi) GetResourceEvents(ctx context.Context, app string, node models.ApplicationTreeNode) ([]models.KubernetesEvent, error) {
+	query := url.Values{}
+	query.Set("resourceName", node.Name)
+	query.Set("resourceNamespace", node.Namespace)
+	query.Set("resourceUID", node.UID)
+
+	apiUrl := fmt.Sprintf("%s/api/v1/applications/%s/events?%s", api.baseUrl.String(), url.PathEscape(app), query.Encode())
+
+	body, statusCode, err := api.doGet(ctx, apiUrl)
+	if err != nil {
+		return nil, err
+	}
+
+	if statusCode != http.StatusOK {
+		return nil, parseArgoErrorResponse(statusCode, body)
+	}
+
+	var events models.KubernetesEventList
+	if err = json.Unmarshal(body, &events); err != nil {
+		return nil, fmt.Errorf("could not parse events response: %w", err)
+	}
+
+	return events.Items, nil
+}
+
+// GetPodLogs fetches the last tailLines log lines of a Pod the named application owns. With
+// previous set it reads the logs of the container instance that terminated last, which is
+// where a crash-looping container's failure is. ArgoCD streams the logs as newline-delimited
+// JSON; follow is off, so the stream ends once the tail has been sent. Like GetResourceEvents
+// it does not log.
+func (api *ArgoApi) GetPodLogs(ctx context.Context, app string, pod models.ApplicationTreeNode, tailLines uint, previous bool) ([]string, error) {
+	query := url.Values{}
+	query.Set("podName", pod.Name)
+	query.Set("namespace", pod.Namespace)
+	query.Set("tailLines", strconv.FormatUint(uint64(tailLines), 10))
+	query.Set("follow", "false")
+	query.Set("previous", strconv.FormatBool(previous))
+
+	apiUrl := fmt.Sprintf("%s/api/v1/applications/%s/logs?%s", api.baseUrl.String(), url.PathEscape(app), query.Encode())
+
+	body, statusCode, err := api.doGet(ctx, apiUrl)
+	if err != nil {
+		return nil, err
+	}
+
+	if statusCode != http.StatusOK {
+		return nil, parseArgoErrorResponse(statusCode, body)
+	}
+
+	return parsePodLogStream(body)
+}
+
+// parsePodLogStream decodes the newline-delimited log stream of GetPodLogs. An error ArgoCD
+// reports in the stream — a pod with several containers, say, whose logs need a container
+// name — fails the whole read, so a partial tail is never reported as the complete one.
+func parsePodLogStream(body []byte) ([]string, error) {
+	var lines []string
+	decoder := json.NewDecoder(bytes.NewReader(body))
+	for decoder.More() {
+		var entry models.PodLogEntry
+		if err := decoder.Decode(&entry); err != nil {
+			return nil, fmt.Errorf("could not parse logs response: %w", err)
+		}
+		if entry.Error != nil {
+			return nil, errors.New(entry.Error.Message)
+		}
+		if entry.Result.Last {
+			break
+		}
+		lines = append(lines, entry.Result.Content)
+	}
+	return lines, nil
+}
@@ -958,3 +958,266 @@ func TestArgoApiDoGetNoRetryOnNon2xx(t *testing.T) {
 	assert.Equal(t, errorBody, body)
 	assert.Equal(t, int32(1), callCount.Load())
 }
+
+func TestArgoApiSyncApplicationSuccess(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, http.MethodPost, r.Method)
+		assert.Equal(t, "/api/v1/applications/demo/sync", r.URL.Path)
+		assert.Equal(t, "application/json", r.Header.Get("Content-Type"))
+
+		var request models.ApplicationSyncRequest
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&request))
+		assert.Equal(t, models.ApplicationSyncRequest{Revision: "main", Prune: true}, request)
+
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"metadata":{"name":"demo"}}`))
+	}))
+	defer server.Close()
+
+	parsedURL, err := url.Parse(server.URL)
+	require.NoError(t, err)
+
+	api := NewArgoApi()
+	api.baseUrl = *parsedURL
+	api.client = server.Client()
+	api.maxRetries = 1
+
+	app, err := api.SyncApplication(context.Background(), "demo", models.ApplicationSyncRequest{Revision: "main", Prune: true})
+	require.NoError(t, err)
+	assert.Equal(t, "demo", app.Metadata.Name)
+}
+
+func TestArgoApiSyncApplicationError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusBadRequest)
+		_, _ = w.Write([]byte(`{"code":9,"message":"another operation is already in progress"}`))
+	}))
+	defer server.Close()
+
+	parsedURL, err := url.Parse(server.URL)
+	require.NoError(t, err)
+
+	api := NewArgoApi()
+	api.baseUrl = *parsedURL
+	api.client = server.Client()
+	api.maxRetries = 1
+
+	_, err = api.SyncApplication(context.Background(), "demo", models.ApplicationSyncRequest{})
+
+	var apiErr *ArgoAPIError
+	require.ErrorAs(t, err, &apiErr)
+	assert.Equal(t, http.StatusBadRequest, apiErr.StatusCode)
+	assert.Equal(t, "another operation is already in progress", apiErr.Message)
+}
+
+// TestArgoApiSyncApplicationDoesNotRetry pins the single attempt: a POST that failed in
+// transit may still have reached ArgoCD, and repeating it would request a second sync.
+func TestArgoApiSyncApplicationDoesNotRetry(t *testing.T) {
+	var calls atomic.Int32
+
+	api := NewArgoApi()
+	api.baseUrl = url.URL{Scheme: "http", Host: "argocd.invalid"}
+	api.maxRetries = 3
+	api.client = &http.Client{Transport: roundTripperFunc(func(*http.Request) (*http.Response, error) {
+		calls.Add(1)
+		return nil, errors.New("connection reset")
+	})}
+
+	_, err := api.SyncApplication(context.Background(), "demo", models.ApplicationSyncRequest{})
+	require.Error(t, err)
+	assert.Equal(t, int32(1), calls.Load())
+}
+
+func TestArgoApiGetRolloutSuccess(t *testing.T) {
+	manifest := `{"metadata":{"name":"web"},"spec":{"strategy":{"canary":{"steps":[{"setWeight":20},{"pause":{}}]}}},"status":{"phase":"Paused","currentStepIndex":1}}`
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/api/v1/applications/demo/resource", r.URL.Path)
+		assert.Equal(t, url.Values{
+			"resourceName": {"web"},
+			"namespace":    {"apps"},
+			"group":        {"argoproj.io"},
+			"version":      {"v1alpha1"},
+			"kind":         {"Rollout"},
+		}, r.URL.Query())
+		w.Header().Set("Content-Type", "application/json")
+		require.NoError(t, json.NewEncoder(w).Encode(models.LiveResource{Manifest: manifest}))
+	}))
+	defer server.Close()
+
+	parsedURL, err := url.Parse(server.URL)
+	require.NoError(t, err)
+
+	api := NewArgoApi()
+	api.baseUrl = *parsedURL
+	api.client = server.Client()
+	api.maxRetries = 1
+
+	rollout, err := api.GetRollout(context.Background(), "demo", models.ApplicationTreeNode{
+		Group: "argoproj.io", Version: "v1alpha1", Kind: "Rollout", Name: "web", Namespace: "apps",
+	})
+	require.NoError(t, err)
+	assert.Equal(t, "web", rollout.Metadata.Name)
+	assert.Equal(t, 2, rollout.CurrentStep())
+	assert.Equal(t, int32(20), rollout.CanaryWeight())
+}
+
+func TestArgoApiGetRolloutError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusForbidden)
+		require.NoError(t, json.NewEncoder(w).Encode(models.ArgoApiErrorResponse{Message: "permission denied"}))
+	}))
+	defer server.Close()
+
+	parsedURL, err := url.Parse(server.URL)
+	require.NoError(t, err)
+
+	api := NewArgoApi()
+	api.baseUrl = *parsedURL
+	api.client = server.Client()
+	api.maxRetries = 1
+
+	_, err = api.GetRollout(context.Background(), "demo", models.ApplicationTreeNode{Kind: "Rollout", Name: "web"})
+
+	var apiErr *ArgoAPIError
+	require.ErrorAs(t, err, &apiErr)
+	assert.Equal(t, http.StatusForbidden, apiErr.StatusCode)
+}
+
+func TestArgoApiListApplicationsSuccess(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/api/v1/applications", r.URL.Path)
+		assert.Equal(t, "team=payments,tier!=db", r.URL.Query().Get("selector"))
+		w.Header().Set("Content-Type", "application/json")
+		_, err := w.Write([]byte(`{"items":[
+			{"metadata":{"name":"api","ownerReferences":[{"kind":"ApplicationSet","name":"payments"}]}},
+			{"metadata":{"name":"worker"}}
+		]}`))
+		require.NoError(t, err)
+	}))
+	defer server.Close()
+
+	parsedURL, err := url.Parse(server.URL)
+	require.NoError(t, err)
+
+	api := NewArgoApi()
+	api.baseUrl = *parsedURL
+	api.client = server.Client()
+	api.maxRetries = 1
+
+	apps, err := api.ListApplications(context.Background(), "team=payments,tier!=db")
+	require.NoError(t, err)
+	require.Len(t, apps, 2)
+	assert.Equal(t, "api", apps[0].Metadata.Name)
+	assert.True(t, apps[0].IsOwnedByApplicationSet("payments"))
+	assert.False(t, apps[1].IsOwnedByApplicationSet("payments"))
+}
+
+func TestArgoApiListApplicationsError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Empty(t, r.URL.RawQuery, "no selector lists every application")
+		w.WriteHeader(http.StatusBadRequest)
+		require.NoError(t, json.NewEncoder(w).Encode(models.ArgoApiErrorResponse{Message: "unable to parse requirement"}))
+	}))
+	defer server.Close()
+
+	parsedURL, err := url.Parse(server.URL)
+	require.NoError(t, err)
+
+	api := NewArgoApi()
+	api.baseUrl = *parsedURL
+	api.client = server.Client()
+	api.maxRetries = 1
+
+	_, err = api.ListApplications(context.Background(), "")
+
+	var apiErr *ArgoAPIError
+	require.ErrorAs(t, err, &apiErr)
+	assert.Equal(t, http.StatusBadRequest, apiErr.StatusCode)
+}
+
+func TestArgoApiGetResourceEvents(t *testing.T) {
+	pod := models.ApplicationTreeNode{Kind: "Pod", Name: "demo-xyz", Namespace: "demo", UID: "uid-1"}
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/api/v1/applications/demo/events", r.URL.Path)
+		assert.Equal(t, "demo-xyz", r.URL.Query().Get("resourceName"))
+		assert.Equal(t, "demo", r.URL.Query().Get("resourceNamespace"))
+		assert.Equal(t, "uid-1", r.URL.Query().Get("resourceUID"))
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"metadata":{},"items":[{"type":"Warning","reason":"BackOff","message":"Back-off restarting failed container","count":7,"lastTimestamp":"2026-01-02T03:04:05Z"}]}`))
+	}))
+	defer server.Close()
+
+	parsedURL, err := url.Parse(server.URL)
+	require.NoError(t, err)
+
+	api := NewArgoApi()
+	api.baseUrl = *parsedURL
+	api.client = server.Client()
+	api.maxRetries = 1
+
+	events, err := api.GetResourceEvents(context.Background(), "demo", pod)
+	require.NoError(t, err)
+	require.Len(t, events, 1)
+	assert.Equal(t, "BackOff", events[0].Reason)
+	assert.Equal(t, 7, events[0].Count)
+	assert.Equal(t, time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC), events[0].OccurredAt())
+}
+
+func TestArgoApiGetPodLogs(t *testing.T) {
+	pod := models.ApplicationTreeNode{Kind: "Pod", Name: "demo-xyz", Namespace: "demo"}
+
+	newAPI := func(t *testing.T, handler http.HandlerFunc) *ArgoApi {
+		server := httptest.NewServer(handler)
+		t.Cleanup(server.Close)
+
+		parsedURL, err := url.Parse(server.URL)
+		require.NoError(t, err)
+
+		api := NewArgoApi()
+		api.baseUrl = *parsedURL
+		api.client = server.Client()
+		api.maxRetries = 1
+		return api
+	}
+
+	t.Run("Reads the log tail from the stream", func(t *testing.T) {
+		api := newAPI(t, func(w http.ResponseWriter, r *http.Request) {
+			assert.Equal(t, "/api/v1/applications/demo/logs", r.URL.Path)
+			assert.Equal(t, "demo-xyz", r.URL.Query().Get("podName"))
+			assert.Equal(t, "demo", r.URL.Query().Get("namespace"))
+			assert.Equal(t, "20", r.URL.Query().Get("tailLines"))
+			assert.Equal(t, "false", r.URL.Query().Get("follow"))
+			assert.Equal(t, "true", r.URL.Query().Get("previous"))
+			_, _ = w.Write([]byte(`{"result":{"content":"starting","last":false}}` + "\n" +
+				`{"result":{"content":"panic: boom","last":false}}` + "\n" +
+				`{"result":{"content":"","last":true}}` + "\n"))
+		})
+
+		lines, err := api.GetPodLogs(context.Background(), "demo", pod, 20, true)
+		require.NoError(t, err)
+		assert.Equal(t, []string{"starting", "panic: boom"}, lines)
+	})
+
+	t.Run("Fails on an error reported in the stream", func(t *testing.T) {
+		api := newAPI(t, func(w http.ResponseWriter, _ *http.Request) {
+			_, _ = w.Write([]byte(`{"error":{"grpc_code":3,"message":"a container name must be specified"}}` + "\n"))
+		})
+
+		_, err := api.GetPodLogs(context.Background(), "demo", pod, 20, false)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "a container name must be specified")
+	})
+
+	t.Run("Fails on a non-200 response", func(t *testing.T) {
+		api := newAPI(t, func(w http.ResponseWriter, _ *http.Request) {
+			w.WriteHeader(http.StatusForbidden)
+			_, _ = w.Write([]byte(`{"message":"permission denied"}`))
+		})
+
+		_, err := api.GetPodLogs(context.Background(), "demo", pod, 20, false)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "permission denied")
+	})
+}
@@ -10,18 +10,25 @@ import (
 
 	"github.com/avast/retry-go/v4"
 
+	"github.com/shini4i/argo-watcher/internal/analysis"
 	"github.com/shini4i/argo-watcher/internal/config"
 	"github.com/shini4i/argo-watcher/internal/lock"
 	"github.com/shini4i/argo-watcher/internal/models"
 	"github.com/shini4i/argo-watcher/internal/notifications"
 	"github.com/shini4i/argo-watcher/internal/state"
+	"github.com/shini4i/argo-watcher/internal/updater"
 )
 
 // ArgoStatusUpdater handles the monitoring and updating of ArgoCD application deployments
 type ArgoStatusUpdater struct {
 	monitor    *DeploymentMonitor
 	gitUpdater *GitUpdater
-	notifier   *notifications.Notifier
+	// merges follows the pull requests of write-backs proposed rather than pushed. Nil
+	// when no pull request provider is configured.
+	merges *mergeWatcher
+	// repoCache bounds the repository cache. Nil when no limits are configured.
+	repoCache *RepoCache
+	notifier  *notifications.Notifier
 	// leaseRenewInterval and leaseTTL parameterize the claim a monitored rollout
 	// holds. They are fields rather than constants so tests can drive a takeover
 	// without waiting out a real lease.
@@ -37,6 +44,18 @@ type ArgoStatusUpdaterConfig struct {
 	RepoCachePath    string
 	AcceptSuspended  bool
 	RefreshApp       bool
+	// SyncApp makes the monitor trigger the sync of every application without auto-sync,
+	// unless its argo-watcher/sync annotation says otherwise.
+	SyncApp bool
+	// RolloutMaxDuration lets the deadline of an Argo Rollouts update move while its steps
+	// keep advancing, up to this bound. Zero keeps the deadline fixed.
+	RolloutMaxDuration time.Duration
+	// Analysis runs the post-deployment analysis an application declares once its rollout
+	// succeeded. Nil runs none.
+	Analysis *analysis.Gate
+	// FailureLogLines is how many log lines of each unhealthy pod a rollout failure reason
+	// quotes. Zero quotes none; the pods' events are reported either way.
+	FailureLogLines  uint
 	WebhookConfig    *config.WebhookConfig
 	MattermostConfig *config.MattermostConfig
 	Locker           lock.Locker
@@ -44,6 +63,11 @@ type ArgoStatusUpdaterConfig struct {
 	BatchWriteBack bool
 	// BatchMaxSize bounds the number of apps committed in a single batch flush.
 	BatchMaxSize uint
+	// PullRequests configures the provider write-backs are proposed through when an
+	// application asks for the pull-request write-back method. Nil configures none.
+	PullRequests *updater.PullRequestConfig
+	// RepoCache bounds the repository cache under RepoCachePath. Nil leaves it unbounded.
+	RepoCache *updater.CacheConfig
 }
 
 // Init initializes the ArgoStatusUpdater with the provided configuration
@@ -63,6 +87,10 @@ func (updater *ArgoStatusUpdater) Init(argo Argo, cfg ArgoStatusUpdaterConfig) e
 	updater.monitor = NewDeploymentMonitor(argo, cfg.RegistryProxyURL, retryOptions, cfg.AcceptSuspended, cfg.RetryDelay)
 	updater.monitor.defaultAttempts = cfg.RetryAttempts
 	updater.monitor.refreshApp = cfg.RefreshApp
+	updater.monitor.syncApp = cfg.SyncApp
+	updater.monitor.rolloutMaxDuration = cfg.RolloutMaxDuration
+	updater.monitor.analysis = cfg.Analysis
+	updater.monitor.failureLogLines = cfg.FailureLogLines
 
 	var batcher *Batcher
 	if cfg.BatchWriteBack {
@@ -70,6 +98,17 @@ func (updater *ArgoStatusUpdater) Init(argo Argo, cfg ArgoStatusUpdaterConfig) e
 		slog.Info("Git write-back batch mode enabled", "max_batch_size", cfg.BatchMaxSize)
 	}
 	updater.gitUpdater = NewGitUpdater(cfg.Locker, cfg.RepoCachePath, argo.metrics, batcher)
+	updater.monitor.history = updater.gitUpdater
+
+	updater.merges = newMergeWatcher(cfg.PullRequests)
+	if updater.merges != nil {
+		updater.gitUpdater.pullRequests = updater.merges.provider
+		slog.Info("Pull request write-back enabled", "provider", cfg.PullRequests.Provider)
+	}
+
+	if cfg.RepoCache != nil {
+		updater.repoCache = NewRepoCache(cfg.RepoCachePath, cfg.Locker, argo.metrics, cfg.RepoCache)
+	}
 
 	var strategies []notifications.NotificationStrategy
 
@@ -112,6 +151,15 @@ func (updater *ArgoStatusUpdater) Close(ctx context.Context) {
 	}
 }
 
+// RunRepoCacheJanitor keeps the repository cache within its limits until stop is closed.
+// It returns at once when no limits are configured.
+func (updater *ArgoStatusUpdater) RunRepoCacheJanitor(stop <-chan struct{}) {
+	if updater.repoCache == nil {
+		return
+	}
+	updater.repoCache.Run(stop)
+}
+
 // WaitForRollout monitors the application until it reaches a final state (deployed
 // or failed), or stops early if a newer deployment for the same app supersedes it
 // (issue #353), or if another replica takes the task over.
@@ -133,7 +181,18 @@ func neverDraining() bool { return false }
 // gives the rollout up: draining reports that shutdown has begun, which ends the
 // monitoring as a takeover would — without a status, so the replica that resumes
 // the task records the outcome instead.
+//
+// A failed deployment rolled back automatically is followed by the monitoring of its
+// rollback, on the same terms.
 func (updater *ArgoStatusUpdater) waitForRollout(task models.Task, resumed bool, draining func() bool) {
+	for next := &task; next != nil; resumed = false {
+		next = updater.monitorRollout(*next, resumed, draining)
+	}
+}
+
+// monitorRollout monitors one deployment through to its outcome, and returns the
+// automatic rollback it started when it failed, if any.
+func (updater *ArgoStatusUpdater) monitorRollout(task models.Task, resumed bool, draining func() bool) *models.Task {
 	updater.monitor.BeginTracking()
 	defer updater.monitor.EndTracking()
 
@@ -159,7 +218,7 @@ func (updater *ArgoStatusUpdater) waitForRollout(task models.Task, resumed bool,
 	// about the claim alone.
 	abandoned := func() bool { return lease.Lost() || draining() }
 
-	application, waited, confirmed, err := updater.waitForApplicationDeployment(task, abandoned)
+	application, waited, confirmed, err := updater.waitForApplicationDeployment(&task, abandoned)
 
 	// Re-checked here because only the poll loop and the write-back consult the
 	// predicate themselves. Every other way out — a failed fetch, a write-back error,
@@ -180,6 +239,14 @@ func (updater *ArgoStatusUpdater) waitForRollout(task models.Task, resumed bool,
 	}
 
 	var imageErr *ImageNotPartOfAppError
+	var syncErr *SyncOperationError
+	var pausedErr *RolloutPausedError
+	var childErr *ChildRolloutError
+	var analysisErr *analysis.FailedError
+	var prErr *PullRequestError
+	var commitErr *CommitNotSyncedError
+	var chartErr *ChartNotSyncedError
+	var skipErr *WriteBackSkippedError
 
 	switch {
 	case errors.Is(err, errReplicaDraining):
@@ -189,15 +256,31 @@ func (updater *ArgoStatusUpdater) waitForRollout(task models.Task, resumed bool,
 		// once the write-back batcher is closed, it would be a failure for a rollout
 		// that is otherwise healthy.
 		slog.Info("Stopped monitoring a deployment while shutting down; another replica will resume it.", "id", task.Id)
-		return
+		return nil
 	case errors.Is(err, errLeaseLost):
 		// Another replica owns this task now and is monitoring the same rollout.
 		// Writing a status here would clobber the outcome it is about to record, and
 		// notifying would announce a result this replica no longer decides.
 		slog.Info("Stopped monitoring a deployment taken over by another replica.", "id", task.Id)
-		return
+		return nil
 	case errors.As(err, &imageErr):
 		updater.monitor.HandleImageNotPartOfApp(&task, imageErr)
+	case errors.As(err, &syncErr):
+		updater.monitor.HandleSyncFailure(&task, syncErr)
+	case errors.As(err, &pausedErr):
+		updater.monitor.HandleRolloutPaused(&task, pausedErr)
+	case errors.As(err, &childErr):
+		updater.monitor.HandleChildRolloutFailure(&task, childErr)
+	case errors.As(err, &analysisErr):
+		updater.monitor.HandleAnalysisFailure(&task, analysisErr)
+	case errors.As(err, &prErr):
+		updater.monitor.HandlePullRequestFailure(&task, prErr)
+	case errors.As(err, &commitErr):
+		updater.monitor.HandleCommitNotSynced(&task, commitErr)
+	case errors.As(err, &chartErr):
+		updater.monitor.HandleChartNotSynced(&task, chartErr)
+	case errors.As(err, &skipErr):
+		updater.monitor.HandleWriteBackSkipped(&task, skipErr)
 	case errors.Is(err, errTaskSuperseded):
 		// A newer deployment for the same app already marked this task "cancelled"
 		// in the shared state (possibly on another replica). Stop without writing a
@@ -223,7 +306,15 @@ func (updater *ArgoStatusUpdater) waitForRollout(task models.Task, resumed bool,
 		updater.monitor.ObserveDeploymentDuration(task.App, time.Since(start).Seconds())
 	}
 
+	// Started before the failure is announced, so the notification names the rollback.
+	rollback := updater.startAutomaticRollback(task, application)
+	if rollback != nil {
+		updater.noteRollback(&task, rollback.Id)
+	}
+
 	sendNotification(task, updater.notifier)
+
+	return rollback
 }
 
 // abortedWriteBackCause names why a write-back gave up. Both of its stop conditions
@@ -242,19 +333,19 @@ func (updater *ArgoStatusUpdater) abortedWriteBackCause(taskId string, abandoned
 // waitForApplicationDeployment fetches the application, writes the image tag back when it is
 // managed, and polls the rollout. The returned bool reports whether ArgoCD confirmed the
 // application: every metric carrying the app name waits for that (issue #552).
-func (updater *ArgoStatusUpdater) waitForApplicationDeployment(task models.Task, abandoned func() bool) (*models.Application, time.Duration, bool, error) {
+func (updater *ArgoStatusUpdater) waitForApplicationDeployment(task *models.Task, abandoned func() bool) (*models.Application, time.Duration, bool, error) {
 	if updater.monitor.taskSuperseded(task.Id) {
 		return nil, 0, false, errTaskSuperseded
 	}
 
 	// The initial fetch happens before the timed polling loop, so it is bounded only by
 	// the HTTP client's per-request timeout rather than the rollout deadline.
-	app, err := updater.monitor.ConfirmApplication(context.Background(), task.App, updater.monitor.resolveRefresh(task))
+	app, err := updater.monitor.ConfirmApplication(context.Background(), task.App, updater.monitor.resolveRefresh(*task))
 	if err != nil {
 		return nil, 0, false, err
 	}
 
-	if err := updater.monitor.StoreInitialAppStatus(&task, app); err != nil {
+	if err := updater.monitor.StoreInitialAppStatus(task, app); err != nil {
 		return nil, 0, true, err
 	}
 
@@ -263,16 +354,39 @@ func (updater *ArgoStatusUpdater) waitForApplicationDeployment(task models.Task,
 	// supersedes it — rather than overwriting that deployment — or the moment this
 	// replica gives the rollout up, which would otherwise have two replicas pushing
 	// the same write-back, or push after the batcher was drained.
-	if err := updater.gitUpdater.UpdateIfNeeded(app, task, func() bool {
-		return updater.monitor.taskSuperseded(task.Id) || abandoned()
-	}); err != nil {
-		if errors.Is(err, ErrDeploymentSuperseded) {
-			return nil, 0, true, updater.abortedWriteBackCause(task.Id, abandoned())
-		}
+	// A task taken over from another replica after its write-back proposed a pull
+	// request is not written back again.
+	pr, err := updater.merges.resume(task)
+	if err != nil {
 		return nil, 0, true, err
 	}
+	if task.PullRequest == nil {
+		pr, err = updater.gitUpdater.UpdateIfNeeded(app, task, func() bool {
+			return updater.monitor.taskSuperseded(task.Id) || abandoned()
+		})
+		if err != nil {
+			if errors.Is(err, ErrDeploymentSuperseded) {
+				return nil, 0, true, updater.abortedWriteBackCause(task.Id, abandoned())
+			}
+			return nil, 0, true, err
+		}
+		updater.monitor.RecordWriteBack(*task)
+	}
 
-	application, waited, err := updater.monitor.WaitRollout(task, abandoned)
+	// A write-back proposed in a pull request reaches the application only once it is
+	// merged, so the rollout is not polled before then. The rollout window starts when
+	// the polling does, so the wait for a reviewer does not eat into it.
+	if pr != nil {
+		if err := updater.merges.await(updater.monitor, task, pr, isAutoMergeEnabled(app.Metadata.Annotations), abandoned); err != nil {
+			return nil, 0, true, err
+		}
+	}
+
+	application, waited, err := updater.monitor.WaitRollout(*task, abandoned)
+	updater.monitor.RecordSyncedRevision(task, application)
+	if err == nil {
+		err = updater.monitor.Analyze(*task, application, abandoned)
+	}
 	return application, waited, true, err
 }
 
@@ -29,6 +29,7 @@ import (
 	"github.com/shini4i/argo-watcher/internal/mocks"
 	"github.com/shini4i/argo-watcher/internal/models"
 	"github.com/shini4i/argo-watcher/internal/notifications"
+	"github.com/shini4i/argo-watcher/internal/updater"
 )
 
 var (
@@ -82,6 +83,8 @@ func newArgoApiMock(ctrl *gomock.Controller) *mocks.MockArgoApiInterface {
 	api := mocks.NewMockArgoApiInterface(ctrl)
 	api.EXPECT().GetResourceTree(gomock.Any(), gomock.Any()).Return(nil, nil).AnyTimes()
 	api.EXPECT().GetManagedResources(gomock.Any(), gomock.Any()).Return(nil, nil).AnyTimes()
+	api.EXPECT().GetResourceEvents(gomock.Any(), gomock.Any(), gomock.Any()).Return(nil, nil).AnyTimes()
+	api.EXPECT().GetPodLogs(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).Return(nil, nil).AnyTimes()
 	return api
 }
 
@@ -544,10 +547,30 @@ func TestDeploymentMonitorHandleDeploymentSuccessHandlesStateError(t *testing.T)
 	metrics.EXPECT().ResetFailedDeployment(task.App)
 	state.EXPECT().SetTaskStatus(task.Id, models.StatusDeployedMessage, "").Return(errors.New("update failed"))
 
-	monitor.handleDeploymentSuccess(&task)
+	monitor.handleDeploymentSuccess(&task, "")
 	assert.Equal(t, models.StatusDeployedMessage, task.Status)
 }
 
+func TestDeploymentMonitorHandleDeploymentSuccessKeepsDowngradeDecision(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	metrics := mocks.NewMockMetricsInterface(ctrl)
+	state := newTaskRepositoryMock(ctrl)
+
+	monitor := NewDeploymentMonitor(Argo{
+		metrics: metrics,
+		State:   state,
+	}, "", []retry.Option{retry.DelayType(zeroDelay), retry.LastErrorOnly(true)}, false, time.Millisecond)
+
+	task := models.Task{Id: "task-id", App: "demo", StatusReason: "Downgrade allowed, the task is marked as a rollback: app from 1.4.0 to 1.2.0"}
+
+	metrics.EXPECT().ResetFailedDeployment(task.App)
+	state.EXPECT().SetTaskStatus(task.Id, models.StatusDeployedMessage, "Downgrade allowed, the task is marked as a rollback: app from 1.4.0 to 1.2.0\n\nsynced").Return(nil)
+
+	monitor.handleDeploymentSuccess(&task, "synced")
+}
+
 func TestDeploymentMonitorHandleDeploymentFailureHandlesStateError(t *testing.T) {
 	ctrl := gomock.NewController(t)
 	defer ctrl.Finish()
@@ -597,6 +620,9 @@ func TestDeploymentMonitorHandleDeploymentFailureEnrichesReasonFromResourceTree(
 	podNode.Health.Status = "Degraded"
 	podNode.Health.Message = `Back-off pulling image "example.com/app:v2": ErrImagePull`
 	api.EXPECT().GetResourceTree(gomock.Any(), "demo").Return(&models.ApplicationTree{Nodes: []models.ApplicationTreeNode{podNode}}, nil)
+	// The pod's events and logs are best-effort and asserted in pod_diagnostics_test.go.
+	api.EXPECT().GetResourceEvents(gomock.Any(), gomock.Any(), gomock.Any()).Return(nil, nil).AnyTimes()
+	api.EXPECT().GetPodLogs(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).Return(nil, nil).AnyTimes()
 
 	monitor := NewDeploymentMonitor(Argo{
 		metrics: metrics,
@@ -772,6 +798,8 @@ func TestArgoStatusUpdaterDegradedReportsRolloutDiagnostics(t *testing.T) {
 	// bypasses: without it, flipping the instance-wide refresh default would fail this test
 	// with an opaque "unexpected call" instead of a behavioural assertion.
 	api.EXPECT().GetManagedResources(gomock.Any(), gomock.Any()).Return(nil, nil).AnyTimes()
+	api.EXPECT().GetResourceEvents(gomock.Any(), gomock.Any(), gomock.Any()).Return(nil, nil).AnyTimes()
+	api.EXPECT().GetPodLogs(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).Return(nil, nil).AnyTimes()
 
 	var capturedReason string
 	metrics.EXPECT().AddInProgressTask()
@@ -950,6 +978,76 @@ func TestDeploymentMonitorHandleArgoAPIFailureAbortCountsAsFailure(t *testing.T)
 	assert.Equal(t, models.StatusAborted, task.Status)
 }
 
+// TestDeploymentMonitorHandleArgoAPIFailureHostKey verifies that a write-back refused
+// for an untrusted SSH host key fails the task with a reason naming the host and its
+// fingerprint, rather than blaming the ArgoCD API.
+func TestDeploymentMonitorHandleArgoAPIFailureHostKey(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	metrics := mocks.NewMockMetricsInterface(ctrl)
+	state := newTaskRepositoryMock(ctrl)
+
+	monitor := NewDeploymentMonitor(Argo{
+		metrics: metrics,
+		State:   state,
+	}, "", []retry.Option{retry.DelayType(zeroDelay), retry.LastErrorOnly(true)}, false, time.Millisecond)
+
+	task := models.Task{Id: "task-id", App: "demo", Validated: true}
+	hostKeyErr := &updater.HostKeyError{Host: "git.example.com:22", Fingerprint: "SHA256:abc", Mismatch: true}
+
+	metrics.EXPECT().AddFailedDeployment(task.App)
+	state.EXPECT().SetTaskStatus(task.Id, models.StatusFailedMessage, hostKeyErr.Reason())
+
+	monitor.HandleArgoAPIFailure(&task, fmt.Errorf("git update: %w", hostKeyErr), true)
+	assert.Equal(t, models.StatusFailedMessage, task.Status)
+}
+
+func TestDeploymentMonitorRecordWriteBack(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	state := newTaskRepositoryMock(ctrl)
+	monitor := NewDeploymentMonitor(Argo{State: state}, "", nil, false, time.Millisecond)
+
+	// Nothing is stored for a task whose write-back noted no commit.
+	monitor.RecordWriteBack(models.Task{Id: "task-id"})
+
+	writeBack := models.WriteBack{SigningKey: "ssh:SHA256:abc"}
+	state.EXPECT().RecordWriteBack("task-id", writeBack).Return(errors.New("database unavailable"))
+	// A store error is logged; the deployment goes on.
+	monitor.RecordWriteBack(models.Task{Id: "task-id", WriteBack: &writeBack})
+}
+
+func TestDeploymentMonitorRecordSyncedRevision(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	state := newTaskRepositoryMock(ctrl)
+	monitor := NewDeploymentMonitor(Argo{State: state}, "", nil, false, time.Millisecond)
+
+	application := &models.Application{}
+	application.Status.OperationState.SyncResult.Revision = "3f786850e387550fdab836ed7e6dc881de23001b"
+
+	// A task that made no write-back commit has nothing to compare the revision with.
+	task := models.Task{Id: "task-id"}
+	monitor.RecordSyncedRevision(&task, application)
+	assert.Nil(t, task.WriteBack)
+
+	pushed := &models.WriteBack{CommitSha: "3f786850e387550fdab836ed7e6dc881de23001b"}
+	task.WriteBack = pushed
+	state.EXPECT().RecordWriteBack("task-id", models.WriteBack{
+		CommitSha:      "3f786850e387550fdab836ed7e6dc881de23001b",
+		SyncedRevision: "3f786850e387550fdab836ed7e6dc881de23001b",
+	}).Return(nil)
+	monitor.RecordSyncedRevision(&task, application)
+	assert.Equal(t, "3f786850e387550fdab836ed7e6dc881de23001b", task.WriteBack.SyncedRevision)
+	assert.Empty(t, pushed.SyncedRevision, "copies of the task sharing the write-back are left alone")
+
+	// Without a fetched application, as after a failed first poll, nothing is recorded.
+	monitor.RecordSyncedRevision(&task, nil)
+}
+
 func TestGitUpdaterUpdateIfNeeded(t *testing.T) {
 	makeApp := func(managed bool) *models.Application {
 		app := &models.Application{}
@@ -974,17 +1072,53 @@ func TestGitUpdaterUpdateIfNeeded(t *testing.T) {
 	t.Run("skipsWhenAppNotManaged", func(t *testing.T) {
 		locker := &spyLocker{}
 		updater := NewGitUpdater(locker, "/tmp/cache", nil, nil)
-		err := updater.UpdateIfNeeded(makeApp(false), validTask)
+		_, err := updater.UpdateIfNeeded(makeApp(false), &validTask)
 		assert.NoError(t, err)
 		assert.False(t, locker.called)
 	})
 
+	// Parameters only reach the application through the write-back, so a task carrying
+	// them fails rather than deploying its images without them.
+	t.Run("failsParametersThatCannotBeWrittenBack", func(t *testing.T) {
+		updater := NewGitUpdater(&spyLocker{}, "/tmp/cache", nil, nil)
+		task := validTask
+		task.Parameters = []models.Parameter{{Name: "features.checkout", Value: "true"}}
+
+		var skipErr *WriteBackSkippedError
+		_, err := updater.UpdateIfNeeded(makeApp(false), &task)
+		require.ErrorAs(t, err, &skipErr)
+		assert.Contains(t, skipErr.Reason(), "not managed by argo-watcher")
+
+		task.Validated = false
+		_, err = updater.UpdateIfNeeded(makeApp(true), &task)
+		require.ErrorAs(t, err, &skipErr)
+		assert.Contains(t, skipErr.Reason(), "no valid credential")
+	})
+
+	// A chart version nobody writes back is never synced, so the task fails at once
+	// instead of polling out its rollout window.
+	t.Run("failsChartVersionThatCannotBeWrittenBack", func(t *testing.T) {
+		updater := NewGitUpdater(&spyLocker{}, "/tmp/cache", nil, nil)
+		task := models.Task{Id: "task-id", App: "demo", ChartVersion: "1.4.0"}
+
+		var skipErr *WriteBackSkippedError
+		_, err := updater.UpdateIfNeeded(makeApp(true), &task)
+		require.ErrorAs(t, err, &skipErr)
+		assert.Equal(t, "chart version 1.4.0", skipErr.Change)
+		assert.Contains(t, skipErr.Reason(), "no valid credential")
+
+		task.Validated = true
+		_, err = updater.UpdateIfNeeded(makeApp(false), &task)
+		require.ErrorAs(t, err, &skipErr)
+		assert.Contains(t, skipErr.Reason(), "not managed by argo-watcher")
+	})
+
 	t.Run("skipsWhenTaskNotValidated", func(t *testing.T) {
 		locker := &spyLocker{}
 		updater := NewGitUpdater(locker, "/tmp/cache", nil, nil)
 		task := validTask
 		task.Validated = false
-		err := updater.UpdateIfNeeded(makeApp(true), task)
+		_, err := updater.UpdateIfNeeded(makeApp(true), &task)
 		assert.NoError(t, err)
 		assert.False(t, locker.called)
 	})
@@ -1006,7 +1140,7 @@ func TestGitUpdaterUpdateIfNeeded(t *testing.T) {
 		task := validTask
 		task.Validated = false
 
-		err := updater.UpdateIfNeeded(makeApp(true), task)
+		_, err := updater.UpdateIfNeeded(makeApp(true), &task)
 		assert.NoError(t, err)
 		assert.False(t, locker.called)
 
@@ -1033,7 +1167,7 @@ func TestGitUpdaterUpdateIfNeeded(t *testing.T) {
 		task := validTask
 		task.Validated = false
 
-		err := updater.UpdateIfNeeded(makeApp(false), task)
+		_, err := updater.UpdateIfNeeded(makeApp(false), &task)
 		assert.NoError(t, err)
 		assert.False(t, locker.called)
 
@@ -1048,7 +1182,7 @@ func TestGitUpdaterUpdateIfNeeded(t *testing.T) {
 		app := makeApp(true)
 		app.Spec.Source.RepoURL = ""
 
-		err := updater.UpdateIfNeeded(app, validTask)
+		_, err := updater.UpdateIfNeeded(app, &validTask)
 		assert.Error(t, err)
 		assert.False(t, locker.called)
 	})
@@ -1057,7 +1191,7 @@ func TestGitUpdaterUpdateIfNeeded(t *testing.T) {
 		locker := &spyLocker{err: errors.New("lock failed")}
 		updater := NewGitUpdater(locker, "/tmp/cache", nil, nil)
 
-		err := updater.UpdateIfNeeded(makeApp(true), validTask)
+		_, err := updater.UpdateIfNeeded(makeApp(true), &validTask)
 		assert.EqualError(t, err, "lock failed")
 		assert.True(t, locker.called)
 	})
@@ -1068,7 +1202,7 @@ func TestGitUpdaterUpdateIfNeeded(t *testing.T) {
 		app := makeApp(true)
 		app.Metadata.Annotations["argo-watcher/managed-images"] = "broken"
 
-		err := updater.UpdateIfNeeded(app, validTask)
+		_, err := updater.UpdateIfNeeded(app, &validTask)
 		assert.Error(t, err)
 		assert.True(t, locker.called)
 	})
@@ -1077,7 +1211,7 @@ func TestGitUpdaterUpdateIfNeeded(t *testing.T) {
 		locker := &spyLocker{}
 		updater := NewGitUpdater(locker, "/tmp/cache", nil, nil)
 
-		err := updater.UpdateIfNeeded(makeApp(true), validTask)
+		_, err := updater.UpdateIfNeeded(makeApp(true), &validTask)
 		assert.NoError(t, err)
 		assert.True(t, locker.called)
 	})
@@ -1088,11 +1222,12 @@ func TestGitUpdaterUpdateIfNeeded(t *testing.T) {
 		metrics := mocks.NewMockMetricsInterface(ctrl)
 		metrics.EXPECT().ObserveGitLockWaitDuration(validTask.App, gomock.Any()).Times(1)
 		metrics.EXPECT().ObserveGitWritebackDuration(validTask.App, gomock.Any()).Times(1)
+		metrics.EXPECT().AddRepoCacheLookup(false).Times(1)
 
 		locker := &spyLocker{}
 		updater := NewGitUpdater(locker, "/tmp/cache", metrics, nil)
 
-		err := updater.UpdateIfNeeded(makeApp(true), validTask)
+		_, err := updater.UpdateIfNeeded(makeApp(true), &validTask)
 		assert.NoError(t, err)
 		assert.True(t, locker.called)
 	})
@@ -1100,13 +1235,14 @@ func TestGitUpdaterUpdateIfNeeded(t *testing.T) {
 	t.Run("skipsMetricsWhenLockNeverAcquired", func(t *testing.T) {
 		ctrl := gomock.NewController(t)
 		defer ctrl.Finish()
-		// Lock acquisition fails before the closure runs, so neither duration is recorded.
+		// Lock acquisition fails before the closure runs, so neither duration nor the
+		// cache lookup is recorded.
 		metrics := mocks.NewMockMetricsInterface(ctrl)
 
 		locker := &spyLocker{err: errors.New("lock failed")}
 		updater := NewGitUpdater(locker, "/tmp/cache", metrics, nil)
 
-		err := updater.UpdateIfNeeded(makeApp(true), validTask)
+		_, err := updater.UpdateIfNeeded(makeApp(true), &validTask)
 		assert.EqualError(t, err, "lock failed")
 	})
 
@@ -1119,13 +1255,14 @@ func TestGitUpdaterUpdateIfNeeded(t *testing.T) {
 		metrics := mocks.NewMockMetricsInterface(ctrl)
 		metrics.EXPECT().ObserveGitLockWaitDuration(validTask.App, gomock.Any()).Times(1)
 		metrics.EXPECT().ObserveGitWritebackDuration(validTask.App, gomock.Any()).Times(1)
+		metrics.EXPECT().AddRepoCacheLookup(false).Times(1)
 
 		locker := &spyLocker{}
 		updater := NewGitUpdater(locker, "/tmp/cache", metrics, nil)
 		app := makeApp(true)
 		app.Metadata.Annotations["argo-watcher/managed-images"] = "broken"
 
-		err := updater.UpdateIfNeeded(app, validTask)
+		_, err := updater.UpdateIfNeeded(app, &validTask)
 		assert.Error(t, err)
 		assert.True(t, locker.called)
 	})
@@ -1146,7 +1283,7 @@ func TestGitUpdaterUpdateIfNeeded(t *testing.T) {
 		}
 
 		updater := NewGitUpdater(locker, "/tmp/cache", nil, batcher)
-		err := updater.UpdateIfNeeded(makeApp(true), validTask)
+		_, err := updater.UpdateIfNeeded(makeApp(true), &validTask)
 
 		assert.ErrorIs(t, err, wantErr, "batch outcome must propagate to the caller")
 		require.NotNil(t, captured, "request must reach the batcher")
@@ -1164,7 +1301,33 @@ func TestGitUpdaterUpdateIfNeeded(t *testing.T) {
 		}
 
 		updater := NewGitUpdater(locker, "/tmp/cache", nil, batcher)
-		assert.NoError(t, updater.UpdateIfNeeded(makeApp(true), validTask))
+		_, err := updater.UpdateIfNeeded(makeApp(true), &validTask)
+		assert.NoError(t, err)
+	})
+
+	t.Run("pullRequestMethodRequiresProvider", func(t *testing.T) {
+		locker := &spyLocker{}
+		batcher := NewBatcher(locker, "/tmp/cache", 20, nil)
+		updater := NewGitUpdater(locker, "/tmp/cache", nil, batcher)
+		app := makeApp(true)
+		app.Metadata.Annotations[writeBackMethodAnnotation] = writeBackMethodPullRequest
+
+		pr, err := updater.UpdateIfNeeded(app, &validTask)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "GIT_PR_PROVIDER")
+		assert.Nil(t, pr)
+		assert.False(t, locker.called)
+	})
+
+	t.Run("rejectsUnknownWriteBackMethod", func(t *testing.T) {
+		locker := &spyLocker{}
+		updater := NewGitUpdater(locker, "/tmp/cache", nil, nil)
+		app := makeApp(true)
+		app.Metadata.Annotations[writeBackMethodAnnotation] = "email"
+
+		_, err := updater.UpdateIfNeeded(app, &validTask)
+		assert.ErrorContains(t, err, writeBackMethodAnnotation)
+		assert.False(t, locker.called)
 	})
 }
 
@@ -1319,14 +1482,14 @@ func TestArgoStatusUpdaterWaitForApplicationDeploymentErrors(t *testing.T) {
 
 	t.Run("failsWhenFetchFails", func(t *testing.T) {
 		api.EXPECT().GetApplication(gomock.Any(), task.App, gomock.Any()).Return(nil, errors.New("network")).Times(1)
-		_, _, confirmed, err := updater.waitForApplicationDeployment(task, neverLost)
+		_, _, confirmed, err := updater.waitForApplicationDeployment(&task, neverLost)
 		assert.Error(t, err)
 		assert.False(t, confirmed)
 	})
 
 	t.Run("failsWhenApplicationNil", func(t *testing.T) {
 		api.EXPECT().GetApplication(gomock.Any(), task.App, gomock.Any()).Return(nil, nil).Times(1)
-		_, _, confirmed, err := updater.waitForApplicationDeployment(task, neverLost)
+		_, _, confirmed, err := updater.waitForApplicationDeployment(&task, neverLost)
 		assert.Error(t, err)
 		assert.True(t, confirmed)
 	})
@@ -1337,7 +1500,7 @@ func TestArgoStatusUpdaterWaitForApplicationDeploymentErrors(t *testing.T) {
 		app.Spec.Source.RepoURL = ""
 		api.EXPECT().GetApplication(gomock.Any(), task.App, gomock.Any()).Return(app, nil).Times(1)
 
-		_, _, confirmed, err := updater.waitForApplicationDeployment(task, neverLost)
+		_, _, confirmed, err := updater.waitForApplicationDeployment(&task, neverLost)
 		assert.Error(t, err)
 		assert.True(t, confirmed)
 	})
@@ -1809,6 +1972,15 @@ func TestCheckRolloutStatus(t *testing.T) {
 		assert.NoError(t, err)
 	})
 
+	// PIN_IMAGE_DIGESTS resolves a digest for every task, but only the write-back pins
+	// it: an application it does not manage keeps running the image by its tag.
+	t.Run("matchesAPinnedImageTheWriteBackDidNotPin", func(t *testing.T) {
+		pinned := task
+		pinned.Images = []models.Image{{Image: "example.com/app", Tag: "v1", Digest: "sha256:aaa"}}
+		err := checkRolloutStatus(pinned, app, app.GetRolloutStatus(pinned.ListImages(), "", false))
+		assert.NoError(t, err)
+	})
+
 	t.Run("returnsForceRetryOnPending", func(t *testing.T) {
 		pending := *app
 		pending.Status.Health.Status = "Progressing"
@@ -2500,7 +2672,7 @@ func TestWaitForApplicationDeploymentStopPredicates(t *testing.T) {
 				Timeout: 15,
 				Images:  []models.Image{{Image: "demo", Tag: "v1"}},
 			}
-			_, _, _, err := updater.waitForApplicationDeployment(task, func() bool { return tt.leaseLost })
+			_, _, _, err := updater.waitForApplicationDeployment(&task, func() bool { return tt.leaseLost })
 
 			assert.ErrorIs(t, err, tt.wantErr)
 		})
@@ -420,9 +420,6 @@ func TestArgoAddTask(t *testing.T) {
 }
 
 func TestArgoDetectRollback(t *testing.T) {
-	ctrl := gomock.NewController(t)
-	defer ctrl.Finish()
-
 	img := func(image, tag string) []models.Image {
 		return []models.Image{{Image: image, Tag: tag}}
 	}
@@ -492,6 +489,24 @@ func TestArgoDetectRollback(t *testing.T) {
 			target:       []models.Image{{Image: "b", Tag: "2"}, {Image: "a", Tag: "1"}},
 			wantTargetID: "t1",
 		},
+		{
+			name: "an unpinned task rolls back to the same tags deployed pinned",
+			history: []deployedTask{
+				{"t1", []models.Image{{Image: "app", Tag: "v1", Digest: "sha256:bbb"}}},
+				{"t2", img("app", "v2")},
+			},
+			target:       img("app", "v1"),
+			wantTargetID: "t1",
+		},
+		{
+			name: "a pinned task rolls back to the same tags deployed unpinned",
+			history: []deployedTask{
+				{"t1", img("app", "v1")},
+				{"t2", img("app", "v2")},
+			},
+			target:       []models.Image{{Image: "app", Tag: "v1", Digest: "sha256:aaa"}},
+			wantTargetID: "t1",
+		},
 	}
 
 	for _, tt := range tests {
@@ -508,13 +523,7 @@ func TestArgoDetectRollback(t *testing.T) {
 				})
 			}
 
-			state := newTaskRepositoryMock(ctrl)
-			state.EXPECT().
-				GetTasks(gomock.Any(), gomock.Any(), "test-app", models.StatusDeployedMessage, rollbackHistoryWindow, 0).
-				Return(deployed, int64(len(deployed)))
-
-			argo := &Argo{State: state}
-			result := argo.detectRollback(models.Task{App: "test-app", Images: tt.target})
+			result := detectRollback(models.Task{App: "test-app", Images: tt.target}, deployed)
 
 			assert.Equal(t, tt.wantTargetID, result)
 		})
@@ -152,7 +152,7 @@ func (b *Batcher) flush(batch []*batchWriteRequest) {
 	// context.Background() mirrors the single-app path (updateGitRepo); git
 	// operations are bounded by GIT_OP_TIMEOUT per attempt rather than by a
 	// caller context.
-	lockErr := b.locker.WithLock(repoURL, func() error {
+	lockErr := withCachedRepo(b.locker, b.metrics, b.repoCachePath, repoURL, branch, func() error {
 		outcomes = runBatchWriteBack(context.Background(), repo, batch, b.drainCh)
 		return nil
 	})
@@ -0,0 +1,84 @@
+package argocd
+
+import (
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/shini4i/argo-watcher/internal/models"
+)
+
+// ChartNotSyncedError fails a chart version task when ArgoCD had not synced the new
+// version of the chart by the end of the rollout window. The application may well be
+// Synced and Healthy by then, on the version it ran before.
+type ChartNotSyncedError struct {
+	App     string
+	Version string
+	// Revision is the chart version ArgoCD compares the application against, empty when
+	// it reported none.
+	Revision string
+	Waited   time.Duration
+}
+
+func (err *ChartNotSyncedError) Error() string {
+	return fmt.Sprintf("application %q did not sync chart version %s", err.App, err.Version)
+}
+
+// Reason renders the user-facing task failure reason.
+func (err *ChartNotSyncedError) Reason() string {
+	synced := "has not reported a chart version"
+	if err.Revision != "" {
+		synced = fmt.Sprintf("is at chart version %s", err.Revision)
+	}
+	return fmt.Sprintf("Application deployment failed. ArgoCD did not sync chart version %s within %s: the application %s.",
+		err.Version, err.Waited.Round(time.Second), synced)
+}
+
+// chartTracker follows, across polls, whether ArgoCD synced the chart version of a task.
+type chartTracker struct {
+	// pending is set when the latest poll found the application Synced and Healthy, but
+	// not at the task's version yet. The poll loop clears it before every check.
+	pending bool
+	// reported is set once the wait for the version was written to the task.
+	reported bool
+}
+
+// checkChartSynced holds back the success of a chart version task until ArgoCD compares
+// the application's chart source against the task's version. Until ArgoCD applied the
+// manifest the write-back changed, the application reads as Synced and Healthy on the
+// version it already ran.
+//
+// It returns errForceRetry while the version is pending, and reports the wait in the
+// task's status reason. An application without a single chart source never reports the
+// version; its write-back already failed on that, and a skipped write-back failed the
+// task before the rollout began.
+func (monitor *DeploymentMonitor) checkChartSynced(task models.Task, app *models.Application, tracker *chartTracker) error {
+	revision, err := app.ChartRevision()
+	if err != nil {
+		slog.Debug("Could not tell the chart version of the application", "error", err, "id", task.Id)
+	} else if revision == task.ChartVersion {
+		return nil
+	}
+
+	tracker.pending = true
+	if !tracker.reported {
+		progress := fmt.Sprintf("Waiting for ArgoCD to sync chart version %s.", task.ChartVersion)
+		if err := monitor.argo.State.SetTaskProgress(task.Id, progress); err != nil {
+			slog.Warn("Failed to record the wait for the chart version", "error", err, "id", task.Id)
+		} else {
+			tracker.reported = true
+		}
+	}
+	return errForceRetry
+}
+
+// HandleChartNotSynced fails a task whose chart version ArgoCD did not sync in time.
+func (monitor *DeploymentMonitor) HandleChartNotSynced(task *models.Task, chartErr *ChartNotSyncedError) {
+	slog.Warn("App deployment failed: ArgoCD did not sync the chart version.", "version", chartErr.Version, "revision", chartErr.Revision, "app", chartErr.App, "id", task.Id)
+	monitor.argo.metrics.AddFailedDeployment(task.App)
+
+	if err := monitor.argo.State.SetTaskStatus(task.Id, models.StatusFailedMessage, chartErr.Reason()); err != nil {
+		slog.Error("Failed to change task status", "error", err, "id", task.Id)
+	}
+	task.Status = models.StatusFailedMessage
+}
@@ -0,0 +1,96 @@
+package argocd
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
+
+	"github.com/shini4i/argo-watcher/internal/mocks"
+	"github.com/shini4i/argo-watcher/internal/models"
+)
+
+var chartTask = models.Task{
+	Id:           "task-id",
+	App:          "demo",
+	ChartVersion: "1.4.0",
+	WriteBack:    &models.WriteBack{CommitSha: writeBackCommit},
+}
+
+// chartApp is a healthy application deploying its chart at version.
+func chartApp(version string) *models.Application {
+	app := canaryApp("Healthy", nil)
+	app.Spec.Source.Chart = "web"
+	app.Status.Sync.Revision = version
+	app.Status.OperationState.SyncResult.Revision = version
+	return app
+}
+
+func TestWaitRolloutWaitsForChartVersion(t *testing.T) {
+	t.Run("Succeeds once ArgoCD synced the version", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		api := mocks.NewMockArgoApiInterface(ctrl)
+		api.EXPECT().GetApplication(gomock.Any(), chartTask.App, false).
+			DoAndReturn(appSequence(chartApp("1.3.0"), chartApp("1.3.0"), chartApp("1.4.0"))).Times(3)
+
+		// The version is the revision ArgoCD reports; no commit is looked up for it.
+		history := &fakeCommitHistory{}
+		monitor, state := newCommitSyncMonitor(ctrl, api, history)
+		state.EXPECT().SetTaskProgress(chartTask.Id, "Waiting for ArgoCD to sync chart version 1.4.0.").Times(1)
+		monitor.defaultAttempts = 5
+
+		_, _, err := monitor.WaitRollout(chartTask, neverLost)
+		require.NoError(t, err)
+		assert.Zero(t, history.lookups)
+	})
+
+	t.Run("Fails when the version is not synced in time", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		api := mocks.NewMockArgoApiInterface(ctrl)
+		api.EXPECT().GetApplication(gomock.Any(), chartTask.App, false).Return(chartApp("1.3.0"), nil).Times(2)
+
+		monitor, state := newCommitSyncMonitor(ctrl, api, &fakeCommitHistory{})
+		state.EXPECT().SetTaskProgress(chartTask.Id, gomock.Any()).Times(1)
+		monitor.defaultAttempts = 2
+
+		_, _, err := monitor.WaitRollout(chartTask, neverLost)
+		var chartErr *ChartNotSyncedError
+		require.ErrorAs(t, err, &chartErr)
+		assert.Equal(t, "1.4.0", chartErr.Version)
+		assert.Equal(t, "1.3.0", chartErr.Revision)
+		assert.Contains(t, chartErr.Reason(), "did not sync chart version 1.4.0")
+		assert.Contains(t, chartErr.Reason(), "is at chart version 1.3.0")
+	})
+
+	t.Run("An application without a chart source never reports the version", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		api := mocks.NewMockArgoApiInterface(ctrl)
+		api.EXPECT().GetApplication(gomock.Any(), chartTask.App, false).Return(canaryApp("Healthy", nil), nil).Times(2)
+
+		monitor, state := newCommitSyncMonitor(ctrl, api, &fakeCommitHistory{})
+		state.EXPECT().SetTaskProgress(chartTask.Id, gomock.Any()).Times(1)
+		monitor.defaultAttempts = 2
+
+		_, _, err := monitor.WaitRollout(chartTask, neverLost)
+		var chartErr *ChartNotSyncedError
+		require.ErrorAs(t, err, &chartErr)
+		assert.Contains(t, chartErr.Reason(), "has not reported a chart version")
+	})
+}
+
+func TestHandleChartNotSynced(t *testing.T) {
+	argo := newGroupArgo(nil)
+	task, err := argo.State.AddTask(models.Task{App: "demo", ChartVersion: "1.4.0"})
+	require.NoError(t, err)
+	monitor := NewDeploymentMonitor(*argo, "", nil, false, 0)
+
+	chartErr := &ChartNotSyncedError{App: "demo", Version: "1.4.0", Revision: "1.3.0"}
+	monitor.HandleChartNotSynced(task, chartErr)
+
+	stored, err := argo.State.GetTask(task.Id)
+	require.NoError(t, err)
+	assert.Equal(t, models.StatusFailedMessage, stored.Status)
+	assert.Contains(t, stored.StatusReason, "is at chart version 1.3.0")
+	assert.Equal(t, models.StatusFailedMessage, task.Status)
+}
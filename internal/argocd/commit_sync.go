@@ -0,0 +1,147 @@
+package argocd
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+
+	"github.com/shini4i/argo-watcher/internal/models"
+	"github.com/shini4i/argo-watcher/internal/updater"
+)
+
+// CommitNotSyncedError fails a deployment that wrote back when ArgoCD had not synced its
+// commit by the end of the rollout window. The application may well run the expected
+// images by then — an unrelated sync can bring up the same tag — but without the commit
+// it is not running the desired state the task wrote.
+type CommitNotSyncedError struct {
+	App    string
+	Commit string
+	// Revision is the revision ArgoCD's last sync applied, empty when it reported none.
+	Revision string
+	Waited   time.Duration
+}
+
+func (err *CommitNotSyncedError) Error() string {
+	return fmt.Sprintf("application %q did not sync commit %s", err.App, err.Commit)
+}
+
+// Reason renders the user-facing task failure reason.
+func (err *CommitNotSyncedError) Reason() string {
+	synced := "has not reported a synced revision"
+	if err.Revision != "" {
+		synced = fmt.Sprintf("last synced revision %s", err.Revision)
+	}
+	return fmt.Sprintf("Application deployment failed. ArgoCD did not pick up commit %s of the write-back within %s: the application %s.",
+		err.Commit, err.Waited.Round(time.Second), synced)
+}
+
+// commitHistory tells whether a revision ArgoCD synced includes a write-back commit.
+type commitHistory interface {
+	ContainsCommit(ctx context.Context, app *models.Application, revision, commit string) (bool, error)
+}
+
+// commitTracker follows, across polls, whether ArgoCD synced the commit of a write-back.
+type commitTracker struct {
+	// included holds the verdict on every synced revision looked up so far: a revision
+	// ArgoCD keeps reporting is looked up once.
+	included map[string]bool
+	// pending is set when the latest poll found the rollout otherwise done, but the
+	// commit not synced yet. The poll loop clears it before every check.
+	pending bool
+	// reported is set once the wait for the commit was written to the task.
+	reported bool
+}
+
+// checkCommitSynced holds back the success of a rollout that wrote back until ArgoCD's
+// last sync applied the commit of the write-back, or a later commit of the branch that
+// includes it. Images alone can read as rolled out after an unrelated sync that happens
+// to run the same tag.
+//
+// It returns errForceRetry while the commit is pending, and reports the wait in the
+// task's status reason. An application annotated argo-watcher/wait-for-commit=false, or
+// a task that made no commit, is judged on its images alone.
+func (monitor *DeploymentMonitor) checkCommitSynced(ctx context.Context, task models.Task, app *models.Application, tracker *commitTracker) error {
+	if task.WriteBack == nil || task.WriteBack.CommitSha == "" || !app.IsCommitSyncAwaited() {
+		return nil
+	}
+
+	for _, revision := range app.SyncedCommits() {
+		if monitor.revisionIncludes(ctx, task, app, revision, tracker) {
+			return nil
+		}
+	}
+
+	tracker.pending = true
+	if !tracker.reported {
+		progress := fmt.Sprintf("Waiting for ArgoCD to pick up commit %s.", task.WriteBack.ShortCommit())
+		if err := monitor.argo.State.SetTaskProgress(task.Id, progress); err != nil {
+			slog.Warn("Failed to record the wait for the write-back commit", "error", err, "id", task.Id)
+		} else {
+			tracker.reported = true
+		}
+	}
+	return errForceRetry
+}
+
+// revisionIncludes reports whether revision is the write-back commit of task or a
+// descendant of it. A lookup that failed is not remembered, so the next poll repeats it.
+func (monitor *DeploymentMonitor) revisionIncludes(ctx context.Context, task models.Task, app *models.Application, revision string, tracker *commitTracker) bool {
+	commit := task.WriteBack.CommitSha
+	if strings.EqualFold(revision, commit) {
+		return true
+	}
+	if included, ok := tracker.included[revision]; ok {
+		return included
+	}
+	if monitor.history == nil {
+		return false
+	}
+
+	included, err := monitor.history.ContainsCommit(ctx, app, revision, commit)
+	if err != nil {
+		slog.Warn("Could not tell whether the synced revision includes the write-back commit", "revision", revision, "commit", commit, "error", err, "id", task.Id)
+		return false
+	}
+	if tracker.included == nil {
+		tracker.included = make(map[string]bool)
+	}
+	tracker.included[revision] = included
+	return included
+}
+
+// HandleCommitNotSynced fails a task whose write-back commit ArgoCD did not sync in time.
+func (monitor *DeploymentMonitor) HandleCommitNotSynced(task *models.Task, commitErr *CommitNotSyncedError) {
+	slog.Warn("App deployment failed: ArgoCD did not sync the write-back commit.", "commit", commitErr.Commit, "revision", commitErr.Revision, "app", commitErr.App, "id", task.Id)
+	monitor.argo.metrics.AddFailedDeployment(task.App)
+
+	if err := monitor.argo.State.SetTaskStatus(task.Id, models.StatusFailedMessage, commitErr.Reason()); err != nil {
+		slog.Error("Failed to change task status", "error", err, "id", task.Id)
+	}
+	task.Status = models.StatusFailedMessage
+}
+
+// ContainsCommit reports whether revision, a commit of the GitOps branch of app, is
+// commit or a descendant of it. It reads the branch's history without the per-repo
+// lock or the cache, which only a write-back needs.
+func (gitUpdater *GitUpdater) ContainsCommit(ctx context.Context, app *models.Application, revision, commit string) (bool, error) {
+	return gitUpdater.containsCommit(ctx, app, revision, commit, updater.GitClient{})
+}
+
+// containsCommit implements ContainsCommit. gitHandler is injected to enable testing.
+func (gitUpdater *GitUpdater) containsCommit(ctx context.Context, app *models.Application, revision, commit string, gitHandler updater.GitHandler) (bool, error) {
+	gitopsRepo, err := models.NewGitopsRepo(app, gitUpdater.repoCachePath)
+	if err != nil {
+		return false, err
+	}
+
+	repo, err := updater.NewGitRepo(gitopsRepo.RepoUrl, gitopsRepo.BranchName, gitopsRepo.Path, gitopsRepo.Filename, gitopsRepo.RepoCachePath, gitHandler)
+	if err != nil {
+		return false, err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, repo.GitOpTimeout())
+	defer cancel()
+	return repo.ContainsCommit(ctx, revision, commit)
+}
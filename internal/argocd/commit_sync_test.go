@@ -0,0 +1,149 @@
+package argocd
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
+
+	"github.com/shini4i/argo-watcher/internal/mocks"
+	"github.com/shini4i/argo-watcher/internal/models"
+)
+
+const (
+	writeBackCommit = "3f786850e387550fdab836ed7e6dc881de23001b"
+	earlierCommit   = "1b2c3d4e5f60718293a4b5c6d7e8f90112233445"
+	laterCommit     = "9a8b7c6d5e4f30211203f4e5d6c7b8a990817263"
+)
+
+// fakeCommitHistory answers ContainsCommit from the revisions it is told include the
+// write-back commit, and counts the lookups.
+type fakeCommitHistory struct {
+	including map[string]bool
+	err       error
+	lookups   int
+}
+
+func (history *fakeCommitHistory) ContainsCommit(_ context.Context, _ *models.Application, revision, _ string) (bool, error) {
+	history.lookups++
+	if history.err != nil {
+		return false, history.err
+	}
+	return history.including[revision], nil
+}
+
+// syncedApp is a healthy application running the task's image whose last sync applied
+// revision.
+func syncedApp(revision string, annotations map[string]string) *models.Application {
+	app := canaryApp("Healthy", annotations)
+	app.Status.Summary.Images = []string{"ghcr.io/shini4i/app:v2"}
+	app.Status.OperationState.SyncResult.Revision = revision
+	return app
+}
+
+func newCommitSyncMonitor(ctrl *gomock.Controller, api ArgoApiInterface, history commitHistory) (*DeploymentMonitor, *mocks.MockTaskRepository) {
+	state := notSupersededState(ctrl)
+	monitor := newRolloutMonitor(api, state)
+	monitor.history = history
+	return monitor, state
+}
+
+func TestWaitRolloutWaitsForWriteBackCommit(t *testing.T) {
+	task := rolloutTask
+	task.WriteBack = &models.WriteBack{CommitSha: writeBackCommit}
+
+	t.Run("Succeeds once ArgoCD synced the commit", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		api := mocks.NewMockArgoApiInterface(ctrl)
+		api.EXPECT().GetApplication(gomock.Any(), task.App, false).
+			DoAndReturn(appSequence(syncedApp(earlierCommit, nil), syncedApp(earlierCommit, nil), syncedApp(writeBackCommit, nil))).Times(3)
+
+		history := &fakeCommitHistory{}
+		monitor, state := newCommitSyncMonitor(ctrl, api, history)
+		state.EXPECT().SetTaskProgress(task.Id, "Waiting for ArgoCD to pick up commit 3f78685.").Times(1)
+		monitor.defaultAttempts = 5
+
+		_, _, err := monitor.WaitRollout(task, neverLost)
+		require.NoError(t, err)
+		assert.Equal(t, 1, history.lookups, "a revision is looked up once")
+	})
+
+	t.Run("A later commit of the branch includes it", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		api := mocks.NewMockArgoApiInterface(ctrl)
+		api.EXPECT().GetApplication(gomock.Any(), task.App, false).Return(syncedApp(laterCommit, nil), nil).Times(1)
+
+		history := &fakeCommitHistory{including: map[string]bool{laterCommit: true}}
+		monitor, _ := newCommitSyncMonitor(ctrl, api, history)
+		monitor.defaultAttempts = 5
+
+		_, _, err := monitor.WaitRollout(task, neverLost)
+		require.NoError(t, err)
+	})
+
+	t.Run("Fails when the commit is not synced in time", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		api := mocks.NewMockArgoApiInterface(ctrl)
+		api.EXPECT().GetApplication(gomock.Any(), task.App, false).Return(syncedApp(earlierCommit, nil), nil).Times(2)
+
+		// A failed lookup is repeated on the next poll.
+		history := &fakeCommitHistory{err: errors.New("repository unavailable")}
+		monitor, state := newCommitSyncMonitor(ctrl, api, history)
+		state.EXPECT().SetTaskProgress(task.Id, gomock.Any()).Times(1)
+		monitor.defaultAttempts = 2
+
+		_, _, err := monitor.WaitRollout(task, neverLost)
+		var commitErr *CommitNotSyncedError
+		require.ErrorAs(t, err, &commitErr)
+		assert.Equal(t, "3f78685", commitErr.Commit)
+		assert.Equal(t, earlierCommit, commitErr.Revision)
+		assert.Contains(t, commitErr.Reason(), "did not pick up commit 3f78685")
+		assert.Equal(t, 2, history.lookups)
+	})
+
+	t.Run("The annotation opts out", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		api := mocks.NewMockArgoApiInterface(ctrl)
+		api.EXPECT().GetApplication(gomock.Any(), task.App, false).
+			Return(syncedApp(earlierCommit, map[string]string{"argo-watcher/wait-for-commit": "false"}), nil).Times(1)
+
+		history := &fakeCommitHistory{}
+		monitor, _ := newCommitSyncMonitor(ctrl, api, history)
+		monitor.defaultAttempts = 2
+
+		_, _, err := monitor.WaitRollout(task, neverLost)
+		require.NoError(t, err)
+		assert.Zero(t, history.lookups)
+	})
+
+	t.Run("A task without a commit is judged on its images", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		api := mocks.NewMockArgoApiInterface(ctrl)
+		api.EXPECT().GetApplication(gomock.Any(), task.App, false).Return(syncedApp(earlierCommit, nil), nil).Times(1)
+
+		monitor, _ := newCommitSyncMonitor(ctrl, api, &fakeCommitHistory{})
+		monitor.defaultAttempts = 2
+
+		_, _, err := monitor.WaitRollout(rolloutTask, neverLost)
+		require.NoError(t, err)
+	})
+}
+
+func TestHandleCommitNotSynced(t *testing.T) {
+	argo := newGroupArgo(nil)
+	task, err := argo.State.AddTask(models.Task{App: "demo", Images: []models.Image{{Image: "app", Tag: "v2"}}})
+	require.NoError(t, err)
+	monitor := NewDeploymentMonitor(*argo, "", nil, false, 0)
+
+	commitErr := &CommitNotSyncedError{App: "demo", Commit: "3f78685"}
+	monitor.HandleCommitNotSynced(task, commitErr)
+
+	stored, err := argo.State.GetTask(task.Id)
+	require.NoError(t, err)
+	assert.Equal(t, models.StatusFailedMessage, stored.Status)
+	assert.Contains(t, stored.StatusReason, "has not reported a synced revision")
+	assert.Equal(t, models.StatusFailedMessage, task.Status)
+}
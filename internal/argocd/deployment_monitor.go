@@ -12,8 +12,10 @@ import (
 
 	"github.com/avast/retry-go/v4"
 
+	"github.com/shini4i/argo-watcher/internal/analysis"
 	"github.com/shini4i/argo-watcher/internal/helpers"
 	"github.com/shini4i/argo-watcher/internal/models"
+	"github.com/shini4i/argo-watcher/internal/updater"
 )
 
 const (
@@ -88,6 +90,21 @@ type DeploymentMonitor struct {
 	// refreshApp is the instance-wide default for requesting an ArgoCD refresh during status checks.
 	// A per-task Refresh override takes precedence (see resolveRefresh).
 	refreshApp bool
+	// syncApp is the instance-wide default for triggering the sync of an application without
+	// auto-sync. The argo-watcher/sync annotation takes precedence (see IsSyncRequested).
+	syncApp bool
+	// rolloutMaxDuration bounds a rollout whose deadline moves while its Argo Rollouts keep
+	// advancing. Zero, or anything up to the rollout window, keeps the deadline fixed (see
+	// rolloutClock).
+	rolloutMaxDuration time.Duration
+	// analysis runs the post-deployment analysis of a rollout that succeeded; nil runs none.
+	analysis *analysis.Gate
+	// failureLogLines is how many log lines of each unhealthy pod a failure reason quotes;
+	// zero quotes none (see collectPodDiagnostics).
+	failureLogLines uint
+	// history tells whether a synced revision includes the commit of a write-back (see
+	// checkCommitSynced). Nil compares the synced revision with the commit alone.
+	history commitHistory
 }
 
 // NewDeploymentMonitor creates a deployment monitor with the supplied configuration.
@@ -216,9 +233,12 @@ func (monitor *DeploymentMonitor) WaitRollout(task models.Task, abandoned func()
 	refresh := monitor.resolveRefresh(task)
 	retryOptions, deadline := monitor.configureRetryOptions(task)
 
-	ctx, cancel := context.WithTimeout(context.Background(), deadline)
-	defer cancel()
+	ctx, clock, stop := monitor.startRolloutClock(deadline)
+	defer stop()
 	retryOptions = append(retryOptions, retry.Context(ctx))
+	if clock.extendable() {
+		retryOptions = append(retryOptions, retry.Attempts(monitor.extendedAttempts(monitor.resolvedDelay())))
+	}
 
 	slog.Debug("Waiting for rollout", "id", task.Id, "deadline", deadline)
 
@@ -228,6 +248,25 @@ func (monitor *DeploymentMonitor) WaitRollout(task models.Task, abandoned func()
 	// which may predate the commit that introduces the image.
 	imagesValidated := false
 
+	// An application without auto-sync never applies the new desired state by itself, so when
+	// asked to, the loop triggers that sync and follows it (see advanceSync).
+	var sync syncTracker
+
+	// Argo Rollouts report their step and traffic weight only in their own status, which
+	// is followed on every poll that finds the application still coming up.
+	var rollouts rolloutTracker
+
+	// An app of apps is judged on its children, whose last assessment outlives the poll
+	// that made it (see checkChildren).
+	var children appOfAppsTracker
+
+	// A rollout that wrote back succeeds only once ArgoCD synced its commit (see
+	// checkCommitSynced), and a chart version task once ArgoCD synced its version (see
+	// checkChartSynced). The commit of the latter went to the manifest, not to a source
+	// of the application, so it is never looked for.
+	var commit commitTracker
+	var chart chartTracker
+
 	err := retry.Do(func() error {
 		// Stop before hitting ArgoCD if a newer deployment superseded this task.
 		// The check is per-iteration: a cancellation that lands mid-iteration is
@@ -251,11 +290,21 @@ func (monitor *DeploymentMonitor) WaitRollout(task models.Task, abandoned func()
 		}
 		application = app
 
+		if app.IsSyncRequested(monitor.syncApp) {
+			if syncErr := monitor.advanceSync(ctx, task, app, &sync); syncErr != nil {
+				return syncErr
+			}
+		}
+
 		if app.IsFireAndForgetModeActive() {
 			slog.Debug("Fire and forget mode is active, skipping checks...", "id", task.Id)
 			return nil
 		}
 
+		if app.IsAppOfApps() {
+			return monitor.checkChildren(ctx, task, app, refresh, &children)
+		}
+
 		status := app.GetRolloutStatus(task.ListImages(), monitor.registryProxyUrl, monitor.acceptSuspended)
 
 		if !imagesValidated && refresh && shouldValidateDesiredImages(app, status) {
@@ -265,8 +314,57 @@ func (monitor *DeploymentMonitor) WaitRollout(task models.Task, abandoned func()
 			}
 		}
 
-		return checkRolloutStatus(task, app, status)
+		rolloutErr := checkRolloutStatus(task, app, status)
+		if errors.Is(rolloutErr, errForceRetry) && status == models.ArgoRolloutAppNotHealthy {
+			if observeErr := monitor.observeRollouts(ctx, task, app, &rollouts, clock); observeErr != nil {
+				return observeErr
+			}
+		}
+		// Only the latest poll tells whether the loop ended waiting for the commit or the
+		// chart version.
+		commit.pending = false
+		chart.pending = false
+		switch {
+		case rolloutErr != nil:
+			// Not rolled out yet, or failed.
+		case task.ChartVersion != "":
+			rolloutErr = monitor.checkChartSynced(task, app, &chart)
+		default:
+			rolloutErr = monitor.checkCommitSynced(ctx, task, app, &commit)
+		}
+
+		return rolloutErr
 	}, retryOptions...)
+	err = deadlineErr(ctx, err)
+	waited := time.Since(start)
+
+	// The images alone would read as a success here, so a rollout that ended still waiting
+	// for its commit is reported as such.
+	if application != nil && commit.pending && rolloutStateAlreadyObserved(err) {
+		return application, waited, &CommitNotSyncedError{
+			App:      task.App,
+			Commit:   task.WriteBack.ShortCommit(),
+			Revision: application.SyncedRevision(),
+			Waited:   waited,
+		}
+	}
+
+	// Likewise for a chart version task still waiting for its version.
+	if application != nil && chart.pending && rolloutStateAlreadyObserved(err) {
+		revision, _ := application.ChartRevision()
+		return application, waited, &ChartNotSyncedError{
+			App:      task.App,
+			Version:  task.ChartVersion,
+			Revision: revision,
+			Waited:   waited,
+		}
+	}
+
+	// An app of apps that did not finish is reported on its children: its own status, which
+	// the caller would judge, lists none of the task's images.
+	if application != nil && application.IsAppOfApps() && !application.IsFireAndForgetModeActive() && rolloutStateAlreadyObserved(err) {
+		return application, waited, &ChildRolloutError{Parent: application, Rollout: children.rollout, Waited: waited}
+	}
 
 	// A nil application means no fetch ever succeeded, so the error is returned as-is for
 	// the caller to classify (e.g. connection refused -> aborted).
@@ -274,7 +372,7 @@ func (monitor *DeploymentMonitor) WaitRollout(task models.Task, abandoned func()
 		err = nil
 	}
 
-	return application, time.Since(start), err
+	return application, waited, err
 }
 
 // rolloutStateAlreadyObserved reports whether err means the poll loop ended with the application's
@@ -353,18 +451,25 @@ func (monitor *DeploymentMonitor) rolloutWindow(task models.Task) time.Duration
 // status and metrics. waited is how long the rollout was polled, reported in the failure message so
 // the user can tell a rollout that ran out its window from one that failed immediately.
 func (monitor *DeploymentMonitor) ProcessDeploymentResult(task *models.Task, application *models.Application, waited time.Duration) {
-	status := application.GetRolloutStatus(task.ListImages(), monitor.registryProxyUrl, monitor.acceptSuspended)
-	if application.IsFireAndForgetModeActive() {
-		status = models.ArgoRolloutAppSuccess
-	}
+	status := monitor.rolloutStatus(*task, application)
 
 	if status == models.ArgoRolloutAppSuccess {
-		monitor.handleDeploymentSuccess(task)
+		monitor.handleDeploymentSuccess(task, monitor.successReason(application))
 	} else {
 		monitor.handleDeploymentFailure(task, status, application, waited)
 	}
 }
 
+// rolloutStatus is the rollout status WaitRollout ended on, as ProcessDeploymentResult
+// judges it.
+func (monitor *DeploymentMonitor) rolloutStatus(task models.Task, application *models.Application) string {
+	// WaitRollout returns an app of apps without an error only once its children rolled out.
+	if application.IsFireAndForgetModeActive() || application.IsAppOfApps() {
+		return models.ArgoRolloutAppSuccess
+	}
+	return application.GetRolloutStatus(task.ListImages(), monitor.registryProxyUrl, monitor.acceptSuspended)
+}
+
 // taskSuperseded reports whether the task has been marked cancelled in the shared
 // state, i.e. a newer deployment for the same app has superseded it. A read error
 // is treated as "not superseded" so a transient state hiccup does not abort an
@@ -378,6 +483,36 @@ func (monitor *DeploymentMonitor) taskSuperseded(id string) bool {
 	return current.Status == models.StatusCancelledMessage
 }
 
+// RecordWriteBack stores what the task's write-back noted about its commit. A store
+// error is logged rather than returned: the commit has landed by then, and failing
+// the deployment would not take it back.
+func (monitor *DeploymentMonitor) RecordWriteBack(task models.Task) {
+	if task.WriteBack == nil {
+		return
+	}
+	if err := monitor.argo.State.RecordWriteBack(task.Id, *task.WriteBack); err != nil {
+		slog.Error("Failed to record the write-back commit", "error", err, "id", task.Id)
+	}
+}
+
+// RecordSyncedRevision notes on a task that wrote back the revision ArgoCD's last
+// sync of application applied, and stores it, so the task shows whether ArgoCD
+// deployed its commit. A task that made no write-back commit is left as it is.
+func (monitor *DeploymentMonitor) RecordSyncedRevision(task *models.Task, application *models.Application) {
+	if task.WriteBack == nil || application == nil {
+		return
+	}
+	revision := application.SyncedRevision()
+	if revision == "" {
+		return
+	}
+	// Copied before it is changed: the task may share its WriteBack with copies of it.
+	writeBack := *task.WriteBack
+	writeBack.SyncedRevision = revision
+	task.WriteBack = &writeBack
+	monitor.RecordWriteBack(*task)
+}
+
 // HandleArgoAPIFailure processes API errors and updates task status accordingly.
 // task is taken by pointer so the resolved terminal status is reflected back to
 // the caller, keeping the outgoing failure notification in sync with the stored
@@ -395,6 +530,12 @@ func (monitor *DeploymentMonitor) HandleArgoAPIFailure(task *models.Task, err er
 	}
 	finalStatus := determineFailureStatus(*task, err)
 	reason := fmt.Sprintf(ArgoAPIErrorTemplate, err.Error())
+	// A write-back refused for an untrusted host key is no ArgoCD failure, and its
+	// own reason names the host and fingerprint an operator has to check.
+	var hostKeyErr *updater.HostKeyError
+	if errors.As(err, &hostKeyErr) {
+		reason = hostKeyErr.Reason()
+	}
 	slog.Warn("Deployment not completed", "status", finalStatus, "reason", reason, "id", task.Id)
 
 	if err := monitor.argo.State.SetTaskStatus(task.Id, finalStatus, reason); err != nil {
@@ -416,10 +557,36 @@ func (monitor *DeploymentMonitor) HandleImageNotPartOfApp(task *models.Task, ima
 	task.Status = models.StatusFailedMessage
 }
 
-func (monitor *DeploymentMonitor) handleDeploymentSuccess(task *models.Task) {
+// HandleSyncFailure fails the task with the outcome of the sync argo-watcher triggered, which
+// the rollout cannot succeed without.
+func (monitor *DeploymentMonitor) HandleSyncFailure(task *models.Task, syncErr *SyncOperationError) {
+	slog.Warn("App deployment failed: the triggered sync did not succeed.", "app", syncErr.App, "id", task.Id)
+	monitor.argo.metrics.AddFailedDeployment(task.App)
+
+	if err := monitor.argo.State.SetTaskStatus(task.Id, models.StatusFailedMessage, syncErr.Reason()); err != nil {
+		slog.Error("Failed to change task status", "error", err, "id", task.Id)
+	}
+	task.Status = models.StatusFailedMessage
+}
+
+// successReason is the status reason recorded on a deployed task. It is empty unless
+// argo-watcher triggered the sync itself, in which case it records how that sync went.
+func (monitor *DeploymentMonitor) successReason(application *models.Application) string {
+	if !application.IsSyncRequested(monitor.syncApp) {
+		return ""
+	}
+	return application.SyncOperationSummary()
+}
+
+func (monitor *DeploymentMonitor) handleDeploymentSuccess(task *models.Task, reason string) {
 	slog.Info("App is running on the expected version.", "id", task.Id)
 	monitor.argo.metrics.ResetFailedDeployment(task.App)
-	if err := monitor.argo.State.SetTaskStatus(task.Id, models.StatusDeployedMessage, ""); err != nil {
+	// Why a downgrade was let through is kept on the deployed task, which is where an
+	// audit of what ran in production looks for it.
+	if strings.HasPrefix(task.StatusReason, downgradeAllowedReason) {
+		reason = strings.TrimSpace(task.StatusReason + "\n\n" + reason)
+	}
+	if err := monitor.argo.State.SetTaskStatus(task.Id, models.StatusDeployedMessage, reason); err != nil {
 		slog.Error("Failed to change task status", "error", err, "id", task.Id)
 	}
 	task.Status = models.StatusDeployedMessage
@@ -428,16 +595,21 @@ func (monitor *DeploymentMonitor) handleDeploymentSuccess(task *models.Task) {
 func (monitor *DeploymentMonitor) handleDeploymentFailure(task *models.Task, status string, application *models.Application, waited time.Duration) {
 	slog.Warn("App deployment failed.", "id", task.Id)
 	monitor.argo.metrics.AddFailedDeployment(task.App)
-	tree := monitor.fetchResourceTree(task)
+	tree := monitor.fetchResourceTree(task.App)
 	reason := fmt.Sprintf(
 		"%s\n\n%s",
 		application.RolloutFailureHeadline(status, waited),
 		application.GetRolloutMessage(status, task.ListImages(), tree),
 	)
+	if excerpt := monitor.collectPodDiagnostics(task.App, tree); excerpt != "" {
+		reason += "\n\n" + excerpt
+	}
 	if err := monitor.argo.State.SetTaskStatus(task.Id, models.StatusFailedMessage, reason); err != nil {
 		slog.Error("Failed to change task status", "error", err, "id", task.Id)
 	}
 	task.Status = models.StatusFailedMessage
+	// Kept on the task as well, so the failure notification carries the same reason.
+	task.StatusReason = reason
 }
 
 // resourceTreeTimeout bounds the best-effort resource-tree fetch on the failure path so
@@ -448,13 +620,13 @@ const resourceTreeTimeout = 10 * time.Second
 // failure reason with pod-level causes (ImagePullBackOff, CrashLoopBackOff). It is deliberately
 // non-fatal: any error yields a nil tree and GetRolloutMessage falls back to the app's top-level
 // resources, so a resource-tree hiccup never prevents the deployment from being marked failed.
-func (monitor *DeploymentMonitor) fetchResourceTree(task *models.Task) *models.ApplicationTree {
+func (monitor *DeploymentMonitor) fetchResourceTree(app string) *models.ApplicationTree {
 	ctx, cancel := context.WithTimeout(context.Background(), resourceTreeTimeout)
 	defer cancel()
 
-	tree, err := monitor.argo.api.GetResourceTree(ctx, task.App)
+	tree, err := monitor.argo.api.GetResourceTree(ctx, app)
 	if err != nil {
-		slog.Debug("Could not fetch resource tree for failure diagnostics", "error", err, "id", task.Id)
+		slog.Debug("Could not fetch resource tree for failure diagnostics", "error", err, "app", app)
 		return nil
 	}
 	return tree
@@ -0,0 +1,117 @@
+package argocd
+
+import (
+	"fmt"
+	"strings"
+
+	"golang.org/x/mod/semver"
+
+	"github.com/shini4i/argo-watcher/internal/models"
+)
+
+// downgradeAllowedReason starts the status reason of a task let through the downgrade
+// protection, so the decision can be told apart from the reasons the rollout records.
+const downgradeAllowedReason = "Downgrade allowed"
+
+// DowngradeError reports a task that would deploy versions older than the ones its
+// application runs, without being marked as a rollback. It is the submitter's to fix,
+// unlike a state backend that cannot be consulted.
+type DowngradeError struct {
+	Downgrades []string
+}
+
+func (err *DowngradeError) Error() string {
+	return fmt.Sprintf("the task downgrades %s; mark it as a rollback to deploy it anyway", strings.Join(err.Downgrades, ", "))
+}
+
+// checkDowngrade compares the versions task deploys with the ones last deployed to its
+// application, newest first in deployed. It returns the status reason recording why a
+// downgrade was let through, nothing when there is no downgrade, and a *DowngradeError
+// when the task is neither marked as a rollback nor sent by a privileged user.
+func checkDowngrade(task models.Task, deployed []models.Task) (string, error) {
+	downgrades := findDowngrades(task, deployed)
+	switch {
+	case len(downgrades) == 0:
+		return "", nil
+	case task.Rollback:
+		return fmt.Sprintf("%s, the task is marked as a rollback: %s", downgradeAllowedReason, strings.Join(downgrades, ", ")), nil
+	case task.Privileged:
+		return fmt.Sprintf("%s, the task was sent by a privileged user: %s", downgradeAllowedReason, strings.Join(downgrades, ", ")), nil
+	default:
+		return "", &DowngradeError{Downgrades: downgrades}
+	}
+}
+
+// findDowngrades describes every version of task older than the one last deployed in
+// its place: the tag of each image against the tag that image was last deployed with,
+// and the chart version against the last one deployed. Versions that are not semantic
+// versions, on either side, cannot be ordered and are never a downgrade.
+func findDowngrades(task models.Task, deployed []models.Task) []string {
+	var downgrades []string
+
+	if task.ChartVersion != "" {
+		for _, previous := range deployed {
+			if previous.ChartVersion == "" {
+				continue
+			}
+			if isDowngrade(task.ChartVersion, previous.ChartVersion) {
+				downgrades = append(downgrades, fmt.Sprintf("the chart from %s to %s", previous.ChartVersion, task.ChartVersion))
+			}
+			break
+		}
+	}
+
+	for _, image := range task.Images {
+		if previous, ok := lastDeployedTag(image.Image, deployed); ok && isDowngrade(image.Tag, previous) {
+			downgrades = append(downgrades, fmt.Sprintf("%s from %s to %s", image.Image, previous, image.Tag))
+		}
+	}
+
+	return downgrades
+}
+
+// lastDeployedTag returns the tag image was deployed with by the most recent task of
+// deployed that carries it.
+func lastDeployedTag(image string, deployed []models.Task) (string, bool) {
+	for _, previous := range deployed {
+		for _, candidate := range previous.Images {
+			if candidate.Image == image {
+				return candidate.Tag, true
+			}
+		}
+	}
+	return "", false
+}
+
+// isDowngrade reports whether version is an older semantic version than current.
+func isDowngrade(version, current string) bool {
+	parsed, ok := parseSemver(version)
+	if !ok {
+		return false
+	}
+	parsedCurrent, ok := parseSemver(current)
+	if !ok {
+		return false
+	}
+	return semver.Compare(parsed, parsedCurrent) < 0
+}
+
+// parseSemver returns tag in the form golang.org/x/mod/semver compares, with or
+// without the conventional "v" prefix. Only a full MAJOR.MINOR.PATCH version counts:
+// semver would read the shorthand "1" or "1.2" as versions, and with them a build
+// number or an all-digit commit hash.
+func parseSemver(tag string) (string, bool) {
+	version := tag
+	if !strings.HasPrefix(version, "v") {
+		version = "v" + version
+	}
+	if !semver.IsValid(version) {
+		return "", false
+	}
+	core, _, _ := strings.Cut(strings.TrimPrefix(version, "v"), "+")
+	core, _, _ = strings.Cut(core, "-")
+	if strings.Count(core, ".") != 2 {
+		return "", false
+	}
+	return version, true
+}
@@ -0,0 +1,123 @@
+package argocd
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/shini4i/argo-watcher/internal/models"
+)
+
+func TestFindDowngrades(t *testing.T) {
+	// deployed is newest first, as GetTasks returns it.
+	deployed := []models.Task{
+		{Id: "t3", Images: []models.Image{{Image: "api", Tag: "v1.4.0"}}},
+		{Id: "t2", Images: []models.Image{{Image: "api", Tag: "v1.3.0"}, {Image: "worker", Tag: "2.0.0"}}},
+		{Id: "t1", ChartVersion: "3.1.0"},
+		{Id: "t0", Images: []models.Image{{Image: "cron", Tag: "latest"}}},
+	}
+
+	tests := []struct {
+		name string
+		task models.Task
+		want []string
+	}{
+		{
+			name: "an older tag than the last deployed one",
+			task: models.Task{Images: []models.Image{{Image: "api", Tag: "v1.3.0"}}},
+			want: []string{"api from v1.4.0 to v1.3.0"},
+		},
+		{
+			name: "each image against its own last deployment",
+			task: models.Task{Images: []models.Image{{Image: "api", Tag: "v1.5.0"}, {Image: "worker", Tag: "1.9.9"}}},
+			want: []string{"worker from 2.0.0 to 1.9.9"},
+		},
+		{
+			name: "tags compare with or without the v prefix",
+			task: models.Task{Images: []models.Image{{Image: "api", Tag: "1.4.0-rc.1"}}},
+			want: []string{"api from v1.4.0 to 1.4.0-rc.1"},
+		},
+		{
+			name: "the same or a newer tag",
+			task: models.Task{Images: []models.Image{{Image: "api", Tag: "v1.4.0"}, {Image: "worker", Tag: "2.0.1"}}},
+		},
+		{
+			name: "a tag that is not a semantic version",
+			task: models.Task{Images: []models.Image{{Image: "api", Tag: "1234567"}, {Image: "cron", Tag: "1.0.0"}}},
+		},
+		{
+			name: "an image never deployed",
+			task: models.Task{Images: []models.Image{{Image: "new", Tag: "0.1.0"}}},
+		},
+		{
+			name: "an older chart version",
+			task: models.Task{ChartVersion: "3.0.2"},
+			want: []string{"the chart from 3.1.0 to 3.0.2"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, findDowngrades(tt.task, deployed))
+		})
+	}
+}
+
+func TestCheckDowngrade(t *testing.T) {
+	deployed := []models.Task{{Images: []models.Image{{Image: "api", Tag: "1.4.0"}}}}
+	downgrade := models.Task{Images: []models.Image{{Image: "api", Tag: "1.2.0"}}}
+
+	t.Run("Rejects a downgrade", func(t *testing.T) {
+		_, err := checkDowngrade(downgrade, deployed)
+
+		var downgradeErr *DowngradeError
+		require.True(t, errors.As(err, &downgradeErr), err)
+		assert.Equal(t, []string{"api from 1.4.0 to 1.2.0"}, downgradeErr.Downgrades)
+	})
+
+	t.Run("Lets a downgrade marked as a rollback through", func(t *testing.T) {
+		task := downgrade
+		task.Rollback = true
+
+		reason, err := checkDowngrade(task, deployed)
+
+		require.NoError(t, err)
+		assert.Equal(t, "Downgrade allowed, the task is marked as a rollback: api from 1.4.0 to 1.2.0", reason)
+	})
+
+	t.Run("Lets a downgrade by a privileged user through", func(t *testing.T) {
+		task := downgrade
+		task.Privileged = true
+
+		reason, err := checkDowngrade(task, deployed)
+
+		require.NoError(t, err)
+		assert.Equal(t, "Downgrade allowed, the task was sent by a privileged user: api from 1.4.0 to 1.2.0", reason)
+	})
+
+	t.Run("Records nothing for an upgrade", func(t *testing.T) {
+		reason, err := checkDowngrade(models.Task{Images: []models.Image{{Image: "api", Tag: "1.5.0"}}}, deployed)
+
+		require.NoError(t, err)
+		assert.Empty(t, reason)
+	})
+}
+
+func TestParseSemver(t *testing.T) {
+	for tag, want := range map[string]string{
+		"1.2.3":            "v1.2.3",
+		"v1.2.3-rc.1":      "v1.2.3-rc.1",
+		"1.2.3+build.7":    "v1.2.3+build.7",
+		"1.2":              "",
+		"20240101":         "",
+		"latest":           "",
+		"v1.2.3.4":         "",
+		"1.2.3-build+meta": "v1.2.3-build+meta",
+	} {
+		version, ok := parseSemver(tag)
+		assert.Equal(t, want, version, tag)
+		assert.Equal(t, want != "", ok, tag)
+	}
+}
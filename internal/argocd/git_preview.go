@@ -0,0 +1,101 @@
+package argocd
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+
+	"github.com/shini4i/argo-watcher/internal/models"
+	"github.com/shini4i/argo-watcher/internal/updater"
+)
+
+// PreviewError rejects a dry run whose application cannot be written back: ArgoCD does
+// not know it, or its annotations do not describe a write-back. Like GroupTargetError,
+// it is the submitter's mistake rather than an outage, and is reported as such.
+type PreviewError struct {
+	Message string
+}
+
+func (err *PreviewError) Error() string {
+	return err.Message
+}
+
+// PreviewWriteBack works out the git change a deployment of task would write back,
+// without creating a task or pushing anything. It previews an application whether or
+// not write-back is enabled on it yet, so the change can be checked before it is.
+func (updater *ArgoStatusUpdater) PreviewWriteBack(task models.Task) (*models.WriteBackPreview, error) {
+	// No refresh: the preview reads the application's annotations and source, which a
+	// refresh does not change.
+	app, err := updater.monitor.ConfirmApplication(context.Background(), task.App, false)
+	if err != nil {
+		if task.IsAppNotFoundError(err) {
+			return nil, &PreviewError{Message: fmt.Sprintf("application %q was not found in ArgoCD", task.App)}
+		}
+		return nil, err
+	}
+
+	return updater.gitUpdater.Preview(app, task)
+}
+
+// Preview resolves the GitOps repository of app, clones or refreshes its cache and
+// returns the change the write-back of task would commit there.
+func (gitUpdater *GitUpdater) Preview(app *models.Application, task models.Task) (*models.WriteBackPreview, error) {
+	return gitUpdater.preview(app, task, updater.GitClient{})
+}
+
+// preview implements Preview. It holds the per-repo lock while it reads the cache, so
+// it never observes a write-back half-way through. gitHandler is injected to enable
+// testing.
+func (gitUpdater *GitUpdater) preview(app *models.Application, task models.Task, gitHandler updater.GitHandler) (*models.WriteBackPreview, error) {
+	gitopsRepo, err := writeBackRepo(app, &task, gitUpdater.repoCachePath)
+	if err != nil {
+		return nil, &PreviewError{Message: fmt.Sprintf("application %q does not describe a write-back target: %s", task.App, err)}
+	}
+
+	if gitopsRepo.Path == "" {
+		return nil, &PreviewError{Message: fmt.Sprintf("application %q has no source path, unsupported Application configuration", task.App)}
+	}
+
+	releaseOverrides, err := writeBackContent(app, &task)
+	if err != nil {
+		return nil, &PreviewError{Message: err.Error()}
+	}
+
+	if releaseOverrides == nil {
+		return nil, &PreviewError{Message: fmt.Sprintf("application %q declares no managed images in its %s annotation", task.App, managedImagesAnnotation)}
+	}
+
+	repo, err := updater.NewGitRepo(gitopsRepo.RepoUrl, gitopsRepo.BranchName, gitopsRepo.Path, gitopsRepo.Filename, gitopsRepo.RepoCachePath, gitHandler)
+	if err != nil {
+		slog.Error("Failed to create git repo instance", "url", gitopsRepo.RepoUrl, "error", err, "app", task.App)
+		return nil, err
+	}
+
+	var change *updater.ChangePreview
+	err = withCachedRepo(gitUpdater.locker, gitUpdater.metrics, gitopsRepo.RepoCachePath, gitopsRepo.RepoUrl, gitopsRepo.BranchName, func() error {
+		ctx, cancel := context.WithTimeout(context.Background(), repo.GitOpTimeout())
+		defer cancel()
+
+		if err := repo.Clone(ctx); err != nil {
+			return fmt.Errorf("clone failed: %w", err)
+		}
+
+		var err error
+		change, err = repo.PreviewApp(app.Metadata.Name, repo.Path, repo.FileName, releaseOverrides, &task)
+		return err
+	})
+	if err != nil {
+		slog.Error("Failed to preview git write-back", "app", task.App, "error", err)
+		return nil, err
+	}
+
+	return &models.WriteBackPreview{
+		App:           app.Metadata.Name,
+		Managed:       app.IsManagedByWatcher(),
+		Repo:          gitopsRepo.RepoUrl,
+		Branch:        gitopsRepo.BranchName,
+		Paths:         change.Paths,
+		CommitMessage: change.CommitMessage,
+		Diff:          change.Diff,
+	}, nil
+}
@@ -0,0 +1,175 @@
+package argocd
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	gogit "github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
+
+	"github.com/shini4i/argo-watcher/internal/lock"
+	"github.com/shini4i/argo-watcher/internal/mocks"
+	"github.com/shini4i/argo-watcher/internal/models"
+)
+
+const previewRepoURL = "git@example.com:test/preview.git"
+
+// newPreviewApp returns an application whose source points at previewRepoURL, with the
+// managed-image annotations of newAppWithImages.
+func newPreviewApp(name string) *models.Application {
+	app := newAppWithImages(name)
+	app.Spec.Source = models.ApplicationSource{RepoURL: previewRepoURL, TargetRevision: "main", Path: "apps"}
+	return app
+}
+
+// clonePreviewRepo commits files into a fresh repository on branch main and clones it to
+// the cache path the preview resolves for previewRepoURL.
+func clonePreviewRepo(t *testing.T, repoCachePath string, files map[string]string) *gogit.Repository {
+	t.Helper()
+
+	sourcePath := t.TempDir()
+	sourceRepo, err := gogit.PlainInit(sourcePath, false)
+	require.NoError(t, err)
+	sourceWt, err := sourceRepo.Worktree()
+	require.NoError(t, err)
+	for name, content := range files {
+		require.NoError(t, os.MkdirAll(filepath.Dir(filepath.Join(sourcePath, name)), 0755))
+		require.NoError(t, os.WriteFile(filepath.Join(sourcePath, name), []byte(content), 0644))
+		_, err = sourceWt.Add(name)
+		require.NoError(t, err)
+	}
+	_, err = sourceWt.Commit("initial", &gogit.CommitOptions{
+		Author:            &object.Signature{Name: "init", Email: "init@test.com", When: time.Now()},
+		AllowEmptyCommits: true,
+	})
+	require.NoError(t, err)
+
+	localRepo, err := gogit.PlainClone(computeRepoCachePath(repoCachePath, previewRepoURL, "main"), false, &gogit.CloneOptions{URL: sourcePath})
+	require.NoError(t, err)
+	return localRepo
+}
+
+func TestGitUpdaterPreview(t *testing.T) {
+	t.Setenv("SSH_KEY_PATH", "/dev/null")
+	t.Setenv("GIT_OP_TIMEOUT", "5s")
+
+	cloningHandler := func(t *testing.T, localRepo *gogit.Repository) *mocks.MockGitHandler {
+		handler := mocks.NewMockGitHandler(gomock.NewController(t))
+		handler.EXPECT().AddSSHKey(gomock.Any(), gomock.Any(), gomock.Any()).Return(nil, nil)
+		handler.EXPECT().PlainOpen(gomock.Any()).Return(nil, gogit.ErrRepositoryNotExists)
+		handler.EXPECT().PlainClone(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).Return(localRepo, nil)
+		return handler
+	}
+
+	t.Run("Diffs the override file against the cached branch", func(t *testing.T) {
+		repoCachePath := t.TempDir()
+		existing := "helm:\n    parameters:\n        - name: image.tag\n          value: v0.9.0\n          forceString: true\n"
+		localRepo := clonePreviewRepo(t, repoCachePath, map[string]string{"apps/.argocd-source-demo.yaml": existing})
+
+		gitUpdater := NewGitUpdater(lock.NewInMemoryLocker(), repoCachePath, nil, nil)
+		preview, err := gitUpdater.preview(newPreviewApp("demo"), *newImageTask(), cloningHandler(t, localRepo))
+		require.NoError(t, err)
+
+		assert.Equal(t, "demo", preview.App)
+		assert.False(t, preview.Managed)
+		assert.Equal(t, previewRepoURL, preview.Repo)
+		assert.Equal(t, "main", preview.Branch)
+		assert.Equal(t, []string{"apps/.argocd-source-demo.yaml"}, preview.Paths)
+		assert.Equal(t, "argo-watcher(demo): update image tag", preview.CommitMessage)
+		assert.Contains(t, preview.Diff, "-          value: v0.9.0\n")
+		assert.Contains(t, preview.Diff, "+          value: v1.0.0\n")
+
+		content, err := os.ReadFile(filepath.Join(computeRepoCachePath(repoCachePath, previewRepoURL, "main"), preview.Paths[0]))
+		require.NoError(t, err)
+		assert.Equal(t, existing, string(content), "a preview must leave the cached clone untouched")
+		head, err := localRepo.Head()
+		require.NoError(t, err)
+		commit, err := localRepo.CommitObject(head.Hash())
+		require.NoError(t, err)
+		assert.Equal(t, "initial", commit.Message, "a preview must not commit")
+	})
+
+	t.Run("Reports a managed application", func(t *testing.T) {
+		repoCachePath := t.TempDir()
+		localRepo := clonePreviewRepo(t, repoCachePath, nil)
+
+		app := newPreviewApp("demo")
+		app.Metadata.Annotations["argo-watcher/managed"] = "true"
+
+		gitUpdater := NewGitUpdater(lock.NewInMemoryLocker(), repoCachePath, nil, nil)
+		preview, err := gitUpdater.preview(app, *newImageTask(), cloningHandler(t, localRepo))
+		require.NoError(t, err)
+
+		assert.True(t, preview.Managed)
+		assert.Contains(t, preview.Diff, "--- /dev/null")
+	})
+
+	t.Run("Rejects an application without managed images", func(t *testing.T) {
+		app := newPreviewApp("demo")
+		delete(app.Metadata.Annotations, managedImagesAnnotation)
+
+		gitUpdater := NewGitUpdater(lock.NewInMemoryLocker(), t.TempDir(), nil, nil)
+		_, err := gitUpdater.preview(app, *newImageTask(), mocks.NewMockGitHandler(gomock.NewController(t)))
+
+		var previewErr *PreviewError
+		require.ErrorAs(t, err, &previewErr)
+		assert.Contains(t, err.Error(), "declares no managed images")
+	})
+
+	t.Run("Rejects a managed image without its tag annotation", func(t *testing.T) {
+		app := newPreviewApp("demo")
+		delete(app.Metadata.Annotations, "argo-watcher/app.helm.image-tag")
+
+		gitUpdater := NewGitUpdater(lock.NewInMemoryLocker(), t.TempDir(), nil, nil)
+		_, err := gitUpdater.preview(app, *newImageTask(), mocks.NewMockGitHandler(gomock.NewController(t)))
+
+		var previewErr *PreviewError
+		require.ErrorAs(t, err, &previewErr)
+		assert.Contains(t, err.Error(), "is missing its argo-watcher/app.helm.image-tag annotation")
+	})
+
+	t.Run("Rejects an application without a git source", func(t *testing.T) {
+		gitUpdater := NewGitUpdater(lock.NewInMemoryLocker(), t.TempDir(), nil, nil)
+		_, err := gitUpdater.preview(newAppWithImages("demo"), *newImageTask(), mocks.NewMockGitHandler(gomock.NewController(t)))
+
+		var previewErr *PreviewError
+		require.ErrorAs(t, err, &previewErr)
+	})
+
+	t.Run("Surfaces a clone failure as is", func(t *testing.T) {
+		handler := mocks.NewMockGitHandler(gomock.NewController(t))
+		handler.EXPECT().AddSSHKey(gomock.Any(), gomock.Any(), gomock.Any()).Return(nil, nil)
+		handler.EXPECT().PlainOpen(gomock.Any()).Return(nil, gogit.ErrRepositoryNotExists)
+		handler.EXPECT().PlainClone(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).Return(nil, errors.New("remote hung up"))
+
+		gitUpdater := NewGitUpdater(lock.NewInMemoryLocker(), t.TempDir(), nil, nil)
+		_, err := gitUpdater.preview(newPreviewApp("demo"), *newImageTask(), handler)
+
+		require.Error(t, err)
+		var previewErr *PreviewError
+		assert.False(t, errors.As(err, &previewErr), "a git failure is not the submitter's mistake")
+		assert.Contains(t, err.Error(), "remote hung up")
+	})
+}
+
+func TestPreviewWriteBackRejectsUnknownApplication(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	api := newArgoApiMock(ctrl)
+	api.EXPECT().GetApplication(gomock.Any(), "missing", false).
+		Return(nil, errors.New(`applications.argoproj.io "missing" not found`))
+
+	updater := initTestUpdater(t, newUpdaterTestConfig(lock.NewInMemoryLocker()), newGroupArgo(api))
+	_, err := updater.PreviewWriteBack(models.Task{App: "missing"})
+
+	var previewErr *PreviewError
+	require.ErrorAs(t, err, &previewErr)
+	assert.Equal(t, `application "missing" was not found in ArgoCD`, err.Error())
+}
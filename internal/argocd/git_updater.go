@@ -2,6 +2,7 @@ package argocd
 
 import (
 	"context"
+	"fmt"
 	"log/slog"
 	"time"
 
@@ -11,6 +12,56 @@ import (
 	"github.com/shini4i/argo-watcher/internal/updater"
 )
 
+// WriteBackSkippedError fails a task whose write-back was skipped although the task
+// carries a change only the write-back makes. Left to run, the rollout would deploy
+// the images and report the task deployed, the change silently dropped.
+type WriteBackSkippedError struct {
+	App string
+	// Change names what the task carried, and Cause why the write-back was skipped.
+	Change string
+	Cause  string
+}
+
+func (err *WriteBackSkippedError) Error() string {
+	return fmt.Sprintf("the write-back of application %q was skipped, dropping its %s: %s", err.App, err.Change, err.Cause)
+}
+
+// Reason renders the user-facing task failure reason.
+func (err *WriteBackSkippedError) Reason() string {
+	return fmt.Sprintf("Application deployment failed. The task carries %s, which only the git write-back applies, but %s.", err.Change, err.Cause)
+}
+
+const (
+	writeBackSkippedUnmanaged   = "the application is not managed by argo-watcher"
+	writeBackSkippedUnvalidated = "the task presented no valid credential"
+)
+
+// skippedWriteBack returns the error failing a task whose write-back is skipped for
+// cause, when the task carries a change the rollout cannot deploy without it: a chart
+// version, which ArgoCD would otherwise be awaited to sync for the whole rollout
+// window, or parameters.
+func skippedWriteBack(task *models.Task, cause string) error {
+	switch {
+	case task.ChartVersion != "":
+		return &WriteBackSkippedError{App: task.App, Change: "chart version " + task.ChartVersion, Cause: cause}
+	case len(task.Parameters) > 0:
+		return &WriteBackSkippedError{App: task.App, Change: "parameters", Cause: cause}
+	default:
+		return nil
+	}
+}
+
+// HandleWriteBackSkipped fails a task whose change was not written back.
+func (monitor *DeploymentMonitor) HandleWriteBackSkipped(task *models.Task, skipErr *WriteBackSkippedError) {
+	slog.Warn("App deployment failed: the write-back the task needs was skipped.", "change", skipErr.Change, "cause", skipErr.Cause, "app", skipErr.App, "id", task.Id)
+	monitor.argo.metrics.AddFailedDeployment(task.App)
+
+	if err := monitor.argo.State.SetTaskStatus(task.Id, models.StatusFailedMessage, skipErr.Reason()); err != nil {
+		slog.Error("Failed to change task status", "error", err, "id", task.Id)
+	}
+	task.Status = models.StatusFailedMessage
+}
+
 // GitUpdater encapsulates the logic required to update Git repositories watched by ArgoCD.
 type GitUpdater struct {
 	locker        lock.Locker
@@ -22,6 +73,9 @@ type GitUpdater struct {
 	// clone + push. When nil (the default) each app is written back on its own via
 	// the serialized per-repo-lock path.
 	batcher *Batcher
+	// pullRequests opens the pull requests of applications using the pull-request
+	// write-back method. When nil such applications fail their write-back.
+	pullRequests updater.PullRequestProvider
 }
 
 // NewGitUpdater creates a GitUpdater instance.
@@ -69,10 +123,17 @@ func (gitUpdater *GitUpdater) observeWriteback(app string, d time.Duration) {
 // watcher and has valid credentials. isSuperseded is an optional (at most one)
 // predicate forwarded to the write-back retry loop so a task superseded by a
 // newer deployment aborts instead of committing a stale image tag.
-func (gitUpdater *GitUpdater) UpdateIfNeeded(app *models.Application, task models.Task, isSuperseded ...func() bool) error {
+//
+// An application using the pull-request write-back method has its change proposed
+// rather than pushed, and the pull request opened for it is returned; it is nil in
+// every other case. A signed commit made by the write-back is noted in task.WriteBack.
+//
+// A skipped write-back returns a *WriteBackSkippedError for a task carrying a chart
+// version or parameters, which nothing else would apply.
+func (gitUpdater *GitUpdater) UpdateIfNeeded(app *models.Application, task *models.Task, isSuperseded ...func() bool) (*updater.PullRequest, error) {
 	if !app.IsManagedByWatcher() {
 		slog.Debug("Skipping git repo update: application is not managed by the watcher.", "id", task.Id)
-		return nil
+		return nil, skippedWriteBack(task, writeBackSkippedUnmanaged)
 	}
 
 	// The managed annotation asks for write-back, so a task that cannot authorize it is a
@@ -83,19 +144,27 @@ func (gitUpdater *GitUpdater) UpdateIfNeeded(app *models.Application, task model
 		slog.Warn("Skipping git repo update: application is managed by the watcher but the task presented no valid credential.",
 			"app", task.App, "id", task.Id)
 		gitUpdater.countSkippedWriteback(task.App)
-		return nil
+		return nil, skippedWriteBack(task, writeBackSkippedUnvalidated)
 	}
 
-	gitopsRepo, err := models.NewGitopsRepo(app, gitUpdater.repoCachePath)
+	gitopsRepo, err := writeBackRepo(app, task, gitUpdater.repoCachePath)
 	if err != nil {
 		slog.Error("Failed to get gitops repo info", "app", task.App, "error", err, "id", task.Id)
-		return err
+		return nil, err
+	}
+
+	method, err := writeBackMethod(app.Metadata.Annotations)
+	if err != nil {
+		return nil, err
+	}
+	if method == writeBackMethodPullRequest {
+		return gitUpdater.proposeGitRepo(app, task, &gitopsRepo, isSuperseded...)
 	}
 
 	// In batch mode the batcher owns the lock, clone, commit and push for the
 	// whole batch, so the per-repo lock is not taken here.
 	if gitUpdater.batcher != nil {
-		return gitUpdater.updateViaBatcher(app, &task, &gitopsRepo, isSuperseded...)
+		return nil, gitUpdater.updateViaBatcher(app, task, &gitopsRepo, isSuperseded...)
 	}
 
 	// Timed from just before the lock request so lock-wait captures the full queueing
@@ -107,16 +176,45 @@ func (gitUpdater *GitUpdater) UpdateIfNeeded(app *models.Application, task model
 		workStart := time.Now()
 		defer func() { gitUpdater.observeWriteback(task.App, time.Since(workStart)) }()
 		slog.Debug("Application managed by watcher. Initiating git repo update.", "id", task.Id)
-		return gitUpdater.updateGitRepo(app, &task, &gitopsRepo, isSuperseded...)
+		return gitUpdater.updateGitRepo(app, task, &gitopsRepo, isSuperseded...)
 	}
 
-	err = gitUpdater.locker.WithLock(gitopsRepo.RepoUrl, gitUpdateFunc)
+	err = withCachedRepo(gitUpdater.locker, gitUpdater.metrics, gitopsRepo.RepoCachePath, gitopsRepo.RepoUrl, gitopsRepo.BranchName, gitUpdateFunc)
 	if err != nil {
 		slog.Error("Failed git repo update", "app", task.App, "error", err, "id", task.Id)
+		return nil, err
+	}
+
+	return nil, nil
+}
+
+// proposeGitRepo writes the change back on a branch of its own and opens a pull
+// request for it. It bypasses the batcher: nothing contends for the task's branch,
+// and the pull request belongs to this task alone. The per-repo lock is still taken,
+// as the proposal is built in the same cached clone as every other write-back.
+func (gitUpdater *GitUpdater) proposeGitRepo(app *models.Application, task *models.Task, gitopsRepo *models.GitopsRepo, isSuperseded ...func() bool) (*updater.PullRequest, error) {
+	if gitUpdater.pullRequests == nil {
+		return nil, fmt.Errorf("application %q asks for the %s write-back method, but no pull request provider is configured (GIT_PR_PROVIDER)", task.App, writeBackMethodPullRequest)
+	}
+
+	var pr *updater.PullRequest
+	lockRequested := time.Now()
+	err := withCachedRepo(gitUpdater.locker, gitUpdater.metrics, gitopsRepo.RepoCachePath, gitopsRepo.RepoUrl, gitopsRepo.BranchName, func() error {
+		gitUpdater.observeLockWait(task.App, time.Since(lockRequested))
+		workStart := time.Now()
+		defer func() { gitUpdater.observeWriteback(task.App, time.Since(workStart)) }()
+
+		var err error
+		// context.Background() is intentional, as in updateGitRepo.
+		pr, err = ProposeGitImageTag(context.Background(), app, task, gitopsRepo, updater.GitClient{}, gitUpdater.pullRequests, isSuperseded...)
 		return err
+	})
+	if err != nil {
+		slog.Error("Failed to propose the git repo update", "app", task.App, "error", err, "id", task.Id)
+		return nil, err
 	}
 
-	return nil
+	return pr, nil
 }
 
 // updateViaBatcher enqueues the write-back into the coalescing batcher and blocks
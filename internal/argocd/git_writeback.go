@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"log/slog"
 	"math/rand"
+	"path"
 	"strings"
 	"time"
 
@@ -20,12 +21,22 @@ import (
 var ErrDeploymentSuperseded = errors.New("deployment superseded before write-back; aborting to avoid committing a stale image tag")
 
 const (
-	managedImagesAnnotation = "argo-watcher/managed-images"
-	managedImageTagPattern  = "argo-watcher/%s.helm.image-tag"
+	managedImagesAnnotation    = "argo-watcher/managed-images"
+	managedImageTagPattern     = "argo-watcher/%s.helm.image-tag"
+	managedImageValuesPattern  = "argo-watcher/%s.helm.values-file"
+	managedImageNamePattern    = "argo-watcher/%s.kustomize.image-name"
+	writeBackModeAnnotation    = "argo-watcher/write-back-mode"
+	writeBackModeHelm          = "helm"
+	writeBackModeKustomize     = "kustomize"
+	kustomizeImageDigestPrefix = "sha256:"
 )
 
-// generateOverrideFileContent builds the Helm override file for the task's managed
-// images. It returns nil (no error) when no managed images are declared.
+// generateOverrideFileContent builds the override file for the task's managed images:
+// Helm parameters by default, or Kustomize images when the application is annotated
+// with argo-watcher/write-back-mode=kustomize. A managed image whose alias names a
+// values file has its tag set in that file instead of a Helm parameter. The task's
+// parameters are written as Helm parameters next to the tags. It returns nil (no error)
+// when no managed images are declared and the task carries no parameters.
 func generateOverrideFileContent(annotations map[string]string, task *models.Task) (*updater.ArgoOverrideFile, error) {
 	overrideFileContent := updater.ArgoOverrideFile{}
 	managedImages, err := extractManagedImages(annotations)
@@ -33,34 +44,172 @@ func generateOverrideFileContent(annotations map[string]string, task *models.Tas
 		return nil, err
 	}
 
-	if len(managedImages) == 0 {
+	if len(managedImages) == 0 && len(task.Parameters) == 0 {
 		slog.Warn("annotation not found, skipping image update", "annotation", managedImagesAnnotation)
 		return nil, nil
 	}
 
+	mode := writeBackModeHelm
+	if value, exists := annotations[writeBackModeAnnotation]; exists {
+		mode = strings.TrimSpace(value)
+	}
+	if mode != writeBackModeHelm && mode != writeBackModeKustomize {
+		return nil, fmt.Errorf("unsupported %s %q, expected %q or %q", writeBackModeAnnotation, mode, writeBackModeHelm, writeBackModeKustomize)
+	}
+
 	for _, image := range task.Images {
 		for appAlias, appImage := range managedImages {
-			if image.Image == appImage {
-				tagAnnotation := fmt.Sprintf(managedImageTagPattern, appAlias)
-				tagPath, exists := annotations[tagAnnotation]
-				if !exists {
-					// Without the tag-path annotation we cannot know which Helm value to
-					// override. Silently skipping would let the write-back report success
-					// while never updating git, so fail loudly instead.
-					return nil, fmt.Errorf("managed image %q (alias %q) is missing its %s annotation", appImage, appAlias, tagAnnotation)
-				}
-				overrideFileContent.Helm.Parameters = append(overrideFileContent.Helm.Parameters, updater.ArgoParameterOverride{
-					Name:        tagPath,
-					Value:       image.Tag,
-					ForceString: true,
+			if image.Image != appImage {
+				continue
+			}
+
+			if mode == writeBackModeKustomize {
+				overrideFileContent.Kustomize.Images = append(overrideFileContent.Kustomize.Images, kustomizeImageOverride(annotations, appAlias, appImage, image))
+				continue
+			}
+
+			tagAnnotation := fmt.Sprintf(managedImageTagPattern, appAlias)
+			tagPath, exists := annotations[tagAnnotation]
+			if !exists {
+				// Without the tag-path annotation we cannot know which Helm value to
+				// override. Silently skipping would let the write-back report success
+				// while never updating git, so fail loudly instead.
+				return nil, fmt.Errorf("managed image %q (alias %q) is missing its %s annotation", appImage, appAlias, tagAnnotation)
+			}
+			if valuesFile := strings.TrimSpace(annotations[fmt.Sprintf(managedImageValuesPattern, appAlias)]); valuesFile != "" {
+				overrideFileContent.ValuesFiles = append(overrideFileContent.ValuesFiles, updater.ValuesFileUpdate{
+					File:  valuesFile,
+					Path:  tagPath,
+					Value: image.PinnedTag(),
 				})
+				continue
 			}
+			overrideFileContent.Helm.Parameters = append(overrideFileContent.Helm.Parameters, updater.ArgoParameterOverride{
+				Name:        tagPath,
+				Value:       image.PinnedTag(),
+				ForceString: true,
+			})
 		}
 	}
 
+	if len(task.Parameters) == 0 {
+		return &overrideFileContent, nil
+	}
+	if mode == writeBackModeKustomize {
+		return nil, fmt.Errorf("the task carries parameters, which are Helm parameters and cannot be written back in %s mode", writeBackModeKustomize)
+	}
+	if err := checkAllowedParameters(annotations, task.Parameters); err != nil {
+		return nil, err
+	}
+	for _, parameter := range task.Parameters {
+		overrideFileContent.Helm.Parameters = append(overrideFileContent.Helm.Parameters, updater.ArgoParameterOverride{
+			Name:        parameter.Name,
+			Value:       parameter.Value,
+			ForceString: parameter.ForceString,
+		})
+	}
+
 	return &overrideFileContent, nil
 }
 
+// writeBackRepo returns the repository the write-back of task goes to: the GitOps
+// repository of the application, or for a chart version task the one holding the
+// application's manifest (see models.NewChartManifest).
+func writeBackRepo(app *models.Application, task *models.Task, repoCachePath string) (models.GitopsRepo, error) {
+	if task.ChartVersion == "" {
+		return models.NewGitopsRepo(app, repoCachePath)
+	}
+	manifest, err := models.NewChartManifest(app, repoCachePath)
+	return manifest.Repo, err
+}
+
+// writeBackContent builds what the write-back of task changes in the repository
+// writeBackRepo returns. A chart version task sets the version in the application's
+// manifest, in place like a tag kept in a values file; any other gets the override file
+// of generateOverrideFileContent.
+func writeBackContent(app *models.Application, task *models.Task) (*updater.ArgoOverrideFile, error) {
+	if task.ChartVersion == "" {
+		return generateOverrideFileContent(app.Metadata.Annotations, task)
+	}
+	manifest, err := models.NewChartManifest(app, "")
+	if err != nil {
+		return nil, err
+	}
+	return &updater.ArgoOverrideFile{
+		ValuesFiles: []updater.ValuesFileUpdate{{
+			File:  manifest.File,
+			Path:  manifest.RevisionPath,
+			Value: task.ChartVersion,
+		}},
+	}, nil
+}
+
+// allowedParametersAnnotation lists the parameters a task may write back to the
+// application.
+const allowedParametersAnnotation = "argo-watcher/allowed-parameters"
+
+// checkAllowedParameters fails unless the application allows every one of parameters
+// in its argo-watcher/allowed-parameters annotation: a comma-separated list of
+// parameter names, where "*" matches any run of characters but "/". Without the
+// annotation a task may write no parameter at all, so CI cannot set values the
+// application's owners did not open up.
+func checkAllowedParameters(annotations map[string]string, parameters []models.Parameter) error {
+	var allowed []string
+	for _, pattern := range strings.Split(annotations[allowedParametersAnnotation], ",") {
+		if pattern = strings.TrimSpace(pattern); pattern != "" {
+			allowed = append(allowed, pattern)
+		}
+	}
+
+	var denied []string
+	for _, parameter := range parameters {
+		if !parameterAllowed(allowed, parameter.Name) {
+			denied = append(denied, parameter.Name)
+		}
+	}
+	if len(denied) == 0 {
+		return nil
+	}
+	if len(allowed) == 0 {
+		return fmt.Errorf("the task carries parameters (%s), but the application allows none in its %s annotation", strings.Join(denied, ", "), allowedParametersAnnotation)
+	}
+	return fmt.Errorf("parameters %s are not allowed by the application's %s annotation (%s)", strings.Join(denied, ", "), allowedParametersAnnotation, strings.Join(allowed, ", "))
+}
+
+// parameterAllowed reports whether name matches one of the allowed patterns. A
+// malformed pattern matches nothing.
+func parameterAllowed(allowed []string, name string) bool {
+	for _, pattern := range allowed {
+		if matched, err := path.Match(pattern, name); err == nil && matched {
+			return true
+		}
+	}
+	return false
+}
+
+// kustomizeImageOverride builds the Kustomize images entry setting the tag of a managed
+// image. The entry overrides the image named in the alias's kustomize.image-name
+// annotation, replacing it with the managed image, or else the managed image itself. An
+// image pinned to a digest, or a tag in digest form ("sha256:..."), pins the digest
+// instead, as Kustomize sets either a tag or a digest.
+func kustomizeImageOverride(annotations map[string]string, appAlias, appImage string, image models.Image) updater.KustomizeImageOverride {
+	override := updater.KustomizeImageOverride{Name: appImage}
+	if name := strings.TrimSpace(annotations[fmt.Sprintf(managedImageNamePattern, appAlias)]); name != "" && name != appImage {
+		override.Name = name
+		override.NewName = appImage
+	}
+
+	switch {
+	case image.Digest != "":
+		override.Digest = image.Digest
+	case strings.HasPrefix(image.Tag, kustomizeImageDigestPrefix):
+		override.Digest = image.Tag
+	default:
+		override.NewTag = image.Tag
+	}
+	return override
+}
+
 // UpdateGitImageTag writes the new image tag for app into the GitOps repository.
 // gitHandler is injected to enable testing; production callers pass updater.GitClient{}.
 //
@@ -72,33 +221,97 @@ func generateOverrideFileContent(annotations map[string]string, task *models.Tas
 // write-back attempt; when it returns true the loop aborts with
 // ErrDeploymentSuperseded instead of committing.
 func UpdateGitImageTag(ctx context.Context, app *models.Application, task *models.Task, gitopsRepo *models.GitopsRepo, gitHandler updater.GitHandler, isSuperseded ...func() bool) error {
-	if gitopsRepo.Path == "" {
-		slog.Warn("No path found for app, unsupported Application configuration", "app", app.Metadata.Name, "id", task.Id)
-		return nil
+	repo, releaseOverrides, err := prepareGitWriteBack(app, task, gitopsRepo, gitHandler)
+	if err != nil || repo == nil {
+		return err
 	}
 
-	var supersededCheck func() bool
-	if len(isSuperseded) > 0 {
-		supersededCheck = isSuperseded[0]
+	err = runGitUpdateWithRetry(ctx, repo, task, firstPredicate(isSuperseded), func(ctx context.Context) error {
+		return repo.UpdateApp(ctx, app.Metadata.Name, releaseOverrides, task)
+	})
+	if err == nil {
+		noteWriteBack(task, repo)
 	}
+	return err
+}
 
-	releaseOverrides, err := generateOverrideFileContent(app.Metadata.Annotations, task)
+// ProposeGitImageTag is UpdateGitImageTag for the pull-request write-back method: the
+// commit is pushed to a branch of the task's own and a pull request is opened through
+// provider to merge it into the application's branch. It returns nil (no error) when
+// there was nothing to write back, as the repository already holds the tags.
+func ProposeGitImageTag(ctx context.Context, app *models.Application, task *models.Task, gitopsRepo *models.GitopsRepo, gitHandler updater.GitHandler, provider updater.PullRequestProvider, isSuperseded ...func() bool) (*updater.PullRequest, error) {
+	repo, releaseOverrides, err := prepareGitWriteBack(app, task, gitopsRepo, gitHandler)
+	if err != nil || repo == nil {
+		return nil, err
+	}
+
+	branch := pullRequestBranch(app.Metadata.Name, task.Id)
+	proposed := false
+	err = runGitUpdateWithRetry(ctx, repo, task, firstPredicate(isSuperseded), func(ctx context.Context) error {
+		var proposeErr error
+		proposed, proposeErr = repo.ProposeApp(ctx, app.Metadata.Name, branch, releaseOverrides, task)
+		return proposeErr
+	})
+	if err != nil || !proposed {
+		return nil, err
+	}
+	noteWriteBack(task, repo)
+
+	openCtx, cancel := context.WithTimeout(ctx, repo.GitOpTimeout())
+	defer cancel()
+
+	pr, err := provider.Open(openCtx, gitopsRepo.RepoUrl, branch, gitopsRepo.BranchName, pullRequestTitle(app.Metadata.Name, task), pullRequestBody(task))
 	if err != nil {
-		return err
+		return nil, fmt.Errorf("failed to open a pull request for branch %s: %w", branch, err)
+	}
+
+	slog.Info("Opened a pull request for the write-back", "app", app.Metadata.Name, "url", pr.URL, "id", task.Id)
+	return pr, nil
+}
+
+// prepareGitWriteBack works out the override content of a write-back and the repository
+// it goes to. It returns a nil repository (and no error) when there is nothing to write.
+func prepareGitWriteBack(app *models.Application, task *models.Task, gitopsRepo *models.GitopsRepo, gitHandler updater.GitHandler) (*updater.GitRepo, *updater.ArgoOverrideFile, error) {
+	if gitopsRepo.Path == "" {
+		slog.Warn("No path found for app, unsupported Application configuration", "app", app.Metadata.Name, "id", task.Id)
+		return nil, nil, nil
+	}
+
+	releaseOverrides, err := writeBackContent(app, task)
+	if err != nil {
+		return nil, nil, err
 	}
 
 	if releaseOverrides == nil {
 		slog.Warn("No release overrides found for app", "app", app.Metadata.Name, "id", task.Id)
-		return nil
+		return nil, nil, nil
 	}
 
 	repo, err := updater.NewGitRepo(gitopsRepo.RepoUrl, gitopsRepo.BranchName, gitopsRepo.Path, gitopsRepo.Filename, gitopsRepo.RepoCachePath, gitHandler)
 	if err != nil {
 		slog.Error("Failed to create git repo instance", "url", gitopsRepo.RepoUrl, "error", err, "id", task.Id)
-		return err
+		return nil, nil, err
 	}
 
-	return runGitUpdateWithRetry(ctx, repo, app.Metadata.Name, releaseOverrides, task, supersededCheck)
+	return repo, releaseOverrides, nil
+}
+
+// noteWriteBack records in task.WriteBack the commit the latest write-back to repo
+// made. A write-back that found the tags already in place made none, and leaves the
+// task as it is.
+func noteWriteBack(task *models.Task, repo *updater.GitRepo) {
+	if repo.LastCommit().IsZero() {
+		return
+	}
+	task.WriteBack = &models.WriteBack{CommitSha: repo.LastCommit().String(), SigningKey: repo.SigningKey()}
+}
+
+// firstPredicate returns the optional predicate of a variadic parameter, or nil.
+func firstPredicate(predicates []func() bool) func() bool {
+	if len(predicates) > 0 {
+		return predicates[0]
+	}
+	return nil
 }
 
 // Retry backoff bounds for the clone+update sequence. Backoff is capped
@@ -138,7 +351,10 @@ func gitUpdateBackoff(attempt uint) time.Duration {
 //
 // Permanent errors (see updater.IsPermanent) short-circuit the loop — a bad SSH key
 // or auth failure fails the same way on every attempt.
-func runGitUpdateWithRetry(parentCtx context.Context, repo *updater.GitRepo, appName string, releaseOverrides *updater.ArgoOverrideFile, task *models.Task, isSuperseded func() bool) error {
+//
+// update makes the attempt's change on the freshly cloned repo, bounded by the
+// attempt's context.
+func runGitUpdateWithRetry(parentCtx context.Context, repo *updater.GitRepo, task *models.Task, isSuperseded func() bool, update func(ctx context.Context) error) error {
 	maxAttempts := repo.GitMaxAttempts()
 	opTimeout := repo.GitOpTimeout()
 
@@ -151,7 +367,7 @@ func runGitUpdateWithRetry(parentCtx context.Context, repo *updater.GitRepo, app
 
 		invalidateCacheOnFinalAttempt(repo, task, attempt, maxAttempts)
 
-		err := runGitUpdateAttempt(parentCtx, repo, opTimeout, appName, releaseOverrides, task)
+		err := runGitUpdateAttempt(parentCtx, repo, opTimeout, update)
 		if err == nil {
 			if attempt > 1 {
 				slog.Info("Git update succeeded", "attempt", attempt, "max_attempts", maxAttempts, "id", task.Id)
@@ -198,7 +414,7 @@ func backoffBeforeRetry(parentCtx context.Context, task *models.Task, attemptErr
 }
 
 // runGitUpdateAttempt performs one clone+update cycle, bounded by opTimeout.
-func runGitUpdateAttempt(parentCtx context.Context, repo *updater.GitRepo, opTimeout time.Duration, appName string, releaseOverrides *updater.ArgoOverrideFile, task *models.Task) error {
+func runGitUpdateAttempt(parentCtx context.Context, repo *updater.GitRepo, opTimeout time.Duration, update func(ctx context.Context) error) error {
 	ctx, cancel := context.WithTimeout(parentCtx, opTimeout)
 	defer cancel()
 
@@ -206,7 +422,7 @@ func runGitUpdateAttempt(parentCtx context.Context, repo *updater.GitRepo, opTim
 		return fmt.Errorf("clone failed: %w", err)
 	}
 
-	if err := repo.UpdateApp(ctx, appName, releaseOverrides, task); err != nil {
+	if err := update(ctx); err != nil {
 		return fmt.Errorf("update failed: %w", err)
 	}
 
@@ -173,7 +173,7 @@ func applyRequest(repo *updater.GitRepo, req *batchWriteRequest, outcomes, commi
 		outcomes[req] = nil
 		return false
 	}
-	content, genErr := generateOverrideFileContent(req.app.Metadata.Annotations, req.task)
+	content, genErr := writeBackContent(req.app, req.task)
 	if genErr != nil {
 		// A per-app misconfiguration is permanent; resolve it terminally so it does
 		// not poison the rest of the batch.
@@ -201,6 +201,9 @@ func applyRequest(repo *updater.GitRepo, req *batchWriteRequest, outcomes, commi
 		return false
 	}
 	delete(commitErrs, req) // committed cleanly; drop any earlier recorded error
+	// Noted now, while the clone's last commit is this app's. The task only reads it
+	// once the batch resolved the request, and a retry re-applying it notes it again.
+	noteWriteBack(req.task, repo)
 	return true
 }
 
@@ -221,6 +221,197 @@ func TestGenerateOverrideFileContent(t *testing.T) {
 		require.NotNil(t, override)
 		assert.Empty(t, override.Helm.Parameters)
 	})
+
+	t.Run("Builds Kustomize images in kustomize mode", func(t *testing.T) {
+		app := &models.Application{
+			Metadata: models.ApplicationMetadata{
+				Name: "app",
+				Annotations: map[string]string{
+					"argo-watcher/managed-images":         "a=example.com/app,b=example.com/worker",
+					"argo-watcher/write-back-mode":        "kustomize",
+					"argo-watcher/b.kustomize.image-name": "worker",
+					"argo-watcher/a.kustomize.image-name": "example.com/app",
+				},
+			},
+		}
+		task := &models.Task{Id: "test-id", Images: []models.Image{
+			{Image: "example.com/app", Tag: "v1"},
+			{Image: "example.com/worker", Tag: "sha256:abc"},
+		}}
+
+		override, err := generateOverrideFileContent(app.Metadata.Annotations, task)
+
+		require.NoError(t, err)
+		require.NotNil(t, override)
+		assert.Empty(t, override.Helm.Parameters)
+		assert.Equal(t, []updater.KustomizeImageOverride{
+			{Name: "example.com/app", NewTag: "v1"},
+			{Name: "worker", NewName: "example.com/worker", Digest: "sha256:abc"},
+		}, override.Kustomize.Images)
+	})
+
+	t.Run("Sets the tag in the values file an alias names", func(t *testing.T) {
+		app := newAppWithImages("app")
+		app.Metadata.Annotations["argo-watcher/app.helm.values-file"] = "values-prod.yaml"
+
+		override, err := generateOverrideFileContent(app.Metadata.Annotations, newImageTask())
+
+		require.NoError(t, err)
+		require.NotNil(t, override)
+		assert.Empty(t, override.Helm.Parameters)
+		assert.Equal(t, []updater.ValuesFileUpdate{{File: "values-prod.yaml", Path: "image.tag", Value: "v1.0.0"}}, override.ValuesFiles)
+	})
+
+	t.Run("Writes a pinned digest next to the tag", func(t *testing.T) {
+		app := newAppWithImages("app")
+		task := newImageTask()
+		task.Images[0].Digest = "sha256:abc"
+
+		override, err := generateOverrideFileContent(app.Metadata.Annotations, task)
+		require.NoError(t, err)
+		assert.Equal(t, "v1.0.0@sha256:abc", override.Helm.Parameters[0].Value)
+
+		app.Metadata.Annotations["argo-watcher/app.helm.values-file"] = "values-prod.yaml"
+		override, err = generateOverrideFileContent(app.Metadata.Annotations, task)
+		require.NoError(t, err)
+		assert.Equal(t, "v1.0.0@sha256:abc", override.ValuesFiles[0].Value)
+	})
+
+	t.Run("Writes a pinned digest instead of the tag in kustomize mode", func(t *testing.T) {
+		app := newAppWithImages("app")
+		app.Metadata.Annotations["argo-watcher/write-back-mode"] = "kustomize"
+		task := newImageTask()
+		task.Images[0].Digest = "sha256:abc"
+
+		override, err := generateOverrideFileContent(app.Metadata.Annotations, task)
+
+		require.NoError(t, err)
+		assert.Equal(t, []updater.KustomizeImageOverride{{Name: "myimage", Digest: "sha256:abc"}}, override.Kustomize.Images)
+	})
+
+	t.Run("Errors on an unknown write-back mode", func(t *testing.T) {
+		app := newAppWithImages("app")
+		app.Metadata.Annotations["argo-watcher/write-back-mode"] = "jsonnet"
+
+		override, err := generateOverrideFileContent(app.Metadata.Annotations, newImageTask())
+
+		require.Error(t, err)
+		assert.Nil(t, override)
+		assert.Contains(t, err.Error(), `unsupported argo-watcher/write-back-mode "jsonnet"`)
+	})
+}
+
+func TestGenerateOverrideFileContentParameters(t *testing.T) {
+	parameters := []models.Parameter{
+		{Name: "features.checkout", Value: "true"},
+		{Name: "config.version", Value: "7", ForceString: true},
+	}
+	taskWithParameters := func() *models.Task {
+		task := newImageTask()
+		task.Parameters = parameters
+		return task
+	}
+
+	t.Run("Writes allowed parameters next to the image tag", func(t *testing.T) {
+		app := newAppWithImages("app")
+		app.Metadata.Annotations["argo-watcher/allowed-parameters"] = "features.*, config.version"
+
+		override, err := generateOverrideFileContent(app.Metadata.Annotations, taskWithParameters())
+
+		require.NoError(t, err)
+		assert.Equal(t, []updater.ArgoParameterOverride{
+			{Name: "image.tag", Value: "v1.0.0", ForceString: true},
+			{Name: "features.checkout", Value: "true"},
+			{Name: "config.version", Value: "7", ForceString: true},
+		}, override.Helm.Parameters)
+	})
+
+	t.Run("Writes parameters of an application without managed images", func(t *testing.T) {
+		annotations := map[string]string{"argo-watcher/allowed-parameters": "*"}
+
+		override, err := generateOverrideFileContent(annotations, taskWithParameters())
+
+		require.NoError(t, err)
+		require.NotNil(t, override)
+		assert.Len(t, override.Helm.Parameters, 2)
+	})
+
+	t.Run("Rejects a parameter the application does not allow", func(t *testing.T) {
+		app := newAppWithImages("app")
+		app.Metadata.Annotations["argo-watcher/allowed-parameters"] = "features.*"
+
+		override, err := generateOverrideFileContent(app.Metadata.Annotations, taskWithParameters())
+
+		require.Error(t, err)
+		assert.Nil(t, override)
+		assert.Contains(t, err.Error(), "parameters config.version are not allowed")
+	})
+
+	t.Run("Rejects every parameter without the annotation", func(t *testing.T) {
+		app := newAppWithImages("app")
+
+		_, err := generateOverrideFileContent(app.Metadata.Annotations, taskWithParameters())
+
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "allows none in its argo-watcher/allowed-parameters annotation")
+	})
+
+	t.Run("Rejects parameters in kustomize mode", func(t *testing.T) {
+		app := newAppWithImages("app")
+		app.Metadata.Annotations["argo-watcher/write-back-mode"] = "kustomize"
+		app.Metadata.Annotations["argo-watcher/allowed-parameters"] = "*"
+
+		_, err := generateOverrideFileContent(app.Metadata.Annotations, taskWithParameters())
+
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "kustomize")
+	})
+}
+
+func TestWriteBackContentChartVersion(t *testing.T) {
+	chartTask := &models.Task{App: "app", ChartVersion: "1.4.0"}
+	newChartApp := func() *models.Application {
+		app := &models.Application{}
+		app.Metadata.Name = "app"
+		app.Metadata.Annotations = map[string]string{
+			"argo-watcher/chart.manifest-repo":   "git@example.com:gitops.git",
+			"argo-watcher/chart.manifest-branch": "main",
+			"argo-watcher/chart.manifest-file":   "apps/app.yaml",
+		}
+		app.Spec.Source.Chart = "web"
+		return app
+	}
+
+	t.Run("Sets the version in the application manifest", func(t *testing.T) {
+		app := newChartApp()
+
+		repo, err := writeBackRepo(app, chartTask, "/tmp/cache")
+		require.NoError(t, err)
+		assert.Equal(t, models.GitopsRepo{
+			RepoUrl:       "git@example.com:gitops.git",
+			BranchName:    "main",
+			Path:          "apps",
+			RepoCachePath: "/tmp/cache",
+		}, repo)
+
+		override, err := writeBackContent(app, chartTask)
+		require.NoError(t, err)
+		assert.Equal(t, &updater.ArgoOverrideFile{
+			ValuesFiles: []updater.ValuesFileUpdate{{File: "app.yaml", Path: "spec.source.targetRevision", Value: "1.4.0"}},
+		}, override)
+	})
+
+	t.Run("Errors without the chart annotations", func(t *testing.T) {
+		app := newChartApp()
+		delete(app.Metadata.Annotations, "argo-watcher/chart.manifest-file")
+
+		_, err := writeBackRepo(app, chartTask, "/tmp/cache")
+		assert.ErrorContains(t, err, "missing annotation(s) argo-watcher/chart.manifest-file")
+
+		override, err := writeBackContent(app, chartTask)
+		assert.ErrorContains(t, err, "missing annotation(s) argo-watcher/chart.manifest-file")
+		assert.Nil(t, override)
+	})
 }
 
 func TestUpdateGitImageTag(t *testing.T) {
@@ -412,10 +603,11 @@ func TestUpdateGitImageTag(t *testing.T) {
 		// regardless of default changes: attempt 1 uses PlainClone, attempt 2 uses
 		// PlainOpen (cache hit), succeeds, loop exits.
 		t.Setenv("GIT_MAX_ATTEMPTS", "3")
+		task := newImageTask()
 		err = UpdateGitImageTag(
 			context.Background(),
 			newAppWithImages("test-app"),
-			newImageTask(),
+			task,
 			&models.GitopsRepo{
 				RepoUrl:       repoURL,
 				BranchName:    branchName,
@@ -424,7 +616,16 @@ func TestUpdateGitImageTag(t *testing.T) {
 			},
 			mockHandler,
 		)
-		assert.NoError(t, err)
+		require.NoError(t, err)
+
+		// The task notes the commit that won the race, not the orphan of the first attempt.
+		remote, err := gogit.PlainOpen(remotePath)
+		require.NoError(t, err)
+		head, err := remote.Head()
+		require.NoError(t, err)
+		require.NotNil(t, task.WriteBack)
+		assert.Equal(t, head.Hash().String(), task.WriteBack.CommitSha)
+		assert.Empty(t, task.WriteBack.SigningKey)
 	})
 
 	t.Run("Permanent SSH key error short-circuits retries", func(t *testing.T) {
@@ -0,0 +1,53 @@
+//go:build integration
+
+package argocd
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/shini4i/argo-watcher/internal/models"
+	"github.com/shini4i/argo-watcher/internal/updater"
+)
+
+// TestIntegration_UntrustedHostKeyIsPermanent runs a write-back through the
+// production GitClient against a Gitea whose host key is in no known_hosts file: it
+// must fail on the first attempt with a HostKeyError naming the host.
+func TestIntegration_UntrustedHostKeyIsPermanent(t *testing.T) {
+	waitForGitea(t, 60*time.Second)
+	env := setupGitea(t)
+
+	knownHosts := filepath.Join(t.TempDir(), "known_hosts")
+	require.NoError(t, os.WriteFile(knownHosts, []byte("\n"), 0600))
+
+	t.Setenv("SSH_KEY_PATH", env.SSHKeyPath)
+	t.Setenv("SSH_KNOWN_HOSTS", knownHosts)
+	t.Setenv("GIT_OP_TIMEOUT", "30s")
+	t.Setenv("GIT_MAX_ATTEMPTS", "3")
+
+	gitopsRepo := &models.GitopsRepo{
+		RepoUrl:       env.DirectRepoURL,
+		BranchName:    "master",
+		Path:          "apps",
+		RepoCachePath: t.TempDir(),
+	}
+	err := UpdateGitImageTag(
+		context.Background(),
+		newAppWithImages("host-key-app"),
+		&models.Task{Id: "task-host-key", Images: []models.Image{{Image: "myimage", Tag: "v1"}}},
+		gitopsRepo,
+		updater.GitClient{},
+	)
+
+	var hostKeyErr *updater.HostKeyError
+	require.ErrorAs(t, err, &hostKeyErr)
+	assert.False(t, hostKeyErr.Mismatch)
+	assert.Contains(t, hostKeyErr.Host, giteaSSHPort)
+	assert.NotContains(t, err.Error(), "after 3 attempts", "an untrusted host key is not retried")
+}
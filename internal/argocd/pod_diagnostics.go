@@ -0,0 +1,146 @@
+package argocd
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"slices"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/shini4i/argo-watcher/internal/helpers"
+	"github.com/shini4i/argo-watcher/internal/models"
+)
+
+const (
+	// podDiagnosticsTimeout bounds every events and logs request made for one failure
+	// together, so enriching the reason can never hold terminal status reporting for long.
+	podDiagnosticsTimeout = 15 * time.Second
+	// maxDiagnosedPods caps the pods whose events and logs are fetched. Replicas of one
+	// workload usually fail for the same reason, so the first few tell the story.
+	maxDiagnosedPods = 3
+	// maxPodEvents caps the events reported per pod; the most recent ones are kept.
+	maxPodEvents = 5
+	// maxDiagnosticLineLength caps a single event message or log line, so one stack dump
+	// on a single line cannot take the whole excerpt.
+	maxDiagnosticLineLength = 300
+	// maxPodDiagnosticsLength caps the whole excerpt, which is stored with the task and
+	// sent in every failure notification.
+	maxPodDiagnosticsLength = 4000
+)
+
+// collectPodDiagnostics best-effort fetches the recent Kubernetes events and the log tail
+// of the unhealthy pods in tree, and renders them as an excerpt for the failure reason.
+// Every line is redacted, since pod logs leave the cluster with it, and the excerpt is
+// bounded. Any request that fails only leaves its part out, and an empty string is
+// returned when nothing could be collected.
+func (monitor *DeploymentMonitor) collectPodDiagnostics(app string, tree *models.ApplicationTree) string {
+	if tree == nil {
+		return ""
+	}
+
+	pods := tree.ListProblemPods()
+	if len(pods) > maxDiagnosedPods {
+		pods = pods[:maxDiagnosedPods]
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), podDiagnosticsTimeout)
+	defer cancel()
+
+	var sections []string
+	for _, pod := range pods {
+		if events := monitor.podEventLines(ctx, app, pod); len(events) > 0 {
+			sections = append(sections, fmt.Sprintf("Recent events of Pod(%s):\n\t%s", pod.Name, strings.Join(events, "\n\t")))
+		}
+		if heading, lines := monitor.podLogLines(ctx, app, pod); len(lines) > 0 {
+			sections = append(sections, heading+"\n\t"+strings.Join(lines, "\n\t"))
+		}
+	}
+
+	return truncateDiagnostics(strings.Join(sections, "\n\n"))
+}
+
+// podEventLines returns the most recent events of pod, oldest first.
+func (monitor *DeploymentMonitor) podEventLines(ctx context.Context, app string, pod models.ApplicationTreeNode) []string {
+	events, err := monitor.argo.api.GetResourceEvents(ctx, app, pod)
+	if err != nil {
+		slog.Debug("Could not fetch pod events for failure diagnostics", "error", err, "app", app, "pod", pod.Name)
+		return nil
+	}
+
+	slices.SortStableFunc(events, func(a, b models.KubernetesEvent) int {
+		return a.OccurredAt().Compare(b.OccurredAt())
+	})
+	if len(events) > maxPodEvents {
+		events = events[len(events)-maxPodEvents:]
+	}
+
+	lines := make([]string, 0, len(events))
+	for _, event := range events {
+		line := fmt.Sprintf("%s %s", event.Type, event.Reason)
+		if event.Count > 1 {
+			line += fmt.Sprintf(" (x%d)", event.Count)
+		}
+		lines = append(lines, diagnosticLine(line+": "+event.Message))
+	}
+	return lines
+}
+
+// podLogLines returns the heading and the lines of the log tail of pod. A crash-looping
+// pod is read from its previous container instance, which holds the crash; should it have
+// none, the current one is read instead.
+func (monitor *DeploymentMonitor) podLogLines(ctx context.Context, app string, pod models.ApplicationTreeNode) (string, []string) {
+	if monitor.failureLogLines == 0 {
+		return "", nil
+	}
+
+	previous := pod.IsCrashLooping()
+	logs, err := monitor.argo.api.GetPodLogs(ctx, app, pod, monitor.failureLogLines, previous)
+	if err != nil && previous {
+		previous = false
+		logs, err = monitor.argo.api.GetPodLogs(ctx, app, pod, monitor.failureLogLines, previous)
+	}
+	if err != nil {
+		slog.Debug("Could not fetch pod logs for failure diagnostics", "error", err, "app", app, "pod", pod.Name)
+		return "", nil
+	}
+
+	heading := fmt.Sprintf("Last log lines of Pod(%s):", pod.Name)
+	if previous {
+		heading = fmt.Sprintf("Last log lines of Pod(%s), previous container:", pod.Name)
+	}
+
+	lines := make([]string, 0, len(logs))
+	for _, line := range logs {
+		lines = append(lines, diagnosticLine(strings.TrimRight(line, "\r\n")))
+	}
+	return heading, lines
+}
+
+// diagnosticLine redacts the secrets in an event message or log line, then shortens it to
+// maxDiagnosticLineLength bytes. Redacting first keeps a cut from hiding half a secret
+// from the patterns.
+func diagnosticLine(line string) string {
+	return truncateUTF8(helpers.RedactSecrets(line), maxDiagnosticLineLength, "…")
+}
+
+// truncateDiagnostics shortens the excerpt to maxPodDiagnosticsLength bytes, marking that
+// the rest was left out.
+func truncateDiagnostics(excerpt string) string {
+	return truncateUTF8(excerpt, maxPodDiagnosticsLength, "\n\t… (truncated)")
+}
+
+// truncateUTF8 shortens text to at most limit bytes including marker, cutting on a
+// character boundary, and leaves text that fits as it is.
+func truncateUTF8(text string, limit int, marker string) string {
+	if len(text) <= limit {
+		return text
+	}
+
+	cut := limit - len(marker)
+	for cut > 0 && !utf8.RuneStart(text[cut]) {
+		cut--
+	}
+	return text[:cut] + marker
+}
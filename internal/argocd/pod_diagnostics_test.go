@@ -0,0 +1,173 @@
+package argocd
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+	"unicode/utf8"
+
+	"github.com/avast/retry-go/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
+
+	"github.com/shini4i/argo-watcher/internal/mocks"
+	"github.com/shini4i/argo-watcher/internal/models"
+)
+
+func newDiagnosticsMonitor(api ArgoApiInterface, logLines uint) *DeploymentMonitor {
+	monitor := NewDeploymentMonitor(Argo{api: api}, "", []retry.Option{retry.DelayType(zeroDelay)}, false, time.Millisecond)
+	monitor.failureLogLines = logLines
+	return monitor
+}
+
+func problemPod(name, message string) models.ApplicationTreeNode {
+	pod := models.ApplicationTreeNode{Kind: "Pod", Name: name, Namespace: "demo"}
+	pod.Health.Status = "Degraded"
+	pod.Health.Message = message
+	return pod
+}
+
+func TestCollectPodDiagnostics(t *testing.T) {
+	at := func(minute int) time.Time { return time.Date(2026, 1, 1, 0, minute, 0, 0, time.UTC) }
+
+	t.Run("Quotes the events and the previous container's logs of a crash-looping pod", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		api := mocks.NewMockArgoApiInterface(ctrl)
+		pod := problemPod("demo-abc", "back-off 5m0s restarting failed container=app")
+
+		api.EXPECT().GetResourceEvents(gomock.Any(), "demo", pod).Return([]models.KubernetesEvent{
+			{Type: "Warning", Reason: "BackOff", Message: "Back-off restarting failed container app", Count: 12, LastTimestamp: at(2)},
+			{Type: "Normal", Reason: "Pulled", Message: "Container image already present", Count: 1, LastTimestamp: at(1)},
+		}, nil)
+		api.EXPECT().GetPodLogs(gomock.Any(), "demo", pod, uint(20), true).
+			Return([]string{"connecting to postgres://app:hunter2@db:5432/app", "panic: boom\n"}, nil)
+
+		excerpt := newDiagnosticsMonitor(api, 20).collectPodDiagnostics("demo", &models.ApplicationTree{Nodes: []models.ApplicationTreeNode{pod}})
+
+		assert.Equal(t, "Recent events of Pod(demo-abc):\n"+
+			"\tNormal Pulled: Container image already present\n"+
+			"\tWarning BackOff (x12): Back-off restarting failed container app\n\n"+
+			"Last log lines of Pod(demo-abc), previous container:\n"+
+			"\tconnecting to postgres://app:<redacted>@db:5432/app\n"+
+			"\tpanic: boom", excerpt)
+	})
+
+	t.Run("Falls back to the current container when there is no previous one", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		api := mocks.NewMockArgoApiInterface(ctrl)
+		pod := problemPod("demo-abc", "CrashLoopBackOff")
+
+		api.EXPECT().GetResourceEvents(gomock.Any(), "demo", pod).Return(nil, nil)
+		gomock.InOrder(
+			api.EXPECT().GetPodLogs(gomock.Any(), "demo", pod, uint(5), true).Return(nil, errors.New("previous terminated container not found")),
+			api.EXPECT().GetPodLogs(gomock.Any(), "demo", pod, uint(5), false).Return([]string{"waiting for config"}, nil),
+		)
+
+		excerpt := newDiagnosticsMonitor(api, 5).collectPodDiagnostics("demo", &models.ApplicationTree{Nodes: []models.ApplicationTreeNode{pod}})
+
+		assert.Equal(t, "Last log lines of Pod(demo-abc):\n\twaiting for config", excerpt)
+	})
+
+	t.Run("Keeps the most recent events", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		api := mocks.NewMockArgoApiInterface(ctrl)
+		pod := problemPod("demo-abc", `Back-off pulling image "demo:v2"`)
+
+		var events []models.KubernetesEvent
+		for minute := 10; minute > 0; minute-- {
+			events = append(events, models.KubernetesEvent{Type: "Warning", Reason: fmt.Sprintf("E%d", minute), EventTime: at(minute)})
+		}
+		api.EXPECT().GetResourceEvents(gomock.Any(), "demo", pod).Return(events, nil)
+
+		excerpt := newDiagnosticsMonitor(api, 0).collectPodDiagnostics("demo", &models.ApplicationTree{Nodes: []models.ApplicationTreeNode{pod}})
+
+		assert.Equal(t, "Recent events of Pod(demo-abc):\n\tWarning E6: \n\tWarning E7: \n\tWarning E8: \n\tWarning E9: \n\tWarning E10: ", excerpt)
+	})
+
+	t.Run("Diagnoses only the first unhealthy pods", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		api := mocks.NewMockArgoApiInterface(ctrl)
+
+		healthy := models.ApplicationTreeNode{Kind: "Pod", Name: "demo-ok"}
+		healthy.Health.Status = "Healthy"
+		deployment := models.ApplicationTreeNode{Group: "apps", Kind: "Deployment", Name: "demo"}
+		deployment.Health.Status = "Degraded"
+		nodes := []models.ApplicationTreeNode{healthy, deployment}
+		for index := range maxDiagnosedPods + 2 {
+			nodes = append(nodes, problemPod(fmt.Sprintf("demo-%d", index), "ErrImagePull"))
+		}
+
+		api.EXPECT().GetResourceEvents(gomock.Any(), "demo", gomock.Any()).Return(nil, errors.New("forbidden")).Times(maxDiagnosedPods)
+
+		excerpt := newDiagnosticsMonitor(api, 0).collectPodDiagnostics("demo", &models.ApplicationTree{Nodes: nodes})
+
+		assert.Empty(t, excerpt, "failed requests leave their part out")
+	})
+
+	t.Run("Bounds lines and the whole excerpt", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		api := mocks.NewMockArgoApiInterface(ctrl)
+		pod := problemPod("demo-abc", "ErrImagePull")
+
+		api.EXPECT().GetResourceEvents(gomock.Any(), "demo", pod).Return(nil, nil)
+		lines := make([]string, 100)
+		for index := range lines {
+			lines[index] = strings.Repeat("é", maxDiagnosticLineLength)
+		}
+		api.EXPECT().GetPodLogs(gomock.Any(), "demo", pod, uint(100), false).Return(lines, nil)
+
+		excerpt := newDiagnosticsMonitor(api, 100).collectPodDiagnostics("demo", &models.ApplicationTree{Nodes: []models.ApplicationTreeNode{pod}})
+
+		assert.LessOrEqual(t, len(excerpt), maxPodDiagnosticsLength)
+		assert.True(t, strings.HasSuffix(excerpt, "\n\t… (truncated)"))
+		for _, line := range strings.Split(excerpt, "\n") {
+			assert.LessOrEqual(t, len(line), maxDiagnosticLineLength+1, "a line is cut to the limit, plus its tab")
+			assert.True(t, utf8.ValidString(line), "a cut must not split a character")
+		}
+	})
+
+	t.Run("Nothing without a resource tree", func(t *testing.T) {
+		api := mocks.NewMockArgoApiInterface(gomock.NewController(t))
+
+		assert.Empty(t, newDiagnosticsMonitor(api, 20).collectPodDiagnostics("demo", nil))
+	})
+}
+
+func TestHandleDeploymentFailureQuotesPodDiagnostics(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	metrics := mocks.NewMockMetricsInterface(ctrl)
+	state := newTaskRepositoryMock(ctrl)
+	api := mocks.NewMockArgoApiInterface(ctrl)
+
+	pod := problemPod("demo-abc", "back-off 5m0s restarting failed container=app")
+	api.EXPECT().GetResourceTree(gomock.Any(), "demo").Return(&models.ApplicationTree{Nodes: []models.ApplicationTreeNode{pod}}, nil)
+	api.EXPECT().GetResourceEvents(gomock.Any(), "demo", pod).
+		Return([]models.KubernetesEvent{{Type: "Warning", Reason: "BackOff", Message: "Back-off restarting failed container app"}}, nil)
+	api.EXPECT().GetPodLogs(gomock.Any(), "demo", pod, uint(20), true).Return([]string{"panic: boom"}, nil)
+
+	monitor := NewDeploymentMonitor(Argo{metrics: metrics, State: state, api: api}, "", nil, false, time.Millisecond)
+	monitor.failureLogLines = 20
+
+	var storedReason string
+	metrics.EXPECT().AddFailedDeployment("demo")
+	state.EXPECT().SetTaskStatus("task-id", models.StatusFailedMessage, gomock.Any()).
+		DoAndReturn(func(_ string, _ string, reason string) error {
+			storedReason = reason
+			return nil
+		})
+
+	task := models.Task{Id: "task-id", App: "demo", Images: []models.Image{{Image: "example.com/app", Tag: "v2"}}}
+	application := &models.Application{}
+	application.Status.Health.Status = "Degraded"
+
+	monitor.handleDeploymentFailure(&task, models.ArgoRolloutAppDegraded, application, 0)
+
+	require.Contains(t, storedReason, "Recent events of Pod(demo-abc):\n\tWarning BackOff: Back-off restarting failed container app")
+	assert.Contains(t, storedReason, "Last log lines of Pod(demo-abc), previous container:\n\tpanic: boom")
+	assert.Equal(t, storedReason, task.StatusReason, "the failure notification must carry the stored reason")
+}
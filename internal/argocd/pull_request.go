@@ -0,0 +1,320 @@
+package argocd
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+
+	"github.com/shini4i/argo-watcher/internal/models"
+	"github.com/shini4i/argo-watcher/internal/updater"
+)
+
+const (
+	// writeBackMethodAnnotation selects how a write-back reaches the application's
+	// branch: pushed to it (the default), or proposed in a pull request for a branch
+	// that rejects direct pushes.
+	writeBackMethodAnnotation  = "argo-watcher/write-back-method"
+	writeBackMethodPush        = "push"
+	writeBackMethodPullRequest = "pull-request"
+	// autoMergeAnnotation makes argo-watcher merge the pull request of a write-back
+	// itself, once the provider reports it mergeable.
+	autoMergeAnnotation = "argo-watcher/auto-merge"
+	// pullRequestBranchPrefix starts the name of every branch a write-back is proposed from.
+	pullRequestBranchPrefix = "argo-watcher/"
+)
+
+// PullRequestError fails a deployment whose pull request was not merged: it was
+// closed, or it was still open when the merge timeout ran out.
+type PullRequestError struct {
+	URL     string
+	Message string
+}
+
+func (err *PullRequestError) Error() string {
+	return fmt.Sprintf("pull request %s %s", err.URL, err.Message)
+}
+
+// Reason renders the user-facing task failure reason.
+func (err *PullRequestError) Reason() string {
+	return fmt.Sprintf("Application deployment failed. The write-back was proposed in pull request %s, which %s.", err.URL, err.Message)
+}
+
+// writeBackMethod returns how the application's write-back reaches its branch.
+func writeBackMethod(annotations map[string]string) (string, error) {
+	method := strings.TrimSpace(annotations[writeBackMethodAnnotation])
+	switch method {
+	case "", writeBackMethodPush:
+		return writeBackMethodPush, nil
+	case writeBackMethodPullRequest:
+		return writeBackMethodPullRequest, nil
+	default:
+		return "", fmt.Errorf("unsupported %s %q, expected %q or %q", writeBackMethodAnnotation, method, writeBackMethodPush, writeBackMethodPullRequest)
+	}
+}
+
+// isAutoMergeEnabled reports whether the application asks for the pull request of
+// its write-back to be merged by argo-watcher.
+func isAutoMergeEnabled(annotations map[string]string) bool {
+	return annotations[autoMergeAnnotation] == "true"
+}
+
+// pullRequestBranch names the branch a task's write-back is proposed from. The task
+// id keeps two deployments of one application from sharing a branch.
+func pullRequestBranch(app, taskId string) string {
+	return fmt.Sprintf("%s%s/%s", pullRequestBranchPrefix, app, taskId)
+}
+
+func pullRequestTitle(app string, task *models.Task) string {
+	return fmt.Sprintf("argo-watcher(%s): %s", app, task.WriteBackSubject())
+}
+
+// pullRequestBody describes the deployment a pull request writes back.
+func pullRequestBody(task *models.Task) string {
+	var body strings.Builder
+	fmt.Fprintf(&body, "Deployment `%s` of `%s`, requested by %s.\n\n", task.Id, task.App, task.Author)
+	if task.ChartVersion != "" {
+		fmt.Fprintf(&body, "Chart version: `%s`\n", task.ChartVersion)
+		return body.String()
+	}
+	body.WriteString("Images:\n")
+	for _, image := range task.Images {
+		fmt.Fprintf(&body, "- `%s:%s`\n", image.Image, image.PinnedTag())
+	}
+	return body.String()
+}
+
+// pullRequestCallTimeout bounds one call made to the provider while a pull request
+// is awaited.
+const pullRequestCallTimeout = 30 * time.Second
+
+// mergeWatcher follows the pull requests of write-backs until they are merged.
+type mergeWatcher struct {
+	provider     updater.PullRequestProvider
+	pollInterval time.Duration
+	timeout      time.Duration
+}
+
+// newMergeWatcher returns the watcher for the provider config names, or nil when it
+// names none.
+func newMergeWatcher(config *updater.PullRequestConfig) *mergeWatcher {
+	if config == nil {
+		return nil
+	}
+	provider := updater.NewPullRequestProvider(config)
+	if provider == nil {
+		return nil
+	}
+	return &mergeWatcher{provider: provider, pollInterval: config.PollInterval, timeout: config.MergeTimeout}
+}
+
+// await holds the task in "awaiting merge" until its pull request is merged, merging
+// it itself when the application opted into auto-merge, and moves it back to
+// "in progress" so its rollout is monitored. A pull request closed unmerged, or not
+// merged within the merge timeout, fails the deployment with a PullRequestError.
+//
+// The pull request is stored with the task, with when the wait began. A replica that
+// gives the rollout up — on lease loss or drain — leaves the pull request open and
+// returns errLeaseLost; the replica that claims the task next is handed it back in
+// task.PullRequest and picks the wait up where it stopped, against the same deadline.
+//
+// A task superseded while it waits has its pull request closed, so a reviewer
+// cannot merge a tag a newer deployment already replaced.
+//
+// The commit the merge landed as replaces the write-back commit on the task, as it is
+// the one ArgoCD syncs: a squash merge leaves the branch's commit out of the base.
+func (watcher *mergeWatcher) await(monitor *DeploymentMonitor, task *models.Task, pr *updater.PullRequest, autoMerge bool, abandoned func() bool) error {
+	if task.PullRequest == nil {
+		reason := fmt.Sprintf("Waiting for pull request %s to be merged.", pr.URL)
+		pullRequest := storedPullRequest(pr, time.Now())
+		awaiting, err := monitor.argo.State.AwaitMerge(task.Id, reason, pullRequest)
+		if err != nil {
+			return err
+		}
+		if !awaiting {
+			watcher.close(*task, pr)
+			return errTaskSuperseded
+		}
+		task.PullRequest = &pullRequest
+	}
+
+	slog.Info("Waiting for the pull request of the write-back to be merged", "url", pr.URL, "auto_merge", autoMerge, "id", task.Id)
+	deadline := time.Unix(task.PullRequest.Since, 0).Add(watcher.timeout)
+
+	for {
+		if monitor.taskSuperseded(task.Id) {
+			watcher.close(*task, pr)
+			return errTaskSuperseded
+		}
+		if abandoned() {
+			return errLeaseLost
+		}
+
+		state, err := watcher.state(pr)
+		switch {
+		case err != nil:
+			// The provider being briefly unreachable says nothing about the pull
+			// request, so it is asked again on the next poll until the timeout.
+			slog.Warn("Failed to check the pull request of the write-back", "url", pr.URL, "error", err, "id", task.Id)
+		case state.Merged:
+			noteMergeCommit(monitor, task, state.MergeCommit)
+			watcher.deleteBranch(*task, pr)
+			return startMergedTask(monitor, *task, pr)
+		case state.Closed:
+			watcher.deleteBranch(*task, pr)
+			return &PullRequestError{URL: pr.URL, Message: "was closed without being merged"}
+		case autoMerge && state.Mergeable:
+			if err := watcher.merge(pr); err != nil {
+				slog.Warn("Failed to merge the pull request of the write-back", "url", pr.URL, "error", err, "id", task.Id)
+			} else {
+				slog.Info("Merged the pull request of the write-back", "url", pr.URL, "id", task.Id)
+				// The merge call does not say what it landed as; the state does.
+				if merged, err := watcher.state(pr); err == nil {
+					noteMergeCommit(monitor, task, merged.MergeCommit)
+				}
+				watcher.deleteBranch(*task, pr)
+				return startMergedTask(monitor, *task, pr)
+			}
+		}
+
+		if time.Now().After(deadline) {
+			watcher.close(*task, pr)
+			return &PullRequestError{URL: pr.URL, Message: fmt.Sprintf("was not merged within %s and was closed", watcher.timeout)}
+		}
+		if !watcher.sleep(abandoned) {
+			return errLeaseLost
+		}
+	}
+}
+
+// abandonCheckInterval is how often a wait between two polls of a pull request checks
+// whether the rollout was given up. The poll interval may be minutes long, longer
+// than a drain is allowed to take.
+const abandonCheckInterval = time.Second
+
+// sleep waits for the poll interval, and reports false as soon as abandoned does
+// instead.
+func (watcher *mergeWatcher) sleep(abandoned func() bool) bool {
+	timer := time.NewTimer(watcher.pollInterval)
+	defer timer.Stop()
+	ticker := time.NewTicker(min(abandonCheckInterval, watcher.pollInterval))
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-timer.C:
+			return true
+		case <-ticker.C:
+			if abandoned() {
+				return false
+			}
+		}
+	}
+}
+
+// storedPullRequest is the record of pr kept with its task, for a wait that began at since.
+func storedPullRequest(pr *updater.PullRequest, since time.Time) models.PullRequest {
+	return models.PullRequest{
+		URL:     pr.URL,
+		Number:  pr.Number,
+		RepoURL: pr.RepoURL,
+		Branch:  pr.Branch,
+		Base:    pr.Base,
+		Since:   since.Unix(),
+	}
+}
+
+// resume returns the pull request a task taken over from another replica still awaits
+// the merge of. It returns nothing for a task that proposed none, or whose pull
+// request was merged before it was taken over.
+func (watcher *mergeWatcher) resume(task *models.Task) (*updater.PullRequest, error) {
+	if task.PullRequest == nil || task.Status != models.StatusAwaitingMergeMessage {
+		return nil, nil
+	}
+	if watcher == nil {
+		return nil, fmt.Errorf("the task awaits the merge of pull request %s, but no pull request provider is configured", task.PullRequest.URL)
+	}
+
+	pullRequest := task.PullRequest
+	return &updater.PullRequest{
+		RepoURL: pullRequest.RepoURL,
+		Number:  pullRequest.Number,
+		URL:     pullRequest.URL,
+		Branch:  pullRequest.Branch,
+		Base:    pullRequest.Base,
+	}, nil
+}
+
+// noteMergeCommit records on a task that wrote back the commit its pull request was
+// merged as. A provider that reported none leaves the commit of the branch: a
+// fast-forward merge lands it as it is.
+func noteMergeCommit(monitor *DeploymentMonitor, task *models.Task, commit string) {
+	if commit == "" || task.WriteBack == nil {
+		return
+	}
+	// Copied before it is changed: the task may share its WriteBack with copies of it.
+	writeBack := *task.WriteBack
+	writeBack.CommitSha = commit
+	task.WriteBack = &writeBack
+	monitor.RecordWriteBack(*task)
+}
+
+// startMergedTask moves a task whose pull request was merged back to in progress.
+func startMergedTask(monitor *DeploymentMonitor, task models.Task, pr *updater.PullRequest) error {
+	started, err := monitor.argo.State.StartMergedTask(task.Id)
+	if err != nil {
+		return err
+	}
+	if !started {
+		return errTaskSuperseded
+	}
+
+	slog.Info("The pull request of the write-back was merged; monitoring the rollout", "url", pr.URL, "id", task.Id)
+	return nil
+}
+
+func (watcher *mergeWatcher) state(pr *updater.PullRequest) (updater.PullRequestState, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), pullRequestCallTimeout)
+	defer cancel()
+	return watcher.provider.State(ctx, pr)
+}
+
+func (watcher *mergeWatcher) merge(pr *updater.PullRequest) error {
+	ctx, cancel := context.WithTimeout(context.Background(), pullRequestCallTimeout)
+	defer cancel()
+	return watcher.provider.Merge(ctx, pr)
+}
+
+// close closes a pull request nobody should merge anymore, and deletes its branch.
+// Failing to is only logged: the deployment already has its outcome.
+func (watcher *mergeWatcher) close(task models.Task, pr *updater.PullRequest) {
+	ctx, cancel := context.WithTimeout(context.Background(), pullRequestCallTimeout)
+	defer cancel()
+	if err := watcher.provider.Close(ctx, pr); err != nil {
+		slog.Warn("Failed to close the pull request of the write-back", "url", pr.URL, "error", err, "id", task.Id)
+	}
+	watcher.deleteBranch(task, pr)
+}
+
+// deleteBranch deletes the branch of a pull request that was merged or closed, so the
+// branches of past write-backs do not pile up in the repository. Failing to is only
+// logged, like failing to close one.
+func (watcher *mergeWatcher) deleteBranch(task models.Task, pr *updater.PullRequest) {
+	ctx, cancel := context.WithTimeout(context.Background(), pullRequestCallTimeout)
+	defer cancel()
+	if err := watcher.provider.DeleteBranch(ctx, pr); err != nil {
+		slog.Warn("Failed to delete the branch of the write-back's pull request", "branch", pr.Branch, "url", pr.URL, "error", err, "id", task.Id)
+	}
+}
+
+// HandlePullRequestFailure fails a task whose pull request was not merged.
+func (monitor *DeploymentMonitor) HandlePullRequestFailure(task *models.Task, prErr *PullRequestError) {
+	slog.Warn("App deployment failed: the pull request of the write-back was not merged.", "url", prErr.URL, "id", task.Id)
+	monitor.argo.metrics.AddFailedDeployment(task.App)
+
+	if err := monitor.argo.State.SetTaskStatus(task.Id, models.StatusFailedMessage, prErr.Reason()); err != nil {
+		slog.Error("Failed to change task status", "error", err, "id", task.Id)
+	}
+	task.Status = models.StatusFailedMessage
+}
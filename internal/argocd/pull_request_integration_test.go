@@ -0,0 +1,93 @@
+//go:build integration
+
+package argocd
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/shini4i/argo-watcher/internal/models"
+	"github.com/shini4i/argo-watcher/internal/updater"
+)
+
+// giteaAccessToken creates an API token for the test user, as an operator would
+// for GIT_PR_TOKEN.
+func giteaAccessToken(t *testing.T, env *giteaEnv) string {
+	t.Helper()
+	body := fmt.Sprintf(`{"name": "argo-watcher-%d", "scopes": ["write:repository"]}`, time.Now().UnixNano())
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, fmt.Sprintf("%s/api/v1/users/%s/tokens", giteaAPI, env.User), strings.NewReader(body))
+	require.NoError(t, err)
+	req.SetBasicAuth(env.User, env.Password)
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	require.Less(t, resp.StatusCode, 300, "gitea token creation returned %d", resp.StatusCode)
+
+	var token struct {
+		Sha1 string `json:"sha1"`
+	}
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&token))
+	return token.Sha1
+}
+
+// TestIntegration_PullRequestWriteBack proposes a write-back to Gitea, merges the
+// pull request once Gitea reports it mergeable, and checks the tag landed on the
+// base branch only then.
+func TestIntegration_PullRequestWriteBack(t *testing.T) {
+	waitForGitea(t, 60*time.Second)
+	env := setupGitea(t)
+
+	t.Setenv("SSH_KEY_PATH", env.SSHKeyPath)
+	t.Setenv("GIT_OP_TIMEOUT", "60s")
+
+	provider := updater.NewPullRequestProvider(&updater.PullRequestConfig{
+		Provider: updater.PullRequestProviderGitea,
+		ApiUrl:   giteaAPI + "/api/v1",
+		Token:    giteaAccessToken(t, env),
+	})
+	require.NotNil(t, provider)
+
+	const app = "pr-app"
+	overrideRel := fmt.Sprintf("apps/.argocd-source-%s.yaml", app)
+	gitopsRepo := &models.GitopsRepo{
+		RepoUrl:       env.DirectRepoURL,
+		BranchName:    "master",
+		Path:          "apps",
+		RepoCachePath: t.TempDir(),
+	}
+	task := &models.Task{Id: "task-pr", App: app, Images: []models.Image{{Image: "myimage", Tag: "v1"}}}
+
+	pr, err := ProposeGitImageTag(context.Background(), newAppWithImages(app), task, gitopsRepo, testGitHandler{}, provider)
+	require.NoError(t, err)
+	require.NotNil(t, pr)
+	assert.Equal(t, pullRequestBranch(app, task.Id), pr.Branch)
+
+	_, content := cloneRemoteState(t, env.DirectRepoURL, env.SSHKeyPath, "master", overrideRel)
+	assert.Empty(t, content, "a proposed write-back must not reach the base branch before the merge")
+
+	ctx := context.Background()
+	require.Eventually(t, func() bool {
+		state, err := provider.State(ctx, pr)
+		return err == nil && state.Mergeable
+	}, 30*time.Second, time.Second, "gitea must report the pull request mergeable")
+
+	require.NoError(t, provider.Merge(ctx, pr))
+
+	state, err := provider.State(ctx, pr)
+	require.NoError(t, err)
+	assert.True(t, state.Merged)
+
+	_, content = cloneRemoteState(t, env.DirectRepoURL, env.SSHKeyPath, "master", overrideRel)
+	assert.Contains(t, content, "v1", "the merged pull request must carry the tag to the base branch")
+}
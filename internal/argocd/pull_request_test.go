@@ -0,0 +1,240 @@
+package argocd
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/shini4i/argo-watcher/internal/models"
+	"github.com/shini4i/argo-watcher/internal/updater"
+)
+
+// fakePullRequestProvider answers State with the states it is given, in order,
+// repeating the last one, and records what it was asked to do.
+type fakePullRequestProvider struct {
+	mu       sync.Mutex
+	states   []updater.PullRequestState
+	stateErr error
+	merged   int
+	closed   int
+	deleted  int
+}
+
+func (provider *fakePullRequestProvider) Open(_ context.Context, repoURL, branch, base, _, _ string) (*updater.PullRequest, error) {
+	return &updater.PullRequest{RepoURL: repoURL, Number: 1, URL: "https://git.example.com/pulls/1", Branch: branch, Base: base}, nil
+}
+
+func (provider *fakePullRequestProvider) State(context.Context, *updater.PullRequest) (updater.PullRequestState, error) {
+	provider.mu.Lock()
+	defer provider.mu.Unlock()
+	if provider.stateErr != nil {
+		err := provider.stateErr
+		provider.stateErr = nil
+		return updater.PullRequestState{}, err
+	}
+	state := provider.states[0]
+	if len(provider.states) > 1 {
+		provider.states = provider.states[1:]
+	}
+	return state, nil
+}
+
+func (provider *fakePullRequestProvider) Merge(context.Context, *updater.PullRequest) error {
+	provider.mu.Lock()
+	defer provider.mu.Unlock()
+	provider.merged++
+	provider.states = []updater.PullRequestState{{Merged: true, Closed: true}}
+	return nil
+}
+
+func (provider *fakePullRequestProvider) Close(context.Context, *updater.PullRequest) error {
+	provider.mu.Lock()
+	defer provider.mu.Unlock()
+	provider.closed++
+	return nil
+}
+
+func (provider *fakePullRequestProvider) DeleteBranch(context.Context, *updater.PullRequest) error {
+	provider.mu.Lock()
+	defer provider.mu.Unlock()
+	provider.deleted++
+	return nil
+}
+
+func TestWriteBackMethod(t *testing.T) {
+	method, err := writeBackMethod(nil)
+	require.NoError(t, err)
+	assert.Equal(t, writeBackMethodPush, method)
+
+	method, err = writeBackMethod(map[string]string{writeBackMethodAnnotation: " pull-request "})
+	require.NoError(t, err)
+	assert.Equal(t, writeBackMethodPullRequest, method)
+
+	_, err = writeBackMethod(map[string]string{writeBackMethodAnnotation: "email"})
+	assert.Error(t, err)
+}
+
+func TestPullRequestBranch(t *testing.T) {
+	assert.Equal(t, "argo-watcher/demo/8f14e45f", pullRequestBranch("demo", "8f14e45f"))
+}
+
+// newMergeTest returns a monitor backed by in-memory state holding one in-progress
+// task, and a watcher polling the given provider without delay.
+func newMergeTest(t *testing.T, provider *fakePullRequestProvider) (*DeploymentMonitor, *mergeWatcher, models.Task) {
+	t.Helper()
+	argo := newGroupArgo(nil)
+	task, err := argo.State.AddTask(models.Task{App: "demo", Images: []models.Image{{Image: "app", Tag: "v2"}}})
+	require.NoError(t, err)
+
+	monitor := NewDeploymentMonitor(*argo, "", nil, false, time.Second)
+	watcher := &mergeWatcher{provider: provider, pollInterval: time.Millisecond, timeout: time.Minute}
+	return monitor, watcher, *task
+}
+
+func TestMergeWatcherAwait(t *testing.T) {
+	pr := &updater.PullRequest{URL: "https://git.example.com/pulls/1"}
+
+	t.Run("A merged pull request resumes the rollout", func(t *testing.T) {
+		provider := &fakePullRequestProvider{
+			states:   []updater.PullRequestState{{}, {Merged: true, Closed: true}},
+			stateErr: errors.New("provider unavailable"),
+		}
+		monitor, watcher, task := newMergeTest(t, provider)
+
+		require.NoError(t, watcher.await(monitor, &task, pr, false, neverDraining))
+
+		stored, err := monitor.argo.State.GetTask(task.Id)
+		require.NoError(t, err)
+		assert.Equal(t, models.StatusInProgressMessage, stored.Status)
+		assert.Zero(t, provider.merged)
+		assert.Equal(t, 1, provider.deleted, "the branch of a merged pull request is deleted")
+	})
+
+	t.Run("The merge commit replaces the commit of the branch", func(t *testing.T) {
+		provider := &fakePullRequestProvider{states: []updater.PullRequestState{{Merged: true, Closed: true, MergeCommit: "9a8b7c6"}}}
+		monitor, watcher, task := newMergeTest(t, provider)
+		task.WriteBack = &models.WriteBack{CommitSha: "3f78685", SigningKey: "ssh:SHA256:abc"}
+
+		require.NoError(t, watcher.await(monitor, &task, pr, false, neverDraining))
+		assert.Equal(t, "9a8b7c6", task.WriteBack.CommitSha)
+
+		stored, err := monitor.argo.State.GetTask(task.Id)
+		require.NoError(t, err)
+		require.NotNil(t, stored.WriteBack)
+		assert.Equal(t, "9a8b7c6", stored.WriteBack.CommitSha)
+	})
+
+	t.Run("Auto-merge merges a mergeable pull request", func(t *testing.T) {
+		provider := &fakePullRequestProvider{states: []updater.PullRequestState{{}, {Mergeable: true}}}
+		monitor, watcher, task := newMergeTest(t, provider)
+
+		require.NoError(t, watcher.await(monitor, &task, pr, true, neverDraining))
+		assert.Equal(t, 1, provider.merged)
+		assert.Equal(t, 1, provider.deleted)
+	})
+
+	t.Run("A closed pull request fails the deployment", func(t *testing.T) {
+		provider := &fakePullRequestProvider{states: []updater.PullRequestState{{Closed: true}}}
+		monitor, watcher, task := newMergeTest(t, provider)
+
+		err := watcher.await(monitor, &task, pr, true, neverDraining)
+		var prErr *PullRequestError
+		require.ErrorAs(t, err, &prErr)
+		assert.Contains(t, prErr.Reason(), pr.URL)
+		assert.Equal(t, 1, provider.deleted, "the branch of a closed pull request is deleted")
+
+		monitor.HandlePullRequestFailure(&task, prErr)
+		stored, err := monitor.argo.State.GetTask(task.Id)
+		require.NoError(t, err)
+		assert.Equal(t, models.StatusFailedMessage, stored.Status)
+		assert.Equal(t, prErr.Reason(), stored.StatusReason)
+	})
+
+	t.Run("A pull request not merged in time is closed", func(t *testing.T) {
+		provider := &fakePullRequestProvider{states: []updater.PullRequestState{{}}}
+		monitor, watcher, task := newMergeTest(t, provider)
+		watcher.timeout = 5 * time.Millisecond
+
+		var prErr *PullRequestError
+		require.ErrorAs(t, watcher.await(monitor, &task, pr, false, neverDraining), &prErr)
+		assert.Equal(t, 1, provider.closed)
+		assert.Equal(t, 1, provider.deleted)
+	})
+
+	t.Run("A superseded task closes its pull request", func(t *testing.T) {
+		provider := &fakePullRequestProvider{states: []updater.PullRequestState{{}}}
+		monitor, watcher, task := newMergeTest(t, provider)
+		require.NoError(t, monitor.argo.State.SetTaskStatus(task.Id, models.StatusCancelledMessage, ""))
+
+		assert.ErrorIs(t, watcher.await(monitor, &task, pr, false, neverDraining), errTaskSuperseded)
+		assert.Equal(t, 1, provider.closed)
+	})
+
+	t.Run("An abandoned wait leaves the pull request open", func(t *testing.T) {
+		provider := &fakePullRequestProvider{states: []updater.PullRequestState{{}}}
+		monitor, watcher, task := newMergeTest(t, provider)
+
+		err := watcher.await(monitor, &task, pr, false, func() bool { return true })
+		assert.ErrorIs(t, err, errLeaseLost)
+		assert.Zero(t, provider.closed)
+		assert.Zero(t, provider.deleted)
+
+		stored, err := monitor.argo.State.GetTask(task.Id)
+		require.NoError(t, err)
+		assert.Equal(t, models.StatusAwaitingMergeMessage, stored.Status)
+		require.NotNil(t, stored.PullRequest, "the replica taking the task over needs the pull request")
+		assert.Equal(t, pr.URL, stored.PullRequest.URL)
+		assert.NotZero(t, stored.PullRequest.Since)
+	})
+
+	t.Run("A drain ends the wait between two polls", func(t *testing.T) {
+		provider := &fakePullRequestProvider{states: []updater.PullRequestState{{}}}
+		monitor, watcher, task := newMergeTest(t, provider)
+		watcher.pollInterval = time.Hour
+
+		var polls int
+		start := time.Now()
+		err := watcher.await(monitor, &task, pr, false, func() bool { polls++; return polls > 1 })
+		assert.ErrorIs(t, err, errLeaseLost)
+		assert.Less(t, time.Since(start), time.Minute, "the wait must not sleep through the poll interval")
+	})
+
+	t.Run("A resumed wait keeps the deadline it began with", func(t *testing.T) {
+		provider := &fakePullRequestProvider{states: []updater.PullRequestState{{}}}
+		monitor, watcher, task := newMergeTest(t, provider)
+		_, err := monitor.argo.State.AwaitMerge(task.Id, "waiting", models.PullRequest{URL: pr.URL})
+		require.NoError(t, err)
+		task.Status = models.StatusAwaitingMergeMessage
+		task.PullRequest = &models.PullRequest{URL: pr.URL, Since: time.Now().Add(-2 * watcher.timeout).Unix()}
+
+		var prErr *PullRequestError
+		require.ErrorAs(t, watcher.await(monitor, &task, pr, false, neverDraining), &prErr)
+		assert.Equal(t, 1, provider.closed)
+	})
+}
+
+func TestMergeWatcherResume(t *testing.T) {
+	stored := &models.PullRequest{URL: "https://git.example.com/pulls/1", Number: 1, RepoURL: "https://git.example.com/gitops.git", Branch: "argo-watcher/demo/1", Base: "main"}
+	watcher := &mergeWatcher{provider: &fakePullRequestProvider{}}
+
+	pr, err := watcher.resume(&models.Task{Status: models.StatusAwaitingMergeMessage, PullRequest: stored})
+	require.NoError(t, err)
+	assert.Equal(t, &updater.PullRequest{URL: stored.URL, Number: 1, RepoURL: stored.RepoURL, Branch: stored.Branch, Base: "main"}, pr)
+
+	pr, err = watcher.resume(&models.Task{Status: models.StatusInProgressMessage, PullRequest: stored})
+	require.NoError(t, err)
+	assert.Nil(t, pr, "a pull request merged before the takeover is not awaited again")
+
+	pr, err = watcher.resume(&models.Task{Status: models.StatusInProgressMessage})
+	require.NoError(t, err)
+	assert.Nil(t, pr)
+
+	var unconfigured *mergeWatcher
+	_, err = unconfigured.resume(&models.Task{Status: models.StatusAwaitingMergeMessage, PullRequest: stored})
+	assert.Error(t, err)
+}
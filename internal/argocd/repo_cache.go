@@ -0,0 +1,157 @@
+package argocd
+
+import (
+	"log/slog"
+	"sort"
+	"time"
+
+	"github.com/shini4i/argo-watcher/internal/lock"
+	"github.com/shini4i/argo-watcher/internal/prometheus"
+	"github.com/shini4i/argo-watcher/internal/updater"
+)
+
+// invalidCacheGrace is how long a clone that cannot be opened as a repository is kept
+// before it is evicted. Without a remote there is no per-repo lock to take, and a clone
+// that young may be one a write-back is still making.
+const invalidCacheGrace = time.Hour
+
+// withCachedRepo runs f under the per-repo lock of repoURL, on which every use of the
+// repository cache is serialised, and records whether the clone of branch f is about to
+// use was cached.
+func withCachedRepo(locker lock.Locker, metrics prometheus.MetricsInterface, root, repoURL, branch string, f func() error) error {
+	return locker.WithLock(repoURL, func() error {
+		if metrics != nil {
+			metrics.AddRepoCacheLookup(updater.IsCached(root, repoURL, branch))
+		}
+		return f()
+	})
+}
+
+// RepoCache bounds the repository cache, which otherwise keeps a clone of every
+// repository and branch ever written back to. Each sweep evicts the clones unused for
+// longer than the configured age, then the least recently used ones until the cache
+// fits the configured size.
+//
+// A clone is only evicted under the per-repo lock of its repository, the one every
+// write-back holds while it uses the clone, so a clone in use is never removed.
+type RepoCache struct {
+	root    string
+	locker  lock.Locker
+	metrics prometheus.MetricsInterface
+	config  *updater.CacheConfig
+	// now is time.Now, overridable in tests.
+	now func() time.Time
+}
+
+// NewRepoCache creates a RepoCache for the cache under root. metrics may be nil.
+func NewRepoCache(root string, locker lock.Locker, metrics prometheus.MetricsInterface, config *updater.CacheConfig) *RepoCache {
+	return &RepoCache{
+		root:    root,
+		locker:  locker,
+		metrics: metrics,
+		config:  config,
+		now:     time.Now,
+	}
+}
+
+// Run sweeps the cache right away and then on every sweep interval, until stop is
+// closed.
+func (cache *RepoCache) Run(stop <-chan struct{}) {
+	ticker := time.NewTicker(cache.config.SweepInterval)
+	defer ticker.Stop()
+
+	for {
+		cache.Sweep()
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Sweep evicts what exceeds the cache's limits and records its size. Evictions that
+// fail are logged and retried by the next sweep.
+func (cache *RepoCache) Sweep() {
+	entries, err := updater.ListCache(cache.root)
+	if err != nil {
+		slog.Error("Failed to list the repository cache", "error", err)
+		return
+	}
+
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].LastUsed.Before(entries[j].LastUsed)
+	})
+
+	var total int64
+	for _, entry := range entries {
+		total += entry.Size
+	}
+	maxSize := int64(cache.config.MaxSizeMB) << 20 // #nosec G115 -- megabytes, far below the overflow
+	now := cache.now()
+
+	clones := 0
+	for _, entry := range entries {
+		reason := cache.evictionReason(entry, now, total, maxSize)
+		if reason == "" || !cache.evict(entry, reason) {
+			clones++
+			continue
+		}
+		total -= entry.Size
+	}
+
+	if cache.metrics != nil {
+		cache.metrics.SetRepoCacheSize(total, clones)
+	}
+}
+
+// evictionReason tells why entry is to be evicted from a cache of total bytes, or ""
+// when it is to be kept. Entries are considered least recently used first, so the
+// size limit takes the oldest ones.
+func (cache *RepoCache) evictionReason(entry updater.CacheEntry, now time.Time, total, maxSize int64) string {
+	unused := now.Sub(entry.LastUsed)
+	switch {
+	case entry.RepoURL == "":
+		if unused > invalidCacheGrace {
+			return "invalid"
+		}
+		return ""
+	case cache.config.MaxAge > 0 && unused > cache.config.MaxAge:
+		return "age"
+	case maxSize > 0 && total > maxSize:
+		return "size"
+	}
+	return ""
+}
+
+// evict removes entry for reason, under the per-repo lock of its repository when it
+// has one, and reports whether it did.
+func (cache *RepoCache) evict(entry updater.CacheEntry, reason string) bool {
+	var removed bool
+	remove := func() error {
+		var err error
+		removed, err = updater.EvictCache(cache.root, entry)
+		return err
+	}
+
+	var err error
+	if entry.RepoURL == "" {
+		err = remove()
+	} else {
+		err = cache.locker.WithLock(entry.RepoURL, remove)
+	}
+	if err != nil {
+		slog.Warn("Failed to evict a cached repository", "path", entry.Path, "repo", entry.RepoURL, "error", err)
+		return false
+	}
+	if !removed {
+		slog.Debug("Kept a cached repository used since the sweep began", "path", entry.Path, "repo", entry.RepoURL)
+		return false
+	}
+
+	slog.Info("Evicted a cached repository", "path", entry.Path, "repo", entry.RepoURL, "reason", reason, "size_bytes", entry.Size)
+	if cache.metrics != nil {
+		cache.metrics.AddRepoCacheEviction(reason)
+	}
+	return true
+}
@@ -0,0 +1,166 @@
+package argocd
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
+
+	"github.com/shini4i/argo-watcher/internal/mocks"
+	"github.com/shini4i/argo-watcher/internal/updater"
+)
+
+// keyLocker records the keys it was asked to lock.
+type keyLocker struct {
+	keys []string
+	err  error
+}
+
+func (locker *keyLocker) WithLock(key string, f func() error) error {
+	locker.keys = append(locker.keys, key)
+	if locker.err != nil {
+		return locker.err
+	}
+	return f()
+}
+
+// cachedClone puts a clone of repoURL holding size bytes in the cache under root, last
+// used age ago. An empty repoURL makes a clone with no remote.
+func cachedClone(t *testing.T, root, repoURL string, size int, age time.Duration) string {
+	t.Helper()
+	path := updater.CachePath(root, repoURL, "main")
+	repo, err := git.PlainInit(path, false)
+	require.NoError(t, err)
+	if repoURL != "" {
+		_, err = repo.CreateRemote(&config.RemoteConfig{Name: "origin", URLs: []string{repoURL}})
+		require.NoError(t, err)
+	}
+	require.NoError(t, os.WriteFile(filepath.Join(path, "values.yaml"), make([]byte, size), 0o600))
+	lastUsed := time.Now().Add(-age)
+	require.NoError(t, os.Chtimes(path, lastUsed, lastUsed))
+	return path
+}
+
+func TestRepoCacheSweep(t *testing.T) {
+	const mb = 1 << 20
+
+	t.Run("Evicts clones unused for longer than the age limit", func(t *testing.T) {
+		root := t.TempDir()
+		stale := cachedClone(t, root, "git@example.com:org/stale.git", 16, 48*time.Hour)
+		fresh := cachedClone(t, root, "git@example.com:org/fresh.git", 16, time.Minute)
+
+		ctrl := gomock.NewController(t)
+		metrics := mocks.NewMockMetricsInterface(ctrl)
+		metrics.EXPECT().AddRepoCacheEviction("age").Times(1)
+		metrics.EXPECT().SetRepoCacheSize(gomock.Any(), 1).Times(1)
+
+		locker := &keyLocker{}
+		cache := NewRepoCache(root, locker, metrics, &updater.CacheConfig{MaxAge: 24 * time.Hour})
+		cache.Sweep()
+
+		assert.NoDirExists(t, stale)
+		assert.DirExists(t, fresh)
+		assert.Equal(t, []string{"git@example.com:org/stale.git"}, locker.keys, "a clone is evicted under the lock of its repository")
+	})
+
+	t.Run("Evicts the least recently used clones beyond the size limit", func(t *testing.T) {
+		root := t.TempDir()
+		oldest := cachedClone(t, root, "git@example.com:org/oldest.git", mb, 3*time.Hour)
+		older := cachedClone(t, root, "git@example.com:org/older.git", mb, 2*time.Hour)
+		newest := cachedClone(t, root, "git@example.com:org/newest.git", mb, time.Hour)
+
+		ctrl := gomock.NewController(t)
+		metrics := mocks.NewMockMetricsInterface(ctrl)
+		metrics.EXPECT().AddRepoCacheEviction("size").Times(2)
+		metrics.EXPECT().SetRepoCacheSize(gomock.Any(), 1).Do(func(bytes int64, _ int) {
+			assert.Less(t, bytes, int64(2*mb))
+		}).Times(1)
+
+		cache := NewRepoCache(root, &keyLocker{}, metrics, &updater.CacheConfig{MaxSizeMB: 2})
+		cache.Sweep()
+
+		assert.NoDirExists(t, oldest)
+		assert.NoDirExists(t, older)
+		assert.DirExists(t, newest)
+	})
+
+	t.Run("Keeps a cache within its limits", func(t *testing.T) {
+		root := t.TempDir()
+		path := cachedClone(t, root, "git@example.com:org/gitops.git", 16, 48*time.Hour)
+
+		ctrl := gomock.NewController(t)
+		metrics := mocks.NewMockMetricsInterface(ctrl)
+		metrics.EXPECT().SetRepoCacheSize(gomock.Any(), 1).Times(1)
+
+		locker := &keyLocker{}
+		cache := NewRepoCache(root, locker, metrics, &updater.CacheConfig{})
+		cache.Sweep()
+
+		assert.DirExists(t, path)
+		assert.Empty(t, locker.keys)
+	})
+
+	t.Run("Evicts a clone without a remote once it is past its grace", func(t *testing.T) {
+		root := t.TempDir()
+		broken := filepath.Join(root, "broken")
+		require.NoError(t, os.Mkdir(broken, 0o750))
+		lastUsed := time.Now().Add(-2 * invalidCacheGrace)
+		require.NoError(t, os.Chtimes(broken, lastUsed, lastUsed))
+		young := cachedClone(t, root, "", 16, time.Minute)
+
+		locker := &keyLocker{}
+		cache := NewRepoCache(root, locker, nil, &updater.CacheConfig{})
+		cache.Sweep()
+
+		assert.NoDirExists(t, broken)
+		assert.DirExists(t, young, "a clone that young may still be being made")
+		assert.Empty(t, locker.keys)
+	})
+
+	t.Run("Keeps a clone whose lock could not be taken", func(t *testing.T) {
+		root := t.TempDir()
+		stale := cachedClone(t, root, "git@example.com:org/stale.git", 16, 48*time.Hour)
+
+		ctrl := gomock.NewController(t)
+		metrics := mocks.NewMockMetricsInterface(ctrl)
+		metrics.EXPECT().SetRepoCacheSize(gomock.Any(), 1).Times(1)
+
+		cache := NewRepoCache(root, &keyLocker{err: errors.New("lock failed")}, metrics, &updater.CacheConfig{MaxAge: time.Hour})
+		cache.Sweep()
+
+		assert.DirExists(t, stale)
+	})
+}
+
+func TestRepoCacheRun(t *testing.T) {
+	root := t.TempDir()
+	stale := cachedClone(t, root, "git@example.com:org/stale.git", 16, 48*time.Hour)
+
+	stop := make(chan struct{})
+	done := make(chan struct{})
+	cache := NewRepoCache(root, &keyLocker{}, nil, &updater.CacheConfig{MaxAge: time.Hour, SweepInterval: time.Hour})
+	go func() {
+		cache.Run(stop)
+		close(done)
+	}()
+
+	// The first sweep runs right away rather than an interval later.
+	require.Eventually(t, func() bool {
+		_, err := os.Stat(stale)
+		return os.IsNotExist(err)
+	}, 5*time.Second, 10*time.Millisecond)
+
+	close(stop)
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Run did not return after stop was closed")
+	}
+}
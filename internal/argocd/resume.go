@@ -24,11 +24,22 @@ const StaleResumedTaskReason = "Deployment window elapsed while the task was una
 // creation. A task whose window has already elapsed is aborted here instead of
 // being resumed, which is the outcome it would reach on the first poll anyway.
 //
+// A task that awaits the merge of its pull request is resumed whatever its age: its
+// rollout window only starts once the pull request is merged, and the wait keeps the
+// deadline the merge timeout set when it began.
+//
 // draining reports that this replica has begun shutting down. A rollout resumed
 // shortly before shutdown is given up as soon as that happens: the claim is
 // released in the last shutdown phase, so the next replica to sweep resumes the
 // deployment and records its outcome.
 func (updater *ArgoStatusUpdater) ResumeRollout(task models.Task, draining func() bool) {
+	if task.Status == models.StatusAwaitingMergeMessage {
+		slog.Info("Resuming the wait for the pull request of a deployment abandoned by another replica",
+			"id", task.Id, "app", task.App, "url", task.PullRequest.URL)
+		updater.waitForRollout(task, true, draining)
+		return
+	}
+
 	remaining, resumable := updater.monitor.remainingWindow(task, time.Now())
 	if !resumable {
 		slog.Info("Not resuming a deployment whose window already elapsed", "id", task.Id, "app", task.App)
@@ -15,6 +15,7 @@ import (
 	"github.com/shini4i/argo-watcher/internal/mocks"
 	"github.com/shini4i/argo-watcher/internal/models"
 	"github.com/shini4i/argo-watcher/internal/notifications"
+	"github.com/shini4i/argo-watcher/internal/updater"
 )
 
 func monitorWithDefaultWindow(window time.Duration) *DeploymentMonitor {
@@ -284,6 +285,55 @@ func TestResumeRollout_StopsWhenTheReplicaStartsDraining(t *testing.T) {
 		"a deployment left to another replica must not be announced as finished")
 }
 
+// A task awaiting the merge of its pull request is taken over whatever its age: its
+// rollout window starts at the merge, so the window remainingWindow measures from
+// the task's creation says nothing about it. The new owner follows the stored pull
+// request instead of writing back again.
+func TestResumeRollout_FollowsAPullRequestAwaitingMerge(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	apiMock := newArgoApiMock(ctrl)
+	metricsMock := mocks.NewMockMetricsInterface(ctrl)
+	stateMock := newTaskRepositoryMock(ctrl)
+
+	argo := &Argo{}
+	argo.Init(stateMock, apiMock, metricsMock)
+	stateMock.EXPECT().GetTask(gomock.Any()).
+		Return(&models.Task{Status: models.StatusAwaitingMergeMessage}, nil).AnyTimes()
+
+	provider := &fakePullRequestProvider{states: []updater.PullRequestState{{}}}
+	statusUpdater := initTestUpdater(t, newUpdaterTestConfig(lock.NewInMemoryLocker()), argo)
+	statusUpdater.merges = &mergeWatcher{provider: provider, pollInterval: time.Millisecond, timeout: time.Hour}
+
+	task := models.Task{
+		Id:      "awaiting-id",
+		App:     "test-app",
+		Timeout: 30,
+		// Accepted well over its rollout window ago, while the pull request was reviewed.
+		Created:     float64(time.Now().Add(-10 * time.Minute).Unix()),
+		Status:      models.StatusAwaitingMergeMessage,
+		PullRequest: &models.PullRequest{URL: "https://git.example.com/pulls/1", Since: time.Now().Add(-10 * time.Minute).Unix()},
+		Validated:   true,
+		Images:      []models.Image{{Image: "app", Tag: "v1"}},
+	}
+
+	// The replica starts draining while the pull request is still open, which ends
+	// the wait without a status: no AwaitMerge, no abort, no write-back is expected.
+	var draining atomic.Bool
+	apiMock.EXPECT().GetApplication(gomock.Any(), task.App, gomock.Any()).
+		DoAndReturn(func(context.Context, string, bool) (*models.Application, error) {
+			draining.Store(true)
+			return &models.Application{}, nil
+		}).Times(1)
+	metricsMock.EXPECT().AddInProgressTask()
+	metricsMock.EXPECT().RemoveInProgressTask()
+
+	statusUpdater.ResumeRollout(task, draining.Load)
+
+	assert.Zero(t, provider.closed, "the pull request is left for the next replica")
+}
+
 // A superseded task is the one outcome a draining replica must still announce. The
 // newer deployment already wrote "cancelled", and a cancelled task is never
 // re-claimed by a sweep — so the replica that resumes nothing here is the last one
@@ -0,0 +1,144 @@
+package argocd
+
+import (
+	"fmt"
+	"log/slog"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/shini4i/argo-watcher/internal/models"
+	"github.com/shini4i/argo-watcher/internal/state"
+)
+
+// rollbackStartedReason starts the line the status reason of a failed task gains when
+// an automatic rollback is started for it.
+const rollbackStartedReason = "Automatic rollback started"
+
+// startAutomaticRollback reverts a failed deployment of an application annotated
+// argo-watcher/auto-rollback: it records a rollback task carrying the images of the
+// application's last successful deployment, and returns it for the caller to monitor
+// like any other deployment — its write-back, rollout and notifications included. It
+// returns nil when the task is not to be rolled back.
+//
+// application is the state the rollout ended on. Without one the failure was never
+// observed in ArgoCD — the write-back or the API failed first — so the failed version
+// was never rolled out and there is nothing to revert. Only a deployment that wrote
+// back is rolled back either, which is one made with a credential; and a rollback is
+// never rolled back in turn, so a version that cannot be restored is not retried in a
+// loop. A deployment of the application submitted after the failure wins over the
+// rollback: it is already replacing the failed version.
+func (updater *ArgoStatusUpdater) startAutomaticRollback(task models.Task, application *models.Application) *models.Task {
+	if task.Status != models.StatusFailedMessage || application == nil || !application.IsAutoRollbackEnabled() {
+		return nil
+	}
+	if !task.Validated || task.RollbackOfId != "" {
+		return nil
+	}
+
+	argo := updater.monitor.argo
+	target := lastDeployedVersion(argo.State, task)
+	if target == nil {
+		slog.Warn("Not rolling back the failed deployment: the same images were never deployed before", "app", task.App, "id", task.Id)
+		return nil
+	}
+	if imageSignature(*target) == imageSignature(task) {
+		slog.Info("Not rolling back the failed deployment: it redeployed the current version", "app", task.App, "id", task.Id)
+		return nil
+	}
+
+	if newer := pendingDeployment(argo.State, task.App); newer != nil {
+		slog.Info("Not rolling back the failed deployment: a newer deployment of the app is pending", "app", task.App, "id", task.Id, "newer_id", newer.Id, "newer_status", newer.Status)
+		return nil
+	}
+
+	rollback, err := argo.State.AddTask(models.Task{
+		App:              task.App,
+		Author:           task.Author,
+		Project:          task.Project,
+		Images:           target.Images,
+		Parameters:       target.Parameters,
+		ChartVersion:     target.ChartVersion,
+		Validated:        task.Validated,
+		Timeout:          task.Timeout,
+		Refresh:          task.Refresh,
+		IsRollback:       true,
+		RollbackTargetId: target.Id,
+		RollbackOfId:     task.Id,
+	})
+	if err != nil {
+		slog.Error("Failed to record the automatic rollback", "error", err, "app", task.App, "id", task.Id)
+		return nil
+	}
+
+	// Claimed for the same reason AddTask claims a submitted task: this replica monitors
+	// it from here on.
+	if err := argo.State.ClaimTask(rollback.Id); err != nil {
+		slog.Warn("Failed to claim the automatic rollback for this replica", "error", err, "id", rollback.Id)
+	}
+	argo.metrics.AddAcceptedDeployment()
+
+	slog.Info("Rolling back a failed deployment", "app", task.App, "id", task.Id, "rollback_id", rollback.Id, "rollback_target_id", target.Id)
+	return rollback
+}
+
+// pendingStatuses are the statuses of a deployment that has not reached an outcome
+// yet: one being rolled out, one of a group deployment waiting for its turn, and one
+// waiting for the merge of its pull request.
+var pendingStatuses = []string{models.StatusInProgressMessage, models.StatusQueuedMessage, models.StatusAwaitingMergeMessage}
+
+// pendingDeployment returns a deployment of app that has not reached an outcome yet,
+// or nil when there is none. The failed task has, so any it finds was submitted after.
+func pendingDeployment(repository state.TaskRepository, app string) *models.Task {
+	for _, status := range pendingStatuses {
+		if pending, _ := repository.GetTasks(0, float64(time.Now().Unix()), app, status, 1, 0); len(pending) > 0 {
+			return &pending[0]
+		}
+	}
+	return nil
+}
+
+// noteRollback adds to the status reason of the failed task the automatic rollback
+// started for it, so the task and its failure notification both name the task that
+// reverts it. The reason is read back from the state, where the failure handlers
+// write it.
+func (updater *ArgoStatusUpdater) noteRollback(task *models.Task, rollbackId string) {
+	repository := updater.monitor.argo.State
+	reason := task.StatusReason
+	if stored, err := repository.GetTask(task.Id); err == nil {
+		reason = stored.StatusReason
+	}
+	reason = strings.TrimSpace(reason + "\n\n" + fmt.Sprintf("%s: task %s restores the last successful deployment.", rollbackStartedReason, rollbackId))
+
+	if err := repository.SetTaskStatus(task.Id, task.Status, reason); err != nil {
+		slog.Error("Failed to record the automatic rollback on the failed task", "error", err, "id", task.Id)
+	}
+	task.StatusReason = reason
+}
+
+// lastDeployedVersion returns the app's most recent successful deployment of the same
+// images as task, whatever their tags, or nil when there is none within
+// rollbackHistoryWindow. Matching on the image names keeps a failure from being rolled
+// back to a deployment of the app's other images, which would leave it in place.
+func lastDeployedVersion(repository state.TaskRepository, task models.Task) *models.Task {
+	deployed, _ := repository.GetTasks(0, float64(time.Now().Unix()), task.App, models.StatusDeployedMessage, rollbackHistoryWindow, 0)
+
+	names := imageNames(task)
+	for index := range deployed {
+		if imageNames(deployed[index]) == names {
+			return &deployed[index]
+		}
+	}
+	return nil
+}
+
+// imageNames returns a key for the names of a task's images, without their tags. It is
+// empty for a chart version task, which carries none, so those match one another.
+func imageNames(task models.Task) string {
+	names := make([]string, len(task.Images))
+	for index, image := range task.Images {
+		names[index] = image.Image
+	}
+	slices.Sort(names)
+	return strings.Join(slices.Compact(names), ",")
+}
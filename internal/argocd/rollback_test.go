@@ -0,0 +1,231 @@
+package argocd
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
+
+	"github.com/shini4i/argo-watcher/internal/mocks"
+	"github.com/shini4i/argo-watcher/internal/models"
+)
+
+// failedTask is a validated deployment of v2 that failed.
+var failedTask = models.Task{
+	Id:        "failed-id",
+	App:       "demo",
+	Author:    "author",
+	Project:   "project",
+	Images:    []models.Image{{Image: "ghcr.io/shini4i/app", Tag: "v2"}},
+	Status:    models.StatusFailedMessage,
+	Validated: true,
+	Timeout:   60,
+}
+
+// rollbackApp returns a managed application opted into automatic rollbacks.
+func rollbackApp() *models.Application {
+	app := &models.Application{}
+	app.Metadata.Name = "demo"
+	app.Metadata.Annotations = map[string]string{
+		"argo-watcher/managed":       "true",
+		"argo-watcher/auto-rollback": "true",
+	}
+	return app
+}
+
+func newRollbackUpdater(state *mocks.MockTaskRepository, metrics *mocks.MockMetricsInterface) *ArgoStatusUpdater {
+	argo := Argo{State: state, metrics: metrics}
+	return &ArgoStatusUpdater{monitor: NewDeploymentMonitor(argo, "", nil, false, time.Millisecond)}
+}
+
+func expectDeployedHistory(state *mocks.MockTaskRepository, deployed ...models.Task) {
+	state.EXPECT().GetTasks(float64(0), gomock.Any(), "demo", models.StatusDeployedMessage, rollbackHistoryWindow, 0).
+		Return(deployed, int64(len(deployed)))
+}
+
+// expectNoPendingDeployment has the state report no deployment of the app that has
+// not reached an outcome.
+func expectNoPendingDeployment(state *mocks.MockTaskRepository) {
+	for _, status := range pendingStatuses {
+		state.EXPECT().GetTasks(float64(0), gomock.Any(), "demo", status, 1, 0).Return(nil, int64(0))
+	}
+}
+
+func TestStartAutomaticRollback(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	state := mocks.NewMockTaskRepository(ctrl)
+	metrics := mocks.NewMockMetricsInterface(ctrl)
+
+	// The most recent deployment is of the app's other image, which a rollback of
+	// ghcr.io/shini4i/app must not restore.
+	expectDeployedHistory(state,
+		models.Task{Id: "sidecar-id", Images: []models.Image{{Image: "ghcr.io/shini4i/sidecar", Tag: "v9"}}},
+		models.Task{
+			Id:         "previous-id",
+			Images:     []models.Image{{Image: "ghcr.io/shini4i/app", Tag: "v1"}},
+			Parameters: []models.Parameter{{Name: "features.checkout", Value: "false"}},
+		},
+	)
+	expectNoPendingDeployment(state)
+
+	var recorded models.Task
+	state.EXPECT().AddTask(gomock.Any()).DoAndReturn(func(task models.Task) (*models.Task, error) {
+		recorded = task
+		task.Id = "rollback-id"
+		task.Status = models.StatusInProgressMessage
+		return &task, nil
+	})
+	state.EXPECT().ClaimTask("rollback-id").Return(nil)
+	metrics.EXPECT().AddAcceptedDeployment()
+
+	rollback := newRollbackUpdater(state, metrics).startAutomaticRollback(failedTask, rollbackApp())
+	require.NotNil(t, rollback)
+
+	assert.Equal(t, "rollback-id", rollback.Id)
+	assert.Equal(t, []models.Image{{Image: "ghcr.io/shini4i/app", Tag: "v1"}}, recorded.Images)
+	assert.Equal(t, []models.Parameter{{Name: "features.checkout", Value: "false"}}, recorded.Parameters, "the rollback restores the parameters deployed with the images")
+	assert.True(t, recorded.IsRollback)
+	assert.Equal(t, "previous-id", recorded.RollbackTargetId)
+	assert.Equal(t, "failed-id", recorded.RollbackOfId)
+	assert.True(t, recorded.Validated, "the rollback writes back on the failed task's credential")
+	assert.Equal(t, "author", recorded.Author)
+	assert.Equal(t, 60, recorded.Timeout)
+}
+
+func TestStartAutomaticRollbackOfChartVersion(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	state := mocks.NewMockTaskRepository(ctrl)
+	metrics := mocks.NewMockMetricsInterface(ctrl)
+
+	// An image deployment of the app carries no chart version to restore.
+	expectDeployedHistory(state,
+		models.Task{Id: "image-id", Images: []models.Image{{Image: "ghcr.io/shini4i/app", Tag: "v9"}}},
+		models.Task{Id: "previous-id", ChartVersion: "1.3.0"},
+	)
+	expectNoPendingDeployment(state)
+
+	var recorded models.Task
+	state.EXPECT().AddTask(gomock.Any()).DoAndReturn(func(task models.Task) (*models.Task, error) {
+		recorded = task
+		task.Id = "rollback-id"
+		return &task, nil
+	})
+	state.EXPECT().ClaimTask("rollback-id").Return(nil)
+	metrics.EXPECT().AddAcceptedDeployment()
+
+	failed := failedTask
+	failed.Images = nil
+	failed.ChartVersion = "1.4.0"
+
+	rollback := newRollbackUpdater(state, metrics).startAutomaticRollback(failed, rollbackApp())
+	require.NotNil(t, rollback)
+
+	assert.Equal(t, "1.3.0", recorded.ChartVersion)
+	assert.Empty(t, recorded.Images)
+	assert.Equal(t, "previous-id", recorded.RollbackTargetId)
+}
+
+func TestStartAutomaticRollbackSkipsWhatIsNotToBeRolledBack(t *testing.T) {
+	optedOut := rollbackApp()
+	delete(optedOut.Metadata.Annotations, "argo-watcher/auto-rollback")
+
+	unmanaged := rollbackApp()
+	delete(unmanaged.Metadata.Annotations, "argo-watcher/managed")
+
+	aborted := failedTask
+	aborted.Status = models.StatusAborted
+
+	unvalidated := failedTask
+	unvalidated.Validated = false
+
+	rollback := failedTask
+	rollback.RollbackOfId = "earlier-failed-id"
+
+	tests := map[string]struct {
+		task models.Task
+		app  *models.Application
+	}{
+		"not opted in":                 {task: failedTask, app: optedOut},
+		"not managed":                  {task: failedTask, app: unmanaged},
+		"never observed in ArgoCD":     {task: failedTask, app: nil},
+		"not failed":                   {task: aborted, app: rollbackApp()},
+		"written back without a token": {task: unvalidated, app: rollbackApp()},
+		"a rollback itself":            {task: rollback, app: rollbackApp()},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			// No expectation: the state is not even consulted.
+			updater := newRollbackUpdater(newTaskRepositoryMock(ctrl), mocks.NewMockMetricsInterface(ctrl))
+			assert.Nil(t, updater.startAutomaticRollback(tt.task, tt.app))
+		})
+	}
+}
+
+func TestStartAutomaticRollbackWithoutAVersionToRestore(t *testing.T) {
+	tests := map[string][]models.Task{
+		"never deployed before": {
+			{Id: "sidecar-id", Images: []models.Image{{Image: "ghcr.io/shini4i/sidecar", Tag: "v9"}}},
+		},
+		"redeploy of the current version": {
+			{Id: "current-id", Images: []models.Image{{Image: "ghcr.io/shini4i/app", Tag: "v2"}}},
+		},
+	}
+
+	for name, deployed := range tests {
+		t.Run(name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			state := newTaskRepositoryMock(ctrl)
+			expectDeployedHistory(state, deployed...)
+
+			updater := newRollbackUpdater(state, mocks.NewMockMetricsInterface(ctrl))
+			assert.Nil(t, updater.startAutomaticRollback(failedTask, rollbackApp()))
+		})
+	}
+}
+
+func TestStartAutomaticRollbackDefersToANewerDeployment(t *testing.T) {
+	for _, status := range pendingStatuses {
+		t.Run(status, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			state := newTaskRepositoryMock(ctrl)
+			expectDeployedHistory(state, models.Task{Id: "previous-id", Images: []models.Image{{Image: "ghcr.io/shini4i/app", Tag: "v1"}}})
+			state.EXPECT().GetTasks(float64(0), gomock.Any(), "demo", status, 1, 0).
+				Return([]models.Task{{Id: "newer-id", Status: status}}, int64(1))
+			state.EXPECT().GetTasks(float64(0), gomock.Any(), "demo", gomock.Any(), 1, 0).Return(nil, int64(0)).AnyTimes()
+
+			// No AddTask expectation: the newer deployment replaces the failed version instead.
+			updater := newRollbackUpdater(state, mocks.NewMockMetricsInterface(ctrl))
+			assert.Nil(t, updater.startAutomaticRollback(failedTask, rollbackApp()))
+		})
+	}
+}
+
+func TestNoteRollback(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	state := mocks.NewMockTaskRepository(ctrl)
+	state.EXPECT().GetTask("failed-id").Return(&models.Task{Id: "failed-id", StatusReason: "ImagePullBackOff"}, nil)
+	state.EXPECT().SetTaskStatus("failed-id", models.StatusFailedMessage,
+		"ImagePullBackOff\n\nAutomatic rollback started: task rollback-id restores the last successful deployment.").Return(nil)
+
+	task := failedTask
+	newRollbackUpdater(state, mocks.NewMockMetricsInterface(ctrl)).noteRollback(&task, "rollback-id")
+
+	assert.Equal(t, "ImagePullBackOff\n\nAutomatic rollback started: task rollback-id restores the last successful deployment.", task.StatusReason,
+		"the failure notification names the rollback too")
+}
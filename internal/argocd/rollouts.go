@@ -0,0 +1,194 @@
+package argocd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+
+	"github.com/avast/retry-go/v4"
+
+	"github.com/shini4i/argo-watcher/internal/helpers"
+	"github.com/shini4i/argo-watcher/internal/models"
+)
+
+// RolloutPausedError ends the rollout of an application whose Argo Rollouts all paused on
+// their way to promotion, when the application counts that as the end of its deployment
+// (argo-watcher/rollout-pause-success). It travels the error path because it stops the
+// poll loop the way a terminal failure does, but it is reported as the "paused" success
+// state: the new version is serving the share of traffic its plan gives it, and promoting
+// it further is a decision argo-watcher does not wait for.
+type RolloutPausedError struct {
+	App string
+	// Progress lists the state of every Rollout of the application, one per line.
+	Progress string
+}
+
+func (err *RolloutPausedError) Error() string {
+	return fmt.Sprintf("rollout of application %q is paused waiting for promotion", err.App)
+}
+
+// Reason renders the user-facing task status reason.
+func (err *RolloutPausedError) Reason() string {
+	return fmt.Sprintf("Rollout paused waiting for promotion.\n\n%s", err.Progress)
+}
+
+// rolloutTracker follows the Argo Rollouts updates of one deployment across polls.
+type rolloutTracker struct {
+	// progress is the report last written to the task, so an unchanged one is not
+	// written again on every poll.
+	progress string
+	// position is where the Rollouts stood on the previous poll (see models.Rollout.Position).
+	position string
+}
+
+// observeRollouts reports the progress of the Argo Rollouts the application owns while its
+// rollout is not final. ArgoCD shows such an application as Progressing or Suspended for as
+// long as a canary or a blue-green preview lasts, which says nothing about how far along it
+// is; the Rollouts' own status does.
+//
+// The step and traffic weight are written to the task's status reason, and a Rollout that
+// advanced since the previous poll extends the deadline (see rolloutClock). It returns an
+// unrecoverable *RolloutPausedError once every Rollout is paused for promotion and the
+// application counts that as success, and nil otherwise: all of this is best-effort, and a
+// failed lookup leaves the rollout to be judged on the application state alone.
+func (monitor *DeploymentMonitor) observeRollouts(ctx context.Context, task models.Task, app *models.Application, tracker *rolloutTracker, clock *rolloutClock) error {
+	tree, err := monitor.argo.api.GetResourceTree(ctx, task.App)
+	if err != nil {
+		slog.Debug("Could not fetch resource tree to report rollout progress", "error", err, "id", task.Id)
+		return nil
+	}
+
+	nodes := tree.RolloutNodes()
+	if len(nodes) == 0 {
+		return nil
+	}
+
+	rollouts := make([]*models.Rollout, 0, len(nodes))
+	for _, node := range nodes {
+		rollout, err := monitor.argo.api.GetRollout(ctx, task.App, node)
+		if err != nil {
+			// A report naming only some of the Rollouts would read as the whole story,
+			// and judging a pause on them could end a deployment still under way.
+			slog.Debug("Could not fetch rollout to report its progress", "rollout", node.Name, "error", err, "id", task.Id)
+			return nil
+		}
+		rollouts = append(rollouts, rollout)
+	}
+
+	lines := make([]string, 0, len(rollouts))
+	positions := make([]string, 0, len(rollouts))
+	paused := true
+	for _, rollout := range rollouts {
+		lines = append(lines, rollout.Progress())
+		positions = append(positions, rollout.Position())
+		paused = paused && rollout.IsPausedForPromotion()
+	}
+
+	progress := strings.Join(lines, "\n")
+	if progress != tracker.progress {
+		if err := monitor.argo.State.SetTaskProgress(task.Id, progress); err != nil {
+			slog.Warn("Failed to record rollout progress", "error", err, "id", task.Id)
+		} else {
+			tracker.progress = progress
+		}
+	}
+
+	position := strings.Join(positions, ",")
+	if tracker.position != "" && position != tracker.position && clock.extend() {
+		slog.Info("Rollout advanced; extending the deployment deadline", "app", task.App, "id", task.Id)
+	}
+	tracker.position = position
+
+	if paused && app.IsRolloutPauseSuccess() {
+		return retry.Unrecoverable(&RolloutPausedError{App: task.App, Progress: progress})
+	}
+
+	return nil
+}
+
+// HandleRolloutPaused records a deployment that ended with its Rollouts paused for promotion.
+// It counts as a success: the failed-deployment gauge is reset as for a finished rollout.
+func (monitor *DeploymentMonitor) HandleRolloutPaused(task *models.Task, pausedErr *RolloutPausedError) {
+	slog.Info("App rollout is paused waiting for promotion.", "app", pausedErr.App, "id", task.Id)
+	monitor.argo.metrics.ResetFailedDeployment(task.App)
+
+	if err := monitor.argo.State.SetTaskStatus(task.Id, models.StatusPausedMessage, pausedErr.Reason()); err != nil {
+		slog.Error("Failed to change task status", "error", err, "id", task.Id)
+	}
+	task.Status = models.StatusPausedMessage
+}
+
+// rolloutClock is the deadline of one poll loop. Without a maximum duration configured
+// (ROLLOUT_MAX_DURATION) it is a plain context deadline, fixed at the rollout window. With
+// one, the window restarts every time an Argo Rollouts update advances a step, so a canary
+// that keeps moving is not cut off by a timeout sized for a plain Deployment, while one
+// stuck on a step still fails a window after its last advance. The maximum bounds the
+// whole loop either way.
+//
+// A movable deadline cannot be a context deadline, so the loop's context is cancelled by a
+// timer instead, which also interrupts the wait between polls as a deadline would.
+type rolloutClock struct {
+	window  time.Duration
+	ceiling time.Time
+	// timer is nil when the deadline cannot move.
+	timer *time.Timer
+}
+
+// startRolloutClock returns the context bounding a poll loop given window, the clock that
+// moves its deadline, and the function releasing both.
+func (monitor *DeploymentMonitor) startRolloutClock(window time.Duration) (context.Context, *rolloutClock, func()) {
+	clock := &rolloutClock{window: window}
+
+	if monitor.rolloutMaxDuration <= window {
+		ctx, cancel := context.WithTimeout(context.Background(), window)
+		return ctx, clock, cancel
+	}
+
+	ctx, cancel := context.WithCancelCause(context.Background())
+	clock.ceiling = time.Now().Add(monitor.rolloutMaxDuration)
+	clock.timer = time.AfterFunc(window, func() { cancel(context.DeadlineExceeded) })
+
+	return ctx, clock, func() {
+		clock.timer.Stop()
+		cancel(context.Canceled)
+	}
+}
+
+// extendable reports whether the deadline can move at all.
+func (clock *rolloutClock) extendable() bool {
+	return clock.timer != nil
+}
+
+// extend restarts the window from now, up to the ceiling, and reports whether it did. A
+// deadline that already passed stays passed.
+func (clock *rolloutClock) extend() bool {
+	if !clock.extendable() {
+		return false
+	}
+
+	remaining := min(clock.window, time.Until(clock.ceiling))
+	if remaining <= 0 || !clock.timer.Stop() {
+		return false
+	}
+
+	clock.timer.Reset(remaining)
+	return true
+}
+
+// deadlineErr restores the deadline error for a loop ended by the clock's timer, which can
+// only cancel the context: the failure is reported the same whether the deadline moved or not.
+func deadlineErr(ctx context.Context, err error) error {
+	if errors.Is(err, context.Canceled) && errors.Is(context.Cause(ctx), context.DeadlineExceeded) {
+		return context.DeadlineExceeded
+	}
+	return err
+}
+
+// extendedAttempts is how many polls a loop whose deadline can move is given: enough to
+// poll for the whole maximum duration, since the clock rather than the count ends it.
+func (monitor *DeploymentMonitor) extendedAttempts(delay time.Duration) uint {
+	return helpers.SafeIntToUint(helpers.CeilDivDuration(monitor.rolloutMaxDuration, delay) + 1)
+}
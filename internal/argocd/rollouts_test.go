@@ -0,0 +1,255 @@
+package argocd
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/avast/retry-go/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
+
+	"github.com/shini4i/argo-watcher/internal/mocks"
+	"github.com/shini4i/argo-watcher/internal/models"
+)
+
+var rolloutTask = models.Task{
+	Id:     "task-id",
+	App:    "demo",
+	Images: []models.Image{{Image: "ghcr.io/shini4i/app", Tag: "v2"}},
+}
+
+var rolloutTree = &models.ApplicationTree{Nodes: []models.ApplicationTreeNode{
+	{Group: "argoproj.io", Version: "v1alpha1", Kind: "Rollout", Name: "web", Namespace: "apps"},
+}}
+
+// canaryApp is an application whose canary runs the task's image next to the stable one,
+// which ArgoCD reports as Suspended (paused on a step) or Progressing.
+func canaryApp(health string, annotations map[string]string) *models.Application {
+	app := &models.Application{}
+	app.Metadata.Annotations = annotations
+	app.Status.Summary.Images = []string{"ghcr.io/shini4i/app:v1", "ghcr.io/shini4i/app:v2"}
+	app.Status.Sync.Status = "Synced"
+	app.Status.Health.Status = health
+	return app
+}
+
+// canaryAt returns a five-step canary on the given 0-based step.
+func canaryAt(step int32, phase, pauseReason string) *models.Rollout {
+	rollout := &models.Rollout{}
+	rollout.Metadata.Name = "web"
+	weight := int32(20)
+	rollout.Spec.Strategy.Canary = &struct {
+		Steps []models.RolloutStep `json:"steps"`
+	}{Steps: []models.RolloutStep{{SetWeight: &weight}, {}, {}, {}, {}}}
+	rollout.Status.Phase = phase
+	rollout.Status.CurrentStepIndex = &step
+	if pauseReason != "" {
+		rollout.Status.PauseConditions = []struct {
+			Reason string `json:"reason"`
+		}{{Reason: pauseReason}}
+	}
+	return rollout
+}
+
+func newRolloutMonitor(api ArgoApiInterface, state *mocks.MockTaskRepository) *DeploymentMonitor {
+	return NewDeploymentMonitor(
+		Argo{api: api, State: state},
+		"",
+		[]retry.Option{retry.DelayType(zeroDelay), retry.LastErrorOnly(true)},
+		false,
+		time.Millisecond,
+	)
+}
+
+func TestWaitRolloutReportsRolloutProgress(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	healthy := canaryApp("Healthy", nil)
+	healthy.Status.Summary.Images = []string{"ghcr.io/shini4i/app:v2"}
+
+	api := mocks.NewMockArgoApiInterface(ctrl)
+	api.EXPECT().GetApplication(gomock.Any(), rolloutTask.App, false).DoAndReturn(appSequence(
+		canaryApp("Suspended", nil),
+		canaryApp("Suspended", nil),
+		canaryApp("Progressing", nil),
+		healthy,
+	)).Times(4)
+	api.EXPECT().GetResourceTree(gomock.Any(), rolloutTask.App).Return(rolloutTree, nil).Times(3)
+	gomock.InOrder(
+		api.EXPECT().GetRollout(gomock.Any(), rolloutTask.App, rolloutTree.Nodes[0]).Return(canaryAt(1, "Paused", "CanaryPauseStep"), nil).Times(2),
+		api.EXPECT().GetRollout(gomock.Any(), rolloutTask.App, rolloutTree.Nodes[0]).Return(canaryAt(2, "Progressing", ""), nil),
+	)
+
+	// An unchanged report is written once, however many polls observe it.
+	state := notSupersededState(ctrl)
+	gomock.InOrder(
+		state.EXPECT().SetTaskProgress(rolloutTask.Id, `Rollout "web": canary step 2 of 5, 20% of traffic, paused`),
+		state.EXPECT().SetTaskProgress(rolloutTask.Id, `Rollout "web": canary step 3 of 5, 20% of traffic, progressing`),
+	)
+
+	_, _, err := newRolloutMonitor(api, state).WaitRollout(rolloutTask, neverLost)
+	require.NoError(t, err)
+}
+
+func TestWaitRolloutEndsOnPausedRolloutWhenAccepted(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	api := mocks.NewMockArgoApiInterface(ctrl)
+	api.EXPECT().GetApplication(gomock.Any(), rolloutTask.App, false).
+		Return(canaryApp("Suspended", map[string]string{"argo-watcher/rollout-pause-success": "true"}), nil).Times(1)
+	api.EXPECT().GetResourceTree(gomock.Any(), rolloutTask.App).Return(rolloutTree, nil)
+	api.EXPECT().GetRollout(gomock.Any(), rolloutTask.App, gomock.Any()).Return(canaryAt(1, "Paused", "CanaryPauseStep"), nil)
+
+	state := notSupersededState(ctrl)
+	state.EXPECT().SetTaskProgress(rolloutTask.Id, gomock.Any())
+
+	_, _, err := newRolloutMonitor(api, state).WaitRollout(rolloutTask, neverLost)
+
+	var pausedErr *RolloutPausedError
+	require.ErrorAs(t, err, &pausedErr)
+	assert.Equal(t, "Rollout paused waiting for promotion.\n\nRollout \"web\": canary step 2 of 5, 20% of traffic, paused", pausedErr.Reason())
+}
+
+func TestWaitRolloutKeepsPollingPausedRolloutByDefault(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	api := mocks.NewMockArgoApiInterface(ctrl)
+	api.EXPECT().GetApplication(gomock.Any(), rolloutTask.App, false).Return(canaryApp("Suspended", nil), nil).Times(2)
+	api.EXPECT().GetResourceTree(gomock.Any(), rolloutTask.App).Return(rolloutTree, nil).Times(2)
+	api.EXPECT().GetRollout(gomock.Any(), rolloutTask.App, gomock.Any()).Return(canaryAt(1, "Paused", "CanaryPauseStep"), nil).Times(2)
+
+	state := notSupersededState(ctrl)
+	state.EXPECT().SetTaskProgress(rolloutTask.Id, gomock.Any()).Times(1)
+
+	monitor := newRolloutMonitor(api, state)
+	monitor.defaultAttempts = 2
+
+	application, _, err := monitor.WaitRollout(rolloutTask, neverLost)
+	require.NoError(t, err)
+	assert.Equal(t, "Suspended", application.Status.Health.Status)
+}
+
+func TestWaitRolloutIgnoresUnreadableRollouts(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	api := mocks.NewMockArgoApiInterface(ctrl)
+	api.EXPECT().GetApplication(gomock.Any(), rolloutTask.App, false).
+		Return(canaryApp("Suspended", map[string]string{"argo-watcher/rollout-pause-success": "true"}), nil).Times(1)
+	api.EXPECT().GetResourceTree(gomock.Any(), rolloutTask.App).Return(rolloutTree, nil)
+	api.EXPECT().GetRollout(gomock.Any(), rolloutTask.App, gomock.Any()).Return(nil, fmt.Errorf("permission denied"))
+
+	// No SetTaskProgress expectation: nothing is reported from a failed lookup.
+	monitor := newRolloutMonitor(api, notSupersededState(ctrl))
+	monitor.defaultAttempts = 1
+
+	_, _, err := monitor.WaitRollout(rolloutTask, neverLost)
+	require.NoError(t, err)
+}
+
+// advancingRollout serves a canary that moves one step every call after pausing for
+// delay, standing in for a canary whose steps take longer than the rollout window.
+func advancingRollout(delay time.Duration, advance bool) func(context.Context, string, models.ApplicationTreeNode) (*models.Rollout, error) {
+	step := int32(0)
+	return func(context.Context, string, models.ApplicationTreeNode) (*models.Rollout, error) {
+		time.Sleep(delay)
+		if advance {
+			step++
+		}
+		return canaryAt(step, "Progressing", ""), nil
+	}
+}
+
+func newExtendingMonitor(ctrl *gomock.Controller, api ArgoApiInterface) *DeploymentMonitor {
+	state := notSupersededState(ctrl)
+	state.EXPECT().SetTaskProgress(gomock.Any(), gomock.Any()).AnyTimes()
+
+	// A 100ms window, which the test's slow rollout lookups outlast within a few polls.
+	monitor := newRolloutMonitor(api, state)
+	monitor.retryDelay = 50 * time.Millisecond
+	monitor.defaultAttempts = 2
+	monitor.rolloutMaxDuration = 5 * time.Second
+	return monitor
+}
+
+func TestWaitRolloutExtendsDeadlineWhileStepsAdvance(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	healthy := canaryApp("Healthy", nil)
+	healthy.Status.Summary.Images = []string{"ghcr.io/shini4i/app:v2"}
+
+	// Four steps of the five-step canary, the last of which lands past the initial window.
+	progressing := make([]*models.Application, 4)
+	for index := range progressing {
+		progressing[index] = canaryApp("Progressing", nil)
+	}
+
+	api := mocks.NewMockArgoApiInterface(ctrl)
+	api.EXPECT().GetApplication(gomock.Any(), rolloutTask.App, false).
+		DoAndReturn(appSequence(append(progressing, healthy)...)).Times(len(progressing) + 1)
+	api.EXPECT().GetResourceTree(gomock.Any(), rolloutTask.App).Return(rolloutTree, nil).Times(len(progressing))
+	api.EXPECT().GetRollout(gomock.Any(), rolloutTask.App, gomock.Any()).
+		DoAndReturn(advancingRollout(40*time.Millisecond, true)).Times(len(progressing))
+
+	application, waited, err := newExtendingMonitor(ctrl, api).WaitRollout(rolloutTask, neverLost)
+	require.NoError(t, err)
+	assert.Equal(t, "Healthy", application.Status.Health.Status)
+	assert.Greater(t, waited, 100*time.Millisecond, "the rollout outlasted its window, so the deadline must have moved")
+}
+
+func TestWaitRolloutDoesNotExtendDeadlineForStalledRollout(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	api := mocks.NewMockArgoApiInterface(ctrl)
+	api.EXPECT().GetApplication(gomock.Any(), rolloutTask.App, false).Return(canaryApp("Progressing", nil), nil).MinTimes(1)
+	api.EXPECT().GetResourceTree(gomock.Any(), rolloutTask.App).Return(rolloutTree, nil).AnyTimes()
+	api.EXPECT().GetRollout(gomock.Any(), rolloutTask.App, gomock.Any()).
+		DoAndReturn(advancingRollout(40*time.Millisecond, false)).AnyTimes()
+
+	application, waited, err := newExtendingMonitor(ctrl, api).WaitRollout(rolloutTask, neverLost)
+	require.NoError(t, err)
+	assert.Equal(t, "Progressing", application.Status.Health.Status)
+	assert.Less(t, waited, time.Second, "a rollout that stopped advancing must time out a window after its last step")
+}
+
+func TestWaitForRolloutRecordsPausedRollout(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	task := rolloutTask
+	task.Validated = true
+	app := canaryApp("Suspended", map[string]string{"argo-watcher/rollout-pause-success": "true"})
+
+	api := mocks.NewMockArgoApiInterface(ctrl)
+	api.EXPECT().GetApplication(gomock.Any(), task.App, gomock.Any()).Return(app, nil).Times(2)
+	api.EXPECT().GetResourceTree(gomock.Any(), task.App).Return(rolloutTree, nil)
+	api.EXPECT().GetRollout(gomock.Any(), task.App, gomock.Any()).Return(canaryAt(1, "Paused", "CanaryPauseStep"), nil)
+
+	metrics := mocks.NewMockMetricsInterface(ctrl)
+	metrics.EXPECT().AddInProgressTask()
+	metrics.EXPECT().RemoveInProgressTask()
+	metrics.EXPECT().ResetFailedDeployment(task.App)
+	metrics.EXPECT().AddDeploymentOutcome(task.App, models.StatusPausedMessage)
+
+	state := notSupersededState(ctrl)
+	state.EXPECT().SetTaskProgress(task.Id, gomock.Any())
+	state.EXPECT().SetTaskStatus(task.Id, models.StatusPausedMessage, gomock.Any()).
+		DoAndReturn(func(_, _, reason string) error {
+			assert.Contains(t, reason, `Rollout "web": canary step 2 of 5, 20% of traffic, paused`)
+			return nil
+		})
+
+	argo := &Argo{}
+	argo.Init(state, api, metrics)
+	updater := initTestUpdater(t, newUpdaterTestConfig(&spyLocker{}), argo)
+
+	updater.WaitForRollout(task, false)
+}
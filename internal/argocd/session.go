@@ -0,0 +1,180 @@
+package argocd
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+
+	"github.com/shini4i/argo-watcher/internal/models"
+)
+
+// sessionRenewShare is the share of a session token's lifetime after which it is renewed.
+// Renewing ahead of the expiry, rather than on the first 401, keeps a request from being
+// sent with a token that expires while it is in flight.
+const sessionRenewShare = 0.8
+
+// argoTokenCookie is the cookie ArgoCD reads the API token from.
+const argoTokenCookie = "argocd.token"
+
+// tokenExpiryRecorder is the part of the metrics that records the token expiry.
+type tokenExpiryRecorder interface {
+	SetArgoTokenExpiry(expiry time.Time)
+}
+
+// argoSession is an ArgoCD session logged in with account credentials. Its token is
+// renewed once it is past sessionRenewShare of its lifetime, and whenever ArgoCD rejects
+// it — a token outlives neither a restart of ArgoCD with a new signing key nor the
+// account's tokens being revoked.
+type argoSession struct {
+	username string
+	password string
+
+	// mu serializes logins: requests that find the token due for renewal at the same time
+	// wait for one login instead of each making their own.
+	mu      sync.Mutex
+	token   string
+	renewAt time.Time
+}
+
+// SetTokenMetrics sets where the expiry of the token is recorded. A static token is
+// recorded as soon as Init runs, a session token on every login.
+func (api *ArgoApi) SetTokenMetrics(metrics tokenExpiryRecorder) {
+	api.tokenMetrics = metrics
+}
+
+// setToken hands the token to the cookie jar every request is sent through.
+func (api *ArgoApi) setToken(token string) {
+	// This is an outbound request cookie sent to the ArgoCD API through the
+	// client's cookie jar, not a Set-Cookie response to a browser, so G124's
+	// Secure/HttpOnly/SameSite attributes do not apply — the Go HTTP client
+	// ignores those browser-storage directives when sending.
+	cookie := &http.Cookie{ // #nosec G124
+		Name:  argoTokenCookie,
+		Value: token,
+	}
+	api.client.Jar.SetCookies(&api.baseUrl, []*http.Cookie{cookie})
+}
+
+// recordTokenExpiry records when token expires, as far as it can be told: an opaque token,
+// or a JWT without an expiry, is recorded as one that never expires.
+func (api *ArgoApi) recordTokenExpiry(token string) time.Time {
+	expiry := tokenExpiry(token)
+	if api.tokenMetrics != nil {
+		api.tokenMetrics.SetArgoTokenExpiry(expiry)
+	}
+	return expiry
+}
+
+// tokenExpiry reads the expiry of an ArgoCD token. The signature is not checked: the
+// token is ArgoCD's to verify, and its expiry is only used to schedule the renewal.
+func tokenExpiry(token string) time.Time {
+	claims := jwt.RegisteredClaims{}
+	if _, _, err := jwt.NewParser().ParseUnverified(token, &claims); err != nil || claims.ExpiresAt == nil {
+		return time.Time{}
+	}
+	return claims.ExpiresAt.Time
+}
+
+// withSession makes an API call with a valid session token. A call ArgoCD answers with
+// 401 is made once more with a renewed token; ArgoCD acted on none of it, so that is
+// safe for a POST as well. Without a session the call is made as it is.
+func (api *ArgoApi) withSession(ctx context.Context, call func() ([]byte, int, error)) ([]byte, int, error) {
+	if api.session == nil {
+		return call()
+	}
+
+	token, err := api.sessionToken(ctx, "")
+	if err != nil {
+		return nil, 0, err
+	}
+
+	body, statusCode, err := call()
+	if err != nil || statusCode != http.StatusUnauthorized {
+		return body, statusCode, err
+	}
+
+	slog.Info("ArgoCD rejected the session token; logging in again", "username", api.session.username)
+	if _, err := api.sessionToken(ctx, token); err != nil {
+		return nil, 0, err
+	}
+
+	return call()
+}
+
+// currentToken returns the token the session last logged in with.
+func (session *argoSession) currentToken() string {
+	session.mu.Lock()
+	defer session.mu.Unlock()
+	return session.token
+}
+
+// sessionToken returns the session token, logging in first when there is none yet, when
+// it is due for renewal, or when it is rejected — the token passed as rejected. A token
+// that was rejected but has already been replaced by a concurrent call is not renewed
+// again.
+func (api *ArgoApi) sessionToken(ctx context.Context, rejected string) (string, error) {
+	session := api.session
+	session.mu.Lock()
+	defer session.mu.Unlock()
+
+	renew := session.token == "" ||
+		(rejected != "" && rejected == session.token) ||
+		(!session.renewAt.IsZero() && time.Now().After(session.renewAt))
+	if !renew {
+		return session.token, nil
+	}
+
+	token, err := api.login(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	issued := time.Now()
+	session.token = token
+	session.renewAt = time.Time{}
+	if expiry := api.recordTokenExpiry(token); !expiry.IsZero() {
+		session.renewAt = issued.Add(time.Duration(float64(expiry.Sub(issued)) * sessionRenewShare))
+	}
+	api.setToken(token)
+
+	slog.Debug("Logged in to ArgoCD", "username", session.username, "renew_at", session.renewAt)
+	return token, nil
+}
+
+// login exchanges the account credentials for a session token through ArgoCD's
+// /api/v1/session. It is a single attempt, as for any POST (see doPost); a failed
+// login fails the call that needed it, and the next call tries again.
+func (api *ArgoApi) login(ctx context.Context) (string, error) {
+	apiUrl := fmt.Sprintf("%s/api/v1/session", api.baseUrl.String())
+
+	body, statusCode, err := api.post(ctx, apiUrl, models.SessionRequest{
+		Username: api.session.username,
+		Password: api.session.password,
+	})
+	if err != nil {
+		return "", err
+	}
+
+	if statusCode != http.StatusOK {
+		loginErr := parseArgoErrorResponse(statusCode, body)
+		var apiErr *ArgoAPIError
+		if errors.As(loginErr, &apiErr) {
+			apiErr.Message = fmt.Sprintf("ArgoCD login as %q failed: %s", api.session.username, apiErr.Message)
+		}
+		return "", loginErr
+	}
+
+	var session models.SessionResponse
+	if err := json.Unmarshal(body, &session); err != nil || session.Token == "" {
+		return "", fmt.Errorf("could not parse ArgoCD session response: %s", body)
+	}
+
+	return session.Token, nil
+}
@@ -0,0 +1,223 @@
+package argocd
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/shini4i/argo-watcher/internal/config"
+	"github.com/shini4i/argo-watcher/internal/models"
+)
+
+// expiryRecorder records the token expiries reported to the metrics.
+type expiryRecorder struct {
+	mu       sync.Mutex
+	expiries []time.Time
+}
+
+func (recorder *expiryRecorder) SetArgoTokenExpiry(expiry time.Time) {
+	recorder.mu.Lock()
+	defer recorder.mu.Unlock()
+	recorder.expiries = append(recorder.expiries, expiry)
+}
+
+// fakeArgoSessions is an ArgoCD that issues session tokens to one account and accepts
+// only the token it issued last.
+type fakeArgoSessions struct {
+	mu     sync.Mutex
+	logins int
+	valid  string
+	expiry time.Time
+}
+
+func (fake *fakeArgoSessions) issue(t *testing.T) string {
+	fake.logins++
+	claims := jwt.RegisteredClaims{Subject: "argo-watcher", ID: fmt.Sprint(fake.logins), ExpiresAt: jwt.NewNumericDate(fake.expiry)}
+	token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString([]byte("argocd-server-key"))
+	require.NoError(t, err)
+	fake.valid = token
+	return token
+}
+
+// revoke makes ArgoCD reject the token it issued last, as a restart with a new key would.
+func (fake *fakeArgoSessions) revoke() {
+	fake.mu.Lock()
+	defer fake.mu.Unlock()
+	fake.valid = "revoked"
+}
+
+func (fake *fakeArgoSessions) handler(t *testing.T) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fake.mu.Lock()
+		defer fake.mu.Unlock()
+
+		if r.URL.Path == "/api/v1/session" {
+			var request models.SessionRequest
+			require.NoError(t, json.NewDecoder(r.Body).Decode(&request))
+			if request.Username != "argo-watcher" || request.Password != "secret" {
+				w.WriteHeader(http.StatusUnauthorized)
+				_, _ = w.Write([]byte(`{"error":"Invalid username or password","code":16,"message":"Invalid username or password"}`))
+				return
+			}
+			require.NoError(t, json.NewEncoder(w).Encode(models.SessionResponse{Token: fake.issue(t)}))
+			return
+		}
+
+		cookie, err := r.Cookie(argoTokenCookie)
+		if r.URL.Path == "/api/v1/session/userinfo" {
+			// ArgoCD answers userinfo without rejecting an expired token.
+			loggedIn := err == nil && cookie.Value == fake.valid
+			require.NoError(t, json.NewEncoder(w).Encode(models.Userinfo{LoggedIn: loggedIn, Username: "argo-watcher"}))
+			return
+		}
+		if err != nil || cookie.Value != fake.valid {
+			w.WriteHeader(http.StatusUnauthorized)
+			_, _ = w.Write([]byte(`{"error":"invalid session","code":16,"message":"invalid session"}`))
+			return
+		}
+		_, _ = w.Write([]byte(`{"metadata":{"name":"demo"}}`))
+	})
+}
+
+func newSessionApi(t *testing.T, server *httptest.Server, password string) (*ArgoApi, *expiryRecorder) {
+	argoURL, err := url.Parse(server.URL)
+	require.NoError(t, err)
+
+	recorder := &expiryRecorder{}
+	api := NewArgoApi()
+	api.SetTokenMetrics(recorder)
+	require.NoError(t, api.Init(&config.ServerConfig{
+		ArgoUrl:        *argoURL,
+		ArgoUsername:   "argo-watcher",
+		ArgoPassword:   password,
+		ArgoApiTimeout: 5,
+		ArgoApiRetries: 1,
+	}))
+	return api, recorder
+}
+
+func TestArgoApiSessionLogsInOnce(t *testing.T) {
+	fake := &fakeArgoSessions{expiry: time.Now().Add(24 * time.Hour).Truncate(time.Second)}
+	server := httptest.NewServer(fake.handler(t))
+	defer server.Close()
+
+	api, recorder := newSessionApi(t, server, "secret")
+	assert.Equal(t, 0, fake.logins, "nothing is asked of ArgoCD before the first call")
+
+	for range 3 {
+		app, err := api.GetApplication(context.Background(), "demo", false)
+		require.NoError(t, err)
+		assert.Equal(t, "demo", app.Metadata.Name)
+	}
+
+	assert.Equal(t, 1, fake.logins)
+	assert.Equal(t, []time.Time{fake.expiry}, recorder.expiries)
+}
+
+func TestArgoApiSessionRenewsRejectedToken(t *testing.T) {
+	fake := &fakeArgoSessions{expiry: time.Now().Add(24 * time.Hour)}
+	server := httptest.NewServer(fake.handler(t))
+	defer server.Close()
+
+	api, _ := newSessionApi(t, server, "secret")
+	_, err := api.GetApplication(context.Background(), "demo", false)
+	require.NoError(t, err)
+
+	fake.revoke()
+
+	_, err = api.GetApplication(context.Background(), "demo", false)
+	require.NoError(t, err, "a rejected token is renewed and the call made again")
+	assert.Equal(t, 2, fake.logins)
+}
+
+func TestArgoApiSessionRenewsTokenReportedLoggedOut(t *testing.T) {
+	fake := &fakeArgoSessions{expiry: time.Now().Add(24 * time.Hour)}
+	server := httptest.NewServer(fake.handler(t))
+	defer server.Close()
+
+	api, _ := newSessionApi(t, server, "secret")
+	userInfo, err := api.GetUserInfo()
+	require.NoError(t, err)
+	assert.True(t, userInfo.LoggedIn)
+
+	fake.revoke()
+
+	userInfo, err = api.GetUserInfo()
+	require.NoError(t, err)
+	assert.True(t, userInfo.LoggedIn, "a token ArgoCD reports as logged out is renewed and the call made again")
+	assert.Equal(t, 2, fake.logins)
+}
+
+func TestArgoApiSessionRenewsAheadOfExpiry(t *testing.T) {
+	fake := &fakeArgoSessions{expiry: time.Now().Add(time.Hour)}
+	server := httptest.NewServer(fake.handler(t))
+	defer server.Close()
+
+	api, _ := newSessionApi(t, server, "secret")
+	_, err := api.GetApplication(context.Background(), "demo", false)
+	require.NoError(t, err)
+	assert.WithinDuration(t, time.Now().Add(48*time.Minute), api.session.renewAt, time.Minute,
+		"renewed after 80% of the lifetime")
+
+	api.session.renewAt = time.Now().Add(-time.Second)
+
+	_, err = api.GetApplication(context.Background(), "demo", false)
+	require.NoError(t, err)
+	assert.Equal(t, 2, fake.logins)
+}
+
+func TestArgoApiSessionLoginFailure(t *testing.T) {
+	fake := &fakeArgoSessions{expiry: time.Now().Add(time.Hour)}
+	server := httptest.NewServer(fake.handler(t))
+	defer server.Close()
+
+	api, recorder := newSessionApi(t, server, "wrong")
+	_, err := api.GetUserInfo()
+
+	var apiErr *ArgoAPIError
+	require.ErrorAs(t, err, &apiErr)
+	assert.Equal(t, http.StatusUnauthorized, apiErr.StatusCode)
+	assert.Equal(t, `ArgoCD login as "argo-watcher" failed: Invalid username or password`, err.Error())
+	assert.Empty(t, recorder.expiries)
+}
+
+func TestArgoApiStaticTokenExpiry(t *testing.T) {
+	argoURL, err := url.Parse("https://example.com")
+	require.NoError(t, err)
+
+	expiry := time.Now().Add(72 * time.Hour).Truncate(time.Second)
+	token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.RegisteredClaims{ExpiresAt: jwt.NewNumericDate(expiry)}).
+		SignedString([]byte("argocd-server-key"))
+	require.NoError(t, err)
+
+	testCases := []struct {
+		name     string
+		token    string
+		expected time.Time
+	}{
+		{name: "expiring token", token: token, expected: expiry},
+		{name: "opaque token", token: "super-secret", expected: time.Time{}},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			recorder := &expiryRecorder{}
+			api := NewArgoApi()
+			api.SetTokenMetrics(recorder)
+			require.NoError(t, api.Init(&config.ServerConfig{ArgoUrl: *argoURL, ArgoToken: tc.token}))
+
+			assert.Nil(t, api.session)
+			assert.Equal(t, []time.Time{tc.expected}, recorder.expiries)
+		})
+	}
+}
@@ -0,0 +1,103 @@
+package argocd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+
+	"github.com/avast/retry-go/v4"
+
+	"github.com/shini4i/argo-watcher/internal/models"
+)
+
+// argoOperationInProgressMessage is how ArgoCD refuses a sync while another operation on the
+// application is still running. The refusal is temporary, so the request is repeated.
+const argoOperationInProgressMessage = "another operation is already in progress"
+
+// SyncOperationError reports that the sync argo-watcher triggered for an application without
+// auto-sync failed, or could not be requested at all. Nothing else applies the new desired
+// state, so waiting for the rollout would only end in a timeout.
+type SyncOperationError struct {
+	App string
+	// Report is what ArgoCD said about the failure: the operation's phase, message and failed
+	// resources, or the reason the request was refused.
+	Report string
+}
+
+func (err *SyncOperationError) Error() string {
+	return fmt.Sprintf("sync of application %q did not succeed", err.App)
+}
+
+// Reason renders the user-facing task failure reason.
+func (err *SyncOperationError) Reason() string {
+	return fmt.Sprintf(
+		"Application deployment failed. The ArgoCD sync argo-watcher triggered for %q did not succeed.\n\n%s",
+		err.App,
+		err.Report,
+	)
+}
+
+// syncTracker follows the sync argo-watcher triggers during one rollout.
+type syncTracker struct {
+	requested bool
+	// previousStart is the start time of the operation the application last recorded before
+	// the sync was requested. An operation state with any other start time is the one
+	// argo-watcher asked for.
+	previousStart string
+}
+
+// advanceSync drives the sync of an application that is not synced automatically. On the
+// polls before ArgoCD accepts the request it asks again; afterwards it holds the rollout check
+// back until ArgoCD has picked the operation up, since until then the application state
+// predates the sync. It returns nil once the rollout can be judged on the application state,
+// errForceRetry while it cannot yet, and an unrecoverable *SyncOperationError when the sync
+// failed.
+func (monitor *DeploymentMonitor) advanceSync(ctx context.Context, task models.Task, app *models.Application, tracker *syncTracker) error {
+	if !tracker.requested {
+		if err := monitor.requestSync(ctx, task, app, tracker); err != nil {
+			return retry.Unrecoverable(err)
+		}
+		return errForceRetry
+	}
+
+	operation := app.Status.OperationState
+	if operation.StartedAt == tracker.previousStart {
+		slog.Debug("Waiting for ArgoCD to start the requested sync", "id", task.Id)
+		return errForceRetry
+	}
+
+	if operation.IsFailed() {
+		return retry.Unrecoverable(&SyncOperationError{App: task.App, Report: app.SyncOperationReport()})
+	}
+
+	return nil
+}
+
+// requestSync asks ArgoCD to sync the application and records whether it accepted. A refusal
+// that can clear up by itself — another operation still running, ArgoCD unreachable — leaves
+// the request pending for the next poll; any other refusal is returned as a
+// *SyncOperationError, as it would only be repeated on every poll until the deadline.
+func (monitor *DeploymentMonitor) requestSync(ctx context.Context, task models.Task, app *models.Application, tracker *syncTracker) error {
+	tracker.previousStart = app.Status.OperationState.StartedAt
+
+	_, err := monitor.argo.api.SyncApplication(ctx, task.App, app.SyncRequest())
+	if err == nil {
+		slog.Info("Triggered ArgoCD sync for an application without auto-sync", "app", task.App, "id", task.Id)
+		tracker.requested = true
+		return nil
+	}
+
+	var apiErr *ArgoAPIError
+	switch {
+	case errors.As(err, &apiErr) && strings.Contains(apiErr.Message, argoOperationInProgressMessage):
+		slog.Debug("ArgoCD is running another operation for the app; requesting the sync again on the next poll", "id", task.Id)
+	case isArgoUnavailable(err):
+		slog.Warn("Could not reach ArgoCD to trigger the sync; retrying on the next poll", "error", err, "id", task.Id)
+	default:
+		return &SyncOperationError{App: task.App, Report: fmt.Sprintf("ArgoCD refused the sync request: %s", err)}
+	}
+
+	return nil
+}
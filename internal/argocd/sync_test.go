@@ -0,0 +1,215 @@
+package argocd
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/avast/retry-go/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
+
+	"github.com/shini4i/argo-watcher/internal/mocks"
+	"github.com/shini4i/argo-watcher/internal/models"
+)
+
+var syncTask = models.Task{
+	Id:      "task-id",
+	App:     "demo",
+	Timeout: 60,
+	Images:  []models.Image{{Image: "ghcr.io/shini4i/app", Tag: "v2"}},
+}
+
+// manualSyncApp returns an application without auto-sync that opted into the triggered sync,
+// whose last recorded operation started at startedAt and ended in phase.
+func manualSyncApp(startedAt, phase string, images ...string) *models.Application {
+	app := &models.Application{}
+	app.Metadata.Annotations = map[string]string{"argo-watcher/sync": "true", "argo-watcher/sync-prune": "true"}
+	app.Status.Summary.Images = images
+	app.Status.Sync.Status = "OutOfSync"
+	app.Status.Health.Status = "Healthy"
+	app.Status.OperationState.StartedAt = startedAt
+	app.Status.OperationState.Phase = phase
+	return app
+}
+
+// deployedApp is manualSyncApp once its sync applied the task's image.
+func deployedApp(startedAt string) *models.Application {
+	app := manualSyncApp(startedAt, "Succeeded", "ghcr.io/shini4i/app:v2")
+	app.Status.Sync.Status = "Synced"
+	return app
+}
+
+// appSequence serves the given applications one per call, repeating the last one.
+func appSequence(apps ...*models.Application) func(context.Context, string, bool) (*models.Application, error) {
+	calls := 0
+	return func(context.Context, string, bool) (*models.Application, error) {
+		app := apps[min(calls, len(apps)-1)]
+		calls++
+		return app, nil
+	}
+}
+
+func newSyncMonitor(ctrl *gomock.Controller, api ArgoApiInterface) *DeploymentMonitor {
+	return NewDeploymentMonitor(
+		Argo{api: api, State: notSupersededState(ctrl)},
+		"",
+		[]retry.Option{retry.DelayType(zeroDelay), retry.LastErrorOnly(true)},
+		false,
+		time.Millisecond,
+	)
+}
+
+func TestWaitRolloutTriggersSyncAndWaitsForIt(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	// The second poll still shows the old operation together with the new image: judging the
+	// rollout on it would report a deployment ArgoCD has not started syncing yet.
+	stale := deployedApp("2026-01-01T10:00:00Z")
+
+	api := newArgoApiMock(ctrl)
+	api.EXPECT().GetApplication(gomock.Any(), syncTask.App, false).DoAndReturn(appSequence(
+		manualSyncApp("2026-01-01T10:00:00Z", "Succeeded", "ghcr.io/shini4i/app:v1"),
+		stale,
+		deployedApp("2026-01-01T10:05:00Z"),
+	)).Times(3)
+	api.EXPECT().SyncApplication(gomock.Any(), syncTask.App, models.ApplicationSyncRequest{Prune: true}).
+		Return(&models.Application{}, nil).Times(1)
+
+	application, _, err := newSyncMonitor(ctrl, api).WaitRollout(syncTask, neverLost)
+	require.NoError(t, err)
+	assert.Equal(t, "2026-01-01T10:05:00Z", application.Status.OperationState.StartedAt)
+}
+
+func TestWaitRolloutFailsWhenTriggeredSyncFails(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	failed := manualSyncApp("2026-01-01T10:05:00Z", "Failed", "ghcr.io/shini4i/app:v1")
+	failed.Status.OperationState.Message = "one or more objects failed to apply"
+
+	api := newArgoApiMock(ctrl)
+	api.EXPECT().GetApplication(gomock.Any(), syncTask.App, false).DoAndReturn(appSequence(
+		manualSyncApp("2026-01-01T10:00:00Z", "Succeeded", "ghcr.io/shini4i/app:v1"),
+		failed,
+	)).Times(2)
+	api.EXPECT().SyncApplication(gomock.Any(), syncTask.App, gomock.Any()).Return(&models.Application{}, nil)
+
+	_, _, err := newSyncMonitor(ctrl, api).WaitRollout(syncTask, neverLost)
+
+	var syncErr *SyncOperationError
+	require.ErrorAs(t, err, &syncErr)
+	assert.Contains(t, syncErr.Reason(), `Last sync operation: Failed, message: "one or more objects failed to apply"`)
+}
+
+func TestWaitRolloutRequestsSyncAgainWhileAnotherOperationRuns(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	api := newArgoApiMock(ctrl)
+	api.EXPECT().GetApplication(gomock.Any(), syncTask.App, false).DoAndReturn(appSequence(
+		manualSyncApp("2026-01-01T10:00:00Z", "Running", "ghcr.io/shini4i/app:v1"),
+		manualSyncApp("2026-01-01T10:00:00Z", "Succeeded", "ghcr.io/shini4i/app:v1"),
+		deployedApp("2026-01-01T10:05:00Z"),
+	)).Times(3)
+	gomock.InOrder(
+		api.EXPECT().SyncApplication(gomock.Any(), syncTask.App, gomock.Any()).
+			Return(nil, &ArgoAPIError{StatusCode: http.StatusBadRequest, Message: "another operation is already in progress"}),
+		api.EXPECT().SyncApplication(gomock.Any(), syncTask.App, gomock.Any()).Return(&models.Application{}, nil),
+	)
+
+	_, _, err := newSyncMonitor(ctrl, api).WaitRollout(syncTask, neverLost)
+	require.NoError(t, err)
+}
+
+func TestWaitRolloutFailsWhenSyncIsRefused(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	api := newArgoApiMock(ctrl)
+	api.EXPECT().GetApplication(gomock.Any(), syncTask.App, false).
+		Return(manualSyncApp("", "", "ghcr.io/shini4i/app:v1"), nil).Times(1)
+	api.EXPECT().SyncApplication(gomock.Any(), syncTask.App, gomock.Any()).
+		Return(nil, &ArgoAPIError{StatusCode: http.StatusForbidden, Message: "permission denied"}).Times(1)
+
+	_, _, err := newSyncMonitor(ctrl, api).WaitRollout(syncTask, neverLost)
+
+	var syncErr *SyncOperationError
+	require.ErrorAs(t, err, &syncErr)
+	assert.Contains(t, syncErr.Reason(), "ArgoCD refused the sync request: permission denied")
+}
+
+func TestWaitRolloutLeavesAutoSyncedAppAlone(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	app := deployedApp("2026-01-01T10:00:00Z")
+	app.Spec.SyncPolicy = &models.ApplicationSyncPolicy{Automated: &models.ApplicationSyncPolicyAutomated{}}
+
+	// No SyncApplication expectation: any call is a failure.
+	api := newArgoApiMock(ctrl)
+	api.EXPECT().GetApplication(gomock.Any(), syncTask.App, false).Return(app, nil).Times(1)
+
+	monitor := newSyncMonitor(ctrl, api)
+	monitor.syncApp = true
+
+	_, _, err := monitor.WaitRollout(syncTask, neverLost)
+	require.NoError(t, err)
+}
+
+func TestWaitForRolloutRecordsSyncFailure(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	task := syncTask
+	task.Validated = true
+
+	api := newArgoApiMock(ctrl)
+	api.EXPECT().GetApplication(gomock.Any(), task.App, gomock.Any()).
+		Return(manualSyncApp("", "", "ghcr.io/shini4i/app:v1"), nil).Times(2)
+	api.EXPECT().SyncApplication(gomock.Any(), task.App, gomock.Any()).
+		Return(nil, &ArgoAPIError{StatusCode: http.StatusForbidden, Message: "permission denied"})
+
+	metrics := mocks.NewMockMetricsInterface(ctrl)
+	metrics.EXPECT().AddInProgressTask()
+	metrics.EXPECT().RemoveInProgressTask()
+	metrics.EXPECT().AddFailedDeployment(task.App)
+	metrics.EXPECT().AddDeploymentOutcome(task.App, models.StatusFailedMessage)
+
+	state := notSupersededState(ctrl)
+	state.EXPECT().SetTaskStatus(task.Id, models.StatusFailedMessage, gomock.Any()).
+		DoAndReturn(func(_, _, reason string) error {
+			assert.Contains(t, reason, `The ArgoCD sync argo-watcher triggered for "demo" did not succeed.`)
+			return nil
+		})
+
+	argo := &Argo{}
+	argo.Init(state, api, metrics)
+	updater := initTestUpdater(t, newUpdaterTestConfig(&spyLocker{}), argo)
+
+	updater.WaitForRollout(task, false)
+}
+
+func TestProcessDeploymentResultRecordsTriggeredSync(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	app := deployedApp("2026-01-01T10:05:00Z")
+	app.Status.OperationState.SyncResult.Revision = "0123456789abcdef0123456789abcdef01234567"
+
+	metrics := mocks.NewMockMetricsInterface(ctrl)
+	metrics.EXPECT().ResetFailedDeployment(syncTask.App)
+
+	state := newTaskRepositoryMock(ctrl)
+	state.EXPECT().SetTaskStatus(syncTask.Id, models.StatusDeployedMessage,
+		"Synced by argo-watcher: sync operation Succeeded at revision 0123456.")
+
+	monitor := NewDeploymentMonitor(Argo{metrics: metrics, State: state}, "", nil, false, time.Millisecond)
+
+	task := syncTask
+	monitor.ProcessDeploymentResult(&task, app, time.Second)
+	assert.Equal(t, models.StatusDeployedMessage, task.Status)
+}
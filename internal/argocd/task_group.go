@@ -0,0 +1,236 @@
+package argocd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/shini4i/argo-watcher/internal/models"
+	"github.com/shini4i/argo-watcher/internal/state"
+)
+
+// groupListTimeout bounds the ArgoCD lookup that resolves a group deployment's
+// applications, which runs while the submitting client waits for its response.
+const groupListTimeout = 30 * time.Second
+
+// GroupTargetError rejects a group deployment whose target cannot be resolved into
+// applications: nothing matches it, or ArgoCD refused the selector. It is the
+// submitter's mistake rather than an outage, and is reported as such.
+type GroupTargetError struct {
+	Message string
+}
+
+func (err *GroupTargetError) Error() string {
+	return err.Message
+}
+
+// groupTargetDescription names the target of a group deployment in messages.
+func groupTargetDescription(task models.Task) string {
+	switch {
+	case task.Selector != "" && task.ApplicationSet != "":
+		return fmt.Sprintf("ApplicationSet %q with selector %q", task.ApplicationSet, task.Selector)
+	case task.ApplicationSet != "":
+		return fmt.Sprintf("ApplicationSet %q", task.ApplicationSet)
+	default:
+		return fmt.Sprintf("selector %q", task.Selector)
+	}
+}
+
+// GroupApplications returns the applications a group deployment addresses, sorted
+// by name so the order they roll out in does not depend on ArgoCD's.
+//
+// A selector is resolved by ArgoCD itself. An ApplicationSet is matched on the owner
+// reference ArgoCD puts on every application it generates, which is the one link
+// between the two that needs no label convention.
+func (argo *Argo) GroupApplications(task models.Task) ([]models.Application, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), groupListTimeout)
+	defer cancel()
+
+	apps, err := argo.api.ListApplications(ctx, task.Selector)
+	if err != nil {
+		var apiErr *ArgoAPIError
+		if errors.As(err, &apiErr) && apiErr.StatusCode == http.StatusBadRequest {
+			return nil, &GroupTargetError{Message: fmt.Sprintf("ArgoCD rejected %s: %s", groupTargetDescription(task), apiErr.Message)}
+		}
+		return nil, err
+	}
+
+	if task.ApplicationSet != "" {
+		apps = slices.DeleteFunc(apps, func(app models.Application) bool {
+			return !app.IsOwnedByApplicationSet(task.ApplicationSet)
+		})
+	}
+
+	if len(apps) == 0 {
+		return nil, &GroupTargetError{Message: fmt.Sprintf("no application matches %s", groupTargetDescription(task))}
+	}
+
+	slices.SortFunc(apps, func(a, b models.Application) int {
+		return strings.Compare(a.Metadata.Name, b.Metadata.Name)
+	})
+	return apps, nil
+}
+
+// AddTaskGroup turns a deployment addressed to a label selector or an ApplicationSet
+// into one task per application of apps, all sharing a group id, and adds them to the
+// task repository. apps are the applications GroupApplications resolved the target
+// to, so the tasks stored are for the very applications the submission was checked
+// against. With task.MaxParallel set, only that many start in progress; the rest are
+// stored as queued and started by WaitForGroup as slots free up.
+//
+// Each task goes through AddTask, so it supersedes and is superseded exactly like
+// a deployment submitted for its application alone. The group is created whole or
+// not at all: if a task cannot be stored, the ones already created are aborted
+// before the error is returned.
+func (argo *Argo) AddTaskGroup(task models.Task, apps []models.Application) (*models.TaskGroup, error) {
+	if !argo.IsAvailable() {
+		return nil, errors.New(models.StatusArgoCDUnavailableMessage)
+	}
+
+	if len(task.Images) == 0 && task.ChartVersion == "" {
+		return nil, fmt.Errorf("trying to create task without images")
+	}
+
+	if len(apps) == 0 {
+		return nil, &GroupTargetError{Message: fmt.Sprintf("no application matches %s", groupTargetDescription(task))}
+	}
+
+	group := &models.TaskGroup{Id: uuid.NewString()}
+	slog.Info("A new group deployment was triggered", "group_id", group.Id, "target", groupTargetDescription(task), "apps", len(apps))
+
+	children := make([]models.Task, 0, len(apps))
+	for index := range apps {
+		child := task
+		child.App = apps[index].Metadata.Name
+		child.Selector = ""
+		child.ApplicationSet = ""
+		child.GroupId = group.Id
+		child.Status = ""
+		if task.MaxParallel > 0 && index >= task.MaxParallel {
+			child.Status = models.StatusQueuedMessage
+		}
+		children = append(children, child)
+	}
+
+	// Storing a task cancels what its application had in progress, so a downgrade
+	// found halfway through would leave the applications before it with their rollouts
+	// cancelled and the group aborted. Every child is checked before any is stored.
+	if err := argo.checkGroupDowngrades(children); err != nil {
+		return nil, err
+	}
+
+	for _, child := range children {
+		newTask, err := argo.addTask(child)
+		if err != nil {
+			argo.abortGroup(group.Tasks, err)
+			return nil, err
+		}
+		group.Tasks = append(group.Tasks, *newTask)
+	}
+
+	group.Status = models.GroupStatus(group.Tasks)
+	return group, nil
+}
+
+// abortGroup marks the tasks of a group that could not be created in full as
+// aborted, so none of them is left in progress with nobody monitoring it.
+func (argo *Argo) abortGroup(tasks []models.Task, cause error) {
+	reason := fmt.Sprintf("The group deployment could not be created: %s", cause)
+	for _, task := range tasks {
+		if err := argo.State.SetTaskStatus(task.Id, models.StatusAborted, reason); err != nil {
+			slog.Error("Failed to abort a task of an incomplete group deployment", "error", err, "id", task.Id)
+		}
+	}
+}
+
+// GetTaskGroup returns a group deployment with the current state of its tasks, and
+// state.ErrTaskNotFound when no task belongs to the group.
+func (argo *Argo) GetTaskGroup(id string) (*models.TaskGroup, error) {
+	tasks, err := argo.State.GetGroupTasks(id)
+	if err != nil {
+		return nil, err
+	}
+	if len(tasks) == 0 {
+		return nil, state.ErrTaskNotFound
+	}
+
+	return &models.TaskGroup{Id: id, Status: models.GroupStatus(tasks), Tasks: tasks}, nil
+}
+
+// WaitForGroup monitors the rollouts of a group deployment, keeping at most
+// maxParallel of them in progress at once (all of them when it is not positive).
+// A queued task is started when an earlier one finishes; one that a newer
+// deployment cancelled, or that went stale while it waited, is skipped.
+//
+// The queue lives in this process. A replica that stops with tasks still queued
+// leaves them to the obsolete-task sweep, which aborts them once they are older
+// than the staleness window; the tasks it had already started are taken over by
+// another replica like any other rollout.
+func (updater *ArgoStatusUpdater) WaitForGroup(tasks []models.Task, maxParallel int) {
+	if maxParallel <= 0 || maxParallel > len(tasks) {
+		maxParallel = len(tasks)
+	}
+
+	slots := make(chan struct{}, maxParallel)
+	var wg sync.WaitGroup
+
+	for _, task := range tasks {
+		slots <- struct{}{}
+
+		if task.Status == models.StatusQueuedMessage {
+			started, err := updater.monitor.argo.State.StartQueuedTask(task.Id)
+			if err != nil {
+				slog.Error("Failed to start a queued deployment of the group", "error", err, "id", task.Id, "group_id", task.GroupId)
+			}
+			if err != nil || !started {
+				<-slots
+				continue
+			}
+			slog.Info("Starting a queued deployment of the group", "app", task.App, "id", task.Id, "group_id", task.GroupId)
+			task.Status = models.StatusInProgressMessage
+		}
+
+		wg.Add(1)
+		go func(task models.Task) {
+			defer wg.Done()
+			defer func() { <-slots }()
+			updater.WaitForRollout(task, false)
+		}(task)
+	}
+
+	wg.Wait()
+}
+
+// checkGroupDowngrades returns a *DowngradeError listing, by application, every
+// downgrade the children of a group deployment would make without being let through,
+// when downgrades are prevented. addTask checks each child again as it is stored.
+func (argo *Argo) checkGroupDowngrades(children []models.Task) error {
+	if !argo.PreventDowngrades {
+		return nil
+	}
+
+	var downgrades []string
+	for _, child := range children {
+		deployed, _ := argo.State.GetTasks(0, float64(time.Now().Unix()), child.App, models.StatusDeployedMessage, rollbackHistoryWindow, 0)
+		_, err := checkDowngrade(child, deployed)
+		var downgradeErr *DowngradeError
+		if errors.As(err, &downgradeErr) {
+			for _, downgrade := range downgradeErr.Downgrades {
+				downgrades = append(downgrades, child.App+": "+downgrade)
+			}
+		}
+	}
+
+	if len(downgrades) > 0 {
+		return &DowngradeError{Downgrades: downgrades}
+	}
+	return nil
+}
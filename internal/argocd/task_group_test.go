@@ -0,0 +1,270 @@
+package argocd
+
+import (
+	"errors"
+	"net/http"
+	"testing"
+
+	promclient "github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
+
+	"github.com/shini4i/argo-watcher/internal/lock"
+	"github.com/shini4i/argo-watcher/internal/mocks"
+	"github.com/shini4i/argo-watcher/internal/models"
+	"github.com/shini4i/argo-watcher/internal/prometheus"
+	"github.com/shini4i/argo-watcher/internal/state"
+)
+
+var groupTask = models.Task{
+	Author:  "author",
+	Project: "project",
+	Timeout: 15,
+	Images:  []models.Image{{Image: "ghcr.io/shini4i/app", Tag: "v2"}},
+}
+
+// listedApp returns an application as ArgoCD lists it, generated by the given
+// ApplicationSets.
+func listedApp(name string, appSets ...string) models.Application {
+	app := models.Application{}
+	app.Metadata.Name = name
+	for _, appSet := range appSets {
+		app.Metadata.OwnerReferences = append(app.Metadata.OwnerReferences, models.OwnerReference{Kind: models.ApplicationSetKind, Name: appSet})
+	}
+	return app
+}
+
+// newGroupArgo returns an Argo backed by in-memory state, so the tasks a group creates
+// can be inspected as the server would serve them.
+func newGroupArgo(api ArgoApiInterface) *Argo {
+	argo := &Argo{}
+	argo.Init(&state.InMemoryState{}, api, prometheus.NewMetrics(promclient.NewRegistry()))
+	return argo
+}
+
+// addTaskGroup resolves the target of task and adds its group, as the server does.
+func addTaskGroup(argo *Argo, task models.Task) (*models.TaskGroup, error) {
+	apps, err := argo.GroupApplications(task)
+	if err != nil {
+		return nil, err
+	}
+	return argo.AddTaskGroup(task, apps)
+}
+
+func TestAddTaskGroupResolvesSelector(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	api := newArgoApiMock(ctrl)
+	api.EXPECT().ListApplications(gomock.Any(), "team=payments").
+		Return([]models.Application{listedApp("web"), listedApp("api"), listedApp("worker")}, nil)
+
+	task := groupTask
+	task.Selector = "team=payments"
+	task.MaxParallel = 2
+
+	argo := newGroupArgo(api)
+	group, err := addTaskGroup(argo, task)
+	require.NoError(t, err)
+
+	require.Len(t, group.Tasks, 3)
+	assert.Equal(t, []string{"api", "web", "worker"}, []string{group.Tasks[0].App, group.Tasks[1].App, group.Tasks[2].App},
+		"applications roll out in name order")
+	assert.Equal(t, models.StatusInProgressMessage, group.Tasks[0].Status)
+	assert.Equal(t, models.StatusInProgressMessage, group.Tasks[1].Status)
+	assert.Equal(t, models.StatusQueuedMessage, group.Tasks[2].Status, "beyond max_parallel a task waits for a slot")
+	assert.Equal(t, models.StatusInProgressMessage, group.Status)
+
+	stored, err := argo.GetTaskGroup(group.Id)
+	require.NoError(t, err)
+	assert.Len(t, stored.Tasks, 3)
+	for _, child := range stored.Tasks {
+		assert.Equal(t, group.Id, child.GroupId)
+		assert.Empty(t, child.Selector, "the target is resolved; each task names its application")
+	}
+}
+
+func TestAddTaskGroupMatchesApplicationSetOwner(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	api := newArgoApiMock(ctrl)
+	api.EXPECT().ListApplications(gomock.Any(), "").
+		Return([]models.Application{listedApp("api", "payments"), listedApp("db"), listedApp("web", "storefront")}, nil)
+
+	task := groupTask
+	task.ApplicationSet = "payments"
+
+	group, err := addTaskGroup(newGroupArgo(api), task)
+	require.NoError(t, err)
+	require.Len(t, group.Tasks, 1)
+	assert.Equal(t, "api", group.Tasks[0].App)
+}
+
+func TestAddTaskGroupRejectsUnresolvableTarget(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	t.Run("nothing matches", func(t *testing.T) {
+		api := newArgoApiMock(ctrl)
+		api.EXPECT().ListApplications(gomock.Any(), "team=nobody").Return(nil, nil)
+
+		task := groupTask
+		task.Selector = "team=nobody"
+
+		_, err := newGroupArgo(api).GroupApplications(task)
+
+		var targetErr *GroupTargetError
+		require.ErrorAs(t, err, &targetErr)
+		assert.Equal(t, `no application matches selector "team=nobody"`, err.Error())
+	})
+
+	t.Run("ArgoCD refuses the selector", func(t *testing.T) {
+		api := newArgoApiMock(ctrl)
+		api.EXPECT().ListApplications(gomock.Any(), "team==").
+			Return(nil, &ArgoAPIError{StatusCode: http.StatusBadRequest, Message: "unable to parse requirement"})
+
+		task := groupTask
+		task.Selector = "team=="
+
+		_, err := newGroupArgo(api).GroupApplications(task)
+
+		var targetErr *GroupTargetError
+		require.ErrorAs(t, err, &targetErr)
+		assert.Contains(t, err.Error(), "unable to parse requirement")
+	})
+
+	t.Run("an outage is not the submitter's mistake", func(t *testing.T) {
+		api := newArgoApiMock(ctrl)
+		api.EXPECT().ListApplications(gomock.Any(), gomock.Any()).
+			Return(nil, &ArgoAPIError{StatusCode: http.StatusBadGateway, Message: "bad gateway"})
+
+		task := groupTask
+		task.Selector = "team=payments"
+
+		_, err := newGroupArgo(api).GroupApplications(task)
+
+		var targetErr *GroupTargetError
+		require.Error(t, err)
+		assert.False(t, errors.As(err, &targetErr))
+	})
+}
+
+// A group is created whole or not at all: the tasks stored before a failure must not
+// be left in progress with nobody monitoring them.
+func TestAddTaskGroupAbortsPartialGroup(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	api := newArgoApiMock(ctrl)
+	api.EXPECT().ListApplications(gomock.Any(), gomock.Any()).
+		Return([]models.Application{listedApp("api"), listedApp("web")}, nil)
+
+	stateMock := newTaskRepositoryMock(ctrl)
+	stateMock.EXPECT().GetTasks(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).
+		Return([]models.Task{}, int64(0)).AnyTimes()
+	stateMock.EXPECT().CancelInProgressTasks(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).Return(int64(0), nil).AnyTimes()
+	gomock.InOrder(
+		stateMock.EXPECT().AddTask(gomock.Any()).Return(&models.Task{Id: "first", App: "api"}, nil),
+		stateMock.EXPECT().AddTask(gomock.Any()).Return(nil, errors.New("failed to create task in database")),
+	)
+	stateMock.EXPECT().SetTaskStatus("first", models.StatusAborted,
+		"The group deployment could not be created: failed to create task in database")
+
+	metricsMock := mocks.NewMockMetricsInterface(ctrl)
+	metricsMock.EXPECT().AddAcceptedDeployment()
+
+	argo := &Argo{}
+	argo.Init(stateMock, api, metricsMock)
+
+	task := groupTask
+	task.Selector = "team=payments"
+
+	_, err := addTaskGroup(argo, task)
+	require.Error(t, err)
+}
+
+// A downgrade is found before anything is stored, so no application of the group has
+// its rollout cancelled by a group that is then refused.
+func TestAddTaskGroupRejectsDowngradeBeforeStoringAnything(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	api := newArgoApiMock(ctrl)
+	api.EXPECT().ListApplications(gomock.Any(), gomock.Any()).
+		Return([]models.Application{listedApp("api"), listedApp("web"), listedApp("worker")}, nil)
+
+	// No AddTask or CancelInProgressTasks is expected: either would fail the test.
+	stateMock := newTaskRepositoryMock(ctrl)
+	stateMock.EXPECT().GetTasks(gomock.Any(), gomock.Any(), "web", models.StatusDeployedMessage, gomock.Any(), 0).
+		Return([]models.Task{{App: "web", Images: []models.Image{{Image: "ghcr.io/shini4i/app", Tag: "1.3.0"}}}}, int64(1))
+	stateMock.EXPECT().GetTasks(gomock.Any(), gomock.Any(), gomock.Any(), models.StatusDeployedMessage, gomock.Any(), 0).
+		Return([]models.Task{}, int64(0)).Times(2)
+
+	argo := &Argo{PreventDowngrades: true}
+	argo.Init(stateMock, api, mocks.NewMockMetricsInterface(ctrl))
+
+	task := groupTask
+	task.Selector = "team=payments"
+	task.Images = []models.Image{{Image: "ghcr.io/shini4i/app", Tag: "1.2.0"}}
+
+	_, err := addTaskGroup(argo, task)
+
+	var downgradeErr *DowngradeError
+	require.ErrorAs(t, err, &downgradeErr)
+	assert.Equal(t, []string{"web: ghcr.io/shini4i/app from 1.3.0 to 1.2.0"}, downgradeErr.Downgrades)
+}
+
+func TestGetTaskGroupNotFound(t *testing.T) {
+	_, err := newGroupArgo(nil).GetTaskGroup("unknown")
+	assert.ErrorIs(t, err, state.ErrTaskNotFound)
+}
+
+// With one slot, a queued task starts only once the task ahead of it finished, and a
+// queued task a newer deployment cancelled is never started at all.
+func TestWaitForGroupRunsQueuedTasksInTurn(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	api := newArgoApiMock(ctrl)
+	api.EXPECT().ListApplications(gomock.Any(), gomock.Any()).
+		Return([]models.Application{listedApp("api"), listedApp("web"), listedApp("worker")}, nil)
+
+	argo := newGroupArgo(api)
+
+	task := groupTask
+	task.Selector = "team=payments"
+	task.MaxParallel = 1
+
+	group, err := addTaskGroup(argo, task)
+	require.NoError(t, err)
+	first, second, superseded := group.Tasks[0], group.Tasks[1], group.Tasks[2]
+
+	_, err = argo.State.CancelInProgressTasks(superseded.App, superseded.Images, supersededTaskReason, true)
+	require.NoError(t, err)
+
+	deployed := models.Application{}
+	deployed.Status.Summary.Images = []string{"ghcr.io/shini4i/app:v2"}
+	deployed.Status.Sync.Status = "Synced"
+	deployed.Status.Health.Status = "Healthy"
+
+	api.EXPECT().GetApplication(gomock.Any(), first.App, gomock.Any()).Return(&deployed, nil).MinTimes(1)
+	api.EXPECT().GetApplication(gomock.Any(), second.App, gomock.Any()).
+		DoAndReturn(func(_ any, _ string, _ bool) (*models.Application, error) {
+			ahead, err := argo.State.GetTask(first.Id)
+			require.NoError(t, err)
+			assert.Equal(t, models.StatusDeployedMessage, ahead.Status, "a queued task must wait for a free slot")
+			return &deployed, nil
+		}).MinTimes(1)
+
+	updater := initTestUpdater(t, newUpdaterTestConfig(lock.NewInMemoryLocker()), argo)
+	updater.WaitForGroup(group.Tasks, task.MaxParallel)
+
+	finished, err := argo.GetTaskGroup(group.Id)
+	require.NoError(t, err)
+	assert.Equal(t, models.StatusDeployedMessage, finished.Tasks[0].Status)
+	assert.Equal(t, models.StatusDeployedMessage, finished.Tasks[1].Status)
+	assert.Equal(t, models.StatusCancelledMessage, finished.Tasks[2].Status)
+	assert.Equal(t, models.StatusCancelledMessage, finished.Status)
+}
@@ -169,16 +169,51 @@ func (watcher *Watcher) guardRedirect(request *http.Request, via []*http.Request
 
 // addTask presents the watcher's credential and returns the new task ID.
 func (watcher *Watcher) addTask(task models.Task) (string, error) {
-	requestBody, err := json.Marshal(task)
+	responseBody, err := watcher.submitTask(task, http.StatusAccepted)
+	if err != nil {
+		return "", err
+	}
+
+	var accepted models.TaskStatus
+	err = json.Unmarshal(responseBody, &accepted)
 	if err != nil {
 		return "", err
 	}
 
+	return accepted.Id, nil
+}
+
+// previewTask submits task as a dry run and returns the git change its deployment
+// would write back.
+func (watcher *Watcher) previewTask(task models.Task) (*models.WriteBackPreview, error) {
+	task.DryRun = true
+
+	responseBody, err := watcher.submitTask(task, http.StatusOK)
+	if err != nil {
+		return nil, err
+	}
+
+	var preview models.WriteBackPreview
+	if err := json.Unmarshal(responseBody, &preview); err != nil {
+		return nil, err
+	}
+
+	return &preview, nil
+}
+
+// submitTask posts task with the watcher's credential and returns the response body,
+// or the server's reason when the response status is not expectedStatus.
+func (watcher *Watcher) submitTask(task models.Task, expectedStatus int) ([]byte, error) {
+	requestBody, err := json.Marshal(task)
+	if err != nil {
+		return nil, err
+	}
+
 	url := fmt.Sprintf("%s/api/v1/tasks", watcher.baseUrl)
 
 	request, err := http.NewRequest(http.MethodPost, url, bytes.NewBuffer(requestBody))
 	if err != nil {
-		return "", err
+		return nil, err
 	}
 
 	request.Header.Set("Content-Type", "application/json; charset=UTF-8")
@@ -195,7 +230,7 @@ func (watcher *Watcher) addTask(task models.Task) (string, error) {
 
 	response, err := watcher.client.Do(request)
 	if err != nil {
-		return "", err
+		return nil, err
 	}
 
 	defer func() {
@@ -206,20 +241,14 @@ func (watcher *Watcher) addTask(task models.Task) (string, error) {
 
 	responseBody, err := io.ReadAll(response.Body)
 	if err != nil {
-		return "", err
+		return nil, err
 	}
 
-	if response.StatusCode != http.StatusAccepted {
-		return "", serverErrorFromResponse(response.StatusCode, responseBody)
+	if response.StatusCode != expectedStatus {
+		return nil, serverErrorFromResponse(response.StatusCode, responseBody)
 	}
 
-	var accepted models.TaskStatus
-	err = json.Unmarshal(responseBody, &accepted)
-	if err != nil {
-		return "", err
-	}
-
-	return accepted.Id, nil
+	return responseBody, nil
 }
 
 func (watcher *Watcher) getTaskStatus(id string) (*models.TaskStatus, error) {
@@ -262,6 +291,16 @@ func (watcher *Watcher) waitForDeployment(id, appName, version string) error {
 			}
 			retryCount++
 			time.Sleep(clientConfig.RetryInterval)
+		case models.StatusQueuedMessage:
+			// A task of a group deployment waiting for its turn. Its deadline starts when
+			// it leaves the queue, so the wait does not count toward the expected time.
+			log.Println("Application deployment is queued behind other applications of its group...")
+			time.Sleep(clientConfig.RetryInterval)
+		case models.StatusAwaitingMergeMessage:
+			// The write-back was proposed in a pull request; the rollout starts once it
+			// is merged, so the wait does not count toward the expected time either.
+			log.Printf("Application deployment is waiting for its pull request to be merged...\n%s", taskInfo.StatusReason)
+			time.Sleep(clientConfig.RetryInterval)
 		case models.StatusAppNotFoundMessage:
 			return fmt.Errorf("Application %s does not exist.\n%s", appName, taskInfo.StatusReason)
 		case models.StatusArgoCDUnavailableMessage:
@@ -271,6 +310,9 @@ func (watcher *Watcher) waitForDeployment(id, appName, version string) error {
 		case models.StatusDeployedMessage:
 			log.Printf("The deployment of %s version is done.", version)
 			return nil
+		case models.StatusPausedMessage:
+			log.Printf("The deployment of %s version is paused waiting for promotion.\n%s", version, taskInfo.StatusReason)
+			return nil
 		default:
 			// Treat any status this client does not recognize (e.g. one added by a
 			// newer server) as terminal. Without this the loop would re-poll with no
@@ -301,12 +343,15 @@ func isDeploymentOverTime(retryCount int, retryInterval time.Duration, expectedD
 }
 
 // Run is the client entrypoint: it builds the task, submits it, and waits for the deployment.
-func Run() {
+// With dryRun (or DRY_RUN) set it prints the git change the deployment would write back
+// instead, and deploys nothing.
+func Run(dryRun bool) {
 	var err error
 
 	if clientConfig, err = NewClientConfig(); err != nil {
 		log.Fatalf("Couldn't get client configuration. Got the following error: %s", err)
 	}
+	clientConfig.DryRun = clientConfig.DryRun || dryRun
 
 	watcher := setupWatcher(clientConfig)
 	task := createTask(clientConfig)
@@ -315,6 +360,15 @@ func Run() {
 		printClientConfiguration(watcher, task)
 	}
 
+	if clientConfig.DryRun {
+		preview, err := watcher.previewTask(task)
+		if err != nil {
+			handleFatalError(err, "Couldn't preview the deployment.")
+		}
+		printPreview(os.Stdout, preview)
+		return
+	}
+
 	log.Printf("Waiting for %s app to be running on %s version.\n", task.App, clientConfig.Tag)
 
 	id, err := watcher.addTask(task)
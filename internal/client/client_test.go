@@ -9,6 +9,7 @@ import (
 	"net/http/httptest"
 	"net/url"
 	"strings"
+	"sync/atomic"
 	"testing"
 	"time"
 
@@ -28,6 +29,9 @@ var (
 	cancelledTaskId     = "be8c42c0-a645-11ec-8ea5-f2c4bb72758e"
 	abortedTaskId       = "be8c42c0-a645-11ec-8ea5-f2c4bb727590"
 	unhandledStatusId   = "be8c42c0-a645-11ec-8ea5-f2c4bb72758f"
+	pausedTaskId        = "be8c42c0-a645-11ec-8ea5-f2c4bb727591"
+	queuedTaskId        = "be8c42c0-a645-11ec-8ea5-f2c4bb727592"
+	awaitingMergeTaskId = "be8c42c0-a645-11ec-8ea5-f2c4bb727593"
 
 	failedTaskReason = "Application deployment failed. Image \"ghcr.io/shini4i/typo\" is not part of application \"demo\".\n\n" +
 		"List of images defined in the application:\n\tghcr.io/shini4i/app"
@@ -54,6 +58,13 @@ func addTaskHandler(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
+// queuedTaskPolls counts the lookups of queuedTaskId, which leaves the queue after the first.
+var queuedTaskPolls atomic.Int32
+
+// awaitingMergeTaskPolls counts the lookups of awaitingMergeTaskId, whose pull request
+// is merged after the first.
+var awaitingMergeTaskPolls atomic.Int32
+
 func getTaskStatusHandler(w http.ResponseWriter, r *http.Request) {
 	if r.Method != "GET" {
 		w.WriteHeader(http.StatusMethodNotAllowed)
@@ -84,6 +95,19 @@ func getTaskStatusHandler(w http.ResponseWriter, r *http.Request) {
 		status = models.StatusCancelledMessage
 	case abortedTaskId:
 		status = models.StatusAborted
+	case pausedTaskId:
+		status = models.StatusPausedMessage
+	case queuedTaskId:
+		// Queued on the first poll, deployed from then on.
+		status = models.StatusDeployedMessage
+		if queuedTaskPolls.Add(1) == 1 {
+			status = models.StatusQueuedMessage
+		}
+	case awaitingMergeTaskId:
+		status = models.StatusDeployedMessage
+		if awaitingMergeTaskPolls.Add(1) == 1 {
+			status = models.StatusAwaitingMergeMessage
+		}
 	case unhandledStatusId:
 		status = "some-unknown-status"
 	}
@@ -181,6 +205,56 @@ func init() {
 	client = &Watcher{baseUrl: server.URL, client: server.Client()}
 }
 
+func TestPreviewTask(t *testing.T) {
+	t.Run("returns the preview of a dry run", func(t *testing.T) {
+		var submitted models.Task
+		server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+			_ = json.NewDecoder(req.Body).Decode(&submitted)
+			rw.WriteHeader(http.StatusOK)
+			_ = json.NewEncoder(rw).Encode(models.WriteBackPreview{
+				App:    "test",
+				Repo:   "git@example.com:gitops.git",
+				Branch: "main",
+				Paths:  []string{"apps/.argocd-source-test.yaml"},
+				Diff:   "--- /dev/null\n",
+			})
+		}))
+		defer server.Close()
+
+		watcher := NewWatcher(server.URL, false, 30*time.Second)
+		preview, err := watcher.previewTask(models.Task{
+			App: "test", Author: "x", Project: "y",
+			Images: []models.Image{{Tag: testVersion, Image: "example"}},
+		})
+
+		assert.NoError(t, err)
+		assert.True(t, submitted.DryRun, "the submission must ask for a dry run")
+		assert.Equal(t, []string{"apps/.argocd-source-test.yaml"}, preview.Paths)
+		assert.Equal(t, "--- /dev/null\n", preview.Diff)
+	})
+
+	t.Run("surfaces the server's reason", func(t *testing.T) {
+		server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+			rw.WriteHeader(http.StatusNotAcceptable)
+			_ = json.NewEncoder(rw).Encode(models.TaskStatus{
+				Status: "rejected",
+				Error:  `application "test" declares no managed images`,
+			})
+		}))
+		defer server.Close()
+
+		watcher := NewWatcher(server.URL, false, 30*time.Second)
+		_, err := watcher.previewTask(models.Task{
+			App: "test", Author: "x", Project: "y",
+			Images: []models.Image{{Tag: testVersion, Image: "example"}},
+		})
+
+		assert.Error(t, err)
+		assert.Contains(t, err.Error(), "406")
+		assert.Contains(t, err.Error(), "declares no managed images")
+	})
+}
+
 func TestNewWatcher(t *testing.T) {
 	baseUrl := "http://localhost:8080"
 	debugMode := true
@@ -418,6 +492,24 @@ func TestWaitForDeployment(t *testing.T) {
 			taskId:        taskId,
 			expectedError: "",
 		},
+		{
+			// A rollout paused for promotion is a success the app opted into.
+			name:          "Paused deployment",
+			taskId:        pausedTaskId,
+			expectedError: "",
+		},
+		{
+			// A task of a group deployment waits for its turn without failing the client.
+			name:          "Queued deployment",
+			taskId:        queuedTaskId,
+			expectedError: "",
+		},
+		{
+			// A write-back proposed in a pull request waits for its merge without failing the client.
+			name:          "Deployment awaiting merge",
+			taskId:        awaitingMergeTaskId,
+			expectedError: "",
+		},
 		{
 			name:          "Failed deployment",
 			taskId:        failedTaskId,
@@ -457,6 +549,11 @@ func TestWaitForDeployment(t *testing.T) {
 		},
 	}
 
+	// A non-terminal status sleeps between polls; keep that instant.
+	previousConfig := clientConfig
+	clientConfig = &Config{}
+	t.Cleanup(func() { clientConfig = previousConfig })
+
 	for _, tc := range testCases {
 		t.Run(tc.name, func(t *testing.T) {
 			err := client.waitForDeployment(tc.taskId, "test", testVersion)
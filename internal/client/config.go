@@ -1,6 +1,7 @@
 package client
 
 import (
+	"errors"
 	"time"
 
 	envConfig "github.com/caarlos0/env/v11"
@@ -10,8 +11,8 @@ import (
 
 type Config struct {
 	Url          string        `env:"ARGO_WATCHER_URL,required,notEmpty"`
-	Images       []string      `env:"IMAGES,required,notEmpty"`
-	Tag          string        `env:"IMAGE_TAG,required,notEmpty"`
+	Images       []string      `env:"IMAGES"`
+	Tag          string        `env:"IMAGE_TAG"`
 	App          string        `env:"ARGO_APP,required,notEmpty"`
 	Author       string        `env:"COMMIT_AUTHOR,required,notEmpty"`
 	Project      string        `env:"PROJECT_NAME,required,notEmpty"`
@@ -26,6 +27,18 @@ type Config struct {
 	RetryInterval          time.Duration `env:"RETRY_INTERVAL" envDefault:"15s"`
 	ExpectedDeploymentTime time.Duration `env:"EXPECTED_DEPLOY_TIME" envDefault:"15m"`
 	Debug                  bool          `env:"DEBUG"`
+	// Parameters are Helm parameters written back next to the image tag, given as
+	// TASK_PARAMETERS=name=value,name=value. The application must allow them.
+	Parameters map[string]string `env:"TASK_PARAMETERS" envKeyValSeparator:"="`
+	// ChartVersion deploys this version of the application's Helm chart instead of
+	// images, so IMAGES and IMAGE_TAG are required only without it.
+	ChartVersion string `env:"CHART_VERSION"`
+	// DryRun prints the git change the deployment would write back instead of deploying.
+	// The --dry-run flag sets it too.
+	DryRun bool `env:"DRY_RUN"`
+	// Rollback marks the deployment as a deliberate return to an earlier version, which
+	// a server with PREVENT_DOWNGRADES otherwise rejects.
+	Rollback bool `env:"ROLLBACK"`
 }
 
 // NewClientConfig parses environment variables into a Config. A parse failure groups
@@ -35,5 +48,14 @@ func NewClientConfig() (*Config, error) {
 	if err != nil {
 		return nil, helpers.PrettifyEnvError(err, "invalid argo-watcher client configuration:")
 	}
+
+	switch {
+	case config.ChartVersion != "" && len(config.Images) > 0:
+		return nil, errors.New("invalid argo-watcher client configuration:\nCHART_VERSION cannot be combined with IMAGES")
+	case config.ChartVersion != "":
+		// A chart version task carries no images.
+	case len(config.Images) == 0 || config.Tag == "":
+		return nil, errors.New("invalid argo-watcher client configuration:\nmissing required environment variables:\n  - IMAGES and IMAGE_TAG, or CHART_VERSION")
+	}
 	return &config, nil
 }
@@ -35,6 +35,17 @@ func TestNewClientConfig_Success(t *testing.T) {
 	assert.Equal(t, "test-token", config.Token)
 	assert.Equal(t, 60*time.Second, config.Timeout)
 	assert.Equal(t, true, config.Debug)
+	assert.Empty(t, config.Parameters)
+}
+
+func TestNewClientConfig_Parameters(t *testing.T) {
+	setValidClientEnv(t)
+	t.Setenv("TASK_PARAMETERS", "features.checkout=true,config.version=7")
+
+	config, err := NewClientConfig()
+
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]string{"features.checkout": "true", "config.version": "7"}, config.Parameters)
 }
 
 // TestNewClientConfig_InvalidDuration verifies that the formatter is wired
@@ -66,3 +77,36 @@ func TestNewClientConfig_EmptyRequiredRejected(t *testing.T) {
 	assert.Contains(t, err.Error(), "ARGO_APP")
 	assert.Contains(t, err.Error(), "should not be empty")
 }
+
+func TestNewClientConfig_ChartVersion(t *testing.T) {
+	t.Run("Takes the place of images", func(t *testing.T) {
+		setValidClientEnv(t)
+		t.Setenv("IMAGES", "")
+		t.Setenv("IMAGE_TAG", "")
+		t.Setenv("CHART_VERSION", "1.4.0")
+
+		config, err := NewClientConfig()
+
+		assert.NoError(t, err)
+		assert.Equal(t, "1.4.0", config.ChartVersion)
+		assert.Empty(t, config.Images)
+	})
+
+	t.Run("Cannot be combined with images", func(t *testing.T) {
+		setValidClientEnv(t)
+		t.Setenv("CHART_VERSION", "1.4.0")
+
+		_, err := NewClientConfig()
+
+		assert.ErrorContains(t, err, "CHART_VERSION cannot be combined with IMAGES")
+	})
+
+	t.Run("Images need a tag without it", func(t *testing.T) {
+		setValidClientEnv(t)
+		t.Setenv("IMAGE_TAG", "")
+
+		_, err := NewClientConfig()
+
+		assert.ErrorContains(t, err, "IMAGES and IMAGE_TAG, or CHART_VERSION")
+	})
+}
@@ -7,6 +7,7 @@ import (
 	"io"
 	"log"
 	"net/http"
+	"sort"
 	"strings"
 	"time"
 
@@ -136,16 +137,50 @@ func getImagesList(list []string, tag string) []models.Image {
 	return images
 }
 
+// getParametersList turns the name=value pairs of TASK_PARAMETERS into parameters,
+// sorted by name so the request does not depend on map order.
+func getParametersList(values map[string]string) []models.Parameter {
+	var parameters []models.Parameter
+	for name, value := range values {
+		parameters = append(parameters, models.Parameter{Name: name, Value: value})
+	}
+	sort.Slice(parameters, func(i, j int) bool {
+		return parameters[i].Name < parameters[j].Name
+	})
+	return parameters
+}
+
 func createTask(config *Config) models.Task {
 	images := getImagesList(config.Images, config.Tag)
 	return models.Task{
-		App:     config.App,
-		Author:  config.Author,
-		Project: config.Project,
-		Images:  images,
-		Timeout: config.TaskTimeout,
-		Refresh: config.Refresh,
+		App:          config.App,
+		Author:       config.Author,
+		Project:      config.Project,
+		Images:       images,
+		Parameters:   getParametersList(config.Parameters),
+		ChartVersion: config.ChartVersion,
+		Timeout:      config.TaskTimeout,
+		Refresh:      config.Refresh,
+		DryRun:       config.DryRun,
+		Rollback:     config.Rollback,
+	}
+}
+
+// printPreview writes the git change of a dry run to out. The diff is written as is,
+// so the output can be piped into tools that read unified diffs.
+func printPreview(out io.Writer, preview *models.WriteBackPreview) {
+	_, _ = fmt.Fprintf(out, "Dry run for %s: nothing was deployed or pushed.\n", preview.App)
+	if !preview.Managed {
+		_, _ = fmt.Fprintln(out, "Write-back is not enabled on this application yet (argo-watcher/managed is not \"true\").")
+	}
+	_, _ = fmt.Fprintf(out, "Repository: %s\nBranch: %s\nFiles: %s\n", preview.Repo, preview.Branch, strings.Join(preview.Paths, ", "))
+
+	if preview.Diff == "" {
+		_, _ = fmt.Fprintln(out, "The files already hold these tags; the write-back would commit nothing.")
+		return
 	}
+
+	_, _ = fmt.Fprintf(out, "Commit message: %s\n\n%s", preview.CommitMessage, preview.Diff)
 }
 
 // printClientConfiguration logs the client configuration and warns when no auth token is set.
@@ -157,7 +192,10 @@ func printClientConfiguration(watcher *Watcher, task models.Task) {
 		"PROJECT_NAME: %s\n"+
 		"IMAGE_TAG: %s\n"+
 		"IMAGES: %s\n\n",
-		watcher.baseUrl, task.App, task.Author, task.Project, clientConfig.Tag, task.Images)
+		watcher.baseUrl, task.App, task.Author, task.Project, clientConfig.Tag, task.ListImages())
+	if task.ChartVersion != "" {
+		fmt.Printf("CHART_VERSION: %s\n\n", task.ChartVersion)
+	}
 	if clientConfig.Token == "" && clientConfig.JsonWebToken == "" {
 		fmt.Println("Neither deploy token nor JSON Web token found, git commit will not be performed")
 	}
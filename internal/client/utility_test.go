@@ -379,6 +379,104 @@ func TestCreateTask(t *testing.T) {
 
 		assert.Nil(t, task.Refresh, "an omitted TASK_REFRESH must leave the server default in effect")
 	})
+
+	t.Run("DryRun", func(t *testing.T) {
+		config := &Config{
+			App:     "test-app",
+			Author:  "test-author",
+			Project: "test-project",
+			Images:  []string{"image1"},
+			Tag:     "test-tag",
+			DryRun:  true,
+		}
+
+		task := createTask(config)
+
+		assert.True(t, task.DryRun)
+	})
+
+	t.Run("Rollback", func(t *testing.T) {
+		config := &Config{
+			App:      "test-app",
+			Author:   "test-author",
+			Project:  "test-project",
+			Images:   []string{"image1"},
+			Tag:      "test-tag",
+			Rollback: true,
+		}
+
+		task := createTask(config)
+
+		assert.True(t, task.Rollback)
+	})
+
+	t.Run("Parameters", func(t *testing.T) {
+		config := &Config{
+			App:        "test-app",
+			Author:     "test-author",
+			Project:    "test-project",
+			Images:     []string{"image1"},
+			Tag:        "test-tag",
+			Parameters: map[string]string{"features.checkout": "true", "config.version": "7"},
+		}
+
+		task := createTask(config)
+
+		assert.Equal(t, []models.Parameter{
+			{Name: "config.version", Value: "7"},
+			{Name: "features.checkout", Value: "true"},
+		}, task.Parameters)
+	})
+
+	t.Run("ChartVersion", func(t *testing.T) {
+		config := &Config{
+			App:          "test-app",
+			Author:       "test-author",
+			Project:      "test-project",
+			ChartVersion: "1.4.0",
+		}
+
+		task := createTask(config)
+
+		assert.Equal(t, "1.4.0", task.ChartVersion)
+		assert.Empty(t, task.Images)
+	})
+}
+
+func TestPrintPreview(t *testing.T) {
+	t.Run("Change", func(t *testing.T) {
+		var out bytes.Buffer
+		printPreview(&out, &models.WriteBackPreview{
+			App:           "test-app",
+			Managed:       true,
+			Repo:          "git@example.com:gitops.git",
+			Branch:        "main",
+			Paths:         []string{"apps/.argocd-source-test-app.yaml", "apps/values-prod.yaml"},
+			CommitMessage: "argo-watcher(test-app): update image tag",
+			Diff:          "--- a/apps/.argocd-source-test-app.yaml\n",
+		})
+
+		assert.Equal(t, "Dry run for test-app: nothing was deployed or pushed.\n"+
+			"Repository: git@example.com:gitops.git\n"+
+			"Branch: main\n"+
+			"Files: apps/.argocd-source-test-app.yaml, apps/values-prod.yaml\n"+
+			"Commit message: argo-watcher(test-app): update image tag\n\n"+
+			"--- a/apps/.argocd-source-test-app.yaml\n", out.String())
+	})
+
+	t.Run("NoChangeOnUnmanagedApp", func(t *testing.T) {
+		var out bytes.Buffer
+		printPreview(&out, &models.WriteBackPreview{
+			App:    "test-app",
+			Repo:   "git@example.com:gitops.git",
+			Branch: "main",
+			Paths:  []string{"apps/.argocd-source-test-app.yaml"},
+		})
+
+		assert.Contains(t, out.String(), "Write-back is not enabled on this application yet")
+		assert.Contains(t, out.String(), "the write-back would commit nothing")
+		assert.NotContains(t, out.String(), "Commit message")
+	})
 }
 
 func TestPrintClientConfiguration(t *testing.T) {
@@ -417,7 +515,7 @@ func TestPrintClientConfiguration(t *testing.T) {
 		"COMMIT_AUTHOR: test-author\n" +
 		"PROJECT_NAME: test-project\n" +
 		"IMAGE_TAG: test-tag\n" +
-		"IMAGES: [{image1 test-tag} {image2 test-tag}]\n\n" +
+		"IMAGES: [image1:test-tag image2:test-tag]\n\n" +
 		"Neither deploy token nor JSON Web token found, git commit will not be performed\n"
 
 	oldStdout := os.Stdout
@@ -91,11 +91,17 @@ type MattermostConfig struct {
 type ServerConfig struct {
 	ArgoUrl            url.URL          `env:"ARGO_URL,required,notEmpty" json:"argo_cd_url"`
 	ArgoUrlAlias       string           `env:"ARGO_URL_ALIAS" json:"argo_cd_url_alias,omitempty"` // Used to generate App Url. Can be omitted if ArgoUrl is reachable from outside.
-	ArgoToken          string           `env:"ARGO_TOKEN,required,notEmpty" json:"-"`
+	ArgoToken          string           `env:"ARGO_TOKEN" json:"-"`                               // Static API token; ArgoUsername and ArgoPassword replace it (see argoCredentialProblems).
+	ArgoUsername       string           `env:"ARGO_USERNAME" json:"-"`                            // Account logged in through ArgoCD's session API, whose token is renewed before it expires.
+	ArgoPassword       string           `env:"ARGO_PASSWORD" json:"-"`
 	ArgoApiTimeout     int64            `env:"ARGO_API_TIMEOUT" envDefault:"60" json:"argo_api_timeout"`
 	AcceptSuspendedApp bool             `env:"ACCEPT_SUSPENDED_APP" envDefault:"false" json:"accept_suspended_app"`
+	PreventDowngrades  bool             `env:"PREVENT_DOWNGRADES" envDefault:"false" json:"prevent_downgrades"` // Reject a task whose semver tags are older than the ones deployed, unless it is marked as a rollback or sent by a privileged user.
 	DeploymentTimeout  uint             `env:"DEPLOYMENT_TIMEOUT" envDefault:"900" json:"deployment_timeout"`
 	ArgoRefreshApp     bool             `env:"ARGO_REFRESH_APP" envDefault:"true" json:"argo_refresh_app"`
+	ArgoSyncApp        bool             `env:"ARGO_SYNC_APP" envDefault:"false" json:"argo_sync_app"`           // Trigger the sync of applications without auto-sync; the argo-watcher/sync annotation overrides it per app.
+	RolloutMaxDuration uint             `env:"ROLLOUT_MAX_DURATION" envDefault:"0" json:"rollout_max_duration"` // Upper bound, in seconds, for a deadline extended while Argo Rollouts steps keep advancing; 0 keeps the deadline fixed.
+	FailureLogLines    uint             `env:"FAILURE_LOG_LINES" envDefault:"20" json:"failure_log_lines"`      // Log lines of each unhealthy pod quoted in a rollout failure reason; 0 quotes none.
 	RegistryProxyUrl   string           `env:"DOCKER_IMAGES_PROXY" json:"registry_proxy_url,omitempty"`
 	StateType          string           `env:"STATE_TYPE,required" json:"state_type"`
 	StaticFilePath     string           `env:"STATIC_FILES_PATH" envDefault:"static" json:"-"`
@@ -245,6 +251,8 @@ func NewServerConfig() (*ServerConfig, error) {
 
 	// Trim whitespace from tokens to prevent issues with trailing newlines from env vars
 	config.ArgoToken = strings.TrimSpace(config.ArgoToken)
+	config.ArgoUsername = strings.TrimSpace(config.ArgoUsername)
+	config.ArgoPassword = strings.TrimSpace(config.ArgoPassword)
 	config.DeployToken = strings.TrimSpace(config.DeployToken)
 	config.JWTSecret = strings.TrimSpace(config.JWTSecret)
 	config.Mattermost.Token = strings.TrimSpace(config.Mattermost.Token)
@@ -309,6 +317,24 @@ func taskRetentionProblems(config *ServerConfig) []string {
 	return nil
 }
 
+// argoCredentialProblems reports what keeps argo-watcher from authenticating to ArgoCD:
+// neither a static token nor account credentials, half of the credentials, or both ways
+// at once — which would leave it unclear which one a failing login is due to.
+func argoCredentialProblems(config *ServerConfig) []string {
+	hasAccount := config.ArgoUsername != "" || config.ArgoPassword != ""
+	switch {
+	case config.ArgoToken != "" && hasAccount:
+		return []string{"  - ArgoToken: ARGO_TOKEN cannot be combined with ARGO_USERNAME and ARGO_PASSWORD; set one or the other"}
+	case config.ArgoToken == "" && !hasAccount:
+		return []string{"  - ArgoToken: must be set, or replaced by ARGO_USERNAME and ARGO_PASSWORD"}
+	case hasAccount && config.ArgoUsername == "":
+		return []string{"  - ArgoUsername: ARGO_USERNAME must be set together with ARGO_PASSWORD"}
+	case hasAccount && config.ArgoPassword == "":
+		return []string{"  - ArgoPassword: ARGO_PASSWORD must be set together with ARGO_USERNAME"}
+	}
+	return nil
+}
+
 // validateServerConfig checks the semantic rules that env parsing cannot
 // express (allowed enum values, numeric ranges). It reports every violation in
 // one grouped message — mirroring helpers.PrettifyEnvError — so an operator can
@@ -344,6 +370,7 @@ func validateServerConfig(config *ServerConfig) error {
 		problems = append(problems, "  - OIDC.RequireTaskReadAuth: OIDC_REQUIRE_TASK_READ_AUTH requires OIDC_ENABLED=true; with OIDC disabled no read endpoint is protected")
 	}
 	problems = append(problems, taskRetentionProblems(config)...)
+	problems = append(problems, argoCredentialProblems(config)...)
 
 	if len(problems) == 0 {
 		return nil
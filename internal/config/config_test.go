@@ -152,7 +152,6 @@ func TestNewServerConfig_RequiredFieldsMissing(t *testing.T) {
 	// STATE_TYPE is intentionally not asserted: the project's Taskfile sets
 	// STATE_TYPE=in-memory for `task test` runs.
 	assert.Contains(t, err.Error(), "missing required environment variables:")
-	assert.Contains(t, err.Error(), "ARGO_TOKEN")
 }
 
 func TestNewServerConfig_InvalidStateType_IsReadable(t *testing.T) {
@@ -181,17 +180,62 @@ func TestNewServerConfig_InvalidArgoApiRetries_IsReadable(t *testing.T) {
 	assert.Contains(t, err.Error(), "got 11")
 }
 
-// A required variable that is present but empty is rejected at parse time (the
-// `,notEmpty` tag), not silently accepted and left to fail later.
-func TestNewServerConfig_EmptyRequiredRejected(t *testing.T) {
+// An ARGO_TOKEN that is present but empty counts as missing, not as a token to send.
+func TestNewServerConfig_EmptyArgoTokenRejected(t *testing.T) {
 	t.Setenv("ARGO_URL", "https://example.com")
 	t.Setenv("STATE_TYPE", "in-memory")
-	t.Setenv("ARGO_TOKEN", "") // set, but empty
+	t.Setenv("ARGO_TOKEN", " ") // set, but blank
 
 	_, err := NewServerConfig()
 	assert.Error(t, err)
-	assert.Contains(t, err.Error(), "ARGO_TOKEN")
-	assert.Contains(t, err.Error(), "should not be empty")
+	assert.Contains(t, err.Error(), "ArgoToken: must be set, or replaced by ARGO_USERNAME and ARGO_PASSWORD")
+}
+
+func TestNewServerConfig_ArgoCredentials(t *testing.T) {
+	testCases := []struct {
+		name     string
+		env      map[string]string
+		expected string
+	}{
+		{
+			name: "account credentials replace the token",
+			env:  map[string]string{"ARGO_USERNAME": "argo-watcher", "ARGO_PASSWORD": "secret"},
+		},
+		{
+			name:     "token and credentials together",
+			env:      map[string]string{"ARGO_TOKEN": "secret-token", "ARGO_USERNAME": "argo-watcher", "ARGO_PASSWORD": "secret"},
+			expected: "cannot be combined",
+		},
+		{
+			name:     "username without password",
+			env:      map[string]string{"ARGO_USERNAME": "argo-watcher"},
+			expected: "ARGO_PASSWORD must be set together with ARGO_USERNAME",
+		},
+		{
+			name:     "password without username",
+			env:      map[string]string{"ARGO_PASSWORD": "secret"},
+			expected: "ARGO_USERNAME must be set together with ARGO_PASSWORD",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Setenv("ARGO_URL", "https://example.com")
+			t.Setenv("STATE_TYPE", "in-memory")
+			for key, value := range tc.env {
+				t.Setenv(key, value)
+			}
+
+			cfg, err := NewServerConfig()
+			if tc.expected == "" {
+				require.NoError(t, err)
+				assert.Equal(t, "argo-watcher", cfg.ArgoUsername)
+				return
+			}
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), tc.expected)
+		})
+	}
 }
 
 func TestServerConfig_GetRetryAttempts(t *testing.T) {
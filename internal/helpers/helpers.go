@@ -11,8 +11,28 @@ import (
 )
 
 // ImagesContains reports whether images contains image. When a registry proxy is
-// set it matches the image both with and without the proxy prefix.
+// set it matches the image both with and without the proxy prefix. An image pinned to
+// a digest ("name:tag@digest") matches an image of the same name at that digest,
+// whatever tag it is written with, as a Kustomize override drops the tag. It also
+// matches the image at its tag with no digest at all: one the write-back did not pin,
+// such as an image of an application it does not manage.
 func ImagesContains(images []string, image string, registryProxy string) bool {
+	if tagged, digest, pinned := strings.Cut(image, "@"); pinned {
+		name := ImageName(image)
+		tag := strings.TrimPrefix(tagged, name)
+		return slices.ContainsFunc(images, func(candidate string) bool {
+			candidateName := ImageName(candidate)
+			if candidateName != name && (registryProxy == "" || candidateName != registryProxy+"/"+name) {
+				return false
+			}
+			candidateTagged, candidateDigest, found := strings.Cut(candidate, "@")
+			if !found {
+				return tag != "" && strings.TrimPrefix(candidateTagged, candidateName) == tag
+			}
+			return candidateDigest == digest
+		})
+	}
+
 	if registryProxy != "" {
 		imageWithProxy := registryProxy + "/" + image
 		// We need to check image with and without proxy because mutating webhook
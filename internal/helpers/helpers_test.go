@@ -31,6 +31,18 @@ var imageContainsTest = []imagesContainsTest{
 	{[]string{fmt.Sprintf("%s/%s", registryProxy, image1), image2, image3}, image1, registryProxy, true},
 	{[]string{image1, image2, image3}, image1, registryProxy, true},
 	{[]string{image1, image2, image3}, "v0.0.2", registryProxy, false},
+	// An image pinned to a digest matches on its name and digest, whatever the tag.
+	{[]string{"app:v0.0.1@sha256:aaa", image2}, "app:v0.0.1@sha256:aaa", "", true},
+	{[]string{"app@sha256:aaa", image2}, "app:v0.0.1@sha256:aaa", "", true},
+	{[]string{"app:v0.0.1@sha256:bbb", image2}, "app:v0.0.1@sha256:aaa", "", false},
+	// An image the write-back did not pin is matched on its tag alone.
+	{[]string{image1, image2}, "app:v0.0.1@sha256:aaa", "", true},
+	{[]string{fmt.Sprintf("%s/%s", registryProxy, image1)}, "app:v0.0.1@sha256:aaa", registryProxy, true},
+	{[]string{"app:v0.0.2", image2}, "app:v0.0.1@sha256:aaa", "", false},
+	{[]string{"app", image2}, "app:v0.0.1@sha256:aaa", "", false},
+	{[]string{"worker@sha256:aaa", image2}, "app:v0.0.1@sha256:aaa", "", false},
+	{[]string{fmt.Sprintf("%s/app@sha256:aaa", registryProxy)}, "app:v0.0.1@sha256:aaa", registryProxy, true},
+	{[]string{fmt.Sprintf("%s/app@sha256:aaa", registryProxy)}, "app:v0.0.1@sha256:aaa", "", false},
 }
 
 func TestImageContains(t *testing.T) {
@@ -0,0 +1,138 @@
+package models
+
+import (
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// analysisAnnotationPrefix starts the annotations declaring the analysis queries of an
+	// application: argo-watcher/analysis.<name>.query holds the PromQL, and
+	// argo-watcher/analysis.<name>.min and .max the range every sample must stay within.
+	analysisAnnotationPrefix = "argo-watcher/analysis."
+	// analysisDurationAnnotation overrides how long the queries are evaluated for.
+	analysisDurationAnnotation = "argo-watcher/analysis-duration"
+)
+
+// AnalysisQuery is a PromQL query whose every sample must lie within [Min, Max] for the
+// deployment to pass its analysis. Either bound may be absent, but not both.
+type AnalysisQuery struct {
+	Name  string   `yaml:"name"`
+	Query string   `yaml:"query"`
+	Min   *float64 `yaml:"min"`
+	Max   *float64 `yaml:"max"`
+}
+
+// AnalysisSpec is the post-deployment analysis of an application: queries evaluated
+// repeatedly for Duration once the rollout succeeded. A zero Duration leaves it to the
+// instance default.
+type AnalysisSpec struct {
+	Duration time.Duration
+	Queries  []AnalysisQuery
+}
+
+// Validate reports the first query that cannot be evaluated as declared.
+func (query *AnalysisQuery) Validate() error {
+	switch {
+	case strings.TrimSpace(query.Query) == "":
+		return fmt.Errorf("analysis %q has no query", query.Name)
+	case query.Min == nil && query.Max == nil:
+		return fmt.Errorf("analysis %q has neither a min nor a max threshold", query.Name)
+	case query.Min != nil && query.Max != nil && *query.Min > *query.Max:
+		return fmt.Errorf("analysis %q has a min threshold above its max", query.Name)
+	}
+	return nil
+}
+
+// Violation describes how value breaks the query's thresholds, or returns "" when it
+// does not. labels identifies the series the value belongs to.
+func (query *AnalysisQuery) Violation(value float64, labels string) string {
+	series := query.Name
+	if labels != "" {
+		series += " " + labels
+	}
+
+	switch {
+	case query.Min != nil && value < *query.Min:
+		return fmt.Sprintf("%s = %s, below the minimum %s", series, formatAnalysisValue(value), formatAnalysisValue(*query.Min))
+	case query.Max != nil && value > *query.Max:
+		return fmt.Sprintf("%s = %s, above the maximum %s", series, formatAnalysisValue(value), formatAnalysisValue(*query.Max))
+	}
+	return ""
+}
+
+func formatAnalysisValue(value float64) string {
+	return strconv.FormatFloat(value, 'g', -1, 64)
+}
+
+// AnalysisSpec returns the analysis the application declares through its annotations,
+// nil when it declares none, and an error naming the annotation at fault when one of
+// them is malformed.
+func (app *Application) AnalysisSpec() (*AnalysisSpec, error) {
+	queries := map[string]*AnalysisQuery{}
+	spec := &AnalysisSpec{}
+
+	for key, value := range app.Metadata.Annotations {
+		if key == analysisDurationAnnotation {
+			duration, err := time.ParseDuration(value)
+			if err != nil || duration <= 0 {
+				return nil, fmt.Errorf("annotation %s: %q is not a positive duration", key, value)
+			}
+			spec.Duration = duration
+			continue
+		}
+
+		rest, ok := strings.CutPrefix(key, analysisAnnotationPrefix)
+		if !ok {
+			continue
+		}
+		name, field, ok := strings.Cut(rest, ".")
+		if !ok || name == "" {
+			return nil, fmt.Errorf("annotation %s: expected %s<name>.query, .min or .max", key, analysisAnnotationPrefix)
+		}
+
+		query, found := queries[name]
+		if !found {
+			query = &AnalysisQuery{Name: name}
+			queries[name] = query
+		}
+
+		switch field {
+		case "query":
+			query.Query = value
+		case "min", "max":
+			threshold, err := strconv.ParseFloat(strings.TrimSpace(value), 64)
+			if err != nil {
+				return nil, fmt.Errorf("annotation %s: %q is not a number", key, value)
+			}
+			if field == "min" {
+				query.Min = &threshold
+			} else {
+				query.Max = &threshold
+			}
+		default:
+			return nil, fmt.Errorf("annotation %s: expected %s<name>.query, .min or .max", key, analysisAnnotationPrefix)
+		}
+	}
+
+	if len(queries) == 0 {
+		if spec.Duration != 0 {
+			return nil, fmt.Errorf("annotation %s is set, but no analysis query is declared", analysisDurationAnnotation)
+		}
+		return nil, nil
+	}
+
+	for _, query := range queries {
+		if err := query.Validate(); err != nil {
+			return nil, err
+		}
+		spec.Queries = append(spec.Queries, *query)
+	}
+	// Sorted so the queries run, and their failures are listed, in a stable order.
+	slices.SortFunc(spec.Queries, func(a, b AnalysisQuery) int { return strings.Compare(a.Name, b.Name) })
+
+	return spec, nil
+}
@@ -0,0 +1,88 @@
+package models
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAnalysisSpec(t *testing.T) {
+	app := &Application{}
+	app.Metadata.Annotations = map[string]string{
+		"argo-watcher/analysis.latency.query":    "latency_p99",
+		"argo-watcher/analysis.latency.max":      "0.3",
+		"argo-watcher/analysis.error-rate.query": "error_rate",
+		"argo-watcher/analysis.error-rate.min":   "0",
+		"argo-watcher/analysis.error-rate.max":   "0.01",
+		"argo-watcher/analysis-duration":         "10m",
+	}
+
+	spec, err := app.AnalysisSpec()
+	require.NoError(t, err)
+	assert.Equal(t, 10*time.Minute, spec.Duration)
+	require.Len(t, spec.Queries, 2)
+	assert.Equal(t, "error-rate", spec.Queries[0].Name)
+	assert.Equal(t, 0.0, *spec.Queries[0].Min)
+	assert.Equal(t, 0.01, *spec.Queries[0].Max)
+	assert.Equal(t, "latency", spec.Queries[1].Name)
+	assert.Nil(t, spec.Queries[1].Min)
+}
+
+func TestAnalysisSpecWithoutQueries(t *testing.T) {
+	app := &Application{}
+	spec, err := app.AnalysisSpec()
+	assert.NoError(t, err)
+	assert.Nil(t, spec)
+
+	app.Metadata.Annotations = map[string]string{"argo-watcher/analysis-duration": "1m"}
+	_, err = app.AnalysisSpec()
+	assert.ErrorContains(t, err, "no analysis query is declared")
+}
+
+func TestAnalysisSpecRejectsMalformedAnnotations(t *testing.T) {
+	tests := map[string]struct {
+		annotations map[string]string
+		message     string
+	}{
+		"unknown field": {
+			annotations: map[string]string{"argo-watcher/analysis.latency.limit": "1"},
+			message:     "expected argo-watcher/analysis.<name>.query, .min or .max",
+		},
+		"threshold not a number": {
+			annotations: map[string]string{"argo-watcher/analysis.latency.query": "q", "argo-watcher/analysis.latency.max": "fast"},
+			message:     `"fast" is not a number`,
+		},
+		"no threshold": {
+			annotations: map[string]string{"argo-watcher/analysis.latency.query": "q"},
+			message:     "neither a min nor a max threshold",
+		},
+		"inverted range": {
+			annotations: map[string]string{"argo-watcher/analysis.latency.query": "q", "argo-watcher/analysis.latency.min": "2", "argo-watcher/analysis.latency.max": "1"},
+			message:     "min threshold above its max",
+		},
+		"no query": {
+			annotations: map[string]string{"argo-watcher/analysis.latency.max": "1"},
+			message:     `analysis "latency" has no query`,
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			app := &Application{}
+			app.Metadata.Annotations = tt.annotations
+			_, err := app.AnalysisSpec()
+			assert.ErrorContains(t, err, tt.message)
+		})
+	}
+}
+
+func TestAnalysisQueryViolation(t *testing.T) {
+	low, high := 1.0, 2.0
+	query := AnalysisQuery{Name: "rps", Min: &low, Max: &high}
+
+	assert.Empty(t, query.Violation(1.5, ""))
+	assert.Equal(t, "rps = 0.5, below the minimum 1", query.Violation(0.5, ""))
+	assert.Equal(t, `rps {pod="a"} = 3, above the maximum 2`, query.Violation(3, `{pod="a"}`))
+}
@@ -0,0 +1,191 @@
+package models
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/shini4i/argo-watcher/internal/helpers"
+)
+
+const (
+	// ApplicationGroup and ApplicationKind identify an ArgoCD Application in another
+	// application's resource tree: the children of an app of apps.
+	ApplicationGroup = "argoproj.io"
+	ApplicationKind  = "Application"
+
+	// appOfAppsAnnotation marks an application whose resources are other applications. Its
+	// rollout is judged on the children that run the task's images rather than on its own
+	// summary, which lists no image at all.
+	appOfAppsAnnotation = "argo-watcher/app-of-apps"
+)
+
+// IsAppOfApps reports whether the application asked to be tracked through its child
+// applications.
+func (app *Application) IsAppOfApps() bool {
+	return app.Metadata.Annotations[appOfAppsAnnotation] == "true"
+}
+
+// ChildApplicationNames returns the names of the Applications in the tree, sorted and
+// without duplicates.
+func (tree *ApplicationTree) ChildApplicationNames() []string {
+	if tree == nil {
+		return nil
+	}
+
+	var names []string
+	for _, node := range tree.Nodes {
+		if node.Group == ApplicationGroup && node.Kind == ApplicationKind {
+			names = append(names, node.Name)
+		}
+	}
+	slices.Sort(names)
+	return slices.Compact(names)
+}
+
+// CarriedImages returns the rollout images whose repository the application runs, at any
+// tag. An application carries an image before it runs the expected tag, which is what tells
+// the child waiting for a deployment from its siblings.
+func (app *Application) CarriedImages(rolloutImages []string, registryProxyUrl string) []string {
+	running := make([]string, 0, len(app.Status.Summary.Images))
+	for _, image := range app.Status.Summary.Images {
+		name := helpers.ImageName(image)
+		if registryProxyUrl != "" {
+			name = strings.TrimPrefix(name, registryProxyUrl+"/")
+		}
+		running = append(running, name)
+	}
+
+	var carried []string
+	for _, image := range rolloutImages {
+		if slices.Contains(running, helpers.ImageName(image)) {
+			carried = append(carried, image)
+		}
+	}
+	return carried
+}
+
+// ChildRollout is the rollout of one child application of an app of apps.
+type ChildRollout struct {
+	Application *Application
+	// Images are the task's images the child carries (see CarriedImages).
+	Images []string
+	// Status is the child's rollout status for those images (see GetRolloutStatus).
+	Status string
+}
+
+// AppOfAppsRollout is the rollout of an app of apps, as observed on its children.
+type AppOfAppsRollout struct {
+	// Children are the child applications carrying any of the task's images, in name order.
+	// Children carrying none of them are not part of the deployment and are left out.
+	Children []ChildRollout
+	// Missing are the task's images no child carries.
+	Missing []string
+}
+
+// NewAppOfAppsRollout assesses the children of an app of apps against the task's images.
+func NewAppOfAppsRollout(children []*Application, rolloutImages []string, registryProxyUrl string, acceptSuspended bool) *AppOfAppsRollout {
+	rollout := &AppOfAppsRollout{}
+	carried := map[string]bool{}
+
+	for _, child := range children {
+		images := child.CarriedImages(rolloutImages, registryProxyUrl)
+		if len(images) == 0 {
+			continue
+		}
+		for _, image := range images {
+			carried[image] = true
+		}
+		rollout.Children = append(rollout.Children, ChildRollout{
+			Application: child,
+			Images:      images,
+			Status:      child.GetRolloutStatus(images, registryProxyUrl, acceptSuspended),
+		})
+	}
+
+	for _, image := range rolloutImages {
+		if !carried[image] {
+			rollout.Missing = append(rollout.Missing, image)
+		}
+	}
+
+	return rollout
+}
+
+// Status sums up the children: "degraded" as soon as one child is, since that is terminal;
+// "not available" while an image has no child to run it; otherwise the status of the first
+// child that has not rolled out yet, and "success" once every one of them has.
+func (rollout *AppOfAppsRollout) Status() string {
+	for _, child := range rollout.Children {
+		if child.Status == ArgoRolloutAppDegraded {
+			return ArgoRolloutAppDegraded
+		}
+	}
+
+	if len(rollout.Missing) > 0 {
+		return ArgoRolloutAppNotAvailable
+	}
+
+	for _, child := range rollout.Children {
+		if child.Status != ArgoRolloutAppSuccess {
+			return child.Status
+		}
+	}
+
+	return ArgoRolloutAppSuccess
+}
+
+// FailedChildren returns the children that have not rolled out.
+func (rollout *AppOfAppsRollout) FailedChildren() []ChildRollout {
+	var failed []ChildRollout
+	for _, child := range rollout.Children {
+		if child.Status != ArgoRolloutAppSuccess {
+			failed = append(failed, child)
+		}
+	}
+	return failed
+}
+
+// FailureReport renders the failure of an app of apps, one section per child that did not
+// roll out, each with the report GetRolloutMessage gives for a standalone application.
+// trees holds the live resource trees of the failed children by name; a missing one only
+// leaves that child's report without its pod-level causes.
+func (rollout *AppOfAppsRollout) FailureReport(waited time.Duration, trees map[string]*ApplicationTree) string {
+	failed := rollout.FailedChildren()
+
+	summary := make([]string, 0, len(failed))
+	for _, child := range failed {
+		summary = append(summary, fmt.Sprintf("%s (%s)", child.Application.Metadata.Name, child.Status))
+	}
+
+	var headline string
+	switch {
+	case len(failed) > 0:
+		headline = "Application deployment failed. Child applications not rolled out: " + strings.Join(summary, ", ")
+	default:
+		headline = "Application deployment failed. No child application runs every expected image"
+	}
+	// Anything under a second rounds to "0s", as in RolloutFailureHeadline.
+	if rounded := waited.Round(time.Second); rounded >= time.Second {
+		headline += fmt.Sprintf(" after waiting %s", rounded)
+	}
+
+	sections := []string{headline + "."}
+
+	if len(rollout.Missing) > 0 {
+		sections = append(sections, "Images no child application runs:\n\t"+strings.Join(rollout.Missing, "\n\t"))
+	}
+
+	for _, child := range failed {
+		name := child.Application.Metadata.Name
+		sections = append(sections, fmt.Sprintf(
+			"Child application %q (%s):\n%s",
+			name,
+			child.Status,
+			child.Application.GetRolloutMessage(child.Status, child.Images, trees[name]),
+		))
+	}
+
+	return strings.Join(sections, "\n\n")
+}
@@ -0,0 +1,107 @@
+package models
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func childApplication(name, health string, images ...string) *Application {
+	app := &Application{}
+	app.Metadata.Name = name
+	app.Status.Summary.Images = images
+	app.Status.Sync.Status = "Synced"
+	app.Status.Health.Status = health
+	return app
+}
+
+func TestChildApplicationNames(t *testing.T) {
+	tree := &ApplicationTree{Nodes: []ApplicationTreeNode{
+		{Group: "argoproj.io", Kind: "Application", Name: "web"},
+		{Group: "apps", Kind: "Deployment", Name: "web"},
+		{Group: "argoproj.io", Kind: "Rollout", Name: "api"},
+		{Group: "argoproj.io", Kind: "Application", Name: "api"},
+		{Group: "argoproj.io", Kind: "Application", Name: "web"},
+	}}
+
+	assert.Equal(t, []string{"api", "web"}, tree.ChildApplicationNames())
+
+	var missing *ApplicationTree
+	assert.Empty(t, missing.ChildApplicationNames())
+}
+
+func TestCarriedImages(t *testing.T) {
+	app := childApplication("web", "Healthy", "proxy.example.com/ghcr.io/shini4i/web:v1", "redis:7")
+
+	assert.Equal(t, []string{"ghcr.io/shini4i/web:v2"},
+		app.CarriedImages([]string{"ghcr.io/shini4i/web:v2", "ghcr.io/shini4i/api:v2"}, "proxy.example.com"))
+	assert.Empty(t, app.CarriedImages([]string{"ghcr.io/shini4i/web:v2"}, ""), "without the proxy the repository differs")
+}
+
+func TestAppOfAppsRolloutStatus(t *testing.T) {
+	images := []string{"ghcr.io/shini4i/web:v2", "ghcr.io/shini4i/api:v2"}
+
+	testCases := []struct {
+		name     string
+		children []*Application
+		expected string
+		missing  []string
+	}{
+		{
+			name: "every carrying child rolled out",
+			children: []*Application{
+				childApplication("api", "Healthy", "ghcr.io/shini4i/api:v2"),
+				childApplication("db", "Degraded", "postgres:16"),
+				childApplication("web", "Healthy", "ghcr.io/shini4i/web:v2"),
+			},
+			expected: ArgoRolloutAppSuccess,
+		},
+		{
+			name: "a child still runs the previous tag",
+			children: []*Application{
+				childApplication("api", "Healthy", "ghcr.io/shini4i/api:v2"),
+				childApplication("web", "Healthy", "ghcr.io/shini4i/web:v1"),
+			},
+			expected: ArgoRolloutAppNotAvailable,
+		},
+		{
+			name: "no child runs an image",
+			children: []*Application{
+				childApplication("web", "Healthy", "ghcr.io/shini4i/web:v2"),
+			},
+			expected: ArgoRolloutAppNotAvailable,
+			missing:  []string{"ghcr.io/shini4i/api:v2"},
+		},
+		{
+			name: "a degraded child outweighs a pending one",
+			children: []*Application{
+				childApplication("api", "Progressing", "ghcr.io/shini4i/api:v2"),
+				childApplication("web", "Degraded", "ghcr.io/shini4i/web:v2"),
+			},
+			expected: ArgoRolloutAppDegraded,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			rollout := NewAppOfAppsRollout(tc.children, images, "", false)
+			assert.Equal(t, tc.expected, rollout.Status())
+			assert.Equal(t, tc.missing, rollout.Missing)
+		})
+	}
+}
+
+func TestAppOfAppsFailureReport(t *testing.T) {
+	rollout := NewAppOfAppsRollout([]*Application{
+		childApplication("api", "Healthy", "ghcr.io/shini4i/api:v2"),
+		childApplication("web", "Progressing", "ghcr.io/shini4i/web:v2"),
+	}, []string{"ghcr.io/shini4i/web:v2", "ghcr.io/shini4i/api:v2", "ghcr.io/shini4i/worker:v2"}, "", false)
+
+	expected := "Application deployment failed. Child applications not rolled out: web (not healthy) after waiting 5m0s.\n\n" +
+		"Images no child application runs:\n\tghcr.io/shini4i/worker:v2\n\n" +
+		"Child application \"web\" (not healthy):\n" +
+		"App sync status \"Synced\"\nApp health status \"Progressing\""
+
+	assert.Equal(t, expected, rollout.FailureReport(5*time.Minute, nil))
+}
@@ -27,6 +27,18 @@ const (
 	// whose images it cannot see: used only by sync hooks (ArgoCD omits those resources),
 	// or named by a custom resource whose workload an operator creates out-of-band.
 	skipImageValidationAnnotation = "argo-watcher/skip-image-validation"
+	// syncAnnotation overrides the instance-wide ARGO_SYNC_APP for one application: "true"
+	// makes argo-watcher trigger the sync an application without auto-sync needs, "false"
+	// keeps it from doing so. syncPruneAnnotation and syncRevisionAnnotation shape that sync.
+	syncAnnotation         = "argo-watcher/sync"
+	syncPruneAnnotation    = "argo-watcher/sync-prune"
+	syncRevisionAnnotation = "argo-watcher/sync-revision"
+	// autoRollbackAnnotation makes a failed deployment of a managed application write back
+	// the images of its last successful deployment.
+	autoRollbackAnnotation = "argo-watcher/auto-rollback"
+	// waitForCommitAnnotation set to "false" lets a deployment that wrote back succeed on
+	// its images alone, without waiting for ArgoCD to sync the commit it pushed.
+	waitForCommitAnnotation = "argo-watcher/wait-for-commit"
 )
 
 type ApplicationOperationResource struct {
@@ -72,9 +84,12 @@ type ApplicationTree struct {
 }
 
 type ApplicationTreeNode struct {
+	Group     string `json:"group"`
+	Version   string `json:"version"`
 	Kind      string `json:"kind"`
 	Name      string `json:"name"`
 	Namespace string `json:"namespace"`
+	UID       string `json:"uid"`
 	Health    struct {
 		Status  string `json:"status"`
 		Message string `json:"message"`
@@ -109,8 +124,11 @@ type ApplicationStatus struct {
 }
 
 type ApplicationStatusOperationState struct {
-	Phase      string `json:"phase"`
-	Message    string `json:"message"`
+	Phase   string `json:"phase"`
+	Message string `json:"message"`
+	// StartedAt is when the operation began, as ArgoCD renders it. It tells one operation from
+	// the next, which the phase alone cannot: two syncs in a row both end "Succeeded".
+	StartedAt  string `json:"startedAt"`
 	SyncResult struct {
 		Resources []ApplicationOperationResource `json:"resources"`
 		// Revision is the revision this sync applied, which is not necessarily the one the
@@ -120,12 +138,64 @@ type ApplicationStatusOperationState struct {
 	} `json:"syncResult"`
 }
 
+// IsFailed reports whether the operation ended in a terminal failure.
+func (operation *ApplicationStatusOperationState) IsFailed() bool {
+	return isTerminalFailurePhase(operation.Phase)
+}
+
+// ApplicationSyncRequest is the body of ArgoCD's POST /api/v1/applications/{name}/sync. An empty
+// Revision syncs to the application's own target revision.
+type ApplicationSyncRequest struct {
+	Revision string `json:"revision,omitempty"`
+	Prune    bool   `json:"prune"`
+}
+
+// SessionRequest is the body of ArgoCD's POST /api/v1/session, which logs an account in.
+type SessionRequest struct {
+	Username string `json:"username"`
+	Password string `json:"password"`
+}
+
+// SessionResponse carries the session token POST /api/v1/session issues.
+type SessionResponse struct {
+	Token string `json:"token"`
+}
+
 type ApplicationMetadata struct {
 	Name        string            `json:"name"`
+	Labels      map[string]string `json:"labels,omitempty"`
 	Annotations map[string]string `json:"annotations"`
+	// OwnerReferences names the ApplicationSet that generated the application, if any.
+	OwnerReferences []OwnerReference `json:"ownerReferences,omitempty"`
+}
+
+// OwnerReference is the part of a Kubernetes owner reference that identifies the owner.
+type OwnerReference struct {
+	Kind string `json:"kind"`
+	Name string `json:"name"`
+}
+
+// ApplicationSetKind is the owner reference kind of an application generated by an ApplicationSet.
+const ApplicationSetKind = "ApplicationSet"
+
+// IsOwnedByApplicationSet reports whether the named ApplicationSet generated the application.
+func (app *Application) IsOwnedByApplicationSet(name string) bool {
+	for _, owner := range app.Metadata.OwnerReferences {
+		if owner.Kind == ApplicationSetKind && owner.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// ApplicationList is the response of ArgoCD's /api/v1/applications endpoint.
+type ApplicationList struct {
+	Items []Application `json:"items"`
 }
 
 type ApplicationSpec struct {
+	// Project is the ArgoCD project the application belongs to.
+	Project    string                 `json:"project,omitempty"`
 	Source     ApplicationSource      `json:"source"`
 	Sources    []ApplicationSource    `json:"sources"`
 	SyncPolicy *ApplicationSyncPolicy `json:"syncPolicy"`
@@ -150,6 +220,9 @@ type ApplicationSource struct {
 	RepoURL        string `json:"repoURL"`
 	TargetRevision string `json:"targetRevision"`
 	Path           string `json:"path"`
+	// Chart names the Helm chart of a source read from a chart repository rather than
+	// from git; TargetRevision is then the chart's version.
+	Chart string `json:"chart,omitempty"`
 }
 
 // GetRolloutStatus calculates application rollout status depending on the expected images and proxy configuration.
@@ -501,6 +574,30 @@ func (app *Application) lastSyncOperationLine() string {
 	return line
 }
 
+// SyncedRevision returns the revision ArgoCD's last sync applied, one per source joined by
+// commas for a multi-source application, or an empty string when no sync is recorded.
+func (app *Application) SyncedRevision() string {
+	return newRevisions(app.Status.OperationState.SyncResult.Revision, app.Status.OperationState.SyncResult.Revisions).key
+}
+
+// SyncedCommits returns the git commits ArgoCD's last sync applied, one per source whose
+// revision is a commit: the version of a Helm chart source names none.
+func (app *Application) SyncedCommits() []string {
+	result := app.Status.OperationState.SyncResult
+	list := result.Revisions
+	if result.Revision != "" && len(list) <= 1 {
+		list = []string{result.Revision}
+	}
+
+	commits := make([]string, 0, len(list))
+	for _, revision := range list {
+		if isCommitSha(revision) {
+			commits = append(commits, revision)
+		}
+	}
+	return commits
+}
+
 // AutoSyncEnabled reports whether ArgoCD applies this application's desired state on its own.
 func (app *Application) AutoSyncEnabled() bool {
 	if app.Spec.SyncPolicy == nil || app.Spec.SyncPolicy.Automated == nil {
@@ -552,17 +649,26 @@ func newRevisions(revision string, list []string) revisions {
 // shortRevision abbreviates a full git SHA to the seven characters ArgoCD's own UI shows. Anything
 // else — a tag, a branch, a chart version — is returned untouched.
 func shortRevision(revision string) string {
+	if !isCommitSha(revision) {
+		return revision
+	}
+	return revision[:7]
+}
+
+// isCommitSha reports whether revision is a full git SHA rather than a tag, a branch or
+// a chart version.
+func isCommitSha(revision string) bool {
 	const shaLength = 40
 
 	if len(revision) != shaLength {
-		return revision
+		return false
 	}
 	for _, char := range revision {
 		if !strings.ContainsRune("0123456789abcdefABCDEF", char) {
-			return revision
+			return false
 		}
 	}
-	return revision[:7]
+	return true
 }
 
 // isTerminalFailurePhase reports whether the given ArgoCD phase value indicates a terminal failure. The same
@@ -718,6 +824,12 @@ func (app *Application) IsFireAndForgetModeActive() bool {
 	return app.Metadata.Annotations[fireAndForgetAnnotation] == "true"
 }
 
+// IsAutoRollbackEnabled reports whether the app carries "argo-watcher/auto-rollback=true".
+// Only a managed application can be rolled back: the rollback is a write-back too.
+func (app *Application) IsAutoRollbackEnabled() bool {
+	return app.IsManagedByWatcher() && app.Metadata.Annotations[autoRollbackAnnotation] == "true"
+}
+
 // IsImageValidationSkipped reports whether the app carries "argo-watcher/skip-image-validation=true".
 func (app *Application) IsImageValidationSkipped() bool {
 	if app.Metadata.Annotations == nil {
@@ -726,6 +838,62 @@ func (app *Application) IsImageValidationSkipped() bool {
 	return app.Metadata.Annotations[skipImageValidationAnnotation] == "true"
 }
 
+// IsCommitSyncAwaited reports whether a deployment that wrote back waits for ArgoCD to
+// sync its commit before it succeeds, which it does unless the app carries
+// "argo-watcher/wait-for-commit=false".
+func (app *Application) IsCommitSyncAwaited() bool {
+	return app.Metadata.Annotations[waitForCommitAnnotation] != "false"
+}
+
+// IsSyncRequested reports whether argo-watcher triggers the sync this application's rollout
+// waits for. An application with auto-sync never needs one; otherwise the argo-watcher/sync
+// annotation decides, falling back to instanceDefault (ARGO_SYNC_APP) when it is absent.
+func (app *Application) IsSyncRequested(instanceDefault bool) bool {
+	if app.AutoSyncEnabled() {
+		return false
+	}
+	if value, ok := app.Metadata.Annotations[syncAnnotation]; ok {
+		return value == "true"
+	}
+	return instanceDefault
+}
+
+// SyncRequest builds the sync request from the application's sync-prune and sync-revision
+// annotations. Without them ArgoCD syncs the target revision and prunes nothing.
+func (app *Application) SyncRequest() ApplicationSyncRequest {
+	return ApplicationSyncRequest{
+		Revision: app.Metadata.Annotations[syncRevisionAnnotation],
+		Prune:    app.Metadata.Annotations[syncPruneAnnotation] == "true",
+	}
+}
+
+// SyncOperationReport renders the sync operation ArgoCD last ran: its phase and message, then
+// every resource it could not apply.
+func (app *Application) SyncOperationReport() string {
+	report := app.lastSyncOperationLine()
+	if failed := app.listFailedSyncResultResources(); len(failed) > 0 {
+		report += "\n\nFailed resources:\n\t" + strings.Join(failed, "\n\t")
+	}
+	return report
+}
+
+// SyncOperationSummary is the one-line account of a sync argo-watcher triggered, recorded as
+// the reason of a deployment that succeeded through it.
+func (app *Application) SyncOperationSummary() string {
+	operation := app.Status.OperationState
+
+	phase := operation.Phase
+	if phase == "" {
+		phase = "unknown"
+	}
+
+	summary := "Synced by argo-watcher: sync operation " + phase
+	if applied := newRevisions(operation.SyncResult.Revision, operation.SyncResult.Revisions); applied.key != "" {
+		summary += " at " + applied.phrase
+	}
+	return summary + "."
+}
+
 type Userinfo struct {
 	LoggedIn bool   `json:"loggedIn"`
 	Username string `json:"username"`
@@ -198,6 +198,30 @@ func TestShortRevision(t *testing.T) {
 	}
 }
 
+func TestSyncedRevision(t *testing.T) {
+	application := Application{}
+	assert.Empty(t, application.SyncedRevision(), "no sync recorded")
+
+	application.Status.OperationState.SyncResult.Revision = "3f786850e387550fdab836ed7e6dc881de23001b"
+	assert.Equal(t, "3f786850e387550fdab836ed7e6dc881de23001b", application.SyncedRevision())
+
+	application.Status.OperationState.SyncResult.Revisions = []string{"3f78685", "1.2.0"}
+	assert.Equal(t, "3f78685,1.2.0", application.SyncedRevision(), "a multi-source application reports every source")
+}
+
+func TestSyncedCommits(t *testing.T) {
+	const commit = "3f786850e387550fdab836ed7e6dc881de23001b"
+
+	application := Application{}
+	assert.Empty(t, application.SyncedCommits(), "no sync recorded")
+
+	application.Status.OperationState.SyncResult.Revision = commit
+	assert.Equal(t, []string{commit}, application.SyncedCommits())
+
+	application.Status.OperationState.SyncResult.Revisions = []string{"1.2.0", commit}
+	assert.Equal(t, []string{commit}, application.SyncedCommits(), "a chart version names no commit")
+}
+
 func TestAutoSyncEnabled(t *testing.T) {
 	enabled, disabled := true, false
 
@@ -313,6 +337,20 @@ func TestArgoRolloutStatus(t *testing.T) {
 		assert.Equal(t, ArgoRolloutAppNotAvailable, application.GetRolloutStatus(images, registryProxyUrl, false))
 	})
 
+	t.Run("Rollout status - a pinned image is matched on its digest", func(t *testing.T) {
+		application := Application{}
+		application.Status.Sync.Status = "Synced"
+		application.Status.Health.Status = "Healthy"
+		images := []string{"ghcr.io/shini4i/argo-watcher:version1@sha256:aaa"}
+
+		// The tag was pushed again: the application runs it at another digest.
+		application.Status.Summary.Images = []string{"ghcr.io/shini4i/argo-watcher:version1@sha256:bbb"}
+		assert.Equal(t, ArgoRolloutAppNotAvailable, application.GetRolloutStatus(images, "", false))
+
+		application.Status.Summary.Images = []string{"ghcr.io/shini4i/argo-watcher@sha256:aaa"}
+		assert.Equal(t, ArgoRolloutAppSuccess, application.GetRolloutStatus(images, "", false))
+	})
+
 	t.Run("Rollout status - ArgoRolloutAppNotSynced", func(t *testing.T) {
 		application := Application{}
 		application.Status.Summary.Images = []string{"ghcr.io/shini4i/argo-watcher:version1"}
@@ -1399,20 +1437,14 @@ func TestIsManagedByWatcher(t *testing.T) {
 		{
 			name: "No annotations",
 			application: Application{
-				Metadata: struct {
-					Name        string            `json:"name"`
-					Annotations map[string]string `json:"annotations"`
-				}{},
+				Metadata: ApplicationMetadata{},
 			},
 			expected: false,
 		},
 		{
 			name: "Managed by Watcher",
 			application: Application{
-				Metadata: struct {
-					Name        string            `json:"name"`
-					Annotations map[string]string `json:"annotations"`
-				}{
+				Metadata: ApplicationMetadata{
 					Annotations: map[string]string{
 						managedAnnotation: "true",
 					},
@@ -1423,10 +1455,7 @@ func TestIsManagedByWatcher(t *testing.T) {
 		{
 			name: "Not managed by Watcher",
 			application: Application{
-				Metadata: struct {
-					Name        string            `json:"name"`
-					Annotations map[string]string `json:"annotations"`
-				}{
+				Metadata: ApplicationMetadata{
 					Annotations: map[string]string{
 						managedAnnotation: "false",
 					},
@@ -1508,3 +1537,87 @@ func TestIsImageValidationSkipped(t *testing.T) {
 		})
 	}
 }
+
+func TestIsAutoRollbackEnabled(t *testing.T) {
+	tt := []struct {
+		name        string
+		annotations map[string]string
+		want        bool
+	}{
+		{"opted in", map[string]string{managedAnnotation: "true", autoRollbackAnnotation: "true"}, true},
+		{"not managed", map[string]string{autoRollbackAnnotation: "true"}, false},
+		{"not opted in", map[string]string{managedAnnotation: "true"}, false},
+		{"annotations are nil", nil, false},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			app := Application{Metadata: ApplicationMetadata{Annotations: tc.annotations}}
+			assert.Equal(t, tc.want, app.IsAutoRollbackEnabled())
+		})
+	}
+}
+
+func TestIsCommitSyncAwaited(t *testing.T) {
+	tt := []struct {
+		name        string
+		annotations map[string]string
+		want        bool
+	}{
+		{"opted out", map[string]string{waitForCommitAnnotation: "false"}, false},
+		{"explicitly enabled", map[string]string{waitForCommitAnnotation: "true"}, true},
+		{"annotations are nil", nil, true},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			app := Application{Metadata: ApplicationMetadata{Annotations: tc.annotations}}
+			assert.Equal(t, tc.want, app.IsCommitSyncAwaited())
+		})
+	}
+}
+
+func TestIsSyncRequested(t *testing.T) {
+	automated := &ApplicationSyncPolicy{Automated: &ApplicationSyncPolicyAutomated{}}
+
+	tt := []struct {
+		name            string
+		annotations     map[string]string
+		syncPolicy      *ApplicationSyncPolicy
+		instanceDefault bool
+		want            bool
+	}{
+		{"opted in", map[string]string{syncAnnotation: "true"}, nil, false, true},
+		{"opted out despite the instance default", map[string]string{syncAnnotation: "false"}, nil, true, false},
+		{"instance default applies", nil, nil, true, true},
+		{"disabled by default", nil, nil, false, false},
+		{"auto-sync app never synced", map[string]string{syncAnnotation: "true"}, automated, true, false},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			app := Application{Metadata: ApplicationMetadata{Annotations: tc.annotations}}
+			app.Spec.SyncPolicy = tc.syncPolicy
+			assert.Equal(t, tc.want, app.IsSyncRequested(tc.instanceDefault))
+		})
+	}
+}
+
+func TestSyncRequest(t *testing.T) {
+	app := Application{Metadata: ApplicationMetadata{Annotations: map[string]string{
+		syncPruneAnnotation:    "true",
+		syncRevisionAnnotation: "release-1.2",
+	}}}
+	assert.Equal(t, ApplicationSyncRequest{Revision: "release-1.2", Prune: true}, app.SyncRequest())
+
+	assert.Equal(t, ApplicationSyncRequest{}, (&Application{}).SyncRequest())
+}
+
+func TestSyncOperationSummary(t *testing.T) {
+	app := Application{}
+	assert.Equal(t, "Synced by argo-watcher: sync operation unknown.", app.SyncOperationSummary())
+
+	app.Status.OperationState.Phase = "Succeeded"
+	app.Status.OperationState.SyncResult.Revision = "0123456789abcdef0123456789abcdef01234567"
+	assert.Equal(t, "Synced by argo-watcher: sync operation Succeeded at revision 0123456.", app.SyncOperationSummary())
+}
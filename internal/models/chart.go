@@ -0,0 +1,123 @@
+package models
+
+import (
+	"fmt"
+	"path"
+	"strings"
+)
+
+const (
+	// The chart annotations locate, in a GitOps repository, the Application manifest a
+	// chart version task writes its version into: usually the one an app of apps syncs.
+	chartManifestRepoAnnotation   = "argo-watcher/chart.manifest-repo"
+	chartManifestBranchAnnotation = "argo-watcher/chart.manifest-branch"
+	chartManifestFileAnnotation   = "argo-watcher/chart.manifest-file"
+	// chartRevisionPathAnnotation names the YAML path of the version in that file, for a
+	// manifest laid out differently from an Application, such as the values of an app of
+	// apps chart.
+	chartRevisionPathAnnotation = "argo-watcher/chart.revision-path"
+)
+
+// ChartManifest is where a chart version task writes the new version: a YAML file of a
+// GitOps repository, and the path of the value in it.
+type ChartManifest struct {
+	// Repo is the repository and branch holding the file. Its Path is the directory of
+	// the file, and it names no override file.
+	Repo GitopsRepo
+	// File is the name of the file in Repo.Path.
+	File string
+	// RevisionPath is the dot-separated YAML path of the version, such as
+	// "spec.source.targetRevision". A number picks an entry of a list.
+	RevisionPath string
+}
+
+// NewChartManifest reads from the application's chart annotations where the version of
+// its chart is kept. Without argo-watcher/chart.revision-path the file is taken for the
+// application's own manifest, and the path for the targetRevision of its chart source.
+func NewChartManifest(app *Application, repoCachePath string) (ChartManifest, error) {
+	annotations := app.Metadata.Annotations
+	manifest := ChartManifest{
+		Repo: GitopsRepo{
+			RepoUrl:       strings.TrimSpace(annotations[chartManifestRepoAnnotation]),
+			BranchName:    strings.TrimSpace(annotations[chartManifestBranchAnnotation]),
+			RepoCachePath: repoCachePath,
+		},
+		RevisionPath: strings.TrimSpace(annotations[chartRevisionPathAnnotation]),
+	}
+
+	file := path.Clean("/" + strings.TrimSpace(annotations[chartManifestFileAnnotation]))
+	var missing []string
+	if manifest.Repo.RepoUrl == "" {
+		missing = append(missing, chartManifestRepoAnnotation)
+	}
+	if manifest.Repo.BranchName == "" {
+		missing = append(missing, chartManifestBranchAnnotation)
+	}
+	if file == "/" {
+		missing = append(missing, chartManifestFileAnnotation)
+	}
+	if len(missing) > 0 {
+		return manifest, fmt.Errorf("application %q does not say where its chart version is kept: missing annotation(s) %s", app.Metadata.Name, strings.Join(missing, ", "))
+	}
+	// Rooted before it was cleaned, the file cannot lead out of the repository.
+	file = strings.TrimPrefix(file, "/")
+	manifest.Repo.Path, manifest.File = path.Dir(file), path.Base(file)
+
+	index, err := app.ChartSource()
+	switch {
+	case err != nil:
+		return manifest, err
+	case manifest.RevisionPath != "":
+		// Named by the annotation.
+	case index < 0:
+		manifest.RevisionPath = "spec.source.targetRevision"
+	default:
+		manifest.RevisionPath = fmt.Sprintf("spec.sources.%d.targetRevision", index)
+	}
+	return manifest, nil
+}
+
+// ChartSource returns the index among spec.sources of the application's source that
+// reads a Helm chart from a chart repository, or -1 when it is the single source of the
+// application. A multi-source application must have exactly one such source, as
+// nothing would tell which of several a version is for.
+func (app *Application) ChartSource() (int, error) {
+	if len(app.Spec.Sources) == 0 {
+		if app.Spec.Source.Chart == "" {
+			return 0, fmt.Errorf("application %q does not deploy a chart from a chart repository", app.Metadata.Name)
+		}
+		return -1, nil
+	}
+
+	index := -1
+	for current := range app.Spec.Sources {
+		if app.Spec.Sources[current].Chart == "" {
+			continue
+		}
+		if index >= 0 {
+			return 0, fmt.Errorf("application %q has more than one chart source", app.Metadata.Name)
+		}
+		index = current
+	}
+	if index < 0 {
+		return 0, fmt.Errorf("application %q does not deploy a chart from a chart repository", app.Metadata.Name)
+	}
+	return index, nil
+}
+
+// ChartRevision returns the chart version ArgoCD compares the application's chart source
+// against, which is the version it reports as synced once the sync status is Synced.
+// It is empty while ArgoCD has not resolved one.
+func (app *Application) ChartRevision() (string, error) {
+	index, err := app.ChartSource()
+	if err != nil {
+		return "", err
+	}
+	if index < 0 {
+		return app.Status.Sync.Revision, nil
+	}
+	if index >= len(app.Status.Sync.Revisions) {
+		return "", nil
+	}
+	return app.Status.Sync.Revisions[index], nil
+}
@@ -0,0 +1,144 @@
+package models
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func chartApp(annotations map[string]string, sources ...ApplicationSource) *Application {
+	var app Application
+	app.Metadata.Name = "test-app"
+	app.Metadata.Annotations = annotations
+	if len(sources) == 1 {
+		app.Spec.Source = sources[0]
+	} else {
+		app.Spec.Sources = sources
+	}
+	return &app
+}
+
+func chartAnnotations() map[string]string {
+	return map[string]string{
+		chartManifestRepoAnnotation:   "git@example.com:gitops.git",
+		chartManifestBranchAnnotation: "main",
+		chartManifestFileAnnotation:   "apps/test-app.yaml",
+	}
+}
+
+func TestNewChartManifest(t *testing.T) {
+	t.Run("Single source", func(t *testing.T) {
+		manifest, err := NewChartManifest(chartApp(chartAnnotations(), ApplicationSource{Chart: "web"}), "/tmp/cache")
+
+		assert.NoError(t, err)
+		assert.Equal(t, ChartManifest{
+			Repo: GitopsRepo{
+				RepoUrl:       "git@example.com:gitops.git",
+				BranchName:    "main",
+				Path:          "apps",
+				RepoCachePath: "/tmp/cache",
+			},
+			File:         "test-app.yaml",
+			RevisionPath: "spec.source.targetRevision",
+		}, manifest)
+	})
+
+	t.Run("Multi-source", func(t *testing.T) {
+		manifest, err := NewChartManifest(chartApp(chartAnnotations(), ApplicationSource{}, ApplicationSource{Chart: "web"}), "/tmp/cache")
+
+		assert.NoError(t, err)
+		assert.Equal(t, "spec.sources.1.targetRevision", manifest.RevisionPath)
+	})
+
+	t.Run("Revision path from the annotation", func(t *testing.T) {
+		annotations := chartAnnotations()
+		annotations[chartRevisionPathAnnotation] = "apps.web.version"
+
+		manifest, err := NewChartManifest(chartApp(annotations, ApplicationSource{Chart: "web"}), "/tmp/cache")
+
+		assert.NoError(t, err)
+		assert.Equal(t, "apps.web.version", manifest.RevisionPath)
+	})
+
+	t.Run("File cannot leave the repository", func(t *testing.T) {
+		annotations := chartAnnotations()
+		annotations[chartManifestFileAnnotation] = "../../etc/app.yaml"
+
+		manifest, err := NewChartManifest(chartApp(annotations, ApplicationSource{Chart: "web"}), "/tmp/cache")
+
+		assert.NoError(t, err)
+		assert.Equal(t, "etc", manifest.Repo.Path)
+		assert.Equal(t, "app.yaml", manifest.File)
+	})
+
+	t.Run("Missing annotations", func(t *testing.T) {
+		_, err := NewChartManifest(chartApp(map[string]string{chartManifestBranchAnnotation: "main"}, ApplicationSource{Chart: "web"}), "/tmp/cache")
+
+		assert.EqualError(t, err, `application "test-app" does not say where its chart version is kept: missing annotation(s) argo-watcher/chart.manifest-repo, argo-watcher/chart.manifest-file`)
+	})
+
+	t.Run("No chart source", func(t *testing.T) {
+		_, err := NewChartManifest(chartApp(chartAnnotations(), ApplicationSource{}), "/tmp/cache")
+
+		assert.EqualError(t, err, `application "test-app" does not deploy a chart from a chart repository`)
+	})
+}
+
+func TestChartSource(t *testing.T) {
+	testCases := []struct {
+		name    string
+		sources []ApplicationSource
+		index   int
+		err     string
+	}{
+		{"single chart source", []ApplicationSource{{Chart: "web"}}, -1, ""},
+		{"single git source", []ApplicationSource{{}}, 0, `application "test-app" does not deploy a chart from a chart repository`},
+		{"one chart among sources", []ApplicationSource{{}, {Chart: "web"}}, 1, ""},
+		{"no chart among sources", []ApplicationSource{{}, {}}, 0, `application "test-app" does not deploy a chart from a chart repository`},
+		{"several charts", []ApplicationSource{{Chart: "web"}, {Chart: "worker"}}, 0, `application "test-app" has more than one chart source`},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			index, err := chartApp(nil, tc.sources...).ChartSource()
+
+			if tc.err != "" {
+				assert.EqualError(t, err, tc.err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tc.index, index)
+		})
+	}
+}
+
+func TestChartRevision(t *testing.T) {
+	t.Run("Single source", func(t *testing.T) {
+		app := chartApp(nil, ApplicationSource{Chart: "web"})
+		app.Status.Sync.Revision = "1.4.0"
+
+		revision, err := app.ChartRevision()
+
+		assert.NoError(t, err)
+		assert.Equal(t, "1.4.0", revision)
+	})
+
+	t.Run("Multi-source", func(t *testing.T) {
+		app := chartApp(nil, ApplicationSource{}, ApplicationSource{Chart: "web"})
+		app.Status.Sync.Revisions = []string{"0a1b2c3", "1.4.0"}
+
+		revision, err := app.ChartRevision()
+
+		assert.NoError(t, err)
+		assert.Equal(t, "1.4.0", revision)
+	})
+
+	t.Run("Multi-source not resolved yet", func(t *testing.T) {
+		app := chartApp(nil, ApplicationSource{}, ApplicationSource{Chart: "web"})
+
+		revision, err := app.ChartRevision()
+
+		assert.NoError(t, err)
+		assert.Empty(t, revision)
+	})
+}
@@ -15,6 +15,19 @@ const (
 	// watcher stops polling ArgoCD for the superseded task to avoid wasting API
 	// calls on a rollout nobody is waiting for anymore.
 	StatusCancelledMessage = "cancelled"
+	// StatusPausedMessage marks a deployment whose Argo Rollouts paused on their
+	// way to promotion, for an application that counts that as the end of its
+	// deployment. Like "deployed" it is a success: the new version serves the
+	// traffic its rollout plan gives it at that step.
+	StatusPausedMessage = "paused"
+	// StatusQueuedMessage marks a task of a group deployment that waits for one of
+	// the group's rollout slots (see Task.MaxParallel). It is not monitored yet, and
+	// moves to "in progress" once an earlier task of the group finishes.
+	StatusQueuedMessage = "queued"
+	// StatusAwaitingMergeMessage marks a deployment whose write-back was proposed
+	// through a pull request rather than pushed. Its rollout is not monitored until
+	// the pull request is merged, when it moves back to "in progress".
+	StatusAwaitingMergeMessage = "awaiting merge"
 )
 
 // allowedTaskStatusFilters lists every status string the /api/v1/tasks
@@ -35,6 +48,9 @@ var allowedTaskStatusFilters = map[string]struct{}{
 	StatusDeployedMessage:          {},
 	StatusAccepted:                 {},
 	StatusCancelledMessage:         {},
+	StatusPausedMessage:            {},
+	StatusQueuedMessage:            {},
+	StatusAwaitingMergeMessage:     {},
 }
 
 // IsAllowedTaskStatus reports whether the given status string is accepted
@@ -11,6 +11,7 @@ func TestIsAllowedTaskStatus(t *testing.T) {
 		{"cancelled is allowed", StatusCancelledMessage, true},
 		{"in progress is allowed", StatusInProgressMessage, true},
 		{"deployed is allowed", StatusDeployedMessage, true},
+		{"awaiting merge is allowed", StatusAwaitingMergeMessage, true},
 		{"unknown is rejected", "totally-bogus", false},
 		{"empty is rejected", "", false},
 	}
@@ -0,0 +1,70 @@
+package models
+
+import (
+	"strings"
+	"time"
+)
+
+// KubernetesEventList is the response of ArgoCD's /api/v1/applications/{name}/events
+// endpoint: the Kubernetes events of one resource of the application.
+type KubernetesEventList struct {
+	Items []KubernetesEvent `json:"items"`
+}
+
+// KubernetesEvent is a Kubernetes core/v1 Event. Only the fields a failure reason reports
+// are decoded.
+type KubernetesEvent struct {
+	// Type is "Normal" or "Warning".
+	Type    string `json:"type"`
+	Reason  string `json:"reason"`
+	Message string `json:"message"`
+	// Count is how many times the event was recorded; zero for an event recorded through
+	// the events.k8s.io API, which counts in a series instead.
+	Count int `json:"count"`
+	// LastTimestamp is unset for an event recorded through the events.k8s.io API, which
+	// sets EventTime instead.
+	LastTimestamp time.Time `json:"lastTimestamp"`
+	EventTime     time.Time `json:"eventTime"`
+}
+
+// OccurredAt is when the event was last recorded.
+func (event KubernetesEvent) OccurredAt() time.Time {
+	if event.LastTimestamp.IsZero() {
+		return event.EventTime
+	}
+	return event.LastTimestamp
+}
+
+// PodLogEntry is one line of the newline-delimited JSON stream returned by ArgoCD's
+// /api/v1/applications/{name}/logs endpoint. The stream ends with an entry whose Last
+// flag is set.
+type PodLogEntry struct {
+	Result struct {
+		Content string `json:"content"`
+		Last    bool   `json:"last"`
+	} `json:"result"`
+	Error *struct {
+		Message string `json:"message"`
+	} `json:"error,omitempty"`
+}
+
+// ListProblemPods returns the Pod nodes whose health indicates a problem — the pods whose
+// events and logs explain a failed rollout — in tree order.
+func (tree *ApplicationTree) ListProblemPods() []ApplicationTreeNode {
+	var pods []ApplicationTreeNode
+	for index := range tree.Nodes {
+		node := tree.Nodes[index]
+		if node.Kind != "Pod" || node.Group != "" || !isProblemHealthStatus(node.Health.Status) {
+			continue
+		}
+		pods = append(pods, node)
+	}
+	return pods
+}
+
+// IsCrashLooping reports whether the node is a Pod restarting a failed container, whose
+// useful logs belong to the previous, terminated container instance.
+func (node ApplicationTreeNode) IsCrashLooping() bool {
+	return strings.Contains(node.Health.Message, "CrashLoopBackOff") ||
+		strings.Contains(node.Health.Message, "restarting failed container")
+}
@@ -0,0 +1,47 @@
+package models
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func podNode(group, kind, name, health, message string) ApplicationTreeNode {
+	node := ApplicationTreeNode{Group: group, Kind: kind, Name: name}
+	node.Health.Status = health
+	node.Health.Message = message
+	return node
+}
+
+func TestListProblemPods(t *testing.T) {
+	tree := &ApplicationTree{Nodes: []ApplicationTreeNode{
+		podNode("apps", "Deployment", "demo", "Degraded", ""),
+		podNode("", "Pod", "demo-ok", "Healthy", ""),
+		podNode("", "Pod", "demo-crash", "Degraded", "CrashLoopBackOff"),
+		podNode("", "Pod", "demo-pending", "Progressing", ""),
+		podNode("example.com", "Pod", "custom", "Degraded", ""),
+	}}
+
+	pods := tree.ListProblemPods()
+
+	var names []string
+	for _, pod := range pods {
+		names = append(names, pod.Name)
+	}
+	assert.Equal(t, []string{"demo-crash"}, names)
+}
+
+func TestApplicationTreeNodeIsCrashLooping(t *testing.T) {
+	assert.True(t, podNode("", "Pod", "a", "Degraded", "back-off 5m0s restarting failed container=app pod=a").IsCrashLooping())
+	assert.True(t, podNode("", "Pod", "a", "Degraded", "CrashLoopBackOff").IsCrashLooping())
+	assert.False(t, podNode("", "Pod", "a", "Degraded", `Back-off pulling image "demo:v2"`).IsCrashLooping())
+}
+
+func TestKubernetesEventOccurredAt(t *testing.T) {
+	last := time.Date(2026, 1, 1, 10, 0, 0, 0, time.UTC)
+	series := time.Date(2026, 1, 1, 11, 0, 0, 0, time.UTC)
+
+	assert.Equal(t, last, KubernetesEvent{LastTimestamp: last, EventTime: series}.OccurredAt())
+	assert.Equal(t, series, KubernetesEvent{EventTime: series}.OccurredAt())
+}
@@ -0,0 +1,20 @@
+package models
+
+// WriteBackPreview is the git change a deployment would write back, returned for a
+// dry-run submission instead of a task.
+type WriteBackPreview struct {
+	App string `json:"app" example:"argo-watcher"`
+	// Managed reports whether the application has write-back enabled. A dry run also
+	// previews an application that does not yet, so the change can be checked before
+	// argo-watcher/managed is set.
+	Managed bool   `json:"managed"`
+	Repo    string `json:"repo" example:"git@github.com:example/gitops.git"`
+	Branch  string `json:"branch" example:"main"`
+	// Paths are the files the write-back writes: the override file, or the values files
+	// the tags are set in.
+	Paths []string `json:"paths" example:"charts/demo/.argocd-source-argo-watcher.yaml"`
+	// CommitMessage and Diff are empty when the files already hold the new tags, in
+	// which case the write-back would commit nothing.
+	CommitMessage string `json:"commit_message,omitempty"`
+	Diff          string `json:"diff,omitempty"`
+}
@@ -0,0 +1,196 @@
+package models
+
+import (
+	"fmt"
+	"strings"
+)
+
+const (
+	// RolloutGroup and RolloutKind identify an Argo Rollouts Rollout in an application's
+	// resource tree.
+	RolloutGroup = "argoproj.io"
+	RolloutKind  = "Rollout"
+
+	// rolloutPauseSuccessAnnotation lets an application finish its deployment as "paused" once
+	// every Rollout it owns is paused waiting for promotion, instead of waiting for the
+	// promotion itself.
+	rolloutPauseSuccessAnnotation = "argo-watcher/rollout-pause-success"
+
+	// Pause reasons Argo Rollouts records while a Rollout waits to be promoted. Any other
+	// reason (an inconclusive analysis, for one) is a pause that needs a decision, not a
+	// step of the plan.
+	rolloutCanaryPauseStep = "CanaryPauseStep"
+	rolloutBlueGreenPause  = "BlueGreenPause"
+)
+
+// RolloutNodes returns the Argo Rollouts Rollout resources of the tree.
+func (tree *ApplicationTree) RolloutNodes() []ApplicationTreeNode {
+	if tree == nil {
+		return nil
+	}
+
+	var rollouts []ApplicationTreeNode
+	for _, node := range tree.Nodes {
+		if node.Group == RolloutGroup && node.Kind == RolloutKind {
+			rollouts = append(rollouts, node)
+		}
+	}
+	return rollouts
+}
+
+// IsRolloutPauseSuccess reports whether the application asked for a Rollout paused on its
+// way to promotion to count as the end of the deployment.
+func (app *Application) IsRolloutPauseSuccess() bool {
+	return app.Metadata.Annotations[rolloutPauseSuccessAnnotation] == "true"
+}
+
+// LiveResource is the response of ArgoCD's /api/v1/applications/{name}/resource endpoint:
+// the live manifest of one resource, JSON-serialized.
+type LiveResource struct {
+	Manifest string `json:"manifest"`
+}
+
+// RolloutStep is one step of a canary strategy. Only the fields that describe the traffic
+// split are decoded: a step is either a weight change, a pause, or something else entirely
+// (an analysis, an experiment), which leaves both unset.
+type RolloutStep struct {
+	SetWeight *int32 `json:"setWeight,omitempty"`
+	Pause     *struct {
+		Duration any `json:"duration,omitempty"`
+	} `json:"pause,omitempty"`
+}
+
+// Rollout is the part of an Argo Rollouts Rollout that tells how far a canary or blue-green
+// update has come.
+type Rollout struct {
+	Metadata struct {
+		Name string `json:"name"`
+	} `json:"metadata"`
+	Spec struct {
+		Strategy struct {
+			Canary *struct {
+				Steps []RolloutStep `json:"steps"`
+			} `json:"canary,omitempty"`
+			BlueGreen *struct {
+				ActiveService  string `json:"activeService"`
+				PreviewService string `json:"previewService"`
+			} `json:"blueGreen,omitempty"`
+		} `json:"strategy"`
+	} `json:"spec"`
+	Status struct {
+		Phase            string `json:"phase"`
+		Message          string `json:"message"`
+		CurrentStepIndex *int32 `json:"currentStepIndex,omitempty"`
+		PauseConditions  []struct {
+			Reason string `json:"reason"`
+		} `json:"pauseConditions"`
+		Canary struct {
+			Weights *struct {
+				Canary struct {
+					Weight int32 `json:"weight"`
+				} `json:"canary"`
+			} `json:"weights,omitempty"`
+		} `json:"canary"`
+		BlueGreen struct {
+			ActiveSelector  string `json:"activeSelector"`
+			PreviewSelector string `json:"previewSelector"`
+		} `json:"blueGreen"`
+	} `json:"status"`
+}
+
+// IsCanary reports whether the Rollout uses the canary strategy; otherwise it is blue-green.
+func (rollout *Rollout) IsCanary() bool {
+	return rollout.Spec.Strategy.Canary != nil
+}
+
+// StepCount is the number of steps of a canary strategy.
+func (rollout *Rollout) StepCount() int {
+	if !rollout.IsCanary() {
+		return 0
+	}
+	return len(rollout.Spec.Strategy.Canary.Steps)
+}
+
+// CurrentStep is the 1-based number of the canary step the Rollout is on. A Rollout past
+// its last step, or one without steps, reports StepCount.
+func (rollout *Rollout) CurrentStep() int {
+	index := rollout.Status.CurrentStepIndex
+	if index == nil {
+		return rollout.StepCount()
+	}
+	return min(int(*index)+1, rollout.StepCount())
+}
+
+// CanaryWeight is the share of traffic, in percent, the canary receives. Argo Rollouts
+// reports it directly when it manages traffic through a mesh or an ingress; without
+// traffic routing the split is approximated by replica counts, so the weight of the last
+// setWeight step reached is reported instead.
+func (rollout *Rollout) CanaryWeight() int32 {
+	if weights := rollout.Status.Canary.Weights; weights != nil {
+		return weights.Canary.Weight
+	}
+
+	if !rollout.IsCanary() || rollout.Status.CurrentStepIndex == nil {
+		return 0
+	}
+
+	steps := rollout.Spec.Strategy.Canary.Steps
+	for index := min(int(*rollout.Status.CurrentStepIndex), len(steps)) - 1; index >= 0; index-- {
+		if weight := steps[index].SetWeight; weight != nil {
+			return *weight
+		}
+	}
+	return 0
+}
+
+// IsPausedForPromotion reports whether the Rollout is paused by a step of its own plan: a
+// canary pause step, or a blue-green preview waiting to be promoted.
+func (rollout *Rollout) IsPausedForPromotion() bool {
+	if rollout.Status.Phase != "Paused" {
+		return false
+	}
+
+	for _, condition := range rollout.Status.PauseConditions {
+		if condition.Reason == rolloutCanaryPauseStep || condition.Reason == rolloutBlueGreenPause {
+			return true
+		}
+	}
+	return false
+}
+
+// Position identifies how far the update has come, for telling whether it advanced between
+// two polls: the step and traffic weight of a canary, the promoted and preview versions of
+// a blue-green Rollout, and the phase of either.
+func (rollout *Rollout) Position() string {
+	if rollout.IsCanary() {
+		return fmt.Sprintf("%s:%d:%d:%s", rollout.Metadata.Name, rollout.CurrentStep(), rollout.CanaryWeight(), rollout.Status.Phase)
+	}
+	blueGreen := rollout.Status.BlueGreen
+	return fmt.Sprintf("%s:%s:%s:%s", rollout.Metadata.Name, blueGreen.ActiveSelector, blueGreen.PreviewSelector, rollout.Status.Phase)
+}
+
+// Progress renders the Rollout's state as one line of a task's progress report, e.g.
+// `Rollout "web": canary step 3 of 5, 40% of traffic, paused`.
+func (rollout *Rollout) Progress() string {
+	var parts []string
+	if rollout.IsCanary() {
+		parts = append(parts,
+			fmt.Sprintf("canary step %d of %d", rollout.CurrentStep(), rollout.StepCount()),
+			fmt.Sprintf("%d%% of traffic", rollout.CanaryWeight()),
+		)
+	} else if rollout.IsPausedForPromotion() {
+		parts = append(parts, "blue-green, preview waiting for promotion")
+	} else {
+		parts = append(parts, "blue-green")
+	}
+
+	if phase := rollout.Status.Phase; phase != "" {
+		parts = append(parts, strings.ToLower(phase))
+	}
+
+	line := fmt.Sprintf("Rollout %q: %s", rollout.Metadata.Name, strings.Join(parts, ", "))
+	if message := rollout.Status.Message; message != "" {
+		line += fmt.Sprintf(" (%s)", message)
+	}
+	return line
+}
@@ -0,0 +1,117 @@
+package models
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func parseRollout(t *testing.T, manifest string) *Rollout {
+	t.Helper()
+	var rollout Rollout
+	require.NoError(t, json.Unmarshal([]byte(manifest), &rollout))
+	return &rollout
+}
+
+const canarySpec = `"spec":{"strategy":{"canary":{"steps":[{"setWeight":20},{"pause":{}},{"setWeight":50},{"pause":{"duration":"10m"}},{"analysis":{}}]}}}`
+
+func TestRolloutNodes(t *testing.T) {
+	tree := &ApplicationTree{Nodes: []ApplicationTreeNode{
+		{Group: "apps", Kind: "Deployment", Name: "api"},
+		{Group: "argoproj.io", Kind: "Rollout", Name: "web"},
+		{Group: "argoproj.io", Kind: "AnalysisRun", Name: "web-analysis"},
+	}}
+
+	nodes := tree.RolloutNodes()
+	require.Len(t, nodes, 1)
+	assert.Equal(t, "web", nodes[0].Name)
+
+	assert.Nil(t, (*ApplicationTree)(nil).RolloutNodes())
+}
+
+func TestRolloutCanaryProgress(t *testing.T) {
+	tt := []struct {
+		name     string
+		manifest string
+		step     int
+		weight   int32
+		progress string
+	}{
+		{
+			name:     "paused on a step with the weight approximated from the plan",
+			manifest: `{"metadata":{"name":"web"},` + canarySpec + `,"status":{"phase":"Paused","currentStepIndex":1,"pauseConditions":[{"reason":"CanaryPauseStep"}]}}`,
+			step:     2,
+			weight:   20,
+			progress: `Rollout "web": canary step 2 of 5, 20% of traffic, paused`,
+		},
+		{
+			name:     "weight reported by traffic routing",
+			manifest: `{"metadata":{"name":"web"},` + canarySpec + `,"status":{"phase":"Progressing","message":"more replicas need to be updated","currentStepIndex":2,"canary":{"weights":{"canary":{"weight":45}}}}}`,
+			step:     3,
+			weight:   45,
+			progress: `Rollout "web": canary step 3 of 5, 45% of traffic, progressing (more replicas need to be updated)`,
+		},
+		{
+			name:     "past the last step",
+			manifest: `{"metadata":{"name":"web"},` + canarySpec + `,"status":{"phase":"Healthy","currentStepIndex":5}}`,
+			step:     5,
+			weight:   50,
+			progress: `Rollout "web": canary step 5 of 5, 50% of traffic, healthy`,
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			rollout := parseRollout(t, tc.manifest)
+			assert.True(t, rollout.IsCanary())
+			assert.Equal(t, tc.step, rollout.CurrentStep())
+			assert.Equal(t, tc.weight, rollout.CanaryWeight())
+			assert.Equal(t, tc.progress, rollout.Progress())
+		})
+	}
+}
+
+func TestRolloutBlueGreenProgress(t *testing.T) {
+	rollout := parseRollout(t, `{"metadata":{"name":"web"},"spec":{"strategy":{"blueGreen":{"activeService":"web","previewService":"web-preview"}}},`+
+		`"status":{"phase":"Paused","pauseConditions":[{"reason":"BlueGreenPause"}],"blueGreen":{"activeSelector":"6b7c","previewSelector":"9f8e"}}}`)
+
+	assert.False(t, rollout.IsCanary())
+	assert.True(t, rollout.IsPausedForPromotion())
+	assert.Equal(t, `Rollout "web": blue-green, preview waiting for promotion, paused`, rollout.Progress())
+	assert.Equal(t, "web:6b7c:9f8e:Paused", rollout.Position())
+}
+
+func TestRolloutIsPausedForPromotion(t *testing.T) {
+	tt := []struct {
+		name   string
+		status string
+		want   bool
+	}{
+		{"canary pause step", `{"phase":"Paused","pauseConditions":[{"reason":"CanaryPauseStep"}]}`, true},
+		{"inconclusive analysis", `{"phase":"Paused","pauseConditions":[{"reason":"InconclusiveAnalysis"}]}`, false},
+		{"progressing", `{"phase":"Progressing"}`, false},
+		{"paused without a condition", `{"phase":"Paused"}`, false},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			rollout := parseRollout(t, `{`+canarySpec+`,"status":`+tc.status+`}`)
+			assert.Equal(t, tc.want, rollout.IsPausedForPromotion())
+		})
+	}
+}
+
+func TestRolloutPositionChangesWithTheStep(t *testing.T) {
+	first := parseRollout(t, `{"metadata":{"name":"web"},`+canarySpec+`,"status":{"phase":"Paused","currentStepIndex":1}}`)
+	second := parseRollout(t, `{"metadata":{"name":"web"},`+canarySpec+`,"status":{"phase":"Progressing","currentStepIndex":2}}`)
+
+	assert.NotEqual(t, first.Position(), second.Position())
+}
+
+func TestIsRolloutPauseSuccess(t *testing.T) {
+	app := Application{Metadata: ApplicationMetadata{Annotations: map[string]string{rolloutPauseSuccessAnnotation: "true"}}}
+	assert.True(t, app.IsRolloutPauseSuccess())
+	assert.False(t, (&Application{}).IsRolloutPauseSuccess())
+}
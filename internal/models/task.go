@@ -8,6 +8,32 @@ import (
 type Image struct {
 	Image string `json:"image" example:"ghcr.io/shini4i/argo-watcher"`
 	Tag   string `json:"tag" example:"dev"`
+	// Digest is the digest of the manifest Tag pointed to when the task was submitted,
+	// resolved by the server with PIN_IMAGE_DIGESTS on. It is set by the server only.
+	Digest string `json:"digest,omitempty" example:"sha256:6c3c624b58dbbcd3c0dd82b4c53f04194d1247c6eebdaab7c610cf7d66709b3b"`
+	// Signer names the keys of COSIGN_POLICY_PATH whose cosign signatures of the image
+	// were verified when the task was submitted. It is set by the server only.
+	Signer string `json:"signer,omitempty" example:"release"`
+}
+
+// PinnedTag returns the tag written back for the image: the tag, followed by the digest
+// it resolved to when there is one ("v1.2.3@sha256:...").
+func (image Image) PinnedTag() string {
+	if image.Digest == "" {
+		return image.Tag
+	}
+	return image.Tag + "@" + image.Digest
+}
+
+// Parameter is a Helm parameter a task writes into the override file next to its image
+// tags, such as a feature flag released together with them. Only the parameters the
+// application allows in its argo-watcher/allowed-parameters annotation are written.
+type Parameter struct {
+	Name  string `json:"name" binding:"required" example:"features.checkout"`
+	Value string `json:"value" example:"true"`
+	// ForceString writes the value as a string, as image tags are, rather than letting
+	// Helm read "true" or "2" as a boolean or a number.
+	ForceString bool `json:"force_string,omitempty" example:"false"`
 }
 
 type SavedAppStatus struct {
@@ -19,10 +45,10 @@ type Task struct {
 	Id           string  `json:"id,omitempty"`
 	Created      float64 `json:"created,omitempty"`
 	Updated      float64 `json:"updated,omitempty"`
-	App          string  `json:"app" binding:"required" example:"argo-watcher"`
+	App          string  `json:"app" binding:"required_without_all=Selector ApplicationSet" example:"argo-watcher"`
 	Author       string  `json:"author" binding:"required" example:"John Doe"`
 	Project      string  `json:"project" binding:"required" example:"Demo"`
-	Images       []Image `json:"images" binding:"required"`
+	Images       []Image `json:"images" binding:"required_without=ChartVersion"`
 	Status       string  `json:"status,omitempty"`
 	StatusReason string  `json:"status_reason,omitempty"`
 	// Validated records whether the request that created this task presented a valid
@@ -35,10 +61,117 @@ type Task struct {
 	// A nil pointer (field omitted) keeps the instance default, so old clients are unaffected;
 	// an explicit true/false forces a refresh on or off for this deployment (issue #334).
 	Refresh *bool `json:"refresh,omitempty" example:"false"`
+	// Parameters are written back next to the image tags. Empty for a task that only
+	// deploys images.
+	Parameters []Parameter `json:"parameters,omitempty" binding:"omitempty,dive"`
+	// ChartVersion makes the task a chart version bump rather than an image deployment:
+	// the write-back sets the version of the application's Helm chart in its Application
+	// manifest, and the rollout waits for ArgoCD to sync that version. Such a task
+	// carries no images.
+	ChartVersion string `json:"chart_version,omitempty" example:"1.4.0"`
 	// RollbackTargetId is the ID of the most recent earlier task whose image set
 	// this deployment returns to. Empty when the deployment is not a rollback.
-	RollbackTargetId string         `json:"rollback_target_id,omitempty"`
-	SavedAppStatus   SavedAppStatus `json:"-"`
+	RollbackTargetId string `json:"rollback_target_id,omitempty"`
+	// RollbackOfId is the ID of the failed task an automatic rollback reverts. Empty
+	// for a deployment that was submitted rather than started by argo-watcher.
+	RollbackOfId string `json:"rollback_of_id,omitempty"`
+	// Selector and ApplicationSet address a group deployment instead of a single App:
+	// every application matching the ArgoCD label selector, or generated by the named
+	// ApplicationSet, gets a task of its own. They are only read on submission; the
+	// tasks created from them name their application in App.
+	Selector       string `json:"selector,omitempty" example:"team=payments"`
+	ApplicationSet string `json:"application_set,omitempty" example:"payments"`
+	// MaxParallel bounds how many applications of a group deployment roll out at once;
+	// the rest wait as "queued". Zero rolls them all out together.
+	MaxParallel int `json:"max_parallel,omitempty" example:"2"`
+	// DryRun asks for the git change the deployment would write back instead of the
+	// deployment itself. Like the group target, it is only read on submission.
+	DryRun bool `json:"dry_run,omitempty" example:"false"`
+	// Rollback marks the deployment as a deliberate return to an earlier version, which
+	// lets it through the downgrade protection. It is only read on submission.
+	Rollback bool `json:"rollback,omitempty" example:"false"`
+	// Privileged records whether the request that created this task came from a member
+	// of OIDC_PRIVILEGED_GROUPS, who may downgrade without marking a rollback. Like
+	// Validated, it is never accepted from the API.
+	Privileged bool `json:"-"`
+	// GroupId links the tasks created for one group deployment. Empty for a task that
+	// was submitted for a single application.
+	GroupId string `json:"group_id,omitempty"`
+	// WriteBack describes the commit the task's git write-back made. It is nil when
+	// there is nothing to report about it.
+	WriteBack *WriteBack `json:"write_back,omitempty"`
+	// PullRequest is the pull request the write-back was proposed in, set once the task
+	// awaits its merge. Nil for a write-back pushed to its branch.
+	PullRequest    *PullRequest   `json:"pull_request,omitempty"`
+	SavedAppStatus SavedAppStatus `json:"-"`
+}
+
+// WriteBack describes the commit a task's git write-back made, and the revision
+// ArgoCD synced the application to afterwards.
+type WriteBack struct {
+	// CommitSha is the hash of the commit pushed for the task. With the pull-request
+	// method it is the commit the pull request was merged as, once the provider
+	// reports it.
+	CommitSha string `json:"commit_sha,omitempty" example:"3f786850e387550fdab836ed7e6dc881de23001b"`
+	// SigningKey names the key the commit was signed with: the signing format and the
+	// key's fingerprint, such as "ssh:SHA256:..." or "openpgp:6E3A...". Empty when the
+	// commit is not signed.
+	SigningKey string `json:"signing_key,omitempty" example:"ssh:SHA256:uNiVztksCsDhcc0u9e8BujQXVUpKZIDTMczCvj3tD2s"`
+	// SyncedRevision is the revision ArgoCD's last sync of the application applied
+	// when its rollout ended, one per source separated by commas for a multi-source
+	// application.
+	SyncedRevision string `json:"synced_revision,omitempty" example:"3f786850e387550fdab836ed7e6dc881de23001b"`
+}
+
+// PullRequest is a pull request a write-back was proposed in. It is stored with the
+// task so a replica that takes the task over while it awaits the merge follows the
+// same pull request, against the same deadline, instead of proposing it again.
+type PullRequest struct {
+	URL    string `json:"url" example:"https://github.com/example/gitops/pull/42"`
+	Number int    `json:"number" example:"42"`
+	// RepoURL is the repository the pull request was opened in, as cloned, and Branch
+	// the branch it merges into Base.
+	RepoURL string `json:"repo_url"`
+	Branch  string `json:"branch"`
+	Base    string `json:"base"`
+	// Since is when the task began awaiting the merge, in Unix seconds. The merge
+	// timeout runs from it.
+	Since int64 `json:"since"`
+}
+
+// Merge copies the fields of other that are set over those of writeBack. What is
+// known of a write-back is learned in steps — the commit when it is pushed, the
+// synced revision when the rollout ends — and a later step leaves the others be.
+func (writeBack *WriteBack) Merge(other WriteBack) {
+	if other.CommitSha != "" {
+		writeBack.CommitSha = other.CommitSha
+	}
+	if other.SigningKey != "" {
+		writeBack.SigningKey = other.SigningKey
+	}
+	if other.SyncedRevision != "" {
+		writeBack.SyncedRevision = other.SyncedRevision
+	}
+}
+
+// ShortCommit abbreviates CommitSha to the seven characters ArgoCD's UI shows.
+func (writeBack *WriteBack) ShortCommit() string {
+	return shortRevision(writeBack.CommitSha)
+}
+
+// WriteBackSubject tells what the task's write-back updates, as the default commit
+// message and pull request title name it.
+func (task *Task) WriteBackSubject() string {
+	if task.ChartVersion != "" {
+		return "update chart version to " + task.ChartVersion
+	}
+	return "update image tag"
+}
+
+// IsGroupTarget reports whether the task addresses a group of applications rather
+// than the one named in App.
+func (task *Task) IsGroupTarget() bool {
+	return task.Selector != "" || task.ApplicationSet != ""
 }
 
 // UnknownApp is the app label used for a task whose name must not reach a metric.
@@ -55,11 +188,12 @@ func (task *Task) MetricApp() string {
 	return task.App
 }
 
-// ListImages returns the task's images formatted as "{image}:{tag}".
+// ListImages returns the task's images formatted as "{image}:{tag}", or
+// "{image}:{tag}@{digest}" for an image pinned to a digest.
 func (task *Task) ListImages() []string {
 	list := make([]string, len(task.Images))
 	for index := range task.Images {
-		list[index] = fmt.Sprintf("%s:%s", task.Images[index].Image, task.Images[index].Tag)
+		list[index] = fmt.Sprintf("%s:%s", task.Images[index].Image, task.Images[index].PinnedTag())
 	}
 	return list
 }
@@ -99,6 +233,16 @@ type TaskStatus struct {
 	Status       string  `json:"status,omitempty"`
 	StatusReason string  `json:"status_reason,omitempty"`
 	Error        string  `json:"error,omitempty"`
+	// The fields below are only set on the status of one task, which the task view
+	// reads; see Task for what they hold.
+	Parameters       []Parameter `json:"parameters,omitempty"`
+	ChartVersion     string      `json:"chart_version,omitempty"`
+	IsRollback       bool        `json:"is_rollback,omitempty"`
+	RollbackTargetId string      `json:"rollback_target_id,omitempty"`
+	RollbackOfId     string      `json:"rollback_of_id,omitempty"`
+	WriteBack        *WriteBack  `json:"write_back,omitempty"`
+	// Violations lists every admission rule a rejected task broke.
+	Violations []string `json:"violations,omitempty"`
 }
 
 type ArgoApiErrorResponse struct {
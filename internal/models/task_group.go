@@ -0,0 +1,40 @@
+package models
+
+// TaskGroup is a deployment submitted for every application matched by a label selector
+// or generated by an ApplicationSet. Each application is deployed by a task of its own;
+// Status sums up their outcome (see GroupStatus).
+type TaskGroup struct {
+	Id     string `json:"id"`
+	Status string `json:"status"`
+	Tasks  []Task `json:"tasks"`
+	Error  string `json:"error,omitempty"`
+}
+
+// GroupStatus is the status of a group deployment given the tasks it is made of:
+//   - "in progress" while any task is still queued, awaiting a merge or rolling out;
+//   - "failed" once they all finished and any of them did not succeed;
+//   - "cancelled" when none failed but a newer deployment superseded some of them;
+//   - "deployed" when every application was deployed (or paused on its way to promotion).
+func GroupStatus(tasks []Task) string {
+	failed, cancelled := false, false
+	for _, task := range tasks {
+		switch task.Status {
+		case StatusInProgressMessage, StatusQueuedMessage, StatusAwaitingMergeMessage:
+			return StatusInProgressMessage
+		case StatusDeployedMessage, StatusPausedMessage:
+		case StatusCancelledMessage:
+			cancelled = true
+		default:
+			failed = true
+		}
+	}
+
+	switch {
+	case failed:
+		return StatusFailedMessage
+	case cancelled:
+		return StatusCancelledMessage
+	default:
+		return StatusDeployedMessage
+	}
+}
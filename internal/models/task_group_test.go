@@ -0,0 +1,36 @@
+package models
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGroupStatus(t *testing.T) {
+	tasks := func(statuses ...string) []Task {
+		list := make([]Task, len(statuses))
+		for index, status := range statuses {
+			list[index].Status = status
+		}
+		return list
+	}
+
+	tests := []struct {
+		name     string
+		tasks    []Task
+		expected string
+	}{
+		{"running while any task is in progress", tasks(StatusDeployedMessage, StatusInProgressMessage, StatusFailedMessage), StatusInProgressMessage},
+		{"running while any task is queued", tasks(StatusDeployedMessage, StatusQueuedMessage), StatusInProgressMessage},
+		{"failed once finished with a failure", tasks(StatusDeployedMessage, StatusFailedMessage, StatusCancelledMessage), StatusFailedMessage},
+		{"an aborted task fails the group", tasks(StatusDeployedMessage, StatusAborted), StatusFailedMessage},
+		{"cancelled when superseded without failures", tasks(StatusDeployedMessage, StatusCancelledMessage), StatusCancelledMessage},
+		{"deployed when every task succeeded", tasks(StatusDeployedMessage, StatusPausedMessage), StatusDeployedMessage},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			assert.Equal(t, test.expected, GroupStatus(test.tasks))
+		})
+	}
+}
@@ -76,6 +76,22 @@ func TestTask_RefreshUnmarshal(t *testing.T) {
 	}
 }
 
+func TestWriteBack_Merge(t *testing.T) {
+	writeBack := WriteBack{CommitSha: "3f78685", SigningKey: "ssh:SHA256:abc"}
+
+	writeBack.Merge(WriteBack{SyncedRevision: "3f78685"})
+	assert.Equal(t, WriteBack{CommitSha: "3f78685", SigningKey: "ssh:SHA256:abc", SyncedRevision: "3f78685"}, writeBack)
+
+	writeBack.Merge(WriteBack{CommitSha: "1b2c3d4"})
+	assert.Equal(t, "1b2c3d4", writeBack.CommitSha)
+	assert.Equal(t, "ssh:SHA256:abc", writeBack.SigningKey, "an empty field keeps what was known")
+}
+
+func TestWriteBack_ShortCommit(t *testing.T) {
+	writeBack := WriteBack{CommitSha: "3f786850e387550fdab836ed7e6dc881de23001b"}
+	assert.Equal(t, "3f78685", writeBack.ShortCommit())
+}
+
 func TestTask_ListImages(t *testing.T) {
 	task := Task{
 		Images: []Image{
@@ -96,6 +112,14 @@ func TestTask_ListImages(t *testing.T) {
 	assert.Equal(t, expected, result, "List of images does not match")
 }
 
+func TestTask_ListImages_Pinned(t *testing.T) {
+	task := Task{
+		Images: []Image{{Image: "example", Tag: "v0.2.0", Digest: "sha256:abc"}},
+	}
+
+	assert.Equal(t, []string{"example:v0.2.0@sha256:abc"}, task.ListImages())
+}
+
 func TestTask_ListImages_Empty(t *testing.T) {
 	task := Task{
 		Images: []Image{},
@@ -120,3 +144,8 @@ func TestTask_IsAppNotFoundError_Fail(t *testing.T) {
 	}
 	assert.Equal(t, false, task.IsAppNotFoundError(errors.New("random but very important error")))
 }
+
+func TestTask_WriteBackSubject(t *testing.T) {
+	assert.Equal(t, "update image tag", (&Task{Images: []Image{{Image: "app", Tag: "v2"}}}).WriteBackSubject())
+	assert.Equal(t, "update chart version to 1.4.0", (&Task{ChartVersion: "1.4.0"}).WriteBackSubject())
+}
@@ -135,6 +135,36 @@ func TestSend(t *testing.T) {
 		assert.NoError(t, err)
 	})
 
+	t.Run("Write-back commit in the template", func(t *testing.T) {
+		commitTmpl, err := template.New("webhook").Parse(`{"commit":"{{with .WriteBack}}{{.CommitSha}}{{end}}"}`)
+		require.NoError(t, err)
+
+		for _, test := range []struct {
+			task     models.Task
+			expected string
+		}{
+			{models.Task{Id: "a", WriteBack: &models.WriteBack{CommitSha: "3f78685"}}, `{"commit":"3f78685"}`},
+			{models.Task{Id: "b"}, `{"commit":""}`},
+		} {
+			ctrl := gomock.NewController(t)
+			mockClient := mocks.NewMockHTTPClient(ctrl)
+			mockClient.EXPECT().Do(gomock.Any()).DoAndReturn(func(req *http.Request) (*http.Response, error) {
+				body, _ := io.ReadAll(req.Body)
+				assert.JSONEq(t, test.expected, string(body))
+				return &http.Response{StatusCode: http.StatusOK, Body: io.NopCloser(strings.NewReader(""))}, nil
+			})
+
+			service := &WebhookStrategy{
+				url:                  "http://testhost/hook",
+				contentType:          "application/json",
+				allowedResponseCodes: []int{200},
+				client:               mockClient,
+				template:             commitTmpl,
+			}
+			assert.NoError(t, service.Send(test.task))
+		}
+	})
+
 	t.Run("Failed Template Execution", func(t *testing.T) {
 		invalidTmpl, err := template.New("webhook").Parse(`{"missing_field":"{{.Missing}}>"}`)
 		require.NoError(t, err)
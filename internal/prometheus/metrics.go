@@ -1,6 +1,8 @@
 package prometheus
 
 import (
+	"time"
+
 	"github.com/prometheus/client_golang/prometheus"
 )
 
@@ -23,6 +25,10 @@ type MetricsInterface interface {
 	ObserveGitBatchSize(size int)
 	AddUnauthenticatedRead(path, app string)
 	AddSkippedWriteback(app string)
+	SetArgoTokenExpiry(expiry time.Time)
+	AddRepoCacheLookup(hit bool)
+	AddRepoCacheEviction(reason string)
+	SetRepoCacheSize(bytes int64, clones int)
 }
 
 type Metrics struct {
@@ -40,6 +46,11 @@ type Metrics struct {
 	GitBatchSize         prometheus.Histogram
 	UnauthenticatedReads *prometheus.CounterVec
 	SkippedWritebacks    *prometheus.CounterVec
+	ArgoTokenExpiry      prometheus.Gauge
+	RepoCacheLookups     *prometheus.CounterVec
+	RepoCacheEvictions   *prometheus.CounterVec
+	RepoCacheSize        prometheus.Gauge
+	RepoCacheClones      prometheus.Gauge
 }
 
 // NewMetrics registers the collectors with the provided Registerer.
@@ -151,9 +162,43 @@ func NewMetrics(reg prometheus.Registerer) *Metrics {
 			Name: "gitops_writeback_skipped_unvalidated",
 			Help: "Write-backs skipped because a task for a watcher-managed application presented no valid credential.",
 		}, []string{"app"}),
+		// ArgoTokenExpiry is when the token argo-watcher authenticates to ArgoCD with stops
+		// being accepted, so an alert can fire before every deployment starts failing with
+		// "failed to login to argocd". A session token logged in with ARGO_USERNAME is renewed
+		// ahead of it, which moves the value forward; a static ARGO_TOKEN only ever gets
+		// closer. It stays 0 for a token that never expires.
+		ArgoTokenExpiry: prometheus.NewGauge(prometheus.GaugeOpts{
+			Name: "argocd_token_expiry_timestamp_seconds",
+			Help: "Unix time at which the ArgoCD API token expires; 0 when it does not expire.",
+		}),
+		// RepoCacheLookups counts write-backs by whether a clone of the repository and
+		// branch was already cached. A miss costs a full clone, so a falling hit rate after
+		// tightening REPO_CACHE_MAX_SIZE_MB or REPO_CACHE_MAX_AGE means the limits evict
+		// clones still in use.
+		RepoCacheLookups: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "gitops_repo_cache_lookups_total",
+			Help: "Write-backs by whether the repository clone was cached (hit) or had to be cloned (miss).",
+		}, []string{"result"}),
+		// RepoCacheEvictions counts clones removed from the cache, by whether they went
+		// unused for too long ("age"), were the least recently used beyond the size limit
+		// ("size"), or could not be opened as a repository ("invalid").
+		RepoCacheEvictions: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "gitops_repo_cache_evictions_total",
+			Help: "Repository clones evicted from the cache, by reason.",
+		}, []string{"reason"}),
+		// RepoCacheSize and RepoCacheClones are measured by every sweep of the cache, after
+		// its evictions.
+		RepoCacheSize: prometheus.NewGauge(prometheus.GaugeOpts{
+			Name: "gitops_repo_cache_size_bytes",
+			Help: "Size on disk of the repository cache as of its last sweep.",
+		}),
+		RepoCacheClones: prometheus.NewGauge(prometheus.GaugeOpts{
+			Name: "gitops_repo_cache_clones",
+			Help: "Number of repository clones in the cache as of its last sweep.",
+		}),
 	}
 
-	reg.MustRegister(m.FailedDeployment, m.DeploymentsTotal, m.AcceptedDeployments, m.UnconfirmedFailures, m.ArgocdUnavailable, m.StateUnavailable, m.InProgressTasks, m.RefreshDuration, m.GitWritebackDuration, m.GitLockWaitDuration, m.DeploymentDuration, m.GitBatchSize, m.UnauthenticatedReads, m.SkippedWritebacks)
+	reg.MustRegister(m.FailedDeployment, m.DeploymentsTotal, m.AcceptedDeployments, m.UnconfirmedFailures, m.ArgocdUnavailable, m.StateUnavailable, m.InProgressTasks, m.RefreshDuration, m.GitWritebackDuration, m.GitLockWaitDuration, m.DeploymentDuration, m.GitBatchSize, m.UnauthenticatedReads, m.SkippedWritebacks, m.ArgoTokenExpiry, m.RepoCacheLookups, m.RepoCacheEvictions, m.RepoCacheSize, m.RepoCacheClones)
 
 	return m
 }
@@ -248,3 +293,32 @@ func (m *Metrics) AddUnauthenticatedRead(path, app string) {
 func (m *Metrics) AddSkippedWriteback(app string) {
 	m.SkippedWritebacks.WithLabelValues(app).Inc()
 }
+
+// SetArgoTokenExpiry sets ArgoTokenExpiry; a zero expiry records a token that never expires.
+func (m *Metrics) SetArgoTokenExpiry(expiry time.Time) {
+	if expiry.IsZero() {
+		m.ArgoTokenExpiry.Set(0)
+		return
+	}
+	m.ArgoTokenExpiry.Set(float64(expiry.Unix()))
+}
+
+// AddRepoCacheLookup increments RepoCacheLookups under "hit" or "miss".
+func (m *Metrics) AddRepoCacheLookup(hit bool) {
+	result := "miss"
+	if hit {
+		result = "hit"
+	}
+	m.RepoCacheLookups.WithLabelValues(result).Inc()
+}
+
+// AddRepoCacheEviction increments RepoCacheEvictions for reason; see that field.
+func (m *Metrics) AddRepoCacheEviction(reason string) {
+	m.RepoCacheEvictions.WithLabelValues(reason).Inc()
+}
+
+// SetRepoCacheSize sets RepoCacheSize and RepoCacheClones.
+func (m *Metrics) SetRepoCacheSize(bytes int64, clones int) {
+	m.RepoCacheSize.Set(float64(bytes))
+	m.RepoCacheClones.Set(float64(clones))
+}
@@ -3,6 +3,7 @@ package prometheus
 import (
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/testutil"
@@ -152,6 +153,18 @@ func TestMetrics_AddSkippedWriteback(t *testing.T) {
 	assert.NoError(t, err)
 }
 
+func TestMetrics_SetArgoTokenExpiry(t *testing.T) {
+	reg := prometheus.NewRegistry()
+	m := NewMetrics(reg)
+
+	m.SetArgoTokenExpiry(time.Unix(1767225600, 0))
+	assert.Equal(t, float64(1767225600), testutil.ToFloat64(m.ArgoTokenExpiry))
+
+	m.SetArgoTokenExpiry(time.Time{})
+	assert.Equal(t, float64(0), testutil.ToFloat64(m.ArgoTokenExpiry))
+	assert.Equal(t, 1, testutil.CollectAndCount(reg, "argocd_token_expiry_timestamp_seconds"))
+}
+
 func TestMetrics_ObserveGitWritebackDuration(t *testing.T) {
 	reg := prometheus.NewRegistry()
 	m := NewMetrics(reg)
@@ -264,3 +277,29 @@ func TestMetrics_LabellessCountersStartAtZero(t *testing.T) {
 		"accepted_deployments", "unconfirmed_deployment_failures")
 	assert.NoError(t, err)
 }
+
+func TestMetrics_RepoCache(t *testing.T) {
+	reg := prometheus.NewRegistry()
+	m := NewMetrics(reg)
+	expectedMetric := `
+		# HELP gitops_repo_cache_lookups_total Write-backs by whether the repository clone was cached (hit) or had to be cloned (miss).
+		# TYPE gitops_repo_cache_lookups_total counter
+		gitops_repo_cache_lookups_total{result="hit"} 2
+		gitops_repo_cache_lookups_total{result="miss"} 1
+		# HELP gitops_repo_cache_evictions_total Repository clones evicted from the cache, by reason.
+		# TYPE gitops_repo_cache_evictions_total counter
+		gitops_repo_cache_evictions_total{reason="age"} 1
+	`
+
+	m.AddRepoCacheLookup(true)
+	m.AddRepoCacheLookup(true)
+	m.AddRepoCacheLookup(false)
+	m.AddRepoCacheEviction("age")
+	m.SetRepoCacheSize(4096, 3)
+
+	err := testutil.CollectAndCompare(reg, strings.NewReader(expectedMetric), "gitops_repo_cache_lookups_total", "gitops_repo_cache_evictions_total")
+	assert.NoError(t, err)
+	assert.Equal(t, float64(4096), testutil.ToFloat64(m.RepoCacheSize))
+	assert.Equal(t, float64(3), testutil.ToFloat64(m.RepoCacheClones))
+	assert.Equal(t, 1, testutil.CollectAndCount(reg, "gitops_repo_cache_size_bytes"))
+}
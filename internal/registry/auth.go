@@ -0,0 +1,181 @@
+package registry
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+)
+
+// maxTokenResponseBytes bounds how much of a token response is read.
+const maxTokenResponseBytes = 1 << 20
+
+// credential is a username and password for a registry.
+type credential struct {
+	Username string
+	Password string
+}
+
+// dockerConfig is the part of a Docker config.json holding registry credentials.
+type dockerConfig struct {
+	Auths map[string]struct {
+		Auth     string `json:"auth"`
+		Username string `json:"username"`
+		Password string `json:"password"`
+	} `json:"auths"`
+}
+
+// loadCredentials reads the registry credentials of the Docker config.json at path,
+// keyed by registry host. Docker keys an entry by host or by URL, and Docker Hub by
+// https://index.docker.io/v1/.
+func loadCredentials(path string) (map[string]credential, error) {
+	content, err := os.ReadFile(path) // #nosec G304 -- the path is operator configuration
+	if err != nil {
+		return nil, fmt.Errorf("could not read the registry credentials: %w", err)
+	}
+
+	var config dockerConfig
+	if err := json.Unmarshal(content, &config); err != nil {
+		return nil, fmt.Errorf("could not parse the registry credentials %s: %w", path, err)
+	}
+
+	credentials := make(map[string]credential, len(config.Auths))
+	for key, entry := range config.Auths {
+		found := credential{Username: entry.Username, Password: entry.Password}
+		if entry.Auth != "" {
+			decoded, err := base64.StdEncoding.DecodeString(entry.Auth)
+			if err != nil {
+				return nil, fmt.Errorf("registry credentials of %q: auth is not base64", key)
+			}
+			username, password, ok := strings.Cut(string(decoded), ":")
+			if !ok {
+				return nil, fmt.Errorf("registry credentials of %q: auth is not a username:password pair", key)
+			}
+			found = credential{Username: username, Password: password}
+		}
+		credentials[credentialHost(key)] = found
+	}
+	return credentials, nil
+}
+
+// credentialHost returns the registry host a Docker config.json entry is keyed by.
+func credentialHost(key string) string {
+	host := key
+	if parsed, err := url.Parse(key); err == nil && parsed.Host != "" {
+		host = parsed.Host
+	}
+	host, _, _ = strings.Cut(host, "/")
+	if host == "index.docker.io" || host == dockerHubApiHost {
+		return dockerHubHost
+	}
+	return host
+}
+
+// challenge is a parsed WWW-Authenticate header of a registry response.
+type challenge struct {
+	Scheme     string
+	Parameters map[string]string
+}
+
+// parseChallenge reads a WWW-Authenticate header such as
+// `Bearer realm="https://auth.example.com/token",service="registry",scope="..."`.
+func parseChallenge(header string) challenge {
+	scheme, rest, _ := strings.Cut(strings.TrimSpace(header), " ")
+	parsed := challenge{Scheme: strings.ToLower(scheme), Parameters: map[string]string{}}
+
+	for rest = strings.TrimSpace(rest); rest != ""; rest = strings.TrimLeft(rest, ", ") {
+		name, value, found := strings.Cut(rest, "=")
+		if !found {
+			break
+		}
+		name = strings.ToLower(strings.TrimSpace(name))
+		if strings.HasPrefix(value, `"`) {
+			end := strings.Index(value[1:], `"`)
+			if end < 0 {
+				parsed.Parameters[name] = value[1:]
+				break
+			}
+			parsed.Parameters[name] = value[1 : end+1]
+			rest = value[end+2:]
+			continue
+		}
+		value, rest, _ = strings.Cut(value, ",")
+		parsed.Parameters[name] = strings.TrimSpace(value)
+	}
+	return parsed
+}
+
+// authorization answers the challenge of a registry with the Authorization header to
+// repeat the request with: the credential itself for Basic, or for Bearer a token the
+// realm issues, anonymously when there is no credential.
+func (client *Client) authorization(ctx context.Context, ref reference, found challenge) (string, error) {
+	cred, hasCredential := client.credentials[ref.Host]
+
+	switch found.Scheme {
+	case "basic":
+		if !hasCredential {
+			return "", fmt.Errorf("registry %s requires credentials, and REGISTRY_AUTH_FILE holds none for it", ref.Host)
+		}
+		return "Basic " + base64.StdEncoding.EncodeToString([]byte(cred.Username+":"+cred.Password)), nil
+	case "bearer":
+	default:
+		return "", fmt.Errorf("registry %s asks for unsupported authentication %q", ref.Host, found.Scheme)
+	}
+
+	realm, err := url.Parse(found.Parameters["realm"])
+	if err != nil || (realm.Scheme != "https" && realm.Scheme != "http") {
+		return "", fmt.Errorf("registry %s names no usable token realm", ref.Host)
+	}
+	query := realm.Query()
+	if service := found.Parameters["service"]; service != "" {
+		query.Set("service", service)
+	}
+	scope := found.Parameters["scope"]
+	if scope == "" {
+		scope = "repository:" + ref.Repository + ":pull"
+	}
+	query.Set("scope", scope)
+	realm.RawQuery = query.Encode()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, realm.String(), nil)
+	if err != nil {
+		return "", err
+	}
+	if hasCredential {
+		req.SetBasicAuth(cred.Username, cred.Password)
+	}
+
+	resp, err := client.client.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("could not get a token for registry %s: %w", ref.Host, err)
+	}
+	defer closeBody(resp)
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxTokenResponseBytes))
+	if err != nil {
+		return "", err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("registry %s refused a token (HTTP %d): %.200s", ref.Host, resp.StatusCode, body)
+	}
+
+	var token struct {
+		Token       string `json:"token"`
+		AccessToken string `json:"access_token"`
+	}
+	if err := json.Unmarshal(body, &token); err != nil {
+		return "", fmt.Errorf("unexpected token response from registry %s: %.200s", ref.Host, body)
+	}
+	if token.Token == "" {
+		token.Token = token.AccessToken
+	}
+	if token.Token == "" {
+		return "", fmt.Errorf("registry %s issued an empty token", ref.Host)
+	}
+	return "Bearer " + token.Token, nil
+}
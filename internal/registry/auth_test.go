@@ -0,0 +1,63 @@
+package registry
+
+import (
+	"encoding/base64"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func writeAuthFile(t *testing.T, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "config.json")
+	require.NoError(t, os.WriteFile(path, []byte(content), 0o600))
+	return path
+}
+
+func TestLoadCredentials(t *testing.T) {
+	auth := base64.StdEncoding.EncodeToString([]byte("ci:s3cr:et"))
+	path := writeAuthFile(t, `{"auths": {
+		"ghcr.io": {"auth": "`+auth+`"},
+		"https://index.docker.io/v1/": {"username": "hub", "password": "token"},
+		"https://registry.example.com:5000/v2/": {"username": "local", "password": "pass"}
+	}}`)
+
+	credentials, err := loadCredentials(path)
+
+	require.NoError(t, err)
+	assert.Equal(t, map[string]credential{
+		"ghcr.io":                   {Username: "ci", Password: "s3cr:et"},
+		"docker.io":                 {Username: "hub", Password: "token"},
+		"registry.example.com:5000": {Username: "local", Password: "pass"},
+	}, credentials)
+}
+
+func TestLoadCredentialsRejectsAMalformedFile(t *testing.T) {
+	for content, message := range map[string]string{
+		`{"auths": `: "could not parse the registry credentials",
+		`{"auths": {"ghcr.io": {"auth": "%%%"}}}`:  "auth is not base64",
+		`{"auths": {"ghcr.io": {"auth": "Y2k="}}}`: "auth is not a username:password pair",
+	} {
+		_, err := loadCredentials(writeAuthFile(t, content))
+		assert.ErrorContains(t, err, message, content)
+	}
+
+	_, err := loadCredentials(filepath.Join(t.TempDir(), "missing.json"))
+	assert.ErrorContains(t, err, "could not read the registry credentials")
+}
+
+func TestParseChallenge(t *testing.T) {
+	parsed := parseChallenge(`Bearer realm="https://auth.docker.io/token",service="registry.docker.io",scope="repository:library/nginx:pull,push"`)
+
+	assert.Equal(t, "bearer", parsed.Scheme)
+	assert.Equal(t, map[string]string{
+		"realm":   "https://auth.docker.io/token",
+		"service": "registry.docker.io",
+		"scope":   "repository:library/nginx:pull,push",
+	}, parsed.Parameters)
+
+	assert.Equal(t, challenge{Scheme: "basic", Parameters: map[string]string{"realm": "Registry"}}, parseChallenge(`Basic realm=Registry`))
+}
@@ -0,0 +1,226 @@
+package registry
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+// maxManifestBytes bounds how much of a manifest is read when the registry does not
+// name its digest. Manifests and indexes are a few kilobytes.
+const maxManifestBytes = 4 << 20
+
+// manifestMediaTypes are the manifests a tag is resolved to. An index comes first, so
+// a multi-platform image resolves to the digest every platform pulls it by.
+var manifestMediaTypes = []string{
+	"application/vnd.oci.image.index.v1+json",
+	"application/vnd.docker.distribution.manifest.list.v2+json",
+	"application/vnd.oci.image.manifest.v1+json",
+	"application/vnd.docker.distribution.manifest.v2+json",
+}
+
+// ErrNotFound is returned for an image or tag the registry does not have.
+var ErrNotFound = errors.New("not found in the registry")
+
+var (
+	tagPattern    = regexp.MustCompile(`^[A-Za-z0-9_][A-Za-z0-9_.-]{0,127}$`)
+	digestPattern = regexp.MustCompile(`^sha(256:[a-f0-9]{64}|512:[a-f0-9]{128})$`)
+)
+
+// IsValidTag reports whether tag is a tag as the OCI distribution specification
+// defines one: up to 128 letters, digits, underscores, periods and dashes, not
+// starting with a period or a dash.
+func IsValidTag(tag string) bool {
+	return tagPattern.MatchString(tag)
+}
+
+// Client resolves image tags through the OCI distribution API of their registries.
+type Client struct {
+	registryProxy string
+	insecureHosts []string
+	credentials   map[string]credential
+	client        *http.Client
+}
+
+// NewClient returns a Client for config. With registryProxy set (DOCKER_IMAGES_PROXY),
+// images are looked up through the proxy, under the name the cluster pulls them by.
+func NewClient(config *Config, registryProxy string) (*Client, error) {
+	client := &Client{
+		registryProxy: strings.TrimSuffix(strings.TrimSpace(registryProxy), "/"),
+		insecureHosts: config.InsecureHosts,
+		credentials:   map[string]credential{},
+		client:        &http.Client{Timeout: config.Timeout},
+	}
+	if config.AuthFile != "" {
+		credentials, err := loadCredentials(config.AuthFile)
+		if err != nil {
+			return nil, err
+		}
+		client.credentials = credentials
+	}
+	return client, nil
+}
+
+// Resolve returns the digest of the manifest tag points to in the repository of image.
+// The error wraps ErrNotFound when the registry has no such tag.
+func (client *Client) Resolve(ctx context.Context, image, tag string) (string, error) {
+	if !tagPattern.MatchString(tag) {
+		return "", fmt.Errorf("%q is not a tag that can be resolved to a digest", tag)
+	}
+	name, ref, manifestUrl, err := client.manifestUrl(image, tag)
+	if err != nil {
+		return "", err
+	}
+
+	// HEAD answers with the digest alone. A registry that leaves the digest out of the
+	// headers gets a GET, and the digest is that of the manifest it returns.
+	digest, err := client.fetchDigest(ctx, ref, http.MethodHead, manifestUrl)
+	if err == nil && digest == "" {
+		digest, err = client.fetchDigest(ctx, ref, http.MethodGet, manifestUrl)
+	}
+	if err != nil {
+		return "", fmt.Errorf("could not resolve %s:%s: %w", name, tag, err)
+	}
+	if !digestPattern.MatchString(digest) {
+		return "", fmt.Errorf("could not resolve %s:%s: the registry answered with digest %q", name, tag, digest)
+	}
+
+	slog.Debug("Resolved an image tag to its digest", "image", name, "tag", tag, "digest", digest)
+	return digest, nil
+}
+
+// Verify checks that the repository of image has a manifest for tag, which may also be
+// a digest. The error wraps ErrNotFound when the registry has no such manifest.
+func (client *Client) Verify(ctx context.Context, image, tag string) error {
+	if !tagPattern.MatchString(tag) && !digestPattern.MatchString(tag) {
+		return fmt.Errorf("%q is neither a tag nor a digest", tag)
+	}
+	name, ref, manifestUrl, err := client.manifestUrl(image, tag)
+	if err != nil {
+		return err
+	}
+
+	if _, err = client.fetchDigest(ctx, ref, http.MethodHead, manifestUrl); err != nil {
+		return fmt.Errorf("could not verify %s:%s: %w", name, tag, err)
+	}
+
+	slog.Debug("Verified an image tag exists", "image", name, "tag", tag)
+	return nil
+}
+
+// manifestUrl returns the name image is looked up by, its parsed reference, and the
+// URL of the manifest of tag in its repository.
+func (client *Client) manifestUrl(image, tag string) (string, reference, string, error) {
+	name, ref, repositoryUrl, err := client.repositoryUrl(image)
+	return name, ref, repositoryUrl + "/manifests/" + tag, err
+}
+
+// repositoryUrl returns the name image is looked up by, its parsed reference, and the
+// URL of its repository in the distribution API, under which its manifests and blobs are.
+func (client *Client) repositoryUrl(image string) (string, reference, string, error) {
+	name := image
+	if client.registryProxy != "" {
+		name = client.registryProxy + "/" + image
+	}
+	ref, err := parseReference(name)
+	if err != nil {
+		return "", reference{}, "", err
+	}
+
+	scheme := "https"
+	if slices.Contains(client.insecureHosts, ref.Host) {
+		scheme = "http"
+	}
+	return name, ref, fmt.Sprintf("%s://%s/v2/%s", scheme, ref.apiHost(), ref.Repository), nil
+}
+
+// fetchDigest requests the manifest at manifestUrl and returns the digest the response
+// names, or for a GET the digest of the body it carries. A HEAD response naming none
+// returns an empty digest.
+func (client *Client) fetchDigest(ctx context.Context, ref reference, method, manifestUrl string) (string, error) {
+	resp, err := client.send(ctx, ref, method, manifestUrl, manifestMediaTypes)
+	if err != nil {
+		return "", err
+	}
+	return readDigest(resp, method)
+}
+
+// send requests target from the registry of ref, accepting the media types of accept,
+// and answers a challenge of the registry once. The caller closes the response body.
+func (client *Client) send(ctx context.Context, ref reference, method, target string, accept []string) (*http.Response, error) {
+	authorization := ""
+	for attempt := 0; ; attempt++ {
+		req, err := http.NewRequestWithContext(ctx, method, target, nil)
+		if err != nil {
+			return nil, err
+		}
+		if len(accept) > 0 {
+			req.Header.Set("Accept", strings.Join(accept, ", "))
+		}
+		if authorization != "" {
+			req.Header.Set("Authorization", authorization)
+		}
+
+		resp, err := client.client.Do(req)
+		if err != nil {
+			return nil, err
+		}
+		if resp.StatusCode != http.StatusUnauthorized || attempt > 0 {
+			return resp, nil
+		}
+		closeBody(resp)
+
+		authorization, err = client.authorization(ctx, ref, parseChallenge(resp.Header.Get("WWW-Authenticate")))
+		if err != nil {
+			return nil, err
+		}
+	}
+}
+
+// closeBody closes the body of resp, logging rather than returning a failure.
+func closeBody(resp *http.Response) {
+	if err := resp.Body.Close(); err != nil {
+		slog.Error("failed to close response body", "error", err)
+	}
+}
+
+// readDigest reads the digest of a manifest response and closes its body.
+func readDigest(resp *http.Response, method string) (string, error) {
+	defer closeBody(resp)
+
+	if err := statusError(resp); err != nil {
+		return "", err
+	}
+
+	if digest := resp.Header.Get("Docker-Content-Digest"); digest != "" || method == http.MethodHead {
+		return digest, nil
+	}
+
+	hash := sha256.New()
+	if _, err := io.Copy(hash, io.LimitReader(resp.Body, maxManifestBytes)); err != nil {
+		return "", err
+	}
+	return "sha256:" + hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+// statusError returns the error a registry response that is not 200 OK stands for,
+// ErrNotFound for a 404.
+func statusError(resp *http.Response) error {
+	switch {
+	case resp.StatusCode == http.StatusNotFound:
+		return ErrNotFound
+	case resp.StatusCode == http.StatusUnauthorized:
+		return errors.New("the registry refused the credentials")
+	case resp.StatusCode != http.StatusOK:
+		return fmt.Errorf("the registry answered HTTP %d", resp.StatusCode)
+	}
+	return nil
+}
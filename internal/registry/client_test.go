@@ -0,0 +1,242 @@
+package registry
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const indexManifest = `{"schemaVersion":2,"mediaType":"application/vnd.oci.image.index.v1+json","manifests":[]}`
+
+// localRegistry stands in for a registry serving the manifests of its repositories,
+// keyed by "repository:tag", and their blobs, keyed by "repository@digest".
+type localRegistry struct {
+	*httptest.Server
+	manifests map[string]string
+	blobs     map[string]string
+	// token, when set, is required as a bearer token issued by the registry's /token
+	// realm to the credential in user and password, when those are set.
+	token    string
+	user     string
+	password string
+	// omitDigest leaves the Docker-Content-Digest header out of manifest responses.
+	omitDigest bool
+	requests   []string
+}
+
+func newLocalRegistry(t *testing.T, manifests map[string]string) *localRegistry {
+	t.Helper()
+	registry := &localRegistry{manifests: manifests, blobs: map[string]string{}}
+	registry.Server = httptest.NewServer(http.HandlerFunc(registry.serve))
+	t.Cleanup(registry.Close)
+	return registry
+}
+
+func (registry *localRegistry) host() string {
+	parsed, _ := url.Parse(registry.URL)
+	return parsed.Host
+}
+
+func (registry *localRegistry) serve(w http.ResponseWriter, r *http.Request) {
+	registry.requests = append(registry.requests, r.Method+" "+r.URL.Path)
+
+	if r.URL.Path == "/token" {
+		if user, password, _ := r.BasicAuth(); user != registry.user || password != registry.password {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		_ = json.NewEncoder(w).Encode(map[string]string{"token": registry.token})
+		return
+	}
+
+	if registry.token != "" && r.Header.Get("Authorization") != "Bearer "+registry.token {
+		w.Header().Set("WWW-Authenticate", `Bearer realm="`+registry.URL+`/token",service="local"`)
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+
+	if repository, digest, found := strings.Cut(strings.TrimPrefix(r.URL.Path, "/v2/"), "/blobs/"); found {
+		blob, exists := registry.blobs[repository+"@"+digest]
+		if !exists {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		_, _ = w.Write([]byte(blob))
+		return
+	}
+
+	repository, tag, found := strings.Cut(strings.TrimPrefix(r.URL.Path, "/v2/"), "/manifests/")
+	manifest, exists := registry.manifests[repository+":"+tag]
+	if !found || !exists {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	var described struct {
+		MediaType string `json:"mediaType"`
+	}
+	_ = json.Unmarshal([]byte(manifest), &described)
+	if !strings.Contains(r.Header.Get("Accept"), described.MediaType) {
+		w.WriteHeader(http.StatusNotAcceptable)
+		return
+	}
+
+	if !registry.omitDigest {
+		w.Header().Set("Docker-Content-Digest", manifestDigest(manifest))
+	}
+	w.Header().Set("Content-Type", described.MediaType)
+	if r.Method == http.MethodGet {
+		_, _ = w.Write([]byte(manifest))
+	}
+}
+
+func manifestDigest(manifest string) string {
+	sum := sha256.Sum256([]byte(manifest))
+	return "sha256:" + hex.EncodeToString(sum[:])
+}
+
+func newTestClient(t *testing.T, registry *localRegistry, proxy string) *Client {
+	t.Helper()
+	client, err := NewClient(&Config{InsecureHosts: []string{registry.host()}, Timeout: 5 * time.Second}, proxy)
+	require.NoError(t, err)
+	return client
+}
+
+func TestResolve(t *testing.T) {
+	t.Run("Resolves a tag to the digest of its manifest", func(t *testing.T) {
+		registry := newLocalRegistry(t, map[string]string{"team/app:v1.2.3": indexManifest})
+
+		digest, err := newTestClient(t, registry, "").Resolve(context.Background(), registry.host()+"/team/app", "v1.2.3")
+
+		require.NoError(t, err)
+		assert.Equal(t, manifestDigest(indexManifest), digest)
+		assert.Equal(t, []string{"HEAD /v2/team/app/manifests/v1.2.3"}, registry.requests)
+	})
+
+	t.Run("Hashes the manifest when the registry does not name its digest", func(t *testing.T) {
+		registry := newLocalRegistry(t, map[string]string{"team/app:v1.2.3": indexManifest})
+		registry.omitDigest = true
+
+		digest, err := newTestClient(t, registry, "").Resolve(context.Background(), registry.host()+"/team/app", "v1.2.3")
+
+		require.NoError(t, err)
+		assert.Equal(t, manifestDigest(indexManifest), digest)
+		assert.Equal(t, []string{"HEAD /v2/team/app/manifests/v1.2.3", "GET /v2/team/app/manifests/v1.2.3"}, registry.requests)
+	})
+
+	t.Run("Looks the image up through the registry proxy", func(t *testing.T) {
+		registry := newLocalRegistry(t, map[string]string{"ghcr.io/shini4i/app:v1": indexManifest})
+
+		digest, err := newTestClient(t, registry, registry.host()).Resolve(context.Background(), "ghcr.io/shini4i/app", "v1")
+
+		require.NoError(t, err)
+		assert.Equal(t, manifestDigest(indexManifest), digest)
+	})
+
+	t.Run("Authenticates with a token of the registry's realm", func(t *testing.T) {
+		registry := newLocalRegistry(t, map[string]string{"team/app:v1": indexManifest})
+		registry.token, registry.user, registry.password = "issued-token", "ci", "secret"
+
+		authFile := filepath.Join(t.TempDir(), "config.json")
+		require.NoError(t, os.WriteFile(authFile, []byte(`{"auths":{"`+registry.host()+`":{"username":"ci","password":"secret"}}}`), 0o600))
+		client, err := NewClient(&Config{AuthFile: authFile, InsecureHosts: []string{registry.host()}, Timeout: 5 * time.Second}, "")
+		require.NoError(t, err)
+
+		digest, err := client.Resolve(context.Background(), registry.host()+"/team/app", "v1")
+
+		require.NoError(t, err)
+		assert.Equal(t, manifestDigest(indexManifest), digest)
+	})
+
+	t.Run("Fails when the realm refuses a token", func(t *testing.T) {
+		registry := newLocalRegistry(t, map[string]string{"team/app:v1": indexManifest})
+		registry.token, registry.user, registry.password = "issued-token", "ci", "secret"
+
+		_, err := newTestClient(t, registry, "").Resolve(context.Background(), registry.host()+"/team/app", "v1")
+
+		assert.ErrorContains(t, err, "refused a token (HTTP 401)")
+	})
+
+	t.Run("Reports a missing tag as not found", func(t *testing.T) {
+		registry := newLocalRegistry(t, map[string]string{"team/app:v1": indexManifest})
+
+		_, err := newTestClient(t, registry, "").Resolve(context.Background(), registry.host()+"/team/app", "v2")
+
+		assert.ErrorIs(t, err, ErrNotFound)
+		assert.ErrorContains(t, err, "team/app:v2")
+	})
+
+	t.Run("Rejects what is not a tag", func(t *testing.T) {
+		registry := newLocalRegistry(t, nil)
+
+		_, err := newTestClient(t, registry, "").Resolve(context.Background(), registry.host()+"/team/app", "../v1")
+
+		assert.ErrorContains(t, err, "is not a tag")
+		assert.Empty(t, registry.requests)
+	})
+}
+
+func TestVerify(t *testing.T) {
+	t.Run("Finds a tag with a single HEAD request", func(t *testing.T) {
+		registry := newLocalRegistry(t, map[string]string{"team/app:v1.2.3": indexManifest})
+		registry.omitDigest = true
+
+		err := newTestClient(t, registry, "").Verify(context.Background(), registry.host()+"/team/app", "v1.2.3")
+
+		require.NoError(t, err)
+		assert.Equal(t, []string{"HEAD /v2/team/app/manifests/v1.2.3"}, registry.requests)
+	})
+
+	t.Run("Finds a digest", func(t *testing.T) {
+		digest := manifestDigest(indexManifest)
+		registry := newLocalRegistry(t, map[string]string{"team/app:" + digest: indexManifest})
+
+		err := newTestClient(t, registry, "").Verify(context.Background(), registry.host()+"/team/app", digest)
+
+		assert.NoError(t, err)
+	})
+
+	t.Run("Uses the credentials of the image's registry host", func(t *testing.T) {
+		registry := newLocalRegistry(t, map[string]string{"team/app:v1": indexManifest})
+		registry.token, registry.user, registry.password = "issued-token", "ci", "secret"
+
+		authFile := filepath.Join(t.TempDir(), "config.json")
+		require.NoError(t, os.WriteFile(authFile, []byte(`{"auths":{
+			"ghcr.io":{"username":"other","password":"wrong"},
+			"`+registry.host()+`":{"username":"ci","password":"secret"}
+		}}`), 0o600))
+		client, err := NewClient(&Config{AuthFile: authFile, InsecureHosts: []string{registry.host()}, Timeout: 5 * time.Second}, "")
+		require.NoError(t, err)
+
+		assert.NoError(t, client.Verify(context.Background(), registry.host()+"/team/app", "v1"))
+	})
+
+	t.Run("Reports a missing tag as not found, naming the image", func(t *testing.T) {
+		registry := newLocalRegistry(t, map[string]string{"team/app:v1.2.3": indexManifest})
+
+		err := newTestClient(t, registry, "").Verify(context.Background(), registry.host()+"/team/app", "v1.2.4")
+
+		assert.ErrorIs(t, err, ErrNotFound)
+		assert.ErrorContains(t, err, "could not verify "+registry.host()+"/team/app:v1.2.4")
+	})
+
+	t.Run("Rejects what is neither a tag nor a digest", func(t *testing.T) {
+		registry := newLocalRegistry(t, nil)
+
+		err := newTestClient(t, registry, "").Verify(context.Background(), registry.host()+"/team/app", "v1/../v2")
+
+		assert.ErrorContains(t, err, "is neither a tag nor a digest")
+		assert.Empty(t, registry.requests)
+	})
+}
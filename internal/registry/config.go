@@ -0,0 +1,59 @@
+package registry
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	envConfig "github.com/caarlos0/env/v11"
+
+	"github.com/shini4i/argo-watcher/internal/helpers"
+)
+
+// Config holds the settings of the container registry lookups. It has no required
+// fields, so servers that never look an image up start without any of them.
+type Config struct {
+	// PinDigests resolves the tag of every task image to the digest of its manifest when
+	// the task is submitted. The digest is stored with the task, written back next to the
+	// tag, and the rollout is matched on it.
+	PinDigests bool `env:"PIN_IMAGE_DIGESTS" envDefault:"false"`
+	// VerifyImages refuses a task whose image tags the registry does not have, instead
+	// of accepting it and timing out on pods stuck in ImagePullBackOff. Pinning digests
+	// verifies the images as a side effect.
+	VerifyImages bool `env:"VERIFY_IMAGES" envDefault:"false"`
+	// SignaturePolicyPath names a YAML file of the public keys whose cosign signatures
+	// the images of an application or project need (see policySpec).
+	SignaturePolicyPath string `env:"COSIGN_POLICY_PATH"`
+	// AuthFile names a Docker config.json whose "auths" hold the credentials of private
+	// registries, as written by docker login or kept in a dockerconfigjson Secret.
+	AuthFile string `env:"REGISTRY_AUTH_FILE"`
+	// InsecureHosts are the registries spoken to over plain HTTP, such as a local
+	// registry on localhost:5000.
+	InsecureHosts []string `env:"REGISTRY_INSECURE_HOSTS" envSeparator:","`
+	// Timeout bounds the lookup of a single image, authentication included.
+	Timeout time.Duration `env:"REGISTRY_TIMEOUT" envDefault:"30s"`
+}
+
+// NewConfig loads Config from environment variables.
+func NewConfig() (*Config, error) {
+	config, err := envConfig.ParseAs[Config]()
+	if err != nil {
+		return nil, helpers.PrettifyEnvError(err, "invalid argo-watcher registry configuration:")
+	}
+
+	config.AuthFile = strings.TrimSpace(config.AuthFile)
+	config.SignaturePolicyPath = strings.TrimSpace(config.SignaturePolicyPath)
+	hosts := config.InsecureHosts[:0]
+	for _, host := range config.InsecureHosts {
+		if host = strings.TrimSpace(host); host != "" {
+			hosts = append(hosts, host)
+		}
+	}
+	config.InsecureHosts = hosts
+
+	if config.Timeout <= 0 {
+		return nil, fmt.Errorf("REGISTRY_TIMEOUT must be > 0, got %s", config.Timeout)
+	}
+
+	return &config, nil
+}
@@ -0,0 +1,27 @@
+package registry
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewConfigDefaults(t *testing.T) {
+	t.Setenv("REGISTRY_INSECURE_HOSTS", " localhost:5000, ,registry.local ")
+
+	cfg, err := NewConfig()
+	require.NoError(t, err)
+	assert.False(t, cfg.PinDigests)
+	assert.False(t, cfg.VerifyImages)
+	assert.Equal(t, []string{"localhost:5000", "registry.local"}, cfg.InsecureHosts)
+	assert.Equal(t, 30*time.Second, cfg.Timeout)
+}
+
+func TestNewConfigRejectsANonPositiveTimeout(t *testing.T) {
+	t.Setenv("REGISTRY_TIMEOUT", "0s")
+
+	_, err := NewConfig()
+	assert.ErrorContains(t, err, "REGISTRY_TIMEOUT must be > 0")
+}
@@ -0,0 +1,132 @@
+package registry
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+	"os"
+	"slices"
+
+	"gopkg.in/yaml.v3"
+)
+
+// policySpec is the format of COSIGN_POLICY_PATH:
+//
+//	keys:
+//	  release: |
+//	    -----BEGIN PUBLIC KEY-----
+//	    ...
+//	    -----END PUBLIC KEY-----
+//	projects:
+//	  payments: [release]
+//	applications:
+//	  payments-api: [release, security]
+//	default: [release]
+//
+// An application's rule replaces its project's, which replaces the default. Images of
+// applications no rule covers, or whose rule is an empty list, are deployed without a
+// signature check.
+type policySpec struct {
+	Keys         map[string]string   `yaml:"keys"`
+	Projects     map[string][]string `yaml:"projects"`
+	Applications map[string][]string `yaml:"applications"`
+	Default      []string            `yaml:"default"`
+}
+
+// SignaturePolicy holds the public keys whose cosign signatures an image needs, per
+// application and project.
+type SignaturePolicy struct {
+	keys         map[string]crypto.PublicKey
+	projects     map[string][]string
+	applications map[string][]string
+	fallback     []string
+}
+
+// LoadSignaturePolicy reads the policy file at path. Every key is parsed and every rule
+// checked up front, so a mistake in the file fails startup instead of the first
+// deployment of the application it concerns.
+func LoadSignaturePolicy(path string) (*SignaturePolicy, error) {
+	content, err := os.ReadFile(path) // #nosec G304 -- the path is operator configuration
+	if err != nil {
+		return nil, fmt.Errorf("could not read the signature policy: %w", err)
+	}
+
+	var spec policySpec
+	if err := yaml.Unmarshal(content, &spec); err != nil {
+		return nil, fmt.Errorf("could not parse the signature policy %s: %w", path, err)
+	}
+
+	policy := &SignaturePolicy{
+		keys:         make(map[string]crypto.PublicKey, len(spec.Keys)),
+		projects:     spec.Projects,
+		applications: spec.Applications,
+		fallback:     spec.Default,
+	}
+	for name, encoded := range spec.Keys {
+		if policy.keys[name], err = parsePublicKey(encoded); err != nil {
+			return nil, fmt.Errorf("signature policy key %q: %w", name, err)
+		}
+	}
+
+	rules := map[string][]string{"default": spec.Default}
+	for project, keys := range spec.Projects {
+		rules[fmt.Sprintf("project %q", project)] = keys
+	}
+	for app, keys := range spec.Applications {
+		rules[fmt.Sprintf("application %q", app)] = keys
+	}
+	for rule, keys := range rules {
+		for _, key := range keys {
+			if _, ok := policy.keys[key]; !ok {
+				return nil, fmt.Errorf("signature policy of the %s names unknown key %q", rule, key)
+			}
+		}
+	}
+
+	return policy, nil
+}
+
+// KeysFor returns the names of the keys one of which must have signed the images of
+// app in project, and none when its images need no signature.
+func (policy *SignaturePolicy) KeysFor(app, project string) []string {
+	if keys, ok := policy.applications[app]; ok {
+		return keys
+	}
+	if keys, ok := policy.projects[project]; ok {
+		return keys
+	}
+	return policy.fallback
+}
+
+// parsePublicKey parses a PEM-encoded public key of a kind cosign signs with.
+func parsePublicKey(encoded string) (crypto.PublicKey, error) {
+	block, _ := pem.Decode([]byte(encoded))
+	if block == nil || block.Type != "PUBLIC KEY" {
+		return nil, fmt.Errorf("not a PEM-encoded PUBLIC KEY")
+	}
+	key, err := x509.ParsePKIXPublicKey(block.Bytes)
+	if err != nil {
+		return nil, err
+	}
+
+	switch key.(type) {
+	case *ecdsa.PublicKey, *rsa.PublicKey, ed25519.PublicKey:
+		return key, nil
+	default:
+		return nil, fmt.Errorf("unsupported key type %T", key)
+	}
+}
+
+// sortedKeys returns the distinct key names of rules in order.
+func sortedKeys(rules [][]string) []string {
+	var names []string
+	for _, keys := range rules {
+		names = append(names, keys...)
+	}
+	slices.Sort(names)
+	return slices.Compact(names)
+}
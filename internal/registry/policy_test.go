@@ -0,0 +1,77 @@
+package registry
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/pem"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newSigningKey returns an ECDSA P-256 key, the kind cosign generates, and its public
+// key PEM-encoded, indented to sit in a YAML block scalar.
+func newSigningKey(t *testing.T) (*ecdsa.PrivateKey, string) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	der, err := x509.MarshalPKIXPublicKey(&key.PublicKey)
+	require.NoError(t, err)
+	encoded := pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der})
+	return key, "    " + strings.ReplaceAll(strings.TrimSpace(string(encoded)), "\n", "\n    ")
+}
+
+func writePolicy(t *testing.T, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "policy.yaml")
+	require.NoError(t, os.WriteFile(path, []byte(content), 0o600))
+	return path
+}
+
+func TestLoadSignaturePolicy(t *testing.T) {
+	_, release := newSigningKey(t)
+	_, security := newSigningKey(t)
+
+	policy, err := LoadSignaturePolicy(writePolicy(t, `
+keys:
+  release: |
+`+release+`
+  security: |
+`+security+`
+projects:
+  payments: [release]
+  sandbox: []
+applications:
+  payments-api: [release, security]
+default: [security]
+`))
+
+	require.NoError(t, err)
+	assert.Equal(t, []string{"release", "security"}, policy.KeysFor("payments-api", "payments"))
+	assert.Equal(t, []string{"release"}, policy.KeysFor("payments-worker", "payments"))
+	assert.Empty(t, policy.KeysFor("playground", "sandbox"))
+	assert.Equal(t, []string{"security"}, policy.KeysFor("search", "discovery"))
+}
+
+func TestLoadSignaturePolicyRejectsAMistake(t *testing.T) {
+	_, release := newSigningKey(t)
+
+	for content, message := range map[string]string{
+		"keys: [":                           "could not parse the signature policy",
+		"keys:\n  release: not a key\n":     `signature policy key "release": not a PEM-encoded PUBLIC KEY`,
+		"applications:\n  api: [release]\n": `signature policy of the application "api" names unknown key "release"`,
+		"keys:\n  release: |\n" + release + "\ndefault: [other]\n": `signature policy of the default names unknown key "other"`,
+	} {
+		_, err := LoadSignaturePolicy(writePolicy(t, content))
+		assert.ErrorContains(t, err, message, content)
+	}
+
+	_, err := LoadSignaturePolicy(filepath.Join(t.TempDir(), "missing.yaml"))
+	assert.ErrorContains(t, err, "could not read the signature policy")
+}
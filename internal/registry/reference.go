@@ -0,0 +1,60 @@
+package registry
+
+import (
+	"fmt"
+	"strings"
+)
+
+const (
+	// dockerHubHost is how images of Docker Hub are named, or left unnamed, and
+	// dockerHubApiHost is where its distribution API is served.
+	dockerHubHost    = "docker.io"
+	dockerHubApiHost = "registry-1.docker.io"
+)
+
+// reference is an image name split into the registry serving it and the repository in
+// that registry.
+type reference struct {
+	// Host is the registry as the image names it, with its port ("localhost:5000"), and
+	// "docker.io" for an image without one.
+	Host       string
+	Repository string
+}
+
+// parseReference splits the name of an image, without tag or digest. Like Docker, it
+// takes the first path component for a registry only when it looks like a host name:
+// it holds a dot or a port, or is localhost. An official Docker Hub image lives under
+// library/.
+func parseReference(image string) (reference, error) {
+	if image == "" || strings.ContainsAny(image, "@ ") {
+		return reference{}, fmt.Errorf("%q is not an image name", image)
+	}
+
+	host, repository, found := strings.Cut(image, "/")
+	if !found || !(strings.ContainsAny(host, ".:") || host == "localhost") {
+		host, repository = dockerHubHost, image
+	}
+	if host == "index.docker.io" {
+		host = dockerHubHost
+	}
+	if host == dockerHubHost && !strings.Contains(repository, "/") {
+		repository = "library/" + repository
+	}
+	if repository == "" || repository != strings.ToLower(repository) {
+		return reference{}, fmt.Errorf("%q is not an image name", image)
+	}
+
+	return reference{Host: host, Repository: repository}, nil
+}
+
+// apiHost returns the host serving the registry's distribution API.
+func (ref reference) apiHost() string {
+	if ref.Host == dockerHubHost {
+		return dockerHubApiHost
+	}
+	return ref.Host
+}
+
+func (ref reference) String() string {
+	return ref.Host + "/" + ref.Repository
+}
@@ -0,0 +1,41 @@
+package registry
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseReference(t *testing.T) {
+	tests := []struct {
+		image      string
+		host       string
+		repository string
+		apiHost    string
+	}{
+		{"nginx", "docker.io", "library/nginx", "registry-1.docker.io"},
+		{"bitnami/redis", "docker.io", "bitnami/redis", "registry-1.docker.io"},
+		{"docker.io/nginx", "docker.io", "library/nginx", "registry-1.docker.io"},
+		{"index.docker.io/bitnami/redis", "docker.io", "bitnami/redis", "registry-1.docker.io"},
+		{"ghcr.io/shini4i/argo-watcher", "ghcr.io", "shini4i/argo-watcher", "ghcr.io"},
+		{"localhost:5000/team/app", "localhost:5000", "team/app", "localhost:5000"},
+		{"localhost/app", "localhost", "app", "localhost"},
+		{"registry.example.local/ghcr.io/shini4i/app", "registry.example.local", "ghcr.io/shini4i/app", "registry.example.local"},
+	}
+
+	for _, test := range tests {
+		ref, err := parseReference(test.image)
+		require.NoError(t, err, test.image)
+		assert.Equal(t, test.host, ref.Host, test.image)
+		assert.Equal(t, test.repository, ref.Repository, test.image)
+		assert.Equal(t, test.apiHost, ref.apiHost(), test.image)
+	}
+}
+
+func TestParseReferenceRejectsWhatIsNotAnImageName(t *testing.T) {
+	for _, image := range []string{"", "ghcr.io/", "ghcr.io/Team/App", "app@sha256:abc", "my app"} {
+		_, err := parseReference(image)
+		assert.Error(t, err, image)
+	}
+}
@@ -0,0 +1,226 @@
+package registry
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"maps"
+	"net/http"
+	"slices"
+	"strings"
+)
+
+// maxPayloadBytes bounds how much of a signed payload is read. A cosign payload is a
+// few hundred bytes.
+const maxPayloadBytes = 1 << 20
+
+// signatureAnnotation is the annotation under which cosign stores the signature of the
+// payload a layer of a signature manifest holds.
+const signatureAnnotation = "dev.cosignproject.cosign/signature"
+
+// signatureMediaTypes are the manifests cosign stores signatures in.
+var signatureMediaTypes = []string{
+	"application/vnd.oci.image.manifest.v1+json",
+	"application/vnd.docker.distribution.manifest.v2+json",
+}
+
+// Target is an application an image is deployed to, with the ArgoCD project it belongs
+// to as ArgoCD reports it. The project a task names is free text and decides nothing.
+type Target struct {
+	App     string
+	Project string
+}
+
+// SignatureError reports an image that does not carry a signature the policy requires.
+// It is the submitter's to fix, unlike a registry that cannot be consulted.
+type SignatureError struct {
+	Message string
+}
+
+func (err *SignatureError) Error() string {
+	return err.Message
+}
+
+// signatureManifest is the part of a cosign signature manifest listing its signatures.
+type signatureManifest struct {
+	Layers []struct {
+		Digest      string            `json:"digest"`
+		Annotations map[string]string `json:"annotations"`
+	} `json:"layers"`
+}
+
+// simpleSigning is the part of a cosign payload naming the manifest it was signed for.
+type simpleSigning struct {
+	Critical struct {
+		Image struct {
+			DockerManifestDigest string `json:"docker-manifest-digest"`
+		} `json:"image"`
+	} `json:"critical"`
+}
+
+// signature is a payload cosign signed, and the signature made over it.
+type signature struct {
+	payload   []byte
+	signature []byte
+}
+
+// Signatures verifies the cosign signatures of images against a SignaturePolicy,
+// reading them from the registry the images are in.
+type Signatures struct {
+	client *Client
+	policy *SignaturePolicy
+}
+
+// NewSignatures returns Signatures looking signatures up through client.
+func NewSignatures(client *Client, policy *SignaturePolicy) *Signatures {
+	return &Signatures{client: client, policy: policy}
+}
+
+// Verify checks that image at reference, a digest or a tag resolved to one, carries a
+// cosign signature by one of the keys the policy requires of each of targets. It
+// returns the names of the keys whose signatures were verified, joined by ", ", and
+// the digest they were verified for; nothing when the policy requires no signature of
+// any of targets. A tag can be moved once it is verified, so it is the digest that
+// must be deployed. The error is a *SignatureError when the image is unsigned or
+// signed by none of the keys required.
+func (signatures *Signatures) Verify(ctx context.Context, targets []Target, image, reference string) (string, string, error) {
+	required := make(map[string][]string)
+	for _, target := range targets {
+		if keys := signatures.policy.KeysFor(target.App, target.Project); len(keys) > 0 {
+			required[target.App] = keys
+		}
+	}
+	if len(required) == 0 {
+		return "", "", nil
+	}
+
+	digest := reference
+	if !digestPattern.MatchString(reference) {
+		var err error
+		if digest, err = signatures.client.Resolve(ctx, image, reference); err != nil {
+			return "", "", err
+		}
+	}
+
+	found, err := signatures.client.signatures(ctx, image, digest)
+	if err != nil {
+		return "", "", err
+	}
+	apps := slices.Sorted(maps.Keys(required))
+	if len(found) == 0 {
+		return "", "", &SignatureError{Message: fmt.Sprintf("%s@%s is not signed, and application %q requires a signature by one of %s", image, digest, apps[0], strings.Join(required[apps[0]], ", "))}
+	}
+
+	var signers []string
+	for _, name := range sortedKeys(slices.Collect(maps.Values(required))) {
+		for _, candidate := range found {
+			if candidate.verify(signatures.policy.keys[name], digest) {
+				signers = append(signers, name)
+				break
+			}
+		}
+	}
+
+	for _, app := range apps {
+		if !slices.ContainsFunc(required[app], func(key string) bool { return slices.Contains(signers, key) }) {
+			return "", "", &SignatureError{Message: fmt.Sprintf("%s@%s is not signed by any of the keys application %q requires: %s", image, digest, app, strings.Join(required[app], ", "))}
+		}
+	}
+
+	slog.Debug("Verified the signature of an image", "image", image, "digest", digest, "signers", signers)
+	return strings.Join(signers, ", "), digest, nil
+}
+
+// signatures returns the cosign signatures stored for the manifest at digest in the
+// repository of image, none when it has no signature manifest.
+func (client *Client) signatures(ctx context.Context, image, digest string) ([]signature, error) {
+	name, ref, repositoryUrl, err := client.repositoryUrl(image)
+	if err != nil {
+		return nil, err
+	}
+
+	// cosign stores the signatures of sha256:<hex> under the tag sha256-<hex>.sig.
+	signatureTag := strings.Replace(digest, ":", "-", 1) + ".sig"
+	content, err := client.fetch(ctx, ref, repositoryUrl+"/manifests/"+signatureTag, signatureMediaTypes, maxManifestBytes)
+	if err != nil {
+		if errors.Is(err, ErrNotFound) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("could not read the signatures of %s@%s: %w", name, digest, err)
+	}
+
+	var manifest signatureManifest
+	if err := json.Unmarshal(content, &manifest); err != nil {
+		return nil, fmt.Errorf("could not parse the signatures of %s@%s: %w", name, digest, err)
+	}
+
+	var found []signature
+	for _, layer := range manifest.Layers {
+		encoded, ok := layer.Annotations[signatureAnnotation]
+		if !ok || !digestPattern.MatchString(layer.Digest) {
+			continue
+		}
+		decoded, err := base64.StdEncoding.DecodeString(encoded)
+		if err != nil {
+			slog.Warn("Skipping a signature that is not base64", "image", name, "digest", digest, "layer", layer.Digest)
+			continue
+		}
+
+		payload, err := client.fetch(ctx, ref, repositoryUrl+"/blobs/"+layer.Digest, nil, maxPayloadBytes)
+		if err != nil {
+			return nil, fmt.Errorf("could not read a signed payload of %s@%s: %w", name, digest, err)
+		}
+		if sum := sha256.Sum256(payload); strings.HasPrefix(layer.Digest, "sha256:") && layer.Digest != "sha256:"+hex.EncodeToString(sum[:]) {
+			slog.Warn("Skipping a signed payload that does not match its digest", "image", name, "digest", digest, "layer", layer.Digest)
+			continue
+		}
+		found = append(found, signature{payload: payload, signature: decoded})
+	}
+	return found, nil
+}
+
+// fetch returns the body of a GET of target, read up to limit bytes. The error wraps
+// ErrNotFound when the registry does not have it.
+func (client *Client) fetch(ctx context.Context, ref reference, target string, accept []string, limit int64) ([]byte, error) {
+	resp, err := client.send(ctx, ref, http.MethodGet, target, accept)
+	if err != nil {
+		return nil, err
+	}
+	defer closeBody(resp)
+
+	if err := statusError(resp); err != nil {
+		return nil, err
+	}
+	return io.ReadAll(io.LimitReader(resp.Body, limit))
+}
+
+// verify reports whether the signature was made by key over a payload signed for the
+// manifest at digest.
+func (candidate signature) verify(key crypto.PublicKey, digest string) bool {
+	var payload simpleSigning
+	if err := json.Unmarshal(candidate.payload, &payload); err != nil || payload.Critical.Image.DockerManifestDigest != digest {
+		return false
+	}
+
+	hashed := sha256.Sum256(candidate.payload)
+	switch key := key.(type) {
+	case *ecdsa.PublicKey:
+		return ecdsa.VerifyASN1(key, hashed[:], candidate.signature)
+	case *rsa.PublicKey:
+		return rsa.VerifyPKCS1v15(key, crypto.SHA256, hashed[:], candidate.signature) == nil
+	case ed25519.PublicKey:
+		return ed25519.Verify(key, candidate.payload, candidate.signature)
+	default:
+		return false
+	}
+}
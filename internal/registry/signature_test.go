@@ -0,0 +1,153 @@
+package registry
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// sign stores in registry a cosign signature manifest for the manifest at digest in
+// repository, with one signature by each of keys over a payload naming signedDigest.
+func sign(t *testing.T, registry *localRegistry, repository, digest, signedDigest string, keys ...*ecdsa.PrivateKey) {
+	t.Helper()
+	payload := `{"critical":{"identity":{"docker-reference":"` + repository + `"},"image":{"docker-manifest-digest":"` + signedDigest + `"},"type":"cosign container image signature"},"optional":null}`
+	hashed := sha256.Sum256([]byte(payload))
+	payloadDigest := manifestDigest(payload)
+	registry.blobs[repository+"@"+payloadDigest] = payload
+
+	var layers []map[string]any
+	for _, key := range keys {
+		signed, err := ecdsa.SignASN1(rand.Reader, key, hashed[:])
+		require.NoError(t, err)
+		layers = append(layers, map[string]any{
+			"mediaType":   "application/vnd.dev.cosign.simplesigning.v1+json",
+			"digest":      payloadDigest,
+			"size":        len(payload),
+			"annotations": map[string]string{signatureAnnotation: base64.StdEncoding.EncodeToString(signed)},
+		})
+	}
+	manifest, err := json.Marshal(map[string]any{
+		"schemaVersion": 2,
+		"mediaType":     "application/vnd.oci.image.manifest.v1+json",
+		"layers":        layers,
+	})
+	require.NoError(t, err)
+	registry.manifests[repository+":"+strings.Replace(digest, ":", "-", 1)+".sig"] = string(manifest)
+}
+
+func TestSignaturesVerify(t *testing.T) {
+	releaseKey, release := newSigningKey(t)
+	securityKey, security := newSigningKey(t)
+	otherKey, _ := newSigningKey(t)
+	digest := manifestDigest(indexManifest)
+
+	// setup returns a local registry holding team/app:v1, and Signatures for a policy
+	// requiring release of the payments project and security of the audited app.
+	setup := func(t *testing.T) (*localRegistry, *Signatures, string) {
+		registry := newLocalRegistry(t, map[string]string{"team/app:v1": indexManifest})
+		policy, err := LoadSignaturePolicy(writePolicy(t, `
+keys:
+  release: |
+`+release+`
+  security: |
+`+security+`
+projects:
+  payments: [release]
+applications:
+  audited: [security]
+`))
+		require.NoError(t, err)
+		return registry, NewSignatures(newTestClient(t, registry, ""), policy), registry.host() + "/team/app"
+	}
+
+	t.Run("Returns the key that signed the image a tag points to", func(t *testing.T) {
+		registry, signatures, image := setup(t)
+		sign(t, registry, "team/app", digest, digest, releaseKey)
+
+		signer, verified, err := signatures.Verify(context.Background(), []Target{{App: "api", Project: "payments"}}, image, "v1")
+
+		require.NoError(t, err)
+		assert.Equal(t, "release", signer)
+		assert.Equal(t, digest, verified, "the digest verified is the one to deploy")
+	})
+
+	t.Run("Checks a digest without resolving a tag", func(t *testing.T) {
+		registry, signatures, image := setup(t)
+		sign(t, registry, "team/app", digest, digest, releaseKey)
+
+		signer, _, err := signatures.Verify(context.Background(), []Target{{App: "api", Project: "payments"}}, image, digest)
+
+		require.NoError(t, err)
+		assert.Equal(t, "release", signer)
+		assert.NotContains(t, registry.requests, "HEAD /v2/team/app/manifests/v1")
+	})
+
+	t.Run("Holds every application to its own rule", func(t *testing.T) {
+		registry, signatures, image := setup(t)
+		sign(t, registry, "team/app", digest, digest, releaseKey, securityKey)
+
+		signer, _, err := signatures.Verify(context.Background(), []Target{{App: "api", Project: "payments"}, {App: "audited", Project: "payments"}}, image, "v1")
+
+		require.NoError(t, err)
+		assert.Equal(t, "release, security", signer)
+	})
+
+	t.Run("Requires nothing of an application no rule covers", func(t *testing.T) {
+		registry, signatures, image := setup(t)
+
+		signer, verified, err := signatures.Verify(context.Background(), []Target{{App: "api", Project: "search"}}, image, "v1")
+
+		require.NoError(t, err)
+		assert.Empty(t, signer)
+		assert.Empty(t, verified)
+		assert.Empty(t, registry.requests)
+	})
+
+	t.Run("Rejects an unsigned image", func(t *testing.T) {
+		_, signatures, image := setup(t)
+
+		_, _, err := signatures.Verify(context.Background(), []Target{{App: "api", Project: "payments"}}, image, "v1")
+
+		var signatureErr *SignatureError
+		require.True(t, errors.As(err, &signatureErr), err)
+		assert.Equal(t, image+"@"+digest+` is not signed, and application "api" requires a signature by one of release`, err.Error())
+	})
+
+	t.Run("Rejects an image signed by another key", func(t *testing.T) {
+		registry, signatures, image := setup(t)
+		sign(t, registry, "team/app", digest, digest, otherKey, releaseKey)
+
+		_, _, err := signatures.Verify(context.Background(), []Target{{App: "api", Project: "payments"}, {App: "audited", Project: "payments"}}, image, "v1")
+
+		var signatureErr *SignatureError
+		require.True(t, errors.As(err, &signatureErr), err)
+		assert.Equal(t, image+"@"+digest+` is not signed by any of the keys application "audited" requires: security`, err.Error())
+	})
+
+	t.Run("Rejects a signature made for another manifest", func(t *testing.T) {
+		registry, signatures, image := setup(t)
+		sign(t, registry, "team/app", digest, manifestDigest("another manifest"), releaseKey)
+
+		_, _, err := signatures.Verify(context.Background(), []Target{{App: "api", Project: "payments"}}, image, "v1")
+
+		var signatureErr *SignatureError
+		assert.True(t, errors.As(err, &signatureErr), err)
+	})
+
+	t.Run("Reports a missing tag as not found", func(t *testing.T) {
+		_, signatures, image := setup(t)
+
+		_, _, err := signatures.Verify(context.Background(), []Target{{App: "api", Project: "payments"}}, image, "v2")
+
+		assert.ErrorIs(t, err, ErrNotFound)
+	})
+}
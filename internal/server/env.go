@@ -12,7 +12,9 @@ import (
 	"github.com/shini4i/argo-watcher/internal/auth"
 	"github.com/shini4i/argo-watcher/internal/config"
 	"github.com/shini4i/argo-watcher/internal/lock"
+	"github.com/shini4i/argo-watcher/internal/models"
 	"github.com/shini4i/argo-watcher/internal/prometheus"
+	"github.com/shini4i/argo-watcher/internal/registry"
 )
 
 // Env reference: https://www.alexedwards.net/blog/organising-database-access
@@ -24,6 +26,18 @@ type Env struct {
 	lockdown      *Lockdown
 	strategies    map[string]auth.AuthStrategy
 	authenticator *auth.Authenticator
+	// digests resolves the tags of submitted images to digests; nil unless
+	// PIN_IMAGE_DIGESTS is on.
+	digests digestResolver
+	// images verifies that the tags of submitted images exist; nil unless
+	// VERIFY_IMAGES is on.
+	images imageVerifier
+	// signatures verifies the cosign signatures of submitted images; nil unless
+	// COSIGN_POLICY_PATH is set.
+	signatures signatureVerifier
+	// admission judges submitted tasks against the rules of the admission policy; nil
+	// unless ADMISSION_POLICY_PATH is set.
+	admission admissionPolicy
 	// shutdownCh is closed to signal graceful shutdown to all WebSocket goroutines.
 	shutdownCh chan struct{}
 	// draining is set once graceful shutdown begins, so the readiness probe can
@@ -183,9 +197,37 @@ func (env *Env) Shutdown(ctx context.Context) {
 	}
 }
 
+// digestResolver resolves the tag of an image to the digest of its manifest
+// (see registry.Client).
+type digestResolver interface {
+	Resolve(ctx context.Context, image, tag string) (string, error)
+}
+
+// imageVerifier checks that the registry of an image has a manifest for a tag
+// (see registry.Client).
+type imageVerifier interface {
+	Verify(ctx context.Context, image, tag string) error
+}
+
+// signatureVerifier checks that an image carries the cosign signatures the policy
+// requires of the applications it is deployed to, and returns the digest it checked
+// (see registry.Signatures).
+type signatureVerifier interface {
+	Verify(ctx context.Context, targets []registry.Target, image, reference string) (string, string, error)
+}
+
+// admissionPolicy returns the rules a task breaks when deployed to an application
+// (see admission.Policy).
+type admissionPolicy interface {
+	Evaluate(task models.Task, app *models.Application) ([]string, error)
+}
+
 // NewEnv wires up an Env from the server config: lockdown schedules backed by the
-// given deploy lock store, and the enabled auth strategies.
-func NewEnv(serverConfig *config.ServerConfig, argo *argocd.Argo, metrics *prometheus.Metrics, updater *argocd.ArgoStatusUpdater, deployLockStore lock.DeployLockStore) (*Env, error) {
+// given deploy lock store, the enabled auth strategies, digests, which pins the
+// digests of submitted images unless nil, images, which verifies they exist unless
+// nil, signatures, which verifies their signatures unless nil, and admission, which
+// judges submitted tasks unless nil.
+func NewEnv(serverConfig *config.ServerConfig, argo *argocd.Argo, metrics *prometheus.Metrics, updater *argocd.ArgoStatusUpdater, deployLockStore lock.DeployLockStore, digests digestResolver, images imageVerifier, signatures signatureVerifier, admission admissionPolicy) (*Env, error) {
 	var env *Env
 	var err error
 
@@ -194,6 +236,10 @@ func NewEnv(serverConfig *config.ServerConfig, argo *argocd.Argo, metrics *prome
 		argo:       argo,
 		metrics:    metrics,
 		updater:    updater,
+		digests:    digests,
+		images:     images,
+		signatures: signatures,
+		admission:  admission,
 		shutdownCh: make(chan struct{}),
 	}
 
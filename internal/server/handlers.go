@@ -1,16 +1,23 @@
 package server
 
 import (
+	"context"
 	"errors"
+	"fmt"
 	"log/slog"
 	"net/http"
+	"regexp"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/go-chi/chi/v5"
 
+	"github.com/shini4i/argo-watcher/internal/admission"
+	"github.com/shini4i/argo-watcher/internal/argocd"
 	"github.com/shini4i/argo-watcher/internal/auth"
 	"github.com/shini4i/argo-watcher/internal/models"
+	"github.com/shini4i/argo-watcher/internal/registry"
 	"github.com/shini4i/argo-watcher/internal/state"
 )
 
@@ -50,9 +57,11 @@ func (env *Env) getVersion(w http.ResponseWriter, _ *http.Request) {
 // @Accept json
 // @Produce json
 // @Param task body models.Task true "Task"
-// @Success 202 {object} models.TaskStatus
+// @Success 200 {object} models.WriteBackPreview "dry run: the git change the deployment would write back; no task is created"
+// @Success 202 {object} models.TaskStatus "a single task; a deployment addressed to a selector or ApplicationSet returns a models.TaskGroup instead"
 // @Failure 401 {object} models.TaskStatus
 // @Failure 406 {object} models.TaskStatus
+// @Failure 503 {object} models.TaskStatus
 // @Router /api/v1/tasks [post]
 func (env *Env) addTask(w http.ResponseWriter, r *http.Request) {
 	var task models.Task
@@ -67,8 +76,41 @@ func (env *Env) addTask(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// reject deploys while a lockdown (manual or scheduled) is active
-	if env.lockdown.IsLocked() {
+	if err := validateGroupTarget(task); err != nil {
+		writeJSON(w, http.StatusNotAcceptable, models.TaskStatus{
+			Status: "invalid payload",
+			Error:  err.Error(),
+		})
+		return
+	}
+
+	if err := validateParameters(task); err != nil {
+		writeJSON(w, http.StatusNotAcceptable, models.TaskStatus{
+			Status: "invalid payload",
+			Error:  err.Error(),
+		})
+		return
+	}
+
+	if err := validateChartVersion(task); err != nil {
+		writeJSON(w, http.StatusNotAcceptable, models.TaskStatus{
+			Status: "invalid payload",
+			Error:  err.Error(),
+		})
+		return
+	}
+
+	if err := validateImages(task); err != nil {
+		writeJSON(w, http.StatusNotAcceptable, models.TaskStatus{
+			Status: "invalid payload",
+			Error:  err.Error(),
+		})
+		return
+	}
+
+	// reject deploys while a lockdown (manual or scheduled) is active; a dry run
+	// deploys nothing, so it is still answered
+	if !task.DryRun && env.lockdown.IsLocked() {
 		slog.Warn("deploy lock is set, rejecting the task")
 		writeJSON(w, http.StatusNotAcceptable, models.TaskStatus{
 			Status: "rejected",
@@ -91,6 +133,44 @@ func (env *Env) addTask(w http.ResponseWriter, r *http.Request) {
 	}
 
 	task.Validated = tokenValid
+	if env.config.PreventDowngrades {
+		task.Privileged = env.isPrivileged(r)
+	}
+
+	// The applications are looked up once, so the signatures are verified for, the
+	// admission policy judges, and a group's tasks are created for the same applications.
+	apps, err := env.resolveTargets(r.Context(), task)
+	if err != nil {
+		refuseTask(w, "failed to look the applications of the task up in ArgoCD", err)
+		return
+	}
+
+	// Pinned and verified before a dry run too, so the preview shows the digests
+	// written back and a typo in a tag or a missing signature is caught without
+	// deploying anything.
+	err = env.pinDigests(r.Context(), &task)
+	if err == nil {
+		err = env.verifyImages(r.Context(), task)
+	}
+	if err == nil {
+		err = env.verifySignatures(r.Context(), &task, apps)
+	}
+	if err != nil {
+		refuseTask(w, "failed to look images up in the registry", err)
+		return
+	}
+
+	// Admitted last, so the rules see the task as it is stored, digests and signers
+	// included.
+	if err := env.admit(task, apps); err != nil {
+		refuseTask(w, "failed to evaluate the admission policy", err)
+		return
+	}
+
+	if task.DryRun {
+		env.previewTask(w, task)
+		return
+	}
 
 	// Resolve the rollout window now, while this replica's configuration is the one
 	// that applies. Left at zero it would mean "whatever the default is", and a task
@@ -101,8 +181,22 @@ func (env *Env) addTask(w http.ResponseWriter, r *http.Request) {
 		task.Timeout = int(env.config.DeploymentTimeout)
 	}
 
+	if task.IsGroupTarget() {
+		env.addTaskGroup(w, task, apps)
+		return
+	}
+
 	newTask, err := env.argo.AddTask(task)
 	if err != nil {
+		var downgradeErr *argocd.DowngradeError
+		if errors.As(err, &downgradeErr) {
+			slog.Warn("rejecting task", "error", err)
+			writeJSON(w, http.StatusNotAcceptable, models.TaskStatus{
+				Status: "rejected",
+				Error:  err.Error(),
+			})
+			return
+		}
 		slog.Error("failed to add task", "error", err)
 		writeJSON(w, http.StatusServiceUnavailable, models.TaskStatus{
 			Status: "down",
@@ -119,6 +213,319 @@ func (env *Env) addTask(w http.ResponseWriter, r *http.Request) {
 	})
 }
 
+// previewTask answers a dry run with the git change the deployment would write back.
+// The preview reads the GitOps repository with the watcher's own credentials, so it
+// asks for the same valid credential the write-back itself needs.
+func (env *Env) previewTask(w http.ResponseWriter, task models.Task) {
+	if !task.Validated {
+		writeJSON(w, http.StatusUnauthorized, models.TaskStatus{
+			Status: unauthorizedMessage,
+			Error:  "a dry run requires a valid credential",
+		})
+		return
+	}
+
+	preview, err := env.updater.PreviewWriteBack(task)
+	if err != nil {
+		var previewErr *argocd.PreviewError
+		if errors.As(err, &previewErr) {
+			slog.Warn("rejecting dry run", "error", err)
+			writeJSON(w, http.StatusNotAcceptable, models.TaskStatus{
+				Status: "rejected",
+				Error:  err.Error(),
+			})
+			return
+		}
+		slog.Error("failed to preview the write-back", "error", err)
+		writeJSON(w, http.StatusServiceUnavailable, models.TaskStatus{
+			Status: "down",
+			Error:  err.Error(),
+		})
+		return
+	}
+
+	writeJSON(w, http.StatusOK, preview)
+}
+
+// validateGroupTarget rejects a payload that mixes the two ways of addressing a
+// deployment, asks for a preview of a group deployment, which is of one application's
+// write-back, or asks for a negative number of parallel rollouts.
+func validateGroupTarget(task models.Task) error {
+	if task.IsGroupTarget() && task.App != "" {
+		return errors.New("app cannot be combined with selector or application_set")
+	}
+	if task.IsGroupTarget() && task.DryRun {
+		return errors.New("dry_run cannot be combined with selector or application_set")
+	}
+	if task.MaxParallel < 0 {
+		return errors.New("max_parallel cannot be negative")
+	}
+	return nil
+}
+
+// validateParameters rejects a task setting one parameter twice, which would leave the
+// value written back up to the order the parameters happen to be applied in. Whether
+// the application allows them is only known once ArgoCD is asked, at write-back.
+func validateParameters(task models.Task) error {
+	seen := map[string]bool{}
+	for _, parameter := range task.Parameters {
+		name := strings.TrimSpace(parameter.Name)
+		if name != parameter.Name {
+			return fmt.Errorf("parameter name %q has leading or trailing whitespace", parameter.Name)
+		}
+		if seen[name] {
+			return fmt.Errorf("parameter %q is set more than once", name)
+		}
+		seen[name] = true
+	}
+	return nil
+}
+
+// semverPattern matches a semantic version as SemVer 2.0 defines it, optionally
+// prefixed with "v" as Helm accepts chart versions.
+var semverPattern = regexp.MustCompile(`^v?(0|[1-9]\d*)\.(0|[1-9]\d*)\.(0|[1-9]\d*)` +
+	`(-(0|[1-9]\d*|\d*[a-zA-Z-][0-9a-zA-Z-]*)(\.(0|[1-9]\d*|\d*[a-zA-Z-][0-9a-zA-Z-]*))*)?` +
+	`(\+[0-9a-zA-Z-]+(\.[0-9a-zA-Z-]+)*)?$`)
+
+// validateChartVersion rejects a chart version that is not a semantic version, and a
+// chart version task that also carries images or parameters: its write-back goes to
+// the application's manifest, where neither has a place. Whether the application
+// deploys a chart is only known at write-back.
+func validateChartVersion(task models.Task) error {
+	if task.ChartVersion == "" {
+		return nil
+	}
+	if strings.TrimSpace(task.ChartVersion) != task.ChartVersion {
+		return fmt.Errorf("chart version %q has leading or trailing whitespace", task.ChartVersion)
+	}
+	if !semverPattern.MatchString(task.ChartVersion) {
+		return fmt.Errorf("chart version %q is not a semantic version", task.ChartVersion)
+	}
+	if len(task.Images) > 0 {
+		return errors.New("chart_version cannot be combined with images")
+	}
+	if len(task.Parameters) > 0 {
+		return errors.New("chart_version cannot be combined with parameters")
+	}
+	return nil
+}
+
+// validateImages rejects an image tag that is not one: the tag is written into the
+// files of the GitOps repository as it is, where anything but a tag could change
+// more than the value it replaces.
+func validateImages(task models.Task) error {
+	for _, image := range task.Images {
+		if !registry.IsValidTag(image.Tag) {
+			return fmt.Errorf("tag %q of image %s is not a valid image tag", image.Tag, image.Image)
+		}
+	}
+	return nil
+}
+
+// pinDigests resolves the tag of every image of task to the digest of its manifest,
+// when digest pinning is on. A digest is only ever the server's: one sent by the
+// client is dropped, so what is written back is what the registry served.
+func (env *Env) pinDigests(ctx context.Context, task *models.Task) error {
+	for index := range task.Images {
+		task.Images[index].Digest = ""
+	}
+	if env.digests == nil {
+		return nil
+	}
+
+	for index := range task.Images {
+		digest, err := env.digests.Resolve(ctx, task.Images[index].Image, task.Images[index].Tag)
+		if err != nil {
+			return err
+		}
+		task.Images[index].Digest = digest
+	}
+	return nil
+}
+
+// verifyImages checks that the registry has every image tag of task, when image
+// verification is on, so a task that could only end in ImagePullBackOff is refused
+// up front. Pinned digests were resolved from those very tags and need no second look.
+func (env *Env) verifyImages(ctx context.Context, task models.Task) error {
+	if env.images == nil || env.digests != nil {
+		return nil
+	}
+
+	for _, image := range task.Images {
+		if err := env.images.Verify(ctx, image.Image, image.Tag); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// resolveTargets returns the applications task deploys to, as ArgoCD reports them:
+// every application a group deployment addresses, or the one a task names. A single
+// application ArgoCD does not have is left out, and is only looked up at all when a
+// policy needs it. Any other failure is returned: the policies would otherwise be
+// applied without the project the application is in.
+func (env *Env) resolveTargets(ctx context.Context, task models.Task) ([]models.Application, error) {
+	if task.IsGroupTarget() {
+		return env.argo.GroupApplications(task)
+	}
+	if env.signatures == nil && env.admission == nil {
+		return nil, nil
+	}
+
+	app, err := env.argo.GetApplication(ctx, task.App)
+	if err != nil {
+		var apiErr *argocd.ArgoAPIError
+		if errors.As(err, &apiErr) && (apiErr.StatusCode == http.StatusNotFound || task.IsAppNotFoundError(err)) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return []models.Application{*app}, nil
+}
+
+// verifySignatures checks the cosign signatures of every image of task against the
+// signature policy, when there is one, and records on each image the keys whose
+// signatures were verified. A deployment is held to the policy of every application
+// of apps, in the project ArgoCD puts it in; the project the task names is the
+// submitter's to choose and decides nothing. An application ArgoCD does not know is
+// held to the rules of its name alone. A signer is only ever the server's: one sent
+// by the client is dropped. An image whose signature was verified is pinned to the
+// digest verified, whether or not digest pinning is on.
+func (env *Env) verifySignatures(ctx context.Context, task *models.Task, apps []models.Application) error {
+	for index := range task.Images {
+		task.Images[index].Signer = ""
+	}
+	if env.signatures == nil || len(task.Images) == 0 {
+		return nil
+	}
+
+	targets := make([]registry.Target, 0, len(apps))
+	for index := range apps {
+		targets = append(targets, registry.Target{App: apps[index].Metadata.Name, Project: apps[index].Spec.Project})
+	}
+	if len(targets) == 0 && !task.IsGroupTarget() {
+		targets = append(targets, registry.Target{App: task.App})
+	}
+
+	for index := range task.Images {
+		image := &task.Images[index]
+		// A pinned digest is what is written back, so it is the one that must be signed.
+		reference := image.Tag
+		if image.Digest != "" {
+			reference = image.Digest
+		}
+		signer, digest, err := env.signatures.Verify(ctx, targets, image.Image, reference)
+		if err != nil {
+			return err
+		}
+		image.Signer = signer
+		// The tag could be moved to another manifest before the rollout; the digest
+		// verified is what is written back.
+		if digest != "" {
+			image.Digest = digest
+		}
+	}
+	return nil
+}
+
+// admit judges task against the admission policy, when there is one, and returns an
+// *admission.RejectedError listing every rule it breaks. A group deployment is judged
+// once per application of apps, as the task that application would get, and its
+// violations name the application. A task whose application ArgoCD does not know, or
+// will not show, is judged with null.
+func (env *Env) admit(task models.Task, apps []models.Application) error {
+	if env.admission == nil {
+		return nil
+	}
+
+	var violations []string
+	if !task.IsGroupTarget() {
+		var app *models.Application
+		if len(apps) > 0 {
+			app = &apps[0]
+		}
+		found, err := env.admission.Evaluate(task, app)
+		if err != nil {
+			return err
+		}
+		violations = found
+	} else {
+		for index := range apps {
+			child := task
+			child.App = apps[index].Metadata.Name
+			found, err := env.admission.Evaluate(child, &apps[index])
+			if err != nil {
+				return err
+			}
+			for _, violation := range found {
+				violations = append(violations, child.App+": "+violation)
+			}
+		}
+	}
+
+	if len(violations) > 0 {
+		return &admission.RejectedError{Violations: violations}
+	}
+	return nil
+}
+
+// refuseTask answers a task that a check before it is stored did not let through:
+// with 406 when the task itself is at fault, and with 503, logging failure, when the
+// check could not be completed.
+func refuseTask(w http.ResponseWriter, failure string, err error) {
+	var signatureErr *registry.SignatureError
+	var targetErr *argocd.GroupTargetError
+	var rejectedErr *admission.RejectedError
+	if errors.Is(err, registry.ErrNotFound) || errors.As(err, &signatureErr) || errors.As(err, &targetErr) || errors.As(err, &rejectedErr) {
+		slog.Warn("rejecting task", "error", err)
+		status := models.TaskStatus{
+			Status: "rejected",
+			Error:  err.Error(),
+		}
+		if rejectedErr != nil {
+			status.Violations = rejectedErr.Violations
+		}
+		writeJSON(w, http.StatusNotAcceptable, status)
+		return
+	}
+	slog.Error(failure, "error", err)
+	writeJSON(w, http.StatusServiceUnavailable, models.TaskStatus{
+		Status: "down",
+		Error:  err.Error(),
+	})
+}
+
+// addTaskGroup creates the tasks of a deployment addressed to a label selector or
+// an ApplicationSet, one for each of apps, and starts monitoring them as a group. A
+// target that matches nothing is the submitter's mistake and is rejected with 406;
+// any other failure is reported like a single task that could not be added.
+func (env *Env) addTaskGroup(w http.ResponseWriter, task models.Task, apps []models.Application) {
+	group, err := env.argo.AddTaskGroup(task, apps)
+	if err != nil {
+		var targetErr *argocd.GroupTargetError
+		var downgradeErr *argocd.DowngradeError
+		if errors.As(err, &targetErr) || errors.As(err, &downgradeErr) {
+			slog.Warn("rejecting group deployment", "error", err)
+			writeJSON(w, http.StatusNotAcceptable, models.TaskGroup{
+				Status: "rejected",
+				Error:  err.Error(),
+			})
+			return
+		}
+		slog.Error("failed to add group deployment", "error", err)
+		writeJSON(w, http.StatusServiceUnavailable, models.TaskGroup{
+			Status: "down",
+			Error:  err.Error(),
+		})
+		return
+	}
+
+	go env.updater.WaitForGroup(group.Tasks, task.MaxParallel)
+
+	group.Status = models.StatusAccepted
+	writeJSON(w, http.StatusAccepted, group)
+}
+
 // getState godoc
 // @Summary Get state content
 // @Description Get all tasks that match the provided parameters
@@ -206,17 +613,55 @@ func (env *Env) getTaskStatus(w http.ResponseWriter, r *http.Request) {
 	} else {
 		setTaskApp(r, task.MetricApp())
 		writeJSON(w, http.StatusOK, models.TaskStatus{
-			Id:           task.Id,
-			Created:      task.Created,
-			Updated:      task.Updated,
-			App:          task.App,
-			Author:       task.Author,
-			Project:      task.Project,
-			Images:       task.Images,
-			Status:       task.Status,
-			StatusReason: task.StatusReason,
+			Id:               task.Id,
+			Created:          task.Created,
+			Updated:          task.Updated,
+			App:              task.App,
+			Author:           task.Author,
+			Project:          task.Project,
+			Images:           task.Images,
+			Status:           task.Status,
+			StatusReason:     task.StatusReason,
+			Parameters:       task.Parameters,
+			ChartVersion:     task.ChartVersion,
+			IsRollback:       task.IsRollback,
+			RollbackTargetId: task.RollbackTargetId,
+			RollbackOfId:     task.RollbackOfId,
+			WriteBack:        task.WriteBack,
+		})
+	}
+}
+
+// getTaskGroup godoc
+// @Summary Get the status of a group deployment
+// @Description Get the tasks of a deployment submitted for a label selector or an ApplicationSet, and their combined status
+// @Param id path string true "Group id"
+// @Tags backend
+// @Produce json
+// @Success 200 {object} models.TaskGroup
+// @Failure 404 {object} models.TaskGroup
+// @Failure 500 {object} models.TaskGroup
+// @Router /api/v1/groups/{id} [get]
+func (env *Env) getTaskGroup(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "id")
+	group, err := env.argo.GetTaskGroup(id)
+	if err != nil {
+		if errors.Is(err, state.ErrTaskNotFound) {
+			writeJSON(w, http.StatusNotFound, models.TaskGroup{
+				Id:    id,
+				Error: "group not found",
+			})
+			return
+		}
+		slog.Error("failed to retrieve group", "id", id, "error", err)
+		writeJSON(w, http.StatusInternalServerError, models.TaskGroup{
+			Id:    id,
+			Error: "internal server error",
 		})
+		return
 	}
+
+	writeJSON(w, http.StatusOK, group)
 }
 
 // Causes reported by the readiness probe. They are the response body only; the
@@ -321,6 +766,22 @@ func (env *Env) requireOIDCAuth(w http.ResponseWriter, r *http.Request) bool {
 	return false
 }
 
+// isPrivileged reports whether the request carries the OIDC credential the deploy-lock
+// writes require: a valid token of a member of OIDC_PRIVILEGED_GROUPS, when that list
+// is set. A provider that cannot be consulted makes the request unprivileged rather
+// than failing it.
+func (env *Env) isPrivileged(r *http.Request) bool {
+	if !env.config.OIDC.Enabled {
+		return false
+	}
+	for _, header := range []string{oidcHeader, legacyKeycloakHeader} {
+		if valid, _ := env.validateToken(r, header); valid {
+			return true
+		}
+	}
+	return false
+}
+
 // requireAuthenticatedRead returns middleware that rejects reads carrying no valid
 // credential once OIDC auth is enabled; with OIDC disabled it is a no-op.
 //
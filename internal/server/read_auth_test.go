@@ -73,6 +73,7 @@ func readAuthEnvWithTask(t *testing.T, oidcEnabled bool, strategies map[string]a
 	} else {
 		repo.EXPECT().GetTask(gomock.Any()).Return(nil, state.ErrTaskNotFound).AnyTimes()
 	}
+	repo.EXPECT().GetGroupTasks(gomock.Any()).Return([]models.Task{}, nil).AnyTimes()
 	metrics := prometheus.NewMetrics(promclient.NewRegistry())
 	argo := &argocd.Argo{}
 	argo.Init(repo, newArgoAPI(ctrl), metrics)
@@ -294,8 +295,9 @@ func TestReadAuthOpenEndpoints(t *testing.T) {
 // fails here without anyone remembering to extend a test.
 func TestReadAuthCoversEveryRegisteredRead(t *testing.T) {
 	openByDesign := map[string]bool{
-		"/api/v1/config":     true,
-		"/api/v1/tasks/{id}": true,
+		"/api/v1/config":      true,
+		"/api/v1/tasks/{id}":  true,
+		"/api/v1/groups/{id}": true,
 	}
 
 	env, _ := readAuthEnv(t, true, map[string]auth.AuthStrategy{
@@ -0,0 +1,18 @@
+package server
+
+// StartRepoCacheJanitor launches a background goroutine that keeps the repository
+// cache under REPO_CACHE_PATH within REPO_CACHE_MAX_SIZE_MB and REPO_CACHE_MAX_AGE.
+// Without it the cache keeps a clone of every repository and branch ever written
+// back to, for as long as the volume lasts.
+//
+// The goroutine is tracked by connWg and stops when the shutdown channel is closed.
+func (env *Env) StartRepoCacheJanitor() {
+	if env.updater == nil {
+		return
+	}
+	env.connWg.Add(1)
+	go func() {
+		defer env.connWg.Done()
+		env.updater.RunRepoCacheJanitor(env.shutdownCh)
+	}()
+}
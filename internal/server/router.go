@@ -72,7 +72,8 @@ func (env *Env) CreateRouter() *chi.Mux {
 	//   - GET /tasks/{id} is exempt while OIDC_REQUIRE_TASK_READ_AUTH is off, so a
 	//     client polling it without a credential keeps working; the v4 UUID is the
 	//     capability and the enumerable list is protected. Setting that variable moves
-	//     the lookup under the same gate as every other read.
+	//     the lookup under the same gate as every other read. GET /groups/{id} is the
+	//     same lookup for a group deployment, and follows the same rule.
 	//   - POST/DELETE /deploy-lock enforce privileged membership themselves, and are
 	//     registered only under OIDC so they are never an open deploy-freeze switch.
 	requireAuth := env.requireAuthenticatedRead()
@@ -82,8 +83,10 @@ func (env *Env) CreateRouter() *chi.Mux {
 
 		if env.config.OIDC.RequireTaskReadAuth {
 			r.With(requireAuth).Get("/tasks/{id}", env.getTaskStatus)
+			r.With(requireAuth).Get("/groups/{id}", env.getTaskGroup)
 		} else {
 			r.With(env.countUnauthenticatedRead()).Get("/tasks/{id}", env.getTaskStatus)
+			r.With(env.countUnauthenticatedRead()).Get("/groups/{id}", env.getTaskGroup)
 		}
 
 		r.With(requireAuth).Get("/tasks", env.getState)
@@ -3,6 +3,7 @@ package server
 import (
 	"bytes"
 	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"log/slog"
@@ -30,6 +31,7 @@ import (
 	"github.com/shini4i/argo-watcher/internal/mocks"
 	"github.com/shini4i/argo-watcher/internal/models"
 	"github.com/shini4i/argo-watcher/internal/prometheus"
+	"github.com/shini4i/argo-watcher/internal/registry"
 	"github.com/shini4i/argo-watcher/internal/state"
 )
 
@@ -284,7 +286,7 @@ func TestNewEnv(t *testing.T) {
 	metrics := &prometheus.Metrics{}
 	updater := &argocd.ArgoStatusUpdater{}
 
-	env, err := NewEnv(serverConfig, argo, metrics, updater, lock.NewInMemoryDeployLockStore())
+	env, err := NewEnv(serverConfig, argo, metrics, updater, lock.NewInMemoryDeployLockStore(), nil, nil, nil, nil)
 
 	assert.NoError(t, err)
 	assert.Equal(t, env.config, serverConfig)
@@ -319,7 +321,7 @@ func TestNewEnvInvalidOIDCURL(t *testing.T) {
 		},
 	}
 
-	env, err := NewEnv(serverConfig, &argocd.Argo{}, &prometheus.Metrics{}, &argocd.ArgoStatusUpdater{}, lock.NewInMemoryDeployLockStore())
+	env, err := NewEnv(serverConfig, &argocd.Argo{}, &prometheus.Metrics{}, &argocd.ArgoStatusUpdater{}, lock.NewInMemoryDeployLockStore(), nil, nil, nil, nil)
 
 	assert.Error(t, err)
 	assert.Contains(t, err.Error(), "failed to initialize OIDC auth")
@@ -1554,6 +1556,43 @@ func TestGetTaskStatusEndpoint(t *testing.T) {
 		assert.Contains(t, w.Body.String(), "test-app")
 	})
 
+	t.Run("returns what the task view shows", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		repo, _ := newRepo(ctrl)
+		repo.EXPECT().GetTask(gomock.Any()).Return(&models.Task{
+			Id:               "rollback-id",
+			App:              "test-app",
+			Parameters:       []models.Parameter{{Name: "features.checkout", Value: "true"}},
+			ChartVersion:     "1.4.0",
+			IsRollback:       true,
+			RollbackTargetId: "target-id",
+			RollbackOfId:     "failed-id",
+			WriteBack:        &models.WriteBack{CommitSha: "3f786850e387550fdab836ed7e6dc881de23001b"},
+		}, nil)
+		argo := &argocd.Argo{}
+		argo.Init(repo, newArgoAPI(ctrl), newMetrics(ctrl))
+
+		env := &Env{argo: argo}
+
+		router := chi.NewRouter()
+		router.Get("/api/v1/tasks/{id}", env.getTaskStatus)
+
+		req, _ := http.NewRequest(http.MethodGet, "/api/v1/tasks/rollback-id", nil)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		require.Equal(t, http.StatusOK, w.Code)
+		var status models.TaskStatus
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &status))
+		assert.Equal(t, []models.Parameter{{Name: "features.checkout", Value: "true"}}, status.Parameters)
+		assert.Equal(t, "1.4.0", status.ChartVersion)
+		assert.True(t, status.IsRollback)
+		assert.Equal(t, "target-id", status.RollbackTargetId)
+		assert.Equal(t, "failed-id", status.RollbackOfId)
+		require.NotNil(t, status.WriteBack)
+		assert.Equal(t, "3f786850e387550fdab836ed7e6dc881de23001b", status.WriteBack.CommitSha)
+	})
+
 	t.Run("returns 404 when task not found", func(t *testing.T) {
 		ctrl := gomock.NewController(t)
 		repo, _ := newRepo(ctrl)
@@ -2533,3 +2572,864 @@ func TestAddTaskResolvesTheDeploymentWindow(t *testing.T) {
 		})
 	}
 }
+
+// The fields the server writes are dropped from a submission, whatever it sends.
+func TestAddTaskDropsServerOwnedFields(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	tests := []struct {
+		name        string
+		requestJSON string
+		check       func(t *testing.T, stored models.Task)
+	}{
+		{
+			name:        "a status and a group are for the group deployment to set",
+			requestJSON: `{"app":"test-app","author":"a","project":"p","status":"queued","group_id":"someone-elses-group","images":[{"image":"test","tag":"v1"}]}`,
+			check: func(t *testing.T, stored models.Task) {
+				assert.Empty(t, stored.Status, "the task is stored in progress, not queued")
+				assert.Empty(t, stored.GroupId)
+			},
+		},
+		{
+			name:        "a write-back is only the one the task makes",
+			requestJSON: `{"app":"test-app","author":"a","project":"p","write_back":{"commit_sha":"0123456789abcdef"},"images":[{"image":"test","tag":"v1"}]}`,
+			check: func(t *testing.T, stored models.Task) {
+				assert.Nil(t, stored.WriteBack)
+			},
+		},
+		{
+			name:        "only an automatic rollback reverts a failed task",
+			requestJSON: `{"app":"test-app","author":"a","project":"p","rollback_of_id":"failed-id","images":[{"image":"test","tag":"v1"}]}`,
+			check: func(t *testing.T, stored models.Task) {
+				assert.Empty(t, stored.RollbackOfId, "the task is still rolled back should it fail")
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			stateMock := mocks.NewMockTaskRepository(ctrl)
+			metricsMock := mocks.NewMockMetricsInterface(ctrl)
+
+			argo := &argocd.Argo{}
+			argo.Init(stateMock, mocks.NewMockArgoApiInterface(ctrl), metricsMock)
+
+			stateMock.EXPECT().GetTasks(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).
+				Return([]models.Task{}, int64(0)).AnyTimes()
+			stateMock.EXPECT().CancelInProgressTasks(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).
+				Return(int64(0), nil).AnyTimes()
+
+			var stored models.Task
+			stateMock.EXPECT().AddTask(gomock.Any()).DoAndReturn(func(task models.Task) (*models.Task, error) {
+				stored = task
+				return nil, errors.New("stop before the rollout goroutine")
+			})
+
+			lockdown, err := NewLockdown("", lock.NewInMemoryDeployLockStore())
+			require.NoError(t, err)
+
+			env := &Env{
+				argo:     argo,
+				lockdown: lockdown,
+				config:   &config.ServerConfig{DeploymentTimeout: 900},
+			}
+
+			router := chi.NewRouter()
+			router.Post("/api/v1/tasks", env.addTask)
+
+			req, _ := http.NewRequest(http.MethodPost, "/api/v1/tasks", strings.NewReader(tt.requestJSON))
+			req.Header.Set("Content-Type", "application/json")
+			router.ServeHTTP(httptest.NewRecorder(), req)
+
+			require.Equal(t, "test-app", stored.App, "the task reaches the repository")
+			tt.check(t, stored)
+		})
+	}
+}
+
+func TestAddTaskParameters(t *testing.T) {
+	post := func(body string) *httptest.ResponseRecorder {
+		router := chi.NewRouter()
+		router.Post("/api/v1/tasks", (&Env{}).addTask)
+
+		req, _ := http.NewRequest(http.MethodPost, "/api/v1/tasks", strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	for _, tc := range []struct {
+		name       string
+		parameters string
+		want       string
+	}{
+		{"a parameter needs a name", `[{"value":"true"}]`, "invalid payload"},
+		{"a parameter cannot be set twice", `[{"name":"features.checkout","value":"true"},{"name":"features.checkout","value":"false"}]`, `parameter \"features.checkout\" is set more than once`},
+		{"a parameter name cannot be padded", `[{"name":" features.checkout","value":"true"}]`, "leading or trailing whitespace"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			w := post(`{"app":"api","author":"a","project":"p","images":[{"image":"test","tag":"v1"}],"parameters":` + tc.parameters + `}`)
+
+			assert.Equal(t, http.StatusNotAcceptable, w.Code)
+			assert.Contains(t, w.Body.String(), tc.want)
+		})
+	}
+}
+
+func TestAddTaskImageTags(t *testing.T) {
+	post := func(body string) *httptest.ResponseRecorder {
+		router := chi.NewRouter()
+		router.Post("/api/v1/tasks", (&Env{}).addTask)
+
+		req, _ := http.NewRequest(http.MethodPost, "/api/v1/tasks", strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	// As sent in the JSON payload.
+	for _, tag := range []string{"", `v1\nreplicaCount: 0`, "v1 # comment", "v1: true", ".v1", `v1\u0000`} {
+		t.Run(tag, func(t *testing.T) {
+			w := post(`{"app":"api","author":"a","project":"p","images":[{"image":"test","tag":"` + tag + `"}]}`)
+
+			assert.Equal(t, http.StatusNotAcceptable, w.Code)
+			assert.Contains(t, w.Body.String(), "is not a valid image tag")
+		})
+	}
+}
+
+func TestAddTaskChartVersion(t *testing.T) {
+	post := func(body string) *httptest.ResponseRecorder {
+		router := chi.NewRouter()
+		router.Post("/api/v1/tasks", (&Env{}).addTask)
+
+		req, _ := http.NewRequest(http.MethodPost, "/api/v1/tasks", strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	for _, tc := range []struct {
+		name string
+		body string
+		want string
+	}{
+		{"a task needs images or a chart version", `{"app":"api","author":"a","project":"p"}`, "invalid payload"},
+		{"a chart version cannot come with images", `{"app":"api","author":"a","project":"p","chart_version":"1.4.0","images":[{"image":"test","tag":"v1"}]}`, "chart_version cannot be combined with images"},
+		{"a chart version cannot come with parameters", `{"app":"api","author":"a","project":"p","chart_version":"1.4.0","parameters":[{"name":"features.checkout","value":"true"}]}`, "chart_version cannot be combined with parameters"},
+		{"a chart version cannot be padded", `{"app":"api","author":"a","project":"p","chart_version":"1.4.0 "}`, "leading or trailing whitespace"},
+		{"a chart version is a semantic version", `{"app":"api","author":"a","project":"p","chart_version":"1.4.0\nmalicious: true"}`, "is not a semantic version"},
+		{"a chart version is a full semantic version", `{"app":"api","author":"a","project":"p","chart_version":"1.4"}`, "is not a semantic version"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			w := post(tc.body)
+
+			assert.Equal(t, http.StatusNotAcceptable, w.Code)
+			assert.Contains(t, w.Body.String(), tc.want)
+		})
+	}
+}
+
+// fakeDigests resolves tags from its map, keyed by "image:tag", and fails every other
+// lookup with err, or registry.ErrNotFound.
+type fakeDigests struct {
+	digests map[string]string
+	err     error
+}
+
+func (fake *fakeDigests) Resolve(_ context.Context, image, tag string) (string, error) {
+	if digest, ok := fake.digests[image+":"+tag]; ok {
+		return digest, nil
+	}
+	if fake.err != nil {
+		return "", fake.err
+	}
+	return "", fmt.Errorf("could not resolve %s:%s: %w", image, tag, registry.ErrNotFound)
+}
+
+func TestAddTaskPinsDigests(t *testing.T) {
+	const body = `{"app":"test-app","author":"a","project":"p","images":[{"image":"test","tag":"v1","digest":"sha256:forged"}]}`
+
+	// post submits body and returns the task the handler passed to the state, whose
+	// insert fails so no rollout is started.
+	post := func(t *testing.T, digests digestResolver) (*httptest.ResponseRecorder, models.Task) {
+		lockdown, _ := NewLockdown("", lock.NewInMemoryDeployLockStore())
+		strategies := make(map[string]auth.AuthStrategy)
+
+		ctrl := gomock.NewController(t)
+		repo, _ := newRepo(ctrl)
+		repo.EXPECT().Check().Return(true).AnyTimes()
+		var stored models.Task
+		repo.EXPECT().AddTask(gomock.Any()).DoAndReturn(func(task models.Task) (*models.Task, error) {
+			stored = task
+			return nil, fmt.Errorf("stop before the rollout goroutine")
+		}).AnyTimes()
+		argo := &argocd.Argo{}
+		argo.Init(repo, newArgoAPI(ctrl), newMetrics(ctrl))
+
+		env := &Env{
+			lockdown:      lockdown,
+			strategies:    strategies,
+			authenticator: auth.NewAuthenticator(strategies),
+			argo:          argo,
+			config:        &config.ServerConfig{DeploymentTimeout: 900},
+			digests:       digests,
+		}
+
+		router := chi.NewRouter()
+		router.Post("/api/v1/tasks", env.addTask)
+		req, _ := http.NewRequest(http.MethodPost, "/api/v1/tasks", strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w, stored
+	}
+
+	t.Run("the digest the registry serves replaces the client's", func(t *testing.T) {
+		_, stored := post(t, &fakeDigests{digests: map[string]string{"test:v1": "sha256:served"}})
+
+		assert.Equal(t, []models.Image{{Image: "test", Tag: "v1", Digest: "sha256:served"}}, stored.Images)
+	})
+
+	t.Run("a client's digest is dropped without pinning", func(t *testing.T) {
+		_, stored := post(t, nil)
+
+		assert.Equal(t, []models.Image{{Image: "test", Tag: "v1"}}, stored.Images)
+	})
+
+	t.Run("a tag the registry does not have is rejected", func(t *testing.T) {
+		w, _ := post(t, &fakeDigests{})
+
+		assert.Equal(t, http.StatusNotAcceptable, w.Code)
+		assert.Contains(t, w.Body.String(), "could not resolve test:v1")
+	})
+
+	t.Run("an unreachable registry is reported as down", func(t *testing.T) {
+		w, _ := post(t, &fakeDigests{err: errors.New("dial tcp: connection refused")})
+
+		assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+		assert.Contains(t, w.Body.String(), "connection refused")
+	})
+}
+
+// fakeImages knows the images in its set, keyed by "image:tag", and records what it
+// was asked to verify.
+type fakeImages struct {
+	known    map[string]bool
+	verified []string
+}
+
+func (fake *fakeImages) Verify(_ context.Context, image, tag string) error {
+	fake.verified = append(fake.verified, image+":"+tag)
+	if !fake.known[image+":"+tag] {
+		return fmt.Errorf("could not verify %s:%s: %w", image, tag, registry.ErrNotFound)
+	}
+	return nil
+}
+
+func TestAddTaskVerifiesImages(t *testing.T) {
+	const body = `{"app":"test-app","author":"a","project":"p","images":[{"image":"test","tag":"v1"},{"image":"sidecar","tag":"v1.0.O"}]}`
+
+	post := func(t *testing.T, images imageVerifier, digests digestResolver) (*httptest.ResponseRecorder, bool) {
+		lockdown, _ := NewLockdown("", lock.NewInMemoryDeployLockStore())
+		strategies := make(map[string]auth.AuthStrategy)
+
+		ctrl := gomock.NewController(t)
+		repo, _ := newRepo(ctrl)
+		repo.EXPECT().Check().Return(true).AnyTimes()
+		accepted := false
+		repo.EXPECT().AddTask(gomock.Any()).DoAndReturn(func(task models.Task) (*models.Task, error) {
+			accepted = true
+			return nil, fmt.Errorf("stop before the rollout goroutine")
+		}).AnyTimes()
+		argo := &argocd.Argo{}
+		argo.Init(repo, newArgoAPI(ctrl), newMetrics(ctrl))
+
+		env := &Env{
+			lockdown:      lockdown,
+			strategies:    strategies,
+			authenticator: auth.NewAuthenticator(strategies),
+			argo:          argo,
+			config:        &config.ServerConfig{DeploymentTimeout: 900},
+			digests:       digests,
+			images:        images,
+		}
+
+		router := chi.NewRouter()
+		router.Post("/api/v1/tasks", env.addTask)
+		req, _ := http.NewRequest(http.MethodPost, "/api/v1/tasks", strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w, accepted
+	}
+
+	t.Run("a task whose tags all exist is accepted", func(t *testing.T) {
+		images := &fakeImages{known: map[string]bool{"test:v1": true, "sidecar:v1.0.O": true}}
+
+		_, accepted := post(t, images, nil)
+
+		assert.True(t, accepted)
+		assert.Equal(t, []string{"test:v1", "sidecar:v1.0.O"}, images.verified)
+	})
+
+	t.Run("a tag the registry does not have is rejected with the image it names", func(t *testing.T) {
+		w, accepted := post(t, &fakeImages{known: map[string]bool{"test:v1": true}}, nil)
+
+		assert.False(t, accepted)
+		assert.Equal(t, http.StatusNotAcceptable, w.Code)
+		assert.Contains(t, w.Body.String(), "could not verify sidecar:v1.0.O: not found in the registry")
+	})
+
+	t.Run("pinned digests are not verified a second time", func(t *testing.T) {
+		images := &fakeImages{}
+		digests := &fakeDigests{digests: map[string]string{"test:v1": "sha256:a", "sidecar:v1.0.O": "sha256:b"}}
+
+		_, accepted := post(t, images, digests)
+
+		assert.True(t, accepted)
+		assert.Empty(t, images.verified)
+	})
+}
+
+// fakeSignatures returns signer for the images its policy covers, and rejects the
+// others, recording the applications it was asked to verify for.
+type fakeSignatures struct {
+	signer  string
+	digest  string
+	targets []registry.Target
+}
+
+func (fake *fakeSignatures) Verify(_ context.Context, targets []registry.Target, image, reference string) (string, string, error) {
+	fake.targets = targets
+	if fake.signer == "" {
+		return "", "", &registry.SignatureError{Message: image + "@" + reference + " is not signed"}
+	}
+	return fake.signer, fake.digest, nil
+}
+
+func TestAddTaskVerifiesSignatures(t *testing.T) {
+	newEnv := func(t *testing.T, signatures signatureVerifier, digests digestResolver) (*Env, *models.Task) {
+		lockdown, _ := NewLockdown("", lock.NewInMemoryDeployLockStore())
+		strategies := make(map[string]auth.AuthStrategy)
+
+		ctrl := gomock.NewController(t)
+		repo, _ := newRepo(ctrl)
+		repo.EXPECT().Check().Return(true).AnyTimes()
+		stored := &models.Task{}
+		repo.EXPECT().AddTask(gomock.Any()).DoAndReturn(func(task models.Task) (*models.Task, error) {
+			*stored = task
+			return nil, fmt.Errorf("stop before the rollout goroutine")
+		}).AnyTimes()
+		api := mocks.NewMockArgoApiInterface(ctrl)
+		api.EXPECT().Init(gomock.Any()).Return(nil).AnyTimes()
+		// Listed once: the group's tasks are created for the applications verified.
+		api.EXPECT().ListApplications(gomock.Any(), "team=payments").Return([]models.Application{
+			{Metadata: models.ApplicationMetadata{Name: "payments-api"}, Spec: models.ApplicationSpec{Project: "payments"}},
+			{Metadata: models.ApplicationMetadata{Name: "payments-worker"}, Spec: models.ApplicationSpec{Project: "payments"}},
+		}, nil).MaxTimes(1)
+		api.EXPECT().GetApplication(gomock.Any(), "test-app", false).
+			Return(&models.Application{Metadata: models.ApplicationMetadata{Name: "test-app"}, Spec: models.ApplicationSpec{Project: "payments"}}, nil).AnyTimes()
+		api.EXPECT().GetApplication(gomock.Any(), "unknown-app", false).
+			Return(nil, &argocd.ArgoAPIError{StatusCode: http.StatusForbidden, Message: "permission denied"}).AnyTimes()
+		api.EXPECT().GetApplication(gomock.Any(), "degraded-app", false).
+			Return(nil, &argocd.ArgoAPIError{StatusCode: http.StatusBadGateway, Message: "upstream unavailable"}).AnyTimes()
+		argo := &argocd.Argo{}
+		argo.Init(repo, api, newMetrics(ctrl))
+
+		return &Env{
+			lockdown:      lockdown,
+			strategies:    strategies,
+			authenticator: auth.NewAuthenticator(strategies),
+			argo:          argo,
+			config:        &config.ServerConfig{DeploymentTimeout: 900},
+			digests:       digests,
+			signatures:    signatures,
+		}, stored
+	}
+
+	post := func(env *Env, body string) *httptest.ResponseRecorder {
+		router := chi.NewRouter()
+		router.Post("/api/v1/tasks", env.addTask)
+		req, _ := http.NewRequest(http.MethodPost, "/api/v1/tasks", strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	t.Run("the verified signer replaces the client's", func(t *testing.T) {
+		signatures := &fakeSignatures{signer: "release"}
+		env, stored := newEnv(t, signatures, nil)
+
+		post(env, `{"app":"test-app","author":"a","project":"p","images":[{"image":"test","tag":"v1","signer":"forged"}]}`)
+
+		assert.Equal(t, []models.Image{{Image: "test", Tag: "v1", Signer: "release"}}, stored.Images)
+		assert.Equal(t, []registry.Target{{App: "test-app", Project: "payments"}}, signatures.targets,
+			"the project is the one ArgoCD puts the application in, not the one the task names")
+	})
+
+	t.Run("an application ArgoCD does not show is verified by its name alone", func(t *testing.T) {
+		signatures := &fakeSignatures{signer: "release"}
+		env, _ := newEnv(t, signatures, nil)
+
+		post(env, `{"app":"unknown-app","author":"a","project":"payments","images":[{"image":"test","tag":"v1"}]}`)
+
+		assert.Equal(t, []registry.Target{{App: "unknown-app"}}, signatures.targets)
+	})
+
+	t.Run("an application ArgoCD cannot show for an outage is not verified without its project", func(t *testing.T) {
+		signatures := &fakeSignatures{signer: "release"}
+		env, stored := newEnv(t, signatures, nil)
+
+		w := post(env, `{"app":"degraded-app","author":"a","project":"payments","images":[{"image":"test","tag":"v1"}]}`)
+
+		assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+		assert.Nil(t, signatures.targets, "no signature is checked against the rules of the name alone")
+		assert.Empty(t, stored.App)
+	})
+
+	t.Run("the digest verified is written back instead of the tag", func(t *testing.T) {
+		env, stored := newEnv(t, &fakeSignatures{signer: "release", digest: "sha256:verified"}, nil)
+
+		post(env, `{"app":"test-app","author":"a","project":"p","images":[{"image":"test","tag":"v1"}]}`)
+
+		assert.Equal(t, []models.Image{{Image: "test", Tag: "v1", Digest: "sha256:verified", Signer: "release"}}, stored.Images)
+	})
+
+	t.Run("a client's signer is dropped without a policy", func(t *testing.T) {
+		env, stored := newEnv(t, nil, nil)
+
+		post(env, `{"app":"test-app","author":"a","project":"p","images":[{"image":"test","tag":"v1","signer":"forged"}]}`)
+
+		assert.Equal(t, []models.Image{{Image: "test", Tag: "v1"}}, stored.Images)
+	})
+
+	t.Run("an unsigned image is rejected with the reason", func(t *testing.T) {
+		env, stored := newEnv(t, &fakeSignatures{}, &fakeDigests{digests: map[string]string{"test:v1": "sha256:served"}})
+
+		w := post(env, `{"app":"test-app","author":"a","project":"p","images":[{"image":"test","tag":"v1"}]}`)
+
+		assert.Equal(t, http.StatusNotAcceptable, w.Code)
+		assert.Contains(t, w.Body.String(), "test@sha256:served is not signed")
+		assert.Empty(t, stored.Images)
+	})
+
+	t.Run("a group deployment is verified for every application it addresses", func(t *testing.T) {
+		signatures := &fakeSignatures{}
+		env, _ := newEnv(t, signatures, nil)
+
+		w := post(env, `{"selector":"team=payments","author":"a","project":"p","images":[{"image":"test","tag":"v1"}]}`)
+
+		assert.Equal(t, http.StatusNotAcceptable, w.Code)
+		assert.Equal(t, []registry.Target{{App: "payments-api", Project: "payments"}, {App: "payments-worker", Project: "payments"}}, signatures.targets)
+	})
+
+	t.Run("a group deployment is created for the applications verified", func(t *testing.T) {
+		signatures := &fakeSignatures{signer: "release"}
+		env, stored := newEnv(t, signatures, nil)
+
+		w := post(env, `{"selector":"team=payments","author":"a","project":"p","images":[{"image":"test","tag":"v1"}]}`)
+
+		assert.Equal(t, http.StatusServiceUnavailable, w.Code, "the repository refuses to store the task")
+		assert.Equal(t, "payments-api", stored.App)
+		assert.Equal(t, "release", stored.Images[0].Signer)
+	})
+}
+
+// fakeAdmission breaks the rule "labelled" for every application without labels, and
+// records the applications it judged. With err set, it fails to judge at all.
+type fakeAdmission struct {
+	judged []*models.Application
+	err    error
+}
+
+func (fake *fakeAdmission) Evaluate(task models.Task, app *models.Application) ([]string, error) {
+	fake.judged = append(fake.judged, app)
+	if fake.err != nil {
+		return nil, fake.err
+	}
+	if app == nil || len(app.Metadata.Labels) == 0 {
+		return []string{"labelled: " + task.App + " has no labels"}, nil
+	}
+	return nil, nil
+}
+
+func TestAddTaskAdmission(t *testing.T) {
+	labelled := &models.Application{Metadata: models.ApplicationMetadata{Name: "labelled", Labels: map[string]string{"team": "payments"}}}
+
+	newEnv := func(t *testing.T, policy admissionPolicy, appErr error) (*Env, *bool) {
+		lockdown, _ := NewLockdown("", lock.NewInMemoryDeployLockStore())
+		strategies := make(map[string]auth.AuthStrategy)
+
+		ctrl := gomock.NewController(t)
+		repo, _ := newRepo(ctrl)
+		repo.EXPECT().Check().Return(true).AnyTimes()
+		accepted := false
+		repo.EXPECT().AddTask(gomock.Any()).DoAndReturn(func(task models.Task) (*models.Task, error) {
+			accepted = true
+			return nil, fmt.Errorf("stop before the rollout goroutine")
+		}).AnyTimes()
+		api := mocks.NewMockArgoApiInterface(ctrl)
+		api.EXPECT().Init(gomock.Any()).Return(nil).AnyTimes()
+		api.EXPECT().GetUserInfo().Return(&models.Userinfo{LoggedIn: true, Username: "test"}, nil).AnyTimes()
+		// The applications are looked up once per task, however many checks need them.
+		api.EXPECT().GetApplication(gomock.Any(), "labelled", false).Return(labelled, nil).MaxTimes(1)
+		api.EXPECT().GetApplication(gomock.Any(), "other", false).Return(nil, appErr).MaxTimes(1)
+		api.EXPECT().ListApplications(gomock.Any(), "team=payments").Return([]models.Application{
+			{Metadata: models.ApplicationMetadata{Name: "worker"}},
+			*labelled,
+		}, nil).MaxTimes(1)
+		argo := &argocd.Argo{}
+		argo.Init(repo, api, newMetrics(ctrl))
+
+		return &Env{
+			lockdown:      lockdown,
+			strategies:    strategies,
+			authenticator: auth.NewAuthenticator(strategies),
+			argo:          argo,
+			config:        &config.ServerConfig{DeploymentTimeout: 900},
+			admission:     policy,
+		}, &accepted
+	}
+
+	post := func(env *Env, body string) *httptest.ResponseRecorder {
+		router := chi.NewRouter()
+		router.Post("/api/v1/tasks", env.addTask)
+		req, _ := http.NewRequest(http.MethodPost, "/api/v1/tasks", strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	t.Run("a task meeting the policy is stored, judged with its application", func(t *testing.T) {
+		policy := &fakeAdmission{}
+		env, accepted := newEnv(t, policy, nil)
+
+		post(env, `{"app":"labelled","author":"a","project":"p","images":[{"image":"test","tag":"v1"}]}`)
+
+		assert.True(t, *accepted)
+		assert.Equal(t, []*models.Application{labelled}, policy.judged)
+	})
+
+	t.Run("every broken rule is returned", func(t *testing.T) {
+		env, accepted := newEnv(t, &fakeAdmission{}, &argocd.ArgoAPIError{StatusCode: http.StatusForbidden, Message: "permission denied"})
+
+		w := post(env, `{"app":"other","author":"a","project":"p","images":[{"image":"test","tag":"v1"}]}`)
+
+		assert.False(t, *accepted)
+		assert.Equal(t, http.StatusNotAcceptable, w.Code)
+		var status models.TaskStatus
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &status))
+		assert.Equal(t, "rejected", status.Status)
+		assert.Equal(t, []string{"labelled: other has no labels"}, status.Violations)
+		assert.Equal(t, "the task breaks the admission policy: labelled: other has no labels", status.Error)
+	})
+
+	t.Run("a group deployment is judged per application", func(t *testing.T) {
+		env, _ := newEnv(t, &fakeAdmission{}, nil)
+
+		w := post(env, `{"selector":"team=payments","author":"a","project":"p","images":[{"image":"test","tag":"v1"}]}`)
+
+		assert.Equal(t, http.StatusNotAcceptable, w.Code)
+		var status models.TaskStatus
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &status))
+		assert.Equal(t, []string{"worker: labelled: worker has no labels"}, status.Violations)
+	})
+
+	t.Run("an unreachable ArgoCD is reported as down", func(t *testing.T) {
+		env, accepted := newEnv(t, &fakeAdmission{}, errors.New("dial tcp: connection refused"))
+
+		w := post(env, `{"app":"other","author":"a","project":"p","images":[{"image":"test","tag":"v1"}]}`)
+
+		assert.False(t, *accepted)
+		assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+	})
+
+	t.Run("a policy that cannot be evaluated is reported as down", func(t *testing.T) {
+		env, accepted := newEnv(t, &fakeAdmission{err: errors.New("no such key: team")}, nil)
+
+		w := post(env, `{"app":"labelled","author":"a","project":"p","images":[{"image":"test","tag":"v1"}]}`)
+
+		assert.False(t, *accepted)
+		assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+		var status models.TaskStatus
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &status))
+		assert.Equal(t, "down", status.Status)
+		assert.Equal(t, "no such key: team", status.Error)
+	})
+}
+
+func TestAddTaskDowngradeProtection(t *testing.T) {
+	newEnv := func(t *testing.T) (*Env, *models.Task) {
+		lockdown, _ := NewLockdown("", lock.NewInMemoryDeployLockStore())
+		strategies := map[string]auth.AuthStrategy{oidcHeader: newAuthStrategy(t, true, nil)}
+
+		ctrl := gomock.NewController(t)
+		repo := mocks.NewMockTaskRepository(ctrl)
+		repo.EXPECT().Check().Return(true).AnyTimes()
+		repo.EXPECT().GetTasks(gomock.Any(), gomock.Any(), "demo", models.StatusDeployedMessage, gomock.Any(), 0).Return([]models.Task{
+			{Id: "current", App: "demo", Images: []models.Image{{Image: "test", Tag: "1.4.0"}}, Status: models.StatusDeployedMessage},
+		}, int64(1)).AnyTimes()
+		repo.EXPECT().CancelInProgressTasks(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).Return(int64(0), nil).AnyTimes()
+		stored := &models.Task{}
+		repo.EXPECT().AddTask(gomock.Any()).DoAndReturn(func(task models.Task) (*models.Task, error) {
+			*stored = task
+			return nil, fmt.Errorf("stop before the rollout goroutine")
+		}).AnyTimes()
+		argo := &argocd.Argo{PreventDowngrades: true}
+		argo.Init(repo, newArgoAPI(ctrl), newMetrics(ctrl))
+
+		return &Env{
+			lockdown:      lockdown,
+			strategies:    strategies,
+			authenticator: auth.NewAuthenticator(strategies),
+			argo:          argo,
+			config: &config.ServerConfig{
+				DeploymentTimeout: 900,
+				PreventDowngrades: true,
+				OIDC:              config.OIDCConfig{Enabled: true},
+			},
+		}, stored
+	}
+
+	post := func(env *Env, body string, privileged bool) *httptest.ResponseRecorder {
+		router := chi.NewRouter()
+		router.Post("/api/v1/tasks", env.addTask)
+		req, _ := http.NewRequest(http.MethodPost, "/api/v1/tasks", strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		if privileged {
+			req.Header.Set(oidcHeader, "Bearer token")
+		}
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	t.Run("a downgrade is rejected", func(t *testing.T) {
+		env, stored := newEnv(t)
+
+		w := post(env, `{"app":"demo","author":"a","project":"p","images":[{"image":"test","tag":"1.2.0"}]}`, false)
+
+		assert.Equal(t, http.StatusNotAcceptable, w.Code)
+		var status models.TaskStatus
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &status))
+		assert.Equal(t, "rejected", status.Status)
+		assert.Equal(t, "the task downgrades test from 1.4.0 to 1.2.0; mark it as a rollback to deploy it anyway", status.Error)
+		assert.Empty(t, stored.App)
+	})
+
+	t.Run("a downgrade marked as a rollback is stored with the decision", func(t *testing.T) {
+		env, stored := newEnv(t)
+
+		post(env, `{"app":"demo","author":"a","project":"p","rollback":true,"status_reason":"spoofed","images":[{"image":"test","tag":"1.2.0"}]}`, false)
+
+		assert.Equal(t, "demo", stored.App)
+		assert.Equal(t, "Downgrade allowed, the task is marked as a rollback: test from 1.4.0 to 1.2.0", stored.StatusReason)
+	})
+
+	t.Run("a downgrade sent by a privileged user is stored with the decision", func(t *testing.T) {
+		env, stored := newEnv(t)
+
+		post(env, `{"app":"demo","author":"a","project":"p","images":[{"image":"test","tag":"1.2.0"}]}`, true)
+
+		assert.Equal(t, "Downgrade allowed, the task was sent by a privileged user: test from 1.4.0 to 1.2.0", stored.StatusReason)
+	})
+
+	t.Run("an upgrade is stored without a reason", func(t *testing.T) {
+		env, stored := newEnv(t)
+
+		post(env, `{"app":"demo","author":"a","project":"p","status_reason":"spoofed","images":[{"image":"test","tag":"1.5.0"}]}`, false)
+
+		assert.Equal(t, "demo", stored.App)
+		assert.Empty(t, stored.StatusReason)
+	})
+}
+
+func TestAddTaskGroupEndpoint(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	post := func(env *Env, body string) *httptest.ResponseRecorder {
+		router := chi.NewRouter()
+		router.Post("/api/v1/tasks", env.addTask)
+
+		req, _ := http.NewRequest(http.MethodPost, "/api/v1/tasks", strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	t.Run("an app cannot be combined with a group target", func(t *testing.T) {
+		w := post(&Env{}, `{"app":"api","selector":"team=payments","author":"a","project":"p","images":[{"image":"test","tag":"v1"}]}`)
+
+		assert.Equal(t, http.StatusNotAcceptable, w.Code)
+		assert.Contains(t, w.Body.String(), "app cannot be combined with selector or application_set")
+	})
+
+	t.Run("a task must name an app or a group target", func(t *testing.T) {
+		w := post(&Env{}, `{"author":"a","project":"p","images":[{"image":"test","tag":"v1"}]}`)
+
+		assert.Equal(t, http.StatusNotAcceptable, w.Code)
+		assert.Contains(t, w.Body.String(), "invalid payload")
+	})
+
+	t.Run("a target matching nothing is rejected", func(t *testing.T) {
+		api := newArgoAPI(ctrl)
+		api.EXPECT().ListApplications(gomock.Any(), "team=nobody").Return(nil, nil)
+
+		argo := &argocd.Argo{}
+		argo.Init(&state.InMemoryState{}, api, newMetrics(ctrl))
+
+		lockdown, err := NewLockdown("", lock.NewInMemoryDeployLockStore())
+		require.NoError(t, err)
+		strategies := make(map[string]auth.AuthStrategy)
+
+		env := &Env{
+			argo:          argo,
+			lockdown:      lockdown,
+			strategies:    strategies,
+			authenticator: auth.NewAuthenticator(strategies),
+			config:        &config.ServerConfig{DeploymentTimeout: 900},
+		}
+
+		w := post(env, `{"selector":"team=nobody","author":"a","project":"p","images":[{"image":"test","tag":"v1"}]}`)
+
+		assert.Equal(t, http.StatusNotAcceptable, w.Code)
+		assert.Contains(t, w.Body.String(), `no application matches selector \"team=nobody\"`)
+	})
+}
+
+func TestAddTaskDryRun(t *testing.T) {
+	post := func(env *Env, body string) *httptest.ResponseRecorder {
+		router := chi.NewRouter()
+		router.Post("/api/v1/tasks", env.addTask)
+
+		req, _ := http.NewRequest(http.MethodPost, "/api/v1/tasks", strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Authorization", "Bearer test-token")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	// newDryRunEnv returns an Env whose ArgoCD answers GetApplication with err, and
+	// whose only credential is accepted when valid is true. No task repository call
+	// is expected: a dry run must not create a task.
+	newDryRunEnv := func(t *testing.T, valid bool, lockdown *Lockdown, err error) *Env {
+		ctrl := gomock.NewController(t)
+		api := mocks.NewMockArgoApiInterface(ctrl)
+		api.EXPECT().Init(gomock.Any()).Return(nil).AnyTimes()
+		api.EXPECT().GetUserInfo().Return(&models.Userinfo{LoggedIn: true, Username: "test"}, nil).AnyTimes()
+		api.EXPECT().GetApplication(gomock.Any(), "test-app", false).Return(nil, err).AnyTimes()
+
+		argo := &argocd.Argo{}
+		argo.Init(mocks.NewMockTaskRepository(ctrl), api, newMetrics(ctrl))
+		updater := &argocd.ArgoStatusUpdater{}
+		require.NoError(t, updater.Init(*argo, argocd.ArgoStatusUpdaterConfig{RetryAttempts: 1, Locker: lock.NewInMemoryLocker()}))
+
+		if lockdown == nil {
+			lockdown, _ = NewLockdown("", lock.NewInMemoryDeployLockStore())
+		}
+		strategies := map[string]auth.AuthStrategy{
+			"Authorization": newAuthStrategy(t, valid, nil),
+		}
+
+		return &Env{
+			argo:          argo,
+			updater:       updater,
+			lockdown:      lockdown,
+			strategies:    strategies,
+			authenticator: auth.NewAuthenticator(strategies),
+			config:        &config.ServerConfig{DeploymentTimeout: 900},
+		}
+	}
+
+	const dryRunTask = `{"app":"test-app","author":"a","project":"p","images":[{"image":"test","tag":"v1"}],"dry_run":true}`
+
+	t.Run("requires a valid credential", func(t *testing.T) {
+		w := post(newDryRunEnv(t, false, nil, nil), dryRunTask)
+
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+		assert.Contains(t, w.Body.String(), "a dry run requires a valid credential")
+	})
+
+	t.Run("cannot be combined with a group target", func(t *testing.T) {
+		w := post(newDryRunEnv(t, true, nil, nil), `{"selector":"team=payments","author":"a","project":"p","images":[{"image":"test","tag":"v1"}],"dry_run":true}`)
+
+		assert.Equal(t, http.StatusNotAcceptable, w.Code)
+		assert.Contains(t, w.Body.String(), "dry_run cannot be combined with selector or application_set")
+	})
+
+	t.Run("rejects an application ArgoCD does not have", func(t *testing.T) {
+		w := post(newDryRunEnv(t, true, nil, errors.New(`applications.argoproj.io "test-app" not found`)), dryRunTask)
+
+		assert.Equal(t, http.StatusNotAcceptable, w.Code)
+		assert.Contains(t, w.Body.String(), `application \"test-app\" was not found in ArgoCD`)
+	})
+
+	t.Run("reports an unreachable ArgoCD as down", func(t *testing.T) {
+		w := post(newDryRunEnv(t, true, nil, errors.New("connection refused")), dryRunTask)
+
+		assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+		assert.Contains(t, w.Body.String(), "connection refused")
+	})
+
+	t.Run("is answered during a lockdown", func(t *testing.T) {
+		lockdown, err := NewLockdown("", lock.NewInMemoryDeployLockStore())
+		require.NoError(t, err)
+		require.NoError(t, lockdown.SetLock())
+
+		w := post(newDryRunEnv(t, true, lockdown, errors.New(`applications.argoproj.io "test-app" not found`)), dryRunTask)
+
+		assert.Equal(t, http.StatusNotAcceptable, w.Code)
+		assert.NotContains(t, w.Body.String(), "lockdown is active")
+		assert.Contains(t, w.Body.String(), "was not found in ArgoCD")
+	})
+}
+
+func TestGetTaskGroupEndpoint(t *testing.T) {
+	repository := &state.InMemoryState{}
+	for _, app := range []string{"api", "web"} {
+		_, err := repository.AddTask(models.Task{App: app, GroupId: "group-id", Images: []models.Image{{Image: "test", Tag: "v1"}}})
+		require.NoError(t, err)
+	}
+	tasks, err := repository.GetGroupTasks("group-id")
+	require.NoError(t, err)
+	require.NoError(t, repository.SetTaskStatus(tasks[0].Id, models.StatusDeployedMessage, ""))
+
+	argo := &argocd.Argo{}
+	argo.Init(repository, nil, nil)
+	env := &Env{argo: argo}
+
+	router := chi.NewRouter()
+	router.Get("/api/v1/groups/{id}", env.getTaskGroup)
+
+	t.Run("reports the tasks and their combined status", func(t *testing.T) {
+		req, _ := http.NewRequest(http.MethodGet, "/api/v1/groups/group-id", http.NoBody)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		require.Equal(t, http.StatusOK, w.Code)
+		var group models.TaskGroup
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &group))
+		assert.Equal(t, "group-id", group.Id)
+		assert.Equal(t, models.StatusInProgressMessage, group.Status)
+		assert.Len(t, group.Tasks, 2)
+	})
+
+	t.Run("an unknown group is not found", func(t *testing.T) {
+		req, _ := http.NewRequest(http.MethodGet, "/api/v1/groups/unknown", http.NoBody)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusNotFound, w.Code)
+		assert.Contains(t, w.Body.String(), "group not found")
+	})
+}
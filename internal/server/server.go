@@ -12,11 +12,14 @@ import (
 
 	"github.com/prometheus/client_golang/prometheus"
 
+	"github.com/shini4i/argo-watcher/internal/admission"
+	"github.com/shini4i/argo-watcher/internal/analysis"
 	"github.com/shini4i/argo-watcher/internal/argocd"
 	"github.com/shini4i/argo-watcher/internal/config"
 	"github.com/shini4i/argo-watcher/internal/lock"
 	"github.com/shini4i/argo-watcher/internal/logging"
 	prom "github.com/shini4i/argo-watcher/internal/prometheus"
+	"github.com/shini4i/argo-watcher/internal/registry"
 	"github.com/shini4i/argo-watcher/internal/state"
 	"github.com/shini4i/argo-watcher/internal/updater"
 )
@@ -60,6 +63,7 @@ func NewServer(serverConfig *config.ServerConfig, reg prometheus.Registerer) (*S
 	metrics := prom.NewMetrics(reg)
 
 	api := argocd.NewArgoApi()
+	api.SetTokenMetrics(metrics)
 	if err := api.Init(serverConfig); err != nil {
 		return nil, err
 	}
@@ -70,7 +74,7 @@ func NewServer(serverConfig *config.ServerConfig, reg prometheus.Registerer) (*S
 	}
 	go s.ProcessObsoleteTasks(0)
 
-	argo := &argocd.Argo{}
+	argo := &argocd.Argo{PreventDowngrades: serverConfig.PreventDowngrades}
 	argo.Init(s, api, metrics)
 
 	// The distributed Postgres locker and the shared deploy lock both require the
@@ -97,31 +101,105 @@ func NewServer(serverConfig *config.ServerConfig, reg prometheus.Registerer) (*S
 	}
 
 	// Batch write-back settings are parsed independently of the full git config so
-	// servers that do not use git write-back (no SSH_KEY_PATH) still start.
+	// servers that do not use git write-back (no git credential) still start.
 	batchConfig, err := updater.NewBatchConfig()
 	if err != nil {
 		return nil, err
 	}
 
+	// So are the pull request settings; a provider is only required by the
+	// applications that ask for the pull-request write-back method.
+	pullRequestConfig, err := updater.NewPullRequestConfig()
+	if err != nil {
+		return nil, err
+	}
+
+	// And the limits of the repository cache, which all have defaults.
+	cacheConfig, err := updater.NewCacheConfig()
+	if err != nil {
+		return nil, err
+	}
+
+	// Likewise, the post-deployment analysis settings have no required fields.
+	analysisConfig, err := analysis.NewConfig()
+	if err != nil {
+		return nil, err
+	}
+	analysisGate, err := analysis.NewGate(analysisConfig)
+	if err != nil {
+		return nil, err
+	}
+
+	// So are the registry settings; images are only looked up with PIN_IMAGE_DIGESTS
+	// or VERIFY_IMAGES on, or COSIGN_POLICY_PATH set.
+	registryConfig, err := registry.NewConfig()
+	if err != nil {
+		return nil, err
+	}
+	var digests digestResolver
+	var images imageVerifier
+	var signatures signatureVerifier
+	if registryConfig.PinDigests || registryConfig.VerifyImages || registryConfig.SignaturePolicyPath != "" {
+		registryClient, err := registry.NewClient(registryConfig, serverConfig.RegistryProxyUrl)
+		if err != nil {
+			return nil, err
+		}
+		if registryConfig.PinDigests {
+			digests = registryClient
+		}
+		if registryConfig.VerifyImages {
+			images = registryClient
+		}
+		if registryConfig.SignaturePolicyPath != "" {
+			policy, err := registry.LoadSignaturePolicy(registryConfig.SignaturePolicyPath)
+			if err != nil {
+				return nil, err
+			}
+			signatures = registry.NewSignatures(registryClient, policy)
+			slog.Info("Loaded the image signature policy", "path", registryConfig.SignaturePolicyPath)
+		}
+	}
+
+	// The admission policy is optional too; without it every task is admitted.
+	admissionConfig, err := admission.NewConfig()
+	if err != nil {
+		return nil, err
+	}
+	var admissionRules admissionPolicy
+	if admissionConfig.PolicyPath != "" {
+		policy, err := admission.LoadPolicy(admissionConfig.PolicyPath)
+		if err != nil {
+			return nil, err
+		}
+		admissionRules = policy
+		slog.Info("Loaded the admission policy", "rules", policy.Rules(), "path", admissionConfig.PolicyPath)
+	}
+
 	statusUpdater := &argocd.ArgoStatusUpdater{}
 	err = statusUpdater.Init(*argo, argocd.ArgoStatusUpdaterConfig{
-		RetryAttempts:    serverConfig.GetRetryAttempts(),
-		RetryDelay:       argocd.ArgoSyncRetryDelay,
-		RegistryProxyURL: serverConfig.RegistryProxyUrl,
-		RepoCachePath:    serverConfig.RepoCachePath,
-		AcceptSuspended:  serverConfig.AcceptSuspendedApp,
-		RefreshApp:       serverConfig.ArgoRefreshApp,
-		WebhookConfig:    &serverConfig.Webhook,
-		MattermostConfig: &serverConfig.Mattermost,
-		Locker:           locker,
-		BatchWriteBack:   batchConfig.Enabled,
-		BatchMaxSize:     batchConfig.MaxSize,
+		RetryAttempts:      serverConfig.GetRetryAttempts(),
+		RetryDelay:         argocd.ArgoSyncRetryDelay,
+		RegistryProxyURL:   serverConfig.RegistryProxyUrl,
+		RepoCachePath:      serverConfig.RepoCachePath,
+		AcceptSuspended:    serverConfig.AcceptSuspendedApp,
+		RefreshApp:         serverConfig.ArgoRefreshApp,
+		SyncApp:            serverConfig.ArgoSyncApp,
+		RolloutMaxDuration: time.Duration(serverConfig.RolloutMaxDuration) * time.Second,
+		Analysis:           analysisGate,
+		FailureLogLines:    serverConfig.FailureLogLines,
+		WebhookConfig:      &serverConfig.Webhook,
+		MattermostConfig:   &serverConfig.Mattermost,
+		Locker:             locker,
+		BatchWriteBack:     batchConfig.Enabled,
+		BatchMaxSize:       batchConfig.MaxSize,
+		PullRequests:       pullRequestConfig,
+		RepoCache:          cacheConfig,
 	})
 	if err != nil {
 		return nil, fmt.Errorf("failed to initialize the argo updater: %w", err)
 	}
 
-	env, err := NewEnv(serverConfig, argo, metrics, statusUpdater, deployLockStore)
+	env, err := NewEnv(serverConfig, argo, metrics, statusUpdater, deployLockStore, digests, images, signatures, admissionRules)
 	if err != nil {
 		return nil, err
 	}
@@ -169,6 +247,9 @@ func (s *Server) Run() {
 	// deployment (issue #152).
 	s.env.StartTaskReaper()
 
+	// Evict the clones of the repository cache beyond its size and age limits.
+	s.env.StartRepoCacheJanitor()
+
 	go func() {
 		if err := srv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
 			slog.Error("failed to start server", "error", err)
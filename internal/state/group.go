@@ -0,0 +1,105 @@
+package state
+
+import (
+	"slices"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/shini4i/argo-watcher/internal/models"
+	"github.com/shini4i/argo-watcher/internal/state/state_models"
+)
+
+// StartQueuedTask moves a queued task to in progress and claims it for this
+// instance in one statement, and reports whether it did. Checking the status in
+// the same UPDATE is what keeps a task that a newer deployment cancelled while it
+// waited from being started anyway.
+//
+// The creation time restarts as well. Every deadline of a rollout is measured
+// from it — the staleness sweep, and the window a resumed task has left — and
+// the time spent queued must not count against a rollout that had not begun.
+func (state *PostgresState) StartQueuedTask(id string) (bool, error) {
+	if _, err := uuid.Parse(id); err != nil {
+		return false, ErrTaskNotFound
+	}
+
+	result := state.orm.Exec(`
+		UPDATE tasks
+		SET status = ?, created = now(), updated = now(), owner_id = ?, lease_expires_at = now() + make_interval(secs => ?)
+		WHERE id = ? AND status = ?`,
+		models.StatusInProgressMessage, state.ownerId, claimQueryTTLSeconds, id, models.StatusQueuedMessage)
+	if result.Error != nil {
+		return false, result.Error
+	}
+
+	return result.RowsAffected == 1, nil
+}
+
+// GetGroupTasks returns the tasks of a group deployment, oldest first.
+func (state *PostgresState) GetGroupTasks(groupId string) ([]models.Task, error) {
+	if groupId == "" {
+		return []models.Task{}, nil
+	}
+
+	var ormTasks []state_models.TaskModel
+	if err := state.orm.Where("group_id = ?", groupId).Order("created").Find(&ormTasks).Error; err != nil {
+		return nil, err
+	}
+
+	tasks := make([]models.Task, len(ormTasks))
+	for i := range ormTasks {
+		tasks[i] = *ormTasks[i].ConvertToExternalTask()
+	}
+
+	return tasks, nil
+}
+
+// StartQueuedTask moves a queued task to in progress and reports whether it did.
+func (state *InMemoryState) StartQueuedTask(id string) (bool, error) {
+	state.mu.Lock()
+	defer state.mu.Unlock()
+
+	for idx := range state.tasks {
+		if state.tasks[idx].Id == id {
+			if state.tasks[idx].Status != models.StatusQueuedMessage {
+				return false, nil
+			}
+			// Restarted for the same reason as in the Postgres implementation.
+			state.tasks[idx].Status = models.StatusInProgressMessage
+			state.tasks[idx].Created = float64(time.Now().Unix())
+			state.tasks[idx].Updated = float64(time.Now().Unix())
+			return true, nil
+		}
+	}
+	return false, ErrTaskNotFound
+}
+
+// GetGroupTasks returns the tasks of a group deployment, oldest first.
+func (state *InMemoryState) GetGroupTasks(groupId string) ([]models.Task, error) {
+	state.mu.RLock()
+	defer state.mu.RUnlock()
+
+	tasks := []models.Task{}
+	if groupId == "" {
+		return tasks, nil
+	}
+
+	for _, task := range state.tasks {
+		if task.GroupId == groupId {
+			tasks = append(tasks, task)
+		}
+	}
+	// Stable, so tasks created within the same second keep their insertion order.
+	slices.SortStableFunc(tasks, func(a, b models.Task) int {
+		switch {
+		case a.Created < b.Created:
+			return -1
+		case a.Created > b.Created:
+			return 1
+		default:
+			return 0
+		}
+	})
+
+	return tasks, nil
+}
@@ -0,0 +1,55 @@
+package state
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/shini4i/argo-watcher/internal/models"
+)
+
+func TestPostgresState_GroupTasks(t *testing.T) {
+	env := newPostgresTestEnv(t)
+
+	first := env.addTask(t, groupTask("app-a", "group", false))
+	second := env.addTask(t, groupTask("app-b", "group", true))
+	env.addTask(t, groupTask("app-c", "other", false))
+
+	assert.Equal(t, models.StatusInProgressMessage, first.Status)
+	assert.Equal(t, models.StatusQueuedMessage, second.Status)
+
+	tasks, err := env.state.GetGroupTasks("group")
+	require.NoError(t, err)
+	require.Len(t, tasks, 2)
+	assert.Equal(t, first.Id, tasks[0].Id)
+	assert.Equal(t, second.Id, tasks[1].Id)
+	assert.Equal(t, "group", tasks[1].GroupId)
+}
+
+func TestPostgresState_StartQueuedTask(t *testing.T) {
+	env := newPostgresTestEnv(t)
+
+	queued := env.addTask(t, groupTask("app-a", "group", true))
+	superseded := env.addTask(t, groupTask("app-b", "group", true))
+	count, err := env.state.CancelInProgressTasks("app-b", superseded.Images, "superseded", true)
+	require.NoError(t, err)
+	require.Equal(t, int64(1), count, "a queued task is superseded like one in progress")
+
+	started, err := env.state.StartQueuedTask(queued.Id)
+	require.NoError(t, err)
+	assert.True(t, started)
+
+	stored := env.storedModel(t, queued.Id)
+	assert.Equal(t, models.StatusInProgressMessage, stored.Status)
+	assert.Equal(t, env.state.ownerId, stored.OwnerId.String, "a started task is claimed by the replica starting it")
+	assert.True(t, stored.LeaseExpiresAt.Valid)
+
+	started, err = env.state.StartQueuedTask(queued.Id)
+	require.NoError(t, err)
+	assert.False(t, started, "a task leaves the queue once")
+
+	started, err = env.state.StartQueuedTask(superseded.Id)
+	require.NoError(t, err)
+	assert.False(t, started, "a cancelled task must not be started")
+}
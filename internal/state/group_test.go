@@ -0,0 +1,112 @@
+package state
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/shini4i/argo-watcher/internal/models"
+)
+
+// groupTask returns a task of the given group, queued when queued is set.
+func groupTask(app, groupId string, queued bool) models.Task {
+	task := createTestTask(app)
+	task.GroupId = groupId
+	task.Status = ""
+	if queued {
+		task.Status = models.StatusQueuedMessage
+	}
+	return task
+}
+
+func TestInMemoryState_AddTaskKeepsQueuedStatus(t *testing.T) {
+	state := InMemoryState{}
+
+	started, err := state.AddTask(groupTask("app-a", "group", false))
+	require.NoError(t, err)
+	queued, err := state.AddTask(groupTask("app-b", "group", true))
+	require.NoError(t, err)
+
+	assert.Equal(t, models.StatusInProgressMessage, started.Status)
+	assert.Equal(t, models.StatusQueuedMessage, queued.Status)
+}
+
+func TestInMemoryState_StartQueuedTask(t *testing.T) {
+	state := InMemoryState{}
+
+	queued, err := state.AddTask(groupTask("app-a", "group", true))
+	require.NoError(t, err)
+	superseded, err := state.AddTask(groupTask("app-b", "group", true))
+	require.NoError(t, err)
+	count, err := state.CancelInProgressTasks("app-b", superseded.Images, "superseded", true)
+	require.NoError(t, err)
+	require.Equal(t, int64(1), count, "a queued task is superseded like one in progress")
+
+	started, err := state.StartQueuedTask(queued.Id)
+	require.NoError(t, err)
+	assert.True(t, started)
+
+	got, err := state.GetTask(queued.Id)
+	require.NoError(t, err)
+	assert.Equal(t, models.StatusInProgressMessage, got.Status)
+
+	started, err = state.StartQueuedTask(queued.Id)
+	require.NoError(t, err)
+	assert.False(t, started, "a task leaves the queue once")
+
+	started, err = state.StartQueuedTask(superseded.Id)
+	require.NoError(t, err)
+	assert.False(t, started, "a cancelled task must not be started")
+
+	_, err = state.StartQueuedTask("non-existent-id")
+	assert.ErrorIs(t, err, ErrTaskNotFound)
+}
+
+func TestInMemoryState_GetGroupTasks(t *testing.T) {
+	state := InMemoryState{}
+
+	first, err := state.AddTask(groupTask("app-a", "group", false))
+	require.NoError(t, err)
+	second, err := state.AddTask(groupTask("app-b", "group", true))
+	require.NoError(t, err)
+	_, err = state.AddTask(groupTask("app-c", "other", false))
+	require.NoError(t, err)
+	_, err = state.AddTask(createTestTask("app-d"))
+	require.NoError(t, err)
+
+	tasks, err := state.GetGroupTasks("group")
+	require.NoError(t, err)
+	require.Len(t, tasks, 2)
+	assert.Equal(t, first.Id, tasks[0].Id)
+	assert.Equal(t, second.Id, tasks[1].Id)
+
+	tasks, err = state.GetGroupTasks("")
+	require.NoError(t, err)
+	assert.Empty(t, tasks, "a task submitted on its own belongs to no group")
+}
+
+func TestInMemoryState_ProcessObsoleteTasksAbortsStaleQueuedTasks(t *testing.T) {
+	tasks := processInMemoryObsoleteTasks([]models.Task{
+		{Id: "stale", Status: models.StatusQueuedMessage, Updated: 0},
+	}, nil)
+
+	require.Len(t, tasks, 1)
+	assert.Equal(t, models.StatusAborted, tasks[0].Status)
+	assert.Equal(t, StaleTaskAbortReason, tasks[0].StatusReason)
+}
+
+func TestInMemoryState_ProcessObsoleteTasksKeepsLeasedTasks(t *testing.T) {
+	tasks := processInMemoryObsoleteTasks([]models.Task{
+		{Id: "watched", Status: models.StatusAwaitingMergeMessage, Updated: 0},
+		{Id: "lapsed", Status: models.StatusAwaitingMergeMessage, Updated: 0},
+	}, map[string]time.Time{
+		"watched": time.Now().Add(time.Minute),
+		"lapsed":  time.Now().Add(-time.Minute),
+	})
+
+	require.Len(t, tasks, 2)
+	assert.Equal(t, models.StatusAwaitingMergeMessage, tasks[0].Status, "a task its monitor still renews is not stale")
+	assert.Equal(t, models.StatusAborted, tasks[1].Status)
+}
@@ -3,6 +3,8 @@ package state
 import (
 	"errors"
 	"log/slog"
+	"maps"
+	"slices"
 	"sort"
 	"sync"
 	"time"
@@ -26,6 +28,9 @@ const (
 type InMemoryState struct {
 	mu    sync.RWMutex
 	tasks []models.Task
+	// leases holds, per task, until when its monitor was last known to be alive. The
+	// stale sweep leaves a task with a live lease alone.
+	leases map[string]time.Time
 }
 
 var _ TaskRepository = (*InMemoryState)(nil)
@@ -36,8 +41,8 @@ func (state *InMemoryState) Connect(serverConfig *config.ServerConfig) error {
 	return nil
 }
 
-// AddTask assigns an id, timestamps, and in-progress status, then appends the
-// task. The error is always nil; in-memory storage has no persistence failure.
+// AddTask assigns an id, timestamps, and in-progress status (queued for a group
+// task waiting for a rollout slot), then appends the task. The error is always nil; in-memory storage has no persistence failure.
 func (state *InMemoryState) AddTask(task models.Task) (*models.Task, error) {
 	state.mu.Lock()
 	defer state.mu.Unlock()
@@ -45,7 +50,7 @@ func (state *InMemoryState) AddTask(task models.Task) (*models.Task, error) {
 	task.Id = uuid.New().String()
 	task.Created = float64(time.Now().Unix())
 	task.Updated = float64(time.Now().Unix())
-	task.Status = models.StatusInProgressMessage
+	task.Status = initialStatus(task)
 	state.tasks = append(state.tasks, task)
 	return &task, nil
 }
@@ -142,8 +147,26 @@ func (state *InMemoryState) SetTaskStatus(id, status, reason string) error {
 	return errors.New("task not found")
 }
 
-// CancelInProgressTasks marks in-progress tasks for the given app as cancelled
-// and returns how many were updated. A task is only cancelled when it shares at
+// SetTaskProgress updates the status reason of an in-progress task, leaving a
+// task in any other state untouched.
+func (state *InMemoryState) SetTaskProgress(id, reason string) error {
+	state.mu.Lock()
+	defer state.mu.Unlock()
+
+	for idx, task := range state.tasks {
+		if task.Id == id {
+			if task.Status == models.StatusInProgressMessage {
+				state.tasks[idx].StatusReason = reason
+				state.tasks[idx].Updated = float64(time.Now().Unix())
+			}
+			return nil
+		}
+	}
+	return errors.New("task not found")
+}
+
+// CancelInProgressTasks marks in-progress and queued tasks for the given app as
+// cancelled and returns how many were updated. A task is only cancelled when it shares at
 // least one image name with the supplied images (tags ignored), so independent
 // per-image deployments of the same app do not cancel each other, and only when
 // it carries no more authority than the superseding deployment.
@@ -155,7 +178,7 @@ func (state *InMemoryState) CancelInProgressTasks(app string, images []models.Im
 	now := float64(time.Now().Unix())
 	for idx := range state.tasks {
 		if state.tasks[idx].App == app &&
-			state.tasks[idx].Status == models.StatusInProgressMessage &&
+			slices.Contains(unfinishedStatuses, state.tasks[idx].Status) &&
 			maySupersede(newTaskValidated, state.tasks[idx].Validated) &&
 			imageNamesOverlap(state.tasks[idx].Images, images) {
 			state.tasks[idx].Status = models.StatusCancelledMessage
@@ -181,7 +204,8 @@ func (state *InMemoryState) ProcessObsoleteTasks(retryTimes uint) {
 		func() error {
 			state.mu.Lock()
 			defer state.mu.Unlock()
-			state.tasks = processInMemoryObsoleteTasks(state.tasks)
+			state.tasks = processInMemoryObsoleteTasks(state.tasks, state.leases)
+			maps.DeleteFunc(state.leases, func(_ string, expires time.Time) bool { return expires.Before(time.Now()) })
 			return errDesiredRetry
 		},
 		retry.DelayType(retry.FixedDelay),
@@ -193,13 +217,21 @@ func (state *InMemoryState) ProcessObsoleteTasks(retryTimes uint) {
 	}
 }
 
-func processInMemoryObsoleteTasks(tasks []models.Task) []models.Task {
+// processInMemoryObsoleteTasks drops app-not-found tasks and aborts unfinished ones
+// not updated for TaskStaleThresholdSeconds, unless leases shows their monitor is still
+// alive: a task awaiting the merge of its pull request may wait longer than that.
+func processInMemoryObsoleteTasks(tasks []models.Task, leases map[string]time.Time) []models.Task {
 	var updatedTasks []models.Task
+	now := time.Now()
 	for _, task := range tasks {
 		if task.Status == models.StatusAppNotFoundMessage {
 			continue
 		}
-		if task.Status == models.StatusInProgressMessage && task.Updated+TaskStaleThresholdSeconds < float64(time.Now().Unix()) {
+		if expires, ok := leases[task.Id]; ok && expires.After(now) {
+			updatedTasks = append(updatedTasks, task)
+			continue
+		}
+		if slices.Contains(unfinishedStatuses, task.Status) && task.Updated+TaskStaleThresholdSeconds < float64(now.Unix()) {
 			task.Status = models.StatusAborted
 			task.StatusReason = StaleTaskAbortReason
 		}
@@ -41,7 +41,7 @@ func TestImageNamesOverlap(t *testing.T) {
 		b    []models.Image
 		want bool
 	}{
-		{"both nil", nil, nil, false},
+		{"both empty, as chart version tasks are", nil, nil, true},
 		{"first empty", nil, []models.Image{{Image: "image-a", Tag: "v1"}}, false},
 		{"second empty", []models.Image{{Image: "image-a", Tag: "v1"}}, nil, false},
 		{"fully disjoint", []models.Image{{Image: "image-a"}}, []models.Image{{Image: "image-b"}}, false},
@@ -221,6 +221,31 @@ func TestInMemoryState_SetTaskStatus_NotFound(t *testing.T) {
 	assert.Equal(t, "task not found", err.Error())
 }
 
+func TestInMemoryState_SetTaskProgress(t *testing.T) {
+	state := InMemoryState{}
+
+	inProgress, err := state.AddTask(createTestTask("Test"))
+	require.NoError(t, err)
+	cancelled, err := state.AddTask(createTestTask("Test"))
+	require.NoError(t, err)
+	require.NoError(t, state.SetTaskStatus(cancelled.Id, models.StatusCancelledMessage, "superseded"))
+
+	require.NoError(t, state.SetTaskProgress(inProgress.Id, "canary step 1/3"))
+	require.NoError(t, state.SetTaskProgress(cancelled.Id, "canary step 1/3"))
+
+	got, err := state.GetTask(inProgress.Id)
+	require.NoError(t, err)
+	assert.Equal(t, models.StatusInProgressMessage, got.Status)
+	assert.Equal(t, "canary step 1/3", got.StatusReason)
+
+	got, err = state.GetTask(cancelled.Id)
+	require.NoError(t, err)
+	assert.Equal(t, models.StatusCancelledMessage, got.Status)
+	assert.Equal(t, "superseded", got.StatusReason)
+
+	assert.EqualError(t, state.SetTaskProgress("non-existent-id", ""), "task not found")
+}
+
 func TestInMemoryState_CancelInProgressTasks(t *testing.T) {
 	state := InMemoryState{}
 
@@ -49,11 +49,24 @@ func newOwnerId() (string, error) {
 }
 
 // RenewLease reports whether this instance still holds the task. With in-memory
-// state there is no second replica to lose it to, so it always does.
-func (state *InMemoryState) RenewLease(_ string) (bool, error) {
+// state there is no second replica to lose it to, so it always does. The renewal is
+// still noted: it is what tells the stale sweep that the task is being watched.
+func (state *InMemoryState) RenewLease(id string) (bool, error) {
+	state.noteLease(id)
 	return true, nil
 }
 
+// noteLease records that the task's monitor is alive, for TaskLeaseTTL.
+func (state *InMemoryState) noteLease(id string) {
+	state.mu.Lock()
+	defer state.mu.Unlock()
+
+	if state.leases == nil {
+		state.leases = make(map[string]time.Time)
+	}
+	state.leases[id] = time.Now().Add(TaskLeaseTTL)
+}
+
 // ReleaseOwnedLeases has nothing to hand over: no other process can pick these
 // tasks up.
 func (state *InMemoryState) ReleaseOwnedLeases() (int64, error) {
@@ -128,15 +141,16 @@ func (state *PostgresState) ReleaseOwnedLeases() (int64, error) {
 	result := state.orm.Exec(`
 		UPDATE tasks
 		SET owner_id = NULL, lease_expires_at = now()
-		WHERE owner_id = ? AND status = ?`,
-		state.ownerId, models.StatusInProgressMessage)
+		WHERE owner_id = ? AND status IN ?`,
+		state.ownerId, monitoredStatuses)
 
 	return result.RowsAffected, result.Error
 }
 
-// ClaimExpiredTasks takes over up to limit in-progress tasks whose lease has
-// lapsed — tasks whose previous owner died, was rolled, or released them on
-// shutdown — and returns them ready to be monitored again.
+// ClaimExpiredTasks takes over up to limit in-progress or awaiting-merge tasks whose
+// lease has lapsed — tasks whose previous owner died, was rolled, or released them
+// on shutdown — and returns them ready to be monitored again. A task awaiting merge
+// comes back with its pull request, which its new owner follows.
 //
 // FOR UPDATE SKIP LOCKED is what makes this safe to run on every replica at
 // once: concurrent sweeps neither block each other nor hand the same task to two
@@ -163,7 +177,7 @@ func (state *PostgresState) ClaimExpiredTasks(limit int) ([]models.Task, error)
 		SET owner_id = ?, lease_expires_at = now() + make_interval(secs => ?)
 		WHERE id IN (
 			SELECT id FROM tasks
-			WHERE status = ?
+			WHERE status IN ?
 			  AND (
 			        lease_expires_at < now()
 			     OR (lease_expires_at IS NULL AND created < now() - make_interval(secs => ?))
@@ -173,7 +187,7 @@ func (state *PostgresState) ClaimExpiredTasks(limit int) ([]models.Task, error)
 			LIMIT ?
 		)
 		RETURNING *`,
-		state.ownerId, claimQueryTTLSeconds, models.StatusInProgressMessage, claimQueryTTLSeconds, limit).
+		state.ownerId, claimQueryTTLSeconds, monitoredStatuses, claimQueryTTLSeconds, limit).
 		Scan(&claimed).Error
 	if err != nil {
 		return nil, err
@@ -187,8 +201,9 @@ func (state *PostgresState) ClaimExpiredTasks(limit int) ([]models.Task, error)
 	return tasks, nil
 }
 
-// ClaimTask is a no-op: the accepting process is the only one that could
-// monitor the task.
-func (state *InMemoryState) ClaimTask(_ string) error {
+// ClaimTask only notes the lease for the stale sweep: the accepting process is the
+// only one that could monitor the task.
+func (state *InMemoryState) ClaimTask(id string) error {
+	state.noteLease(id)
 	return nil
 }
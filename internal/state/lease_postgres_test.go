@@ -140,6 +140,28 @@ func TestPostgresState_ClaimExpiredTasks(t *testing.T) {
 		assert.NotNil(t, findTask(claimed, inserted.Id))
 	})
 
+	t.Run("a task awaiting merge is taken over with its pull request", func(t *testing.T) {
+		require.NoError(t, env.state.orm.Exec("TRUNCATE TABLE tasks").Error)
+
+		inserted := env.addTask(t, sampleTask("Proposed"))
+		require.NoError(t, env.state.ClaimTask(inserted.Id))
+		pullRequest := models.PullRequest{URL: "https://git.example.com/pulls/7", Number: 7, Branch: "argo-watcher/Proposed/1", Base: "main", Since: 1700000000}
+		_, err := env.state.AwaitMerge(inserted.Id, "waiting for the pull request", pullRequest)
+		require.NoError(t, err)
+		released, err := env.state.ReleaseOwnedLeases()
+		require.NoError(t, err)
+		assert.Equal(t, int64(1), released, "a task awaiting merge is in flight too")
+
+		claimed, err := env.secondReplica(t).ClaimExpiredTasks(TaskReapBatchSize)
+		require.NoError(t, err)
+
+		resumed := findTask(claimed, inserted.Id)
+		require.NotNil(t, resumed)
+		assert.Equal(t, models.StatusAwaitingMergeMessage, resumed.Status)
+		require.NotNil(t, resumed.PullRequest)
+		assert.Equal(t, pullRequest, *resumed.PullRequest)
+	})
+
 	// A task accepted moments ago has not been abandoned — it is waiting for the
 	// accepting replica's own claim, one statement behind. Claiming it here would
 	// put two monitors on one rollout.
@@ -0,0 +1,100 @@
+package state
+
+import (
+	"database/sql"
+	"encoding/json"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/datatypes"
+
+	"github.com/shini4i/argo-watcher/internal/models"
+)
+
+// AwaitMerge moves an in-progress task to awaiting merge, storing its pull request,
+// and reports whether it did. The status is checked in the UPDATE itself, so a task
+// cancelled since the write-back began keeps its cancellation.
+func (state *PostgresState) AwaitMerge(id, reason string, pullRequest models.PullRequest) (bool, error) {
+	if _, err := uuid.Parse(id); err != nil {
+		return false, ErrTaskNotFound
+	}
+
+	encoded, err := json.Marshal(pullRequest)
+	if err != nil {
+		return false, err
+	}
+
+	result := state.orm.Exec(`
+		UPDATE tasks
+		SET status = ?, status_reason = ?, pull_request = ?, updated = now()
+		WHERE id = ? AND status = ?`,
+		models.StatusAwaitingMergeMessage, sql.NullString{String: reason, Valid: true}, datatypes.JSON(encoded), id, models.StatusInProgressMessage)
+	if result.Error != nil {
+		return false, result.Error
+	}
+
+	return result.RowsAffected == 1, nil
+}
+
+// StartMergedTask moves a task awaiting merge back to in progress and reports
+// whether it did. As with a queued task, the creation time restarts: the rollout
+// deadline is measured from it, and the time a reviewer took must not count
+// against a rollout that had not begun.
+func (state *PostgresState) StartMergedTask(id string) (bool, error) {
+	if _, err := uuid.Parse(id); err != nil {
+		return false, ErrTaskNotFound
+	}
+
+	result := state.orm.Exec(`
+		UPDATE tasks
+		SET status = ?, created = now(), updated = now()
+		WHERE id = ? AND status = ?`,
+		models.StatusInProgressMessage, id, models.StatusAwaitingMergeMessage)
+	if result.Error != nil {
+		return false, result.Error
+	}
+
+	return result.RowsAffected == 1, nil
+}
+
+// AwaitMerge moves an in-progress task to awaiting merge, storing its pull request,
+// and reports whether it did.
+func (state *InMemoryState) AwaitMerge(id, reason string, pullRequest models.PullRequest) (bool, error) {
+	state.mu.Lock()
+	defer state.mu.Unlock()
+
+	for idx := range state.tasks {
+		if state.tasks[idx].Id == id {
+			if state.tasks[idx].Status != models.StatusInProgressMessage {
+				return false, nil
+			}
+			state.tasks[idx].Status = models.StatusAwaitingMergeMessage
+			state.tasks[idx].StatusReason = reason
+			state.tasks[idx].PullRequest = &pullRequest
+			state.tasks[idx].Updated = float64(time.Now().Unix())
+			return true, nil
+		}
+	}
+	return false, ErrTaskNotFound
+}
+
+// StartMergedTask moves a task awaiting merge back to in progress and reports
+// whether it did.
+func (state *InMemoryState) StartMergedTask(id string) (bool, error) {
+	state.mu.Lock()
+	defer state.mu.Unlock()
+
+	for idx := range state.tasks {
+		if state.tasks[idx].Id == id {
+			if state.tasks[idx].Status != models.StatusAwaitingMergeMessage {
+				return false, nil
+			}
+			// Restarted for the same reason as in the Postgres implementation.
+			state.tasks[idx].Status = models.StatusInProgressMessage
+			state.tasks[idx].Created = float64(time.Now().Unix())
+			state.tasks[idx].Updated = float64(time.Now().Unix())
+			return true, nil
+		}
+	}
+	return false, ErrTaskNotFound
+}
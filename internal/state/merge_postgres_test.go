@@ -0,0 +1,40 @@
+package state
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/shini4i/argo-watcher/internal/models"
+)
+
+func TestPostgresState_AwaitMerge(t *testing.T) {
+	env := newPostgresTestEnv(t)
+
+	task := env.addTask(t, createTestTask("app-a"))
+	superseded := env.addTask(t, createTestTask("app-b"))
+
+	awaiting, err := env.state.AwaitMerge(task.Id, "waiting for the pull request", models.PullRequest{})
+	require.NoError(t, err)
+	assert.True(t, awaiting)
+
+	stored := env.storedModel(t, task.Id)
+	assert.Equal(t, models.StatusAwaitingMergeMessage, stored.Status)
+	assert.Equal(t, "waiting for the pull request", stored.StatusReason.String)
+
+	started, err := env.state.StartMergedTask(task.Id)
+	require.NoError(t, err)
+	assert.True(t, started)
+	assert.Equal(t, models.StatusInProgressMessage, env.storedModel(t, task.Id).Status)
+
+	_, err = env.state.AwaitMerge(superseded.Id, "waiting for the pull request", models.PullRequest{})
+	require.NoError(t, err)
+	count, err := env.state.CancelInProgressTasks("app-b", superseded.Images, "superseded", true)
+	require.NoError(t, err)
+	require.Equal(t, int64(1), count, "a task awaiting merge is superseded like one in progress")
+
+	started, err = env.state.StartMergedTask(superseded.Id)
+	require.NoError(t, err)
+	assert.False(t, started, "a cancelled task must not be started")
+}
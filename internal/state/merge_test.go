@@ -0,0 +1,62 @@
+package state
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/shini4i/argo-watcher/internal/models"
+)
+
+func TestInMemoryState_AwaitMerge(t *testing.T) {
+	state := InMemoryState{}
+
+	task, err := state.AddTask(createTestTask("app-a"))
+	require.NoError(t, err)
+
+	awaiting, err := state.AwaitMerge(task.Id, "waiting for the pull request", models.PullRequest{})
+	require.NoError(t, err)
+	assert.True(t, awaiting)
+
+	got, err := state.GetTask(task.Id)
+	require.NoError(t, err)
+	assert.Equal(t, models.StatusAwaitingMergeMessage, got.Status)
+	assert.Equal(t, "waiting for the pull request", got.StatusReason)
+
+	started, err := state.StartMergedTask(task.Id)
+	require.NoError(t, err)
+	assert.True(t, started)
+
+	got, err = state.GetTask(task.Id)
+	require.NoError(t, err)
+	assert.Equal(t, models.StatusInProgressMessage, got.Status)
+
+	started, err = state.StartMergedTask(task.Id)
+	require.NoError(t, err)
+	assert.False(t, started, "only a task awaiting merge is started")
+
+	_, err = state.AwaitMerge("non-existent-id", "reason", models.PullRequest{})
+	assert.ErrorIs(t, err, ErrTaskNotFound)
+}
+
+func TestInMemoryState_AwaitMergeIsSuperseded(t *testing.T) {
+	state := InMemoryState{}
+
+	task, err := state.AddTask(createTestTask("app-a"))
+	require.NoError(t, err)
+	_, err = state.AwaitMerge(task.Id, "waiting for the pull request", models.PullRequest{})
+	require.NoError(t, err)
+
+	count, err := state.CancelInProgressTasks("app-a", task.Images, "superseded", true)
+	require.NoError(t, err)
+	require.Equal(t, int64(1), count, "a task awaiting merge is superseded like one in progress")
+
+	started, err := state.StartMergedTask(task.Id)
+	require.NoError(t, err)
+	assert.False(t, started, "a cancelled task must not be started")
+
+	awaiting, err := state.AwaitMerge(task.Id, "waiting for the pull request", models.PullRequest{})
+	require.NoError(t, err)
+	assert.False(t, awaiting, "a cancelled task must not await a merge")
+}
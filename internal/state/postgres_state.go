@@ -19,7 +19,10 @@ import (
 	"github.com/shini4i/argo-watcher/internal/state/state_models"
 )
 
-const whereStatusEquals = "status = ?"
+const (
+	whereStatusEquals = "status = ?"
+	whereStatusIn     = "status IN ?"
+)
 
 // retentionDeleteBatchSize is how many expired tasks one DELETE removes. It
 // keeps each statement short enough not to hold locks or grow a transaction for
@@ -67,19 +70,26 @@ func (state *PostgresState) Connect(serverConfig *config.ServerConfig) error {
 	return nil
 }
 
-// AddTask returns the task with the DB-generated id and creation time.
+// AddTask returns the task with the DB-generated id and creation time. The task
+// is stored in progress, unless it is a group task queued for a rollout slot.
 func (state *PostgresState) AddTask(task models.Task) (*models.Task, error) {
+	status := initialStatus(task)
 	ormTask := state_models.TaskModel{
 		Images:           datatypes.NewJSONSlice(task.Images),
-		Status:           models.StatusInProgressMessage,
+		Parameters:       datatypes.NewJSONSlice(task.Parameters),
+		ChartVersion:     task.ChartVersion,
+		Status:           status,
+		StatusReason:     sql.NullString{String: task.StatusReason, Valid: task.StatusReason != ""},
 		ApplicationName:  sql.NullString{String: task.App, Valid: true},
 		Author:           sql.NullString{String: task.Author, Valid: true},
 		Project:          sql.NullString{String: task.Project, Valid: true},
 		IsRollback:       task.IsRollback,
 		RollbackTargetId: task.RollbackTargetId,
+		RollbackOfId:     task.RollbackOfId,
 		Validated:        task.Validated,
 		Timeout:          task.Timeout,
 		Refresh:          nullBoolFromPointer(task.Refresh),
+		GroupId:          task.GroupId,
 	}
 
 	if err := state.orm.Create(&ormTask).Error; err != nil {
@@ -89,7 +99,7 @@ func (state *PostgresState) AddTask(task models.Task) (*models.Task, error) {
 
 	task.Id = ormTask.Id.String()
 	task.Created = float64(ormTask.Created.UnixMilli())
-	task.Status = models.StatusInProgressMessage
+	task.Status = status
 
 	return &task, nil
 }
@@ -177,8 +187,24 @@ func (state *PostgresState) SetTaskStatus(id, status, reason string) error {
 	return nil
 }
 
-// CancelInProgressTasks marks in-progress tasks for the given app as cancelled
-// and returns how many rows were affected. A task is only cancelled when it
+// SetTaskProgress updates the status reason of an in-progress task. The status
+// condition is part of the UPDATE itself, so a task that was cancelled or
+// finished since the caller last read it keeps its reason. Matching no row is
+// therefore not an error.
+func (state *PostgresState) SetTaskProgress(id, reason string) error {
+	uuidv4, err := uuid.Parse(id)
+	if err != nil {
+		return err
+	}
+
+	return state.orm.Model(&state_models.TaskModel{}).
+		Where("id = ?", uuidv4).
+		Where(whereStatusEquals, models.StatusInProgressMessage).
+		Update("status_reason", sql.NullString{String: reason, Valid: true}).Error
+}
+
+// CancelInProgressTasks marks in-progress and queued tasks for the given app as
+// cancelled and returns how many rows were affected. A task is only cancelled when it
 // shares at least one image name with the supplied images (tags ignored), so
 // independent per-image deployments of the same app do not cancel each other,
 // and only when it carries no more authority than the superseding deployment.
@@ -189,7 +215,7 @@ func (state *PostgresState) CancelInProgressTasks(app string, images []models.Im
 	var candidates []state_models.TaskModel
 	if err := state.orm.Model(&state_models.TaskModel{}).
 		Where(`"tasks"."app" = ?`, app).
-		Where(whereStatusEquals, models.StatusInProgressMessage).
+		Where(whereStatusIn, unfinishedStatuses).
 		Find(&candidates).Error; err != nil {
 		return 0, err
 	}
@@ -206,7 +232,7 @@ func (state *PostgresState) CancelInProgressTasks(app string, images []models.Im
 
 	result := state.orm.Model(&state_models.TaskModel{}).
 		Where("id IN ?", ids).
-		Where(whereStatusEquals, models.StatusInProgressMessage).
+		Where(whereStatusIn, unfinishedStatuses).
 		Updates(state_models.TaskModel{
 			Status:       models.StatusCancelledMessage,
 			StatusReason: sql.NullString{String: reason, Valid: true},
@@ -264,8 +290,11 @@ func (state *PostgresState) doProcessPostgresObsoleteTasks() error {
 		return err
 	}
 
-	slog.Debug("Marking in progress tasks older than 1 hour as aborted...")
-	if err := state.orm.Where(whereStatusEquals, models.StatusInProgressMessage).Where("created < now() - interval '1 hour'").Updates(&state_models.TaskModel{
+	// A task whose lease is live is still watched by a replica — one awaiting the merge
+	// of its pull request may rightly wait longer than the hour — so it is left alone.
+	slog.Debug("Marking unwatched unfinished tasks older than 1 hour as aborted...")
+	if err := state.orm.Where(whereStatusIn, unfinishedStatuses).Where("created < now() - interval '1 hour'").
+		Where("lease_expires_at IS NULL OR lease_expires_at < now()").Updates(&state_models.TaskModel{
 		Status:       models.StatusAborted,
 		StatusReason: sql.NullString{String: StaleTaskAbortReason, Valid: true},
 	}).Error; err != nil {
@@ -92,6 +92,18 @@ func TestPostgresState_AddTask(t *testing.T) {
 	assert.Equal(t, "Test", result.App)
 }
 
+func TestPostgresState_AddTaskKeepsStatusReason(t *testing.T) {
+	env := newPostgresTestEnv(t)
+
+	task := sampleTask("Downgrade")
+	task.StatusReason = "Downgrade allowed, the task is marked as a rollback"
+	inserted := env.addTask(t, task)
+
+	stored, err := env.state.GetTask(inserted.Id)
+	require.NoError(t, err)
+	assert.Equal(t, "Downgrade allowed, the task is marked as a rollback", stored.StatusReason)
+}
+
 func TestPostgresState_RollbackFieldsRoundTrip(t *testing.T) {
 	env := newPostgresTestEnv(t)
 
@@ -99,6 +111,7 @@ func TestPostgresState_RollbackFieldsRoundTrip(t *testing.T) {
 		task := sampleTask("Rollback")
 		task.IsRollback = true
 		task.RollbackTargetId = "11111111-1111-4111-8111-111111111111"
+		task.RollbackOfId = "22222222-2222-4222-8222-222222222222"
 		inserted := env.addTask(t, task)
 
 		stored, err := env.state.GetTask(inserted.Id)
@@ -106,6 +119,7 @@ func TestPostgresState_RollbackFieldsRoundTrip(t *testing.T) {
 		require.NotNil(t, stored)
 		assert.True(t, stored.IsRollback)
 		assert.Equal(t, "11111111-1111-4111-8111-111111111111", stored.RollbackTargetId)
+		assert.Equal(t, "22222222-2222-4222-8222-222222222222", stored.RollbackOfId)
 	})
 
 	t.Run("defaults apply when rollback fields are unset", func(t *testing.T) {
@@ -116,9 +130,44 @@ func TestPostgresState_RollbackFieldsRoundTrip(t *testing.T) {
 		require.NotNil(t, stored)
 		assert.False(t, stored.IsRollback)
 		assert.Empty(t, stored.RollbackTargetId)
+		assert.Empty(t, stored.RollbackOfId)
 	})
 }
 
+func TestPostgresState_ParametersRoundTrip(t *testing.T) {
+	env := newPostgresTestEnv(t)
+
+	task := sampleTask("Parameters")
+	task.Parameters = []models.Parameter{
+		{Name: "features.checkout", Value: "true"},
+		{Name: "config.version", Value: "7", ForceString: true},
+	}
+	inserted := env.addTask(t, task)
+
+	stored, err := env.state.GetTask(inserted.Id)
+	require.NoError(t, err)
+	assert.Equal(t, task.Parameters, stored.Parameters)
+
+	withoutParameters := env.addTask(t, sampleTask("NoParameters"))
+	stored, err = env.state.GetTask(withoutParameters.Id)
+	require.NoError(t, err)
+	assert.Empty(t, stored.Parameters)
+}
+
+func TestPostgresState_ChartVersionRoundTrip(t *testing.T) {
+	env := newPostgresTestEnv(t)
+
+	task := sampleTask("ChartVersion")
+	task.Images = nil
+	task.ChartVersion = "1.4.0"
+	inserted := env.addTask(t, task)
+
+	stored, err := env.state.GetTask(inserted.Id)
+	require.NoError(t, err)
+	assert.Equal(t, "1.4.0", stored.ChartVersion)
+	assert.Empty(t, stored.Images)
+}
+
 func TestPostgresState_GetTasks(t *testing.T) {
 	env := newPostgresTestEnv(t)
 
@@ -210,6 +259,26 @@ func TestPostgresState_SetTaskStatus(t *testing.T) {
 	assert.Equal(t, "finished", taskInfo.StatusReason)
 }
 
+func TestPostgresState_SetTaskProgress(t *testing.T) {
+	env := newPostgresTestEnv(t)
+	inProgress := env.addTask(t, sampleTask("Test"))
+	cancelled := env.addTask(t, sampleTask("Test"))
+	require.NoError(t, env.state.SetTaskStatus(cancelled.Id, models.StatusCancelledMessage, "superseded"))
+
+	require.NoError(t, env.state.SetTaskProgress(inProgress.Id, "canary step 1/3"))
+	require.NoError(t, env.state.SetTaskProgress(cancelled.Id, "canary step 1/3"))
+
+	got, err := env.state.GetTask(inProgress.Id)
+	require.NoError(t, err)
+	assert.Equal(t, models.StatusInProgressMessage, got.Status)
+	assert.Equal(t, "canary step 1/3", got.StatusReason)
+
+	got, err = env.state.GetTask(cancelled.Id)
+	require.NoError(t, err)
+	assert.Equal(t, models.StatusCancelledMessage, got.Status)
+	assert.Equal(t, "superseded", got.StatusReason)
+}
+
 func TestPostgresState_CancelInProgressTasks(t *testing.T) {
 	env := newPostgresTestEnv(t)
 
@@ -391,6 +460,12 @@ func TestPostgresState_ProcessObsoleteTasks(t *testing.T) {
 	_, err = db.Exec("UPDATE tasks SET status = $1, created = $2 WHERE id = $3", models.StatusAppNotFoundMessage, expired, appNotFound.Id)
 	require.NoError(t, err)
 
+	// A task awaiting a merge for longer than the hour is still watched while its lease is live.
+	watched := env.addTask(t, sampleTask("WatchedApp"))
+	require.NoError(t, env.state.ClaimTask(watched.Id))
+	_, err = db.Exec("UPDATE tasks SET status = $1, created = $2 WHERE id = $3", models.StatusAwaitingMergeMessage, expired, watched.Id)
+	require.NoError(t, err)
+
 	env.state.ProcessObsoleteTasks(1)
 
 	_, err = env.state.GetTask(appNotFound.Id)
@@ -401,6 +476,10 @@ func TestPostgresState_ProcessObsoleteTasks(t *testing.T) {
 	require.NotNil(t, task)
 	assert.Equal(t, models.StatusAborted, task.Status)
 	assert.Equal(t, StaleTaskAbortReason, task.StatusReason)
+
+	task, err = env.state.GetTask(watched.Id)
+	require.NoError(t, err)
+	assert.Equal(t, models.StatusAwaitingMergeMessage, task.Status, "a task with a live lease is not stale")
 }
 
 func TestPostgresState_Check(t *testing.T) {
@@ -17,6 +17,26 @@ var errDesiredRetry = errors.New("desired retry error")
 // silently reported as a missing task.
 var ErrTaskNotFound = errors.New("task not found")
 
+// unfinishedStatuses are the statuses of a task that has not reached an outcome
+// yet: one being monitored, one of a group deployment waiting for its turn, and
+// one waiting for the merge of its pull request. All of them can be superseded by
+// a newer deployment, and all of them go stale.
+var unfinishedStatuses = []string{models.StatusInProgressMessage, models.StatusQueuedMessage, models.StatusAwaitingMergeMessage}
+
+// monitoredStatuses are the statuses of a task a replica watches, and so holds a
+// lease on: one whose rollout is polled, and one whose pull request is. A queued task
+// is watched by the rollout of its group instead.
+var monitoredStatuses = []string{models.StatusInProgressMessage, models.StatusAwaitingMergeMessage}
+
+// initialStatus is the status a task is stored with: in progress, unless it is a
+// group task that waits for a rollout slot.
+func initialStatus(task models.Task) string {
+	if task.Status == models.StatusQueuedMessage {
+		return models.StatusQueuedMessage
+	}
+	return models.StatusInProgressMessage
+}
+
 // maySupersede reports whether a deployment may cancel an in-flight task, by
 // comparing the credential each one presented. Only the uncredentialed-cancels-
 // credentialed direction is refused.
@@ -26,8 +46,13 @@ func maySupersede(newTaskValidated, inFlightValidated bool) bool {
 
 // imageNamesOverlap reports whether the two image slices share at least one
 // image name (the repository, ignoring the tag). It is used to decide whether a
-// new deployment supersedes an in-progress one for the same app.
+// new deployment supersedes an in-progress one for the same app. Two empty slices
+// overlap: only chart version tasks carry no images, and a newer version of the
+// app's chart supersedes an older one.
 func imageNamesOverlap(a, b []models.Image) bool {
+	if len(a) == 0 && len(b) == 0 {
+		return true
+	}
 	names := make(map[string]struct{}, len(a))
 	for _, img := range a {
 		names[img.Image] = struct{}{}
@@ -47,8 +72,13 @@ type TaskRepository interface {
 	GetTasks(startTime float64, endTime float64, app string, status string, limit int, offset int) ([]models.Task, int64)
 	GetTask(id string) (*models.Task, error)
 	SetTaskStatus(id, status, reason string) error
-	// CancelInProgressTasks marks in-progress tasks for the given app as
-	// cancelled and returns how many were affected. A task is only cancelled when
+	// SetTaskProgress replaces the status reason of a task that is still in
+	// progress, and leaves any other task untouched. Progress is written on
+	// every poll that observes a change, so unlike SetTaskStatus it must not
+	// race a cancellation written by a newer deployment in the meantime.
+	SetTaskProgress(id, reason string) error
+	// CancelInProgressTasks marks in-progress (and queued or awaiting merge) tasks
+	// for the given app as cancelled and returns how many were affected. A task is only cancelled when
 	// it shares at least one image name with the supplied images, so independent
 	// per-image deployments of the same app do not cancel each other (issue #353).
 	// Tags are ignored on purpose: a newer tag of the same image must still
@@ -63,6 +93,26 @@ type TaskRepository interface {
 	Check() bool
 	ProcessObsoleteTasks(retryTimes uint)
 
+	// StartQueuedTask moves a queued task of a group deployment to in progress and
+	// claims it for this instance, and reports whether it did. A false return means
+	// the task is no longer queued — a newer deployment cancelled it, or it went
+	// stale — and must not be monitored.
+	StartQueuedTask(id string) (bool, error)
+	// AwaitMerge moves an in-progress task to awaiting merge with the given reason,
+	// stores the pull request it awaits, and reports whether it did. A false return
+	// means the task is no longer in progress — a newer deployment cancelled it — and
+	// its pull request is stale.
+	AwaitMerge(id, reason string, pullRequest models.PullRequest) (bool, error)
+	// StartMergedTask moves a task whose pull request was merged back to in
+	// progress, and reports whether it did. Like StartQueuedTask, a false return
+	// means the task was cancelled while it waited and must not be monitored.
+	StartMergedTask(id string) (bool, error)
+	// RecordWriteBack stores what is known of the commit the task's write-back made.
+	RecordWriteBack(id string, writeBack models.WriteBack) error
+	// GetGroupTasks returns the tasks created for a group deployment, oldest first.
+	// An unknown group yields an empty slice.
+	GetGroupTasks(groupId string) ([]models.Task, error)
+
 	// ClaimTask records this instance as the one monitoring the task, for as long
 	// as it keeps renewing the claim.
 	ClaimTask(id string) error
@@ -74,8 +124,8 @@ type TaskRepository interface {
 	// was monitoring are taken over immediately instead of after the lease lapses,
 	// and reports how many were given up.
 	ReleaseOwnedLeases() (int64, error)
-	// ClaimExpiredTasks takes over up to limit in-progress tasks whose lease has
-	// lapsed, and returns them ready to be monitored again. The returned tasks
+	// ClaimExpiredTasks takes over up to limit in-progress or awaiting-merge tasks
+	// whose lease has lapsed, and returns them ready to be monitored again. The returned tasks
 	// carry the authority and overrides the rollout acts on, which the API-facing
 	// tasks deliberately do not.
 	ClaimExpiredTasks(limit int) ([]models.Task, error)
@@ -2,6 +2,7 @@ package state_models
 
 import (
 	"database/sql"
+	"encoding/json"
 	"time"
 
 	"github.com/google/uuid"
@@ -36,6 +37,25 @@ type TaskModel struct {
 	// expired is available for another replica to claim and resume.
 	OwnerId        sql.NullString `gorm:"column:owner_id;"`
 	LeaseExpiresAt sql.NullTime   `gorm:"column:lease_expires_at;"`
+	// GroupId links the tasks created for one group deployment, empty for a task
+	// submitted on its own.
+	GroupId string `gorm:"column:group_id;not null;default:'';"`
+	// RollbackOfId links an automatic rollback to the failed task it reverts.
+	RollbackOfId string `gorm:"column:rollback_of_id;not null;default:'';"`
+	// CommitSha, SigningKey and SyncedRevision describe the task's write-back commit
+	// and the revision ArgoCD synced afterwards; all are empty when it made none.
+	CommitSha      string `gorm:"column:commit_sha;not null;default:'';"`
+	SigningKey     string `gorm:"column:signing_key;not null;default:'';"`
+	SyncedRevision string `gorm:"column:synced_revision;not null;default:'';"`
+	// Parameters are the Helm parameters written back next to the image tags.
+	// Persisted so a task resumed by another replica writes them back too.
+	Parameters datatypes.JSONSlice[models.Parameter] `gorm:"column:parameters;type:jsonb;not null;default:'[]';"`
+	// ChartVersion is the chart version a chart version task writes back; empty for a
+	// task deploying images.
+	ChartVersion string `gorm:"column:chart_version;not null;default:'';"`
+	// PullRequest is the pull request the write-back was proposed in, as JSON, stored
+	// when the task starts awaiting its merge. NULL for a write-back pushed directly.
+	PullRequest datatypes.JSON `gorm:"column:pull_request;type:jsonb;"`
 }
 
 func (TaskModel) TableName() string {
@@ -56,10 +76,38 @@ func (ormTask *TaskModel) ConvertToExternalTask() *models.Task {
 		Author:           ormTask.Author.String,
 		Project:          ormTask.Project.String,
 		Images:           ormTask.Images,
+		Parameters:       ormTask.Parameters,
+		ChartVersion:     ormTask.ChartVersion,
 		Status:           ormTask.Status,
 		StatusReason:     ormTask.StatusReason.String,
 		IsRollback:       ormTask.IsRollback,
 		RollbackTargetId: ormTask.RollbackTargetId,
+		GroupId:          ormTask.GroupId,
+		RollbackOfId:     ormTask.RollbackOfId,
+		WriteBack:        ormTask.convertWriteBack(),
+		PullRequest:      ormTask.convertPullRequest(),
+	}
+}
+
+// convertPullRequest decodes the pull request column, or returns nil when it holds
+// none or cannot be read.
+func (ormTask *TaskModel) convertPullRequest() *models.PullRequest {
+	var pullRequest *models.PullRequest
+	if len(ormTask.PullRequest) == 0 || json.Unmarshal(ormTask.PullRequest, &pullRequest) != nil {
+		return nil
+	}
+	return pullRequest
+}
+
+// convertWriteBack gathers the write-back columns, or returns nil when none is set.
+func (ormTask *TaskModel) convertWriteBack() *models.WriteBack {
+	if ormTask.CommitSha == "" && ormTask.SigningKey == "" && ormTask.SyncedRevision == "" {
+		return nil
+	}
+	return &models.WriteBack{
+		CommitSha:      ormTask.CommitSha,
+		SigningKey:     ormTask.SigningKey,
+		SyncedRevision: ormTask.SyncedRevision,
 	}
 }
 
@@ -0,0 +1,51 @@
+package state
+
+import (
+	"github.com/google/uuid"
+
+	"github.com/shini4i/argo-watcher/internal/models"
+)
+
+// RecordWriteBack stores what is known of the commit the task's write-back made. A
+// field left empty keeps the value stored before, as models.WriteBack.Merge does.
+func (state *PostgresState) RecordWriteBack(id string, writeBack models.WriteBack) error {
+	if _, err := uuid.Parse(id); err != nil {
+		return ErrTaskNotFound
+	}
+
+	result := state.orm.Exec(`
+		UPDATE tasks
+		SET commit_sha = COALESCE(NULLIF(?, ''), commit_sha),
+			signing_key = COALESCE(NULLIF(?, ''), signing_key),
+			synced_revision = COALESCE(NULLIF(?, ''), synced_revision)
+		WHERE id = ?`,
+		writeBack.CommitSha, writeBack.SigningKey, writeBack.SyncedRevision, id)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrTaskNotFound
+	}
+	return nil
+}
+
+// RecordWriteBack stores what is known of the commit the task's write-back made.
+func (state *InMemoryState) RecordWriteBack(id string, writeBack models.WriteBack) error {
+	state.mu.Lock()
+	defer state.mu.Unlock()
+
+	for idx := range state.tasks {
+		if state.tasks[idx].Id == id {
+			// Merged into a copy: the stored task may share its WriteBack with one a
+			// caller holds.
+			merged := models.WriteBack{}
+			if state.tasks[idx].WriteBack != nil {
+				merged = *state.tasks[idx].WriteBack
+			}
+			merged.Merge(writeBack)
+			state.tasks[idx].WriteBack = &merged
+			return nil
+		}
+	}
+	return ErrTaskNotFound
+}
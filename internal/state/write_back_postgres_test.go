@@ -0,0 +1,37 @@
+package state
+
+import (
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/shini4i/argo-watcher/internal/models"
+)
+
+func TestPostgresState_RecordWriteBack(t *testing.T) {
+	env := newPostgresTestEnv(t)
+
+	task := env.addTask(t, createTestTask("app-a"))
+
+	got, err := env.state.GetTask(task.Id)
+	require.NoError(t, err)
+	assert.Nil(t, got.WriteBack, "a task without a write-back commit reports none")
+
+	writeBack := models.WriteBack{CommitSha: "3f78685", SigningKey: "openpgp:6E3A"}
+	require.NoError(t, env.state.RecordWriteBack(task.Id, writeBack))
+
+	got, err = env.state.GetTask(task.Id)
+	require.NoError(t, err)
+	assert.Equal(t, &writeBack, got.WriteBack)
+
+	require.NoError(t, env.state.RecordWriteBack(task.Id, models.WriteBack{SyncedRevision: "3f78685"}))
+	stored := env.storedModel(t, task.Id)
+	assert.Equal(t, "3f78685", stored.CommitSha, "an empty field keeps the stored value")
+	assert.Equal(t, "openpgp:6E3A", stored.SigningKey)
+	assert.Equal(t, "3f78685", stored.SyncedRevision)
+
+	assert.ErrorIs(t, env.state.RecordWriteBack(uuid.NewString(), writeBack), ErrTaskNotFound)
+	assert.ErrorIs(t, env.state.RecordWriteBack("not-a-uuid", writeBack), ErrTaskNotFound)
+}
@@ -0,0 +1,36 @@
+package state
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/shini4i/argo-watcher/internal/models"
+)
+
+func TestInMemoryState_RecordWriteBack(t *testing.T) {
+	state := InMemoryState{}
+
+	task, err := state.AddTask(createTestTask("app-a"))
+	require.NoError(t, err)
+
+	got, err := state.GetTask(task.Id)
+	require.NoError(t, err)
+	assert.Nil(t, got.WriteBack)
+
+	writeBack := models.WriteBack{CommitSha: "3f78685", SigningKey: "ssh:SHA256:abc"}
+	require.NoError(t, state.RecordWriteBack(task.Id, writeBack))
+
+	got, err = state.GetTask(task.Id)
+	require.NoError(t, err)
+	assert.Equal(t, &writeBack, got.WriteBack)
+
+	require.NoError(t, state.RecordWriteBack(task.Id, models.WriteBack{SyncedRevision: "3f78685"}))
+	got, err = state.GetTask(task.Id)
+	require.NoError(t, err)
+	assert.Equal(t, &models.WriteBack{CommitSha: "3f78685", SigningKey: "ssh:SHA256:abc", SyncedRevision: "3f78685"}, got.WriteBack,
+		"the synced revision is added to what was recorded before")
+
+	assert.ErrorIs(t, state.RecordWriteBack("non-existent-id", writeBack), ErrTaskNotFound)
+}
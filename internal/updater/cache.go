@@ -0,0 +1,139 @@
+package updater
+
+import (
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"io"
+	"io/fs"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+
+	"github.com/go-git/go-git/v5"
+)
+
+// CacheEntry is one clone in the repository cache.
+type CacheEntry struct {
+	Path string
+	// RepoURL is the remote the clone was made from, empty when the clone is too broken
+	// to tell.
+	RepoURL string
+	// Size is the size of the clone on disk in bytes.
+	Size int64
+	// LastUsed is when a write-back last cloned into or fetched the clone. It is the
+	// modification time of the clone's directory, which Clone touches.
+	LastUsed time.Time
+}
+
+// CachePath returns the directory under root that caches the clone of branch of
+// repoURL. Hashing URL+branch gives filesystem-level isolation, so concurrent
+// operations on different branches of the same repository do not conflict.
+func CachePath(root, repoURL, branch string) string {
+	hasher := fnv.New64a()
+	// The Write method on hash.Hash is documented to never return an error.
+	_, _ = io.WriteString(hasher, fmt.Sprintf("%s-%s", repoURL, branch))
+	return filepath.Join(root, strconv.FormatUint(hasher.Sum64(), 16))
+}
+
+// IsCached reports whether root holds a clone of branch of repoURL.
+func IsCached(root, repoURL, branch string) bool {
+	_, err := os.Stat(CachePath(root, repoURL, branch))
+	return err == nil
+}
+
+// ListCache returns the clones in the repository cache under root. A missing root is
+// an empty cache.
+func ListCache(root string) ([]CacheEntry, error) {
+	dirs, err := os.ReadDir(root)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read the repository cache %s: %w", root, err)
+	}
+
+	var entries []CacheEntry
+	for _, dir := range dirs {
+		if !dir.IsDir() {
+			continue
+		}
+		info, err := dir.Info()
+		if err != nil {
+			// Removed since the directory was read.
+			continue
+		}
+		path := filepath.Join(root, dir.Name())
+		entries = append(entries, CacheEntry{
+			Path:     path,
+			RepoURL:  cachedRemote(path),
+			Size:     diskUsage(path),
+			LastUsed: info.ModTime(),
+		})
+	}
+	return entries, nil
+}
+
+// EvictCache removes entry from the repository cache under root. The caller holds the
+// per-repo lock of entry.RepoURL, so no write-back is using the clone; a clone used
+// again since it was listed is kept, and EvictCache reports whether it removed it.
+func EvictCache(root string, entry CacheEntry) (bool, error) {
+	if err := assertInsideRoot(root, entry.Path); err != nil {
+		return false, fmt.Errorf("cache entry %q is not inside the repository cache %q; refusing to remove", entry.Path, root)
+	}
+
+	info, err := os.Stat(entry.Path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	if info.ModTime().After(entry.LastUsed) {
+		return false, nil
+	}
+
+	if err := os.RemoveAll(entry.Path); err != nil {
+		return false, fmt.Errorf("failed to remove cached repository %s: %w", entry.Path, err)
+	}
+	return true, nil
+}
+
+// markCacheUsed records that a write-back used the clone at path.
+func markCacheUsed(path string) {
+	now := time.Now()
+	if err := os.Chtimes(path, now, now); err != nil {
+		slog.Debug("Failed to mark the cached repository as used", "path", path, "error", err)
+	}
+}
+
+// cachedRemote returns the origin URL of the clone at path, or "" when it has none.
+func cachedRemote(path string) string {
+	repo, err := git.PlainOpen(path)
+	if err != nil {
+		return ""
+	}
+	remote, err := repo.Remote("origin")
+	if err != nil || len(remote.Config().URLs) == 0 {
+		return ""
+	}
+	return remote.Config().URLs[0]
+}
+
+// diskUsage sums the size of the files under path. Files that vanish during the walk
+// are not counted.
+func diskUsage(path string) int64 {
+	var size int64
+	_ = filepath.WalkDir(path, func(_ string, entry fs.DirEntry, err error) error {
+		if err != nil || entry.IsDir() {
+			return nil
+		}
+		if info, err := entry.Info(); err == nil {
+			size += info.Size()
+		}
+		return nil
+	})
+	return size
+}
@@ -0,0 +1,105 @@
+package updater
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// cacheClone creates a clone of branch of repoURL under root holding size bytes, last
+// used at lastUsed.
+func cacheClone(t *testing.T, root, repoURL, branch string, size int, lastUsed time.Time) string {
+	t.Helper()
+	path := CachePath(root, repoURL, branch)
+	repo, err := git.PlainInit(path, false)
+	require.NoError(t, err)
+	_, err = repo.CreateRemote(&config.RemoteConfig{Name: "origin", URLs: []string{repoURL}})
+	require.NoError(t, err)
+	require.NoError(t, os.WriteFile(filepath.Join(path, "values.yaml"), make([]byte, size), 0o600))
+	require.NoError(t, os.Chtimes(path, lastUsed, lastUsed))
+	return path
+}
+
+func TestListCache(t *testing.T) {
+	t.Run("A missing cache is empty", func(t *testing.T) {
+		entries, err := ListCache(filepath.Join(t.TempDir(), "missing"))
+		require.NoError(t, err)
+		assert.Empty(t, entries)
+	})
+
+	t.Run("Lists every clone", func(t *testing.T) {
+		root := t.TempDir()
+		lastUsed := time.Now().Add(-time.Hour).Truncate(time.Second)
+		path := cacheClone(t, root, "git@example.com:org/gitops.git", "main", 4096, lastUsed)
+		broken := filepath.Join(root, "broken")
+		require.NoError(t, os.Mkdir(broken, 0o750))
+		require.NoError(t, os.WriteFile(filepath.Join(root, "stray-file"), nil, 0o600))
+
+		entries, err := ListCache(root)
+		require.NoError(t, err)
+		require.Len(t, entries, 2)
+
+		byPath := map[string]CacheEntry{}
+		for _, entry := range entries {
+			byPath[entry.Path] = entry
+		}
+		assert.Equal(t, "git@example.com:org/gitops.git", byPath[path].RepoURL)
+		assert.GreaterOrEqual(t, byPath[path].Size, int64(4096))
+		assert.True(t, byPath[path].LastUsed.Equal(lastUsed))
+		assert.Empty(t, byPath[broken].RepoURL, "a clone without a remote has no URL")
+	})
+}
+
+func TestEvictCache(t *testing.T) {
+	lastUsed := time.Now().Add(-time.Hour).Truncate(time.Second)
+
+	t.Run("Removes the clone", func(t *testing.T) {
+		root := t.TempDir()
+		path := cacheClone(t, root, "git@example.com:org/gitops.git", "main", 16, lastUsed)
+
+		removed, err := EvictCache(root, CacheEntry{Path: path, LastUsed: lastUsed})
+		require.NoError(t, err)
+		assert.True(t, removed)
+		assert.NoDirExists(t, path)
+	})
+
+	t.Run("Keeps a clone used since it was listed", func(t *testing.T) {
+		root := t.TempDir()
+		path := cacheClone(t, root, "git@example.com:org/gitops.git", "main", 16, lastUsed)
+		markCacheUsed(path)
+
+		removed, err := EvictCache(root, CacheEntry{Path: path, LastUsed: lastUsed})
+		require.NoError(t, err)
+		assert.False(t, removed)
+		assert.DirExists(t, path)
+	})
+
+	t.Run("A clone already gone is not an error", func(t *testing.T) {
+		root := t.TempDir()
+		removed, err := EvictCache(root, CacheEntry{Path: filepath.Join(root, "gone"), LastUsed: lastUsed})
+		require.NoError(t, err)
+		assert.False(t, removed)
+	})
+
+	t.Run("Refuses a path outside the cache", func(t *testing.T) {
+		outside := t.TempDir()
+		removed, err := EvictCache(t.TempDir(), CacheEntry{Path: outside, LastUsed: lastUsed})
+		require.Error(t, err)
+		assert.False(t, removed)
+		assert.DirExists(t, outside)
+	})
+}
+
+func TestIsCached(t *testing.T) {
+	root := t.TempDir()
+	cacheClone(t, root, "git@example.com:org/gitops.git", "main", 16, time.Now())
+
+	assert.True(t, IsCached(root, "git@example.com:org/gitops.git", "main"))
+	assert.False(t, IsCached(root, "git@example.com:org/gitops.git", "release"))
+}
@@ -1,9 +1,11 @@
 package updater
 
 import (
+	"errors"
 	"fmt"
 	"log/slog"
 	"os"
+	"strings"
 	"time"
 
 	envConfig "github.com/caarlos0/env/v11"
@@ -14,11 +16,36 @@ import (
 // GitConfig holds runtime configuration for the git updater, parsed from
 // environment variables on startup.
 type GitConfig struct {
-	SshKeyPath          string `env:"SSH_KEY_PATH,required"`
+	// SshKeyPath is the key SSH remotes are authenticated with. It is required unless
+	// HTTPS credentials are configured instead.
+	SshKeyPath          string `env:"SSH_KEY_PATH"`
 	SshKeyPass          string `env:"SSH_KEY_PASS"`
 	SshCommitUser       string `env:"SSH_COMMIT_USER" envDefault:"argo-watcher"`
 	SshCommitMail       string `env:"SSH_COMMIT_MAIL" envDefault:"argo-watcher@example.com"`
 	CommitMessageFormat string `env:"COMMIT_MESSAGE_FORMAT"`
+	// SshKnownHosts lists the known_hosts files SSH remotes are verified against,
+	// ~/.ssh/known_hosts and /etc/ssh/ssh_known_hosts by default.
+	SshKnownHosts []string `env:"SSH_KNOWN_HOSTS" envSeparator:":"`
+	// SshHostKeys pins the host key of a repository host, optionally with its port,
+	// in authorized_keys format. A pinned key takes precedence over the files.
+	SshHostKeys map[string]string `env:"SSH_HOST_KEYS" envKeyValSeparator:"="`
+	// HttpsCredentials maps a repository host, optionally with its port, to the file
+	// holding the token HTTPS remotes on that host are authenticated with. The file is
+	// re-read before every clone, so a short-lived token can be rotated in place.
+	HttpsCredentials map[string]string `env:"GIT_HTTPS_CREDENTIALS" envKeyValSeparator:"="`
+	// HttpsUsernames maps a repository host to the user its token is sent with,
+	// x-access-token by default.
+	HttpsUsernames map[string]string `env:"GIT_HTTPS_USERNAMES" envKeyValSeparator:"="`
+	// HttpAllowInsecure lets a token be sent to an http:// remote, in plaintext. Off by
+	// default, which fails the write-back to such a remote instead.
+	HttpAllowInsecure bool `env:"GIT_HTTP_ALLOW_INSECURE" envDefault:"false"`
+	// SigningFormat turns on commit signing: "openpgp" or "ssh", as git's gpg.format.
+	// Empty leaves write-back commits unsigned.
+	SigningFormat string `env:"GIT_SIGNING_FORMAT"`
+	// SigningKeyPath is the private key commits are signed with: an OpenPGP key ring,
+	// armored or binary, or an OpenSSH private key.
+	SigningKeyPath string `env:"GIT_SIGNING_KEY_PATH"`
+	SigningKeyPass string `env:"GIT_SIGNING_KEY_PASS"`
 	// GitOpTimeout bounds a single clone+update attempt. Per-attempt (not total)
 	// timeout is deliberate: it lets retries actually succeed when the first
 	// attempt times out on a slow remote. The worst-case wall clock for the full
@@ -41,7 +68,7 @@ type GitConfig struct {
 
 // BatchConfig holds the settings for the optional contention-coalescing batch
 // write-back mode. It is parsed independently of GitConfig because it must be
-// readable at server startup, whereas GitConfig requires SSH_KEY_PATH which is
+// readable at server startup, whereas GitConfig requires a git credential which is
 // only set on deployments that actually use git write-back.
 type BatchConfig struct {
 	// Enabled turns on batch write-back. When false (the default) each app is
@@ -53,6 +80,85 @@ type BatchConfig struct {
 	MaxSize uint `env:"GIT_BATCH_MAX_SIZE" envDefault:"20"`
 }
 
+// CacheConfig holds the limits of the repository cache under REPO_CACHE_PATH, which
+// otherwise keeps a clone of every repository and branch ever written back to. Like
+// BatchConfig it is parsed on its own and has no required fields.
+type CacheConfig struct {
+	// MaxSizeMB bounds the size of the cache in megabytes: the least recently used
+	// clones are evicted until it fits. Zero leaves the size unbounded.
+	MaxSizeMB uint `env:"REPO_CACHE_MAX_SIZE_MB" envDefault:"0"`
+	// MaxAge evicts a clone no write-back used for that long. Zero keeps clones
+	// however long they are unused.
+	MaxAge time.Duration `env:"REPO_CACHE_MAX_AGE" envDefault:"720h"`
+	// SweepInterval is the time between two sweeps of the cache.
+	SweepInterval time.Duration `env:"REPO_CACHE_SWEEP_INTERVAL" envDefault:"1h"`
+}
+
+// Pull request providers accepted by GIT_PR_PROVIDER.
+const (
+	PullRequestProviderGitea  = "gitea"
+	PullRequestProviderGitHub = "github"
+	PullRequestProviderGitLab = "gitlab"
+)
+
+// PullRequestConfig holds the settings of the pull-request write-back method, in
+// which a change is pushed to a branch of its own and proposed through the provider's
+// API instead of being pushed to the application's branch. Like BatchConfig it is
+// parsed on its own and has no required fields, so servers that push directly start
+// without any of them.
+type PullRequestConfig struct {
+	// Provider names the API pull requests are opened through. Empty disables the
+	// method: an application asking for it fails its write-back instead.
+	Provider string `env:"GIT_PR_PROVIDER"`
+	// ApiUrl is the provider's API root, such as https://gitea.example.com/api/v1,
+	// https://api.github.com or https://gitlab.example.com/api/v4.
+	ApiUrl string `env:"GIT_PR_API_URL"`
+	Token  string `env:"GIT_PR_TOKEN"`
+	// PollInterval is the time between two looks at a pull request awaiting merge.
+	PollInterval time.Duration `env:"GIT_PR_POLL_INTERVAL" envDefault:"30s"`
+	// MergeTimeout bounds how long a task awaits the merge of its pull request before
+	// it fails.
+	MergeTimeout time.Duration `env:"GIT_PR_MERGE_TIMEOUT" envDefault:"30m"`
+}
+
+// NewPullRequestConfig loads PullRequestConfig from environment variables. The API
+// URL and token are only required once a provider is set.
+func NewPullRequestConfig() (*PullRequestConfig, error) {
+	config, err := envConfig.ParseAs[PullRequestConfig]()
+	if err != nil {
+		return nil, helpers.PrettifyEnvError(err, "invalid argo-watcher pull request configuration:")
+	}
+
+	config.Provider = strings.ToLower(strings.TrimSpace(config.Provider))
+	config.ApiUrl = strings.TrimSuffix(strings.TrimSpace(config.ApiUrl), "/")
+	config.Token = strings.TrimSpace(config.Token)
+
+	if config.Provider == "" {
+		return &config, nil
+	}
+
+	switch config.Provider {
+	case PullRequestProviderGitea, PullRequestProviderGitHub, PullRequestProviderGitLab:
+	default:
+		return nil, fmt.Errorf("GIT_PR_PROVIDER must be one of %q, %q or %q, got %q",
+			PullRequestProviderGitea, PullRequestProviderGitHub, PullRequestProviderGitLab, config.Provider)
+	}
+	if config.ApiUrl == "" {
+		return nil, fmt.Errorf("GIT_PR_API_URL is required when GIT_PR_PROVIDER is set")
+	}
+	if config.Token == "" {
+		return nil, fmt.Errorf("GIT_PR_TOKEN is required when GIT_PR_PROVIDER is set")
+	}
+	if config.PollInterval <= 0 {
+		return nil, fmt.Errorf("GIT_PR_POLL_INTERVAL must be > 0, got %s", config.PollInterval)
+	}
+	if config.MergeTimeout <= 0 {
+		return nil, fmt.Errorf("GIT_PR_MERGE_TIMEOUT must be > 0, got %s", config.MergeTimeout)
+	}
+
+	return &config, nil
+}
+
 // NewBatchConfig loads BatchConfig from environment variables. It has no
 // required fields, so it is safe to call at server startup even when git
 // write-back is not configured. When batching is enabled, MaxSize must be > 0.
@@ -69,6 +175,23 @@ func NewBatchConfig() (*BatchConfig, error) {
 	return &config, nil
 }
 
+// NewCacheConfig loads CacheConfig from environment variables.
+func NewCacheConfig() (*CacheConfig, error) {
+	config, err := envConfig.ParseAs[CacheConfig]()
+	if err != nil {
+		return nil, helpers.PrettifyEnvError(err, "invalid argo-watcher repository cache configuration:")
+	}
+
+	if config.MaxAge < 0 {
+		return nil, fmt.Errorf("REPO_CACHE_MAX_AGE must not be negative, got %s", config.MaxAge)
+	}
+	if config.SweepInterval <= 0 {
+		return nil, fmt.Errorf("REPO_CACHE_SWEEP_INTERVAL must be > 0, got %s", config.SweepInterval)
+	}
+
+	return &config, nil
+}
+
 // NewGitConfig loads GitConfig from environment variables and applies the
 // backward-compat mapping for the deprecated GIT_TIMEOUT variable.
 //
@@ -83,6 +206,25 @@ func NewGitConfig() (*GitConfig, error) {
 		return nil, helpers.PrettifyEnvError(err, "invalid argo-watcher git updater configuration:")
 	}
 
+	if config.SshKeyPath == "" && len(config.HttpsCredentials) == 0 {
+		return nil, errors.New("invalid argo-watcher git updater configuration:\nmissing required environment variables:\n  - SSH_KEY_PATH or GIT_HTTPS_CREDENTIALS")
+	}
+
+	if _, err := parseHostKeys(config.SshHostKeys); err != nil {
+		return nil, err
+	}
+
+	config.SigningFormat = strings.ToLower(strings.TrimSpace(config.SigningFormat))
+	switch config.SigningFormat {
+	case "":
+	case SigningFormatOpenPGP, SigningFormatSSH:
+		if config.SigningKeyPath == "" {
+			return nil, fmt.Errorf("GIT_SIGNING_KEY_PATH is required when GIT_SIGNING_FORMAT is set")
+		}
+	default:
+		return nil, fmt.Errorf("GIT_SIGNING_FORMAT must be %q or %q, got %q", SigningFormatOpenPGP, SigningFormatSSH, config.SigningFormat)
+	}
+
 	if err := applyLegacyGitTimeout(&config); err != nil {
 		return nil, err
 	}
@@ -61,6 +61,58 @@ func TestNewGitConfig(t *testing.T) {
 		assert.Nil(t, config)
 		assert.Contains(t, err.Error(), "missing required environment variables")
 		assert.Contains(t, err.Error(), "SSH_KEY_PATH")
+		assert.Contains(t, err.Error(), "GIT_HTTPS_CREDENTIALS")
+	})
+
+	t.Run("HTTPS credentials replace the SSH key", func(t *testing.T) {
+		os.Unsetenv("SSH_KEY_PATH") //nolint:errcheck
+		t.Setenv("GIT_HTTPS_CREDENTIALS", "github.com=/etc/tokens/github,gitea.example.com:3000=/etc/tokens/gitea")
+		t.Setenv("GIT_HTTPS_USERNAMES", "gitea.example.com:3000=deployer")
+
+		config, err := NewGitConfig()
+
+		require.NoError(t, err)
+		assert.Empty(t, config.SshKeyPath)
+		assert.Equal(t, map[string]string{
+			"github.com":             "/etc/tokens/github",
+			"gitea.example.com:3000": "/etc/tokens/gitea",
+		}, config.HttpsCredentials)
+		assert.Equal(t, map[string]string{"gitea.example.com:3000": "deployer"}, config.HttpsUsernames)
+	})
+
+	t.Run("Reads and normalizes the signing format", func(t *testing.T) {
+		t.Setenv("SSH_KEY_PATH", "/test/key")
+		t.Setenv("GIT_SIGNING_FORMAT", " SSH ")
+		t.Setenv("GIT_SIGNING_KEY_PATH", "/test/signing_key")
+
+		config, err := NewGitConfig()
+
+		require.NoError(t, err)
+		assert.Equal(t, SigningFormatSSH, config.SigningFormat)
+		assert.Equal(t, "/test/signing_key", config.SigningKeyPath)
+	})
+
+	t.Run("Failure - Signing format without a key", func(t *testing.T) {
+		t.Setenv("SSH_KEY_PATH", "/test/key")
+		t.Setenv("GIT_SIGNING_FORMAT", "openpgp")
+
+		config, err := NewGitConfig()
+
+		require.Error(t, err)
+		assert.Nil(t, config)
+		assert.Contains(t, err.Error(), "GIT_SIGNING_KEY_PATH")
+	})
+
+	t.Run("Failure - Unknown signing format", func(t *testing.T) {
+		t.Setenv("SSH_KEY_PATH", "/test/key")
+		t.Setenv("GIT_SIGNING_FORMAT", "x509")
+		t.Setenv("GIT_SIGNING_KEY_PATH", "/test/signing_key")
+
+		config, err := NewGitConfig()
+
+		require.Error(t, err)
+		assert.Nil(t, config)
+		assert.Contains(t, err.Error(), "GIT_SIGNING_FORMAT")
 	})
 
 	t.Run("Failure - Malformed GIT_OP_TIMEOUT", func(t *testing.T) {
@@ -150,6 +202,92 @@ func TestNewBatchConfig(t *testing.T) {
 	})
 }
 
+func TestNewCacheConfig(t *testing.T) {
+	t.Run("Defaults to a 30 day age limit and no size limit", func(t *testing.T) {
+		config, err := NewCacheConfig()
+
+		require.NoError(t, err)
+		assert.Zero(t, config.MaxSizeMB)
+		assert.Equal(t, 720*time.Hour, config.MaxAge)
+		assert.Equal(t, time.Hour, config.SweepInterval)
+	})
+
+	t.Run("Reads custom limits", func(t *testing.T) {
+		t.Setenv("REPO_CACHE_MAX_SIZE_MB", "512")
+		t.Setenv("REPO_CACHE_MAX_AGE", "0")
+		t.Setenv("REPO_CACHE_SWEEP_INTERVAL", "10m")
+
+		config, err := NewCacheConfig()
+
+		require.NoError(t, err)
+		assert.Equal(t, uint(512), config.MaxSizeMB)
+		assert.Zero(t, config.MaxAge)
+		assert.Equal(t, 10*time.Minute, config.SweepInterval)
+	})
+
+	t.Run("Failure - Zero sweep interval", func(t *testing.T) {
+		t.Setenv("REPO_CACHE_SWEEP_INTERVAL", "0s")
+
+		config, err := NewCacheConfig()
+
+		require.Error(t, err)
+		assert.Nil(t, config)
+		assert.Contains(t, err.Error(), "REPO_CACHE_SWEEP_INTERVAL")
+	})
+
+	t.Run("Failure - Negative age", func(t *testing.T) {
+		t.Setenv("REPO_CACHE_MAX_AGE", "-1h")
+
+		config, err := NewCacheConfig()
+
+		require.Error(t, err)
+		assert.Nil(t, config)
+		assert.Contains(t, err.Error(), "REPO_CACHE_MAX_AGE")
+	})
+}
+
+func TestNewPullRequestConfig(t *testing.T) {
+	t.Run("Disabled without a provider", func(t *testing.T) {
+		config, err := NewPullRequestConfig()
+
+		require.NoError(t, err)
+		assert.Empty(t, config.Provider)
+		assert.Equal(t, 30*time.Second, config.PollInterval)
+		assert.Equal(t, 30*time.Minute, config.MergeTimeout)
+	})
+
+	t.Run("Reads and normalizes a provider", func(t *testing.T) {
+		t.Setenv("GIT_PR_PROVIDER", " Gitea ")
+		t.Setenv("GIT_PR_API_URL", "http://gitea.example.com/api/v1/")
+		t.Setenv("GIT_PR_TOKEN", "secret")
+
+		config, err := NewPullRequestConfig()
+
+		require.NoError(t, err)
+		assert.Equal(t, PullRequestProviderGitea, config.Provider)
+		assert.Equal(t, "http://gitea.example.com/api/v1", config.ApiUrl)
+	})
+
+	t.Run("Failure - Unknown provider", func(t *testing.T) {
+		t.Setenv("GIT_PR_PROVIDER", "bitbucket")
+
+		_, err := NewPullRequestConfig()
+
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "GIT_PR_PROVIDER")
+	})
+
+	t.Run("Failure - Provider without a token", func(t *testing.T) {
+		t.Setenv("GIT_PR_PROVIDER", "github")
+		t.Setenv("GIT_PR_API_URL", "https://api.github.com")
+
+		_, err := NewPullRequestConfig()
+
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "GIT_PR_TOKEN")
+	})
+}
+
 func TestLegacyGitTimeoutMapping(t *testing.T) {
 	t.Run("GIT_TIMEOUT alone is used directly as GIT_OP_TIMEOUT", func(t *testing.T) {
 		t.Setenv("SSH_KEY_PATH", "/test/key")
@@ -0,0 +1,70 @@
+package updater
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/go-git/go-git/v5/plumbing/storer"
+	"github.com/go-git/go-git/v5/storage/memory"
+)
+
+// historyDepth is how many of the branch's latest commits ContainsCommit reads. A
+// revision ArgoCD synced while a write-back is awaited is one of the few commits made
+// since, so the commit it is asked about is well within this.
+const historyDepth = 50
+
+// ContainsCommit reports whether revision, a commit of the repository's branch, is
+// commit or a descendant of it: whether a checkout of revision includes what commit
+// changed. A revision or commit older than the branch's latest historyDepth commits
+// reads as not included.
+//
+// The history is fetched into memory rather than into the cache: the cache is kept
+// shallow and is only touched under the per-repo lock, which a read has no need of.
+func (repo *GitRepo) ContainsCommit(ctx context.Context, revision, commit string) (bool, error) {
+	if revision == commit {
+		return true, nil
+	}
+
+	if err := repo.loadAuth(); err != nil {
+		return false, err
+	}
+
+	history, err := git.CloneContext(ctx, memory.NewStorage(), nil, &git.CloneOptions{
+		URL:           repo.RepoURL,
+		ReferenceName: plumbing.NewBranchReferenceName(repo.BranchName),
+		SingleBranch:  true,
+		Depth:         historyDepth,
+		Tags:          git.NoTags,
+		Auth:          repo.auth,
+	})
+	if err != nil {
+		return false, fmt.Errorf("failed to fetch the history of %s: %w", repo.BranchName, err)
+	}
+
+	tip, err := history.CommitObject(plumbing.NewHash(revision))
+	if errors.Is(err, plumbing.ErrObjectNotFound) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+
+	target := plumbing.NewHash(commit)
+	found := false
+	err = object.NewCommitPreorderIter(tip, nil, nil).ForEach(func(ancestor *object.Commit) error {
+		if ancestor.Hash == target {
+			found = true
+			return storer.ErrStop
+		}
+		return nil
+	})
+	// The walk ends on a missing object where the fetched history is cut off.
+	if err != nil && !errors.Is(err, plumbing.ErrObjectNotFound) {
+		return false, err
+	}
+	return found, nil
+}
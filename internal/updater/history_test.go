@@ -0,0 +1,66 @@
+package updater
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
+
+	"github.com/shini4i/argo-watcher/internal/mocks"
+)
+
+func TestContainsCommit(t *testing.T) {
+	sourcePath := t.TempDir()
+	source, err := git.PlainInit(sourcePath, false)
+	require.NoError(t, err)
+	worktree, err := source.Worktree()
+	require.NoError(t, err)
+
+	commit := func(message string) string {
+		hash, err := worktree.Commit(message, &git.CommitOptions{
+			Author:            &object.Signature{Name: "Test", Email: "test@example.com", When: time.Now()},
+			AllowEmptyCommits: true,
+		})
+		require.NoError(t, err)
+		return hash.String()
+	}
+	before := commit("initial commit")
+	writeBack := commit("argo-watcher(demo): update image tag")
+	after := commit("argo-watcher(other): update image tag")
+
+	ctrl := gomock.NewController(t)
+	handler := mocks.NewMockGitHandler(ctrl)
+	handler.EXPECT().AddSSHKey(gomock.Any(), gomock.Any(), gomock.Any()).Return(nil, nil).AnyTimes()
+	repo := newTestRepo(t, handler)
+	repo.RepoURL = sourcePath
+	repo.BranchName = "master"
+	ctx := context.Background()
+
+	for _, tc := range []struct {
+		name     string
+		revision string
+		want     bool
+	}{
+		{"The commit itself", writeBack, true},
+		{"A later commit of the branch", after, true},
+		{"An earlier commit of the branch", before, false},
+		{"A revision the branch does not have", "3f786850e387550fdab836ed7e6dc881de23001b", false},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			contains, err := repo.ContainsCommit(ctx, tc.revision, writeBack)
+			require.NoError(t, err)
+			assert.Equal(t, tc.want, contains)
+		})
+	}
+
+	t.Run("An unreachable remote is an error", func(t *testing.T) {
+		repo.RepoURL = t.TempDir()
+		_, err := repo.ContainsCommit(ctx, after, writeBack)
+		assert.Error(t, err)
+	})
+}
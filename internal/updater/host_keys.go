@@ -0,0 +1,121 @@
+package updater
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+
+	"github.com/go-git/go-git/v5/plumbing/transport"
+	gogitssh "github.com/go-git/go-git/v5/plumbing/transport/ssh"
+	cryptossh "golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/knownhosts"
+)
+
+// HostKeyError refuses an SSH remote whose host key is not trusted: it is listed
+// neither in the known_hosts files nor among the pinned keys, or it differs from
+// the key trusted for that host. Either way the server may not be the one the
+// write-back is meant for, so nothing is sent to it, and retrying cannot help.
+type HostKeyError struct {
+	Host        string
+	Fingerprint string
+	// Mismatch tells a host whose key differs from the one trusted for it from a
+	// host not trusted at all.
+	Mismatch bool
+}
+
+func (err *HostKeyError) Error() string {
+	if err.Mismatch {
+		return fmt.Sprintf("ssh: host key %s of %s does not match the key trusted for it", err.Fingerprint, err.Host)
+	}
+	return fmt.Sprintf("ssh: host key %s of %s is not trusted", err.Fingerprint, err.Host)
+}
+
+// Reason renders the user-facing task failure reason.
+func (err *HostKeyError) Reason() string {
+	what := "is unknown"
+	if err.Mismatch {
+		what = "does not match the key trusted for it, which may mean the server is being impersonated"
+	}
+	return fmt.Sprintf("Application deployment failed. The git write-back was refused: the SSH host key of %s (%s) %s. "+
+		"If the server is genuine, add its key to SSH_KNOWN_HOSTS or SSH_HOST_KEYS.", err.Host, err.Fingerprint, what)
+}
+
+// parseHostKeys parses the pinned keys of SSH_HOST_KEYS, each in authorized_keys
+// format ("ssh-ed25519 AAAA...").
+func parseHostKeys(entries map[string]string) (map[string]cryptossh.PublicKey, error) {
+	keys := make(map[string]cryptossh.PublicKey, len(entries))
+	for host, line := range entries {
+		key, _, _, _, err := cryptossh.ParseAuthorizedKey([]byte(line))
+		if err != nil {
+			return nil, fmt.Errorf("SSH_HOST_KEYS: invalid key for host %q: %w", host, err)
+		}
+		keys[host] = key
+	}
+	return keys, nil
+}
+
+// verifyHostKeys makes auth check the host key of the remote at repoURL against the
+// pinned keys and the known_hosts files. A key pinned for the host takes precedence
+// over the files, and is the only key the remote is asked to present.
+func (config *GitConfig) verifyHostKeys(auth *gogitssh.PublicKeys, repoURL string) error {
+	endpoint, err := transport.NewEndpoint(repoURL)
+	if err != nil {
+		return fmt.Errorf("invalid repository URL %q: %w", repoURL, err)
+	}
+	port := endpoint.Port
+	if port <= 0 {
+		port = gogitssh.DefaultPort
+	}
+	hostWithPort := net.JoinHostPort(endpoint.Host, strconv.Itoa(port))
+
+	pinned, err := parseHostKeys(config.SshHostKeys)
+	if err != nil {
+		return err
+	}
+
+	// The default files are optional once keys are pinned: without any, only the
+	// pinned hosts are trusted. Files SSH_KNOWN_HOSTS names are never optional, or a
+	// typo in the path would quietly leave every host without a pin untrusted.
+	db, dbErr := gogitssh.NewKnownHostsDb(config.SshKnownHosts...)
+	if dbErr != nil && len(config.SshKnownHosts) > 0 {
+		return fmt.Errorf("failed to load SSH_KNOWN_HOSTS: %w", dbErr)
+	}
+
+	auth.HostKeyCallback = func(hostname string, remote net.Addr, key cryptossh.PublicKey) error {
+		fingerprint := cryptossh.FingerprintSHA256(key)
+		if host, value := lookupHost(config.SshHostKeys, hostname); value != "" {
+			if bytes.Equal(pinned[host].Marshal(), key.Marshal()) {
+				return nil
+			}
+			return &HostKeyError{Host: hostname, Fingerprint: fingerprint, Mismatch: true}
+		}
+
+		if db == nil {
+			return &HostKeyError{Host: hostname, Fingerprint: fingerprint}
+		}
+		err := db.HostKeyCallback()(hostname, remote, key)
+		var keyErr *knownhosts.KeyError
+		if errors.As(err, &keyErr) {
+			return &HostKeyError{Host: hostname, Fingerprint: fingerprint, Mismatch: len(keyErr.Want) > 0}
+		}
+		return err
+	}
+
+	if host, value := lookupHost(config.SshHostKeys, hostWithPort); value != "" {
+		auth.HostKeyAlgorithms = hostKeyAlgorithms(pinned[host].Type())
+	} else if db != nil {
+		auth.HostKeyAlgorithms = db.HostKeyAlgorithms(hostWithPort)
+	}
+	return nil
+}
+
+// hostKeyAlgorithms lists the algorithms a server may sign with using a key of
+// keyType. An RSA key signs with the SHA-2 algorithms rather than its own name.
+func hostKeyAlgorithms(keyType string) []string {
+	if keyType == cryptossh.KeyAlgoRSA {
+		return []string{cryptossh.KeyAlgoRSASHA512, cryptossh.KeyAlgoRSASHA256, cryptossh.KeyAlgoRSA}
+	}
+	return []string{keyType}
+}
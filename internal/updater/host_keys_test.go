@@ -0,0 +1,114 @@
+package updater
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	gogitssh "github.com/go-git/go-git/v5/plumbing/transport/ssh"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	cryptossh "golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/knownhosts"
+)
+
+func newHostKey(t *testing.T) cryptossh.PublicKey {
+	t.Helper()
+	pub, _, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+	key, err := cryptossh.NewPublicKey(pub)
+	require.NoError(t, err)
+	return key
+}
+
+func authorizedKey(key cryptossh.PublicKey) string {
+	return strings.TrimSpace(string(cryptossh.MarshalAuthorizedKey(key)))
+}
+
+func TestVerifyHostKeys(t *testing.T) {
+	trusted, other := newHostKey(t), newHostKey(t)
+	remote := &net.TCPAddr{IP: net.IPv4(192, 0, 2, 1), Port: 22}
+
+	knownHosts := filepath.Join(t.TempDir(), "known_hosts")
+	line := knownhosts.Line([]string{knownhosts.Normalize("git.example.com:22")}, trusted)
+	require.NoError(t, os.WriteFile(knownHosts, []byte(line+"\n"), 0600))
+
+	config := &GitConfig{
+		SshKnownHosts: []string{knownHosts},
+		SshHostKeys:   map[string]string{"gitea.example.com:2222": authorizedKey(trusted)},
+	}
+
+	verify := func(t *testing.T, repoURL, hostname string, key cryptossh.PublicKey) (*gogitssh.PublicKeys, error) {
+		t.Helper()
+		auth := &gogitssh.PublicKeys{}
+		require.NoError(t, config.verifyHostKeys(auth, repoURL))
+		return auth, auth.HostKeyCallback(hostname, remote, key)
+	}
+
+	t.Run("A key listed in known_hosts is trusted", func(t *testing.T) {
+		auth, err := verify(t, "git@git.example.com:org/gitops.git", "git.example.com:22", trusted)
+		assert.NoError(t, err)
+		assert.Equal(t, []string{cryptossh.KeyAlgoED25519}, auth.HostKeyAlgorithms)
+	})
+
+	t.Run("A pinned key is trusted and is the only one asked for", func(t *testing.T) {
+		auth, err := verify(t, "ssh://git@gitea.example.com:2222/org/gitops.git", "gitea.example.com:2222", trusted)
+		assert.NoError(t, err)
+		assert.Equal(t, []string{cryptossh.KeyAlgoED25519}, auth.HostKeyAlgorithms)
+	})
+
+	t.Run("A key differing from the trusted one is a permanent mismatch", func(t *testing.T) {
+		for _, hostname := range []string{"git.example.com:22", "gitea.example.com:2222"} {
+			_, err := verify(t, "git@git.example.com:org/gitops.git", hostname, other)
+			var hostKeyErr *HostKeyError
+			require.ErrorAs(t, err, &hostKeyErr)
+			assert.True(t, hostKeyErr.Mismatch)
+			assert.Equal(t, hostname, hostKeyErr.Host)
+			assert.Equal(t, cryptossh.FingerprintSHA256(other), hostKeyErr.Fingerprint)
+			assert.True(t, IsPermanent(err))
+			assert.Contains(t, hostKeyErr.Reason(), hostKeyErr.Fingerprint)
+		}
+	})
+
+	t.Run("An unknown host is refused", func(t *testing.T) {
+		_, err := verify(t, "git@unknown.example.com:org/gitops.git", "unknown.example.com:22", trusted)
+		var hostKeyErr *HostKeyError
+		require.ErrorAs(t, err, &hostKeyErr)
+		assert.False(t, hostKeyErr.Mismatch)
+		assert.Contains(t, hostKeyErr.Reason(), "unknown.example.com:22")
+	})
+
+	t.Run("Only pinned hosts are trusted without a known_hosts file", func(t *testing.T) {
+		t.Setenv("SSH_KNOWN_HOSTS", "")
+		t.Setenv("HOME", t.TempDir())
+		pinnedOnly := &GitConfig{SshHostKeys: map[string]string{"gitea.example.com": authorizedKey(trusted)}}
+		auth := &gogitssh.PublicKeys{}
+		require.NoError(t, pinnedOnly.verifyHostKeys(auth, "ssh://git@gitea.example.com:2222/org/gitops.git"))
+		assert.NoError(t, auth.HostKeyCallback("gitea.example.com:2222", remote, trusted))
+
+		var hostKeyErr *HostKeyError
+		assert.ErrorAs(t, auth.HostKeyCallback("git.example.com:22", remote, trusted), &hostKeyErr)
+	})
+
+	t.Run("A missing known_hosts file fails without pinned keys", func(t *testing.T) {
+		missing := &GitConfig{SshKnownHosts: []string{filepath.Join(t.TempDir(), "missing")}}
+		assert.ErrorContains(t, missing.verifyHostKeys(&gogitssh.PublicKeys{}, "git@git.example.com:org/gitops.git"), "SSH_KNOWN_HOSTS")
+	})
+
+	t.Run("A missing known_hosts file fails even with pinned keys", func(t *testing.T) {
+		missing := &GitConfig{
+			SshKnownHosts: []string{filepath.Join(t.TempDir(), "missing")},
+			SshHostKeys:   map[string]string{"gitea.example.com": authorizedKey(trusted)},
+		}
+		assert.ErrorContains(t, missing.verifyHostKeys(&gogitssh.PublicKeys{}, "ssh://git@gitea.example.com:2222/org/gitops.git"), "SSH_KNOWN_HOSTS")
+	})
+}
+
+func TestParseHostKeys(t *testing.T) {
+	_, err := parseHostKeys(map[string]string{"git.example.com": "not a key"})
+	assert.ErrorContains(t, err, "git.example.com")
+}
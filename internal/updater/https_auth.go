@@ -0,0 +1,87 @@
+package updater
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/go-git/go-git/v5/plumbing/transport/http"
+)
+
+// ErrHTTPSCredentialNotConfigured is returned when an HTTPS remote's host has no
+// entry in GIT_HTTPS_CREDENTIALS.
+var ErrHTTPSCredentialNotConfigured = errors.New("no HTTPS credential configured for host")
+
+// ErrInsecureHTTPRemote is returned for an http:// remote while GIT_HTTP_ALLOW_INSECURE
+// is off: its token would be sent in plaintext.
+var ErrInsecureHTTPRemote = errors.New("refusing to send a token to an http:// remote; use https:// or set GIT_HTTP_ALLOW_INSECURE")
+
+// defaultHTTPSUsername is sent with a token when GIT_HTTPS_USERNAMES names no user for
+// the host. GitHub requires it for App installation tokens, and GitHub and GitLab
+// accept any non-empty user alongside a personal access token.
+const defaultHTTPSUsername = "x-access-token"
+
+// isHTTPRemote reports whether repoURL is reached over HTTP(S) rather than SSH.
+func isHTTPRemote(repoURL string) bool {
+	lower := strings.ToLower(repoURL)
+	return strings.HasPrefix(lower, "https://") || strings.HasPrefix(lower, "http://")
+}
+
+// httpsAuth returns the token authentication for an HTTPS remote, keyed by its host.
+// A plain http:// remote is refused unless HttpAllowInsecure is set, as the token
+// would travel in the clear.
+// The token file is read on every call rather than once per GitRepo, so a short-lived
+// token rotated on disk — a GitHub App installation token refreshed by a sidecar, say —
+// is picked up by the next clone without a restart.
+func (config *GitConfig) httpsAuth(repoURL string) (*http.BasicAuth, error) {
+	parsed, err := url.Parse(repoURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid repository URL %q: %w", repoURL, err)
+	}
+	if strings.EqualFold(parsed.Scheme, "http") && !config.HttpAllowInsecure {
+		return nil, fmt.Errorf("%w: %s", ErrInsecureHTTPRemote, parsed.Host)
+	}
+
+	host, tokenPath := lookupHost(config.HttpsCredentials, parsed.Host)
+	if tokenPath == "" {
+		return nil, fmt.Errorf("%w: %s", ErrHTTPSCredentialNotConfigured, parsed.Host)
+	}
+
+	token, err := os.ReadFile(filepath.Clean(tokenPath))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read the HTTPS token for %s: %w", host, err)
+	}
+	password := strings.TrimSpace(string(token))
+	if password == "" {
+		return nil, fmt.Errorf("the HTTPS token file for %s is empty: %s", host, tokenPath)
+	}
+
+	_, username := lookupHost(config.HttpsUsernames, parsed.Host)
+	if username == "" {
+		username = defaultHTTPSUsername
+	}
+	return &http.BasicAuth{Username: username, Password: password}, nil
+}
+
+// lookupHost finds the entry for host in a map keyed by repository host. An entry
+// naming the host with its port wins over one naming the host alone, so one server
+// can serve two ports with different credentials. Hosts compare case-insensitively.
+func lookupHost(entries map[string]string, host string) (string, string) {
+	candidates := []string{host}
+	if hostname, _, err := net.SplitHostPort(host); err == nil {
+		candidates = append(candidates, hostname)
+	}
+
+	for _, candidate := range candidates {
+		for key, value := range entries {
+			if strings.EqualFold(strings.TrimSpace(key), candidate) {
+				return key, strings.TrimSpace(value)
+			}
+		}
+	}
+	return host, ""
+}
@@ -0,0 +1,91 @@
+package updater
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestIsHTTPRemote(t *testing.T) {
+	assert.True(t, isHTTPRemote("https://github.com/example/gitops.git"))
+	assert.True(t, isHTTPRemote("HTTP://gitea:3000/org/gitops.git"))
+	assert.False(t, isHTTPRemote("git@github.com:example/gitops.git"))
+	assert.False(t, isHTTPRemote("ssh://git@gitea:2222/org/gitops.git"))
+}
+
+func TestHttpsAuth(t *testing.T) {
+	dir := t.TempDir()
+	writeToken := func(name, content string) string {
+		path := filepath.Join(dir, name)
+		require.NoError(t, os.WriteFile(path, []byte(content), 0600))
+		return path
+	}
+
+	config := &GitConfig{
+		HttpsCredentials: map[string]string{
+			"GitHub.com":             writeToken("github", "ghs_token\n"),
+			"gitea.example.com":      writeToken("gitea", "gitea-default"),
+			"gitea.example.com:3000": writeToken("gitea-3000", "gitea-3000"),
+			"empty.example.com":      writeToken("empty", "\n"),
+			"missing.example.com":    filepath.Join(dir, "missing"),
+		},
+		HttpsUsernames: map[string]string{"gitea.example.com:3000": "deployer", "GITHUB.COM": "ci-bot"},
+	}
+
+	t.Run("Hosts compare case-insensitively, with the default username", func(t *testing.T) {
+		auth, err := config.httpsAuth("https://gitea.example.com/org/gitops.git")
+		require.NoError(t, err)
+		assert.Equal(t, "x-access-token", auth.Username)
+		assert.Equal(t, "gitea-default", auth.Password)
+	})
+
+	t.Run("A username is looked up like the credential", func(t *testing.T) {
+		auth, err := config.httpsAuth("https://github.com:443/example/gitops.git")
+		require.NoError(t, err)
+		assert.Equal(t, "ci-bot", auth.Username)
+		assert.Equal(t, "ghs_token", auth.Password)
+	})
+
+	t.Run("An entry with the port wins over the host alone", func(t *testing.T) {
+		auth, err := config.httpsAuth("https://gitea.example.com:3000/org/gitops.git")
+		require.NoError(t, err)
+		assert.Equal(t, "deployer", auth.Username)
+		assert.Equal(t, "gitea-3000", auth.Password)
+
+		auth, err = config.httpsAuth("https://gitea.example.com:8443/org/gitops.git")
+		require.NoError(t, err)
+		assert.Equal(t, "gitea-default", auth.Password)
+	})
+
+	t.Run("A token is not sent to an http:// remote unless allowed", func(t *testing.T) {
+		_, err := config.httpsAuth("HTTP://github.com/example/gitops.git")
+		assert.ErrorIs(t, err, ErrInsecureHTTPRemote)
+		assert.True(t, IsPermanent(err))
+
+		insecure := *config
+		insecure.HttpAllowInsecure = true
+		auth, err := insecure.httpsAuth("http://github.com/example/gitops.git")
+		require.NoError(t, err)
+		assert.Equal(t, "ghs_token", auth.Password)
+	})
+
+	t.Run("A host without a credential is a permanent error", func(t *testing.T) {
+		_, err := config.httpsAuth("https://gitlab.example.com/group/gitops.git")
+		assert.ErrorIs(t, err, ErrHTTPSCredentialNotConfigured)
+		assert.True(t, IsPermanent(err))
+	})
+
+	t.Run("An unreadable or empty token file is retried", func(t *testing.T) {
+		_, err := config.httpsAuth("https://missing.example.com/org/gitops.git")
+		require.Error(t, err)
+		assert.False(t, IsPermanent(err), "the file may be caught mid-rotation")
+
+		_, err = config.httpsAuth("https://empty.example.com/org/gitops.git")
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "empty")
+		assert.False(t, IsPermanent(err))
+	})
+}
@@ -24,9 +24,14 @@ var ErrSSHKeyEmpty = errors.New("SSH key file is empty")
 
 // IsPermanent reports whether err describes a failure that retrying cannot fix.
 //
-// Two classes are treated as permanent:
-//   - SSH key pre-flight validation errors (ErrSSHKeyNotProvided, ErrSSHKeyNotFound,
-//     ErrSSHKeyEmpty): the key is missing or unreadable on every attempt.
+// Three classes are treated as permanent:
+//   - Credential pre-flight errors (ErrSSHKeyNotProvided, ErrSSHKeyNotFound,
+//     ErrSSHKeyEmpty, ErrHTTPSCredentialNotConfigured, ErrInsecureHTTPRemote): the key
+//     is missing or unreadable, the host has no token, or the token may not be sent
+//     to it, on every attempt. An unreadable token file is not among them, as it may
+//     be caught mid-rotation.
+//   - An untrusted SSH host key (HostKeyError): the server presents the same key
+//     on every attempt, and sending it the write-back is exactly what must not happen.
 //   - Git transport authentication errors (transport.ErrAuthenticationRequired,
 //     transport.ErrAuthorizationFailed): the server rejected our credentials and
 //     will continue to do so regardless of how many times we retry.
@@ -37,9 +42,13 @@ func IsPermanent(err error) bool {
 	if err == nil {
 		return false
 	}
+	var hostKeyErr *HostKeyError
 	return errors.Is(err, ErrSSHKeyNotProvided) ||
 		errors.Is(err, ErrSSHKeyNotFound) ||
 		errors.Is(err, ErrSSHKeyEmpty) ||
+		errors.Is(err, ErrHTTPSCredentialNotConfigured) ||
+		errors.Is(err, ErrInsecureHTTPRemote) ||
+		errors.As(err, &hostKeyErr) ||
 		errors.Is(err, transport.ErrAuthenticationRequired) ||
 		errors.Is(err, transport.ErrAuthorizationFailed)
 }
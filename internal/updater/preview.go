@@ -0,0 +1,104 @@
+package updater
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/pmezard/go-difflib/difflib"
+)
+
+// previewDiffContext is the number of unchanged lines shown around each change.
+const previewDiffContext = 3
+
+// ChangePreview is the change CommitAppLocal would commit for an app, worked out
+// without writing to the clone.
+type ChangePreview struct {
+	// Paths are the files the write-back writes, relative to the repository root.
+	Paths []string
+	// CommitMessage is the message the commit would carry; empty when there is
+	// nothing to commit.
+	CommitMessage string
+	// Diff is the unified diff of the files that change; empty when they already hold
+	// the new content.
+	Diff string
+}
+
+// PreviewApp works out what CommitAppLocal would commit for an app on top of the
+// cloned branch — the same merge with the existing override file, the same values
+// file edits, the same rendering and the same commit message — and leaves the clone
+// untouched. Like CommitAppLocal, it expects Clone to have run.
+func (repo *GitRepo) PreviewApp(appName, path, fileName string, overrideContent *ArgoOverrideFile, tmplData any) (*ChangePreview, error) {
+	files, err := repo.appFiles(appName, path, fileName, overrideContent)
+	if err != nil {
+		return nil, err
+	}
+
+	preview := &ChangePreview{}
+	var diffs []string
+	for _, file := range files {
+		relativePath, err := filepath.Rel(repo.localRepoPath, file.fullPath)
+		if err != nil {
+			return nil, fmt.Errorf("could not determine relative path: %w", err)
+		}
+		relativePath = filepath.ToSlash(relativePath)
+		preview.Paths = append(preview.Paths, relativePath)
+
+		existing, err := os.ReadFile(file.fullPath) // #nosec G304 -- path validated by assertInsideRoot in appFiles
+		exists := err == nil
+		if err != nil && !os.IsNotExist(err) {
+			return nil, fmt.Errorf("failed to read %s: %w", relativePath, err)
+		}
+
+		// The same comparison commitLocal makes to skip a file.
+		if exists && bytes.Equal(existing, file.content) {
+			continue
+		}
+
+		diff, err := unifiedDiff(relativePath, existing, file.content, exists)
+		if err != nil {
+			return nil, err
+		}
+		diffs = append(diffs, diff)
+	}
+
+	if len(diffs) == 0 {
+		return preview, nil
+	}
+
+	preview.CommitMessage = repo.generateCommitMessage(appName, tmplData)
+	preview.Diff = strings.Join(diffs, "")
+	return preview, nil
+}
+
+// unifiedDiff renders the change from before to after in the format of git diff,
+// with /dev/null standing for a file that does not exist yet.
+func unifiedDiff(path string, before, after []byte, exists bool) (string, error) {
+	fromFile := "a/" + path
+	if !exists {
+		fromFile = "/dev/null"
+	}
+
+	return difflib.GetUnifiedDiffString(difflib.UnifiedDiff{
+		A:        splitLines(before),
+		B:        splitLines(after),
+		FromFile: fromFile,
+		ToFile:   "b/" + path,
+		Context:  previewDiffContext,
+	})
+}
+
+// splitLines splits content into lines that keep their newline. Unlike
+// difflib.SplitLines, it does not turn the final newline into an extra empty line.
+func splitLines(content []byte) []string {
+	if len(content) == 0 {
+		return nil
+	}
+	lines := strings.SplitAfter(string(content), "\n")
+	if lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	return lines
+}
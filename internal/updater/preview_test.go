@@ -0,0 +1,99 @@
+package updater
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPreviewApp(t *testing.T) {
+	newParams := func(tag string) *ArgoOverrideFile {
+		params := &ArgoOverrideFile{}
+		params.Helm.Parameters = []ArgoParameterOverride{{Name: "image.tag", Value: tag}}
+		return params
+	}
+
+	setup := func(t *testing.T) (*GitRepo, string) {
+		_, _, localRepo, _, localPath := setupGitForTest(t)
+
+		repo := newTestRepo(t, &GitClient{})
+		repo.localRepo = localRepo
+		repo.localRepoPath = localPath
+		require.NoError(t, os.MkdirAll(filepath.Join(localPath, "apps"), 0755))
+		return repo, localPath
+	}
+
+	t.Run("New override file", func(t *testing.T) {
+		repo, localPath := setup(t)
+
+		preview, err := repo.PreviewApp("app-a", "apps", "", newParams("v1.0.0"), nil)
+		require.NoError(t, err)
+
+		assert.Equal(t, []string{"apps/.argocd-source-app-a.yaml"}, preview.Paths)
+		assert.Equal(t, "argo-watcher(app-a): update image tag", preview.CommitMessage)
+		assert.Contains(t, preview.Diff, "--- /dev/null")
+		assert.Contains(t, preview.Diff, "@@ -0,0 +1,5 @@")
+		assert.Contains(t, preview.Diff, "+++ b/apps/.argocd-source-app-a.yaml")
+		assert.Contains(t, preview.Diff, "+          value: v1.0.0\n")
+
+		_, err = os.Stat(filepath.Join(localPath, preview.Paths[0]))
+		assert.True(t, os.IsNotExist(err), "a preview must not write the override file")
+	})
+
+	t.Run("Changed override file", func(t *testing.T) {
+		repo, localPath := setup(t)
+		_, err := repo.CommitAppLocal("app-a", "apps", "", newParams("v1.0.0"), nil)
+		require.NoError(t, err)
+		before, err := os.ReadFile(filepath.Join(localPath, "apps/.argocd-source-app-a.yaml"))
+		require.NoError(t, err)
+
+		preview, err := repo.PreviewApp("app-a", "apps", "", newParams("v2.0.0"), nil)
+		require.NoError(t, err)
+
+		assert.Contains(t, preview.Diff, "--- a/apps/.argocd-source-app-a.yaml")
+		assert.Contains(t, preview.Diff, "-          value: v1.0.0\n")
+		assert.Contains(t, preview.Diff, "+          value: v2.0.0\n")
+
+		after, err := os.ReadFile(filepath.Join(localPath, "apps/.argocd-source-app-a.yaml"))
+		require.NoError(t, err)
+		assert.Equal(t, before, after, "a preview must leave the override file untouched")
+	})
+
+	t.Run("Unchanged override file", func(t *testing.T) {
+		repo, _ := setup(t)
+		_, err := repo.CommitAppLocal("app-a", "apps", "", newParams("v1.0.0"), nil)
+		require.NoError(t, err)
+
+		preview, err := repo.PreviewApp("app-a", "apps", "", newParams("v1.0.0"), nil)
+		require.NoError(t, err)
+
+		assert.Equal(t, []string{"apps/.argocd-source-app-a.yaml"}, preview.Paths)
+		assert.Empty(t, preview.CommitMessage)
+		assert.Empty(t, preview.Diff)
+	})
+
+	t.Run("Values file", func(t *testing.T) {
+		repo, localPath := setup(t)
+		require.NoError(t, os.WriteFile(filepath.Join(localPath, "apps/values-prod.yaml"), []byte("image:\n  tag: v1.0.0 # CI\n"), 0600))
+
+		content := &ArgoOverrideFile{ValuesFiles: []ValuesFileUpdate{{File: "values-prod.yaml", Path: "image.tag", Value: "v2.0.0"}}}
+		preview, err := repo.PreviewApp("app-a", "apps", "", content, nil)
+		require.NoError(t, err)
+
+		assert.Equal(t, []string{"apps/values-prod.yaml"}, preview.Paths, "only values files leave the override file alone")
+		assert.Contains(t, preview.Diff, "-  tag: v1.0.0 # CI\n")
+		assert.Contains(t, preview.Diff, "+  tag: v2.0.0 # CI\n")
+	})
+
+	t.Run("Path traversal rejected", func(t *testing.T) {
+		repo := newTestRepo(t, nil)
+		repo.localRepoPath = t.TempDir()
+
+		_, err := repo.PreviewApp("app-a", "apps", "../../../../etc/passwd", newParams("v1.0.0"), nil)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "not inside repository root")
+	})
+}
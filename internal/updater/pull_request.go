@@ -0,0 +1,378 @@
+package updater
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// pullRequestRequestTimeout bounds a single call to the provider's API.
+const pullRequestRequestTimeout = 30 * time.Second
+
+// maxPullRequestResponseBytes bounds how much of a provider response is read.
+const maxPullRequestResponseBytes = 1 << 20
+
+// PullRequest is a pull request (a merge request on GitLab) opened for a write-back.
+type PullRequest struct {
+	// RepoURL is the repository the pull request was opened in, as cloned.
+	RepoURL string
+	Number  int
+	URL     string
+	// Branch is the branch the write-back was pushed to, merged into Base.
+	Branch string
+	Base   string
+}
+
+// PullRequestState is what the provider reports of a pull request.
+type PullRequestState struct {
+	Merged bool
+	// Closed is set for a pull request closed without being merged.
+	Closed bool
+	// Mergeable reports that nothing keeps the pull request from being merged: it has
+	// no conflict, and its checks passed (or it has none).
+	Mergeable bool
+	// MergeCommit is the commit a merged pull request landed as on its base branch,
+	// when the provider reports one. A squash merge leaves the commit of the
+	// write-back out of the base branch's history; this one is in it.
+	MergeCommit string
+}
+
+// PullRequestProvider opens and follows pull requests through a git hosting API.
+type PullRequestProvider interface {
+	Open(ctx context.Context, repoURL, branch, base, title, body string) (*PullRequest, error)
+	State(ctx context.Context, pr *PullRequest) (PullRequestState, error)
+	Merge(ctx context.Context, pr *PullRequest) error
+	Close(ctx context.Context, pr *PullRequest) error
+	// DeleteBranch deletes the branch of a pull request that was merged or closed. A
+	// branch that is already gone is not an error.
+	DeleteBranch(ctx context.Context, pr *PullRequest) error
+}
+
+// NewPullRequestProvider returns the provider config names, or nil when it names none.
+func NewPullRequestProvider(config *PullRequestConfig) PullRequestProvider {
+	client := &http.Client{Timeout: pullRequestRequestTimeout}
+
+	switch config.Provider {
+	case PullRequestProviderGitea, PullRequestProviderGitHub:
+		return &githubProvider{api: pullRequestAPI{baseUrl: config.ApiUrl, client: client, authHeader: "Authorization", authValue: "token " + config.Token}, gitea: config.Provider == PullRequestProviderGitea}
+	case PullRequestProviderGitLab:
+		return &gitlabProvider{api: pullRequestAPI{baseUrl: config.ApiUrl, client: client, authHeader: "PRIVATE-TOKEN", authValue: config.Token}}
+	default:
+		return nil
+	}
+}
+
+// repositoryPath returns the "owner/name" path of a repository from its clone URL,
+// in any of the scp-like (git@host:owner/name.git), ssh:// and http(s):// forms.
+func repositoryPath(repoURL string) (string, error) {
+	path := repoURL
+	if strings.Contains(repoURL, "://") {
+		parsed, err := url.Parse(repoURL)
+		if err != nil {
+			return "", fmt.Errorf("invalid repository URL %q: %w", repoURL, err)
+		}
+		path = parsed.Path
+	} else if _, scpPath, found := strings.Cut(repoURL, ":"); found {
+		path = scpPath
+	}
+
+	path = strings.TrimSuffix(strings.Trim(path, "/"), ".git")
+	if !strings.Contains(path, "/") {
+		return "", fmt.Errorf("cannot tell the owner and name of repository %q", repoURL)
+	}
+	return path, nil
+}
+
+// pullRequestAPI sends JSON requests to a provider's API.
+type pullRequestAPI struct {
+	baseUrl    string
+	client     *http.Client
+	authHeader string
+	authValue  string
+}
+
+// do sends body (when not nil) as JSON and decodes the response into out (when not nil).
+// Any status outside 2xx is an error quoting the start of the response.
+func (api pullRequestAPI) do(ctx context.Context, method, path string, body, out any) error {
+	var reader io.Reader
+	if body != nil {
+		payload, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		reader = bytes.NewReader(payload)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, api.baseUrl+path, reader)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/json")
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	req.Header.Set(api.authHeader, api.authValue)
+
+	resp, err := api.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if closeErr := resp.Body.Close(); closeErr != nil {
+			slog.Error("failed to close response body", "error", closeErr)
+		}
+	}()
+
+	content, err := io.ReadAll(io.LimitReader(resp.Body, maxPullRequestResponseBytes))
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return &pullRequestAPIError{method: method, path: path, statusCode: resp.StatusCode, content: content}
+	}
+	if out == nil {
+		return nil
+	}
+	if err := json.Unmarshal(content, out); err != nil {
+		return fmt.Errorf("unexpected response to %s %s: %w", method, path, err)
+	}
+	return nil
+}
+
+// pullRequestAPIError is a response of a git hosting API outside 2xx.
+type pullRequestAPIError struct {
+	method     string
+	path       string
+	statusCode int
+	content    []byte
+}
+
+func (err *pullRequestAPIError) Error() string {
+	return fmt.Sprintf("%s %s returned HTTP %d: %.200s", err.method, err.path, err.statusCode, err.content)
+}
+
+// ignoreMissing drops an error reporting that what was addressed does not exist:
+// HTTP 404, or the 422 GitHub answers for a reference that does not.
+func ignoreMissing(err error) error {
+	var apiErr *pullRequestAPIError
+	if errors.As(err, &apiErr) && (apiErr.statusCode == http.StatusNotFound || apiErr.statusCode == http.StatusUnprocessableEntity) {
+		return nil
+	}
+	return err
+}
+
+// githubProvider speaks the GitHub pull request API, which Gitea implements too. The
+// two only differ in how a pull request is merged.
+type githubProvider struct {
+	api   pullRequestAPI
+	gitea bool
+}
+
+func (provider *githubProvider) Open(ctx context.Context, repoURL, branch, base, title, body string) (*PullRequest, error) {
+	repo, err := repositoryPath(repoURL)
+	if err != nil {
+		return nil, err
+	}
+
+	var created struct {
+		Number  int    `json:"number"`
+		HtmlUrl string `json:"html_url"`
+	}
+	request := map[string]string{"title": title, "body": body, "head": branch, "base": base}
+	if err := provider.api.do(ctx, http.MethodPost, "/repos/"+repo+"/pulls", request, &created); err != nil {
+		return nil, err
+	}
+	return &PullRequest{RepoURL: repoURL, Number: created.Number, URL: created.HtmlUrl, Branch: branch, Base: base}, nil
+}
+
+func (provider *githubProvider) State(ctx context.Context, pr *PullRequest) (PullRequestState, error) {
+	repo, err := repositoryPath(pr.RepoURL)
+	if err != nil {
+		return PullRequestState{}, err
+	}
+
+	var current struct {
+		State  string `json:"state"`
+		Merged bool   `json:"merged"`
+		// Mergeable is null on GitHub while the merge commit is being computed.
+		Mergeable *bool `json:"mergeable"`
+		// MergeableState is GitHub's summary of everything that may hold the merge
+		// back, check runs included. Gitea does not report it.
+		MergeableState string `json:"mergeable_state"`
+		MergeCommitSha string `json:"merge_commit_sha"`
+		Head           struct {
+			Sha string `json:"sha"`
+		} `json:"head"`
+	}
+	if err := provider.api.do(ctx, http.MethodGet, fmt.Sprintf("/repos/%s/pulls/%d", repo, pr.Number), nil, &current); err != nil {
+		return PullRequestState{}, err
+	}
+
+	state := PullRequestState{Merged: current.Merged, Closed: current.State == "closed" && !current.Merged}
+	// GitHub computes a merge commit for an open pull request too, which is not on
+	// the base branch until the pull request is merged.
+	if current.Merged {
+		state.MergeCommit = current.MergeCommitSha
+	}
+	if state.Merged || state.Closed || current.Mergeable == nil || !*current.Mergeable {
+		return state, nil
+	}
+
+	// Checks reported through the checks API, GitHub Actions among them, are not part
+	// of the combined status: on GitHub only a clean mergeable state says they passed.
+	// "has_hooks" is as clean, on a GitHub Enterprise with pre-receive hooks.
+	if !provider.gitea {
+		state.Mergeable = current.MergeableState == "clean" || current.MergeableState == "has_hooks"
+		return state, nil
+	}
+
+	// The combined status of the head commit sums up its checks. A commit nothing
+	// reported on is "pending" on GitHub, so the count tells it from a running check.
+	var status struct {
+		State      string `json:"state"`
+		TotalCount int    `json:"total_count"`
+	}
+	if err := provider.api.do(ctx, http.MethodGet, fmt.Sprintf("/repos/%s/commits/%s/status", repo, current.Head.Sha), nil, &status); err != nil {
+		return PullRequestState{}, err
+	}
+	state.Mergeable = status.TotalCount == 0 || status.State == "success"
+	return state, nil
+}
+
+func (provider *githubProvider) Merge(ctx context.Context, pr *PullRequest) error {
+	repo, err := repositoryPath(pr.RepoURL)
+	if err != nil {
+		return err
+	}
+
+	path := fmt.Sprintf("/repos/%s/pulls/%d/merge", repo, pr.Number)
+	if provider.gitea {
+		return provider.api.do(ctx, http.MethodPost, path, map[string]any{"Do": "merge", "delete_branch_after_merge": true}, nil)
+	}
+	return provider.api.do(ctx, http.MethodPut, path, map[string]string{"merge_method": "merge"}, nil)
+}
+
+func (provider *githubProvider) Close(ctx context.Context, pr *PullRequest) error {
+	repo, err := repositoryPath(pr.RepoURL)
+	if err != nil {
+		return err
+	}
+	return provider.api.do(ctx, http.MethodPatch, fmt.Sprintf("/repos/%s/pulls/%d", repo, pr.Number), map[string]string{"state": "closed"}, nil)
+}
+
+func (provider *githubProvider) DeleteBranch(ctx context.Context, pr *PullRequest) error {
+	repo, err := repositoryPath(pr.RepoURL)
+	if err != nil {
+		return err
+	}
+
+	path := fmt.Sprintf("/repos/%s/git/refs/heads/%s", repo, pr.Branch)
+	if provider.gitea {
+		path = fmt.Sprintf("/repos/%s/branches/%s", repo, pr.Branch)
+	}
+	return ignoreMissing(provider.api.do(ctx, http.MethodDelete, path, nil, nil))
+}
+
+// gitlabProvider speaks the GitLab merge request API.
+type gitlabProvider struct {
+	api pullRequestAPI
+}
+
+// projectPath returns the API path of the project repoURL names, addressed by its
+// URL-encoded full path.
+func (provider *gitlabProvider) projectPath(repoURL string) (string, error) {
+	repo, err := repositoryPath(repoURL)
+	if err != nil {
+		return "", err
+	}
+	return "/projects/" + url.PathEscape(repo), nil
+}
+
+func (provider *gitlabProvider) Open(ctx context.Context, repoURL, branch, base, title, body string) (*PullRequest, error) {
+	project, err := provider.projectPath(repoURL)
+	if err != nil {
+		return nil, err
+	}
+
+	var created struct {
+		Iid    int    `json:"iid"`
+		WebUrl string `json:"web_url"`
+	}
+	request := map[string]any{
+		"source_branch":        branch,
+		"target_branch":        base,
+		"title":                title,
+		"description":          body,
+		"remove_source_branch": true,
+	}
+	if err := provider.api.do(ctx, http.MethodPost, project+"/merge_requests", request, &created); err != nil {
+		return nil, err
+	}
+	return &PullRequest{RepoURL: repoURL, Number: created.Iid, URL: created.WebUrl, Branch: branch, Base: base}, nil
+}
+
+func (provider *gitlabProvider) State(ctx context.Context, pr *PullRequest) (PullRequestState, error) {
+	project, err := provider.projectPath(pr.RepoURL)
+	if err != nil {
+		return PullRequestState{}, err
+	}
+
+	var current struct {
+		State               string `json:"state"`
+		DetailedMergeStatus string `json:"detailed_merge_status"`
+		HeadPipeline        *struct {
+			Status string `json:"status"`
+		} `json:"head_pipeline"`
+		// A fast-forward merge sets neither: the commits of the branch land as they are.
+		MergeCommitSha  string `json:"merge_commit_sha"`
+		SquashCommitSha string `json:"squash_commit_sha"`
+	}
+	if err := provider.api.do(ctx, http.MethodGet, fmt.Sprintf("%s/merge_requests/%d", project, pr.Number), nil, &current); err != nil {
+		return PullRequestState{}, err
+	}
+
+	state := PullRequestState{
+		Merged:    current.State == "merged",
+		Closed:    current.State == "closed",
+		Mergeable: current.State == "opened" && current.DetailedMergeStatus == "mergeable" && (current.HeadPipeline == nil || current.HeadPipeline.Status == "success"),
+	}
+	if state.Merged {
+		state.MergeCommit = current.MergeCommitSha
+		if state.MergeCommit == "" {
+			state.MergeCommit = current.SquashCommitSha
+		}
+	}
+	return state, nil
+}
+
+func (provider *gitlabProvider) Merge(ctx context.Context, pr *PullRequest) error {
+	project, err := provider.projectPath(pr.RepoURL)
+	if err != nil {
+		return err
+	}
+	return provider.api.do(ctx, http.MethodPut, fmt.Sprintf("%s/merge_requests/%d/merge", project, pr.Number), nil, nil)
+}
+
+func (provider *gitlabProvider) Close(ctx context.Context, pr *PullRequest) error {
+	project, err := provider.projectPath(pr.RepoURL)
+	if err != nil {
+		return err
+	}
+	return provider.api.do(ctx, http.MethodPut, fmt.Sprintf("%s/merge_requests/%d", project, pr.Number), map[string]string{"state_event": "close"}, nil)
+}
+
+func (provider *gitlabProvider) DeleteBranch(ctx context.Context, pr *PullRequest) error {
+	project, err := provider.projectPath(pr.RepoURL)
+	if err != nil {
+		return err
+	}
+	return ignoreMissing(provider.api.do(ctx, http.MethodDelete, project+"/repository/branches/"+url.PathEscape(pr.Branch), nil, nil))
+}
@@ -0,0 +1,167 @@
+package updater
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRepositoryPath(t *testing.T) {
+	for repoURL, want := range map[string]string{
+		"git@github.com:example/gitops.git":            "example/gitops",
+		"ssh://git@gitea.example.com:2222/org/gitops":  "org/gitops",
+		"https://gitlab.example.com/group/sub/gitops/": "group/sub/gitops",
+	} {
+		t.Run(repoURL, func(t *testing.T) {
+			path, err := repositoryPath(repoURL)
+			require.NoError(t, err)
+			assert.Equal(t, want, path)
+		})
+	}
+
+	t.Run("A URL without an owner is rejected", func(t *testing.T) {
+		_, err := repositoryPath("git@github.com:gitops.git")
+		assert.Error(t, err)
+	})
+}
+
+func TestNewPullRequestProvider(t *testing.T) {
+	assert.Nil(t, NewPullRequestProvider(&PullRequestConfig{}))
+	assert.IsType(t, &githubProvider{}, NewPullRequestProvider(&PullRequestConfig{Provider: PullRequestProviderGitea}))
+	assert.IsType(t, &gitlabProvider{}, NewPullRequestProvider(&PullRequestConfig{Provider: PullRequestProviderGitLab}))
+}
+
+func TestGiteaPullRequestProvider(t *testing.T) {
+	var merged map[string]any
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "token secret", r.Header.Get("Authorization"))
+		switch r.Method + " " + r.URL.Path {
+		case "POST /api/v1/repos/org/gitops/pulls":
+			var request map[string]string
+			require.NoError(t, json.NewDecoder(r.Body).Decode(&request))
+			assert.Equal(t, "argo-watcher/demo/1", request["head"])
+			assert.Equal(t, "main", request["base"])
+			_, _ = w.Write([]byte(`{"number": 7, "html_url": "http://gitea/org/gitops/pulls/7"}`))
+		case "GET /api/v1/repos/org/gitops/pulls/7":
+			_, _ = w.Write([]byte(`{"state": "open", "merged": false, "mergeable": true, "merge_commit_sha": "tentative", "head": {"sha": "abc"}}`))
+		case "GET /api/v1/repos/org/gitops/pulls/8":
+			_, _ = w.Write([]byte(`{"state": "closed", "merged": true, "merge_commit_sha": "def", "head": {"sha": "abc"}}`))
+		case "GET /api/v1/repos/org/gitops/commits/abc/status":
+			_, _ = w.Write([]byte(`{"state": "pending", "total_count": 1}`))
+		case "POST /api/v1/repos/org/gitops/pulls/7/merge":
+			require.NoError(t, json.NewDecoder(r.Body).Decode(&merged))
+		case "DELETE /api/v1/repos/org/gitops/branches/argo-watcher/demo/1":
+			w.WriteHeader(http.StatusNoContent)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+			_, _ = w.Write([]byte(`{"message": "not found"}`))
+		}
+	}))
+	defer server.Close()
+
+	provider := NewPullRequestProvider(&PullRequestConfig{Provider: PullRequestProviderGitea, ApiUrl: server.URL + "/api/v1", Token: "secret"})
+	ctx := context.Background()
+
+	pr, err := provider.Open(ctx, "git@gitea:org/gitops.git", "argo-watcher/demo/1", "main", "title", "body")
+	require.NoError(t, err)
+	assert.Equal(t, 7, pr.Number)
+	assert.Equal(t, "http://gitea/org/gitops/pulls/7", pr.URL)
+
+	state, err := provider.State(ctx, pr)
+	require.NoError(t, err)
+	assert.Equal(t, PullRequestState{}, state, "a pending check keeps the pull request from being mergeable")
+
+	state, err = provider.State(ctx, &PullRequest{RepoURL: pr.RepoURL, Number: 8})
+	require.NoError(t, err)
+	assert.Equal(t, PullRequestState{Merged: true, MergeCommit: "def"}, state)
+
+	require.NoError(t, provider.Merge(ctx, pr))
+	assert.Equal(t, "merge", merged["Do"])
+	require.NoError(t, provider.DeleteBranch(ctx, pr))
+
+	err = provider.Close(ctx, &PullRequest{RepoURL: "git@gitea:org/other.git", Number: 1})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "HTTP 404")
+}
+
+func TestGitHubPullRequestProvider(t *testing.T) {
+	var deleted []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method + " " + r.URL.Path {
+		case "GET /repos/org/gitops/pulls/7":
+			_, _ = w.Write([]byte(`{"state": "open", "mergeable": true, "mergeable_state": "blocked", "head": {"sha": "abc"}}`))
+		case "GET /repos/org/gitops/pulls/8":
+			_, _ = w.Write([]byte(`{"state": "open", "mergeable": true, "mergeable_state": "clean", "head": {"sha": "def"}}`))
+		case "DELETE /repos/org/gitops/git/refs/heads/argo-watcher/demo/1":
+			deleted = append(deleted, r.URL.Path)
+			w.WriteHeader(http.StatusNoContent)
+		case "DELETE /repos/org/gitops/git/refs/heads/argo-watcher/demo/2":
+			w.WriteHeader(http.StatusUnprocessableEntity)
+			_, _ = w.Write([]byte(`{"message": "Reference does not exist"}`))
+		default:
+			// The combined status is not asked for: it leaves out the checks API.
+			t.Errorf("unexpected request %s %s", r.Method, r.URL.Path)
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	provider := NewPullRequestProvider(&PullRequestConfig{Provider: PullRequestProviderGitHub, ApiUrl: server.URL, Token: "secret"})
+	ctx := context.Background()
+
+	state, err := provider.State(ctx, &PullRequest{RepoURL: "git@github.com:org/gitops.git", Number: 7})
+	require.NoError(t, err)
+	assert.False(t, state.Mergeable, "a required check still running or failing blocks the merge")
+
+	state, err = provider.State(ctx, &PullRequest{RepoURL: "git@github.com:org/gitops.git", Number: 8})
+	require.NoError(t, err)
+	assert.True(t, state.Mergeable)
+
+	require.NoError(t, provider.DeleteBranch(ctx, &PullRequest{RepoURL: "git@github.com:org/gitops.git", Branch: "argo-watcher/demo/1"}))
+	assert.Len(t, deleted, 1)
+	assert.NoError(t, provider.DeleteBranch(ctx, &PullRequest{RepoURL: "git@github.com:org/gitops.git", Branch: "argo-watcher/demo/2"}),
+		"a branch already deleted is not an error")
+}
+
+func TestGitLabPullRequestProvider(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "secret", r.Header.Get("PRIVATE-TOKEN"))
+		switch r.Method + " " + r.URL.EscapedPath() {
+		case "POST /api/v4/projects/group%2Fgitops/merge_requests":
+			_, _ = w.Write([]byte(`{"iid": 3, "web_url": "http://gitlab/group/gitops/-/merge_requests/3"}`))
+		case "GET /api/v4/projects/group%2Fgitops/merge_requests/3":
+			_, _ = w.Write([]byte(`{"state": "opened", "detailed_merge_status": "mergeable", "head_pipeline": {"status": "success"}}`))
+		case "GET /api/v4/projects/group%2Fgitops/merge_requests/4":
+			_, _ = w.Write([]byte(`{"state": "merged", "merge_commit_sha": null, "squash_commit_sha": "def"}`))
+		case "DELETE /api/v4/projects/group%2Fgitops/repository/branches/argo-watcher%2Fdemo%2F1":
+			// Removed with the merge already: the merge request asked for it.
+			w.WriteHeader(http.StatusNotFound)
+			_, _ = w.Write([]byte(`{"message": "404 Branch Not Found"}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	provider := NewPullRequestProvider(&PullRequestConfig{Provider: PullRequestProviderGitLab, ApiUrl: server.URL + "/api/v4", Token: "secret"})
+	ctx := context.Background()
+
+	pr, err := provider.Open(ctx, "git@gitlab:group/gitops.git", "argo-watcher/demo/1", "main", "title", "body")
+	require.NoError(t, err)
+	assert.Equal(t, 3, pr.Number)
+
+	state, err := provider.State(ctx, pr)
+	require.NoError(t, err)
+	assert.Equal(t, PullRequestState{Mergeable: true}, state)
+
+	state, err = provider.State(ctx, &PullRequest{RepoURL: pr.RepoURL, Number: 4})
+	require.NoError(t, err)
+	assert.Equal(t, PullRequestState{Merged: true, MergeCommit: "def"}, state, "a squash merge lands as its squash commit")
+
+	assert.NoError(t, provider.DeleteBranch(ctx, pr), "a branch already removed is not an error")
+}
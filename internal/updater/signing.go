@@ -0,0 +1,212 @@
+package updater
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/sha512"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/ProtonMail/go-crypto/openpgp"
+	"github.com/go-git/go-git/v5"
+	cryptossh "golang.org/x/crypto/ssh"
+)
+
+// Commit signing formats accepted by GIT_SIGNING_FORMAT, named as git's gpg.format.
+const (
+	SigningFormatOpenPGP = "openpgp"
+	SigningFormatSSH     = "ssh"
+)
+
+// ErrSigningKeyInvalid is returned when the commit signing key cannot be loaded. It
+// is the same key on every attempt, so a write-back failing on it is not retried.
+var ErrSigningKeyInvalid = errors.New("invalid commit signing key")
+
+// sshSignatureNamespace is the namespace git signs commits in, and verifies them
+// against with "ssh-keygen -Y verify -n git".
+const sshSignatureNamespace = "git"
+
+// commitSigner signs write-back commits with the key of GIT_SIGNING_KEY_PATH.
+type commitSigner struct {
+	git.Signer
+	// identity names the key in the task metadata: the format followed by the key's
+	// fingerprint, such as "ssh:SHA256:..." or "openpgp:6E3A...".
+	identity string
+}
+
+// loadCommitSigner loads the signing key named by the configuration, or returns nil
+// when commits are not signed.
+func (config *GitConfig) loadCommitSigner() (*commitSigner, error) {
+	if config.SigningFormat == "" {
+		return nil, nil
+	}
+
+	keyData, err := os.ReadFile(filepath.Clean(config.SigningKeyPath))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrSigningKeyInvalid, err)
+	}
+
+	var signer *commitSigner
+	switch config.SigningFormat {
+	case SigningFormatOpenPGP:
+		signer, err = newOpenPGPSigner(keyData, config.SigningKeyPass)
+	case SigningFormatSSH:
+		signer, err = newSSHSigner(keyData, config.SigningKeyPass)
+	default:
+		err = fmt.Errorf("unsupported signing format %q", config.SigningFormat)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%w %s: %w", ErrSigningKeyInvalid, config.SigningKeyPath, err)
+	}
+	return signer, nil
+}
+
+// openPGPSigner produces the armored detached signature git stores in a commit's
+// gpgsig header.
+type openPGPSigner struct {
+	entity *openpgp.Entity
+}
+
+func (signer *openPGPSigner) Sign(message io.Reader) ([]byte, error) {
+	var signature bytes.Buffer
+	if err := openpgp.ArmoredDetachSign(&signature, signer.entity, message, nil); err != nil {
+		return nil, err
+	}
+	return signature.Bytes(), nil
+}
+
+// newOpenPGPSigner reads the first key of an armored or binary OpenPGP key ring,
+// decrypting it with passphrase when it is protected.
+func newOpenPGPSigner(keyData []byte, passphrase string) (*commitSigner, error) {
+	entities, err := openpgp.ReadArmoredKeyRing(bytes.NewReader(keyData))
+	if err != nil {
+		entities, err = openpgp.ReadKeyRing(bytes.NewReader(keyData))
+	}
+	if err != nil {
+		return nil, err
+	}
+	if len(entities) == 0 {
+		return nil, errors.New("the key ring holds no key")
+	}
+
+	entity := entities[0]
+	if entity.PrivateKey == nil {
+		return nil, errors.New("the key ring holds a public key only")
+	}
+	if entity.PrivateKey.Encrypted {
+		if passphrase == "" {
+			return nil, errors.New("the key is protected and GIT_SIGNING_KEY_PASS is not set")
+		}
+		if err := entity.DecryptPrivateKeys([]byte(passphrase)); err != nil {
+			return nil, err
+		}
+	}
+
+	return &commitSigner{
+		Signer:   &openPGPSigner{entity: entity},
+		identity: fmt.Sprintf("%s:%X", SigningFormatOpenPGP, entity.PrimaryKey.Fingerprint),
+	}, nil
+}
+
+// sshSigner produces the armored SSHSIG signature git stores in a commit's gpgsig
+// header when gpg.format is ssh, as "ssh-keygen -Y sign -n git" would.
+type sshSigner struct {
+	key cryptossh.Signer
+}
+
+// sshSignedData is the blob an SSHSIG signature covers; the message itself is only
+// present as its hash.
+type sshSignedData struct {
+	Namespace     string
+	Reserved      string
+	HashAlgorithm string
+	Hash          string
+}
+
+// sshSignatureBlob is the SSHSIG envelope, without its magic preamble.
+type sshSignatureBlob struct {
+	Version       uint32
+	PublicKey     string
+	Namespace     string
+	Reserved      string
+	HashAlgorithm string
+	Signature     string
+}
+
+func (signer *sshSigner) Sign(message io.Reader) ([]byte, error) {
+	hash := sha512.New()
+	if _, err := io.Copy(hash, message); err != nil {
+		return nil, err
+	}
+	signedData := append([]byte("SSHSIG"), cryptossh.Marshal(sshSignedData{
+		Namespace:     sshSignatureNamespace,
+		HashAlgorithm: "sha512",
+		Hash:          string(hash.Sum(nil)),
+	})...)
+
+	signature, err := signSSH(signer.key, signedData)
+	if err != nil {
+		return nil, err
+	}
+
+	blob := append([]byte("SSHSIG"), cryptossh.Marshal(sshSignatureBlob{
+		Version:       1,
+		PublicKey:     string(signer.key.PublicKey().Marshal()),
+		Namespace:     sshSignatureNamespace,
+		HashAlgorithm: "sha512",
+		Signature:     string(cryptossh.Marshal(signature)),
+	})...)
+	return armorSSHSignature(blob), nil
+}
+
+// signSSH signs data with key. An RSA key signs with rsa-sha2-512, as ssh-keygen
+// does: the SHA-1 "ssh-rsa" algorithm is refused by current verifiers.
+func signSSH(key cryptossh.Signer, data []byte) (*cryptossh.Signature, error) {
+	if algorithmSigner, ok := key.(cryptossh.AlgorithmSigner); ok && key.PublicKey().Type() == cryptossh.KeyAlgoRSA {
+		return algorithmSigner.SignWithAlgorithm(rand.Reader, data, cryptossh.KeyAlgoRSASHA512)
+	}
+	return key.Sign(rand.Reader, data)
+}
+
+// armorSSHSignature wraps blob the way ssh-keygen does, 70 columns to a line.
+func armorSSHSignature(blob []byte) []byte {
+	encoded := base64.StdEncoding.EncodeToString(blob)
+	var armored strings.Builder
+	armored.WriteString("-----BEGIN SSH SIGNATURE-----\n")
+	for len(encoded) > 70 {
+		armored.WriteString(encoded[:70] + "\n")
+		encoded = encoded[70:]
+	}
+	armored.WriteString(encoded + "\n")
+	armored.WriteString("-----END SSH SIGNATURE-----\n")
+	return []byte(armored.String())
+}
+
+// newSSHSigner parses an OpenSSH private key, decrypting it with passphrase when it
+// is protected.
+func newSSHSigner(keyData []byte, passphrase string) (*commitSigner, error) {
+	var key cryptossh.Signer
+	var err error
+	if passphrase == "" {
+		key, err = cryptossh.ParsePrivateKey(keyData)
+	} else {
+		key, err = cryptossh.ParsePrivateKeyWithPassphrase(keyData, []byte(passphrase))
+	}
+	if err != nil {
+		var passphraseErr *cryptossh.PassphraseMissingError
+		if errors.As(err, &passphraseErr) {
+			return nil, errors.New("the key is protected and GIT_SIGNING_KEY_PASS is not set")
+		}
+		return nil, err
+	}
+
+	return &commitSigner{
+		Signer:   &sshSigner{key: key},
+		identity: fmt.Sprintf("%s:%s", SigningFormatSSH, cryptossh.FingerprintSHA256(key.PublicKey())),
+	}, nil
+}
@@ -0,0 +1,195 @@
+package updater
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha512"
+	"encoding/base64"
+	"encoding/pem"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/ProtonMail/go-crypto/openpgp"
+	"github.com/ProtonMail/go-crypto/openpgp/armor"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	cryptossh "golang.org/x/crypto/ssh"
+)
+
+// writeSSHSigningKey writes key in OpenSSH format, protected with passphrase unless
+// it is empty, and returns its path.
+func writeSSHSigningKey(t *testing.T, key any, passphrase string) string {
+	t.Helper()
+	var block *pem.Block
+	var err error
+	if passphrase == "" {
+		block, err = cryptossh.MarshalPrivateKey(key, "")
+	} else {
+		block, err = cryptossh.MarshalPrivateKeyWithPassphrase(key, "", []byte(passphrase))
+	}
+	require.NoError(t, err)
+
+	path := filepath.Join(t.TempDir(), "signing_key")
+	require.NoError(t, os.WriteFile(path, pem.EncodeToMemory(block), 0600))
+	return path
+}
+
+// writeOpenPGPSigningKey creates an OpenPGP key, writes it armored, protected with
+// passphrase unless it is empty, and returns its path with the armored public key.
+func writeOpenPGPSigningKey(t *testing.T, passphrase string) (string, string) {
+	t.Helper()
+	entity, err := openpgp.NewEntity("argo-watcher", "", "argo-watcher@example.com", nil)
+	require.NoError(t, err)
+
+	var public bytes.Buffer
+	publicWriter, err := armor.Encode(&public, openpgp.PublicKeyType, nil)
+	require.NoError(t, err)
+	require.NoError(t, entity.Serialize(publicWriter))
+	require.NoError(t, publicWriter.Close())
+
+	var private bytes.Buffer
+	privateWriter, err := armor.Encode(&private, openpgp.PrivateKeyType, nil)
+	require.NoError(t, err)
+	if passphrase == "" {
+		require.NoError(t, entity.SerializePrivate(privateWriter, nil))
+	} else {
+		require.NoError(t, entity.EncryptPrivateKeys([]byte(passphrase), nil))
+		require.NoError(t, entity.SerializePrivateWithoutSigning(privateWriter, nil))
+	}
+	require.NoError(t, privateWriter.Close())
+
+	path := filepath.Join(t.TempDir(), "signing_key.asc")
+	require.NoError(t, os.WriteFile(path, private.Bytes(), 0600))
+	return path, public.String()
+}
+
+// verifySSHSignature checks an armored SSHSIG signature of message the way
+// "ssh-keygen -Y verify -n git" does, and returns the signature's format.
+func verifySSHSignature(t *testing.T, armored []byte, message string) string {
+	t.Helper()
+	text := strings.TrimSpace(string(armored))
+	require.True(t, strings.HasPrefix(text, "-----BEGIN SSH SIGNATURE-----\n"))
+	require.True(t, strings.HasSuffix(text, "\n-----END SSH SIGNATURE-----"))
+	lines := strings.Split(text, "\n")
+	for _, line := range lines[1 : len(lines)-1] {
+		assert.LessOrEqual(t, len(line), 70)
+	}
+
+	blob, err := base64.StdEncoding.DecodeString(strings.Join(lines[1:len(lines)-1], ""))
+	require.NoError(t, err)
+	require.True(t, bytes.HasPrefix(blob, []byte("SSHSIG")))
+
+	var envelope sshSignatureBlob
+	require.NoError(t, cryptossh.Unmarshal(blob[len("SSHSIG"):], &envelope))
+	assert.Equal(t, uint32(1), envelope.Version)
+	assert.Equal(t, sshSignatureNamespace, envelope.Namespace)
+
+	publicKey, err := cryptossh.ParsePublicKey([]byte(envelope.PublicKey))
+	require.NoError(t, err)
+	var signature cryptossh.Signature
+	require.NoError(t, cryptossh.Unmarshal([]byte(envelope.Signature), &signature))
+
+	hash := sha512.Sum512([]byte(message))
+	signedData := append([]byte("SSHSIG"), cryptossh.Marshal(sshSignedData{
+		Namespace:     sshSignatureNamespace,
+		HashAlgorithm: envelope.HashAlgorithm,
+		Hash:          string(hash[:]),
+	})...)
+	require.NoError(t, publicKey.Verify(signedData, &signature))
+	return signature.Format
+}
+
+func TestLoadCommitSigner(t *testing.T) {
+	t.Run("Unsigned without a format", func(t *testing.T) {
+		signer, err := (&GitConfig{}).loadCommitSigner()
+		require.NoError(t, err)
+		assert.Nil(t, signer)
+	})
+
+	t.Run("Missing key file", func(t *testing.T) {
+		config := &GitConfig{SigningFormat: SigningFormatSSH, SigningKeyPath: filepath.Join(t.TempDir(), "missing")}
+		_, err := config.loadCommitSigner()
+		assert.ErrorIs(t, err, ErrSigningKeyInvalid)
+	})
+
+	t.Run("Protected SSH key without a passphrase", func(t *testing.T) {
+		_, key, err := ed25519.GenerateKey(rand.Reader)
+		require.NoError(t, err)
+		config := &GitConfig{SigningFormat: SigningFormatSSH, SigningKeyPath: writeSSHSigningKey(t, key, "secret")}
+
+		_, err = config.loadCommitSigner()
+		assert.ErrorIs(t, err, ErrSigningKeyInvalid)
+		assert.ErrorContains(t, err, "GIT_SIGNING_KEY_PASS")
+
+		config.SigningKeyPass = "secret"
+		signer, err := config.loadCommitSigner()
+		require.NoError(t, err)
+		assert.NotNil(t, signer)
+	})
+
+	t.Run("An SSH key is no OpenPGP key", func(t *testing.T) {
+		_, key, err := ed25519.GenerateKey(rand.Reader)
+		require.NoError(t, err)
+		config := &GitConfig{SigningFormat: SigningFormatOpenPGP, SigningKeyPath: writeSSHSigningKey(t, key, "")}
+
+		_, err = config.loadCommitSigner()
+		assert.ErrorIs(t, err, ErrSigningKeyInvalid)
+	})
+}
+
+func TestSSHSigner(t *testing.T) {
+	const message = "tree 4b825dc642cb6eb9a060e54bf8d69288fbee4904\n\nBump app to v2\n"
+
+	t.Run("Ed25519", func(t *testing.T) {
+		public, key, err := ed25519.GenerateKey(rand.Reader)
+		require.NoError(t, err)
+		config := &GitConfig{SigningFormat: SigningFormatSSH, SigningKeyPath: writeSSHSigningKey(t, key, "")}
+
+		signer, err := config.loadCommitSigner()
+		require.NoError(t, err)
+		sshPublic, err := cryptossh.NewPublicKey(public)
+		require.NoError(t, err)
+		assert.Equal(t, "ssh:"+cryptossh.FingerprintSHA256(sshPublic), signer.identity)
+
+		signature, err := signer.Sign(strings.NewReader(message))
+		require.NoError(t, err)
+		assert.Equal(t, cryptossh.KeyAlgoED25519, verifySSHSignature(t, signature, message))
+	})
+
+	t.Run("RSA signs with SHA-512", func(t *testing.T) {
+		key, err := rsa.GenerateKey(rand.Reader, 2048)
+		require.NoError(t, err)
+		config := &GitConfig{SigningFormat: SigningFormatSSH, SigningKeyPath: writeSSHSigningKey(t, key, "")}
+
+		signer, err := config.loadCommitSigner()
+		require.NoError(t, err)
+		signature, err := signer.Sign(strings.NewReader(message))
+		require.NoError(t, err)
+		assert.Equal(t, cryptossh.KeyAlgoRSASHA512, verifySSHSignature(t, signature, message))
+	})
+}
+
+func TestOpenPGPSigner(t *testing.T) {
+	const message = "tree 4b825dc642cb6eb9a060e54bf8d69288fbee4904\n\nBump app to v2\n"
+
+	for _, passphrase := range []string{"", "secret"} {
+		keyPath, publicKey := writeOpenPGPSigningKey(t, passphrase)
+		config := &GitConfig{SigningFormat: SigningFormatOpenPGP, SigningKeyPath: keyPath, SigningKeyPass: passphrase}
+
+		signer, err := config.loadCommitSigner()
+		require.NoError(t, err)
+		assert.True(t, strings.HasPrefix(signer.identity, "openpgp:"))
+
+		signature, err := signer.Sign(strings.NewReader(message))
+		require.NoError(t, err)
+
+		keyRing, err := openpgp.ReadArmoredKeyRing(strings.NewReader(publicKey))
+		require.NoError(t, err)
+		_, err = openpgp.CheckArmoredDetachedSignature(keyRing, strings.NewReader(message), bytes.NewReader(signature), nil)
+		assert.NoError(t, err)
+	}
+}
@@ -8,29 +8,41 @@ import (
 	"context"
 	"errors"
 	"fmt"
-	"hash/fnv"
-	"io"
 	"log/slog"
 	"os"
 	"path/filepath"
-	"strconv"
 	"strings"
 	"text/template"
 	"time"
 
 	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/config"
 	"github.com/go-git/go-git/v5/plumbing"
 	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/go-git/go-git/v5/plumbing/transport"
 	"github.com/go-git/go-git/v5/plumbing/transport/ssh"
 	"gopkg.in/yaml.v3"
 )
 
-// ArgoOverrideFile represents the structure of the Helm parameter override file
-// that is committed to the GitOps repository.
+// ArgoOverrideFile represents the structure of the ArgoCD source override file
+// that is committed to the GitOps repository. Only one of its sections is written:
+// ArgoCD rejects an override that sets both Helm and Kustomize.
 type ArgoOverrideFile struct {
 	Helm struct {
 		Parameters []ArgoParameterOverride `yaml:"parameters"`
-	} `yaml:"helm"`
+	} `yaml:"helm,omitempty"`
+	Kustomize struct {
+		Images []KustomizeImageOverride `yaml:"images"`
+	} `yaml:"kustomize,omitempty"`
+	// ValuesFiles are not part of the override file: each is written into the values
+	// file it names. When they are all the content carries, the override file is left
+	// alone.
+	ValuesFiles []ValuesFileUpdate `yaml:"-"`
+}
+
+// hasOverrides reports whether the content sets anything in the override file.
+func (content *ArgoOverrideFile) hasOverrides() bool {
+	return len(content.Helm.Parameters) > 0 || len(content.Kustomize.Images) > 0
 }
 
 // ArgoParameterOverride defines a single Helm parameter to be overridden.
@@ -40,9 +52,78 @@ type ArgoParameterOverride struct {
 	ForceString bool   `yaml:"forceString"`
 }
 
+// KustomizeImageOverride defines a single Kustomize image to be overridden. ArgoCD
+// stores it as one string in the form of "kustomize edit set image":
+// [<name>=]<newName>[:<newTag>|@<digest>], with the name alone when NewName is empty.
+type KustomizeImageOverride struct {
+	// Name is the image name used in the kustomization, which the override replaces.
+	Name    string
+	NewName string
+	NewTag  string
+	Digest  string
+}
+
+// String renders the override in ArgoCD's form.
+func (image KustomizeImageOverride) String() string {
+	override := image.Name
+	if image.NewName != "" {
+		override += "=" + image.NewName
+	}
+	if image.Digest != "" {
+		return override + "@" + image.Digest
+	}
+	if image.NewTag != "" {
+		return override + ":" + image.NewTag
+	}
+	return override
+}
+
+// MarshalYAML writes the override as ArgoCD's string form.
+func (image KustomizeImageOverride) MarshalYAML() (any, error) {
+	return image.String(), nil
+}
+
+// UnmarshalYAML reads an override in ArgoCD's string form.
+func (image *KustomizeImageOverride) UnmarshalYAML(value *yaml.Node) error {
+	var override string
+	if err := value.Decode(&override); err != nil {
+		return err
+	}
+	*image = parseKustomizeImageOverride(override)
+	return nil
+}
+
+// parseKustomizeImageOverride splits an override in ArgoCD's string form. The tag is
+// the part after the last colon of the last path component, so a registry port is
+// not mistaken for one.
+func parseKustomizeImageOverride(override string) KustomizeImageOverride {
+	image := KustomizeImageOverride{}
+	reference := override
+	if name, newName, found := strings.Cut(override, "="); found {
+		image.Name = name
+		reference = newName
+	}
+
+	if name, digest, found := strings.Cut(reference, "@"); found {
+		reference = name
+		image.Digest = digest
+	} else if colon := strings.LastIndex(reference, ":"); colon > strings.LastIndex(reference, "/") {
+		image.NewTag = reference[colon+1:]
+		reference = reference[:colon]
+	}
+
+	if image.Name == "" {
+		image.Name = reference
+	} else {
+		image.NewName = reference
+	}
+	return image
+}
+
 // GitRepo holds the state for operations on a single Git repository branch.
 type GitRepo struct {
-	// RepoURL is the SSH URL of the repository to be cloned.
+	// RepoURL is the URL of the repository to be cloned: an SSH one, or an HTTPS one
+	// authenticated with a token (see GitConfig.HttpsCredentials).
 	RepoURL    string
 	BranchName string
 	// Path is the directory within the repository where the manifest file is located.
@@ -53,20 +134,21 @@ type GitRepo struct {
 	localRepoPath string
 	localRepo     *git.Repository
 	sshAuth       *ssh.PublicKeys
+	// auth is the credential the remote is reached with, set by every Clone.
+	auth transport.AuthMethod
 	// gitConfig contains user-configurable git settings like commit author and email.
 	gitConfig  *GitConfig
 	GitHandler GitHandler
+	// signer signs every commit made in the clone, nil when commits are not signed.
+	signer *commitSigner
+	// lastCommit is the commit made by the latest commitLocal since Clone, zero when
+	// none was made.
+	lastCommit plumbing.Hash
 }
 
 // getRepoCachePath generates a unique, deterministic local path for the repository cache.
-// Hashing URL+branch gives filesystem-level isolation, so concurrent operations on
-// different branches of the same repository do not conflict.
 func (repo *GitRepo) getRepoCachePath() string {
-	hasher := fnv.New64a()
-	// The Write method on hash.Hash is documented to never return an error.
-	_, _ = io.WriteString(hasher, fmt.Sprintf("%s-%s", repo.RepoURL, repo.BranchName))
-	hashUint64 := hasher.Sum64()
-	return filepath.Join(repo.repoCachePath, strconv.FormatUint(hashUint64, 16))
+	return CachePath(repo.repoCachePath, repo.RepoURL, repo.BranchName)
 }
 
 // Clone handles the initial setup of the local repository cache. A cache that opens
@@ -77,8 +159,11 @@ func (repo *GitRepo) getRepoCachePath() string {
 // commitLocal's byte-compare skip depends on that reset: it treats equal bytes as
 // "no change", which only holds because this function leaves the worktree at origin.
 //
+// A successful Clone marks the cache as used, which is what keeps it from being
+// evicted as unused (see CacheEntry.LastUsed).
+//
 // Both the fresh clone and the warm-cache fetch are shallow (Depth:1, no tags):
-// argo-watcher only reads the branch tip and commits one file on top of it, so
+// argo-watcher only reads the branch tip and commits a few files on top of it, so
 // the repository's history is never needed. This keeps the clone/fetch cost off
 // the deep-history path, which matters because the whole operation runs under
 // the distributed per-repo advisory lock.
@@ -87,16 +172,22 @@ func (repo *GitRepo) getRepoCachePath() string {
 // total-budget context for the whole update flow so that one stuck operation
 // cannot hold the per-repo lock past that budget.
 func (repo *GitRepo) Clone(ctx context.Context) error {
+	if err := repo.clone(ctx); err != nil {
+		return err
+	}
+	markCacheUsed(repo.localRepoPath)
+	return nil
+}
+
+// clone implements Clone.
+func (repo *GitRepo) clone(ctx context.Context) error {
 	var err error
 
 	repo.localRepoPath = repo.getRepoCachePath()
+	repo.lastCommit = plumbing.ZeroHash
 
-	// Only load the SSH key once per GitRepo lifetime; the key does not change
-	// between calls and re-reading it on the race-recovery Clone() wastes budget.
-	if repo.sshAuth == nil {
-		if repo.sshAuth, err = repo.GitHandler.AddSSHKey("git", repo.gitConfig.SshKeyPath, repo.gitConfig.SshKeyPass); err != nil {
-			return err
-		}
+	if err := repo.loadAuth(); err != nil {
+		return err
 	}
 
 	repo.localRepo, err = repo.GitHandler.PlainOpen(repo.localRepoPath)
@@ -120,7 +211,7 @@ func (repo *GitRepo) Clone(ctx context.Context) error {
 			SingleBranch:  true,
 			Depth:         1,
 			Tags:          git.NoTags,
-			Auth:          repo.sshAuth,
+			Auth:          repo.auth,
 		})
 		return err
 	}
@@ -130,7 +221,7 @@ func (repo *GitRepo) Clone(ctx context.Context) error {
 	// history on the first fetch, undoing the shallow clone's win.
 	err = repo.localRepo.FetchContext(ctx, &git.FetchOptions{
 		RemoteName: "origin",
-		Auth:       repo.sshAuth,
+		Auth:       repo.auth,
 		Force:      true,
 		Depth:      1,
 		Tags:       git.NoTags,
@@ -155,6 +246,39 @@ func (repo *GitRepo) Clone(ctx context.Context) error {
 	})
 }
 
+// loadAuth sets the credential the remote is reached with, chosen by the scheme of
+// its URL. The SSH key is only loaded once per GitRepo lifetime: it does not change
+// between calls, and re-reading it on the race-recovery Clone() wastes budget. An
+// HTTPS token is re-read every time instead, as it may be short-lived and rotated
+// on disk while a write-back retries.
+func (repo *GitRepo) loadAuth() error {
+	if isHTTPRemote(repo.RepoURL) {
+		auth, err := repo.gitConfig.httpsAuth(repo.RepoURL)
+		if err != nil {
+			return err
+		}
+		repo.auth = auth
+		return nil
+	}
+
+	if repo.sshAuth == nil {
+		var err error
+		if repo.sshAuth, err = repo.GitHandler.AddSSHKey("git", repo.gitConfig.SshKeyPath, repo.gitConfig.SshKeyPass); err != nil {
+			return err
+		}
+		// A handler that already decided how host keys are checked keeps its
+		// choice; the one GitClient returns is verified against our trusted keys.
+		if repo.sshAuth != nil && repo.sshAuth.HostKeyCallback == nil {
+			if err = repo.gitConfig.verifyHostKeys(repo.sshAuth, repo.RepoURL); err != nil {
+				repo.sshAuth = nil
+				return err
+			}
+		}
+	}
+	repo.auth = repo.sshAuth
+	return nil
+}
+
 // generateOverrideFileNameForApp builds the override file path from an explicit
 // path and fileName. If fileName is empty a default name is derived from the
 // application name; otherwise fileName is used verbatim. The path and fileName
@@ -167,13 +291,23 @@ func generateOverrideFileNameForApp(path, fileName, appName string) string {
 	return fmt.Sprintf("%s/%s", path, fileName)
 }
 
+// writeBackSubject is implemented by template data that tells what its write-back
+// updates, which the default commit message names.
+type writeBackSubject interface {
+	WriteBackSubject() string
+}
+
 // generateCommitMessage creates the commit message for the update. It uses a
 // user-configurable Go template if provided; otherwise, it falls back to a
 // default format. Template errors (parse or execute) are logged and the default
 // message is used so a malformed COMMIT_MESSAGE_FORMAT does not abort the
 // deployment update — availability takes precedence over a custom commit message.
 func (repo *GitRepo) generateCommitMessage(appName string, tmplData any) string {
-	commitMsg := fmt.Sprintf("argo-watcher(%s): update image tag", appName)
+	subject := "update image tag"
+	if described, ok := tmplData.(writeBackSubject); ok {
+		subject = described.WriteBackSubject()
+	}
+	commitMsg := fmt.Sprintf("argo-watcher(%s): %s", appName, subject)
 
 	if repo.gitConfig.CommitMessageFormat == "" {
 		return commitMsg
@@ -209,30 +343,107 @@ func (repo *GitRepo) UpdateApp(ctx context.Context, appName string, overrideCont
 	return repo.push(ctx)
 }
 
-// CommitAppLocal merges an app's override file with the new content and commits
-// the change into the local clone WITHOUT pushing. It reports whether a commit
-// was actually created (false when the on-disk content already matches, so there
-// is nothing to push). path and fileName are the app's write-back location; they
-// are passed explicitly because in batch write-back many apps share one clone yet
-// each has its own location. tmplData is forwarded to the commit-message template.
-func (repo *GitRepo) CommitAppLocal(appName, path, fileName string, overrideContent *ArgoOverrideFile, tmplData any) (bool, error) {
-	overrideFileName := generateOverrideFileNameForApp(path, fileName, appName)
-	fullPath := filepath.Join(repo.localRepoPath, overrideFileName)
-
-	if err := assertInsideRoot(repo.localRepoPath, fullPath); err != nil {
+// ProposeApp is UpdateApp for the pull-request write-back: the commit is pushed to
+// branch rather than to the repository's branch, which it is then proposed for. The
+// push is forced, so a retried attempt replaces the commit an earlier one left there.
+// It reports whether a commit was created; when the content already matches, nothing
+// is pushed and there is nothing to propose.
+func (repo *GitRepo) ProposeApp(ctx context.Context, appName, branch string, overrideContent *ArgoOverrideFile, tmplData any) (bool, error) {
+	committed, err := repo.CommitAppLocal(appName, repo.Path, repo.FileName, overrideContent, tmplData)
+	if err != nil || !committed {
 		return false, err
 	}
 
-	commitMsg := repo.generateCommitMessage(appName, tmplData)
+	if err := ctx.Err(); err != nil {
+		return false, fmt.Errorf("budget exhausted before push: %w", err)
+	}
 
-	slog.Debug("Updating override file", "path", fullPath)
+	refSpec := config.RefSpec(fmt.Sprintf("+refs/heads/%s:refs/heads/%s", repo.BranchName, branch))
+	return true, repo.localRepo.PushContext(ctx, &git.PushOptions{
+		Auth:       repo.auth,
+		RemoteName: "origin",
+		RefSpecs:   []config.RefSpec{refSpec},
+	})
+}
 
-	finalContent, err := repo.mergeOverrideFileContent(fullPath, overrideContent)
+// CommitAppLocal merges an app's override file with the new content, sets the values
+// it updates in the app's values files, and commits the change into the local clone
+// WITHOUT pushing. It reports whether a commit was actually created (false when the
+// on-disk content already matches, so there is nothing to push). path and fileName are
+// the app's write-back location; they are passed explicitly because in batch write-back
+// many apps share one clone yet each has its own location. tmplData is forwarded to the
+// commit-message template.
+func (repo *GitRepo) CommitAppLocal(appName, path, fileName string, overrideContent *ArgoOverrideFile, tmplData any) (bool, error) {
+	files, err := repo.appFiles(appName, path, fileName, overrideContent)
 	if err != nil {
 		return false, err
 	}
 
-	return repo.commitLocal(fullPath, commitMsg, finalContent)
+	commitMsg := repo.generateCommitMessage(appName, tmplData)
+
+	return repo.commitLocal(commitMsg, files)
+}
+
+// appFile is the content a write-back gives one file of the clone.
+type appFile struct {
+	fullPath string
+	content  []byte
+}
+
+// appFiles works out the new content of every file an app's write-back writes: the
+// override file, unless the content only updates values files, and each values file
+// it updates.
+func (repo *GitRepo) appFiles(appName, path, fileName string, overrideContent *ArgoOverrideFile) ([]appFile, error) {
+	var files []appFile
+
+	if overrideContent.hasOverrides() || len(overrideContent.ValuesFiles) == 0 {
+		fullPath := filepath.Join(repo.localRepoPath, generateOverrideFileNameForApp(path, fileName, appName))
+		if err := assertInsideRoot(repo.localRepoPath, fullPath); err != nil {
+			return nil, err
+		}
+
+		slog.Debug("Updating override file", "path", fullPath)
+
+		finalContent, err := repo.mergeOverrideFileContent(fullPath, overrideContent)
+		if err != nil {
+			return nil, err
+		}
+		contentBytes, err := yaml.Marshal(finalContent)
+		if err != nil {
+			return nil, err
+		}
+		files = append(files, appFile{fullPath: fullPath, content: contentBytes})
+	}
+
+	var valuesFiles []string
+	updatesByFile := map[string][]ValuesFileUpdate{}
+	for _, update := range overrideContent.ValuesFiles {
+		if _, seen := updatesByFile[update.File]; !seen {
+			valuesFiles = append(valuesFiles, update.File)
+		}
+		updatesByFile[update.File] = append(updatesByFile[update.File], update)
+	}
+
+	for _, valuesFile := range valuesFiles {
+		fullPath := filepath.Join(repo.localRepoPath, path, valuesFile)
+		if err := assertInsideRoot(repo.localRepoPath, fullPath); err != nil {
+			return nil, err
+		}
+
+		slog.Debug("Updating values file", "path", fullPath)
+
+		existing, err := os.ReadFile(fullPath) // #nosec G304 -- path validated by assertInsideRoot above
+		if err != nil {
+			return nil, fmt.Errorf("failed to read values file %s: %w", valuesFile, err)
+		}
+		contentBytes, err := setYAMLValues(existing, updatesByFile[valuesFile])
+		if err != nil {
+			return nil, err
+		}
+		files = append(files, appFile{fullPath: fullPath, content: contentBytes})
+	}
+
+	return files, nil
 }
 
 // Push publishes all locally-committed changes to the remote, bounded by ctx.
@@ -273,45 +484,62 @@ func (repo *GitRepo) mergeOverrideFileContent(fullPath string, overrideContent *
 		return nil, fmt.Errorf("failed to unmarshal existing override file: %w", err)
 	}
 
+	// ArgoCD rejects a source override carrying both a helm and a kustomize section, so
+	// content written in one write-back mode drops the section of the other. That is
+	// what switching an application's argo-watcher/write-back-mode leaves behind.
+	switch {
+	case len(overrideContent.Kustomize.Images) > 0 && len(overrideContent.Helm.Parameters) == 0:
+		existingOverrideFile.Helm.Parameters = nil
+	case len(overrideContent.Helm.Parameters) > 0 && len(overrideContent.Kustomize.Images) == 0:
+		existingOverrideFile.Kustomize.Images = nil
+	}
+
 	mergeParameters(&existingOverrideFile, overrideContent)
+	mergeKustomizeImages(&existingOverrideFile, overrideContent)
 
 	return &existingOverrideFile, nil
 }
 
-func (repo *GitRepo) commitLocal(fullPath, commitMsg string, overrideContent *ArgoOverrideFile) (bool, error) {
+// commitLocal writes files into the clone and commits those whose content changed,
+// reporting whether a commit was created.
+func (repo *GitRepo) commitLocal(commitMsg string, files []appFile) (bool, error) {
 	worktree, err := repo.localRepo.Worktree()
 	if err != nil {
 		return false, err
 	}
 
-	contentBytes, err := yaml.Marshal(overrideContent)
-	if err != nil {
-		return false, err
-	}
+	changed := false
+	for _, file := range files {
+		// Detect "nothing to commit" with a per-file byte compare instead of
+		// worktree.Status(). Clone() hard-resets to origin HEAD and these files are
+		// the only paths we write, so equal bytes mean a clean worktree — but O(files)
+		// instead of scanning the whole repo, which dominates the cost on a large repo.
+		// #nosec G304 -- path already validated by assertInsideRoot in appFiles
+		if existing, readErr := os.ReadFile(file.fullPath); readErr == nil && bytes.Equal(existing, file.content) {
+			continue
+		}
 
-	// Detect "nothing to commit" with a single-file byte compare instead of
-	// worktree.Status(). Clone() hard-resets to origin HEAD and this override file is
-	// the only path we write, so equal bytes mean a clean worktree — but O(1 file)
-	// instead of scanning the whole repo, which dominates the cost on a large repo.
-	// #nosec G304 -- path already validated by assertInsideRoot in CommitAppLocal
-	if existing, readErr := os.ReadFile(fullPath); readErr == nil && bytes.Equal(existing, contentBytes) {
-		slog.Debug("No changes detected. Skipping commit.")
-		return false, nil
-	}
+		relativePath, err := filepath.Rel(repo.localRepoPath, file.fullPath)
+		if err != nil {
+			return false, fmt.Errorf("could not determine relative path: %w", err)
+		}
 
-	if err := os.WriteFile(fullPath, contentBytes, 0600); err != nil {
-		return false, fmt.Errorf("failed to write override file: %w", err)
-	}
+		if err := os.WriteFile(file.fullPath, file.content, 0600); err != nil {
+			return false, fmt.Errorf("failed to write %s: %w", filepath.ToSlash(relativePath), err)
+		}
 
-	// Add the file to the staging area. SkipStatus avoids the full-worktree Status()
-	// scan that worktree.Add performs internally; with an explicit single-file path
-	// go-git hashes and stages only that file (new or modified).
-	relativePath, err := filepath.Rel(repo.localRepoPath, fullPath)
-	if err != nil {
-		return false, fmt.Errorf("could not determine relative path: %w", err)
+		// Add the file to the staging area. SkipStatus avoids the full-worktree Status()
+		// scan that worktree.Add performs internally; with an explicit single-file path
+		// go-git hashes and stages only that file (new or modified).
+		if err := worktree.AddWithOptions(&git.AddOptions{Path: relativePath, SkipStatus: true}); err != nil {
+			return false, err
+		}
+		changed = true
 	}
-	if err := worktree.AddWithOptions(&git.AddOptions{Path: relativePath, SkipStatus: true}); err != nil {
-		return false, err
+
+	if !changed {
+		slog.Debug("No changes detected. Skipping commit.")
+		return false, nil
 	}
 
 	commitOpts := &git.CommitOptions{
@@ -321,9 +549,14 @@ func (repo *GitRepo) commitLocal(fullPath, commitMsg string, overrideContent *Ar
 			When:  time.Now(),
 		},
 	}
-	if _, err = worktree.Commit(commitMsg, commitOpts); err != nil {
+	if repo.signer != nil {
+		commitOpts.Signer = repo.signer
+	}
+	hash, err := worktree.Commit(commitMsg, commitOpts)
+	if err != nil {
 		return false, err
 	}
+	repo.lastCommit = hash
 
 	return true, nil
 }
@@ -346,7 +579,7 @@ func (repo *GitRepo) push(ctx context.Context) error {
 	}
 
 	pushOpts := &git.PushOptions{
-		Auth:       repo.sshAuth,
+		Auth:       repo.auth,
 		RemoteName: "origin",
 	}
 	return repo.localRepo.PushContext(ctx, pushOpts)
@@ -368,6 +601,22 @@ func mergeParameters(existing, newContent *ArgoOverrideFile) {
 	}
 }
 
+func mergeKustomizeImages(existing, newContent *ArgoOverrideFile) {
+	for _, newImage := range newContent.Kustomize.Images {
+		found := false
+		for idx, existingImage := range existing.Kustomize.Images {
+			if existingImage.Name == newImage.Name {
+				existing.Kustomize.Images[idx] = newImage
+				found = true
+				break
+			}
+		}
+		if !found {
+			existing.Kustomize.Images = append(existing.Kustomize.Images, newImage)
+		}
+	}
+}
+
 // NewGitRepo constructs a GitRepo, loading the git configuration from the environment.
 func NewGitRepo(repoURL, branchName, path, fileName, repoCachePath string, gitHandler GitHandler) (*GitRepo, error) {
 	gitConfig, err := NewGitConfig()
@@ -375,6 +624,11 @@ func NewGitRepo(repoURL, branchName, path, fileName, repoCachePath string, gitHa
 		return nil, fmt.Errorf("failed to load git config: %w", err)
 	}
 
+	signer, err := gitConfig.loadCommitSigner()
+	if err != nil {
+		return nil, err
+	}
+
 	return &GitRepo{
 		RepoURL:       repoURL,
 		BranchName:    branchName,
@@ -383,9 +637,25 @@ func NewGitRepo(repoURL, branchName, path, fileName, repoCachePath string, gitHa
 		gitConfig:     gitConfig,
 		GitHandler:    gitHandler,
 		repoCachePath: repoCachePath,
+		signer:        signer,
 	}, nil
 }
 
+// LastCommit returns the commit made by the latest UpdateApp, ProposeApp or
+// CommitAppLocal since the last Clone, or the zero hash when it changed nothing.
+func (repo *GitRepo) LastCommit() plumbing.Hash {
+	return repo.lastCommit
+}
+
+// SigningKey names the key commits are signed with, such as "ssh:SHA256:...", or
+// returns an empty string when they are not signed.
+func (repo *GitRepo) SigningKey() string {
+	if repo.signer == nil {
+		return ""
+	}
+	return repo.signer.identity
+}
+
 // GitOpTimeout returns the per-attempt wall-clock budget for one clone+update
 // cycle so callers can build bounded contexts without seeing credentials in
 // the rest of GitConfig. The full retry loop's worst-case wall clock is
@@ -411,7 +681,8 @@ func (repo *GitRepo) GitMaxAttempts() uint {
 // when localRepoPath is empty (i.e. before the first Clone) is a safe no-op.
 //
 // Note: sshAuth is NOT cleared because the SSH key file is not expected to
-// change during the lifetime of a GitRepo. A key rotation requires a restart.
+// change during the lifetime of a GitRepo. A key rotation requires a restart;
+// an HTTPS token needs none, as it is re-read by every Clone.
 func (repo *GitRepo) InvalidateCache() error {
 	repo.localRepo = nil
 	if repo.localRepoPath == "" {
@@ -14,11 +14,13 @@ import (
 	"github.com/go-git/go-git/v5/plumbing"
 	"github.com/go-git/go-git/v5/plumbing/object"
 	"github.com/go-git/go-git/v5/plumbing/transport"
+	githttp "github.com/go-git/go-git/v5/plumbing/transport/http"
 	"github.com/go-git/go-git/v5/plumbing/transport/ssh"
 	"github.com/go-git/go-git/v5/storage/memory"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 	"go.uber.org/mock/gomock"
+	"gopkg.in/yaml.v3"
 
 	"github.com/shini4i/argo-watcher/internal/mocks"
 )
@@ -191,6 +193,20 @@ func TestGenerateCommitMessage(t *testing.T) {
 	repo.gitConfig.CommitMessageFormat = "ci: bump {{ .MissingKey }}"
 	assert.Equal(t, "argo-watcher(test-app): update image tag", repo.generateCommitMessage("test-app", tmplData),
 		"execute error should fall back to default")
+
+	repo.gitConfig.CommitMessageFormat = ""
+	assert.Equal(t, "argo-watcher(test-app): update chart version to 1.4.0",
+		repo.generateCommitMessage("test-app", chartVersionData{AppName: "test-app", version: "1.4.0"}))
+}
+
+// chartVersionData stands for template data that names what its write-back updates.
+type chartVersionData struct {
+	AppName string
+	version string
+}
+
+func (data chartVersionData) WriteBackSubject() string {
+	return "update chart version to " + data.version
 }
 
 func TestMergeParameters(t *testing.T) {
@@ -214,6 +230,62 @@ func TestMergeParameters(t *testing.T) {
 	assert.Contains(t, existing.Helm.Parameters, ArgoParameterOverride{Name: "debug", Value: "true"})
 }
 
+func TestMergeKustomizeImages(t *testing.T) {
+	existing := &ArgoOverrideFile{}
+	existing.Kustomize.Images = []KustomizeImageOverride{
+		{Name: "example.com/app", NewTag: "v1.0.0"},
+		{Name: "example.com/sidecar", NewTag: "v3"},
+	}
+
+	newContent := &ArgoOverrideFile{}
+	newContent.Kustomize.Images = []KustomizeImageOverride{
+		{Name: "example.com/app", NewTag: "v2.0.0"},
+		{Name: "placeholder", NewName: "example.com/worker", Digest: "sha256:abc"},
+	}
+
+	mergeKustomizeImages(existing, newContent)
+
+	assert.Equal(t, []KustomizeImageOverride{
+		{Name: "example.com/app", NewTag: "v2.0.0"},
+		{Name: "example.com/sidecar", NewTag: "v3"},
+		{Name: "placeholder", NewName: "example.com/worker", Digest: "sha256:abc"},
+	}, existing.Kustomize.Images)
+}
+
+func TestKustomizeImageOverrideYAML(t *testing.T) {
+	overrides := []struct {
+		form  string
+		image KustomizeImageOverride
+	}{
+		{form: "example.com/app:v1", image: KustomizeImageOverride{Name: "example.com/app", NewTag: "v1"}},
+		{form: "registry:5000/app:v1", image: KustomizeImageOverride{Name: "registry:5000/app", NewTag: "v1"}},
+		{form: "app=registry:5000/app:v1", image: KustomizeImageOverride{Name: "app", NewName: "registry:5000/app", NewTag: "v1"}},
+		{form: "example.com/app@sha256:abc", image: KustomizeImageOverride{Name: "example.com/app", Digest: "sha256:abc"}},
+		{form: "registry:5000/app", image: KustomizeImageOverride{Name: "registry:5000/app"}},
+	}
+
+	for _, override := range overrides {
+		t.Run(override.form, func(t *testing.T) {
+			var decoded KustomizeImageOverride
+			require.NoError(t, yaml.Unmarshal([]byte(override.form), &decoded))
+			assert.Equal(t, override.image, decoded)
+
+			encoded, err := yaml.Marshal(override.image)
+			require.NoError(t, err)
+			assert.Equal(t, override.form+"\n", string(encoded))
+		})
+	}
+
+	t.Run("Writes only the Kustomize section", func(t *testing.T) {
+		content := &ArgoOverrideFile{}
+		content.Kustomize.Images = []KustomizeImageOverride{{Name: "example.com/app", NewTag: "v1"}}
+
+		encoded, err := yaml.Marshal(content)
+		require.NoError(t, err)
+		assert.Equal(t, "kustomize:\n    images:\n        - example.com/app:v1\n", string(encoded))
+	})
+}
+
 func TestClone(t *testing.T) {
 	ctrl := gomock.NewController(t)
 	mockHandler := mocks.NewMockGitHandler(ctrl)
@@ -252,6 +324,22 @@ func TestClone(t *testing.T) {
 		assert.True(t, os.IsNotExist(err), "Corrupted cache should have been removed")
 	})
 
+	t.Run("Marks the cache as used", func(t *testing.T) {
+		repo.localRepoPath = repo.getRepoCachePath()
+		require.NoError(t, os.MkdirAll(repo.localRepoPath, 0o750))
+		lastUsed := time.Now().Add(-time.Hour)
+		require.NoError(t, os.Chtimes(repo.localRepoPath, lastUsed, lastUsed))
+
+		mockHandler.EXPECT().PlainOpen(gomock.Any()).Return(nil, git.ErrRepositoryNotExists)
+		mockHandler.EXPECT().PlainClone(gomock.Any(), gomock.Any(), false, gomock.Any()).Return(nil, nil)
+		require.NoError(t, repo.Clone(context.Background()))
+
+		info, err := os.Stat(repo.localRepoPath)
+		require.NoError(t, err)
+		assert.True(t, info.ModTime().After(lastUsed), "a clone must read as recently used to the cache sweep")
+		require.NoError(t, os.RemoveAll(repo.localRepoPath))
+	})
+
 	t.Run("Cache Exists and is Valid", func(t *testing.T) {
 		memStore := memory.NewStorage()
 		r, err := git.Init(memStore, nil)
@@ -280,6 +368,40 @@ func TestClone(t *testing.T) {
 	})
 }
 
+func TestCloneHTTPSRemote(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	// No AddSSHKey expectation: an HTTPS remote never loads the SSH key.
+	mockHandler := mocks.NewMockGitHandler(ctrl)
+	repo := newTestRepo(t, mockHandler)
+	repo.RepoURL = "https://git.example.com/org/gitops.git"
+
+	tokenPath := filepath.Join(t.TempDir(), "token")
+	require.NoError(t, os.WriteFile(tokenPath, []byte("first\n"), 0600))
+	repo.gitConfig.HttpsCredentials = map[string]string{"git.example.com": tokenPath}
+
+	var passwords []string
+	mockHandler.EXPECT().PlainOpen(gomock.Any()).Return(nil, git.ErrRepositoryNotExists).Times(2)
+	mockHandler.EXPECT().PlainClone(gomock.Any(), gomock.Any(), false, gomock.Any()).
+		DoAndReturn(func(_ context.Context, _ string, _ bool, o *git.CloneOptions) (*git.Repository, error) {
+			auth, ok := o.Auth.(*githttp.BasicAuth)
+			require.True(t, ok, "an HTTPS remote is authenticated with its token")
+			assert.Equal(t, "x-access-token", auth.Username)
+			passwords = append(passwords, auth.Password)
+			return nil, nil
+		}).Times(2)
+
+	require.NoError(t, repo.Clone(context.Background()))
+	require.NoError(t, os.WriteFile(tokenPath, []byte("second\n"), 0600))
+	require.NoError(t, repo.Clone(context.Background()))
+
+	assert.Equal(t, []string{"first", "second"}, passwords, "a token rotated on disk is used by the next clone")
+
+	repo.RepoURL = "https://other.example.com/org/gitops.git"
+	err := repo.Clone(context.Background())
+	assert.ErrorIs(t, err, ErrHTTPSCredentialNotConfigured)
+	assert.True(t, IsPermanent(err))
+}
+
 func setupGitForTest(t *testing.T) (sourceRepo, remoteRepo, localRepo *git.Repository, remotePath, localPath string) {
 	t.Helper()
 
@@ -420,6 +542,59 @@ func TestFullUpdateAppCycle(t *testing.T) {
 	})
 }
 
+func TestProposeApp(t *testing.T) {
+	t.Run("Pushes the commit to the proposed branch only", func(t *testing.T) {
+		_, remoteRepo, localRepo, _, localPath := setupGitForTest(t)
+
+		repo := newTestRepo(t, &GitClient{})
+		repo.BranchName = "master"
+		repo.localRepo = localRepo
+		repo.localRepoPath = localPath
+		require.NoError(t, os.Mkdir(filepath.Join(localPath, "apps"), 0755))
+
+		baseBefore, err := remoteRepo.Reference(plumbing.NewBranchReferenceName("master"), true)
+		require.NoError(t, err)
+
+		newParams := &ArgoOverrideFile{}
+		newParams.Helm.Parameters = []ArgoParameterOverride{{Name: "image.tag", Value: "v2.0.0"}}
+
+		committed, err := repo.ProposeApp(context.Background(), "my-app", "argo-watcher/my-app/1", newParams, nil)
+		require.NoError(t, err)
+		assert.True(t, committed)
+
+		baseAfter, err := remoteRepo.Reference(plumbing.NewBranchReferenceName("master"), true)
+		require.NoError(t, err)
+		assert.Equal(t, baseBefore.Hash(), baseAfter.Hash())
+
+		proposed, err := remoteRepo.Reference(plumbing.NewBranchReferenceName("argo-watcher/my-app/1"), true)
+		require.NoError(t, err)
+		commit, err := remoteRepo.CommitObject(proposed.Hash())
+		require.NoError(t, err)
+		assert.Contains(t, commit.Message, "argo-watcher(my-app): update image tag")
+	})
+
+	t.Run("Nothing is pushed without a change", func(t *testing.T) {
+		_, remoteRepo, localRepo, _, localPath := setupGitForTest(t)
+
+		repo := newTestRepo(t, &GitClient{})
+		repo.BranchName = "master"
+		repo.localRepo = localRepo
+		repo.localRepoPath = localPath
+		require.NoError(t, os.Mkdir(filepath.Join(localPath, "apps"), 0755))
+
+		params := &ArgoOverrideFile{}
+		params.Helm.Parameters = []ArgoParameterOverride{{Name: "image.tag", Value: "v2.0.0"}}
+		require.NoError(t, repo.UpdateApp(context.Background(), "my-app", params, nil))
+
+		committed, err := repo.ProposeApp(context.Background(), "my-app", "argo-watcher/my-app/1", params, nil)
+		require.NoError(t, err)
+		assert.False(t, committed)
+
+		_, err = remoteRepo.Reference(plumbing.NewBranchReferenceName("argo-watcher/my-app/1"), true)
+		assert.ErrorIs(t, err, plumbing.ErrReferenceNotFound)
+	})
+}
+
 func TestCommitAppLocalAndPush_MultipleAppsSinglePush(t *testing.T) {
 	// Batch primitive: commit several apps' disjoint override files into one clone,
 	// then push once. All files must land in a single push with one commit per app.
@@ -496,6 +671,84 @@ func TestCommitAppLocal_NoChangeReportsNotCommitted(t *testing.T) {
 	assert.False(t, committed, "unchanged content must report not committed so the push is skipped")
 }
 
+func TestCommitAppLocal_SignsCommits(t *testing.T) {
+	_, _, localRepo, _, localPath := setupGitForTest(t)
+	keyPath, publicKey := writeOpenPGPSigningKey(t, "")
+	t.Setenv("GIT_SIGNING_FORMAT", SigningFormatOpenPGP)
+	t.Setenv("GIT_SIGNING_KEY_PATH", keyPath)
+
+	repo := newTestRepo(t, &GitClient{})
+	repo.localRepo = localRepo
+	repo.localRepoPath = localPath
+	require.NoError(t, os.MkdirAll(filepath.Join(localPath, "apps"), 0755))
+	assert.Regexp(t, `^openpgp:[0-9A-F]{40}$`, repo.SigningKey())
+
+	params := &ArgoOverrideFile{}
+	params.Helm.Parameters = []ArgoParameterOverride{{Name: "image.tag", Value: "v1.0.0"}}
+	committed, err := repo.CommitAppLocal("app-a", "apps", "", params, nil)
+	require.NoError(t, err)
+	require.True(t, committed)
+
+	head, err := localRepo.Head()
+	require.NoError(t, err)
+	assert.Equal(t, head.Hash(), repo.LastCommit())
+
+	commit, err := localRepo.CommitObject(head.Hash())
+	require.NoError(t, err)
+	require.NotEmpty(t, commit.PGPSignature)
+	_, err = commit.Verify(publicKey)
+	assert.NoError(t, err, "the batch commit must carry a valid signature")
+}
+
+func TestCommitAppLocal_ValuesFiles(t *testing.T) {
+	_, remoteRepo, localRepo, _, localPath := setupGitForTest(t)
+
+	repo := newTestRepo(t, &GitClient{})
+	repo.localRepo = localRepo
+	repo.localRepoPath = localPath
+	require.NoError(t, os.MkdirAll(filepath.Join(localPath, "apps"), 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(localPath, "apps/values-prod.yaml"), []byte("image:\n  tag: v1.0.0\n"), 0600))
+
+	content := &ArgoOverrideFile{ValuesFiles: []ValuesFileUpdate{{File: "values-prod.yaml", Path: "image.tag", Value: "v2.0.0"}}}
+	content.Helm.Parameters = []ArgoParameterOverride{{Name: "sidecar.tag", Value: "v3"}}
+
+	committed, err := repo.CommitAppLocal("app-a", "apps", "", content, nil)
+	require.NoError(t, err)
+	require.True(t, committed)
+	require.NoError(t, repo.push(context.Background()))
+
+	head, err := remoteRepo.Head()
+	require.NoError(t, err)
+	commit, err := remoteRepo.CommitObject(head.Hash())
+	require.NoError(t, err)
+
+	values, err := commit.File("apps/values-prod.yaml")
+	require.NoError(t, err)
+	valuesContent, err := values.Contents()
+	require.NoError(t, err)
+	assert.Equal(t, "image:\n  tag: v2.0.0\n", valuesContent)
+
+	override, err := commit.File("apps/.argocd-source-app-a.yaml")
+	require.NoError(t, err, "the Helm parameters still go into the override file, in the same commit")
+	overrideContent, err := override.Contents()
+	require.NoError(t, err)
+	assert.Contains(t, overrideContent, "sidecar.tag")
+
+	t.Run("A missing values file fails the write-back", func(t *testing.T) {
+		content := &ArgoOverrideFile{ValuesFiles: []ValuesFileUpdate{{File: "values-dev.yaml", Path: "image.tag", Value: "v2.0.0"}}}
+		_, err := repo.CommitAppLocal("app-a", "apps", "", content, nil)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "failed to read values file values-dev.yaml")
+	})
+
+	t.Run("A values file outside the repository is rejected", func(t *testing.T) {
+		content := &ArgoOverrideFile{ValuesFiles: []ValuesFileUpdate{{File: "../../../etc/passwd", Path: "image.tag", Value: "v2.0.0"}}}
+		_, err := repo.CommitAppLocal("app-a", "apps", "", content, nil)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "is not inside repository root")
+	})
+}
+
 func TestCommitAppLocal_PathTraversalRejected(t *testing.T) {
 	repo := newTestRepo(t, nil)
 	repo.localRepoPath = t.TempDir()
@@ -532,13 +785,47 @@ func TestMergeOverrideFileContent(t *testing.T) {
 		assert.Error(t, err)
 		assert.Contains(t, err.Error(), "unmarshal")
 	})
+
+	t.Run("Switching to kustomize drops the Helm parameters", func(t *testing.T) {
+		tmpFile := filepath.Join(t.TempDir(), ".argocd-source-app.yaml")
+		require.NoError(t, os.WriteFile(tmpFile, []byte("helm:\n  parameters:\n    - name: image.tag\n      value: v1\n      forceString: true\n"), 0644))
+		kustomizeContent := &ArgoOverrideFile{}
+		kustomizeContent.Kustomize.Images = []KustomizeImageOverride{{Name: "ghcr.io/team/app", NewTag: "v2"}}
+
+		finalContent, err := repo.mergeOverrideFileContent(tmpFile, kustomizeContent)
+		require.NoError(t, err)
+		rendered, err := yaml.Marshal(finalContent)
+		require.NoError(t, err)
+		assert.NotContains(t, string(rendered), "helm")
+		assert.Equal(t, kustomizeContent.Kustomize.Images, finalContent.Kustomize.Images)
+	})
+
+	t.Run("Switching to helm drops the Kustomize images", func(t *testing.T) {
+		tmpFile := filepath.Join(t.TempDir(), ".argocd-source-app.yaml")
+		require.NoError(t, os.WriteFile(tmpFile, []byte("kustomize:\n  images:\n    - ghcr.io/team/app:v1\n"), 0644))
+
+		finalContent, err := repo.mergeOverrideFileContent(tmpFile, newContent)
+		require.NoError(t, err)
+		rendered, err := yaml.Marshal(finalContent)
+		require.NoError(t, err)
+		assert.NotContains(t, string(rendered), "kustomize")
+		assert.Equal(t, newContent.Helm.Parameters, finalContent.Helm.Parameters)
+	})
+}
+
+// overrideFile renders content as the override file at fullPath.
+func overrideFile(t *testing.T, fullPath string, content *ArgoOverrideFile) []appFile {
+	t.Helper()
+	contentBytes, err := yaml.Marshal(content)
+	require.NoError(t, err)
+	return []appFile{{fullPath: fullPath, content: contentBytes}}
 }
 
 // commitLocalAndPush is the single-app compose (commit locally, then push) that
 // UpdateApp performs; tests that assert on remote state use it directly.
 func commitLocalAndPush(t *testing.T, repo *GitRepo, fullPath, msg string, content *ArgoOverrideFile) {
 	t.Helper()
-	committed, err := repo.commitLocal(fullPath, msg, content)
+	committed, err := repo.commitLocal(msg, overrideFile(t, fullPath, content))
 	require.NoError(t, err)
 	require.True(t, committed)
 	require.NoError(t, repo.push(context.Background()))
@@ -562,7 +849,7 @@ func TestCommitLocal_SkipsWhenContentUnchanged(t *testing.T) {
 	headBefore, err := localRepo.Head()
 	require.NoError(t, err)
 
-	committed, err := repo.commitLocal(fullPath, "msg", params)
+	committed, err := repo.commitLocal("msg", overrideFile(t, fullPath, params))
 	require.NoError(t, err)
 	assert.False(t, committed, "unchanged content must report not committed")
 
@@ -650,9 +937,9 @@ func TestCommitLocal_WriteFileError(t *testing.T) {
 	fullPath := filepath.Join(localPath, "apps")
 	require.NoError(t, os.Mkdir(fullPath, 0755))
 
-	_, err = repo.commitLocal(fullPath, "msg", &ArgoOverrideFile{})
+	_, err = repo.commitLocal("msg", overrideFile(t, fullPath, &ArgoOverrideFile{}))
 	assert.Error(t, err)
-	assert.Contains(t, err.Error(), "failed to write override file")
+	assert.Contains(t, err.Error(), "failed to write apps")
 }
 
 func TestGitClient_Coverage(t *testing.T) {
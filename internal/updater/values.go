@@ -0,0 +1,207 @@
+package updater
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// ValuesFileUpdate sets one value of a Helm values file kept in the repository, in
+// place of a parameter in the override file. A chart version task sets the version
+// in the application's manifest the same way.
+type ValuesFileUpdate struct {
+	// File is the values file, relative to the app's write-back path.
+	File string
+	// Path is the dot-separated YAML path of the value, e.g. "image.tag". A number
+	// picks an entry of a list, as in "spec.sources.1.targetRevision".
+	Path  string
+	Value string
+}
+
+// setYAMLValues returns content with the scalar at each update's path replaced by its
+// value. The document is parsed into yaml.v3 nodes only to locate each scalar; the
+// replacement is made on the original bytes, so comments, indentation, quoting and
+// key order are kept exactly as they were.
+func setYAMLValues(content []byte, updates []ValuesFileUpdate) ([]byte, error) {
+	lines := bytes.SplitAfter(content, []byte("\n"))
+
+	for _, update := range updates {
+		var document yaml.Node
+		if err := yaml.Unmarshal(joinLines(lines), &document); err != nil {
+			return nil, fmt.Errorf("failed to parse values file %s: %w", update.File, err)
+		}
+
+		node, err := findYAMLScalar(&document, update.Path)
+		if err != nil {
+			return nil, fmt.Errorf("values file %s: %w", update.File, err)
+		}
+		if node.Value == update.Value {
+			continue
+		}
+
+		line := []rune(string(lines[node.Line-1]))
+		start := node.Column - 1
+		end, err := scalarEnd(line, start, node)
+		if err != nil {
+			return nil, fmt.Errorf("values file %s: path %q: %w", update.File, update.Path, err)
+		}
+
+		replaced := string(line[:start]) + renderScalar(update.Value, node.Style) + string(line[end:])
+		lines[node.Line-1] = []byte(replaced)
+	}
+
+	edited := joinLines(lines)
+	if err := verifyYAMLEdit(content, edited, updates); err != nil {
+		return nil, err
+	}
+	return edited, nil
+}
+
+// verifyYAMLEdit parses the edited document again and checks that it holds what the
+// original did, but for the value at each update's path: a value the rendering got
+// wrong must never reach the repository as extra keys or a broken file.
+func verifyYAMLEdit(original, edited []byte, updates []ValuesFileUpdate) error {
+	if len(updates) == 0 {
+		return nil
+	}
+	file := updates[0].File
+
+	var want yaml.Node
+	if err := yaml.Unmarshal(original, &want); err != nil {
+		return fmt.Errorf("failed to parse values file %s: %w", file, err)
+	}
+	for _, update := range updates {
+		node, err := findYAMLScalar(&want, update.Path)
+		if err != nil {
+			return fmt.Errorf("values file %s: %w", file, err)
+		}
+		node.Value = update.Value
+	}
+
+	var got yaml.Node
+	if err := yaml.Unmarshal(edited, &got); err != nil {
+		return fmt.Errorf("values file %s no longer parses once edited: %w", file, err)
+	}
+	if !sameYAML(&want, &got) {
+		return fmt.Errorf("values file %s: the edit would change more than the values it sets", file)
+	}
+	return nil
+}
+
+// sameYAML reports whether two nodes hold the same kinds and values throughout.
+// Styles, comments and positions are not compared: the edit may requote a value.
+func sameYAML(a, b *yaml.Node) bool {
+	if a.Kind != b.Kind || a.Value != b.Value || len(a.Content) != len(b.Content) {
+		return false
+	}
+	for index := range a.Content {
+		if !sameYAML(a.Content[index], b.Content[index]) {
+			return false
+		}
+	}
+	return true
+}
+
+// findYAMLScalar walks the mappings and lists of document along path and returns the
+// scalar the path ends on.
+func findYAMLScalar(document *yaml.Node, path string) (*yaml.Node, error) {
+	if document.Kind != yaml.DocumentNode || len(document.Content) == 0 {
+		return nil, errors.New("the file holds no YAML document")
+	}
+
+	node := document.Content[0]
+	for _, key := range strings.Split(path, ".") {
+		if node.Kind == yaml.SequenceNode {
+			index, err := strconv.Atoi(key)
+			if err != nil || index < 0 || index >= len(node.Content) {
+				return nil, fmt.Errorf("path %q not found", path)
+			}
+			node = node.Content[index]
+			continue
+		}
+		if node.Kind != yaml.MappingNode {
+			return nil, fmt.Errorf("path %q does not lead through mappings", path)
+		}
+
+		var value *yaml.Node
+		for index := 0; index+1 < len(node.Content); index += 2 {
+			if node.Content[index].Value == key {
+				value = node.Content[index+1]
+				break
+			}
+		}
+		if value == nil {
+			return nil, fmt.Errorf("path %q not found", path)
+		}
+		node = value
+	}
+
+	if node.Kind != yaml.ScalarNode {
+		return nil, fmt.Errorf("path %q does not hold a scalar value", path)
+	}
+	return node, nil
+}
+
+// scalarEnd returns the index in line just past the scalar node that starts at start.
+// Only scalars written on a single line can be replaced in place.
+func scalarEnd(line []rune, start int, node *yaml.Node) (int, error) {
+	switch node.Style {
+	case 0:
+		end := start + len([]rune(node.Value))
+		if end > len(line) || string(line[start:end]) != node.Value {
+			return 0, errors.New("the value is not written on a single line")
+		}
+		return end, nil
+	case yaml.SingleQuotedStyle:
+		for index := start + 1; index < len(line); index++ {
+			if line[index] != '\'' {
+				continue
+			}
+			if index+1 < len(line) && line[index+1] == '\'' {
+				index++
+				continue
+			}
+			return index + 1, nil
+		}
+	case yaml.DoubleQuotedStyle:
+		for index := start + 1; index < len(line); index++ {
+			if line[index] == '\\' {
+				index++
+				continue
+			}
+			if line[index] == '"' {
+				return index + 1, nil
+			}
+		}
+	}
+	return 0, errors.New("the value is not written on a single line")
+}
+
+// renderScalar writes value in style. A plain value that YAML would read as anything
+// but a string — "1.10" or "true" — is double-quoted, so Helm still receives a string.
+func renderScalar(value string, style yaml.Style) string {
+	switch style {
+	case yaml.SingleQuotedStyle:
+		return "'" + strings.ReplaceAll(value, "'", "''") + "'"
+	case yaml.DoubleQuotedStyle:
+		return quoteScalar(value)
+	}
+
+	plain := yaml.Node{Kind: yaml.ScalarNode, Value: value}
+	if value == "" || plain.ShortTag() != "!!str" || strings.ContainsAny(value, ":#{}[],&*!|>'\"%@`") {
+		return quoteScalar(value)
+	}
+	return value
+}
+
+func quoteScalar(value string) string {
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(value) + `"`
+}
+
+func joinLines(lines [][]byte) []byte {
+	return bytes.Join(lines, nil)
+}
@@ -0,0 +1,114 @@
+package updater
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const valuesFile = `# Production values
+replicaCount: 3
+
+image:
+  repository: example.com/app # pushed by CI
+  tag: v1.0.0   # bumped by argo-watcher
+worker:
+  image: {repository: example.com/worker, tag: "v1.0.0"}
+sidecar:
+  tag: 'v1'
+notes: |
+  tag: v1.0.0
+`
+
+func TestSetYAMLValues(t *testing.T) {
+	update := func(path, value string) ValuesFileUpdate {
+		return ValuesFileUpdate{File: "values-prod.yaml", Path: path, Value: value}
+	}
+
+	t.Run("Replaces only the values, keeping comments and formatting", func(t *testing.T) {
+		content, err := setYAMLValues([]byte(valuesFile), []ValuesFileUpdate{
+			update("image.tag", "v2.0.0"),
+			update("worker.image.tag", "v2.0.0"),
+			update("sidecar.tag", "it's"),
+		})
+		require.NoError(t, err)
+
+		assert.Equal(t, `# Production values
+replicaCount: 3
+
+image:
+  repository: example.com/app # pushed by CI
+  tag: v2.0.0   # bumped by argo-watcher
+worker:
+  image: {repository: example.com/worker, tag: "v2.0.0"}
+sidecar:
+  tag: 'it''s'
+notes: |
+  tag: v1.0.0
+`, string(content))
+	})
+
+	t.Run("Quotes a plain value YAML would not read as a string", func(t *testing.T) {
+		for value, expected := range map[string]string{
+			"1.10":       `"1.10"`,
+			"true":       `"true"`,
+			"sha256:abc": `"sha256:abc"`,
+			"v1.10":      "v1.10",
+		} {
+			content, err := setYAMLValues([]byte("tag: v1\n"), []ValuesFileUpdate{update("tag", value)})
+			require.NoError(t, err)
+			assert.Equal(t, "tag: "+expected+"\n", string(content), value)
+		}
+	})
+
+	t.Run("Leaves a file already holding the value as it is", func(t *testing.T) {
+		content, err := setYAMLValues([]byte(valuesFile), []ValuesFileUpdate{update("image.tag", "v1.0.0")})
+		require.NoError(t, err)
+		assert.Equal(t, valuesFile, string(content))
+	})
+
+	t.Run("Follows list indexes", func(t *testing.T) {
+		manifest := `spec:
+  sources:
+    - repoURL: https://git.example.com/gitops.git
+      targetRevision: main
+    - chart: web
+      targetRevision: 1.3.0
+`
+		content, err := setYAMLValues([]byte(manifest), []ValuesFileUpdate{update("spec.sources.1.targetRevision", "1.4.0")})
+		require.NoError(t, err)
+		assert.Equal(t, `spec:
+  sources:
+    - repoURL: https://git.example.com/gitops.git
+      targetRevision: main
+    - chart: web
+      targetRevision: 1.4.0
+`, string(content))
+
+		_, err = setYAMLValues([]byte(manifest), []ValuesFileUpdate{update("spec.sources.2.targetRevision", "1.4.0")})
+		assert.ErrorContains(t, err, `path "spec.sources.2.targetRevision" not found`)
+	})
+
+	t.Run("Refuses an edit that would change more than the value", func(t *testing.T) {
+		for _, value := range []string{"v2\nreplicaCount: 0", " v2", "v2\"\nreplicaCount: 0\n#"} {
+			_, err := setYAMLValues([]byte(valuesFile), []ValuesFileUpdate{update("image.tag", value)})
+			require.Error(t, err, value)
+			assert.Equal(t, "values file values-prod.yaml: the edit would change more than the values it sets", err.Error(), value)
+		}
+	})
+
+	t.Run("Rejects paths it cannot set in place", func(t *testing.T) {
+		for path, message := range map[string]string{
+			"image.digest":   `path "image.digest" not found`,
+			"image":          `path "image" does not hold a scalar value`,
+			"replicaCount.x": `path "replicaCount.x" does not lead through mappings`,
+			"notes":          "the value is not written on a single line",
+		} {
+			_, err := setYAMLValues([]byte(valuesFile), []ValuesFileUpdate{update(path, "v2")})
+			require.Error(t, err, path)
+			assert.Contains(t, err.Error(), "values file values-prod.yaml")
+			assert.Contains(t, err.Error(), message)
+		}
+	})
+}
@@ -40,6 +40,9 @@ const ALLOWED_TASK_STATUSES: ReadonlySet<string> = new Set([
   'deployed',
   'accepted',
   'cancelled',
+  'paused',
+  'queued',
+  'awaiting merge',
 ]);
 
 const toUnixSeconds = (value: Date | string | number | undefined, fallback: number): number => {
@@ -1,6 +1,14 @@
 export interface Image {
   image: string;
   tag: string;
+  digest?: string;
+  signer?: string;
+}
+
+export interface Parameter {
+  name: string;
+  value: string;
+  force_string?: boolean;
 }
 
 export interface Task {
@@ -11,10 +19,20 @@ export interface Task {
   author: string;
   project: string;
   images: Image[];
+  parameters?: Parameter[];
+  chart_version?: string;
   status?: string;
   status_reason?: string;
   is_rollback?: boolean;
   rollback_target_id?: string;
+  rollback_of_id?: string;
+  write_back?: WriteBack;
+}
+
+export interface WriteBack {
+  commit_sha?: string;
+  signing_key?: string;
+  synced_revision?: string;
 }
 
 export interface TasksResponse {
@@ -31,10 +49,14 @@ export interface TaskStatus {
   author?: string;
   project?: string;
   images?: Image[];
+  parameters?: Parameter[];
+  chart_version?: string;
   status?: string;
   status_reason?: string;
   is_rollback?: boolean;
   rollback_target_id?: string;
+  rollback_of_id?: string;
+  write_back?: WriteBack;
   error?: string;
 }
 
@@ -247,6 +247,107 @@ describe('TaskShow', () => {
       await renderWithRouter('/task/task-1');
       expect(screen.getByText('A previously deployed version')).toBeInTheDocument();
     });
+
+    it('links an automatic rollback to the failed task it reverts', async () => {
+      mockUseGetOne.mockReturnValue({
+        data: buildTask({
+          is_rollback: true,
+          rollback_target_id: 'abcdef12-3456-4789-8abc-def012345678',
+          rollback_of_id: '12345678-90ab-4cde-8f01-234567890abc',
+        }),
+        isLoading: false,
+        isError: false,
+        refetch: vi.fn(),
+      });
+
+      await renderWithRouter('/task/task-1');
+      expect(screen.getByText(/Reverts failed task/i)).toBeInTheDocument();
+      const link = screen.getByRole('link', { name: '12345678' });
+      expect(link).toHaveAttribute('href', '/task/12345678-90ab-4cde-8f01-234567890abc');
+    });
+  });
+
+  it('shows the write-back commit and the revision Argo CD synced', async () => {
+    mockUseGetOne.mockReturnValue({
+      data: buildTask({
+        write_back: {
+          commit_sha: '3f786850e387550fdab836ed7e6dc881de23001b',
+          synced_revision: '1b6453892473a467d07372d45eb05abc2031647a',
+        },
+      }),
+      isLoading: false,
+      isError: false,
+      refetch: vi.fn(),
+    });
+
+    await renderWithRouter('/task/task-1');
+    expect(screen.getByText('3f786850e387550fdab836ed7e6dc881de23001b')).toBeInTheDocument();
+    expect(screen.getByText(/Revision synced by Argo CD/i)).toBeInTheDocument();
+    expect(screen.getByText('1b6453892473a467d07372d45eb05abc2031647a')).toBeInTheDocument();
+  });
+
+  it('shows the parameters the task wrote back', async () => {
+    mockUseGetOne.mockReturnValue({
+      data: buildTask({ parameters: [{ name: 'features.checkout', value: 'true' }] }),
+      isLoading: false,
+      isError: false,
+      refetch: vi.fn(),
+    });
+
+    await renderWithRouter('/task/task-1');
+    expect(screen.getByText(/^Parameters$/i)).toBeInTheDocument();
+    expect(screen.getByText('features.checkout=true')).toBeInTheDocument();
+  });
+
+  it('shows the digest an image is pinned to', async () => {
+    mockUseGetOne.mockReturnValue({
+      data: buildTask({ images: [{ image: 'ghcr.io/shini4i/app', tag: 'v1.2.3', digest: 'sha256:abc' }] }),
+      isLoading: false,
+      isError: false,
+      refetch: vi.fn(),
+    });
+
+    await renderWithRouter('/task/task-1');
+    expect(screen.getByText('v1.2.3')).toBeInTheDocument();
+    expect(screen.getByText('sha256:abc')).toBeInTheDocument();
+  });
+
+  it('shows the keys whose signatures of an image were verified', async () => {
+    mockUseGetOne.mockReturnValue({
+      data: buildTask({ images: [{ image: 'ghcr.io/shini4i/app', tag: 'v1.2.3', signer: 'release' }] }),
+      isLoading: false,
+      isError: false,
+      refetch: vi.fn(),
+    });
+
+    await renderWithRouter('/task/task-1');
+    expect(screen.getByText('signed by release')).toBeInTheDocument();
+  });
+
+  it('shows the chart version the task deploys', async () => {
+    mockUseGetOne.mockReturnValue({
+      data: buildTask({ images: [], chart_version: '1.4.0' }),
+      isLoading: false,
+      isError: false,
+      refetch: vi.fn(),
+    });
+
+    await renderWithRouter('/task/task-1');
+    expect(screen.getByText(/^Chart version$/i)).toBeInTheDocument();
+    expect(screen.getByText('1.4.0')).toBeInTheDocument();
+  });
+
+  it('shows the key the write-back commit was signed with', async () => {
+    mockUseGetOne.mockReturnValue({
+      data: buildTask({ write_back: { signing_key: 'ssh:SHA256:uNiVztksCsDhcc0u9e8BujQXVUpKZIDTMczCvj3tD2s' } }),
+      isLoading: false,
+      isError: false,
+      refetch: vi.fn(),
+    });
+
+    await renderWithRouter('/task/task-1');
+    expect(screen.getByText(/Commit signed with/i)).toBeInTheDocument();
+    expect(screen.getByText('ssh:SHA256:uNiVztksCsDhcc0u9e8BujQXVUpKZIDTMczCvj3tD2s')).toBeInTheDocument();
   });
 
   it('shows loading indicator while fetching data', async () => {
@@ -386,6 +487,7 @@ describe('TaskShow', () => {
     });
     expect((options as { body: Record<string, unknown> }).body).toMatchObject({
       author: 'user@example.com',
+      rollback: true,
     });
     expect((options as { headers?: Record<string, string> }).headers).toMatchObject({
       'Oidc-Authorization': 'Bearer token',
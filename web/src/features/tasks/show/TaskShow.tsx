@@ -323,6 +323,7 @@ export const TaskShow = () => {
         body: {
           ...data,
           author: identityEmail,
+          rollback: true,
         },
       });
 
@@ -432,6 +433,42 @@ export const TaskShow = () => {
                       }
                     />
                   )}
+                  {data.rollback_of_id && (
+                    <InfoField
+                      label="Reverts failed task"
+                      value={
+                        <Link component={RouterLink} to={`/task/${data.rollback_of_id}`}>
+                          {data.rollback_of_id.slice(0, 8)}
+                        </Link>
+                      }
+                    />
+                  )}
+                  {data.chart_version && (
+                    <InfoField label="Chart version" value={data.chart_version} />
+                  )}
+                  {(data.parameters?.length ?? 0) > 0 && (
+                    <InfoField
+                      label="Parameters"
+                      value={
+                        <Stack spacing={0.25}>
+                          {data.parameters?.map(parameter => (
+                            <Typography key={parameter.name} variant="body2" sx={{ fontFamily: 'monospace' }}>
+                              {parameter.name}={parameter.value}
+                            </Typography>
+                          ))}
+                        </Stack>
+                      }
+                    />
+                  )}
+                  {data.write_back?.commit_sha && (
+                    <InfoField label="Write-back commit" value={data.write_back.commit_sha} />
+                  )}
+                  {data.write_back?.signing_key && (
+                    <InfoField label="Commit signed with" value={data.write_back.signing_key} />
+                  )}
+                  {data.write_back?.synced_revision && (
+                    <InfoField label="Revision synced by Argo CD" value={data.write_back.synced_revision} />
+                  )}
                 </Stack>
               </Grid>
               <Grid size={{ xs: 12, md: 6 }}>
@@ -699,6 +736,14 @@ const ImagesList = ({
             {image.image}
           </Typography>
           <Chip label={image.tag} size="small" color="primary" variant="outlined" />
+          {image.signer && (
+            <Chip label={`signed by ${image.signer}`} size="small" color="success" variant="outlined" />
+          )}
+          {image.digest && (
+            <Typography variant="caption" sx={{ fontFamily: 'monospace', color: 'text.secondary', wordBreak: 'break-all' }}>
+              {image.digest}
+            </Typography>
+          )}
         </Stack>
       ))}
       {hasAdditional && (
@@ -32,6 +32,16 @@ const statusCases: StatusCase[] = [
       reasonSeverity: 'success',
     },
   },
+  {
+    status: 'paused',
+    expected: {
+      label: 'Paused',
+      displayLabel: 'Paused',
+      chipColor: 'success',
+      timelineDotColor: 'success',
+      reasonSeverity: 'success',
+    },
+  },
   {
     status: 'failed',
     expected: {
@@ -62,6 +72,26 @@ const statusCases: StatusCase[] = [
       reasonSeverity: 'info',
     },
   },
+  {
+    status: 'queued',
+    expected: {
+      label: 'Queued',
+      displayLabel: 'Queued',
+      chipColor: 'default',
+      timelineDotColor: 'default',
+      reasonSeverity: 'info',
+    },
+  },
+  {
+    status: 'awaiting merge',
+    expected: {
+      label: 'Awaiting Merge',
+      displayLabel: 'Awaiting merge',
+      chipColor: 'default',
+      timelineDotColor: 'default',
+      reasonSeverity: 'info',
+    },
+  },
   {
     status: 'cancelled',
     expected: {
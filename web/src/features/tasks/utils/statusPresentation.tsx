@@ -3,7 +3,10 @@ import type { AlertColor } from '@mui/material/Alert';
 import type { ChipProps } from '@mui/material/Chip';
 import CheckCircleOutlineIcon from '@mui/icons-material/CheckCircleOutlined';
 import CancelOutlinedIcon from '@mui/icons-material/CancelOutlined';
+import PauseCircleOutlineIcon from '@mui/icons-material/PauseCircleOutlined';
 import ErrorOutlineIcon from '@mui/icons-material/ErrorOutlined';
+import HourglassEmptyIcon from '@mui/icons-material/HourglassEmpty';
+import MergeTypeIcon from '@mui/icons-material/MergeType';
 import CircularProgress from '@mui/material/CircularProgress';
 import { tokens } from '../../../theme/tokens';
 
@@ -53,6 +56,19 @@ export const describeTaskStatus = (status?: string | null): TaskStatusPresentati
         pillBgDark: tokens.statusDeployedBgDark,
         pillFgDark: tokens.statusDeployedFgDark,
       };
+    case 'paused':
+      return {
+        label: 'Paused',
+        displayLabel: 'Paused',
+        chipColor: 'success',
+        timelineDotColor: 'success',
+        reasonSeverity: 'success',
+        icon: <PauseCircleOutlineIcon fontSize="small" />,
+        pillBg: tokens.statusDeployedBg,
+        pillFg: tokens.statusDeployedFg,
+        pillBgDark: tokens.statusDeployedBgDark,
+        pillFgDark: tokens.statusDeployedFgDark,
+      };
     case 'failed':
       return {
         label: 'Failed',
@@ -79,6 +95,32 @@ export const describeTaskStatus = (status?: string | null): TaskStatusPresentati
         pillBgDark: tokens.statusRunningBgDark,
         pillFgDark: tokens.statusRunningFgDark,
       };
+    case 'queued':
+      return {
+        label: 'Queued',
+        displayLabel: 'Queued',
+        chipColor: 'default',
+        timelineDotColor: 'default',
+        reasonSeverity: 'info',
+        icon: <HourglassEmptyIcon fontSize="small" />,
+        pillBg: tokens.statusInfoBg,
+        pillFg: tokens.statusInfoFg,
+        pillBgDark: tokens.statusInfoBgDark,
+        pillFgDark: tokens.statusInfoFgDark,
+      };
+    case 'awaiting merge':
+      return {
+        label: 'Awaiting Merge',
+        displayLabel: 'Awaiting merge',
+        chipColor: 'default',
+        timelineDotColor: 'default',
+        reasonSeverity: 'info',
+        icon: <MergeTypeIcon fontSize="small" />,
+        pillBg: tokens.statusInfoBg,
+        pillFg: tokens.statusInfoFg,
+        pillBgDark: tokens.statusInfoBgDark,
+        pillFgDark: tokens.statusInfoFgDark,
+      };
     case 'cancelled':
       return {
         label: 'Cancelled',